@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/je265/oceanproxy/internal/app"
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+// applyDocument reconciles plans with a YAML document, like POST /api/v1/apply.
+// The file comes first, since the CLI's own flags stop at the first argument;
+// "-" reads the document from stdin.
+func applyDocument(services *app.Services, args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: apply <file|-> [--dry-run] [--prune]")
+		os.Exit(1)
+	}
+	flags := flag.NewFlagSet("apply", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "Only print the changes")
+	prune := flags.Bool("prune", false, "Delete previously applied plans missing from the document")
+	flags.Parse(args[1:])
+
+	var data []byte
+	var err error
+	if args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read document: %v\n", err)
+		os.Exit(1)
+	}
+
+	var doc domain.ApplyDocument
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&doc); err != nil && err != io.EOF {
+		fmt.Fprintf(os.Stderr, "Failed to parse document: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Track the ports of existing instances, as the server does on startup,
+	// so created plans get free ones
+	ctx := context.Background()
+	instances, err := services.InstanceRepo.GetAll(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get instances: %v\n", err)
+		os.Exit(1)
+	}
+	services.PortManager.Reconcile(ctx, instances)
+
+	result, err := services.Apply.Apply(ctx, &doc, *dryRun, *prune)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Apply failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch {
+	case out.json:
+		writeJSON(result)
+	case out.human():
+		if len(result.Changes) == 0 {
+			fmt.Println("No changes")
+			break
+		}
+		fmt.Printf("%-10s %-30s %-10s %s\n", "Action", "Plan", "Applied", "Detail")
+		for _, change := range result.Changes {
+			detail := change.Detail
+			if change.Error != "" {
+				detail = "error: " + change.Error
+			}
+			fmt.Printf("%-10s %-30s %-10t %s\n", change.Action, truncate(change.Name, 30), change.Applied, detail)
+		}
+		if result.DryRun {
+			fmt.Println("Dry run: nothing was changed")
+		}
+	}
+
+	if result.Failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d changes failed\n", result.Failed)
+		os.Exit(1)
+	}
+}
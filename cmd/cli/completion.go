@@ -0,0 +1,313 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// completionShells are the shells completion can generate scripts for
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// cliFlag is a global flag as seen by completion and the man page
+type cliFlag struct {
+	Name    string
+	Usage   string
+	Default string
+	IsBool  bool
+}
+
+// globalFlags returns the flags registered on the default flag set, sorted by name
+func globalFlags() []cliFlag {
+	var flags []cliFlag
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, cliFlag{
+			Name:    f.Name,
+			Usage:   f.Usage,
+			Default: f.DefValue,
+			IsBool:  ok && boolFlag.IsBoolFlag(),
+		})
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+// completion prints a completion script for the shell named in args
+func completion(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: completion <%s>\n", strings.Join(completionShells, "|"))
+		os.Exit(1)
+	}
+
+	var err error
+	switch args[0] {
+	case "bash":
+		err = writeBashCompletion(os.Stdout)
+	case "zsh":
+		err = writeZshCompletion(os.Stdout)
+	case "fish":
+		err = writeFishCompletion(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "Unsupported shell %q, expected one of: %s\n", args[0], strings.Join(completionShells, ", "))
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write completion script: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func writeBashCompletion(w io.Writer) error {
+	var flagWords []string
+	for _, f := range globalFlags() {
+		flagWords = append(flagWords, "-"+f.Name)
+	}
+
+	var b strings.Builder
+	b.WriteString("# bash completion for oceanproxy-cli\n")
+	b.WriteString("# Load with: source <(oceanproxy-cli -command completion bash)\n\n")
+	b.WriteString("_oceanproxy_cli() {\n")
+	b.WriteString("    local cur prev cmd i\n")
+	b.WriteString("    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n\n")
+	b.WriteString("    case \"$prev\" in\n")
+	fmt.Fprintf(&b, "        -command|--command)\n            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n            return ;;\n", strings.Join(commandNames(), " "))
+	b.WriteString("        -config|--config)\n            COMPREPLY=($(compgen -f -- \"$cur\"))\n            return ;;\n")
+	b.WriteString("    esac\n\n")
+	b.WriteString("    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(flagWords, " "))
+	b.WriteString("        return\n")
+	b.WriteString("    fi\n\n")
+	b.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	b.WriteString("        if [[ \"${COMP_WORDS[i]}\" == -command || \"${COMP_WORDS[i]}\" == --command ]]; then\n")
+	b.WriteString("            cmd=\"${COMP_WORDS[i+1]}\"\n")
+	b.WriteString("        fi\n")
+	b.WriteString("    done\n\n")
+	b.WriteString("    case \"$cmd\" in\n")
+	for _, cmd := range commands {
+		if len(cmd.Words) == 0 {
+			continue
+		}
+		names := append([]string{cmd.Name}, cmd.Aliases...)
+		fmt.Fprintf(&b, "        %s)\n            COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", strings.Join(names, "|"), strings.Join(cmd.Words, " "))
+	}
+	b.WriteString("        *)\n            COMPREPLY=($(compgen -f -- \"$cur\")) ;;\n")
+	b.WriteString("    esac\n")
+	b.WriteString("}\n\n")
+	b.WriteString("complete -F _oceanproxy_cli oceanproxy-cli\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeZshCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("#compdef oceanproxy-cli\n")
+	b.WriteString("# zsh completion for oceanproxy-cli\n")
+	b.WriteString("# Install as _oceanproxy-cli in a directory on $fpath, or load with:\n")
+	b.WriteString("#   source <(oceanproxy-cli -command completion zsh)\n\n")
+	b.WriteString("_oceanproxy_cli() {\n")
+	b.WriteString("    local -a commands\n")
+	b.WriteString("    commands=(\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "        %s\n", zshQuote(cmd.Name+":"+cmd.Summary))
+		for _, alias := range cmd.Aliases {
+			fmt.Fprintf(&b, "        %s\n", zshQuote(alias+":"+"Alias for "+cmd.Name))
+		}
+	}
+	b.WriteString("    )\n\n")
+	b.WriteString("    local state line\n")
+	b.WriteString("    _arguments -C \\\n")
+	for _, f := range globalFlags() {
+		switch {
+		case f.Name == "command":
+			fmt.Fprintf(&b, "        %s \\\n", zshQuote("-command["+zshEscape(f.Usage)+"]:command:->command"))
+		case f.Name == "config":
+			fmt.Fprintf(&b, "        %s \\\n", zshQuote("-config["+zshEscape(f.Usage)+"]:file:_files"))
+		case f.IsBool:
+			fmt.Fprintf(&b, "        %s \\\n", zshQuote("-"+f.Name+"["+zshEscape(f.Usage)+"]"))
+		default:
+			fmt.Fprintf(&b, "        %s \\\n", zshQuote("-"+f.Name+"["+zshEscape(f.Usage)+"]:"+f.Name+":"))
+		}
+	}
+	b.WriteString("        '*::arg:->args'\n\n")
+	b.WriteString("    case $state in\n")
+	b.WriteString("        command)\n")
+	b.WriteString("            _describe 'command' commands ;;\n")
+	b.WriteString("        args)\n")
+	b.WriteString("            case ${opt_args[-command]} in\n")
+	for _, cmd := range commands {
+		if len(cmd.Words) == 0 {
+			continue
+		}
+		names := append([]string{cmd.Name}, cmd.Aliases...)
+		fmt.Fprintf(&b, "                %s) compadd -- %s ;;\n", strings.Join(names, "|"), strings.Join(cmd.Words, " "))
+	}
+	b.WriteString("                *) _files ;;\n")
+	b.WriteString("            esac ;;\n")
+	b.WriteString("    esac\n")
+	b.WriteString("}\n\n")
+	b.WriteString("if [ \"$funcstack[1]\" = \"_oceanproxy_cli\" ]; then\n")
+	b.WriteString("    _oceanproxy_cli \"$@\"\n")
+	b.WriteString("else\n")
+	b.WriteString("    compdef _oceanproxy_cli oceanproxy-cli\n")
+	b.WriteString("fi\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeFishCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# fish completion for oceanproxy-cli\n")
+	b.WriteString("# Install with: oceanproxy-cli -command completion fish > ~/.config/fish/completions/oceanproxy-cli.fish\n\n")
+	b.WriteString("complete -c oceanproxy-cli -f\n")
+	for _, f := range globalFlags() {
+		switch {
+		case f.Name == "command":
+			continue
+		case f.Name == "config":
+			fmt.Fprintf(&b, "complete -c oceanproxy-cli -o config -r -F -d %s\n", fishQuote(f.Usage))
+		case f.IsBool:
+			fmt.Fprintf(&b, "complete -c oceanproxy-cli -o %s -d %s\n", f.Name, fishQuote(f.Usage))
+		default:
+			fmt.Fprintf(&b, "complete -c oceanproxy-cli -o %s -x -d %s\n", f.Name, fishQuote(f.Usage))
+		}
+	}
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "complete -c oceanproxy-cli -o command -x -a %s -d %s\n", cmd.Name, fishQuote(cmd.Summary))
+		for _, alias := range cmd.Aliases {
+			fmt.Fprintf(&b, "complete -c oceanproxy-cli -o command -x -a %s -d %s\n", alias, fishQuote("Alias for "+cmd.Name))
+		}
+	}
+	for _, cmd := range commands {
+		if len(cmd.Words) == 0 {
+			continue
+		}
+		names := append([]string{cmd.Name}, cmd.Aliases...)
+		fmt.Fprintf(&b, "complete -c oceanproxy-cli -n '__fish_seen_subcommand_from %s' -a %s\n", strings.Join(names, " "), fishQuote(strings.Join(cmd.Words, " ")))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// zshQuote single-quotes s for zsh
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// zshEscape escapes characters _arguments treats specially in descriptions
+func zshEscape(s string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+// fishQuote single-quotes s for fish
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// manPage prints the oceanproxy-cli(1) man page, or writes it into the
+// directory given in args
+func manPage(args []string) {
+	if len(args) == 0 {
+		if err := writeManPage(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write man page: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	path := filepath.Join(args[0], "oceanproxy-cli.1")
+	if err := os.MkdirAll(args[0], 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create man page: %v\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	if err := writeManPage(file); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write man page: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %s\n", path)
+}
+
+// manDate is the page date; SOURCE_DATE_EPOCH keeps packaged builds reproducible
+func manDate() string {
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		return time.Unix(epoch, 0).UTC().Format("January 2006")
+	}
+	return time.Now().Format("January 2006")
+}
+
+func writeManPage(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, ".TH OCEANPROXY-CLI 1 %q %q \"OceanProxy Manual\"\n", manDate(), "OceanProxy CLI v"+version)
+	b.WriteString(".SH NAME\n")
+	b.WriteString("oceanproxy-cli \\- command line interface for OceanProxy management\n")
+	b.WriteString(".SH SYNOPSIS\n")
+	b.WriteString(".B oceanproxy-cli\n")
+	b.WriteString("[\\fIflags\\fR] \\fB\\-command\\fR \\fIcommand\\fR [\\fIargs\\fR...]\n")
+	b.WriteString(".SH DESCRIPTION\n")
+	b.WriteString("Manages OceanProxy plans and proxy instances directly against the server's\n")
+	b.WriteString("data files, and provides setup, backup and release tooling.\n")
+	b.WriteString("Commands accept the aliases listed with them.\n")
+
+	b.WriteString(".SH OPTIONS\n")
+	for _, f := range globalFlags() {
+		b.WriteString(".TP\n")
+		if f.IsBool {
+			fmt.Fprintf(&b, ".B \\-%s\n", f.Name)
+		} else {
+			fmt.Fprintf(&b, ".BI \\-%s \" %s\"\n", f.Name, f.Name)
+		}
+		b.WriteString(roffEscape(f.Usage))
+		if !f.IsBool && f.Default != "" {
+			fmt.Fprintf(&b, " (default %s)", roffEscape(f.Default))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString(".SH COMMANDS\n")
+	for _, cmd := range commands {
+		b.WriteString(".TP\n")
+		fmt.Fprintf(&b, ".B %s\n", roffEscape(cmd.usageLine()))
+		b.WriteString(roffEscape(cmd.summaryWithAliases()) + "\n")
+	}
+
+	b.WriteString(".SH ENVIRONMENT\n")
+	b.WriteString(".TP\n.B NO_COLOR\nDisables colors in \\-watch output.\n")
+	b.WriteString(".SH EXIT STATUS\n")
+	b.WriteString("0 on success; 1 on errors or when health\\-check finds a failed instance.\n")
+
+	b.WriteString(".SH EXAMPLES\n")
+	for _, example := range examples {
+		b.WriteString(".PP\n.nf\n")
+		b.WriteString(roffEscape(example) + "\n")
+		b.WriteString(".fi\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// roffEscape escapes backslashes, hyphens and leading dots for roff
+func roffEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/je265/oceanproxy/configs"
+)
+
+// initOptions are the answers collected by the init command
+type initOptions struct {
+	ConfigDir      string
+	DataDir        string
+	LogDir         string
+	ProxyConfigDir string
+	NginxConfDir   string
+	Domain         string
+	Port           string
+	Regions        []string
+	User           string
+	Binary         string
+	UnitPath       string
+
+	ProxiesFoAPIKey string
+	NettifyAPIKey   string
+}
+
+// prompter asks questions on stdin; with useDefaults every default is accepted
+type prompter struct {
+	in          *bufio.Reader
+	useDefaults bool
+}
+
+func (p *prompter) ask(question, def string) string {
+	if p.useDefaults {
+		return def
+	}
+
+	if def != "" {
+		fmt.Printf("%s [%s]: ", question, def)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+
+	line, err := p.in.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" || (err != nil && err != io.EOF) {
+		if err == io.EOF {
+			// Non-interactive input: take the remaining defaults
+			p.useDefaults = true
+			fmt.Println()
+		}
+		return def
+	}
+	return line
+}
+
+func (p *prompter) confirm(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	answer := strings.ToLower(p.ask(question+" ("+hint+")", ""))
+	if answer == "" {
+		return def
+	}
+	return answer == "y" || answer == "yes"
+}
+
+// initDeployment bootstraps a server: config files, directories, systemd
+// unit and a check for the 3proxy and nginx binaries
+func initDeployment(args []string) {
+	p := &prompter{
+		in:          bufio.NewReader(os.Stdin),
+		useDefaults: len(args) > 0 && args[0] == "defaults",
+	}
+
+	fmt.Println("OceanProxy deployment setup")
+	fmt.Println()
+
+	opts := initOptions{
+		ConfigDir:      p.ask("Config directory", "/etc/oceanproxy"),
+		DataDir:        p.ask("Data directory", "/var/lib/oceanproxy/data"),
+		LogDir:         p.ask("Log directory", "/var/log/oceanproxy"),
+		ProxyConfigDir: p.ask("3proxy config directory", "/etc/3proxy"),
+		NginxConfDir:   p.ask("Nginx conf.d directory", "/etc/nginx/conf.d"),
+		Domain:         p.ask("Proxy domain", "oceanproxy.io"),
+		Port:           p.ask("API port", "8080"),
+		User:           p.ask("Service user", "oceanproxy"),
+		Binary:         p.ask("Server binary", "/usr/local/bin/oceanproxy"),
+		UnitPath:       p.ask("Systemd unit", "/etc/systemd/system/oceanproxy.service"),
+	}
+	if _, err := strconv.Atoi(opts.Port); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid API port %q\n", opts.Port)
+		os.Exit(1)
+	}
+	for _, region := range strings.Split(p.ask("Regions to serve (comma separated)", "usa,eu,alpha"), ",") {
+		if region = strings.TrimSpace(region); region != "" {
+			opts.Regions = append(opts.Regions, region)
+		}
+	}
+	opts.ProxiesFoAPIKey = p.ask("Proxies.fo API key (blank to skip)", "")
+	opts.NettifyAPIKey = p.ask("Nettify API key (blank to skip)", "")
+	fmt.Println()
+
+	dirs := []struct {
+		path string
+		mode os.FileMode
+	}{
+		{opts.ConfigDir, 0755},
+		{opts.DataDir, 0750},
+		{opts.LogDir, 0750},
+		{opts.ProxyConfigDir, 0750},
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir.path, dir.mode); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create %s: %v\n", dir.path, err)
+			os.Exit(1)
+		}
+		if err := os.Chmod(dir.path, dir.mode); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to set permissions on %s: %v\n", dir.path, err)
+		}
+		fmt.Printf("✓ Directory %s (%o)\n", dir.path, dir.mode)
+	}
+
+	configData, err := renderInitConfig(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate config.yaml: %v\n", err)
+		os.Exit(1)
+	}
+	regionsData, err := renderInitRegions(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate regions.yaml: %v\n", err)
+		os.Exit(1)
+	}
+	plansData, err := renderInitPlans(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate proxy-plans.yaml: %v\n", err)
+		os.Exit(1)
+	}
+
+	files := []struct {
+		path string
+		data []byte
+		mode os.FileMode
+	}{
+		{filepath.Join(opts.ConfigDir, "config.yaml"), configData, 0640},
+		{filepath.Join(opts.ConfigDir, "regions.yaml"), regionsData, 0644},
+		{filepath.Join(opts.ConfigDir, "proxy-plans.yaml"), plansData, 0644},
+		{filepath.Join(opts.ConfigDir, "oceanproxy.env"), renderInitEnv(opts), 0600},
+		{opts.UnitPath, renderInitUnit(opts), 0644},
+	}
+	var written []string
+	for _, file := range files {
+		if _, err := os.Stat(file.path); err == nil && !p.confirm(file.path+" exists, overwrite?", false) {
+			fmt.Printf("- Kept existing %s\n", file.path)
+			continue
+		}
+		if err := os.WriteFile(file.path, file.data, file.mode); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write %s: %v\n", file.path, err)
+			os.Exit(1)
+		}
+		written = append(written, file.path)
+		fmt.Printf("✓ Wrote %s (%o)\n", file.path, file.mode)
+	}
+
+	// The service user owns its writable directories and can read its secrets
+	owned := append([]string{opts.DataDir, opts.LogDir, opts.ProxyConfigDir}, written...)
+	chownToServiceUser(opts.User, owned)
+
+	fmt.Println()
+	checkBinary("3proxy")
+	checkBinary("nginx")
+
+	fmt.Println()
+	fmt.Println("Next steps:")
+	fmt.Println("  systemctl daemon-reload")
+	fmt.Println("  systemctl enable --now oceanproxy")
+	fmt.Printf("  curl http://localhost:%s/ready\n", opts.Port)
+}
+
+// renderInitConfig customizes the sample config.yaml, keeping its comments
+func renderInitConfig(opts initOptions) ([]byte, error) {
+	doc, err := parseYAML(configs.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{
+		"environment":          "production",
+		"server.port":          opts.Port,
+		"database.dsn":         filepath.Join(opts.DataDir, "proxies.json"),
+		"proxy.domain":         opts.Domain,
+		"proxy.config_dir":     opts.ProxyConfigDir,
+		"proxy.log_dir":        opts.LogDir,
+		"proxy.nginx_conf_dir": opts.NginxConfDir,
+	}
+	for path, value := range values {
+		if err := setYAMLValue(doc, path, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return encodeYAML(doc)
+}
+
+// renderInitRegions keeps the selected regions and points them at the domain
+func renderInitRegions(opts initOptions) ([]byte, error) {
+	doc, err := parseYAML(configs.Regions)
+	if err != nil {
+		return nil, err
+	}
+
+	regions := yamlChild(doc.Content[0], "regions")
+	if regions == nil {
+		return nil, fmt.Errorf("sample regions.yaml has no regions")
+	}
+
+	filterYAMLMapping(regions, func(name string, region *yaml.Node) bool {
+		if !containsString(opts.Regions, name) {
+			return false
+		}
+		if suffix := yamlChild(region, "domain_suffix"); suffix != nil {
+			suffix.Value = opts.Domain
+		}
+		return true
+	})
+
+	return encodeYAML(doc)
+}
+
+// renderInitPlans keeps the plan types of the selected regions
+func renderInitPlans(opts initOptions) ([]byte, error) {
+	doc, err := parseYAML(configs.ProxyPlans)
+	if err != nil {
+		return nil, err
+	}
+
+	planTypes := yamlChild(doc.Content[0], "plan_types")
+	if planTypes == nil {
+		return nil, fmt.Errorf("sample proxy-plans.yaml has no plan_types")
+	}
+
+	filterYAMLMapping(planTypes, func(_ string, planType *yaml.Node) bool {
+		region := yamlChild(planType, "region")
+		return region != nil && containsString(opts.Regions, region.Value)
+	})
+
+	return encodeYAML(doc)
+}
+
+// renderInitEnv writes secrets for the systemd EnvironmentFile
+func renderInitEnv(opts initOptions) []byte {
+	var b strings.Builder
+	b.WriteString("# OceanProxy secrets, loaded by the systemd unit\n")
+	fmt.Fprintf(&b, "BEARER_TOKEN=%s\n", generateToken())
+	fmt.Fprintf(&b, "PROXIES_FO_API_KEY=%s\n", opts.ProxiesFoAPIKey)
+	fmt.Fprintf(&b, "NETTIFY_API_KEY=%s\n", opts.NettifyAPIKey)
+	return []byte(b.String())
+}
+
+// renderInitUnit writes the server's systemd unit
+func renderInitUnit(opts initOptions) []byte {
+	return []byte(fmt.Sprintf(`[Unit]
+Description=OceanProxy - White-label HTTP Proxy Service
+Documentation=https://github.com/je265/oceanproxy
+After=network.target network-online.target
+Wants=network-online.target
+Requires=nginx.service
+
+[Service]
+Type=simple
+User=%[1]s
+Group=%[1]s
+WorkingDirectory=%[2]s
+ExecStart=%[3]s -config %[4]s
+ExecReload=/bin/kill -HUP $MAINPID
+KillMode=mixed
+KillSignal=SIGTERM
+TimeoutStopSec=30
+Restart=always
+RestartSec=5
+
+EnvironmentFile=-%[5]s
+
+NoNewPrivileges=true
+PrivateTmp=true
+ProtectHome=true
+ProtectSystem=strict
+ReadWritePaths=%[6]s
+CapabilityBoundingSet=CAP_NET_BIND_SERVICE
+AmbientCapabilities=CAP_NET_BIND_SERVICE
+
+LimitNOFILE=65536
+TasksMax=32768
+
+StandardOutput=journal
+StandardError=journal
+SyslogIdentifier=oceanproxy
+
+[Install]
+WantedBy=multi-user.target
+`,
+		opts.User,
+		opts.ConfigDir,
+		opts.Binary,
+		filepath.Join(opts.ConfigDir, "config.yaml"),
+		filepath.Join(opts.ConfigDir, "oceanproxy.env"),
+		strings.Join([]string{opts.DataDir, opts.LogDir, opts.ProxyConfigDir, opts.NginxConfDir}, " "),
+	))
+}
+
+// chownToServiceUser hands paths to the service user when running as root
+func chownToServiceUser(name string, paths []string) {
+	if os.Geteuid() != 0 {
+		fmt.Printf("- Not running as root; make sure %s can write the data, log and 3proxy directories\n", name)
+		return
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		fmt.Printf("✗ User %s does not exist; create it with: useradd --system --no-create-home %s\n", name, name)
+		return
+	}
+	uid, _ := strconv.Atoi(u.Uid)
+	gid, _ := strconv.Atoi(u.Gid)
+
+	for _, path := range paths {
+		if err := os.Chown(path, uid, gid); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to chown %s: %v\n", path, err)
+		}
+	}
+	fmt.Printf("✓ Ownership set to %s\n", name)
+}
+
+func checkBinary(name string) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		fmt.Printf("✗ %s not found in PATH; install it before starting the service\n", name)
+		return
+	}
+	fmt.Printf("✓ %s found at %s\n", name, path)
+}
+
+func generateToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate bearer token: %v\n", err)
+		os.Exit(1)
+	}
+	return hex.EncodeToString(b)
+}
+
+func parseYAML(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, fmt.Errorf("empty YAML document")
+	}
+	return &doc, nil
+}
+
+func encodeYAML(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlChild returns the value of key in a mapping node, or nil
+func yamlChild(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setYAMLValue sets the scalar at a dotted path, which must exist
+func setYAMLValue(doc *yaml.Node, path, value string) error {
+	node := doc.Content[0]
+	for _, key := range strings.Split(path, ".") {
+		if node = yamlChild(node, key); node == nil {
+			return fmt.Errorf("sample config has no %s", path)
+		}
+	}
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("%s is not a scalar", path)
+	}
+	node.Value = value
+	return nil
+}
+
+// filterYAMLMapping removes the entries of a mapping node keep rejects
+func filterYAMLMapping(mapping *yaml.Node, keep func(key string, value *yaml.Node) bool) {
+	content := mapping.Content[:0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if keep(mapping.Content[i].Value, mapping.Content[i+1]) {
+			content = append(content, mapping.Content[i], mapping.Content[i+1])
+		}
+	}
+	mapping.Content = content
+}
+
+func containsString(values []string, v string) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
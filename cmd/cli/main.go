@@ -2,6 +2,11 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -12,23 +17,31 @@ import (
 
 	"github.com/google/uuid"
 
+	"github.com/je265/oceanproxy/internal/app"
 	"github.com/je265/oceanproxy/internal/domain"
 	"github.com/je265/oceanproxy/internal/repository"
-	jsonRepo "github.com/je265/oceanproxy/internal/repository/json"
 	"github.com/je265/oceanproxy/internal/service"
 	"github.com/je265/oceanproxy/pkg/config"
 	"github.com/je265/oceanproxy/pkg/logger"
+	"github.com/je265/oceanproxy/pkg/selfupdate"
 )
 
 const version = "1.0.0"
 
 func main() {
 	var (
-		showVersion = flag.Bool("version", false, "Show version information")
-		command     = flag.String("command", "", "Command to execute")
-		verbose     = flag.Bool("verbose", false, "Enable verbose output")
+		showVersion   = flag.Bool("version", false, "Show version information")
+		command       = flag.String("command", "", "Command to execute")
+		verbose       = flag.Bool("verbose", false, "Enable verbose output")
+		jsonOutput    = flag.Bool("json", false, "Print machine-readable JSON")
+		quiet         = flag.Bool("quiet", false, "Print only IDs or nothing")
+		watch         = flag.Bool("watch", false, "Refresh status or list-instances until interrupted")
+		watchInterval = flag.Duration("interval", 2*time.Second, "Refresh interval for -watch")
+		configOpts    = config.RegisterFlags(flag.CommandLine)
 	)
+	flag.BoolVar(watch, "w", false, "Shorthand for -watch")
 	flag.Parse()
+	out = outputOptions{json: *jsonOutput, quiet: *quiet}
 
 	if *showVersion {
 		fmt.Printf("OceanProxy CLI v%s\n", version)
@@ -40,52 +53,101 @@ func main() {
 		os.Exit(1)
 	}
 
+	name, ok := resolveCommand(*command)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", *command)
+		printUsage()
+		os.Exit(1)
+	}
+	*command = name
+
+	// These run before any configuration exists
+	switch *command {
+	case "init":
+		initDeployment(flag.Args())
+		return
+	case "completion":
+		completion(flag.Args())
+		return
+	case "man":
+		manPage(flag.Args())
+		return
+	}
+
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.LoadWithOptions(*configOpts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Initialize logger
-	logLevel := "info"
+	// Initialize logger. Service logs go to stdout, so only warnings are
+	// shown unless asked for; wiring the services alone logs every port pool.
+	logLevel := "warn"
 	if *verbose {
 		logLevel = "debug"
 	}
 	log := logger.New(logLevel, "console")
 
-	// Initialize repositories
-	planRepo := jsonRepo.NewPlanRepository(cfg.Database.DSN, log)
-	instanceRepo := jsonRepo.NewInstanceRepository(cfg.Database.DSN, log)
-
-	// Initialize services
-	providerService := service.NewProviderService(cfg, log)
-	proxyService := service.NewProxyService(cfg, log, instanceRepo, planRepo)
+	// Build the same services as the server
+	services, err := app.BuildServices(cfg, log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize services: %v\n", err)
+		os.Exit(1)
+	}
+	planRepo, instanceRepo := services.PlanRepo, services.InstanceRepo
+	proxyService := services.Proxies
 
 	// Execute command
 	switch *command {
 	case "list-plans":
 		listPlans(planRepo)
+	case "list-expiring":
+		listExpiring(planRepo, flag.Args())
 	case "list-instances":
-		listInstances(instanceRepo)
+		if *watch {
+			watchInstances(instanceRepo, *watchInterval)
+		} else {
+			listInstances(instanceRepo)
+		}
 	case "create-plan":
-		createPlan(planRepo, providerService, flag.Args())
+		createPlan(cfg, planRepo, services.Providers, flag.Args())
 	case "delete-plan":
 		deletePlan(planRepo, flag.Args())
+	case "apply":
+		applyDocument(services, flag.Args())
 	case "start-instance":
 		startInstance(proxyService, flag.Args())
 	case "stop-instance":
 		stopInstance(proxyService, flag.Args())
 	case "status":
-		showStatus(planRepo, instanceRepo)
+		if *watch {
+			watchStatus(planRepo, instanceRepo, *watchInterval)
+		} else {
+			showStatus(planRepo, instanceRepo)
+		}
 	case "cleanup":
-		cleanup(planRepo, instanceRepo, proxyService)
+		cleanup(services, flag.Args())
 	case "health-check":
-		healthCheck(proxyService, flag.Args())
+		healthCheck(services.HealthChecker, flag.Args())
+	case "format-endpoint":
+		formatEndpoint(flag.Args())
+	case "proxy-binary":
+		ensureProxyBinary(services.BinaryManager)
+	case "self-update":
+		selfUpdate(selfupdate.New(cfg, log, selfupdate.ComponentCLI, version), flag.Args())
+	case "release-keygen":
+		releaseKeygen()
+	case "sign-release":
+		signRelease(flag.Args())
 	case "export":
 		exportData(planRepo, instanceRepo, flag.Args())
 	case "import":
 		importData(planRepo, instanceRepo, flag.Args())
+	case "backup":
+		backup(cfg, services.Backup, flag.Args())
+	case "restore":
+		restore(services.Backup, flag.Args())
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", *command)
 		printUsage()
@@ -102,24 +164,29 @@ func printUsage() {
 	fmt.Println("Flags:")
 	fmt.Println("  -version          Show version information")
 	fmt.Println("  -verbose          Enable verbose output")
+	fmt.Println("  -json             Print one JSON document (list-plans, list-instances, status, health-check, cleanup)")
+	fmt.Println("  -quiet            Print only IDs (list commands, failed health checks); rely on the exit code")
+	fmt.Println("  -watch, -w        Refresh status or list-instances and highlight changes until Ctrl+C")
+	fmt.Println("  -interval <dur>   Refresh interval for -watch (default 2s)")
+	fmt.Println("  -config <file>    Path to config file")
+	fmt.Println("  -set key=value    Override a config key (repeatable)")
 	fmt.Println()
 	fmt.Println("Commands:")
-	fmt.Println("  list-plans                    List all proxy plans")
-	fmt.Println("  list-instances                List all proxy instances")
-	fmt.Println("  create-plan <args>            Create a new proxy plan")
-	fmt.Println("  delete-plan <plan-id>         Delete a proxy plan")
-	fmt.Println("  start-instance <instance-id>  Start a proxy instance")
-	fmt.Println("  stop-instance <instance-id>   Stop a proxy instance")
-	fmt.Println("  status                        Show system status")
-	fmt.Println("  cleanup                       Clean up stopped/failed instances")
-	fmt.Println("  health-check [instance-id]    Run health checks")
-	fmt.Println("  export <file>                 Export data to file")
-	fmt.Println("  import <file>                 Import data from file")
+	for _, cmd := range commands {
+		fmt.Printf("  %-29s %s\n", cmd.usageLine(), cmd.Summary)
+	}
+	fmt.Println()
+	fmt.Println("Aliases:")
+	for _, cmd := range commands {
+		if len(cmd.Aliases) > 0 {
+			fmt.Printf("  %-29s %s\n", strings.Join(cmd.Aliases, ", "), cmd.Name)
+		}
+	}
 	fmt.Println()
 	fmt.Println("Examples:")
-	fmt.Println("  oceanproxy-cli -command list-plans")
-	fmt.Println("  oceanproxy-cli -command create-plan customer123 residential proxies_fo usa testuser testpass 10 30")
-	fmt.Println("  oceanproxy-cli -command status")
+	for _, example := range examples {
+		fmt.Println("  " + example)
+	}
 }
 
 func listPlans(planRepo repository.PlanRepository) {
@@ -129,6 +196,21 @@ func listPlans(planRepo repository.PlanRepository) {
 		os.Exit(1)
 	}
 
+	switch {
+	case out.json:
+		rows := make([]planOutput, 0, len(plans))
+		for _, plan := range plans {
+			rows = append(rows, newPlanOutput(plan))
+		}
+		writeJSON(rows)
+		return
+	case out.quiet:
+		for _, plan := range plans {
+			fmt.Println(plan.ID.String())
+		}
+		return
+	}
+
 	if len(plans) == 0 {
 		fmt.Println("No plans found")
 		return
@@ -150,6 +232,45 @@ func listPlans(planRepo repository.PlanRepository) {
 	}
 }
 
+func listExpiring(planRepo repository.PlanRepository, args []string) {
+	within := 72 * time.Hour
+	if len(args) > 0 {
+		parsed, err := time.ParseDuration(args[0])
+		if err != nil || parsed <= 0 {
+			fmt.Fprintf(os.Stderr, "Invalid duration: %s\n", args[0])
+			os.Exit(1)
+		}
+		within = parsed
+	}
+
+	now := time.Now()
+	plans, err := planRepo.GetExpiring(context.Background(), now, now.Add(within))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get expiring plans: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(plans) == 0 {
+		fmt.Printf("No plans expiring within %s\n", within)
+		return
+	}
+
+	fmt.Printf("%-36s %-15s %-12s %-12s %-10s %-16s %s\n",
+		"ID", "Customer", "Provider", "Plan Type", "Region", "Expires", "Remaining")
+	fmt.Println(strings.Repeat("-", 120))
+
+	for _, plan := range plans {
+		fmt.Printf("%-36s %-15s %-12s %-12s %-10s %-16s %s\n",
+			plan.ID.String(),
+			truncate(plan.CustomerID, 15),
+			plan.Provider,
+			plan.PlanType,
+			plan.Region,
+			plan.ExpiresAt.Format("2006-01-02 15:04"),
+			plan.ExpiresAt.Sub(now).Round(time.Minute))
+	}
+}
+
 func listInstances(instanceRepo repository.InstanceRepository) {
 	instances, err := instanceRepo.GetAll(context.Background())
 	if err != nil {
@@ -157,6 +278,21 @@ func listInstances(instanceRepo repository.InstanceRepository) {
 		os.Exit(1)
 	}
 
+	switch {
+	case out.json:
+		rows := make([]instanceOutput, 0, len(instances))
+		for _, instance := range instances {
+			rows = append(rows, newInstanceOutput(instance))
+		}
+		writeJSON(rows)
+		return
+	case out.quiet:
+		for _, instance := range instances {
+			fmt.Println(instance.ID.String())
+		}
+		return
+	}
+
 	if len(instances) == 0 {
 		fmt.Println("No instances found")
 		return
@@ -177,7 +313,7 @@ func listInstances(instanceRepo repository.InstanceRepository) {
 	}
 }
 
-func createPlan(planRepo repository.PlanRepository, providerService service.ProviderService, args []string) {
+func createPlan(cfg *config.Config, planRepo repository.PlanRepository, providerService service.ProviderService, args []string) {
 	if len(args) < 7 {
 		fmt.Println("Usage: create-plan <customer-id> <plan-type> <provider> <region> <username> <password> <bandwidth> [duration]")
 		os.Exit(1)
@@ -209,6 +345,15 @@ func createPlan(planRepo repository.PlanRepository, providerService service.Prov
 		Duration:   duration,
 	}
 
+	// Expire at midnight in the configured billing time zone, if any
+	anchor := domain.BillingAnchor{Timezone: cfg.Billing.Timezone}
+	now := time.Now()
+	expiresAt, err := anchor.Expiry(now, req.Duration, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid billing settings: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Create plan
 	plan := &domain.ProxyPlan{
 		ID:         uuid.New(),
@@ -220,9 +365,12 @@ func createPlan(planRepo repository.PlanRepository, providerService service.Prov
 		Password:   req.Password,
 		Status:     domain.PlanStatusCreating,
 		Bandwidth:  req.Bandwidth,
-		ExpiresAt:  time.Now().AddDate(0, 0, req.Duration),
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		ExpiresAt:  expiresAt,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Duration:   req.Duration,
+
+		BillingAnchor: anchor,
 	}
 
 	if err := planRepo.Create(context.Background(), plan); err != nil {
@@ -300,32 +448,72 @@ func stopInstance(proxyService service.ProxyService, args []string) {
 }
 
 func showStatus(planRepo repository.PlanRepository, instanceRepo repository.InstanceRepository) {
-	// Get plan statistics
-	totalPlans, _ := planRepo.Count(context.Background())
-	activePlans, _ := planRepo.CountByStatus(context.Background(), domain.PlanStatusActive)
-	expiredPlans, _ := planRepo.CountByStatus(context.Background(), domain.PlanStatusExpired)
+	if out.human() {
+		if err := printStatus(context.Background(), planRepo, instanceRepo); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to get status: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Each summary is a single pass over its repository
+	plans, err := planRepo.Summary(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get plan counts: %v\n", err)
+		os.Exit(1)
+	}
+	instances, err := instanceRepo.Summary(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get instance counts: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Get instance statistics
-	totalInstances, _ := instanceRepo.Count(context.Background())
-	runningInstances, _ := instanceRepo.CountByStatus(context.Background(), domain.InstanceStatusRunning)
-	stoppedInstances, _ := instanceRepo.CountByStatus(context.Background(), domain.InstanceStatusStopped)
+	if out.json {
+		status := statusOutput{Plans: plans, Instances: instances, RecentPlans: []planOutput{}}
+		if recent, err := planRepo.GetAll(context.Background()); err == nil {
+			for i, plan := range recent {
+				if i >= 5 {
+					break
+				}
+				status.RecentPlans = append(status.RecentPlans, newPlanOutput(plan))
+			}
+		}
+		writeJSON(status)
+	}
+}
+
+// printStatus prints the human-readable system status
+func printStatus(ctx context.Context, planRepo repository.PlanRepository, instanceRepo repository.InstanceRepository) error {
+	// Each summary is a single pass over its repository
+	plans, err := planRepo.Summary(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get plan counts: %w", err)
+	}
+	instances, err := instanceRepo.Summary(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get instance counts: %w", err)
+	}
 
 	fmt.Println("OceanProxy System Status")
 	fmt.Println("========================")
 	fmt.Printf("Plans:\n")
-	fmt.Printf("  Total: %d\n", totalPlans)
-	fmt.Printf("  Active: %d\n", activePlans)
-	fmt.Printf("  Expired: %d\n", expiredPlans)
+	fmt.Printf("  Total: %d\n", plans.Total)
+	fmt.Printf("  Active: %d\n", plans.ByStatus[domain.PlanStatusActive])
+	fmt.Printf("  Grace: %d\n", plans.ByStatus[domain.PlanStatusGrace])
+	fmt.Printf("  Expired: %d\n", plans.ByStatus[domain.PlanStatusExpired])
 	fmt.Printf("\nInstances:\n")
-	fmt.Printf("  Total: %d\n", totalInstances)
-	fmt.Printf("  Running: %d\n", runningInstances)
-	fmt.Printf("  Stopped: %d\n", stoppedInstances)
+	fmt.Printf("  Total: %d\n", instances.Total)
+	fmt.Printf("  Running: %d\n", instances.ByStatus[domain.InstanceStatusRunning])
+	fmt.Printf("  Stopped: %d\n", instances.ByStatus[domain.InstanceStatusStopped])
+	if failed := instances.ByStatus[domain.InstanceStatusFailed]; failed > 0 {
+		fmt.Println(colorize(colorRed, fmt.Sprintf("  Failed: %d", failed)))
+	}
 
 	// Show recent activity
-	plans, err := planRepo.GetAll(context.Background())
-	if err == nil && len(plans) > 0 {
+	recent, err := planRepo.GetAll(ctx)
+	if err == nil && len(recent) > 0 {
 		fmt.Printf("\nRecent Plans:\n")
-		for i, plan := range plans {
+		for i, plan := range recent {
 			if i >= 5 { // Show only last 5
 				break
 			}
@@ -335,58 +523,70 @@ func showStatus(planRepo repository.PlanRepository, instanceRepo repository.Inst
 				plan.Status)
 		}
 	}
-}
 
-func cleanup(planRepo repository.PlanRepository, instanceRepo repository.InstanceRepository, proxyService service.ProxyService) {
-	fmt.Println("Running cleanup...")
+	return nil
+}
 
-	// Find expired plans
-	expiredPlans, err := planRepo.GetExpired(context.Background(), time.Now())
+func cleanup(services *app.Services, args []string) {
+	policy := domain.DefaultCleanupPolicy()
+	flags := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	skipExpire := flags.Bool("skip-expire", false, "Do not expire plans")
+	skipRestart := flags.Bool("skip-restart", false, "Do not restart failed instances")
+	flags.IntVar(&policy.DeleteFailedAfterDays, "delete-failed-after", 0, "Delete instances failed for at least this many days")
+	flags.BoolVar(&policy.PurgeOrphanConfigs, "purge-configs", false, "Remove 3proxy configs of deleted instances")
+	flags.Parse(args)
+	policy.ExpirePlans = !*skipExpire
+	policy.RestartFailed = !*skipRestart
+
+	if out.human() {
+		fmt.Println("Running cleanup...")
+	}
+
+	// Track the ports of existing instances, as the server does on startup,
+	// so deleted instances release theirs
+	ctx := context.Background()
+	instances, err := services.InstanceRepo.GetAll(ctx)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to get expired plans: %v\n", err)
-		return
+		fmt.Fprintf(os.Stderr, "Failed to get instances: %v\n", err)
+		os.Exit(1)
 	}
+	services.PortManager.Reconcile(ctx, instances)
 
-	fmt.Printf("Found %d expired plans\n", len(expiredPlans))
-
-	for _, plan := range expiredPlans {
-		// Update plan status
-		plan.Status = domain.PlanStatusExpired
-		planRepo.Update(context.Background(), plan)
+	report, err := services.Cleanup.Run(ctx, policy, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cleanup failed: %v\n", err)
+		os.Exit(1)
+	}
 
-		// Stop associated instances
-		instances, err := instanceRepo.GetByPlanID(context.Background(), plan.ID)
-		if err != nil {
-			continue
+	switch {
+	case out.json:
+		writeJSON(report)
+	case out.human():
+		fmt.Printf("Expired %d plans and stopped %d of their instances\n", len(report.ExpiredPlans), len(report.StoppedInstances))
+		for _, id := range report.RestartedInstances {
+			fmt.Printf("Restarted failed instance %s\n", id.String())
 		}
-
-		for _, instance := range instances {
-			if instance.Status == domain.InstanceStatusRunning {
-				proxyService.StopInstance(context.Background(), instance.ID)
-				fmt.Printf("Stopped instance %s for expired plan %s\n",
-					instance.ID.String(), plan.ID.String())
-			}
+		for _, failure := range report.FailedRestarts {
+			fmt.Printf("Failed to restart instance %s: %s\n", failure.InstanceID.String(), failure.Error)
 		}
-	}
-
-	// Find failed instances
-	failedInstances, err := instanceRepo.GetByStatus(context.Background(), domain.InstanceStatusFailed)
-	if err == nil {
-		fmt.Printf("Found %d failed instances\n", len(failedInstances))
-		for _, instance := range failedInstances {
-			// Try to restart failed instances
-			if err := proxyService.RestartInstance(context.Background(), instance.ID); err != nil {
-				fmt.Printf("Failed to restart instance %s: %v\n", instance.ID.String(), err)
-			} else {
-				fmt.Printf("Restarted failed instance %s\n", instance.ID.String())
-			}
+		for _, id := range report.DeletedInstances {
+			fmt.Printf("Deleted failed instance %s\n", id.String())
+		}
+		for _, failure := range report.FailedDeletes {
+			fmt.Printf("Failed to delete instance %s: %s\n", failure.InstanceID.String(), failure.Error)
+		}
+		for _, id := range report.SkippedInstances {
+			fmt.Printf("Skipped failed instance %s (maintenance or no-autoheal)\n", id.String())
 		}
+		for _, path := range report.PurgedConfigs {
+			fmt.Printf("Removed orphan config %s\n", path)
+		}
+		fmt.Println("Cleanup completed")
 	}
-
-	fmt.Println("Cleanup completed")
 }
 
-func healthCheck(proxyService service.ProxyService, args []string) {
+func healthCheck(checker *service.HealthChecker, args []string) {
+	var report *domain.HealthCheckReport
 	if len(args) > 0 {
 		// Check specific instance
 		instanceID, err := uuid.Parse(args[0])
@@ -394,39 +594,217 @@ func healthCheck(proxyService service.ProxyService, args []string) {
 			fmt.Fprintf(os.Stderr, "Invalid instance ID: %v\n", err)
 			os.Exit(1)
 		}
-
-		if err := proxyService.HealthCheck(context.Background(), instanceID); err != nil {
-			fmt.Printf("Health check FAILED for instance %s: %v\n", instanceID.String(), err)
-			os.Exit(1)
-		} else {
-			fmt.Printf("Health check PASSED for instance %s\n", instanceID.String())
-		}
+		report = checker.Check(context.Background(), []uuid.UUID{instanceID})
 	} else {
-		// Check all running instances
-		instances, err := proxyService.GetRunningInstances(context.Background())
+		// Check all running instances concurrently
+		var err error
+		report, err = checker.CheckRunning(context.Background())
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to get running instances: %v\n", err)
 			os.Exit(1)
 		}
+	}
 
-		passed := 0
-		failed := 0
-
-		for _, instance := range instances {
-			if err := proxyService.HealthCheck(context.Background(), instance.ID); err != nil {
-				fmt.Printf("FAIL: %s - %v\n", instance.ID.String(), err)
-				failed++
+	switch {
+	case out.json:
+		writeJSON(newHealthCheckOutput(report))
+	case out.quiet:
+		// Only the failures, so the output can be piped to restart them
+		for _, result := range report.Results {
+			if !result.Healthy {
+				fmt.Println(result.InstanceID.String())
+			}
+		}
+	case len(args) > 0:
+		result := report.Results[0]
+		if result.Healthy {
+			fmt.Printf("Health check PASSED for instance %s\n", result.InstanceID.String())
+		} else {
+			fmt.Printf("Health check FAILED for instance %s: %s\n", result.InstanceID.String(), result.Error)
+		}
+	default:
+		for _, result := range report.Results {
+			if result.Healthy {
+				fmt.Printf("PASS: %s\n", result.InstanceID.String())
 			} else {
-				fmt.Printf("PASS: %s\n", instance.ID.String())
-				passed++
+				fmt.Printf("FAIL: %s - %s\n", result.InstanceID.String(), result.Error)
 			}
 		}
 
-		fmt.Printf("\nHealth Check Summary: %d passed, %d failed\n", passed, failed)
-		if failed > 0 {
+		fmt.Printf("\nHealth Check Summary: %d passed, %d failed in %s\n", report.Passed, report.Failed, report.Duration.Round(time.Millisecond))
+	}
+
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func ensureProxyBinary(binaryManager *service.BinaryManager) {
+	if err := binaryManager.Ensure(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "3proxy binary check failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	version, _ := binaryManager.Status()
+	fmt.Printf("3proxy %s at %s\n", version, binaryManager.Path())
+}
+
+func selfUpdate(updater *selfupdate.Updater, args []string) {
+	release, err := updater.Check(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Update check failed: %v\n", err)
+		os.Exit(1)
+	}
+	if release == nil {
+		fmt.Printf("OceanProxy CLI v%s is up to date\n", version)
+		return
+	}
+
+	if len(args) > 0 && args[0] == "check" {
+		fmt.Printf("Update available: v%s -> v%s\n", version, release.Version)
+		return
+	}
+
+	if err := updater.Apply(context.Background(), release); err != nil {
+		fmt.Fprintf(os.Stderr, "Update failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Updated OceanProxy CLI v%s -> v%s\n", version, release.Version)
+}
+
+func backup(cfg *config.Config, backupService *service.BackupService, args []string) {
+	action := "now"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "now":
+		key, err := backupService.BackupNow(context.Background())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Backup failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Backup uploaded to s3://%s/%s\n", cfg.Backup.Bucket, key)
+	case "list":
+		backups, err := backupService.List(context.Background())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to list backups: %v\n", err)
+			os.Exit(1)
+		}
+		if len(backups) == 0 {
+			fmt.Println("No backups found")
+			return
+		}
+
+		fmt.Printf("%-70s %12s %s\n", "Key", "Size", "Modified")
+		fmt.Println(strings.Repeat("-", 110))
+		for _, object := range backups {
+			fmt.Printf("%-70s %12d %s\n", object.Key, object.Size, object.LastModified.Format("2006-01-02 15:04:05"))
+		}
+	case "keygen":
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to generate key: %v\n", err)
 			os.Exit(1)
 		}
+		fmt.Printf("Encryption key (backup.encryption_key): %s\n", base64.StdEncoding.EncodeToString(key))
+	default:
+		fmt.Println("Usage: backup [now|list|keygen]")
+		os.Exit(1)
+	}
+}
+
+func restore(backupService *service.BackupService, args []string) {
+	if len(args) < 2 || (args[0] != "--from" && args[0] != "-from") {
+		fmt.Println("Usage: restore --from <s3://bucket/key | key | latest>")
+		os.Exit(1)
+	}
+
+	fmt.Println("Stop the oceanproxy service before restoring; a running server overwrites restored data")
+
+	restored, err := backupService.Restore(context.Background(), args[1])
+	for _, path := range restored {
+		fmt.Printf("Restored %s\n", path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Restore failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Restored %d files\n", len(restored))
+}
+
+func releaseKeygen() {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate key: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Public key (updates.public_key): %s\n", base64.StdEncoding.EncodeToString(publicKey))
+	fmt.Printf("Private key (keep offline):      %s\n", base64.StdEncoding.EncodeToString(privateKey))
+}
+
+func signRelease(args []string) {
+	if len(args) < 7 {
+		fmt.Println("Usage: sign-release <key-file> <component> <version> <os> <arch> <url> <binary> [channel]")
+		os.Exit(1)
+	}
+
+	keyData, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read key: %v\n", err)
+		os.Exit(1)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(keyData)))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		fmt.Fprintln(os.Stderr, "Key file must hold a base64 ed25519 private key")
+		os.Exit(1)
+	}
+
+	binary, err := os.ReadFile(args[6])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read binary: %v\n", err)
+		os.Exit(1)
+	}
+	sum := sha256.Sum256(binary)
+
+	release := selfupdate.Release{
+		Component: args[1],
+		Version:   args[2],
+		Channel:   "stable",
+		OS:        args[3],
+		Arch:      args[4],
+		URL:       args[5],
+		SHA256:    hex.EncodeToString(sum[:]),
+	}
+	if len(args) > 7 {
+		release.Channel = args[7]
 	}
+	release.Sign(ed25519.PrivateKey(key))
+
+	data, _ := json.MarshalIndent(release, "", "  ")
+	fmt.Println(string(data))
+}
+
+func formatEndpoint(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: format-endpoint <proxy-url> [format]")
+		os.Exit(1)
+	}
+
+	format := domain.EndpointFormatURL
+	if len(args) > 1 {
+		format = args[1]
+	}
+
+	formatted, err := domain.FormatEndpoint(domain.ProxyEndpoint{URL: args[0]}, format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to format endpoint: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(formatted)
 }
 
 func exportData(planRepo repository.PlanRepository, instanceRepo repository.InstanceRepository, args []string) {
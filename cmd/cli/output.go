@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// outputOptions holds the global output flags shared by every command
+type outputOptions struct {
+	json  bool
+	quiet bool
+}
+
+// out is set from -json and -quiet in main. In JSON mode a command writes
+// exactly one JSON document to stdout; in quiet mode list commands print one
+// ID per line, health-check prints the IDs that failed and other commands
+// report only through their exit code. Errors always go to stderr as text.
+var out outputOptions
+
+// human reports whether tables and progress messages should be printed
+func (o outputOptions) human() bool {
+	return !o.json && !o.quiet
+}
+
+// writeJSON prints v as indented JSON on stdout
+func writeJSON(v interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encode output: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// The types below are the documented -json schemas. Fields may be added but
+// never renamed or removed.
+
+// planOutput is one plan in list-plans and status output
+type planOutput struct {
+	ID         uuid.UUID `json:"id"`
+	CustomerID string    `json:"customer_id"`
+	Provider   string    `json:"provider"`
+	PlanType   string    `json:"plan_type"`
+	Region     string    `json:"region"`
+	Username   string    `json:"username"`
+	Status     string    `json:"status"`
+	Bandwidth  int       `json:"bandwidth"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// instanceOutput is one instance in list-instances output
+type instanceOutput struct {
+	ID          uuid.UUID `json:"id"`
+	PlanID      uuid.UUID `json:"plan_id"`
+	PlanTypeKey string    `json:"plan_type_key"`
+	LocalPort   int       `json:"local_port"`
+	Status      string    `json:"status"`
+	ProcessID   int       `json:"process_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// statusOutput is the status command's output
+type statusOutput struct {
+	Plans       *repository.PlanSummary     `json:"plans"`
+	Instances   *repository.InstanceSummary `json:"instances"`
+	RecentPlans []planOutput                `json:"recent_plans"`
+}
+
+// healthCheckOutput is the health-check command's output
+type healthCheckOutput struct {
+	Total      int                       `json:"total"`
+	Passed     int                       `json:"passed"`
+	Failed     int                       `json:"failed"`
+	DurationMS int64                     `json:"duration_ms"`
+	Results    []healthCheckResultOutput `json:"results"`
+}
+
+// healthCheckResultOutput is one instance's health check result
+type healthCheckResultOutput struct {
+	InstanceID uuid.UUID `json:"instance_id"`
+	Healthy    bool      `json:"healthy"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+func newPlanOutput(plan *domain.ProxyPlan) planOutput {
+	return planOutput{
+		ID:         plan.ID,
+		CustomerID: plan.CustomerID,
+		Provider:   plan.Provider,
+		PlanType:   plan.PlanType,
+		Region:     plan.Region,
+		Username:   plan.Username,
+		Status:     plan.Status,
+		Bandwidth:  plan.Bandwidth,
+		ExpiresAt:  plan.ExpiresAt,
+		CreatedAt:  plan.CreatedAt,
+	}
+}
+
+func newInstanceOutput(instance *domain.ProxyInstance) instanceOutput {
+	return instanceOutput{
+		ID:          instance.ID,
+		PlanID:      instance.PlanID,
+		PlanTypeKey: instance.PlanTypeKey,
+		LocalPort:   instance.LocalPort,
+		Status:      instance.Status,
+		ProcessID:   instance.ProcessID,
+		CreatedAt:   instance.CreatedAt,
+	}
+}
+
+func newHealthCheckOutput(report *domain.HealthCheckReport) healthCheckOutput {
+	output := healthCheckOutput{
+		Total:      report.Total,
+		Passed:     report.Passed,
+		Failed:     report.Failed,
+		DurationMS: report.Duration.Milliseconds(),
+		Results:    make([]healthCheckResultOutput, 0, len(report.Results)),
+	}
+	for _, result := range report.Results {
+		output.Results = append(output.Results, healthCheckResultOutput{
+			InstanceID: result.InstanceID,
+			Healthy:    result.Healthy,
+			Error:      result.Error,
+			DurationMS: result.Duration.Milliseconds(),
+		})
+	}
+	return output
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorGray   = "\033[90m"
+	clearScreen = "\033[H\033[2J"
+)
+
+// Row markers shown in the first column of watched instance tables, so
+// changes remain visible when colors are off
+const (
+	markNew     = "+"
+	markChanged = "~"
+	markRemoved = "-"
+)
+
+// isTerminal reports whether stdout is an interactive terminal
+func isTerminal() bool {
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps s in an ANSI color when stdout is a terminal and NO_COLOR is unset
+func colorize(color, s string) string {
+	if color == "" || !isTerminal() || os.Getenv("NO_COLOR") != "" {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// watchLoop calls refresh every interval until interrupted. On a terminal the
+// screen is cleared before each refresh; otherwise refreshes are separated by
+// a timestamp line so the output can be logged.
+func watchLoop(interval time.Duration, refresh func(ctx context.Context) error) {
+	if out.json || out.quiet {
+		fmt.Fprintln(os.Stderr, "-watch cannot be combined with -json or -quiet")
+		os.Exit(1)
+	}
+	if interval <= 0 {
+		fmt.Fprintln(os.Stderr, "-interval must be positive")
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if isTerminal() {
+			fmt.Print(clearScreen)
+		}
+		fmt.Printf("Every %s: refreshed %s (Ctrl+C to stop)\n\n", interval, time.Now().Format("15:04:05"))
+
+		if err := refresh(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Refresh failed: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !isTerminal() {
+			fmt.Println()
+		}
+	}
+}
+
+// instanceTracker remembers the instance statuses seen on the previous
+// refresh so the next one can highlight what changed
+type instanceTracker struct {
+	previous map[uuid.UUID]*domain.ProxyInstance
+}
+
+// instanceChanges describes how a refresh differs from the one before it
+type instanceChanges struct {
+	added   map[uuid.UUID]bool
+	changed map[uuid.UUID]string // previous status
+	removed []*domain.ProxyInstance
+}
+
+// update records instances as the latest refresh and returns the changes
+// since the previous one. The first refresh reports no changes.
+func (t *instanceTracker) update(instances []*domain.ProxyInstance) instanceChanges {
+	changes := instanceChanges{
+		added:   make(map[uuid.UUID]bool),
+		changed: make(map[uuid.UUID]string),
+	}
+
+	current := make(map[uuid.UUID]*domain.ProxyInstance, len(instances))
+	for _, instance := range instances {
+		current[instance.ID] = instance
+		if t.previous == nil {
+			continue
+		}
+		before, seen := t.previous[instance.ID]
+		if !seen {
+			changes.added[instance.ID] = true
+		} else if before.Status != instance.Status {
+			changes.changed[instance.ID] = before.Status
+		}
+	}
+
+	for id, instance := range t.previous {
+		if _, ok := current[id]; !ok {
+			changes.removed = append(changes.removed, instance)
+		}
+	}
+
+	t.previous = current
+	return changes
+}
+
+// printInstanceTable prints instances with new, changed, failed and removed
+// rows highlighted
+func printInstanceTable(instances []*domain.ProxyInstance, changes instanceChanges) {
+	if len(instances) == 0 && len(changes.removed) == 0 {
+		fmt.Println("No instances found")
+		return
+	}
+
+	fmt.Printf("  %-36s %-36s %-10s %-25s %-10s %s\n",
+		"ID", "Plan ID", "Port", "Plan Type", "Status", "Created")
+	fmt.Println(strings.Repeat("-", 132))
+
+	for _, instance := range instances {
+		mark, color := " ", ""
+		if _, ok := changes.changed[instance.ID]; ok {
+			mark, color = markChanged, colorYellow
+		}
+		if changes.added[instance.ID] {
+			mark, color = markNew, colorGreen
+		}
+		if instance.Status == domain.InstanceStatusFailed {
+			color = colorRed
+		}
+
+		fmt.Println(colorize(color, fmt.Sprintf("%s %-36s %-36s %-10d %-25s %-10s %s",
+			mark,
+			instance.ID.String(),
+			instance.PlanID.String(),
+			instance.LocalPort,
+			truncate(instance.PlanTypeKey, 25),
+			instance.Status,
+			instance.CreatedAt.Format("2006-01-02 15:04"))))
+	}
+
+	for _, instance := range changes.removed {
+		fmt.Println(colorize(colorGray, fmt.Sprintf("%s %-36s %-36s %-10d %-25s %-10s %s",
+			markRemoved,
+			instance.ID.String(),
+			instance.PlanID.String(),
+			instance.LocalPort,
+			truncate(instance.PlanTypeKey, 25),
+			"removed",
+			instance.CreatedAt.Format("2006-01-02 15:04"))))
+	}
+}
+
+// printInstanceChanges lists the instance transitions of one refresh, used
+// by the status watch below its counters
+func printInstanceChanges(instances []*domain.ProxyInstance, changes instanceChanges) {
+	if len(changes.added) == 0 && len(changes.changed) == 0 && len(changes.removed) == 0 {
+		return
+	}
+
+	fmt.Printf("\nChanges:\n")
+	for _, instance := range instances {
+		color := ""
+		if instance.Status == domain.InstanceStatusFailed {
+			color = colorRed
+		}
+		if changes.added[instance.ID] {
+			if color == "" {
+				color = colorGreen
+			}
+			fmt.Println(colorize(color, fmt.Sprintf("  %s %s (%s) %s", markNew, instance.ID.String(), instance.PlanTypeKey, instance.Status)))
+		} else if previous, ok := changes.changed[instance.ID]; ok {
+			if color == "" {
+				color = colorYellow
+			}
+			fmt.Println(colorize(color, fmt.Sprintf("  %s %s (%s) %s -> %s", markChanged, instance.ID.String(), instance.PlanTypeKey, previous, instance.Status)))
+		}
+	}
+	for _, instance := range changes.removed {
+		fmt.Println(colorize(colorGray, fmt.Sprintf("  %s %s (%s) removed", markRemoved, instance.ID.String(), instance.PlanTypeKey)))
+	}
+}
+
+// watchInstances refreshes the instance table every interval
+func watchInstances(instanceRepo repository.InstanceRepository, interval time.Duration) {
+	tracker := &instanceTracker{}
+	watchLoop(interval, func(ctx context.Context) error {
+		instances, err := instanceRepo.GetAll(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get instances: %w", err)
+		}
+		printInstanceTable(instances, tracker.update(instances))
+		return nil
+	})
+}
+
+// watchStatus refreshes the system status every interval, followed by the
+// instance transitions since the previous refresh
+func watchStatus(planRepo repository.PlanRepository, instanceRepo repository.InstanceRepository, interval time.Duration) {
+	tracker := &instanceTracker{}
+	watchLoop(interval, func(ctx context.Context) error {
+		if err := printStatus(ctx, planRepo, instanceRepo); err != nil {
+			return err
+		}
+		instances, err := instanceRepo.GetAll(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get instances: %w", err)
+		}
+		printInstanceChanges(instances, tracker.update(instances))
+		return nil
+	})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ProcessUsage is the CPU and memory use of the processes with one name,
+// summed across processes, e.g. all 3proxy instances
+type ProcessUsage struct {
+	Name           string  `json:"name"`
+	Processes      int     `json:"processes"`
+	AvgCPUPercent  float64 `json:"avg_cpu_percent"`
+	PeakCPUPercent float64 `json:"peak_cpu_percent"`
+	PeakRSSBytes   int64   `json:"peak_rss_bytes"`
+
+	// The latest sample, for interim reports
+	CPUPercent float64 `json:"-"`
+	RSSBytes   int64   `json:"-"`
+}
+
+// processSample is one process's cumulative CPU time and resident memory
+type processSample struct {
+	name     string
+	cpuTime  time.Duration
+	rssBytes int64
+}
+
+// procSampler samples the resource usage of processes by name. It only
+// sees processes on the machine it runs on, so run the load test on the
+// server host to get resource figures.
+type procSampler struct {
+	names []string
+
+	mu       sync.Mutex
+	usage    map[string]*ProcessUsage
+	samples  int
+	previous map[int]processSample
+	sampled  time.Time
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func newProcSampler(names []string) *procSampler {
+	usage := make(map[string]*ProcessUsage, len(names))
+	for _, name := range names {
+		usage[name] = &ProcessUsage{Name: name}
+	}
+	return &procSampler{names: names, usage: usage}
+}
+
+// start samples every interval until stop is called
+func (s *procSampler) start(ctx context.Context, interval time.Duration) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.sample(time.Now()); err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  Resource sampling disabled: %v\n", err)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// stop ends sampling and returns the usage seen
+func (s *procSampler) stop() []ProcessUsage {
+	s.cancel()
+	<-s.done
+	return s.current()
+}
+
+// current returns the usage seen so far
+func (s *procSampler) current() []ProcessUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usage := make([]ProcessUsage, 0, len(s.names))
+	for _, name := range s.names {
+		u := *s.usage[name]
+		if s.samples > 0 {
+			u.AvgCPUPercent /= float64(s.samples)
+		}
+		usage = append(usage, u)
+	}
+	return usage
+}
+
+// sample reads the processes and adds their CPU use since the previous
+// sample. AvgCPUPercent holds the running sum until current divides it.
+func (s *procSampler) sample(now time.Time) error {
+	processes, err := readProcesses(s.names)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// CPU use needs two samples
+	if s.previous != nil {
+		elapsed := now.Sub(s.sampled)
+		cpu := make(map[string]float64, len(s.names))
+		rss := make(map[string]int64, len(s.names))
+		count := make(map[string]int, len(s.names))
+		for pid, current := range processes {
+			count[current.name]++
+			rss[current.name] += current.rssBytes
+			if previous, ok := s.previous[pid]; ok && elapsed > 0 {
+				cpu[current.name] += float64(current.cpuTime-previous.cpuTime) / float64(elapsed) * 100
+			}
+		}
+
+		for _, name := range s.names {
+			u := s.usage[name]
+			u.CPUPercent = cpu[name]
+			u.RSSBytes = rss[name]
+			u.AvgCPUPercent += cpu[name]
+			if cpu[name] > u.PeakCPUPercent {
+				u.PeakCPUPercent = cpu[name]
+			}
+			if rss[name] > u.PeakRSSBytes {
+				u.PeakRSSBytes = rss[name]
+			}
+			if count[name] > u.Processes {
+				u.Processes = count[name]
+			}
+		}
+		s.samples++
+	}
+
+	s.previous = processes
+	s.sampled = now
+	return nil
+}
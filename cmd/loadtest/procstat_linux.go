@@ -0,0 +1,75 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicksPerSecond is USER_HZ, the unit of CPU times in /proc, which is
+// 100 on every architecture Linux supports
+const clockTicksPerSecond = 100
+
+// readProcesses returns the processes whose command name is one of names,
+// by PID. Kernel command names are cut to 15 characters, so names are too.
+func readProcesses(names []string) (map[int]processSample, error) {
+	wanted := make(map[string]string, len(names))
+	for _, name := range names {
+		comm := name
+		if len(comm) > 15 {
+			comm = comm[:15]
+		}
+		wanted[comm] = name
+	}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := int64(os.Getpagesize())
+	processes := make(map[int]processSample)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			// The process exited
+			continue
+		}
+
+		// The command name is in parentheses and may contain spaces
+		open, closing := bytes.IndexByte(stat, '('), bytes.LastIndexByte(stat, ')')
+		if open < 0 || closing < open {
+			continue
+		}
+		name, ok := wanted[string(stat[open+1:closing])]
+		if !ok {
+			continue
+		}
+
+		// Fields after the name start with state (field 3); utime and stime
+		// are fields 14 and 15, rss (in pages) is field 24
+		fields := strings.Fields(string(stat[closing+1:]))
+		if len(fields) < 22 {
+			continue
+		}
+		utime, _ := strconv.ParseInt(fields[11], 10, 64)
+		stime, _ := strconv.ParseInt(fields[12], 10, 64)
+		rss, _ := strconv.ParseInt(fields[21], 10, 64)
+
+		processes[pid] = processSample{
+			name:     name,
+			cpuTime:  time.Duration(utime+stime) * time.Second / clockTicksPerSecond,
+			rssBytes: rss * pageSize,
+		}
+	}
+	return processes, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// readProcesses is only implemented on Linux, where /proc is available
+func readProcesses(names []string) (map[int]processSample, error) {
+	return nil, fmt.Errorf("process sampling is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report is the result of a load test run
+type Report struct {
+	Customers         int              `json:"customers"`
+	Proxies           int              `json:"proxies"`
+	Target            string           `json:"target"`
+	DurationSeconds   float64          `json:"duration_seconds"`
+	Requests          int64            `json:"requests"`
+	Errors            int64            `json:"errors"`
+	ErrorRate         float64          `json:"error_rate"`
+	RequestsPerSecond float64          `json:"requests_per_second"`
+	Bytes             int64            `json:"bytes"`
+	BytesPerSecond    float64          `json:"bytes_per_second"`
+	Latency           LatencySummary   `json:"latency"`
+	StatusCodes       map[int]int64    `json:"status_codes,omitempty"`
+	Failures          map[string]int64 `json:"failures,omitempty"`
+	Resources         []ProcessUsage   `json:"resources,omitempty"`
+}
+
+// LatencySummary is the request latency distribution in milliseconds
+type LatencySummary struct {
+	MeanMs float64 `json:"mean_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P90Ms  float64 `json:"p90_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+func newReport(w *window, elapsed time.Duration, customers, proxies int, target string) *Report {
+	report := &Report{
+		Customers:       customers,
+		Proxies:         proxies,
+		Target:          target,
+		DurationSeconds: elapsed.Seconds(),
+		Requests:        w.requests,
+		Errors:          w.errors,
+		Bytes:           w.bytes,
+		Latency: LatencySummary{
+			MeanMs: millis(w.latency.mean()),
+			P50Ms:  millis(w.latency.quantile(0.50)),
+			P90Ms:  millis(w.latency.quantile(0.90)),
+			P99Ms:  millis(w.latency.quantile(0.99)),
+			MaxMs:  millis(w.latency.max),
+		},
+		StatusCodes: w.statuses,
+		Failures:    w.failures,
+	}
+	if w.requests > 0 {
+		report.ErrorRate = float64(w.errors) / float64(w.requests)
+	}
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		report.RequestsPerSecond = float64(w.requests) / seconds
+		report.BytesPerSecond = float64(w.bytes) / seconds
+	}
+	return report
+}
+
+// check returns the thresholds the report misses
+func (r *Report) check(opts *options) []string {
+	var failures []string
+	if opts.maxP99 > 0 && r.Latency.P99Ms > millis(opts.maxP99) {
+		failures = append(failures, fmt.Sprintf("p99 latency %s exceeds %s", formatMillis(r.Latency.P99Ms), opts.maxP99))
+	}
+	if opts.maxErrRate >= 0 && r.ErrorRate > opts.maxErrRate {
+		failures = append(failures, fmt.Sprintf("error rate %.2f%% exceeds %.2f%%", r.ErrorRate*100, opts.maxErrRate*100))
+	}
+	if opts.minRPS > 0 && r.RequestsPerSecond < opts.minRPS {
+		failures = append(failures, fmt.Sprintf("throughput %.1f req/s is below %.1f", r.RequestsPerSecond, opts.minRPS))
+	}
+	return failures
+}
+
+// print writes the report as text
+func (r *Report) print(w io.Writer) {
+	fmt.Fprintf(w, "📊 Load Test Results\n")
+	fmt.Fprintf(w, "==================\n")
+	fmt.Fprintf(w, "Customers:    %d over %d proxies\n", r.Customers, r.Proxies)
+	fmt.Fprintf(w, "Target:       %s\n", r.Target)
+	fmt.Fprintf(w, "Duration:     %s\n", time.Duration(r.DurationSeconds*float64(time.Second)).Round(time.Second))
+	fmt.Fprintf(w, "Requests:     %d (%.1f req/s)\n", r.Requests, r.RequestsPerSecond)
+	fmt.Fprintf(w, "Throughput:   %s/s (%s total)\n", formatBytes(r.BytesPerSecond), formatBytes(float64(r.Bytes)))
+	fmt.Fprintf(w, "Errors:       %d (%.2f%%)\n", r.Errors, r.ErrorRate*100)
+	fmt.Fprintf(w, "Latency:      mean %s, p50 %s, p90 %s, p99 %s, max %s\n",
+		formatMillis(r.Latency.MeanMs), formatMillis(r.Latency.P50Ms), formatMillis(r.Latency.P90Ms),
+		formatMillis(r.Latency.P99Ms), formatMillis(r.Latency.MaxMs))
+
+	if len(r.StatusCodes) > 0 {
+		codes := make([]int, 0, len(r.StatusCodes))
+		for code := range r.StatusCodes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		parts := make([]string, 0, len(codes))
+		for _, code := range codes {
+			parts = append(parts, fmt.Sprintf("%d: %d", code, r.StatusCodes[code]))
+		}
+		fmt.Fprintf(w, "Status codes: %s\n", strings.Join(parts, ", "))
+	}
+
+	if len(r.Failures) > 0 {
+		fmt.Fprintf(w, "\nFailures:\n")
+		kinds := make([]string, 0, len(r.Failures))
+		for kind := range r.Failures {
+			kinds = append(kinds, kind)
+		}
+		sort.Slice(kinds, func(i, j int) bool { return r.Failures[kinds[i]] > r.Failures[kinds[j]] })
+		for _, kind := range kinds {
+			fmt.Fprintf(w, "  %-20s %d\n", kind, r.Failures[kind])
+		}
+	}
+
+	if len(r.Resources) > 0 {
+		fmt.Fprintf(w, "\nResources:\n")
+		fmt.Fprintf(w, "  %-14s %6s %10s %10s %12s\n", "PROCESS", "COUNT", "AVG CPU", "PEAK CPU", "PEAK RSS")
+		for _, usage := range r.Resources {
+			fmt.Fprintf(w, "  %-14s %6d %9.1f%% %9.1f%% %12s\n",
+				usage.Name, usage.Processes, usage.AvgCPUPercent, usage.PeakCPUPercent, formatBytes(float64(usage.PeakRSSBytes)))
+		}
+	}
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func formatMillis(ms float64) string {
+	if ms >= 1000 {
+		return fmt.Sprintf("%.2fs", ms/1000)
+	}
+	return fmt.Sprintf("%.1fms", ms)
+}
+
+func formatBytes(n float64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%.0f B", n)
+	}
+	div, exp := float64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", n/div, "KMGTPE"[exp])
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// errorBackoff is how long a customer waits after a request fails without
+// a response
+const errorBackoff = 100 * time.Millisecond
+
+// runner drives the customers and aggregates their results
+type runner struct {
+	opts    *options
+	proxies []string
+	target  string
+
+	mu     sync.Mutex
+	total  *window
+	recent *window
+}
+
+// window is the results over part or all of a run
+type window struct {
+	started  time.Time
+	latency  *latencyHistogram
+	requests int64
+	errors   int64
+	bytes    int64
+	statuses map[int]int64
+	failures map[string]int64
+}
+
+func newWindow(started time.Time) *window {
+	return &window{
+		started:  started,
+		latency:  newLatencyHistogram(),
+		statuses: make(map[int]int64),
+		failures: make(map[string]int64),
+	}
+}
+
+// result is the outcome of one request
+type result struct {
+	latency time.Duration
+	status  int
+	bytes   int64
+	failure string
+}
+
+func newRunner(opts *options, proxies []string, target string) *runner {
+	return &runner{opts: opts, proxies: proxies, target: target}
+}
+
+// run starts the customers, waits for the duration and returns the report
+func (r *runner) run(ctx context.Context, sampler *procSampler) (*Report, error) {
+	proxyURLs := make([]*url.URL, 0, len(r.proxies))
+	for _, proxy := range r.proxies {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", proxy)
+		}
+		proxyURLs = append(proxyURLs, u)
+	}
+
+	started := time.Now()
+	r.total = newWindow(started)
+	r.recent = newWindow(started)
+
+	ctx, cancel := context.WithTimeout(ctx, r.opts.duration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < r.opts.customers; i++ {
+		var delay time.Duration
+		if r.opts.rampUp > 0 {
+			delay = r.opts.rampUp * time.Duration(i) / time.Duration(r.opts.customers)
+		}
+		wg.Add(1)
+		go func(proxy *url.URL, delay time.Duration) {
+			defer wg.Done()
+			r.customer(ctx, proxy, delay)
+		}(proxyURLs[i%len(proxyURLs)], delay)
+	}
+
+	if r.opts.reportEvery > 0 {
+		go r.reportInterim(ctx, sampler)
+	}
+
+	wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return newReport(r.total, time.Since(started), r.opts.customers, len(r.proxies), r.target), nil
+}
+
+// customer sends requests through one proxy until ctx is done. Each
+// customer keeps its own connections, as a real customer's client would.
+func (r *runner) customer(ctx context.Context, proxy *url.URL, delay time.Duration) {
+	if delay > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+
+	transport := &http.Transport{
+		Proxy:               http.ProxyURL(proxy),
+		MaxIdleConnsPerHost: 1,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport, Timeout: r.opts.timeout}
+
+	var ticker *time.Ticker
+	if r.opts.rate > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / r.opts.rate))
+		defer ticker.Stop()
+	}
+
+	for ctx.Err() == nil {
+		res := r.request(ctx, client)
+		// Requests cut short by the end of the run are not counted
+		if ctx.Err() != nil {
+			return
+		}
+		r.record(res)
+
+		// Pause after connection errors so a dead proxy does not spin
+		if res.status == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(errorBackoff):
+			}
+			continue
+		}
+
+		if ticker != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// request fetches the target once
+func (r *runner) request(ctx context.Context, client *http.Client) result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.target, nil)
+	if err != nil {
+		return result{failure: "request"}
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return result{latency: time.Since(start), failure: classifyError(err)}
+	}
+	n, err := io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	res := result{latency: time.Since(start), status: resp.StatusCode, bytes: n}
+	switch {
+	case err != nil:
+		res.failure = "body: " + classifyError(err)
+	case resp.StatusCode < 200 || resp.StatusCode >= 400:
+		res.failure = fmt.Sprintf("status %d", resp.StatusCode)
+	}
+	return res
+}
+
+// record adds a result to the totals and the interim window
+func (r *runner) record(res result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, w := range []*window{r.total, r.recent} {
+		w.requests++
+		w.bytes += res.bytes
+		// Latency is of responses; failed connections say nothing about it
+		if res.status != 0 {
+			w.latency.record(res.latency)
+			w.statuses[res.status]++
+		}
+		if res.failure != "" {
+			w.errors++
+			w.failures[res.failure]++
+		}
+	}
+}
+
+// reportInterim prints the results of each -report-every window
+func (r *runner) reportInterim(ctx context.Context, sampler *procSampler) {
+	ticker := time.NewTicker(r.opts.reportEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.mu.Lock()
+			recent := r.recent
+			r.recent = newWindow(now)
+			r.mu.Unlock()
+
+			report := newReport(recent, now.Sub(recent.started), r.opts.customers, len(r.proxies), r.target)
+			line := fmt.Sprintf("[%s] %.1f req/s, %s/s, p50 %s, p99 %s, errors %.2f%%",
+				now.Format(time.TimeOnly), report.RequestsPerSecond, formatBytes(report.BytesPerSecond),
+				formatMillis(report.Latency.P50Ms), formatMillis(report.Latency.P99Ms), report.ErrorRate*100)
+			if sampler != nil {
+				for _, usage := range sampler.current() {
+					line += fmt.Sprintf(", %s %.0f%% CPU %s RSS", usage.Name, usage.CPUPercent, formatBytes(float64(usage.RSSBytes)))
+				}
+			}
+			fmt.Fprintln(os.Stderr, line)
+		}
+	}
+}
+
+// classifyError names a request error for the error breakdown
+func classifyError(err error) string {
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &opErr) && (opErr.Op == "dial" || opErr.Op == "proxyconnect"):
+		return "connect"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "connection closed"
+	default:
+		return "other"
+	}
+}
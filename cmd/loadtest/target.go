@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// serveTarget serves /bytes on addr, returning bodyBytes bytes or ?n= bytes
+// per request, so runs can measure the proxies without depending on an
+// outside site. The target must be reachable from the proxies' upstream.
+// It returns the address served and a function shutting the server down.
+func serveTarget(addr string, bodyBytes int) (string, func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to serve target: %w", err)
+	}
+
+	chunk := make([]byte, 32*1024)
+	for i := range chunk {
+		chunk[i] = 'x'
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bytes", func(w http.ResponseWriter, r *http.Request) {
+		n := bodyBytes
+		if v := r.URL.Query().Get("n"); v != "" {
+			if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+				n = parsed
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(n))
+		for n > 0 {
+			size := len(chunk)
+			if n < size {
+				size = n
+			}
+			if _, err := w.Write(chunk[:size]); err != nil {
+				return
+			}
+			n -= size
+		}
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+
+	shutdown := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}
+	return listener.Addr().String(), shutdown, nil
+}
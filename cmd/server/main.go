@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	"github.com/je265/oceanproxy/internal/app"
 	"github.com/je265/oceanproxy/pkg/config"
 	"github.com/je265/oceanproxy/pkg/logger"
+	"github.com/je265/oceanproxy/pkg/selfupdate"
 )
 
 // @title OceanProxy API
@@ -45,16 +47,19 @@ func main() {
 		GitCommit = "unknown"
 	)
 
+	configOpts := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
 	fmt.Printf("🌊 OceanProxy v%s (built %s, commit %s)\n", Version, BuildTime, GitCommit)
 
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.LoadWithOptions(*configOpts)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Initialize logger
-	zapLogger := logger.New(cfg.Logger.Level, cfg.Logger.Format)
+	// Initialize logger; its level can change on reload
+	zapLogger, logLevel := logger.NewAtomic(cfg.Logger.Level, cfg.Logger.Format)
 	defer func() {
 		if err := zapLogger.Sync(); err != nil {
 			// Ignore sync errors on stdout/stderr
@@ -80,6 +85,10 @@ func main() {
 		zapLogger.Fatal("Failed to create application", zap.Error(err))
 	}
 
+	application.OnReload(func(next *config.Config) {
+		logLevel.SetLevel(logger.ParseLevel(next.Logger.Level))
+	}, "logger.level")
+
 	// Create HTTP server
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
@@ -88,31 +97,104 @@ func main() {
 		WriteTimeout: cfg.Server.WriteTimeout,
 	}
 
+	tlsCfg := cfg.Server.TLS
+	if tlsCfg.Enabled {
+		server.TLSConfig, err = app.NewTLSConfig(tlsCfg)
+		if err != nil {
+			zapLogger.Fatal("Failed to configure TLS", zap.Error(err))
+		}
+	}
+
 	// Start server in a goroutine
 	go func() {
 		zapLogger.Info("HTTP server starting",
 			zap.String("addr", server.Addr),
+			zap.Bool("tls", tlsCfg.Enabled),
+			zap.Bool("mtls_admin", tlsCfg.Enabled && tlsCfg.RequireClientCertForAdmin),
 		)
 
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if tlsCfg.Enabled {
+			// Certificates come from TLSConfig.GetCertificate
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			zapLogger.Fatal("Server failed to start", zap.Error(err))
 		}
 	}()
 
+	// Optional plain HTTP listener that redirects to HTTPS
+	var redirectServer *http.Server
+	if tlsCfg.Enabled && tlsCfg.RedirectHTTP {
+		redirectServer = &http.Server{
+			Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, tlsCfg.HTTPPort),
+			Handler:      app.NewHTTPSRedirectHandler(cfg.Server.Port),
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+		}
+
+		go func() {
+			zapLogger.Info("HTTP redirect server starting", zap.String("addr", redirectServer.Addr))
+
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				zapLogger.Error("Redirect server failed", zap.Error(err))
+			}
+		}()
+	}
+
+	// Finish initialization; until then /ready returns 503 and mutations are rejected
+	if err := application.Start(context.Background()); err != nil {
+		zapLogger.Fatal("Failed to start application", zap.Error(err))
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	// Install signed releases automatically; exiting lets systemd restart the new build
+	updateCtx, stopUpdates := context.WithCancel(context.Background())
+	updater := selfupdate.New(cfg, zapLogger, selfupdate.ComponentServer, Version)
+	go updater.Run(updateCtx, func(version string) {
+		zapLogger.Info("Restarting to run the updated release", zap.String("version", version))
+		quit <- syscall.SIGTERM
+	})
+
+	// SIGHUP reloads the configuration without restarting
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			application.Reload("SIGHUP", func() (*config.Config, error) {
+				return config.LoadWithOptions(*configOpts)
+			})
+		}
+	}()
+
 	<-quit
+	stopUpdates()
 
 	zapLogger.Info("Shutting down server...")
+	application.Stop()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(ctx); err != nil {
+			zapLogger.Error("Redirect server forced to shutdown", zap.Error(err))
+		}
+	}
+
 	if err := server.Shutdown(ctx); err != nil {
 		zapLogger.Error("Server forced to shutdown", zap.Error(err))
 	} else {
 		zapLogger.Info("Server exited gracefully")
 	}
+
+	if err := application.Wait(ctx); err != nil {
+		zapLogger.Error("Background workers did not stop cleanly", zap.Error(err))
+	}
 }
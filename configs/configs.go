@@ -0,0 +1,20 @@
+// Package configs embeds the sample configuration files that
+// oceanproxy-cli init customizes for a new deployment.
+package configs
+
+import _ "embed"
+
+// Config is the sample config.yaml
+//
+//go:embed config.yaml
+var Config []byte
+
+// Regions is the sample regions.yaml
+//
+//go:embed regions.yaml
+var Regions []byte
+
+// ProxyPlans is the sample proxy-plans.yaml
+//
+//go:embed proxy-plans.yaml
+var ProxyPlans []byte
@@ -460,7 +460,7 @@ build_and_install_oceanproxy() {
     /usr/local/go/bin/go build -o bin/oceanproxy cmd/server/main.go
     
     # Build the CLI tool
-    /usr/local/go/bin/go build -o bin/oceanproxy-cli cmd/cli/main.go
+    /usr/local/go/bin/go build -o bin/oceanproxy-cli ./cmd/cli
     
     # Install binaries
     cp bin/oceanproxy /usr/local/bin/
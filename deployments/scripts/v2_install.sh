@@ -661,7 +661,7 @@ build_and_install_oceanproxy() {
     
     mkdir -p bin
     /usr/local/go/bin/go build -o bin/oceanproxy cmd/server/main.go
-    /usr/local/go/bin/go build -o bin/oceanproxy-cli cmd/cli/main.go
+    /usr/local/go/bin/go build -o bin/oceanproxy-cli ./cmd/cli
     
     cp bin/oceanproxy /usr/local/bin/
     cp bin/oceanproxy-cli /usr/local/bin/
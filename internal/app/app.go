@@ -2,9 +2,11 @@
 package app
 
 import (
+	"context"
 	"fmt"
-	"net/http"
+	"net"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -14,23 +16,31 @@ import (
 
 	"github.com/je265/oceanproxy/internal/domain"
 	"github.com/je265/oceanproxy/internal/handlers"
-	"github.com/je265/oceanproxy/internal/repository/json"
+	"github.com/je265/oceanproxy/internal/handlers/compat"
+	"github.com/je265/oceanproxy/internal/repository"
 	"github.com/je265/oceanproxy/internal/service"
 	"github.com/je265/oceanproxy/pkg/config"
 )
 
 // App represents the application
 type App struct {
-	cfg    *config.Config
-	logger *zap.Logger
-	router chi.Router
+	cfg       *config.Config
+	logger    *zap.Logger
+	router    chi.Router
+	lifecycle *Lifecycle
+	readOnly  *handlers.ReadOnlyMode
+
+	services    *Services
+	stopWorkers context.CancelFunc
 }
 
 // New creates a new application instance
 func New(cfg *config.Config, logger *zap.Logger) (*App, error) {
 	app := &App{
-		cfg:    cfg,
-		logger: logger,
+		cfg:       cfg,
+		logger:    logger,
+		lifecycle: NewLifecycle(),
+		readOnly:  handlers.NewReadOnlyMode(cfg.Server.ReadOnly),
 	}
 
 	logger.Info("Initializing OceanProxy application",
@@ -39,58 +49,157 @@ func New(cfg *config.Config, logger *zap.Logger) (*App, error) {
 		zap.String("proxy_domain", cfg.Proxy.Domain),
 	)
 
-	// Initialize repositories
-	planRepo := json.NewPlanRepository(cfg.Database.DSN, logger)
-	instanceRepo := json.NewInstanceRepository(cfg.Database.DSN, logger)
-
-	// Load plan type configurations
-	planTypes, err := loadPlanTypeConfigs(logger)
+	services, err := BuildServices(cfg, logger)
 	if err != nil {
-		logger.Warn("Failed to load plan type configs, using defaults", zap.Error(err))
-		planTypes = getDefaultPlanTypes()
+		return nil, err
 	}
+	app.services = services
 
-	// Load region configurations
-	regions, err := loadRegionConfigs(logger)
+	// Initialize handlers
+	planHandler := handlers.NewPlanHandler(services.Plans, logger)
+	proxyHandler := handlers.NewProxyHandler(services.Proxies, services.HealthChecker, services.Overrides, logger)
+	healthHandler := handlers.NewHealthHandler(logger, app.lifecycle, services.BinaryManager)
+	adminHandler := handlers.NewAdminHandler(cfg, logger, services.UpstreamProber, services.GeoVerifier, services.AuthGuard, services.Cleanup, services.PortManager, services.ConfigReloader, services.Supervisor)
+	accountHandler := handlers.NewProviderAccountHandler(services.Accounts, logger)
+	statsHandler := handlers.NewStatsHandler(services.Stats, services.Reports, services.BandwidthMeter, logger)
+	releaseHandler := handlers.NewReleaseHandler(cfg, logger)
+	metricsHandler := handlers.NewMetricsHandler(services.CustomerMetrics, services.OperatorMetrics, logger)
+	portalHandler := handlers.NewPortalHandler(services.APIKeys, logger)
+	capabilityHandler := handlers.NewCapabilityHandler(services.Capabilities, logger)
+	debugHandler := handlers.NewDebugSamplingHandler(services.DebugSampler, services.Plans, logger)
+	tempCredentialHandler := handlers.NewTempCredentialHandler(services.TempCredentials, services.Plans, logger)
+	subUserHandler := handlers.NewSubUserHandler(services.SubUsers, services.Plans, logger)
+	statusHandler := handlers.NewStatusHandler(services.StatusPage, logger)
+	incidentHandler := handlers.NewIncidentHandler(services.Incidents, logger)
+	regionHandler := handlers.NewRegionHandler(services.RegionService, logger)
+	planTypeHandler := handlers.NewPlanTypeHandler(services.PlanTypeService, logger)
+	voucherHandler := handlers.NewVoucherHandler(services.Vouchers, logger)
+	gitOpsHandler := handlers.NewGitOpsHandler(services.GitOps, logger)
+	applyHandler := handlers.NewApplyHandler(services.Apply, logger)
+	slaHandler := handlers.NewSLAHandler(services.SLA, logger)
+	slackHandler := handlers.NewSlackHandler(services.Slack, app.readOnly, logger)
+	alertHandler := handlers.NewAlertHandler(services.Alerts, logger)
+	deadPlanHandler := handlers.NewDeadPlanHandler(services.DeadPlans, logger)
+	maintenanceHandler := handlers.NewMaintenanceHandler(services.Maintenance, logger)
+	readOnlyHandler := handlers.NewReadOnlyHandler(app.readOnly, logger)
+	v2Handler := handlers.NewV2Handler(services.Plans, services.Proxies, logger)
+	compatHandler := compat.NewHandler(services.Plans, logger)
+
+	// Setup router
+	if err := app.setupRouter(planHandler, proxyHandler, healthHandler, adminHandler, accountHandler, metricsHandler, statsHandler, releaseHandler, portalHandler, capabilityHandler, debugHandler, tempCredentialHandler, subUserHandler, statusHandler, incidentHandler, regionHandler, planTypeHandler, voucherHandler, gitOpsHandler, applyHandler, slaHandler, slackHandler, alertHandler, deadPlanHandler, maintenanceHandler, readOnlyHandler, v2Handler, compatHandler); err != nil {
+		return nil, fmt.Errorf("failed to set up router: %w", err)
+	}
+
+	logger.Info("Application initialized successfully")
+
+	return app, nil
+}
+
+// Start completes initialization and reconciles runtime state with the
+// repositories, then marks the application ready to accept mutations
+func (a *App) Start(ctx context.Context) error {
+	a.lifecycle.set(StateReconciling)
+
+	// A missing or wrong 3proxy version fails /ready rather than startup
+	if err := a.services.BinaryManager.Ensure(ctx); err != nil {
+		a.logger.Error("3proxy binary check failed", zap.Error(err))
+	}
+
+	a.logger.Info("Reconciling application state")
+
+	instances, err := a.services.InstanceRepo.GetAll(ctx)
 	if err != nil {
-		logger.Warn("Failed to load region configs, using defaults", zap.Error(err))
-		regions = getDefaultRegions()
+		return fmt.Errorf("failed to load instances for reconciliation: %w", err)
+	}
+	if err := a.services.PortManager.CheckStore(ctx); err != nil {
+		return fmt.Errorf("port allocation store is unreachable: %w", err)
 	}
+	a.services.PortManager.Reconcile(ctx, instances)
 
-	logger.Info("Loaded configurations",
-		zap.Int("plan_types", len(planTypes)),
-		zap.Int("regions", len(regions)),
-	)
+	// The DNS records file follows the stored regions
+	if err := a.services.RegionService.WriteDNSRecords(); err != nil {
+		a.logger.Error("Failed to write region DNS records", zap.Error(err))
+	}
 
-	// Initialize services
-	providerService := service.NewProviderService(cfg, logger)
-	proxyService := service.NewProxyService(cfg, logger, instanceRepo, planRepo)
-	portManager := service.NewPortManager(logger, planTypes)
-	nginxManager := service.NewNginxManager(logger, cfg, regions, planTypes)
-
-	planService := service.NewPlanService(
-		cfg,
-		logger,
-		planRepo,
-		instanceRepo,
-		providerService,
-		proxyService,
-		portManager,
-		nginxManager,
-		regions,
-	)
+	// Background workers run until Stop
+	workerCtx, cancel := context.WithCancel(context.Background())
+	a.stopWorkers = cancel
 
-	// Initialize handlers
-	planHandler := handlers.NewPlanHandler(planService, logger)
-	proxyHandler := handlers.NewProxyHandler(proxyService, logger)
-	healthHandler := handlers.NewHealthHandler(logger)
+	// Instances of DoH plan types cannot resolve names without their forwarder
+	if err := a.services.DNSForwarders.Start(workerCtx); err != nil {
+		cancel()
+		return err
+	}
 
-	// Setup router
-	app.setupRouter(planHandler, proxyHandler, healthHandler)
+	// Workers return when disabled or stopped; a panicking one is restarted
+	workers := []struct {
+		name string
+		run  func(ctx context.Context)
+	}{
+		{"upstream_prober", a.services.UpstreamProber.Run},
+		{"geo_verifier", a.services.GeoVerifier.Run},
+		{"stats", a.services.Stats.Run},
+		{"traffic_collector", a.services.TrafficCollector.Run},
+		{"health_monitor", a.services.HealthMonitor.Run},
+		{"backup", a.services.Backup.Run},
+		{"expiry", a.services.ExpiryWorker.Run},
+		{"teaser_page", a.services.TeaserPage.Run},
+		{"activation", a.services.ActivationWorker.Run},
+		{"auth_guard", a.services.AuthGuard.Run},
+		{"debug_sampler", a.services.DebugSampler.Run},
+		{"temp_credentials", a.services.TempCredentials.Run},
+		{"subusers", a.services.SubUsers.Run},
+		{"sla", a.services.SLA.Run},
+		{"alerting", a.services.Alerts.Run},
+		{"dead_plans", a.services.DeadPlans.Run},
+		{"reports", a.services.Reports.Run},
+		{"bandwidth_meter", a.services.BandwidthMeter.Run},
+		{"maintenance", a.services.Maintenance.Run},
+		{"gitops", a.services.GitOps.Run},
+	}
+	for _, worker := range workers {
+		run := worker.run
+		a.services.Supervisor.Go(workerCtx, worker.name, service.RestartOnFailure, func(ctx context.Context) error {
+			run(ctx)
+			return nil
+		})
+	}
 
-	logger.Info("Application initialized successfully")
+	a.lifecycle.set(StateReady)
+	a.logger.Info("Application ready")
 
-	return app, nil
+	return nil
+}
+
+// Wait blocks until the background workers stopped by Stop have returned,
+// or ctx is done
+func (a *App) Wait(ctx context.Context) error {
+	return a.services.Supervisor.Wait(ctx)
+}
+
+// OnReload makes keys reloadable; apply is called with the new configuration
+// when any of them changes
+func (a *App) OnReload(apply func(next *config.Config), keys ...string) {
+	a.services.ConfigReloader.Register(apply, keys...)
+}
+
+// Reload loads the configuration and applies the settings that can change
+// while the server runs. Proxy instances are not touched.
+func (a *App) Reload(trigger string, load func() (*config.Config, error)) *domain.ConfigReload {
+	return a.services.ConfigReloader.Reload(trigger, load)
+}
+
+// Stop marks the application as stopping so readiness fails while draining
+func (a *App) Stop() {
+	a.lifecycle.set(StateStopping)
+	if a.stopWorkers != nil {
+		a.stopWorkers()
+	}
+}
+
+// Lifecycle returns the application lifecycle
+func (a *App) Lifecycle() *Lifecycle {
+	return a.lifecycle
 }
 
 // Router returns the HTTP router
@@ -103,45 +212,97 @@ func (a *App) setupRouter(
 	planHandler *handlers.PlanHandler,
 	proxyHandler *handlers.ProxyHandler,
 	healthHandler *handlers.HealthHandler,
-) {
+	adminHandler *handlers.AdminHandler,
+	accountHandler *handlers.ProviderAccountHandler,
+	metricsHandler *handlers.MetricsHandler,
+	statsHandler *handlers.StatsHandler,
+	releaseHandler *handlers.ReleaseHandler,
+	portalHandler *handlers.PortalHandler,
+	capabilityHandler *handlers.CapabilityHandler,
+	debugHandler *handlers.DebugSamplingHandler,
+	tempCredentialHandler *handlers.TempCredentialHandler,
+	subUserHandler *handlers.SubUserHandler,
+	statusHandler *handlers.StatusHandler,
+	incidentHandler *handlers.IncidentHandler,
+	regionHandler *handlers.RegionHandler,
+	planTypeHandler *handlers.PlanTypeHandler,
+	voucherHandler *handlers.VoucherHandler,
+	gitOpsHandler *handlers.GitOpsHandler,
+	applyHandler *handlers.ApplyHandler,
+	slaHandler *handlers.SLAHandler,
+	slackHandler *handlers.SlackHandler,
+	alertHandler *handlers.AlertHandler,
+	deadPlanHandler *handlers.DeadPlanHandler,
+	maintenanceHandler *handlers.MaintenanceHandler,
+	readOnlyHandler *handlers.ReadOnlyHandler,
+	v2Handler *handlers.V2Handler,
+	compatHandler *compat.Handler,
+) error {
 	r := chi.NewRouter()
 
 	// Middleware
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(handlers.NewTrustedProxyMiddleware(a.cfg.Server.TrustedProxies))
+	r.Use(handlers.NewBodyLimitMiddleware(a.cfg.Server.MaxBodyBytes))
+	if timeout := a.cfg.Timeouts.Request; timeout > 0 {
+		r.Use(middleware.Timeout(timeout))
+	}
+	if level := a.cfg.Server.CompressionLevel; level > 0 {
+		r.Use(middleware.Compress(level, "application/json", "text/plain"))
+	}
 
 	// CORS middleware
-	r.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			for _, origin := range a.cfg.Server.CORS.AllowOrigins {
-				w.Header().Set("Access-Control-Allow-Origin", origin)
-			}
-			for _, method := range a.cfg.Server.CORS.AllowMethods {
-				w.Header().Add("Access-Control-Allow-Methods", method)
-			}
-			for _, header := range a.cfg.Server.CORS.AllowHeaders {
-				w.Header().Add("Access-Control-Allow-Headers", header)
-			}
-			if a.cfg.Server.CORS.AllowCredentials {
-				w.Header().Set("Access-Control-Allow-Credentials", "true")
-			}
-
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-
-			next.ServeHTTP(w, r)
-		})
-	})
+	cors, err := handlers.NewCORSMiddleware(a.cfg.Server.CORS, a.logger)
+	if err != nil {
+		return err
+	}
+	r.Use(cors)
+
+	// Reject mutations until initialization and reconciliation complete
+	r.Use(handlers.NewReadinessMiddleware(a.lifecycle, a.logger))
+
+	// Reject mutations while an operator holds the API read-only
+	r.Use(handlers.NewReadOnlyMiddleware(a.readOnly, a.logger))
+	a.services.ConfigReloader.Register(func(next *config.Config) {
+		a.readOnly.Set(next.Server.ReadOnly, "server.read_only", time.Now())
+	}, "server.read_only")
+
+	// Per-client request budgets; only the operator bypass token skips them
+	limits := handlers.NewRateLimits(a.cfg.Server.RateLimit)
+	a.services.ConfigReloader.Register(func(next *config.Config) {
+		limits.Set(next.Server.RateLimit)
+	}, "server.rate_limit")
+	createLimit := handlers.NewRateLimitMiddleware(limits.Create, limits.BypassToken, a.logger)
+	apiLimit := handlers.SplitByMethod(
+		handlers.NewRateLimitMiddleware(limits.Read, limits.BypassToken, a.logger),
+		handlers.NewRateLimitMiddleware(limits.Write, limits.BypassToken, a.logger),
+	)
+	publicLimit := handlers.NewRateLimitMiddleware(limits.Public, limits.BypassToken, a.logger)
 
 	// Health checks (no auth required)
 	r.Get("/health", healthHandler.Health)
 	r.Get("/ready", healthHandler.Ready)
 
+	// Public status page
+	r.With(publicLimit).Get("/status", statusHandler.GetStatus)
+	r.With(publicLimit).Get("/status/{region}", statusHandler.GetRegionStatus)
+
+	// Customer-scoped metrics (the token is the credential)
+	r.With(publicLimit).Get("/metrics/customer/{token}", metricsHandler.GetCustomerMetrics)
+
+	// Slack slash commands, authenticated with the Slack app's request signature
+	if a.cfg.Slack.Enabled {
+		r.With(publicLimit).Post("/slack/commands", slackHandler.Command)
+	}
+
+	// Customer portal API, authenticated with read-only per-plan API keys
+	r.Route("/portal/v1", func(r chi.Router) {
+		r.Use(publicLimit)
+		r.Get("/usage", portalHandler.GetUsage)
+	})
+
 	// Log the bearer token being used (for debugging)
 	a.logger.Info("Setting up authentication",
 		zap.String("bearer_token", a.cfg.Auth.BearerToken),
@@ -150,42 +311,176 @@ func (a *App) setupRouter(
 	// API routes with authentication
 	r.Route("/api/v1", func(r chi.Router) {
 		// FIXED: Use the correct bearer token from config
-		r.Use(handlers.NewAuthMiddleware(a.cfg.Auth.BearerToken, a.logger))
+		a.useAdminAuth(r)
+		r.Use(apiLimit)
 
 		// Plan management
 		r.Route("/plans", func(r chi.Router) {
-			r.Post("/", planHandler.CreatePlan)
+			r.With(createLimit).Post("/", planHandler.CreatePlan)
 			r.Get("/", planHandler.GetPlans)
+			r.Get("/expiring", planHandler.GetExpiringPlans)
 			r.Get("/{id}", planHandler.GetPlan)
+			r.Get("/{id}/events", planHandler.GetPlanEvents)
+			r.Get("/{id}/endpoints", planHandler.GetPlanEndpoints)
+			r.Get("/{id}/stats", statsHandler.GetPlanStats)
+			r.Get("/{id}/usage", statsHandler.GetPlanUsage)
 			r.Delete("/{id}", planHandler.DeletePlan)
+			r.With(createLimit).Post("/{id}/clone", planHandler.ClonePlan)
+			r.Post("/{id}/topup", planHandler.TopUpPlan)
+			r.Put("/{id}/auto-renew", planHandler.SetAutoRenew)
+			r.Put("/{id}/expiry-action", planHandler.SetExpiryAction)
+			r.Put("/{id}/allowed-destinations", planHandler.SetAllowedDestinations)
+			r.Put("/{id}/headers", planHandler.SetHeaderPolicy)
+			r.Put("/{id}/cache", planHandler.SetResponseCache)
+			r.Post("/{id}/cache/purge", planHandler.PurgeResponseCache)
+			r.Post("/{id}/rotate-password", planHandler.RotatePassword)
+			r.Put("/{id}/debug-sampling", debugHandler.StartDebugSampling)
+			r.Delete("/{id}/debug-sampling", debugHandler.StopDebugSampling)
+			r.Get("/{id}/debug-samples", debugHandler.GetDebugSamples)
+			r.Post("/{id}/temp-credentials", tempCredentialHandler.IssueTempCredential)
+			r.Delete("/{id}/temp-credentials/{credential_id}", tempCredentialHandler.RevokeTempCredential)
+			r.Get("/{id}/subusers", subUserHandler.GetSubUsers)
+			r.Post("/{id}/subusers", subUserHandler.CreateSubUser)
+			r.Get("/{id}/subusers/{subuser_id}", subUserHandler.GetSubUser)
+			r.Put("/{id}/subusers/{subuser_id}", subUserHandler.UpdateSubUser)
+			r.Delete("/{id}/subusers/{subuser_id}", subUserHandler.DeleteSubUser)
+			r.Post("/{id}/api-keys", portalHandler.CreateAPIKey)
+			r.Get("/{id}/api-keys", portalHandler.GetAPIKeys)
+			r.Delete("/{id}/api-keys/{key_id}", portalHandler.RevokeAPIKey)
 		})
 
 		// Proxy management
 		r.Route("/proxies", func(r chi.Router) {
 			r.Get("/", proxyHandler.GetProxies)
+			r.Post("/health-check", proxyHandler.HealthCheckProxies)
 			r.Get("/{id}", proxyHandler.GetProxy)
 			r.Post("/{id}/start", proxyHandler.StartProxy)
 			r.Post("/{id}/stop", proxyHandler.StopProxy)
 			r.Post("/{id}/restart", proxyHandler.RestartProxy)
+			r.Put("/{id}/overrides", proxyHandler.SetProxyOverrides)
 			r.Get("/{id}/status", proxyHandler.GetProxyStatus)
 		})
 
+		// Upstream provider accounts
+		r.Route("/provider-accounts", func(r chi.Router) {
+			r.Get("/", accountHandler.GetAccounts)
+			r.Get("/{id}", accountHandler.GetAccount)
+			r.Post("/{id}/renew", accountHandler.RenewAccount)
+			r.Post("/{id}/sync", accountHandler.SyncUsage)
+			r.Delete("/{id}", accountHandler.DeleteAccount)
+		})
+
+		// Declarative bulk management of plans
+		r.With(createLimit).Post("/apply", applyHandler.Apply)
+
 		// Statistics
-		r.Get("/stats", planHandler.GetStats)
+		r.Get("/stats", statsHandler.GetStats)
+		r.Get("/stats/margins", statsHandler.GetMargins)
+		r.Get("/stats/report", statsHandler.GetReport)
+		r.Post("/stats/report/send", statsHandler.SendReport)
+
+		// Uptime against SLA targets
+		r.Get("/sla", slaHandler.GetSLA)
+
+		// Features of the purchasable plan types
+		r.Get("/capabilities", capabilityHandler.GetCapabilities)
+		r.Get("/config/endpoint-map", capabilityHandler.GetEndpointMap)
+
+		// Signed releases for self-updating nodes and CLIs
+		r.Get("/releases/latest", releaseHandler.GetLatestRelease)
 	})
 
-	// Legacy endpoints for backward compatibility
-	r.Route("/", func(r chi.Router) {
-		r.Use(handlers.NewAuthMiddleware(a.cfg.Auth.BearerToken, a.logger))
+	// Operator endpoints
+	r.Route("/admin", func(r chi.Router) {
+		a.useAdminAuth(r)
+		r.Use(apiLimit)
+
+		r.Get("/config", adminHandler.GetConfig)
+		r.Get("/readonly", readOnlyHandler.GetReadOnly)
+		r.Put("/readonly", readOnlyHandler.SetReadOnly)
+		r.Get("/config/last-reload", adminHandler.GetLastReload)
+		r.Get("/providers/proxies_fo/reseller-ids", adminHandler.GetProxiesFoResellerIDs)
+		r.Get("/upstreams", adminHandler.GetUpstreams)
+		r.Get("/workers", adminHandler.GetWorkers)
+		r.Get("/metrics", metricsHandler.GetOperatorMetrics)
+		r.Get("/egress-ips", adminHandler.GetEgressIPs)
+		r.Get("/geo-mismatches", adminHandler.GetGeoMismatches)
+		r.Post("/plans/{id}/verify-geo", adminHandler.VerifyPlanGeo)
+		r.Get("/auth-blocks", adminHandler.GetAuthBlocks)
+		r.Delete("/auth-blocks/{ip}", adminHandler.DeleteAuthBlock)
+		r.Post("/cleanup", adminHandler.Cleanup)
+		r.Get("/customers/{customer_id}/metrics-token", metricsHandler.IssueCustomerToken)
+		r.Get("/incidents", incidentHandler.GetIncidents)
+		r.Get("/incidents/{id}", incidentHandler.GetIncident)
+		r.Post("/incidents/{id}/acknowledge", incidentHandler.AcknowledgeIncident)
+		r.Post("/incidents/{id}/resolve", incidentHandler.ResolveIncident)
+		r.Post("/incidents/{id}/annotations", incidentHandler.AnnotateIncident)
+		r.Get("/alerts", alertHandler.GetAlerts)
+		r.Get("/dead-plans", deadPlanHandler.GetDeadPlans)
+		r.Get("/maintenance", maintenanceHandler.GetMaintenance)
+		r.Post("/maintenance", maintenanceHandler.CreateMaintenance)
+		r.Delete("/maintenance/{id}", maintenanceHandler.DeleteMaintenance)
+		r.Get("/regions", regionHandler.GetRegions)
+		r.Post("/regions", regionHandler.CreateRegion)
+		r.Get("/regions/export", regionHandler.ExportRegions)
+		r.Get("/regions/{name}", regionHandler.GetRegion)
+		r.Put("/regions/{name}", regionHandler.UpdateRegion)
+		r.Delete("/regions/{name}", regionHandler.DeleteRegion)
+		r.Get("/plan-types", planTypeHandler.GetPlanTypes)
+		r.Post("/plan-types", planTypeHandler.CreatePlanType)
+		r.Get("/plan-types/{key}", planTypeHandler.GetPlanType)
+		r.Put("/plan-types/{key}", planTypeHandler.UpdatePlanType)
+		r.Post("/plan-types/{key}/disable", planTypeHandler.DisablePlanType)
+		r.Post("/plan-types/{key}/enable", planTypeHandler.EnablePlanType)
+		r.Put("/plan-types/{key}/pricing", planTypeHandler.SetPlanTypePricing)
+		r.Delete("/plan-types/{key}/pricing", planTypeHandler.DeletePlanTypePricing)
+		r.Get("/vouchers", voucherHandler.GetVouchers)
+		r.Post("/vouchers", voucherHandler.GenerateVouchers)
+		r.Get("/vouchers/redemptions", voucherHandler.GetRedemptions)
+		r.Get("/vouchers/{code}", voucherHandler.GetVoucher)
+		r.Post("/vouchers/{code}/revoke", voucherHandler.RevokeVoucher)
+		r.Get("/gitops", gitOpsHandler.GetReport)
+		r.Post("/gitops/sync", gitOpsHandler.Sync)
+	})
 
-		// Proxies.fo legacy endpoint
-		r.Post("/plan", planHandler.CreateProxiesFoPlan)
+	// Breaking improvements: paginated envelopes and typed errors
+	r.Route("/api/v2", func(r chi.Router) {
+		a.useAdminAuth(r)
+		r.Use(apiLimit)
 
-		// Nettify legacy endpoint
-		r.Post("/nettify/plan", planHandler.CreateNettifyPlan)
+		r.With(createLimit).Post("/plans", v2Handler.CreatePlan)
+		r.Get("/plans", v2Handler.GetPlans)
+		r.Get("/plans/{id}", v2Handler.GetPlan)
+		r.Get("/plans/{id}/events", v2Handler.GetPlanEvents)
+		r.Get("/proxies", v2Handler.GetProxies)
 	})
 
+	// Legacy endpoints for backward compatibility, deprecated
+	if a.cfg.API.LegacyRoutes {
+		r.Route("/", func(r chi.Router) {
+			a.useAdminAuth(r)
+			r.Use(createLimit)
+			r.Use(handlers.NewDeprecationMiddleware(a.cfg.API.Sunset(), "/api/v2/plans", a.logger))
+
+			// Proxies.fo legacy endpoint
+			r.Post("/plan", compatHandler.CreateProxiesFoPlan)
+
+			// Nettify legacy endpoint
+			r.Post("/nettify/plan", compatHandler.CreateNettifyPlan)
+		})
+	}
+
 	a.router = r
+	return nil
+}
+
+// useAdminAuth mounts bearer auth, plus client certificate checks when mTLS
+// is required for admin routes
+func (a *App) useAdminAuth(r chi.Router) {
+	if a.cfg.Server.TLS.Enabled && a.cfg.Server.TLS.RequireClientCertForAdmin {
+		r.Use(handlers.NewClientCertMiddleware(a.logger))
+	}
+	r.Use(handlers.NewAuthMiddleware(a.cfg.Auth.BearerToken, a.logger))
 }
 
 // Helper functions to load configurations
@@ -214,6 +509,10 @@ func loadPlanTypeConfigs(logger *zap.Logger) (map[string]*domain.PlanTypeConfig,
 				continue
 			}
 
+			if err := validatePlanTypes(config.PlanTypes); err != nil {
+				return nil, err
+			}
+
 			return config.PlanTypes, nil
 		}
 	}
@@ -221,6 +520,36 @@ func loadPlanTypeConfigs(logger *zap.Logger) (map[string]*domain.PlanTypeConfig,
 	return nil, fmt.Errorf("no plan type configuration file found")
 }
 
+// validatePlanTypes checks the local and egress addresses of plan types. An
+// egress IP is listed once, so pools never hand out the same IP.
+func validatePlanTypes(planTypes map[string]*domain.PlanTypeConfig) error {
+	egressOwners := make(map[string]string)
+	for key, planType := range planTypes {
+		if planType.BindAddress != "" && net.ParseIP(planType.BindAddress) == nil {
+			return fmt.Errorf("plan type %s: bind_address %q is not an IP address", key, planType.BindAddress)
+		}
+		if planType.Pricing != nil {
+			if err := planType.Pricing.Validate(); err != nil {
+				return fmt.Errorf("plan type %s: %w", key, err)
+			}
+		}
+
+		if planType.Egress == nil {
+			continue
+		}
+		for _, ip := range planType.Egress.IPs {
+			if net.ParseIP(ip) == nil {
+				return fmt.Errorf("plan type %s: egress IP %q is not an IP address", key, ip)
+			}
+			if owner, exists := egressOwners[ip]; exists {
+				return fmt.Errorf("plan type %s: egress IP %s is also listed by %s", key, ip, owner)
+			}
+			egressOwners[ip] = key
+		}
+	}
+	return nil
+}
+
 func loadRegionConfigs(logger *zap.Logger) (map[string]*domain.Region, error) {
 	// Try multiple paths for region configs
 	configPaths := []string{
@@ -246,6 +575,12 @@ func loadRegionConfigs(logger *zap.Logger) (map[string]*domain.Region, error) {
 				continue
 			}
 
+			// Regions are keyed by name in the file
+			for name, region := range config.Regions {
+				if region.Name == "" {
+					region.Name = name
+				}
+			}
 			return config.Regions, nil
 		}
 	}
@@ -253,6 +588,83 @@ func loadRegionConfigs(logger *zap.Logger) (map[string]*domain.Region, error) {
 	return nil, fmt.Errorf("no region configuration file found")
 }
 
+// loadRegions returns the stored regions. The first boot seeds the store
+// from regions.yaml, or the defaults; from then on regions are managed
+// through /admin/regions and regions.yaml is only read to re-seed an
+// emptied store.
+func loadRegions(ctx context.Context, repo repository.RegionRepository, logger *zap.Logger) (map[string]*domain.Region, error) {
+	stored, err := repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) > 0 {
+		regions := make(map[string]*domain.Region, len(stored))
+		for _, region := range stored {
+			regions[region.Name] = region
+		}
+		logger.Info("Loaded regions from the region store", zap.Int("regions", len(regions)))
+		return regions, nil
+	}
+
+	regions, err := loadRegionConfigs(logger)
+	if err != nil {
+		logger.Warn("Failed to load region configs, using defaults", zap.Error(err))
+		regions = getDefaultRegions()
+	}
+
+	names := make([]string, 0, len(regions))
+	for name := range regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := repo.Create(ctx, regions[name]); err != nil {
+			return nil, fmt.Errorf("failed to seed region %s: %w", name, err)
+		}
+	}
+	logger.Info("Seeded the region store from configuration", zap.Int("regions", len(regions)))
+
+	return regions, nil
+}
+
+// loadPlanTypes returns the stored plan types. The first boot seeds the store
+// from proxy-plans.yaml, or the defaults; from then on plan types are managed
+// through /admin/plan-types and proxy-plans.yaml is only read to re-seed an
+// emptied store.
+func loadPlanTypes(ctx context.Context, repo repository.PlanTypeRepository, logger *zap.Logger) (map[string]*domain.PlanTypeConfig, error) {
+	stored, err := repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) > 0 {
+		if err := validatePlanTypes(stored); err != nil {
+			return nil, err
+		}
+		logger.Info("Loaded plan types from the plan type store", zap.Int("plan_types", len(stored)))
+		return stored, nil
+	}
+
+	planTypes, err := loadPlanTypeConfigs(logger)
+	if err != nil {
+		logger.Warn("Failed to load plan type configs, using defaults", zap.Error(err))
+		planTypes = getDefaultPlanTypes()
+	}
+
+	keys := make([]string, 0, len(planTypes))
+	for key := range planTypes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := repo.Create(ctx, key, planTypes[key]); err != nil {
+			return nil, fmt.Errorf("failed to seed plan type %s: %w", key, err)
+		}
+	}
+	logger.Info("Seeded the plan type store from configuration", zap.Int("plan_types", len(planTypes)))
+
+	return planTypes, nil
+}
+
 // Default configurations
 func getDefaultPlanTypes() map[string]*domain.PlanTypeConfig {
 	return map[string]*domain.PlanTypeConfig{
@@ -314,6 +726,7 @@ func getDefaultRegions() map[string]*domain.Region {
 				"proxies_fo_usa_datacenter",
 			},
 			NginxConfigFile: "oceanproxy_usa.conf",
+			Countries:       []string{"US"},
 		},
 		"eu": {
 			Name:         "eu",
@@ -326,6 +739,10 @@ func getDefaultRegions() map[string]*domain.Region {
 				"proxies_fo_eu_datacenter",
 			},
 			NginxConfigFile: "oceanproxy_eu.conf",
+			Countries: []string{
+				"AT", "BE", "BG", "CY", "CZ", "DE", "DK", "EE", "ES", "FI", "FR", "GR", "HR", "HU",
+				"IE", "IT", "LT", "LU", "LV", "MT", "NL", "PL", "PT", "RO", "SE", "SI", "SK",
+			},
 		},
 		"alpha": {
 			Name:         "alpha",
@@ -0,0 +1,38 @@
+package app
+
+import "sync/atomic"
+
+// Lifecycle states, in the order an App moves through them
+const (
+	StateStarting    = "starting"
+	StateReconciling = "reconciling"
+	StateReady       = "ready"
+	StateStopping    = "stopping"
+)
+
+// Lifecycle tracks whether the application may admit traffic. Only the
+// ready state accepts mutating API requests.
+type Lifecycle struct {
+	state atomic.Value
+}
+
+// NewLifecycle creates a lifecycle in the starting state
+func NewLifecycle() *Lifecycle {
+	l := &Lifecycle{}
+	l.state.Store(StateStarting)
+	return l
+}
+
+// State returns the current lifecycle state
+func (l *Lifecycle) State() string {
+	return l.state.Load().(string)
+}
+
+// IsReady reports whether initialization and reconciliation have completed
+func (l *Lifecycle) IsReady() bool {
+	return l.State() == StateReady
+}
+
+func (l *Lifecycle) set(state string) {
+	l.state.Store(state)
+}
@@ -0,0 +1,243 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/internal/repository/json"
+	"github.com/je265/oceanproxy/internal/repository/postgres"
+	"github.com/je265/oceanproxy/internal/service"
+	"github.com/je265/oceanproxy/internal/service/provider"
+	"github.com/je265/oceanproxy/pkg/config"
+	"github.com/je265/oceanproxy/pkg/redis"
+)
+
+// Services is the repository and service layer built from a configuration.
+// The server, the CLI and tests build it with BuildServices, so they run
+// against the same wiring. Building starts nothing: the server's Start
+// reconciles ports and runs the workers.
+type Services struct {
+	PlanTypes *service.PlanTypeRegistry
+	Regions   *service.RegionRegistry
+
+	PlanRepo        repository.PlanRepository
+	InstanceRepo    repository.InstanceRepository
+	AccountRepo     repository.ProviderAccountRepository
+	EventRepo       repository.PlanEventRepository
+	StatsRepo       repository.StatsRepository
+	APIKeyRepo      repository.APIKeyRepository
+	IncidentRepo    repository.IncidentRepository
+	RegionRepo      repository.RegionRepository
+	PlanTypeRepo    repository.PlanTypeRepository
+	GitOpsRepo      repository.GitOpsRepository
+	AppliedRepo     repository.AppliedPlanRepository
+	SubUserRepo     repository.SubUserUsageRepository
+	VoucherRepo     repository.VoucherRepository
+	AlertRepo       repository.AlertRepository
+	MaintenanceRepo repository.MaintenanceRepository
+
+	Notifier         service.Notifier
+	Providers        service.ProviderService
+	Accounts         service.ProviderAccountService
+	Proxies          service.ProxyService
+	Plans            service.PlanService
+	APIKeys          *service.APIKeyService
+	PortManager      *service.PortManager
+	NginxManager     *service.NginxManager
+	Overrides        *service.InstanceOverrides
+	BinaryManager    *service.BinaryManager
+	DNSForwarders    *service.DNSForwarders
+	UpstreamProber   *service.UpstreamProber
+	UpstreamLatency  *service.UpstreamLatency
+	GeoVerifier      *service.GeoVerifier
+	Stats            *service.StatsService
+	Reports          *service.ReportService
+	BandwidthMeter   *service.BandwidthMeter
+	TrafficCollector *service.TrafficCollector
+	HealthChecker    *service.HealthChecker
+	HealthMonitor    *service.HealthMonitor
+	Backup           *service.BackupService
+	ExpiryWorker     *service.ExpiryWorker
+	TeaserPage       *service.TeaserPage
+	ActivationWorker *service.ActivationWorker
+	AuthGuard        *service.AuthGuard
+	Cleanup          *service.CleanupService
+	RegionService    *service.RegionService
+	PlanTypeService  *service.PlanTypeService
+	GitOps           *service.GitOpsController
+	Apply            *service.ApplyService
+	CustomerMetrics  *service.CustomerMetrics
+	OperatorMetrics  *service.OperatorMetrics
+	SchemaGuard      *provider.SchemaGuard
+	Capabilities     *service.CapabilityService
+	DebugSampler     *service.DebugSampler
+	TempCredentials  *service.TempCredentialService
+	SubUsers         *service.SubUserService
+	Vouchers         *service.VoucherService
+	StatusPage       *service.StatusPageService
+	Incidents        *service.IncidentService
+	SLA              *service.SLAService
+	Slack            *service.SlackService
+	Alerts           *service.AlertMonitor
+	Maintenance      *service.MaintenanceService
+	DeadPlans        *service.DeadPlanDetector
+	ConfigReloader   *service.ConfigReloader
+	Supervisor       *service.Supervisor
+}
+
+// BuildServices migrates the data files, loads the plan type and region
+// configurations and wires every repository and service
+func BuildServices(cfg *config.Config, logger *zap.Logger) (*Services, error) {
+	dataFile := cfg.Database.JSONFile()
+
+	// Bring data files written by older builds up to date before anything
+	// reads them; files from a newer build stop here
+	if _, err := json.Migrate(dataFile, logger); err != nil {
+		return nil, fmt.Errorf("failed to migrate data files: %w", err)
+	}
+
+	s := &Services{
+		PlanRepo:        json.NewPlanRepository(dataFile, logger),
+		InstanceRepo:    json.NewInstanceRepository(dataFile, logger),
+		AccountRepo:     json.NewProviderAccountRepository(dataFile, logger),
+		EventRepo:       json.NewPlanEventRepository(dataFile, logger),
+		StatsRepo:       json.NewStatsRepository(dataFile, logger),
+		APIKeyRepo:      json.NewAPIKeyRepository(dataFile, logger),
+		IncidentRepo:    json.NewIncidentRepository(dataFile, logger),
+		RegionRepo:      json.NewRegionRepository(dataFile, logger),
+		PlanTypeRepo:    json.NewPlanTypeRepository(dataFile, logger),
+		GitOpsRepo:      json.NewGitOpsRepository(dataFile, logger),
+		AppliedRepo:     json.NewAppliedPlanRepository(dataFile, logger),
+		SubUserRepo:     json.NewSubUserUsageRepository(dataFile, logger),
+		VoucherRepo:     json.NewVoucherRepository(dataFile, logger),
+		AlertRepo:       json.NewAlertRepository(dataFile, logger),
+		MaintenanceRepo: json.NewMaintenanceRepository(dataFile, logger),
+	}
+
+	if cfg.Database.Driver == config.DatabaseDriverPostgres {
+		db, err := postgres.Open(context.Background(), cfg.Database, logger)
+		if err != nil {
+			return nil, err
+		}
+		s.PlanRepo = postgres.NewPlanRepository(db, logger)
+		s.InstanceRepo = postgres.NewInstanceRepository(db, logger)
+	}
+
+	// Load plan types, seeding the plan type store from configuration on first boot
+	planTypeConfigs, err := loadPlanTypes(context.Background(), s.PlanTypeRepo, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plan types: %w", err)
+	}
+	planTypes := service.NewPlanTypeRegistry(planTypeConfigs)
+	s.PlanTypes = planTypes
+	for _, warning := range planTypes.NegativeMargins() {
+		logger.Warn("Plan type sells below upstream cost",
+			zap.String("plan_type", warning.PlanTypeKey),
+			zap.Float64("margin_per_gb", warning.MarginPerGB))
+	}
+
+	// Load regions, seeding the region store from configuration on first boot
+	regions, err := loadRegions(context.Background(), s.RegionRepo, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load regions: %w", err)
+	}
+	s.Regions = service.NewRegionRegistry(regions)
+
+	logger.Info("Loaded configurations",
+		zap.Int("plan_types", len(planTypeConfigs)),
+		zap.Int("regions", len(regions)),
+	)
+
+	s.ConfigReloader = service.NewConfigReloader(cfg, logger)
+	s.Supervisor = service.NewSupervisor(logger)
+	s.SchemaGuard = provider.NewSchemaGuard(logger)
+	s.Providers = service.NewProviderService(cfg, logger, s.SchemaGuard)
+	s.ConfigReloader.Register(func(next *config.Config) {
+		s.Providers.Reconfigure(next.Providers)
+	}, "providers.proxies_fo.api_key", "providers.proxies_fo.base_url", "providers.proxies_fo.reseller_ids",
+		"providers.nettify.api_key", "providers.nettify.base_url")
+	s.Notifier = service.NewNotifier(cfg, logger)
+	exhaustion := service.NewExhaustionMonitor(logger, s.PlanRepo, s.EventRepo, s.Notifier)
+	s.Accounts = service.NewProviderAccountService(cfg, logger, s.AccountRepo, s.Providers, exhaustion)
+	s.UpstreamLatency = service.NewUpstreamLatency(cfg, planTypes)
+	s.UpstreamProber = service.NewUpstreamProber(cfg, logger, planTypes, s.UpstreamLatency)
+	s.BinaryManager = service.NewBinaryManager(cfg, logger)
+	bans := service.NewBanList()
+	s.DNSForwarders = service.NewDNSForwarders(cfg, logger, planTypes)
+	s.Maintenance = service.NewMaintenanceService(cfg, logger, s.MaintenanceRepo, planTypes)
+	crashLoops := service.NewCrashLoopDetector(cfg, logger, s.Notifier, s.Maintenance)
+	s.Proxies = service.NewProxyService(cfg, logger, s.InstanceRepo, s.PlanRepo, s.EventRepo, planTypes, s.UpstreamProber, exhaustion, s.BinaryManager, bans, s.DNSForwarders, s.Supervisor, crashLoops, s.Maintenance)
+	s.PortManager = service.NewPortManager(logger, planTypes, newPortStore(cfg))
+	s.NginxManager = service.NewNginxManager(logger, cfg, s.Regions, planTypes)
+	s.Overrides = service.NewInstanceOverrides(logger, s.InstanceRepo, s.EventRepo, s.Proxies, s.NginxManager)
+
+	s.GeoVerifier = service.NewGeoVerifier(cfg, logger, s.PlanRepo, s.InstanceRepo, s.EventRepo, s.Proxies, s.Regions, planTypes)
+	s.Stats = service.NewStatsService(cfg, logger, s.StatsRepo, s.PlanRepo, s.InstanceRepo, s.PlanTypes)
+	s.TrafficCollector = service.NewTrafficCollector(cfg, logger, s.InstanceRepo, s.StatsRepo, s.UpstreamLatency)
+	s.HealthChecker = service.NewHealthChecker(s.Proxies, cfg.Proxy.HealthCheckWorkers)
+	s.Incidents = service.NewIncidentService(logger, s.IncidentRepo, planTypes)
+	s.HealthMonitor = service.NewHealthMonitor(cfg, logger, s.InstanceRepo, s.EventRepo, s.HealthChecker, s.Incidents, planTypes, crashLoops, s.Maintenance)
+	s.Backup = service.NewBackupService(cfg, logger)
+	s.AuthGuard = service.NewAuthGuard(cfg, logger, s.InstanceRepo, s.Proxies, bans)
+	s.ActivationWorker = service.NewActivationWorker(cfg, logger, s.PlanRepo, s.InstanceRepo, s.EventRepo, s.Proxies, s.NginxManager, s.Notifier)
+
+	s.Vouchers = service.NewVoucherService(logger, s.VoucherRepo)
+	s.Plans = service.NewPlanService(
+		cfg,
+		logger,
+		s.PlanRepo,
+		s.InstanceRepo,
+		s.EventRepo,
+		s.Accounts,
+		s.Providers,
+		s.Proxies,
+		s.PortManager,
+		s.NginxManager,
+		s.GeoVerifier,
+		s.Regions,
+		s.ActivationWorker,
+		s.Supervisor,
+		s.Vouchers,
+		s.Notifier,
+	)
+	s.TeaserPage = service.NewTeaserPage(cfg, logger)
+	s.ExpiryWorker = service.NewExpiryWorker(cfg, logger, s.PlanRepo, s.InstanceRepo, s.EventRepo, s.Proxies, s.Accounts, s.Plans, service.NewPaymentProvider(cfg, logger), s.Notifier, planTypes)
+	s.Cleanup = service.NewCleanupService(cfg, logger, s.PlanRepo, s.InstanceRepo, s.EventRepo, s.Proxies, s.PortManager, s.NginxManager)
+	s.RegionService = service.NewRegionService(cfg, logger, s.RegionRepo, s.PlanRepo, s.InstanceRepo, s.Regions, planTypes, s.NginxManager)
+	s.PlanTypeService = service.NewPlanTypeService(cfg, logger, s.PlanTypeRepo, s.InstanceRepo, planTypes, s.Regions, s.DNSForwarders, s.PortManager, s.NginxManager)
+	s.GitOps = service.NewGitOpsController(cfg, logger, s.GitOpsRepo, s.RegionService, s.PlanTypeService, s.Plans)
+	s.Apply = service.NewApplyService(logger, s.AppliedRepo, s.Plans)
+	s.CustomerMetrics = service.NewCustomerMetrics(cfg, logger, s.PlanRepo, s.InstanceRepo, s.AccountRepo, s.StatsRepo)
+	s.OperatorMetrics = service.NewOperatorMetrics(s.SchemaGuard, s.UpstreamLatency)
+	s.Capabilities = service.NewCapabilityService(cfg, s.Providers, planTypes, s.Regions)
+	s.DebugSampler = service.NewDebugSampler(cfg, logger, s.PlanRepo, s.InstanceRepo, s.EventRepo)
+	s.TempCredentials = service.NewTempCredentialService(cfg, logger, s.PlanRepo, s.InstanceRepo, s.EventRepo, s.Plans, s.Proxies)
+	s.SubUsers = service.NewSubUserService(cfg, logger, s.PlanRepo, s.InstanceRepo, s.SubUserRepo, s.EventRepo, s.Proxies)
+	s.StatusPage = service.NewStatusPageService(cfg, logger, s.InstanceRepo, s.EventRepo, s.Incidents, s.Regions, planTypes)
+	s.SLA = service.NewSLAService(cfg, logger, s.PlanRepo, s.InstanceRepo, s.EventRepo, s.Notifier, planTypes)
+	s.Slack = service.NewSlackService(cfg, logger, s.PlanRepo, s.InstanceRepo, s.Plans, s.Proxies, s.Incidents, s.Supervisor)
+	s.DeadPlans = service.NewDeadPlanDetector(cfg, logger, s.PlanRepo, s.InstanceRepo, s.AccountRepo, s.EventRepo, s.Stats)
+	s.Reports = service.NewReportService(cfg, logger, s.PlanRepo, s.EventRepo, s.Stats)
+	s.BandwidthMeter = service.NewBandwidthMeter(cfg, logger, s.PlanRepo, s.InstanceRepo, s.StatsRepo, s.EventRepo, s.Stats, planTypes, s.Proxies, s.Notifier)
+	s.Alerts = service.NewAlertMonitor(cfg, logger, s.AlertRepo, s.PlanRepo, s.InstanceRepo, planTypes, s.PortManager, s.UpstreamProber, s.Maintenance)
+	s.APIKeys = service.NewAPIKeyService(logger, s.APIKeyRepo, s.PlanRepo, s.InstanceRepo, s.AccountRepo, s.EventRepo, s.Plans, s.SubUsers, s.BandwidthMeter)
+
+	return s, nil
+}
+
+// newPortStore returns the store port allocations are shared through, or
+// nil to keep them in the process
+func newPortStore(cfg *config.Config) service.PortStore {
+	if cfg.PortAllocation.Backend != config.PortAllocationRedis {
+		return nil
+	}
+	client := redis.New(redis.Config{
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+	return service.NewRedisPortStore(client, cfg.PortAllocation.KeyPrefix)
+}
@@ -0,0 +1,183 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StandardWindow names the traffic sent outside every accounting window,
+// which counts in full
+const StandardWindow = "standard"
+
+// AccountingSchedule weighs a plan type's measured traffic by when it was
+// sent, so that off-peak traffic can count for less against plan bandwidth
+// than peak traffic. Traffic outside every window counts in full.
+type AccountingSchedule struct {
+	// Timezone is the IANA zone the windows are in; the billing timezone
+	// when empty
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+
+	// Windows are matched in order; the first covering a minute sets the
+	// rate its traffic counts at
+	Windows []AccountingWindow `yaml:"windows" json:"windows"`
+}
+
+// AccountingWindow is a daily span of time whose traffic counts at Rate
+type AccountingWindow struct {
+	Name string `yaml:"name" json:"name"`
+
+	// Days are the weekdays, by name, the window starts on; every day when
+	// empty
+	Days []string `yaml:"days,omitempty" json:"days,omitempty"`
+
+	// Start and End are HH:MM. A window ending at or before its start runs
+	// past midnight into the next day.
+	Start string `yaml:"start" json:"start"`
+	End   string `yaml:"end" json:"end"`
+
+	// Rate is the share of the window's bytes counted, 0.5 for half
+	Rate float64 `yaml:"rate" json:"rate"`
+}
+
+// Validate checks the timezone, days, times and rates of the windows
+func (s *AccountingSchedule) Validate() error {
+	if s.Timezone != "" {
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			return fmt.Errorf("accounting: unknown time zone %q", s.Timezone)
+		}
+	}
+	if len(s.Windows) == 0 {
+		return fmt.Errorf("accounting: at least one window is required")
+	}
+
+	names := make(map[string]bool, len(s.Windows))
+	for _, window := range s.Windows {
+		if window.Name == "" || window.Name == StandardWindow {
+			return fmt.Errorf("accounting: windows need a name other than %q", StandardWindow)
+		}
+		if names[window.Name] {
+			return fmt.Errorf("accounting: duplicate window %q", window.Name)
+		}
+		names[window.Name] = true
+
+		for _, day := range window.Days {
+			if _, err := parseWeekday(day); err != nil {
+				return fmt.Errorf("accounting: window %q: %w", window.Name, err)
+			}
+		}
+		if _, err := parseClock(window.Start); err != nil {
+			return fmt.Errorf("accounting: window %q: start: %w", window.Name, err)
+		}
+		if _, err := parseClock(window.End); err != nil {
+			return fmt.Errorf("accounting: window %q: end: %w", window.Name, err)
+		}
+		if window.Rate < 0 {
+			return fmt.Errorf("accounting: window %q: rate must not be negative", window.Name)
+		}
+	}
+	return nil
+}
+
+// AccountingClock matches minutes to the windows of a validated schedule
+// in its timezone
+type AccountingClock struct {
+	loc     *time.Location
+	windows []accountingSpan
+}
+
+// accountingSpan is a window with its days and times parsed
+type accountingSpan struct {
+	days       [7]bool
+	start, end int // minutes since midnight
+}
+
+// Clock returns the schedule's clock, in fallback when the schedule has no
+// timezone of its own
+func (s *AccountingSchedule) Clock(fallback *time.Location) (*AccountingClock, error) {
+	loc := fallback
+	if s.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(s.Timezone); err != nil {
+			return nil, fmt.Errorf("accounting: unknown time zone %q", s.Timezone)
+		}
+	}
+
+	clock := &AccountingClock{loc: loc, windows: make([]accountingSpan, len(s.Windows))}
+	for i, window := range s.Windows {
+		span := &clock.windows[i]
+		for _, day := range window.Days {
+			weekday, err := parseWeekday(day)
+			if err != nil {
+				return nil, err
+			}
+			span.days[weekday] = true
+		}
+		if len(window.Days) == 0 {
+			span.days = [7]bool{true, true, true, true, true, true, true}
+		}
+
+		var err error
+		if span.start, err = parseClock(window.Start); err != nil {
+			return nil, err
+		}
+		if span.end, err = parseClock(window.End); err != nil {
+			return nil, err
+		}
+	}
+	return clock, nil
+}
+
+// Window returns the index of the first window covering t, or -1 when t is
+// outside every window
+func (c *AccountingClock) Window(t time.Time) int {
+	local := t.In(c.loc)
+	weekday := local.Weekday()
+	yesterday := (weekday + 6) % 7
+	minute := local.Hour()*60 + local.Minute()
+
+	for i, span := range c.windows {
+		if span.start < span.end {
+			if span.days[weekday] && minute >= span.start && minute < span.end {
+				return i
+			}
+			continue
+		}
+		// Overnight: the evening part on a listed day, the morning part
+		// on the day after one
+		if (span.days[weekday] && minute >= span.start) || (span.days[yesterday] && minute < span.end) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseWeekday parses a weekday name such as "monday"
+func parseWeekday(name string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(name, day.String()) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", name)
+}
+
+// parseClock parses HH:MM into minutes since midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("time %q must be HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// WindowUsage is the traffic sent during one accounting window and the
+// bytes it counts for against the plan's bandwidth
+type WindowUsage struct {
+	Window       string  `json:"window"`
+	Rate         float64 `json:"rate"`
+	Requests     int64   `json:"requests"`
+	BytesIn      int64   `json:"bytes_in"`
+	BytesOut     int64   `json:"bytes_out"`
+	CountedBytes int64   `json:"counted_bytes"`
+}
@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+// Alert kinds raised by monitoring for on-call paging
+const (
+	// AlertRegionDown fires when every instance in a region is failing
+	AlertRegionDown = "region_down"
+	// AlertProviderOutage fires when every instance of a provider is failing,
+	// or every upstream host probed for it is unreachable
+	AlertProviderOutage = "provider_outage"
+	// AlertPortPoolExhausted fires when a plan type has no free local ports
+	AlertPortPoolExhausted = "port_pool_exhausted"
+)
+
+// Alert severities, as PagerDuty names them
+const (
+	AlertSeverityCritical = "critical"
+	AlertSeverityError    = "error"
+	AlertSeverityWarning  = "warning"
+	AlertSeverityInfo     = "info"
+)
+
+// Alert is a monitored condition that is currently firing. It is triggered
+// at the paging services once and resolved there when the condition clears;
+// Key is the dedup key that ties the two together.
+type Alert struct {
+	Key      string            `json:"key"`
+	Kind     string            `json:"kind"`
+	Severity string            `json:"severity"`
+	Subject  string            `json:"subject"`
+	Summary  string            `json:"summary"`
+	Details  map[string]string `json:"details,omitempty"`
+
+	// Destinations are the paging services the alert was triggered at
+	Destinations []string `json:"destinations"`
+
+	TriggeredAt time.Time `json:"triggered_at"`
+
+	// ResolvedAt is set once the condition clears, while the resolve is
+	// still being delivered
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
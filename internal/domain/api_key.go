@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyScopeRead allows a key to read its plan's usage and endpoints
+const APIKeyScopeRead = "read"
+
+// APIKey lets an end customer query one plan through the portal API without
+// the operator bearer token. Only a hash of the key is stored; the key itself
+// is returned once, when it is issued.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	PlanID     uuid.UUID  `json:"plan_id" db:"plan_id"`
+	CustomerID string     `json:"customer_id" db:"customer_id"`
+	Name       string     `json:"name,omitempty" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	Hash       string     `json:"-" db:"hash"`
+	Scope      string     `json:"scope" db:"scope"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// Active reports whether the key has not been revoked
+func (k *APIKey) Active() bool {
+	return k.RevokedAt == nil
+}
+
+// CreateAPIKeyRequest optionally labels a new API key
+type CreateAPIKeyRequest struct {
+	Name string `json:"name,omitempty"`
+}
+
+// CreateAPIKeyResponse carries the only copy of a newly issued key
+type CreateAPIKeyResponse struct {
+	APIKey *APIKey `json:"api_key"`
+	Key    string  `json:"key"`
+}
+
+// PlanUsage is what a customer sees about their plan through the portal API
+type PlanUsage struct {
+	PlanID      uuid.UUID  `json:"plan_id"`
+	PlanType    string     `json:"plan_type"`
+	Provider    string     `json:"provider"`
+	Region      string     `json:"region"`
+	Status      string     `json:"status"`
+	BandwidthGB int        `json:"bandwidth_gb"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	GraceEndsAt *time.Time `json:"grace_ends_at,omitempty"`
+	AutoRenew   bool       `json:"auto_renew"`
+
+	// Upstream usage as of the last usage sync, when known
+	UsedBytes     int64      `json:"used_bytes,omitempty"`
+	LimitBytes    int64      `json:"limit_bytes,omitempty"`
+	UsageSyncedAt *time.Time `json:"usage_synced_at,omitempty"`
+
+	// Metered is the traffic measured on the plan's instances since its
+	// creation or last renewal, when traffic is collected. Its
+	// counted_bytes are what the bandwidth is enforced against.
+	Metered *EdgeUsage `json:"metered,omitempty"`
+
+	InstancesRunning int             `json:"instances_running"`
+	Endpoints        []ProxyEndpoint `json:"endpoints"`
+
+	// SubUsers break the plan's traffic down by sub-user
+	SubUsers []SubUserResponse `json:"subusers,omitempty"`
+}
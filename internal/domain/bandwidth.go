@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LimitBytes returns the plan's bandwidth in bytes, 0 for unlimited
+func (p *ProxyPlan) LimitBytes() int64 {
+	return int64(p.Bandwidth) * 1024 * 1024 * 1024
+}
+
+// MeteringStart returns when the plan's measured traffic starts counting
+// against its bandwidth
+func (p *ProxyPlan) MeteringStart() time.Time {
+	if p.MeteredSince != nil {
+		return *p.MeteredSince
+	}
+	return p.CreatedAt
+}
+
+// EdgeUsage is a plan's traffic as counted from its 3proxy instance logs
+// since MeteringStart, against the plan's bandwidth
+type EdgeUsage struct {
+	PlanID      uuid.UUID `json:"plan_id"`
+	Status      string    `json:"status"`
+	Since       time.Time `json:"since"`
+	BandwidthGB int       `json:"bandwidth_gb"`
+	LimitBytes  int64     `json:"limit_bytes"`
+
+	Requests int64 `json:"requests"`
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+
+	// CountedBytes is the traffic counted against the bandwidth: all of it,
+	// or as weighed by the plan type's accounting schedule
+	CountedBytes int64 `json:"counted_bytes"`
+
+	// Windows break the traffic down by accounting window when the plan
+	// type has a schedule
+	Windows []WindowUsage `json:"windows,omitempty"`
+
+	// Exceeded is set once the plan has used its bandwidth
+	Exceeded bool `json:"exceeded"`
+
+	Instances []InstanceEdgeUsage `json:"instances,omitempty"`
+}
+
+// InstanceEdgeUsage is the measured traffic of one of a plan's instances
+type InstanceEdgeUsage struct {
+	InstanceID   uuid.UUID `json:"instance_id"`
+	Status       string    `json:"status"`
+	Requests     int64     `json:"requests"`
+	BytesIn      int64     `json:"bytes_in"`
+	BytesOut     int64     `json:"bytes_out"`
+	CountedBytes int64     `json:"counted_bytes"`
+}
+
+// UsedBytes returns the traffic the plan's instances proxied
+func (u *EdgeUsage) UsedBytes() int64 {
+	return u.BytesIn + u.BytesOut
+}
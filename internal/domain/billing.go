@@ -0,0 +1,168 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Billing cycles
+const (
+	// BillingCycleDays expires a plan a number of days after it starts
+	BillingCycleDays = "days"
+	// BillingCycleMonthly expires a plan on an anchor day of the month
+	BillingCycleMonthly = "monthly"
+)
+
+// BillingAnchor controls how a plan's expiry is computed. Without a time
+// zone a days cycle expires exactly days*24h later; with one, expiry is
+// rounded up to the next midnight in that zone. Monthly cycles always end at
+// midnight, in UTC unless a zone is given.
+type BillingAnchor struct {
+	// Timezone is an IANA name such as "America/New_York"
+	Timezone string `json:"timezone,omitempty"`
+
+	// Cycle is BillingCycleDays (default) or BillingCycleMonthly
+	Cycle string `json:"billing_cycle,omitempty"`
+
+	// AnchorDay is the day of the month monthly cycles end on; 0 uses the
+	// start day. Days past the end of a short month use its last day.
+	AnchorDay int `json:"billing_anchor_day,omitempty"`
+}
+
+// Validate checks the anchor's cycle, anchor day and time zone
+func (a BillingAnchor) Validate() error {
+	switch a.Cycle {
+	case "", BillingCycleDays, BillingCycleMonthly:
+	default:
+		return fmt.Errorf("billing cycle must be %q or %q", BillingCycleDays, BillingCycleMonthly)
+	}
+	if a.AnchorDay < 0 || a.AnchorDay > 31 {
+		return fmt.Errorf("billing anchor day must be between 1 and 31")
+	}
+	if _, err := a.Location(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Location returns the anchor's time zone, UTC when none is set
+func (a BillingAnchor) Location() (*time.Location, error) {
+	if a.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(a.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", a.Timezone)
+	}
+	return loc, nil
+}
+
+// Expiry returns when a plan starting at start expires: days later for a
+// days cycle, or after months calendar months for a monthly cycle. The first
+// monthly cycle ends on the next anchor day after start, so it may be short.
+func (a BillingAnchor) Expiry(start time.Time, days, months int) (time.Time, error) {
+	loc, err := a.Location()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if a.Cycle != BillingCycleMonthly {
+		if a.Timezone == "" {
+			return start.AddDate(0, 0, days), nil
+		}
+		return ceilMidnight(start.In(loc).AddDate(0, 0, days)), nil
+	}
+
+	if months < 1 {
+		months = 1
+	}
+	local := start.In(loc)
+	anchorDay := a.AnchorDay
+	if anchorDay == 0 {
+		anchorDay = local.Day()
+	}
+
+	year, month := local.Year(), local.Month()
+	end := anchorDate(year, month, anchorDay, loc)
+	if !end.After(local) {
+		month++
+	}
+	return anchorDate(year, month+time.Month(months-1), anchorDay, loc), nil
+}
+
+// GracePeriod keeps an expired plan serving, flagged grace, for Period
+// before its instances are stopped
+type GracePeriod struct {
+	Period time.Duration `yaml:"period" json:"period,omitempty"`
+
+	// Throttle caps each instance's bandwidth in bits per second while the
+	// plan is in grace; 0 leaves it unchanged
+	Throttle int64 `yaml:"throttle" json:"throttle,omitempty"`
+}
+
+// ceilMidnight rounds t up to the next midnight in its location
+func ceilMidnight(t time.Time) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if midnight.Equal(t) {
+		return t
+	}
+	return midnight.AddDate(0, 0, 1)
+}
+
+// anchorDate is midnight on day of the given month, clamped to the month's
+// last day. month may be out of range and is normalized.
+func anchorDate(year int, month time.Month, day int, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	last := first.AddDate(0, 1, -1).Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, loc)
+}
+
+// Term returns the plan's purchased days and months. Plans created before
+// the term was stored derive it from their first period.
+func (p *ProxyPlan) Term() (days, months int) {
+	days, months = p.Duration, p.Months
+	if days < 1 {
+		days = int(math.Round(p.ExpiresAt.Sub(p.CreatedAt).Hours() / 24))
+		if days < 1 {
+			days = 1
+		}
+	}
+	if p.Cycle == BillingCycleMonthly && months < 1 {
+		months = int(math.Max(1, math.Round(float64(days)/30)))
+	}
+	return days, months
+}
+
+// RenewalState records failed automatic renewals of a plan's current term
+type RenewalState struct {
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastAttemptAt time.Time  `json:"last_attempt_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+
+	// Abandoned is set once retries run out; the plan then expires normally
+	Abandoned bool `json:"abandoned,omitempty"`
+}
+
+// RenewalCharge is sent to the payment provider to bill one renewal term.
+// IdempotencyKey is the same for every attempt at the same term, so a
+// retry after a failure past the charge does not bill twice.
+type RenewalCharge struct {
+	IdempotencyKey string    `json:"idempotency_key"`
+	PlanID         string    `json:"plan_id"`
+	CustomerID     string    `json:"customer_id"`
+	PlanType       string    `json:"plan_type"`
+	PlanTypeKey    string    `json:"plan_type_key"`
+	Provider       string    `json:"provider"`
+	Region         string    `json:"region"`
+	Bandwidth      int       `json:"bandwidth"`
+	Duration       int       `json:"duration,omitempty"`
+	Months         int       `json:"months,omitempty"`
+	PeriodStart    time.Time `json:"period_start"`
+	PeriodEnd      time.Time `json:"period_end"`
+	Attempt        int       `json:"attempt"`
+}
@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// ResponseCache is a plan's response cache settings. 3proxy does not cache,
+// so new caches are refused; one stored before can only be turned off.
+type ResponseCache struct {
+	TTLSeconds int `json:"ttl_seconds"`
+	MaxSizeMB  int `json:"max_size_mb"`
+
+	// PurgedAt invalidates every entry cached before it
+	PurgedAt *time.Time `json:"purged_at,omitempty"`
+}
+
+// ResponseCacheRequest turns a plan's response cache on or off. Zero TTL and
+// size use the proxy.cache limits.
+type ResponseCacheRequest struct {
+	Enabled    bool `json:"enabled"`
+	TTLSeconds int  `json:"ttl_seconds,omitempty"`
+	MaxSizeMB  int  `json:"max_size_mb,omitempty"`
+}
@@ -0,0 +1,68 @@
+package domain
+
+// PlanFeatures are the features available to plans of one provider and plan
+// type. Providers declare the upstream features; SOCKS5 comes from the plan
+// type configuration and Renewal also needs billing.payment.
+type PlanFeatures struct {
+	// GeoTargeting means the upstream can pin exit IPs to a country
+	GeoTargeting bool `json:"geo_targeting"`
+
+	// StickySessions means the upstream can keep an exit IP across requests
+	StickySessions bool `json:"sticky_sessions"`
+
+	SOCKS5 bool `json:"socks5"`
+
+	// IPAuth means the upstream can authenticate by source IP instead of
+	// username and password
+	IPAuth bool `json:"ip_auth"`
+
+	Renewal bool `json:"renewal"`
+	TopUp   bool `json:"top_up"`
+}
+
+// RegionCapabilities is a region a plan type is sold in
+type RegionCapabilities struct {
+	Region string `json:"region"`
+	SOCKS5 bool   `json:"socks5"`
+}
+
+// PlanTypeCapabilities describes a purchasable plan type of a provider
+type PlanTypeCapabilities struct {
+	PlanType string               `json:"plan_type"`
+	Regions  []RegionCapabilities `json:"regions"`
+	Features PlanFeatures         `json:"features"`
+}
+
+// ProviderCapabilities lists the purchasable plan types of a provider
+type ProviderCapabilities struct {
+	Provider  string                 `json:"provider"`
+	PlanTypes []PlanTypeCapabilities `json:"plan_types"`
+}
+
+// CapabilityMatrix is served at GET /api/v1/capabilities so frontends can
+// offer only what can be bought
+type CapabilityMatrix struct {
+	Providers []ProviderCapabilities `json:"providers"`
+}
+
+// EndpointMapping is the customer-facing endpoint plans of a plan type are
+// given: Host and Port to connect to and the region Label they are shown with
+type EndpointMapping struct {
+	PlanTypeKey string `json:"plan_type_key"`
+	Provider    string `json:"provider"`
+	PlanType    string `json:"plan_type"`
+	Region      string `json:"region"`
+	Host        string `json:"host,omitempty"`
+	Port        int    `json:"port,omitempty"`
+	Label       string `json:"label,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+
+	// Error explains why the endpoint cannot be resolved, such as a missing region
+	Error string `json:"error,omitempty"`
+}
+
+// EndpointMap is served at GET /api/v1/config/endpoint-map so frontends and
+// support can look endpoints up instead of hardcoding the mapping rules
+type EndpointMap struct {
+	Endpoints []EndpointMapping `json:"endpoints"`
+}
@@ -0,0 +1,56 @@
+package domain
+
+import "github.com/google/uuid"
+
+// CleanupPolicy selects what a cleanup run does
+type CleanupPolicy struct {
+	// ExpirePlans marks plans past their expiry (and grace) expired and
+	// stops their running instances
+	ExpirePlans bool `json:"expire_plans"`
+
+	// RestartFailed tries to restart instances in the failed status
+	RestartFailed bool `json:"restart_failed"`
+
+	// DeleteFailedAfterDays deletes instances that have been failed for at
+	// least this many days, releasing their ports; 0 keeps them
+	DeleteFailedAfterDays int `json:"delete_failed_after_days"`
+
+	// PurgeOrphanConfigs removes 3proxy config files of instances that no
+	// longer exist
+	PurgeOrphanConfigs bool `json:"purge_orphan_configs"`
+
+	// ReleaseLeakedPorts frees allocated ports no instance uses
+	ReleaseLeakedPorts bool `json:"release_leaked_ports"`
+}
+
+// DefaultCleanupPolicy expires plans and restarts failed instances
+func DefaultCleanupPolicy() CleanupPolicy {
+	return CleanupPolicy{ExpirePlans: true, RestartFailed: true}
+}
+
+// CleanupReport lists what a cleanup run changed
+type CleanupReport struct {
+	Policy             CleanupPolicy    `json:"policy"`
+	ExpiredPlans       []uuid.UUID      `json:"expired_plans"`
+	StoppedInstances   []uuid.UUID      `json:"stopped_instances"`
+	RestartedInstances []uuid.UUID      `json:"restarted_instances"`
+	FailedRestarts     []CleanupFailure `json:"failed_restarts"`
+	DeletedInstances   []uuid.UUID      `json:"deleted_instances"`
+	FailedDeletes      []CleanupFailure `json:"failed_deletes"`
+	SkippedInstances   []uuid.UUID      `json:"skipped_instances"`
+	PurgedConfigs      []string         `json:"purged_configs"`
+	ReleasedPorts      []ReleasedPort   `json:"released_ports"`
+}
+
+// CleanupFailure is an instance a cleanup run could not restart or delete
+type CleanupFailure struct {
+	InstanceID uuid.UUID `json:"instance_id"`
+	Error      string    `json:"error"`
+}
+
+// ReleasedPort is a leaked port a cleanup run returned to its pool
+type ReleasedPort struct {
+	PlanTypeKey string `json:"plan_type_key"`
+	Port        int    `json:"port"`
+	PlanID      string `json:"plan_id"`
+}
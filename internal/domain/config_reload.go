@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// ConfigReload summarizes a configuration reload. Applied lists the changed
+// settings now in effect; RestartRequired lists settings that differ from
+// the running configuration but only take effect after a restart.
+type ConfigReload struct {
+	ReloadedAt      time.Time      `json:"reloaded_at"`
+	Trigger         string         `json:"trigger"`
+	Error           string         `json:"error,omitempty"`
+	Applied         []ConfigChange `json:"applied"`
+	RestartRequired []ConfigChange `json:"restart_required"`
+}
+
+// ConfigChange is a changed configuration key; secrets are redacted
+type ConfigChange struct {
+	Key  string      `json:"key"`
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
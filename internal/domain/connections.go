@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConnectionPolicy bounds how long a plan type's proxied connections live,
+// so hung tunnels do not pin upstream sessions. Zero leaves a bound unset.
+type ConnectionPolicy struct {
+	// IdleTimeout closes connections that pass no data for this long
+	IdleTimeout time.Duration `yaml:"idle_timeout" json:"idle_timeout,omitempty"`
+
+	// MaxLifetime closes connections this long after they were opened,
+	// whether or not they are idle. 3proxy has no lifetime limit, so it is
+	// rejected until a backend can apply it.
+	MaxLifetime time.Duration `yaml:"max_lifetime" json:"max_lifetime,omitempty"`
+}
+
+// Validate checks the idle timeout is whole seconds and not negative, and
+// that no lifetime is set
+func (p *ConnectionPolicy) Validate() error {
+	if p.MaxLifetime != 0 {
+		return fmt.Errorf("connections: max_lifetime is not supported by the 3proxy backend")
+	}
+	if p.IdleTimeout < 0 {
+		return fmt.Errorf("connections: idle_timeout must not be negative")
+	}
+	if p.IdleTimeout%time.Second != 0 {
+		return fmt.Errorf("connections: idle_timeout must be whole seconds")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Dead plan findings: signs that a plan customers believe works does not
+const (
+	// DeadPlanUpstreamFailing: the edge sees requests but most of them fail
+	// at the upstream
+	DeadPlanUpstreamFailing = "upstream_failing"
+	// DeadPlanUpstreamAuthFailed: connection tests are refused by the
+	// upstream proxy's authentication
+	DeadPlanUpstreamAuthFailed = "upstream_auth_failed"
+	// DeadPlanConnectionTestFailing: the latest connection test failed
+	DeadPlanConnectionTestFailing = "connection_test_failing"
+	// DeadPlanNoProviderUsage: the edge carried traffic the provider does
+	// not report as used, so it never reached the upstream
+	DeadPlanNoProviderUsage = "no_provider_usage"
+)
+
+// DeadPlan is an active plan the detector flagged, with the evidence
+type DeadPlan struct {
+	PlanID      uuid.UUID `json:"plan_id"`
+	CustomerID  string    `json:"customer_id"`
+	PlanTypeKey string    `json:"plan_type_key"`
+	Status      string    `json:"status"`
+	Findings    []string  `json:"findings"`
+
+	// Requests logged by the plan's instances in the window, and those that
+	// failed at the upstream
+	EdgeRequests   int64 `json:"edge_requests"`
+	FailedRequests int64 `json:"failed_requests"`
+
+	// EdgeBytes is the traffic recorded for the plan since it was created;
+	// ProviderUsedBytes is what the provider last reported as used
+	EdgeBytes         int64      `json:"edge_bytes"`
+	ProviderUsedBytes *int64     `json:"provider_used_bytes,omitempty"`
+	UsageSyncedAt     *time.Time `json:"usage_synced_at,omitempty"`
+
+	// AccountEdgeBytes is set when the provider account is shared: the
+	// edge traffic of all its plans, which its usage is compared with
+	AccountEdgeBytes int64 `json:"account_edge_bytes,omitempty"`
+
+	// Connection test failures in the window and the latest one
+	ConnectionTestFailures int        `json:"connection_test_failures"`
+	LastConnectionError    string     `json:"last_connection_error,omitempty"`
+	LastConnectionFailedAt *time.Time `json:"last_connection_failed_at,omitempty"`
+}
+
+// DeadPlanReport lists the flagged plans, most findings first
+type DeadPlanReport struct {
+	GeneratedAt time.Time     `json:"generated_at"`
+	Window      time.Duration `json:"window"`
+	Checked     int           `json:"checked"`
+	Plans       []*DeadPlan   `json:"plans"`
+}
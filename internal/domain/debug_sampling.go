@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DebugSampling records a share of a plan's requests for a limited time to
+// diagnose customer issues. Only request metadata is kept, never bodies.
+type DebugSampling struct {
+	Percent   float64   `json:"percent"`
+	StartedAt time.Time `json:"started_at"`
+	Until     time.Time `json:"until"`
+}
+
+// Active reports whether requests are sampled at now
+func (s *DebugSampling) Active(now time.Time) bool {
+	return s != nil && now.Before(s.Until)
+}
+
+// DebugSamplingRequest starts sampling Percent of a plan's requests for
+// Minutes; zero minutes use proxy.debug_sampling.max_window
+type DebugSamplingRequest struct {
+	Percent float64 `json:"percent"`
+	Minutes int     `json:"minutes,omitempty"`
+}
+
+// DebugSample is the metadata of one sampled request. Code is the 3proxy
+// result code, 0 for success; the upstream HTTP status is not logged.
+type DebugSample struct {
+	Time       time.Time `json:"time"`
+	InstanceID uuid.UUID `json:"instance_id"`
+	Method     string    `json:"method"`
+	Host       string    `json:"host"`
+	Code       int       `json:"code"`
+	DurationMs int64     `json:"duration_ms"`
+	BytesIn    int64     `json:"bytes_in"`
+	BytesOut   int64     `json:"bytes_out"`
+}
+
+// DebugSamplesResponse lists a plan's samples, oldest first
+type DebugSamplesResponse struct {
+	PlanID   uuid.UUID      `json:"plan_id"`
+	Sampling *DebugSampling `json:"sampling,omitempty"`
+	Samples  []DebugSample  `json:"samples"`
+}
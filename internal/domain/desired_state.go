@@ -0,0 +1,115 @@
+package domain
+
+// Kinds of resources reconciled from declared state
+const (
+	ResourceRegion   = "region"
+	ResourcePlanType = "plan_type"
+	ResourcePlan     = "plan"
+)
+
+// Change actions. Conflicts are differences that cannot be applied in
+// place, such as a plan's region; Unmanaged resources exist live but are
+// not declared and are left alone unless pruning.
+const (
+	ChangeCreate    = "create"
+	ChangeUpdate    = "update"
+	ChangeDelete    = "delete"
+	ChangeDisable   = "disable"
+	ChangeTopUp     = "top_up"
+	ChangeConflict  = "conflict"
+	ChangeUnmanaged = "unmanaged"
+)
+
+// PlanManifest declares a plan. Its name ties it to the plan created for
+// it, so re-applying a manifest updates that plan instead of adding one.
+type PlanManifest struct {
+	Name                string   `yaml:"name" json:"name"`
+	CustomerID          string   `yaml:"customer_id" json:"customer_id"`
+	Provider            string   `yaml:"provider" json:"provider"`
+	Region              string   `yaml:"region" json:"region"`
+	PlanType            string   `yaml:"plan_type" json:"plan_type"`
+	Bandwidth           int      `yaml:"bandwidth" json:"bandwidth"`
+	Duration            int      `yaml:"duration,omitempty" json:"duration,omitempty"`
+	Months              int      `yaml:"months,omitempty" json:"months,omitempty"`
+	AutoRenew           bool     `yaml:"auto_renew,omitempty" json:"auto_renew,omitempty"`
+	ExpiryAction        string   `yaml:"expiry_action,omitempty" json:"expiry_action,omitempty"`
+	AllowedDestinations []string `yaml:"allowed_destinations,omitempty" json:"allowed_destinations,omitempty"`
+}
+
+// Request returns the request that creates a manifest's plan
+func (m *PlanManifest) Request() *CreatePlanRequest {
+	return &CreatePlanRequest{
+		CustomerID:          m.CustomerID,
+		Provider:            m.Provider,
+		Region:              m.Region,
+		PlanType:            m.PlanType,
+		Bandwidth:           m.Bandwidth,
+		Duration:            m.Duration,
+		Months:              m.Months,
+		AutoRenew:           m.AutoRenew,
+		ExpiryAction:        m.ExpiryAction,
+		AllowedDestinations: m.AllowedDestinations,
+	}
+}
+
+// StateChange is one difference between declared and live state and what
+// was done about it
+type StateChange struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Detail string `json:"detail,omitempty"`
+
+	// Applied is false for dry runs, conflicts, unmanaged resources and
+	// failures; Error holds the failure
+	Applied bool   `json:"applied"`
+	Error   string `json:"error,omitempty"`
+}
+
+// FailedChanges returns the number of changes that could not be applied
+func FailedChanges(changes []StateChange) int {
+	failed := 0
+	for _, change := range changes {
+		if change.Error != "" {
+			failed++
+		}
+	}
+	return failed
+}
+
+// ApplyDocument is the desired state of plans sent to the apply API. Plans
+// may be listed at the top level or grouped by customer, in which case they
+// inherit the customer's ID.
+type ApplyDocument struct {
+	Customers []ApplyCustomer `yaml:"customers,omitempty" json:"customers,omitempty"`
+	Plans     []PlanManifest  `yaml:"plans,omitempty" json:"plans,omitempty"`
+}
+
+// ApplyCustomer groups the plans of one customer
+type ApplyCustomer struct {
+	ID    string         `yaml:"id" json:"id"`
+	Plans []PlanManifest `yaml:"plans" json:"plans"`
+}
+
+// Manifests returns every plan of the document, with customer IDs filled in
+// from their group
+func (d *ApplyDocument) Manifests() []PlanManifest {
+	manifests := append([]PlanManifest(nil), d.Plans...)
+	for _, customer := range d.Customers {
+		for _, manifest := range customer.Plans {
+			if manifest.CustomerID == "" {
+				manifest.CustomerID = customer.ID
+			}
+			manifests = append(manifests, manifest)
+		}
+	}
+	return manifests
+}
+
+// ApplyResult is the plan of changes computed by the apply API, applied
+// unless it was a dry run
+type ApplyResult struct {
+	DryRun  bool          `json:"dry_run"`
+	Changes []StateChange `json:"changes"`
+	Failed  int           `json:"failed"`
+}
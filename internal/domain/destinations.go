@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// MaxAllowedDestinations bounds the destination allowlist of a plan
+const MaxAllowedDestinations = 256
+
+// NormalizeDestinations validates a plan's destination allowlist and returns
+// it lowercased, deduplicated and sorted. Entries are host names, wildcard
+// domains ("*.example.com" matches subdomains at any depth but not
+// example.com itself), IP addresses and CIDR networks.
+func NormalizeDestinations(destinations []string) ([]string, error) {
+	if len(destinations) > MaxAllowedDestinations {
+		return nil, fmt.Errorf("may list at most %d destinations", MaxAllowedDestinations)
+	}
+
+	seen := make(map[string]bool)
+	var normalized []string
+	for _, raw := range destinations {
+		destination := strings.ToLower(strings.TrimSpace(raw))
+		if err := validateDestination(destination); err != nil {
+			return nil, fmt.Errorf("has an invalid destination %q (%w)", raw, err)
+		}
+		if ip := net.ParseIP(destination); ip != nil {
+			destination = ip.String()
+		} else if _, network, err := net.ParseCIDR(destination); err == nil {
+			destination = network.String()
+		}
+		if !seen[destination] {
+			seen[destination] = true
+			normalized = append(normalized, destination)
+		}
+	}
+
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+func validateDestination(destination string) error {
+	if destination == "" {
+		return fmt.Errorf("is empty")
+	}
+	if net.ParseIP(destination) != nil {
+		return nil
+	}
+	if _, _, err := net.ParseCIDR(destination); err == nil {
+		return nil
+	}
+
+	host := strings.TrimPrefix(destination, "*.")
+	if len(host) > 253 {
+		return fmt.Errorf("host name is too long")
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return fmt.Errorf("must be a fully qualified host name, IP address or CIDR network")
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("host name label %q is invalid", label)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				return fmt.Errorf("host name label %q is invalid", label)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// EgressSettings lists the public IPs a plan type's instances send traffic
+// from. Dedicated lists give each plan an IP of its own; shared lists spread
+// plans over the IPs, least used first.
+type EgressSettings struct {
+	IPs    []string `yaml:"ips" json:"ips"`
+	Shared bool     `yaml:"shared" json:"shared"`
+}
+
+// EgressIPAllocation describes an egress IP and the plans using it
+type EgressIPAllocation struct {
+	PlanTypeKey string   `json:"plan_type_key"`
+	IP          string   `json:"ip"`
+	Shared      bool     `json:"shared"`
+	PlanIDs     []string `json:"plan_ids"`
+}
+
+// EgressPool manages egress IP allocation for a specific plan type
+type EgressPool struct {
+	mu       sync.RWMutex
+	planType string
+	ips      []string
+	shared   bool
+	plans    map[string]map[string]bool // ip -> plan_ids
+}
+
+// NewEgressPool creates a new egress IP pool for a plan type
+func NewEgressPool(planType string, settings EgressSettings) *EgressPool {
+	pool := &EgressPool{
+		planType: planType,
+		ips:      settings.IPs,
+		shared:   settings.Shared,
+		plans:    make(map[string]map[string]bool, len(settings.IPs)),
+	}
+
+	for _, ip := range settings.IPs {
+		pool.plans[ip] = make(map[string]bool)
+	}
+
+	return pool
+}
+
+// AllocateIP allocates an egress IP for a plan. Dedicated pools return the
+// first free IP; shared pools return the IP used by the fewest plans.
+func (ep *EgressPool) AllocateIP(planID string) (string, error) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	chosen := ""
+	for _, ip := range ep.ips {
+		if ep.plans[ip][planID] {
+			return ip, nil
+		}
+		if !ep.shared && len(ep.plans[ip]) > 0 {
+			continue
+		}
+		if chosen == "" || len(ep.plans[ip]) < len(ep.plans[chosen]) {
+			chosen = ip
+		}
+	}
+
+	if chosen == "" {
+		return "", PortExhaustedf("no available egress IPs for plan type %s", ep.planType)
+	}
+	ep.plans[chosen][planID] = true
+
+	return chosen, nil
+}
+
+// ReleaseIP releases a plan's egress IP back to the pool
+func (ep *EgressPool) ReleaseIP(ip, planID string) error {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	plans, exists := ep.plans[ip]
+	if !exists {
+		return fmt.Errorf("egress IP %s is not in the pool of plan type %s", ip, ep.planType)
+	}
+	if !plans[planID] {
+		return fmt.Errorf("egress IP %s is not allocated to plan %s", ip, planID)
+	}
+
+	delete(plans, planID)
+
+	return nil
+}
+
+// ReserveIP marks an egress IP as allocated to a plan, e.g. when restoring
+// allocations for instances that already exist
+func (ep *EgressPool) ReserveIP(ip, planID string) error {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	plans, exists := ep.plans[ip]
+	if !exists {
+		return fmt.Errorf("egress IP %s is not in the pool of plan type %s", ip, ep.planType)
+	}
+
+	if !ep.shared && !plans[planID] {
+		for owner := range plans {
+			return Conflictf("egress IP %s is already allocated to plan %s", ip, owner)
+		}
+	}
+	plans[planID] = true
+
+	return nil
+}
+
+// Settings returns the IPs of the pool and whether they are shared
+func (ep *EgressPool) Settings() EgressSettings {
+	return EgressSettings{IPs: ep.ips, Shared: ep.shared}
+}
+
+// Allocations returns every IP of the pool with the plans using it
+func (ep *EgressPool) Allocations() []EgressIPAllocation {
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+
+	allocations := make([]EgressIPAllocation, 0, len(ep.ips))
+	for _, ip := range ep.ips {
+		planIDs := make([]string, 0, len(ep.plans[ip]))
+		for planID := range ep.plans[ip] {
+			planIDs = append(planIDs, planID)
+		}
+		sort.Strings(planIDs)
+
+		allocations = append(allocations, EgressIPAllocation{
+			PlanTypeKey: ep.planType,
+			IP:          ip,
+			Shared:      ep.shared,
+			PlanIDs:     planIDs,
+		})
+	}
+
+	return allocations
+}
@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds shared by repositories, pools and services. Failures are
+// classified under one of them so callers can test with errors.Is instead
+// of matching error strings.
+var (
+	// ErrNotFound is the kind of errors for a resource that does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is the kind of errors for a change that clashes with
+	// existing state, such as a taken username or an allocated port
+	ErrConflict = errors.New("conflict")
+	// ErrPortExhausted is the kind of errors for a port or egress IP pool
+	// with nothing left to allocate
+	ErrPortExhausted = errors.New("port pool exhausted")
+	// ErrProviderFailure is the kind of errors for an upstream provider
+	// refusing or failing a request
+	ErrProviderFailure = errors.New("provider failure")
+	// ErrInvalid is the kind of errors for a request the caller got wrong,
+	// such as an unknown provider or an unsupported plan type
+	ErrInvalid = errors.New("invalid request")
+)
+
+// kindError is an error classified under a kind. Its message is kept as
+// written; errors.Is matches both the kind and any wrapped cause.
+type kindError struct {
+	kind  error
+	msg   string
+	cause error
+}
+
+func (e *kindError) Error() string { return e.msg }
+
+func (e *kindError) Unwrap() error { return e.cause }
+
+func (e *kindError) Is(target error) bool { return target == e.kind }
+
+// kindErrorf formats an error of kind; a %w verb in format is unwrapped as usual
+func kindErrorf(kind error, format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	return &kindError{kind: kind, msg: err.Error(), cause: errors.Unwrap(err)}
+}
+
+// NotFoundf formats an ErrNotFound error
+func NotFoundf(format string, args ...interface{}) error {
+	return kindErrorf(ErrNotFound, format, args...)
+}
+
+// Conflictf formats an ErrConflict error
+func Conflictf(format string, args ...interface{}) error {
+	return kindErrorf(ErrConflict, format, args...)
+}
+
+// PortExhaustedf formats an ErrPortExhausted error
+func PortExhaustedf(format string, args ...interface{}) error {
+	return kindErrorf(ErrPortExhausted, format, args...)
+}
+
+// Invalidf formats an ErrInvalid error
+func Invalidf(format string, args ...interface{}) error {
+	return kindErrorf(ErrInvalid, format, args...)
+}
+
+// ProviderFailure classifies err, returned by an upstream provider, as an
+// ErrProviderFailure. It returns nil for a nil err.
+func ProviderFailure(err error) error {
+	if err == nil || errors.Is(err, ErrProviderFailure) {
+		return err
+	}
+	return &kindError{kind: ErrProviderFailure, msg: err.Error(), cause: err}
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Plan event types, recorded as a plan moves through its lifecycle
+const (
+	EventPlanCreated            = "plan_created"
+	EventProviderAccountCreated = "provider_account_created"
+	EventProviderAccountReused  = "provider_account_reused"
+	EventPortAllocated          = "port_allocated"
+	EventInstanceStarted        = "instance_started"
+	EventInstanceStartFailed    = "instance_start_failed"
+	EventInstanceStopped        = "instance_stopped"
+	EventInstanceRestarted      = "instance_restarted"
+	EventInstanceDeleted        = "instance_deleted"
+	EventHealthCheckFailed      = "health_check_failed"
+	EventInstanceUnhealthy      = "instance_unhealthy"
+	EventInstanceRecovered      = "instance_recovered"
+	EventInstanceOverridesSet   = "instance_overrides_set"
+	EventPlanStatusChanged      = "plan_status_changed"
+	EventPlanExpired            = "plan_expired"
+	EventPlanDeleted            = "plan_deleted"
+	EventGeoVerified            = "geo_verified"
+	EventGeoMismatch            = "geo_mismatch"
+	EventGeoRemediated          = "geo_remediated"
+	EventPlanExhausted          = "plan_exhausted"
+	EventPlanToppedUp           = "plan_topped_up"
+	EventPlanSuspended          = "plan_suspended"
+	EventPlanGraceStarted       = "plan_grace_started"
+	EventPlanDowngraded         = "plan_downgraded"
+	EventPlanRenewed            = "plan_renewed"
+	EventPlanRenewalFailed      = "plan_renewal_failed"
+	EventAPIKeyIssued           = "api_key_issued"
+	EventAPIKeyRevoked          = "api_key_revoked"
+	EventPlanPasswordRotated    = "plan_password_rotated"
+	EventPlanDestinationsSet    = "plan_destinations_set"
+	EventPlanHeadersSet         = "plan_headers_set"
+	EventPlanCacheSet           = "plan_cache_set"
+	EventDebugSamplingStarted   = "debug_sampling_started"
+	EventDebugSamplingStopped   = "debug_sampling_stopped"
+	EventPlanActivated          = "plan_activated"
+	EventPlanActivationFailed   = "plan_activation_failed"
+	EventTempCredentialIssued   = "temp_credential_issued"
+	EventTempCredentialRevoked  = "temp_credential_revoked"
+	EventTempCredentialExpired  = "temp_credential_expired"
+	EventSubUserCreated         = "subuser_created"
+	EventSubUserUpdated         = "subuser_updated"
+	EventSubUserDeleted         = "subuser_deleted"
+	EventSubUserExhausted       = "subuser_exhausted"
+	EventVoucherRedeemed        = "voucher_redeemed"
+)
+
+// PlanEvent is an entry in a plan's append-only history
+type PlanEvent struct {
+	ID         uuid.UUID         `json:"id"`
+	PlanID     uuid.UUID         `json:"plan_id"`
+	InstanceID *uuid.UUID        `json:"instance_id,omitempty"`
+	Type       string            `json:"type"`
+	Message    string            `json:"message,omitempty"`
+	Data       map[string]string `json:"data,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
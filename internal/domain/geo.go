@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Geo check remediation modes
+const (
+	GeoRemediationAlert         = "alert"          // flag the plan and log a warning
+	GeoRemediationResetUpstream = "reset_upstream" // also repoint the instance at the plan type's upstream host
+)
+
+// GeoCheck records the result of verifying a plan's exit IP location
+type GeoCheck struct {
+	ExitIP    string    `json:"exit_ip,omitempty"`
+	Country   string    `json:"country,omitempty"`
+	Expected  []string  `json:"expected,omitempty"`
+	Mismatch  bool      `json:"mismatch"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// ExpectsCountry reports whether an exit IP in country satisfies the region.
+// Regions without configured countries accept any location.
+func (r *Region) ExpectsCountry(country string) bool {
+	if len(r.Countries) == 0 {
+		return true
+	}
+	for _, c := range r.Countries {
+		if strings.EqualFold(c, country) {
+			return true
+		}
+	}
+	return false
+}
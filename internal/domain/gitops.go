@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GitOpsReport is the outcome of a sync
+type GitOpsReport struct {
+	Repo       string        `json:"repo"`
+	Branch     string        `json:"branch"`
+	Commit     string        `json:"commit,omitempty"`
+	DryRun     bool          `json:"dry_run"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Changes    []StateChange `json:"changes"`
+
+	// Error is set when the repository could not be pulled or parsed, in
+	// which case nothing was compared
+	Error string `json:"error,omitempty"`
+}
+
+// Failed returns the number of changes that could not be applied
+func (r *GitOpsReport) Failed() int {
+	return FailedChanges(r.Changes)
+}
+
+// GitOpsState is what the GitOps controller keeps between syncs
+type GitOpsState struct {
+	// Plans maps plan manifest names to the plans created for them
+	Plans map[string]uuid.UUID `json:"plans"`
+
+	LastReport *GitOpsReport `json:"last_report,omitempty"`
+}
@@ -0,0 +1,127 @@
+package domain
+
+import (
+	"fmt"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// MaxHeaderPolicyEntries bounds the headers a plan's header policy strips
+// and injects together
+const MaxHeaderPolicyEntries = 64
+
+// maxHeaderValueLength bounds an injected header value
+const maxHeaderValueLength = 1024
+
+// reservedHeaders carry connection framing or proxy authentication and can
+// be neither stripped nor injected
+var reservedHeaders = map[string]bool{
+	"Connection":          true,
+	"Content-Length":      true,
+	"Host":                true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+// HeaderPolicy strips and injects headers on a plan's proxied HTTP requests.
+// Stripped headers are removed before injected ones are set, so a header
+// listed in both is replaced.
+type HeaderPolicy struct {
+	Strip  []string          `json:"strip,omitempty"`
+	Inject map[string]string `json:"inject,omitempty"`
+}
+
+// Empty reports whether the policy leaves requests unchanged
+func (p *HeaderPolicy) Empty() bool {
+	return p == nil || len(p.Strip) == 0 && len(p.Inject) == 0
+}
+
+// NormalizeHeaderPolicy validates a plan's header policy and returns it with
+// canonical header names, Strip deduplicated and sorted. An empty policy
+// normalizes to nil.
+func NormalizeHeaderPolicy(policy *HeaderPolicy) (*HeaderPolicy, error) {
+	if policy.Empty() {
+		return nil, nil
+	}
+	if len(policy.Strip)+len(policy.Inject) > MaxHeaderPolicyEntries {
+		return nil, fmt.Errorf("may list at most %d headers", MaxHeaderPolicyEntries)
+	}
+
+	normalized := &HeaderPolicy{}
+	seen := make(map[string]bool)
+	for _, raw := range policy.Strip {
+		name, err := canonicalHeaderName(raw)
+		if err != nil {
+			return nil, fmt.Errorf("strip has an invalid header %q (%w)", raw, err)
+		}
+		if !seen[name] {
+			seen[name] = true
+			normalized.Strip = append(normalized.Strip, name)
+		}
+	}
+	sort.Strings(normalized.Strip)
+
+	for raw, value := range policy.Inject {
+		name, err := canonicalHeaderName(raw)
+		if err != nil {
+			return nil, fmt.Errorf("inject has an invalid header %q (%w)", raw, err)
+		}
+		if _, ok := normalized.Inject[name]; ok {
+			return nil, fmt.Errorf("inject lists header %q more than once", name)
+		}
+		if err := validateHeaderValue(value); err != nil {
+			return nil, fmt.Errorf("inject has an invalid value for %q (%w)", name, err)
+		}
+		if normalized.Inject == nil {
+			normalized.Inject = make(map[string]string)
+		}
+		normalized.Inject[name] = value
+	}
+
+	return normalized, nil
+}
+
+func canonicalHeaderName(raw string) (string, error) {
+	name := strings.TrimSpace(raw)
+	if name == "" {
+		return "", fmt.Errorf("is empty")
+	}
+	for _, r := range name {
+		if !isHeaderTokenChar(r) {
+			return "", fmt.Errorf("is not a valid header name")
+		}
+	}
+	name = textproto.CanonicalMIMEHeaderKey(name)
+	if reservedHeaders[name] {
+		return "", fmt.Errorf("is managed by the proxy")
+	}
+	return name, nil
+}
+
+func validateHeaderValue(value string) error {
+	if len(value) > maxHeaderValueLength {
+		return fmt.Errorf("is longer than %d bytes", maxHeaderValueLength)
+	}
+	for _, r := range value {
+		if r == '\r' || r == '\n' || r == 0 || r == 0x7f || r < ' ' && r != '\t' {
+			return fmt.Errorf("contains control characters")
+		}
+	}
+	return nil
+}
+
+// isHeaderTokenChar reports whether r may appear in a header name (RFC 9110 token)
+func isHeaderTokenChar(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+}
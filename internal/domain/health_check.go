@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HealthCheckSettings configures how instances of a plan type are health
+// checked. Zero fields fall back to the global proxy settings.
+type HealthCheckSettings struct {
+	// TestURL is fetched through the instance; empty uses proxy.connection_test_url
+	TestURL string `yaml:"test_url" json:"test_url,omitempty"`
+
+	Timeout  time.Duration `yaml:"timeout" json:"timeout,omitempty"`
+	Interval time.Duration `yaml:"interval" json:"interval,omitempty"`
+
+	// Success criteria. Without ExpectedStatus any status below 400 passes.
+	ExpectedStatus []int         `yaml:"expected_status" json:"expected_status,omitempty"`
+	BodyContains   string        `yaml:"body_contains" json:"body_contains,omitempty"`
+	MaxLatency     time.Duration `yaml:"max_latency" json:"max_latency,omitempty"`
+
+	// Flap damping: consecutive failures before an instance is marked
+	// unhealthy, and consecutive successes before it is healthy again
+	FailureThreshold int `yaml:"failure_threshold" json:"failure_threshold,omitempty"`
+	SuccessThreshold int `yaml:"success_threshold" json:"success_threshold,omitempty"`
+}
+
+// Merge returns s with zero fields taken from defaults; s may be nil
+func (s *HealthCheckSettings) Merge(defaults HealthCheckSettings) HealthCheckSettings {
+	if s == nil {
+		return defaults
+	}
+
+	merged := *s
+	if merged.TestURL == "" {
+		merged.TestURL = defaults.TestURL
+	}
+	if merged.Timeout <= 0 {
+		merged.Timeout = defaults.Timeout
+	}
+	if merged.Interval <= 0 {
+		merged.Interval = defaults.Interval
+	}
+	if len(merged.ExpectedStatus) == 0 {
+		merged.ExpectedStatus = defaults.ExpectedStatus
+	}
+	if merged.BodyContains == "" {
+		merged.BodyContains = defaults.BodyContains
+	}
+	if merged.MaxLatency <= 0 {
+		merged.MaxLatency = defaults.MaxLatency
+	}
+	if merged.FailureThreshold <= 0 {
+		merged.FailureThreshold = defaults.FailureThreshold
+	}
+	if merged.SuccessThreshold <= 0 {
+		merged.SuccessThreshold = defaults.SuccessThreshold
+	}
+	return merged
+}
+
+// Evaluate checks a test response against the success criteria
+func (s *HealthCheckSettings) Evaluate(status int, body string, latency time.Duration) error {
+	if len(s.ExpectedStatus) > 0 {
+		expected := false
+		for _, code := range s.ExpectedStatus {
+			if code == status {
+				expected = true
+				break
+			}
+		}
+		if !expected {
+			return fmt.Errorf("connection test returned status %d, expected %v", status, s.ExpectedStatus)
+		}
+	} else if status >= 400 {
+		return fmt.Errorf("connection test returned status %d", status)
+	}
+
+	if s.BodyContains != "" && !strings.Contains(body, s.BodyContains) {
+		return fmt.Errorf("connection test response does not contain %q", s.BodyContains)
+	}
+
+	if s.MaxLatency > 0 && latency > s.MaxLatency {
+		return fmt.Errorf("connection test took %s, limit is %s", latency.Round(time.Millisecond), s.MaxLatency)
+	}
+
+	return nil
+}
+
+// HealthCheckResult is the outcome of one instance's health check
+type HealthCheckResult struct {
+	InstanceID uuid.UUID     `json:"instance_id"`
+	Healthy    bool          `json:"healthy"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// HealthCheckReport aggregates the results of checking many instances
+type HealthCheckReport struct {
+	Total    int                 `json:"total"`
+	Passed   int                 `json:"passed"`
+	Failed   int                 `json:"failed"`
+	Duration time.Duration       `json:"duration"`
+	Results  []HealthCheckResult `json:"results"`
+}
+
+// HealthCheckRequest selects instances to health check; empty checks every
+// running instance
+type HealthCheckRequest struct {
+	InstanceIDs []uuid.UUID `json:"instance_ids,omitempty"`
+}
@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Incident states
+const (
+	IncidentOpen         = "open"
+	IncidentAcknowledged = "acknowledged"
+	IncidentResolved     = "resolved"
+)
+
+// Incident is a service disruption in a region, opened by monitoring when
+// instances breach their health check failure threshold. Instances that
+// fail while it is unresolved join it; it resolves itself once every
+// affected instance has recovered, or when an operator resolves it.
+type Incident struct {
+	ID     uuid.UUID `json:"id"`
+	Title  string    `json:"title"`
+	Status string    `json:"status"`
+	Region string    `json:"region"`
+
+	// PlanIDs and InstanceIDs are everything the incident affected;
+	// Unhealthy are the instances still failing
+	PlanIDs     []uuid.UUID `json:"plan_ids"`
+	InstanceIDs []uuid.UUID `json:"instance_ids"`
+	Unhealthy   []uuid.UUID `json:"unhealthy"`
+
+	OpenedAt       time.Time  `json:"opened_at"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	Timeline []IncidentUpdate `json:"timeline"`
+}
+
+// IncidentUpdate is an entry in an incident's timeline. Author is empty for
+// entries written by monitoring. Only public entries appear on the status
+// page.
+type IncidentUpdate struct {
+	At         time.Time  `json:"at"`
+	Status     string     `json:"status"`
+	Author     string     `json:"author,omitempty"`
+	Message    string     `json:"message"`
+	PlanID     *uuid.UUID `json:"plan_id,omitempty"`
+	InstanceID *uuid.UUID `json:"instance_id,omitempty"`
+	Public     bool       `json:"public"`
+}
+
+// IncidentNoteRequest acknowledges, resolves or annotates an incident
+type IncidentNoteRequest struct {
+	Author  string `json:"author"`
+	Message string `json:"message"`
+	Public  bool   `json:"public,omitempty"`
+}
+
+// PublicIncident is an incident as shown on the status page, without the
+// affected plans and instances or internal timeline entries
+type PublicIncident struct {
+	Title      string           `json:"title"`
+	Status     string           `json:"status"`
+	Region     string           `json:"region"`
+	OpenedAt   time.Time        `json:"opened_at"`
+	ResolvedAt *time.Time       `json:"resolved_at,omitempty"`
+	Updates    []IncidentUpdate `json:"updates"`
+}
+
+// Public returns the incident as shown on the status page
+func (i *Incident) Public() PublicIncident {
+	public := PublicIncident{
+		Title:      i.Title,
+		Status:     i.Status,
+		Region:     i.Region,
+		OpenedAt:   i.OpenedAt,
+		ResolvedAt: i.ResolvedAt,
+		Updates:    []IncidentUpdate{},
+	}
+	for _, update := range i.Timeline {
+		if update.Public {
+			public.Updates = append(public.Updates, IncidentUpdate{
+				At:      update.At,
+				Status:  update.Status,
+				Message: update.Message,
+				Public:  true,
+			})
+		}
+	}
+	return public
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProxyInstance represents a single proxy instance
+type ProxyInstance struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	PlanID      uuid.UUID `json:"plan_id" db:"plan_id"`
+	PlanTypeKey string    `json:"plan_type_key" db:"plan_type_key"`
+	LocalPort   int       `json:"local_port" db:"local_port"`
+	LocalHost   string    `json:"local_host,omitempty" db:"local_host"`
+	EgressIP    string    `json:"egress_ip,omitempty" db:"egress_ip"`
+	AuthHost    string    `json:"auth_host" db:"auth_host"`
+	AuthPort    int       `json:"auth_port" db:"auth_port"`
+	Status      string    `json:"status" db:"status"`
+	ProcessID   int       `json:"process_id,omitempty" db:"process_id"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+
+	// Diagnostics from the most recent failed start attempt
+	LastStartError   string     `json:"last_start_error,omitempty" db:"last_start_error"`
+	LastStartErrorAt *time.Time `json:"last_start_error_at,omitempty" db:"last_start_error_at"`
+
+	// Cgroup limit violations observed for the running process
+	ResourceViolations *ResourceViolations `json:"resource_violations,omitempty" db:"resource_violations"`
+
+	// Maintenance takes the instance out of its nginx upstream, health
+	// checks, alerting and automated remediation while operators work on it
+	Maintenance bool `json:"maintenance,omitempty" db:"maintenance"`
+
+	// NoAutoheal keeps the instance serving and health checked, but nothing
+	// automated restarts, deletes or ejects it
+	NoAutoheal bool `json:"no_autoheal,omitempty" db:"no_autoheal"`
+
+	// OverrideNote says why the overrides above are set
+	OverrideNote string `json:"override_note,omitempty" db:"override_note"`
+}
+
+// DefaultLocalHost is the address nginx and health checks reach instances
+// on when they are not bound to a specific local IP
+const DefaultLocalHost = "127.0.0.1"
+
+// LocalAddress returns the host:port the instance listens on locally
+func (i *ProxyInstance) LocalAddress() string {
+	host := i.LocalHost
+	if host == "" {
+		host = DefaultLocalHost
+	}
+	return net.JoinHostPort(host, strconv.Itoa(i.LocalPort))
+}
+
+// Autoheals reports whether automation may restart, delete or reroute the
+// instance
+func (i *ProxyInstance) Autoheals() bool {
+	return !i.Maintenance && !i.NoAutoheal
+}
+
+// InRotation reports whether nginx should send the instance traffic.
+// Instances in maintenance never get any; failed instances exempt from
+// autoheal keep theirs, as operators set the flag when health checks
+// misjudge an instance.
+func (i *ProxyInstance) InRotation() bool {
+	if i.Maintenance {
+		return false
+	}
+	return i.Status == InstanceStatusRunning || (i.NoAutoheal && i.Status == InstanceStatusFailed)
+}
+
+// InstanceOverridesRequest sets an instance's operator overrides
+type InstanceOverridesRequest struct {
+	Maintenance bool   `json:"maintenance"`
+	NoAutoheal  bool   `json:"no_autoheal"`
+	Note        string `json:"note,omitempty"`
+}
+
+// ResourceViolations summarizes cgroup limit events for an instance
+type ResourceViolations struct {
+	MemoryMaxEvents int64     `json:"memory_max_events"`
+	OOMKills        int64     `json:"oom_kills"`
+	CPUThrottled    int64     `json:"cpu_throttled"`
+	CheckedAt       time.Time `json:"checked_at"`
+}
+
+// Any reports whether any limit has been hit
+func (v *ResourceViolations) Any() bool {
+	return v.MemoryMaxEvents > 0 || v.OOMKills > 0 || v.CPUThrottled > 0
+}
+
+// Instance status constants
+const (
+	InstanceStatusRunning  = "running"
+	InstanceStatusStopped  = "stopped"
+	InstanceStatusFailed   = "failed"
+	InstanceStatusStarting = "starting"
+)
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaintenanceSourceManual marks windows registered by an operator; windows
+// ingested from a feed carry the feed's name as their source
+const MaintenanceSourceManual = "manual"
+
+// MaintenanceWindow is scheduled upstream provider maintenance. While it is
+// in progress, alerts for the plan types it covers are suppressed and their
+// health check failures are annotated with it.
+type MaintenanceWindow struct {
+	ID       uuid.UUID `json:"id"`
+	Provider string    `json:"provider"`
+
+	// PlanTypes limits the window to these plan types; empty covers every
+	// plan type of the provider
+	PlanTypes []string `json:"plan_types,omitempty"`
+
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	Summary  string    `json:"summary"`
+	URL      string    `json:"url,omitempty"`
+
+	// Source is "manual" or the feed the window was read from, and
+	// ExternalID the feed's ID for it
+	Source     string `json:"source"`
+	ExternalID string `json:"external_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// InProgress reports whether the window covers at
+func (w *MaintenanceWindow) InProgress(at time.Time) bool {
+	return !at.Before(w.StartsAt) && at.Before(w.EndsAt)
+}
+
+// Covers reports whether the window applies to a plan type of provider
+func (w *MaintenanceWindow) Covers(provider, planTypeKey string) bool {
+	if w.Provider != provider {
+		return false
+	}
+	if len(w.PlanTypes) == 0 {
+		return true
+	}
+	for _, key := range w.PlanTypes {
+		if key == planTypeKey {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateMaintenanceRequest registers a maintenance window by hand
+type CreateMaintenanceRequest struct {
+	Provider  string    `json:"provider" validate:"required"`
+	PlanTypes []string  `json:"plan_types,omitempty"`
+	StartsAt  time.Time `json:"starts_at" validate:"required"`
+	EndsAt    time.Time `json:"ends_at" validate:"required"`
+	Summary   string    `json:"summary" validate:"required"`
+	URL       string    `json:"url,omitempty"`
+}
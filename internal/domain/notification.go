@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+// Notification types sent to operators and customers
+const (
+	NotificationPlanExhausted  = "plan.exhausted"
+	NotificationPlanSuspended  = "plan.suspended"
+	NotificationPlanGrace      = "plan.grace"
+	NotificationPlanDowngraded = "plan.downgraded"
+
+	// Automatic renewal outcomes
+	NotificationPlanRenewed          = "plan.renewed"
+	NotificationPlanRenewalFailed    = "plan.renewal_failed"
+	NotificationPlanRenewalAbandoned = "plan.renewal_abandoned"
+
+	// NotificationPlanActivationFailed tells operators a new plan could not
+	// be brought up and was marked failed
+	NotificationPlanActivationFailed = "plan.activation_failed"
+
+	// NotificationSLAViolated tells operators a region's or plan's uptime
+	// this month is below its SLA target
+	NotificationSLAViolated = "sla.violated"
+
+	// Operator alerts
+	NotificationPlanCreationFailed = "plan.creation_failed"
+	NotificationInstanceCrashLoop  = "instance.crash_loop"
+	NotificationProviderBalanceLow = "provider.balance_low"
+)
+
+// Notification is a customer- or operator-facing message about a plan
+type Notification struct {
+	Type       string            `json:"type"`
+	PlanID     string            `json:"plan_id,omitempty"`
+	CustomerID string            `json:"customer_id,omitempty"`
+	Message    string            `json:"message"`
+	Data       map[string]string `json:"data,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
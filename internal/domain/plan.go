@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProxyPlan represents a customer's proxy plan
+type ProxyPlan struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	CustomerID  string    `json:"customer_id" db:"customer_id"`
+	PlanType    string    `json:"plan_type" db:"plan_type"`
+	Provider    string    `json:"provider" db:"provider"`
+	Region      string    `json:"region" db:"region"`
+	PlanTypeKey string    `json:"plan_type_key" db:"plan_type_key"`
+	Username    string    `json:"username" db:"username"`
+	Password    string    `json:"password" db:"password"`
+	Status      string    `json:"status" db:"status"`
+	Bandwidth   int       `json:"bandwidth" db:"bandwidth"`
+	ExpiresAt   time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+
+	// ProviderAccountID links the upstream account serving this plan
+	ProviderAccountID *uuid.UUID `json:"provider_account_id,omitempty" db:"provider_account_id"`
+
+	// GeoCheck is the latest exit IP geolocation result
+	GeoCheck *GeoCheck `json:"geo_check,omitempty" db:"-"`
+
+	// BillingAnchor records how ExpiresAt was computed
+	BillingAnchor
+
+	// MeteredSince is when traffic measured at the edge starts counting
+	// against Bandwidth; it is reset on renewal. Nil counts from CreatedAt.
+	MeteredSince *time.Time `json:"metered_since,omitempty" db:"metered_since"`
+
+	// GraceEndsAt is when a plan in grace stops being served
+	GraceEndsAt *time.Time `json:"grace_ends_at,omitempty" db:"grace_ends_at"`
+
+	// Duration and Months are the purchased term, reused on renewal
+	Duration int `json:"duration,omitempty" db:"duration"`
+	Months   int `json:"months,omitempty" db:"months"`
+
+	// AutoRenew charges the customer and extends the plan when it expires;
+	// Renewal tracks failed attempts for the current term
+	AutoRenew bool          `json:"auto_renew" db:"auto_renew"`
+	Renewal   *RenewalState `json:"renewal,omitempty" db:"renewal"`
+
+	// ExpiryAction is what the expiry worker does with the plan once it
+	// expires; empty stops it
+	ExpiryAction string `json:"expiry_action,omitempty" db:"expiry_action"`
+
+	// AllowedDestinations restricts the plan's proxies to these targets;
+	// any other destination is denied. Empty allows every destination.
+	AllowedDestinations []string `json:"allowed_destinations,omitempty" db:"allowed_destinations"`
+
+	// HeaderPolicy strips and injects headers on proxied HTTP requests; it
+	// is not applied by the 3proxy backend
+	HeaderPolicy *HeaderPolicy `json:"header_policy,omitempty" db:"header_policy"`
+
+	// ResponseCache is set while response caching is enabled
+	ResponseCache *ResponseCache `json:"response_cache,omitempty" db:"response_cache"`
+
+	// DebugSampling is set while, and after, the plan's requests are sampled
+	DebugSampling *DebugSampling `json:"debug_sampling,omitempty" db:"debug_sampling"`
+
+	// TempCredentials are extra short-lived users of the plan's instances
+	TempCredentials []TempCredential `json:"temp_credentials,omitempty" db:"temp_credentials"`
+
+	// SubUsers share the plan, each with its own credentials and usage
+	SubUsers []SubUser `json:"subusers,omitempty" db:"subusers"`
+
+	// Voucher is set when the plan was created with a voucher
+	Voucher *PlanVoucher `json:"voucher,omitempty" db:"voucher"`
+
+	// Activation tracks failed attempts to bring the plan's instances up;
+	// it is set while the plan is still creating and retries are pending
+	Activation *ActivationState `json:"activation,omitempty" db:"activation"`
+
+	// Instances are not stored with the plan; they live in the instance
+	// repository and are only attached for callers that load them
+	Instances []*ProxyInstance `json:"instances,omitempty" db:"-"`
+}
+
+// Plan status constants
+const (
+	PlanStatusActive   = "active"
+	PlanStatusExpired  = "expired"
+	PlanStatusCreating = "creating"
+	PlanStatusFailed   = "failed"
+
+	// PlanStatusSuspended marks a plan whose traffic measured at the edge
+	// went over its bandwidth; it is served nothing until topped up
+	PlanStatusSuspended = "suspended"
+
+	// PlanStatusExhausted marks a plan whose upstream bandwidth is used up
+	PlanStatusExhausted = "exhausted"
+
+	// PlanStatusGrace marks an expired plan still served until GraceEndsAt
+	PlanStatusGrace = "grace"
+
+	// PlanStatusTeaser marks an expired plan downgraded to a throttled
+	// teaser instead of being stopped
+	PlanStatusTeaser = "teaser"
+)
+
+// Expiry actions, chosen per plan, run once a plan expires and any grace
+// period and renewal retries are over
+const (
+	// ExpiryActionStop stops the plan's instances and keeps the plan and its
+	// upstream account; it is the default
+	ExpiryActionStop = "stop"
+	// ExpiryActionDelete deletes the plan and its instances, and its upstream
+	// account unless other plans still share it
+	ExpiryActionDelete = "delete"
+	// ExpiryActionDowngrade keeps the plan serving as a throttled teaser
+	ExpiryActionDowngrade = "downgrade"
+)
+
+// IsExpiryAction reports whether action is a known expiry action; empty
+// counts as stop
+func IsExpiryAction(action string) bool {
+	switch action {
+	case "", ExpiryActionStop, ExpiryActionDelete, ExpiryActionDowngrade:
+		return true
+	}
+	return false
+}
+
+// ActivationState records failed attempts to start a new plan's instances
+// and wire them into nginx. Attempts are retried with exponential backoff;
+// once they run out the plan is marked failed.
+type ActivationState struct {
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastAttemptAt time.Time  `json:"last_attempt_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
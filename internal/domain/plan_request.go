@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// API request and response types for plans. They are decoded from and
+// encoded to clients only; what is stored is ProxyPlan and ProxyInstance.
+
+// ProxyEndpoint represents a customer-facing proxy endpoint
+type ProxyEndpoint struct {
+	URL      string `json:"url"`
+	Region   string `json:"region"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// CreatePlanRequest represents a request to create a new proxy plan
+type CreatePlanRequest struct {
+	CustomerID string `json:"customer_id,omitempty" validate:"omitempty"`
+	PlanType   string `json:"plan_type" validate:"required,oneof=residential datacenter isp mobile unlimited"`
+	Provider   string `json:"provider" validate:"required,oneof=proxies_fo nettify"`
+	Region     string `json:"region" validate:"required,oneof=usa eu alpha beta asia"`
+	// Username and Password are no longer accepted from API; kept for backwards-compat but ignored
+	Username  string `json:"username,omitempty" validate:"omitempty"`
+	Password  string `json:"password,omitempty" validate:"omitempty"`
+	Bandwidth int    `json:"bandwidth" validate:"min=1,max=1000"`         // GB
+	Duration  int    `json:"duration,omitempty" validate:"min=1,max=365"` // days
+	Threads   int    `json:"threads,omitempty" validate:"omitempty,min=1"`
+
+	// BillingAnchor selects the time zone and cycle used to compute expiry;
+	// Months is the number of calendar months of a monthly cycle (default 1)
+	BillingAnchor
+	Months int `json:"months,omitempty" validate:"omitempty,min=1,max=12"`
+
+	// AutoRenew renews the plan through billing.payment_url when it expires
+	AutoRenew bool `json:"auto_renew,omitempty"`
+
+	// ExpiryAction is stop (default), delete or downgrade; see ProxyPlan
+	ExpiryAction string `json:"expiry_action,omitempty"`
+
+	// AllowedDestinations restricts the plan to these target hosts, wildcard
+	// domains, IPs and CIDR networks
+	AllowedDestinations []string `json:"allowed_destinations,omitempty"`
+
+	// HeaderPolicy strips and injects headers on proxied HTTP requests
+	HeaderPolicy *HeaderPolicy `json:"header_policy,omitempty"`
+
+	// Voucher redeems a voucher code: a discount off the plan's price, or a
+	// trial whose bandwidth and duration replace the requested ones
+	Voucher string `json:"voucher,omitempty"`
+
+	// ForceNewAccount skips provider account reuse so the plan gets fresh credentials
+	ForceNewAccount bool `json:"-"`
+}
+
+// TopUpPlanRequest adds bandwidth to a plan's provider account
+type TopUpPlanRequest struct {
+	Bandwidth int `json:"bandwidth" validate:"required,min=1"` // GB
+}
+
+// AutoRenewRequest turns automatic renewal of a plan on or off
+type AutoRenewRequest struct {
+	AutoRenew bool `json:"auto_renew"`
+}
+
+// ExpiryActionRequest sets what happens to a plan once it expires
+type ExpiryActionRequest struct {
+	ExpiryAction string `json:"expiry_action"`
+}
+
+// RotatePasswordRequest optionally sets the new password of a plan; it is
+// generated when empty
+type RotatePasswordRequest struct {
+	Password string `json:"password,omitempty"`
+}
+
+// AllowedDestinationsRequest replaces a plan's destination allowlist; an
+// empty list lifts the restriction
+type AllowedDestinationsRequest struct {
+	AllowedDestinations []string `json:"allowed_destinations"`
+}
+
+// ClonePlanRequest optionally overrides the customer of a cloned plan
+type ClonePlanRequest struct {
+	CustomerID string `json:"customer_id,omitempty"`
+}
+
+// CreatePlanResponse represents the response after creating a plan
+type CreatePlanResponse struct {
+	Success   bool            `json:"success"`
+	PlanID    uuid.UUID       `json:"plan_id"`
+	Username  string          `json:"username"`
+	Password  string          `json:"password"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	Proxies   []ProxyEndpoint `json:"proxies"`
+
+	// Status is creating when the plan's instances failed to come up and
+	// activation is being retried
+	Status string `json:"status"`
+
+	// Formatted holds Proxies rendered in the requested ?format=, if any
+	Formatted []string `json:"formatted,omitempty"`
+
+	// Voucher is the voucher redeemed for the plan, if any
+	Voucher *PlanVoucher `json:"voucher,omitempty"`
+}
@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PlanPolicy defines request defaults and limits for a plan type. Zero
+// limits are unbounded; zero defaults leave the request value untouched.
+type PlanPolicy struct {
+	DefaultBandwidth int `yaml:"default_bandwidth" json:"default_bandwidth,omitempty"` // GB
+	MinBandwidth     int `yaml:"min_bandwidth" json:"min_bandwidth,omitempty"`
+	MaxBandwidth     int `yaml:"max_bandwidth" json:"max_bandwidth,omitempty"`
+
+	DefaultDuration  int   `yaml:"default_duration" json:"default_duration,omitempty"` // days
+	MinDuration      int   `yaml:"min_duration" json:"min_duration,omitempty"`
+	MaxDuration      int   `yaml:"max_duration" json:"max_duration,omitempty"`
+	AllowedDurations []int `yaml:"allowed_durations" json:"allowed_durations,omitempty"`
+
+	DefaultThreads int `yaml:"default_threads" json:"default_threads,omitempty"`
+	MaxThreads     int `yaml:"max_threads" json:"max_threads,omitempty"`
+
+	// Clamp brings out-of-range bandwidth, duration and threads into range
+	// instead of rejecting the request
+	Clamp bool `yaml:"clamp" json:"clamp,omitempty"`
+}
+
+// PolicyError reports a request value rejected by a plan policy
+type PolicyError struct {
+	PlanType string
+	Field    string
+	Reason   string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("%s %s for plan type %s", e.Field, e.Reason, e.PlanType)
+}
+
+// IsPolicyError reports whether err was caused by a plan policy violation
+func IsPolicyError(err error) bool {
+	var policyErr *PolicyError
+	return errors.As(err, &policyErr)
+}
+
+// DefaultPlanPolicy returns the built-in policy used when a plan type does not
+// configure one, matching the providers' historical defaults
+func DefaultPlanPolicy(provider, planType string) *PlanPolicy {
+	policy := &PlanPolicy{
+		DefaultBandwidth: 1,
+		MinBandwidth:     1,
+		MaxBandwidth:     1000,
+		DefaultDuration:  30,
+		MinDuration:      1,
+		MaxDuration:      365,
+	}
+
+	switch {
+	case provider == ProviderProxiesFo && planType == "datacenter":
+		policy.DefaultDuration = 1
+		policy.DefaultThreads = 500
+	case provider == ProviderProxiesFo:
+		policy.DefaultDuration = 180
+	}
+
+	return policy
+}
+
+// Apply fills defaults into req and validates it against the policy
+func (p *PlanPolicy) Apply(planTypeKey string, req *CreatePlanRequest) error {
+	if req.Bandwidth == 0 {
+		req.Bandwidth = p.DefaultBandwidth
+	}
+	if req.Duration == 0 {
+		req.Duration = p.DefaultDuration
+	}
+	if req.Threads == 0 {
+		req.Threads = p.DefaultThreads
+	}
+
+	var err error
+	if req.Bandwidth, err = p.limit(planTypeKey, "bandwidth", "GB", req.Bandwidth, p.MinBandwidth, p.MaxBandwidth); err != nil {
+		return err
+	}
+	if req.Duration, err = p.limit(planTypeKey, "duration", "days", req.Duration, p.MinDuration, p.MaxDuration); err != nil {
+		return err
+	}
+	if req.Threads, err = p.limit(planTypeKey, "threads", "", req.Threads, 0, p.MaxThreads); err != nil {
+		return err
+	}
+
+	if len(p.AllowedDurations) > 0 && !containsInt(p.AllowedDurations, req.Duration) {
+		allowed := make([]string, len(p.AllowedDurations))
+		for i, d := range p.AllowedDurations {
+			allowed[i] = strconv.Itoa(d)
+		}
+		return &PolicyError{
+			PlanType: planTypeKey,
+			Field:    "duration",
+			Reason:   fmt.Sprintf("%d days is not one of the allowed durations (%s)", req.Duration, strings.Join(allowed, ", ")),
+		}
+	}
+
+	return nil
+}
+
+// limit clamps or rejects value outside [min, max]; zero bounds are ignored
+func (p *PlanPolicy) limit(planTypeKey, field, unit string, value, min, max int) (int, error) {
+	suffix := ""
+	if unit != "" {
+		suffix = " " + unit
+	}
+
+	if min > 0 && value < min {
+		if p.Clamp {
+			return min, nil
+		}
+		return value, &PolicyError{
+			PlanType: planTypeKey,
+			Field:    field,
+			Reason:   fmt.Sprintf("%d%s is below the minimum of %d%s", value, suffix, min, suffix),
+		}
+	}
+
+	if max > 0 && value > max {
+		if p.Clamp {
+			return max, nil
+		}
+		return value, &PolicyError{
+			PlanType: planTypeKey,
+			Field:    field,
+			Reason:   fmt.Sprintf("%d%s exceeds the maximum of %d%s", value, suffix, max, suffix),
+		}
+	}
+
+	return value, nil
+}
+
+func containsInt(values []int, v int) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
@@ -37,7 +37,7 @@ func (pp *PortPool) AllocatePort(planID string) (int, error) {
 	defer pp.mu.Unlock()
 
 	if len(pp.availablePorts) == 0 {
-		return 0, fmt.Errorf("no available ports in range %d-%d for plan type %s",
+		return 0, PortExhaustedf("no available ports in range %d-%d for plan type %s",
 			pp.portRange.Start, pp.portRange.End, pp.planType)
 	}
 
@@ -68,6 +68,39 @@ func (pp *PortPool) ReleasePort(port int) error {
 	return nil
 }
 
+// ReservePort marks a specific port as allocated to a plan, e.g. when
+// restoring allocations for instances that already exist
+func (pp *PortPool) ReservePort(port int, planID string) error {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	if !pp.portRange.Contains(port) {
+		return fmt.Errorf("port %d is not in range %d-%d", port, pp.portRange.Start, pp.portRange.End)
+	}
+
+	if owner, exists := pp.allocatedPorts[port]; exists {
+		if owner == planID {
+			return nil
+		}
+		return Conflictf("port %d is already allocated to plan %s", port, owner)
+	}
+
+	for i, available := range pp.availablePorts {
+		if available == port {
+			pp.availablePorts = append(pp.availablePorts[:i], pp.availablePorts[i+1:]...)
+			break
+		}
+	}
+	pp.allocatedPorts[port] = planID
+
+	return nil
+}
+
+// Range returns the ports of the pool
+func (pp *PortPool) Range() PortRange {
+	return pp.portRange
+}
+
 // IsAllocated checks if a port is allocated
 func (pp *PortPool) IsAllocated(port int) bool {
 	pp.mu.RLock()
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Pricing is what a plan type's bandwidth costs upstream and sells for, per
+// GB in the operator's currency. Upstream prices are updated by hand when a
+// provider changes them.
+type Pricing struct {
+	UpstreamCostPerGB float64 `yaml:"upstream_cost_per_gb" json:"upstream_cost_per_gb"`
+	RetailPricePerGB  float64 `yaml:"retail_price_per_gb" json:"retail_price_per_gb"`
+
+	// UpdatedAt is when the prices were last changed through the API
+	UpdatedAt *time.Time `yaml:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// Validate checks the prices are not negative
+func (p *Pricing) Validate() error {
+	if p.UpstreamCostPerGB < 0 {
+		return fmt.Errorf("upstream_cost_per_gb must not be negative")
+	}
+	if p.RetailPricePerGB < 0 {
+		return fmt.Errorf("retail_price_per_gb must not be negative")
+	}
+	return nil
+}
+
+// MarginPerGB is the retail price less the upstream cost of one GB
+func (p *Pricing) MarginPerGB() float64 {
+	return p.RetailPricePerGB - p.UpstreamCostPerGB
+}
+
+// Negative reports whether the plan type sells below its upstream cost
+func (p *Pricing) Negative() bool {
+	return p.MarginPerGB() < 0
+}
+
+// Margin is the revenue, upstream cost and margin of bandwidth sold.
+// Revenue is after voucher discounts, which are also reported on their own.
+type Margin struct {
+	Plans         int     `json:"plans"`
+	BandwidthGB   int     `json:"bandwidth_gb"`
+	Revenue       float64 `json:"revenue"`
+	Discounts     float64 `json:"discounts"`
+	UpstreamCost  float64 `json:"upstream_cost"`
+	Margin        float64 `json:"margin"`
+	MarginPercent float64 `json:"margin_percent"` // of revenue; 0 without revenue
+}
+
+// Add counts a plan of bandwidth GB sold at pricing less discountPercent
+func (m *Margin) Add(bandwidthGB int, pricing *Pricing, discountPercent float64) {
+	retail := float64(bandwidthGB) * pricing.RetailPricePerGB
+	discount := retail * discountPercent / 100
+
+	m.Plans++
+	m.BandwidthGB += bandwidthGB
+	m.Revenue += retail - discount
+	m.Discounts += discount
+	m.UpstreamCost += float64(bandwidthGB) * pricing.UpstreamCostPerGB
+	m.Margin = m.Revenue - m.UpstreamCost
+	m.MarginPercent = 0
+	if m.Revenue > 0 {
+		m.MarginPercent = m.Margin / m.Revenue * 100
+	}
+}
+
+// MarginWarning flags a plan type whose upstream cost exceeds its price
+type MarginWarning struct {
+	PlanTypeKey string  `json:"plan_type_key"`
+	Provider    string  `json:"provider"`
+	Region      string  `json:"region"`
+	MarginPerGB float64 `json:"margin_per_gb"`
+	Message     string  `json:"message"`
+}
+
+// NewMarginWarning returns the warning for a plan type selling at a loss
+func NewMarginWarning(key string, planType *PlanTypeConfig) MarginWarning {
+	return MarginWarning{
+		PlanTypeKey: key,
+		Provider:    planType.Provider,
+		Region:      planType.Region,
+		MarginPerGB: planType.Pricing.MarginPerGB(),
+		Message: fmt.Sprintf("upstream cost %.4g per GB exceeds retail price %.4g per GB",
+			planType.Pricing.UpstreamCostPerGB, planType.Pricing.RetailPricePerGB),
+	}
+}
+
+// MarginReport aggregates the margin of plans sold in a window. Prices are
+// the plan types' current ones, so a price change restates past windows.
+type MarginReport struct {
+	From       time.Time          `json:"from"`
+	To         time.Time          `json:"to"`
+	Total      Margin             `json:"total"`
+	ByProvider map[string]*Margin `json:"by_provider"`
+	ByRegion   map[string]*Margin `json:"by_region"`
+
+	// UnpricedPlans were sold under plan types without pricing and are
+	// left out of the totals
+	UnpricedPlans     int      `json:"unpriced_plans"`
+	UnpricedPlanTypes []string `json:"unpriced_plan_types,omitempty"`
+
+	Warnings []MarginWarning `json:"warnings,omitempty"`
+}
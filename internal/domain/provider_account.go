@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProviderAccount is an account held with an upstream provider on behalf of
+// a customer. It is managed independently of the plans linked to it: accounts
+// for bandwidth-metered plans may be reused across plans by topping up
+// bandwidth, and renewal, usage sync and deletion act on the account itself.
+type ProviderAccount struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	Provider    string    `json:"provider" db:"provider"`
+	CustomerID  string    `json:"customer_id" db:"customer_id"`
+	PlanTypeKey string    `json:"plan_type_key" db:"plan_type_key"`
+	PlanType    string    `json:"plan_type" db:"plan_type"`
+	Region      string    `json:"region" db:"region"`
+	UpstreamID  string    `json:"upstream_id" db:"upstream_id"`
+
+	// UpstreamCustomerID is the provider's own user ID for the account, if any
+	UpstreamCustomerID string `json:"upstream_customer_id,omitempty" db:"upstream_customer_id"`
+
+	Username  string      `json:"username" db:"username"`
+	Password  string      `json:"password" db:"password"`
+	Host      string      `json:"host" db:"host"`
+	Port      int         `json:"port" db:"port"`
+	Status    string      `json:"status" db:"status"`
+	Bandwidth int         `json:"bandwidth" db:"bandwidth"` // total GB purchased, including top-ups
+	TopUps    int         `json:"top_ups" db:"top_ups"`
+	PlanIDs   []uuid.UUID `json:"plan_ids" db:"-"`
+
+	// Usage as last reported by the provider
+	MaxBytes      int64      `json:"max_bytes,omitempty" db:"max_bytes"`
+	UsedBytes     int64      `json:"used_bytes,omitempty" db:"used_bytes"`
+	UsageSyncedAt *time.Time `json:"usage_synced_at,omitempty" db:"usage_synced_at"`
+
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// Provider account status constants
+const (
+	ProviderAccountStatusActive  = "active"
+	ProviderAccountStatusDeleted = "deleted"
+)
+
+// LinkPlan records that a plan is served by this account
+func (a *ProviderAccount) LinkPlan(planID uuid.UUID) {
+	for _, id := range a.PlanIDs {
+		if id == planID {
+			return
+		}
+	}
+	a.PlanIDs = append(a.PlanIDs, planID)
+}
+
+// UnlinkPlan removes a plan from this account and reports whether it was linked
+func (a *ProviderAccount) UnlinkPlan(planID uuid.UUID) bool {
+	for i, id := range a.PlanIDs {
+		if id == planID {
+			a.PlanIDs = append(a.PlanIDs[:i], a.PlanIDs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// IsBandwidthMetered reports whether a provider plan type is sold by
+// bandwidth (and can therefore be topped up) rather than by time
+func IsBandwidthMetered(provider, planType string) bool {
+	switch {
+	case provider == ProviderProxiesFo && planType == PlanTypeDatacenter:
+		return false
+	case planType == PlanTypeUnlimited:
+		return false
+	}
+	return true
+}
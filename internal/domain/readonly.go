@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// ReadOnlyStatus reports whether the API rejects mutating requests
+type ReadOnlyStatus struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason,omitempty"`
+
+	// Since is when read-only mode was last switched on
+	Since *time.Time `json:"since,omitempty"`
+}
+
+// SetReadOnlyRequest switches read-only mode on or off
+type SetReadOnlyRequest struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason,omitempty"`
+}
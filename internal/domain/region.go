@@ -11,6 +11,9 @@ type Region struct {
 	Description     string   `yaml:"description" json:"description"`
 	PlanTypes       []string `yaml:"plan_types" json:"plan_types"`
 	NginxConfigFile string   `yaml:"nginx_config_file" json:"nginx_config_file"`
+
+	// Countries lists the ISO country codes exit IPs may geolocate to; empty skips geo checks
+	Countries []string `yaml:"countries,omitempty" json:"countries,omitempty"`
 }
 
 // GetFullDomain returns the complete domain for this region
@@ -34,6 +37,100 @@ type PlanTypeConfig struct {
 	LocalPortRange    PortRange `yaml:"local_port_range" json:"local_port_range"`
 	OutboundPort      int       `yaml:"outbound_port" json:"outbound_port"`
 	NginxUpstreamName string    `yaml:"nginx_upstream_name" json:"nginx_upstream_name"`
+
+	// Disabled plan types accept no new plans; existing plans keep running
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+
+	// BindAddress is the local IP this plan type's instances listen on; empty
+	// listens on all addresses and nginx reaches them on 127.0.0.1
+	BindAddress string `yaml:"bind_address,omitempty" json:"bind_address,omitempty"`
+
+	// Egress optionally pins instances to public IPs they send traffic from
+	Egress *EgressSettings `yaml:"egress,omitempty" json:"egress,omitempty"`
+
+	// Proxy holds optional 3proxy directives applied to every instance of this plan type
+	Proxy *ProxySettings `yaml:"proxy,omitempty" json:"proxy,omitempty"`
+
+	// Connections optionally bounds the idle time and lifetime of proxied connections
+	Connections *ConnectionPolicy `yaml:"connections,omitempty" json:"connections,omitempty"`
+
+	// Accounting optionally weighs measured traffic by when it was sent, as for off-peak rates
+	Accounting *AccountingSchedule `yaml:"accounting,omitempty" json:"accounting,omitempty"`
+
+	// Sandbox optionally restricts the privileges of this plan type's 3proxy processes
+	Sandbox *SandboxSettings `yaml:"sandbox,omitempty" json:"sandbox,omitempty"`
+
+	// Resources optionally caps CPU and memory per instance via cgroup v2
+	Resources *ResourceLimits `yaml:"resources,omitempty" json:"resources,omitempty"`
+
+	// Policy sets request defaults and limits; DefaultPlanPolicy applies when unset
+	Policy *PlanPolicy `yaml:"policy,omitempty" json:"policy,omitempty"`
+
+	// UpstreamSelection optionally routes instances to the best-performing of several upstream hosts
+	UpstreamSelection *UpstreamSelection `yaml:"upstream_selection,omitempty" json:"upstream_selection,omitempty"`
+
+	// HealthCheck overrides the global health check parameters for this plan type
+	HealthCheck *HealthCheckSettings `yaml:"health_check,omitempty" json:"health_check,omitempty"`
+
+	// Grace overrides billing.grace_period and billing.grace_throttle for this plan type
+	Grace *GracePeriod `yaml:"grace,omitempty" json:"grace,omitempty"`
+
+	// Pricing optionally sets the upstream cost and retail price for margin reporting
+	Pricing *Pricing `yaml:"pricing,omitempty" json:"pricing,omitempty"`
+}
+
+// UpstreamStrategyLatency picks the healthy upstream host with the lowest probed RTT
+const UpstreamStrategyLatency = "latency"
+
+// UpstreamSelection lists the upstream hosts a plan type may use. Every host
+// must accept the same provider credentials.
+type UpstreamSelection struct {
+	Strategy string   `yaml:"strategy" json:"strategy"`
+	Hosts    []string `yaml:"hosts" json:"hosts"`
+}
+
+// ResourceLimits defines per-instance cgroup limits. Zero means unlimited.
+type ResourceLimits struct {
+	CPUPercent int `yaml:"cpu_percent" json:"cpu_percent,omitempty"` // percent of one core
+	MemoryMB   int `yaml:"memory_mb" json:"memory_mb,omitempty"`
+}
+
+// SandboxSettings controls privilege dropping for 3proxy instances.
+// When Chroot is set, the 3proxy binary, config dir and log dir must live inside it,
+// as must prlimit when NoFile is set.
+type SandboxSettings struct {
+	User   string `yaml:"user" json:"user,omitempty"`
+	Group  string `yaml:"group" json:"group,omitempty"`
+	NoFile uint64 `yaml:"nofile" json:"nofile,omitempty"` // RLIMIT_NOFILE
+	Chroot string `yaml:"chroot" json:"chroot,omitempty"`
+}
+
+// ProxySettings defines advanced 3proxy directives for a plan type
+type ProxySettings struct {
+	MaxConn    int         `yaml:"maxconn" json:"maxconn,omitempty"`
+	BandLimIn  int64       `yaml:"bandlim_in" json:"bandlim_in,omitempty"`   // bits per second
+	BandLimOut int64       `yaml:"bandlim_out" json:"bandlim_out,omitempty"` // bits per second
+	NSCache    int         `yaml:"nscache" json:"nscache,omitempty"`
+	NSCache6   int         `yaml:"nscache6" json:"nscache6,omitempty"`
+	NServers   []string    `yaml:"nservers" json:"nservers,omitempty"`
+	DoH        []string    `yaml:"doh" json:"doh,omitempty"` // https:// resolvers behind a local forwarder; replace nservers
+	Rules      []ACLRule   `yaml:"rules" json:"rules,omitempty"`
+	SOCKS      *SOCKSBlock `yaml:"socks,omitempty" json:"socks,omitempty"`
+}
+
+// ACLRule is a single 3proxy allow/deny line. Empty lists mean "any".
+type ACLRule struct {
+	Action  string   `yaml:"action" json:"action"` // allow or deny
+	Sources []string `yaml:"sources" json:"sources,omitempty"`
+	Targets []string `yaml:"targets" json:"targets,omitempty"`
+	Ports   []string `yaml:"ports" json:"ports,omitempty"`
+}
+
+// SOCKSBlock enables a SOCKS listener alongside the HTTP proxy.
+// The SOCKS port is the instance's local port plus PortOffset.
+type SOCKSBlock struct {
+	Enabled    bool `yaml:"enabled" json:"enabled"`
+	PortOffset int  `yaml:"port_offset" json:"port_offset"`
 }
 
 // PortRange defines a range of ports
@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OperatorReport summarizes a week of operation for operators: plans sold
+// and expired, traffic and revenue, the heaviest customers, instance
+// failures and what the upstream providers cost
+type OperatorReport struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	NewPlans     []ReportPlan `json:"new_plans"`
+	ExpiredPlans []ReportPlan `json:"expired_plans"`
+
+	Requests int64 `json:"requests"`
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+
+	// Revenue is the margin of plans sold in the window; ProviderSpend
+	// breaks it down by provider. See MarginReport.
+	Revenue       Margin             `json:"revenue"`
+	ProviderSpend map[string]*Margin `json:"provider_spend"`
+	UnpricedPlans int                `json:"unpriced_plans"`
+
+	TopCustomers     []CustomerUsage    `json:"top_customers"`
+	InstanceFailures []InstanceFailures `json:"instance_failures"`
+}
+
+// ReportPlan is a plan listed in an operator report
+type ReportPlan struct {
+	ID          uuid.UUID `json:"id"`
+	CustomerID  string    `json:"customer_id"`
+	PlanTypeKey string    `json:"plan_type_key"`
+	Provider    string    `json:"provider"`
+	Region      string    `json:"region"`
+	Bandwidth   int       `json:"bandwidth"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// NewReportPlan lists plan in a report
+func NewReportPlan(plan *ProxyPlan) ReportPlan {
+	return ReportPlan{
+		ID:          plan.ID,
+		CustomerID:  plan.CustomerID,
+		PlanTypeKey: plan.PlanTypeKey,
+		Provider:    plan.Provider,
+		Region:      plan.Region,
+		Bandwidth:   plan.Bandwidth,
+		Status:      plan.Status,
+		CreatedAt:   plan.CreatedAt,
+		ExpiresAt:   plan.ExpiresAt,
+	}
+}
+
+// CustomerUsage is a customer's traffic across their plans
+type CustomerUsage struct {
+	CustomerID string `json:"customer_id"`
+	Plans      int    `json:"plans"`
+	Requests   int64  `json:"requests"`
+	BytesIn    int64  `json:"bytes_in"`
+	BytesOut   int64  `json:"bytes_out"`
+}
+
+// Bytes returns the customer's total traffic
+func (u CustomerUsage) Bytes() int64 {
+	return u.BytesIn + u.BytesOut
+}
+
+// InstanceFailures counts the failures of one plan's instances
+type InstanceFailures struct {
+	PlanID        uuid.UUID `json:"plan_id"`
+	CustomerID    string    `json:"customer_id"`
+	StartFailures int       `json:"start_failures"`
+	Unhealthy     int       `json:"unhealthy"`
+}
+
+// Total returns the number of failures
+func (f InstanceFailures) Total() int {
+	return f.StartFailures + f.Unhealthy
+}
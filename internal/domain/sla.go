@@ -0,0 +1,32 @@
+package domain
+
+import "time"
+
+// SLAReport is the uptime of every region, plan and instance over a period,
+// measured from health checks
+type SLAReport struct {
+	From      time.Time     `json:"from"`
+	To        time.Time     `json:"to"`
+	Regions   []UptimeEntry `json:"regions"`
+	Plans     []UptimeEntry `json:"plans"`
+	Instances []UptimeEntry `json:"instances"`
+
+	// Violations lists the regions and plans below their target
+	Violations []UptimeEntry `json:"violations"`
+}
+
+// UptimeEntry is the uptime of a region, plan or instance. ID is the region
+// name or the plan or instance ID.
+type UptimeEntry struct {
+	ID              string  `json:"id"`
+	Region          string  `json:"region"`
+	PlanID          string  `json:"plan_id,omitempty"`
+	CustomerID      string  `json:"customer_id,omitempty"`
+	UptimePercent   float64 `json:"uptime_percent"`
+	DowntimeSeconds int64   `json:"downtime_seconds"`
+	Outages         int     `json:"outages"`
+
+	// TargetPercent is the SLA target, 0 when none is set
+	TargetPercent float64 `json:"target_percent,omitempty"`
+	Violated      bool    `json:"violated"`
+}
@@ -0,0 +1,29 @@
+package domain
+
+// Slack response types
+const (
+	// SlackEphemeral responses are only shown to the user who ran the command
+	SlackEphemeral = "ephemeral"
+	// SlackInChannel responses are posted to the channel for everyone
+	SlackInChannel = "in_channel"
+)
+
+// SlackCommand is a slash command invocation posted by Slack
+type SlackCommand struct {
+	Command     string
+	Text        string
+	UserID      string
+	UserName    string
+	TeamID      string
+	ChannelID   string
+	ResponseURL string
+	// ReadOnly is set while the API is in read-only mode, which refuses
+	// the commands that change anything
+	ReadOnly bool
+}
+
+// SlackResponse is a message returned to Slack, formatted as mrkdwn
+type SlackResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
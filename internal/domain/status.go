@@ -0,0 +1,45 @@
+package domain
+
+import "time"
+
+// Status page states, from best to worst
+const (
+	StatusOperational = "operational"
+	StatusDegraded    = "degraded"
+	StatusOutage      = "outage"
+)
+
+// StatusPage is the public service status of every region. It carries only
+// aggregates, never plans, instances or upstreams.
+type StatusPage struct {
+	Title       string         `json:"title"`
+	Status      string         `json:"status"`
+	WindowDays  float64        `json:"window_days"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Regions     []RegionStatus `json:"regions"`
+
+	// Incidents are unresolved or resolved within the window, newest first
+	Incidents []PublicIncident `json:"incidents"`
+}
+
+// RegionStatus is the state of one region. A region is degraded while some
+// of its instances fail health checks and out while all of them do. Uptime
+// is the share of instance time spent healthy over the window; an incident
+// is a period in which at least one instance was unhealthy.
+type RegionStatus struct {
+	Region         string     `json:"region"`
+	Description    string     `json:"description,omitempty"`
+	Status         string     `json:"status"`
+	UptimePercent  float64    `json:"uptime_percent"`
+	Incidents      int        `json:"incidents"`
+	LastIncidentAt *time.Time `json:"last_incident_at,omitempty"`
+}
+
+// WorseStatus returns the worse of two status page states
+func WorseStatus(a, b string) string {
+	rank := map[string]int{StatusOperational: 0, StatusDegraded: 1, StatusOutage: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
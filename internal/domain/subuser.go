@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SubUser is one of several users sharing a plan, each with its own
+// credentials and usage. A sub-user with a bandwidth limit is locked out of
+// the plan's instances once it has used it.
+type SubUser struct {
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	Username string    `json:"username"`
+	Password string    `json:"password,omitempty"`
+
+	// BandwidthGB is the sub-user's share of the plan's bandwidth; zero
+	// leaves it limited only by the plan
+	BandwidthGB int `json:"bandwidth_gb,omitempty"`
+
+	// Disabled is set by the customer; Exhausted once the sub-user has used
+	// its bandwidth
+	Disabled  bool `json:"disabled,omitempty"`
+	Exhausted bool `json:"exhausted,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LimitBytes returns the sub-user's bandwidth limit in bytes, 0 for none
+func (u *SubUser) LimitBytes() int64 {
+	return int64(u.BandwidthGB) * 1024 * 1024 * 1024
+}
+
+// Enabled reports whether the sub-user may use the plan's proxies
+func (u *SubUser) Enabled() bool {
+	return !u.Disabled && !u.Exhausted
+}
+
+// SubUserUsage is the traffic of a sub-user counted from instance logs
+type SubUserUsage struct {
+	Requests   int64      `json:"requests"`
+	BytesIn    int64      `json:"bytes_in"`
+	BytesOut   int64      `json:"bytes_out"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// UsedBytes returns the traffic counted against the sub-user's limit
+func (u *SubUserUsage) UsedBytes() int64 {
+	return u.BytesIn + u.BytesOut
+}
+
+// SubUserRequest creates or updates a sub-user. Username and Password are
+// generated when empty; the username cannot be changed.
+type SubUserRequest struct {
+	Name        string `json:"name"`
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	BandwidthGB int    `json:"bandwidth_gb,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+}
+
+// SubUserResponse is a sub-user with its usage. Live is missing until the
+// sub-user's traffic has been collected since the server started.
+type SubUserResponse struct {
+	SubUser
+	Usage     SubUserUsage `json:"usage"`
+	UsedBytes int64        `json:"used_bytes"`
+	Live      *SubUserLive `json:"live,omitempty"`
+}
+
+// SubUserLive is a sub-user's recent activity, kept in memory from the
+// instance logs. 3proxy logs connections when they close, so connections
+// show up here once finished.
+type SubUserLive struct {
+	// ActiveConnections is the most connections open at once during the
+	// last minute, from the logged connection durations
+	ActiveConnections int `json:"active_connections"`
+
+	// Connections is how many connections closed during the last minute
+	Connections int `json:"connections"`
+
+	// Requests and bytes in the current clock hour
+	RequestsThisHour int64     `json:"requests_this_hour"`
+	BytesThisHour    int64     `json:"bytes_this_hour"`
+	Hour             time.Time `json:"hour"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TempCredential is a short-lived username and password for a plan's
+// proxies, for example for a contractor. It is added as an extra user on the
+// plan's instances and removed once it expires.
+type TempCredential struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	Password  string    `json:"password"`
+	Label     string    `json:"label,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Active reports whether the credential is valid at now
+func (c *TempCredential) Active(now time.Time) bool {
+	return now.Before(c.ExpiresAt)
+}
+
+// TempCredentialRequest issues a credential valid for Minutes; zero minutes
+// use one hour, capped by proxy.temp_credentials.max_ttl
+type TempCredentialRequest struct {
+	Minutes int    `json:"minutes,omitempty"`
+	Label   string `json:"label,omitempty"`
+}
+
+// TempCredentialResponse is an issued credential with the plan's endpoints
+// rendered for it
+type TempCredentialResponse struct {
+	PlanID uuid.UUID `json:"plan_id"`
+	TempCredential
+	Proxies []ProxyEndpoint `json:"proxies"`
+}
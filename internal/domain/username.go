@@ -0,0 +1,24 @@
+package domain
+
+import "errors"
+
+// ErrDuplicateUsername is returned when a plan would reuse a username held by
+// a plan on a different provider account. Plans sharing an account may share
+// its username, since they also share its password.
+var ErrDuplicateUsername error = &kindError{kind: ErrConflict, msg: "username already in use"}
+
+// UsernameConflicts reports whether two plans may not both use their usernames
+func UsernameConflicts(a, b *ProxyPlan) bool {
+	if a.ID == b.ID || a.Username == "" || a.Username != b.Username {
+		return false
+	}
+	if a.ProviderAccountID != nil && b.ProviderAccountID != nil && *a.ProviderAccountID == *b.ProviderAccountID {
+		return false
+	}
+	return true
+}
+
+// IsDuplicateUsername reports whether err was caused by a username conflict
+func IsDuplicateUsername(err error) bool {
+	return errors.Is(err, ErrDuplicateUsername)
+}
@@ -0,0 +1,182 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Voucher kinds
+const (
+	// VoucherKindDiscount takes a percentage off the plan's price
+	VoucherKindDiscount = "discount"
+	// VoucherKindTrial grants a free plan of the voucher's bandwidth and
+	// duration, whatever the request asked for
+	VoucherKindTrial = "trial"
+)
+
+// Voucher is a code redeemed when creating a plan. Its constraints limit
+// which plans it applies to; a zero constraint does not limit.
+type Voucher struct {
+	Code string `json:"code"`
+	Kind string `json:"kind"`
+
+	// DiscountPercent is taken off the plan's price by discount vouchers
+	DiscountPercent float64 `json:"discount_percent,omitempty"`
+
+	// TrialBandwidth (GB) and TrialDuration (days) are granted by trial vouchers
+	TrialBandwidth int `json:"trial_bandwidth,omitempty"`
+	TrialDuration  int `json:"trial_duration,omitempty"`
+
+	// PlanTypes lists the plan type keys the voucher applies to
+	PlanTypes []string `json:"plan_types,omitempty"`
+
+	// MinBandwidth and MaxBandwidth bound the plan's bandwidth in GB
+	MinBandwidth int `json:"min_bandwidth,omitempty"`
+	MaxBandwidth int `json:"max_bandwidth,omitempty"`
+
+	// CustomerID restricts the voucher to one customer
+	CustomerID string `json:"customer_id,omitempty"`
+
+	// MaxRedemptions is how many plans may use the voucher; 1 is single-use
+	MaxRedemptions int `json:"max_redemptions,omitempty"`
+	Redemptions    int `json:"redemptions"`
+
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+
+	// Batch is shared by the vouchers generated together
+	Batch     string    `json:"batch"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NormalizeVoucherCode returns code as vouchers are stored: trimmed and
+// upper case, so codes are matched case-insensitively
+func NormalizeVoucherCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Status returns "active", "revoked", "expired" or "redeemed"
+func (v *Voucher) Status(now time.Time) string {
+	switch {
+	case v.RevokedAt != nil:
+		return "revoked"
+	case v.ExpiresAt != nil && !now.Before(*v.ExpiresAt):
+		return "expired"
+	case v.MaxRedemptions > 0 && v.Redemptions >= v.MaxRedemptions:
+		return "redeemed"
+	default:
+		return "active"
+	}
+}
+
+// Check returns why the voucher cannot be redeemed for a plan of
+// planTypeKey requested by req, or nil if it can. Trial vouchers ignore the
+// requested bandwidth, since they replace it.
+func (v *Voucher) Check(planTypeKey string, req *CreatePlanRequest, now time.Time) error {
+	reject := func(reason string) error {
+		return &PolicyError{PlanType: planTypeKey, Field: "voucher", Reason: reason}
+	}
+
+	switch v.Status(now) {
+	case "revoked":
+		return reject("has been revoked")
+	case "expired":
+		return reject("has expired")
+	case "redeemed":
+		return reject("has already been redeemed")
+	}
+
+	if len(v.PlanTypes) > 0 && !containsString(v.PlanTypes, planTypeKey) {
+		return reject("does not apply")
+	}
+	if v.CustomerID != "" && v.CustomerID != req.CustomerID {
+		return reject("belongs to another customer")
+	}
+	if v.Kind == VoucherKindTrial {
+		return nil
+	}
+	if v.MinBandwidth > 0 && req.Bandwidth < v.MinBandwidth {
+		return reject(fmt.Sprintf("requires at least %d GB", v.MinBandwidth))
+	}
+	if v.MaxBandwidth > 0 && req.Bandwidth > v.MaxBandwidth {
+		return reject(fmt.Sprintf("applies to at most %d GB", v.MaxBandwidth))
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// PlanVoucher records the voucher a plan was created with
+type PlanVoucher struct {
+	Code            string  `json:"code"`
+	Kind            string  `json:"kind"`
+	DiscountPercent float64 `json:"discount_percent"` // 100 for trials
+}
+
+// VoucherRedemption is the audit record of a voucher used to create a plan.
+// If the plan could not be provisioned the redemption is released, giving
+// the use back, and kept with ReleasedAt set.
+type VoucherRedemption struct {
+	ID              uuid.UUID `json:"id"`
+	Code            string    `json:"code"`
+	PlanID          uuid.UUID `json:"plan_id"`
+	CustomerID      string    `json:"customer_id,omitempty"`
+	PlanTypeKey     string    `json:"plan_type_key"`
+	Kind            string    `json:"kind"`
+	DiscountPercent float64   `json:"discount_percent"`
+	Bandwidth       int       `json:"bandwidth"`
+	Duration        int       `json:"duration"`
+	RedeemedAt      time.Time `json:"redeemed_at"`
+
+	ReleasedAt    *time.Time `json:"released_at,omitempty"`
+	ReleaseReason string     `json:"release_reason,omitempty"`
+}
+
+// GenerateVouchersRequest creates Count vouchers with the same terms.
+// Codes are generated as PREFIX-XXXX-XXXX unless Code is set for a single
+// voucher.
+type GenerateVouchersRequest struct {
+	Count  int    `json:"count,omitempty"`
+	Code   string `json:"code,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+
+	Kind            string  `json:"kind"`
+	DiscountPercent float64 `json:"discount_percent,omitempty"`
+	TrialBandwidth  int     `json:"trial_bandwidth,omitempty"`
+	TrialDuration   int     `json:"trial_duration,omitempty"`
+
+	PlanTypes    []string `json:"plan_types,omitempty"`
+	MinBandwidth int      `json:"min_bandwidth,omitempty"`
+	MaxBandwidth int      `json:"max_bandwidth,omitempty"`
+	CustomerID   string   `json:"customer_id,omitempty"`
+
+	// SingleUse is shorthand for MaxRedemptions 1
+	SingleUse      bool       `json:"single_use,omitempty"`
+	MaxRedemptions int        `json:"max_redemptions,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	Note           string     `json:"note,omitempty"`
+}
+
+// GenerateVouchersResponse lists the vouchers generated by one request
+type GenerateVouchersResponse struct {
+	Batch    string     `json:"batch"`
+	Vouchers []*Voucher `json:"vouchers"`
+}
+
+// VoucherResponse is a voucher with its status and redemption audit
+type VoucherResponse struct {
+	*Voucher
+	Status            string               `json:"status"`
+	RedemptionRecords []*VoucherRedemption `json:"redemption_records,omitempty"`
+}
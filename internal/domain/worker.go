@@ -0,0 +1,38 @@
+package domain
+
+import "time"
+
+// Worker kinds
+const (
+	// WorkerKindWorker is a long-running loop restarted per its policy
+	WorkerKindWorker = "worker"
+	// WorkerKindTask is a one-shot job; runs with the same name share a status
+	WorkerKindTask = "task"
+)
+
+// Worker states
+const (
+	WorkerStateRunning    = "running"
+	WorkerStateRestarting = "restarting"
+	WorkerStateStopped    = "stopped"
+	WorkerStateFailed     = "failed"
+	WorkerStateIdle       = "idle"
+)
+
+// WorkerStatus reports a supervised background goroutine
+type WorkerStatus struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Policy   string `json:"policy,omitempty"`
+	State    string `json:"state"`
+	Running  int    `json:"running"`
+	Runs     int64  `json:"runs"`
+	Restarts int64  `json:"restarts"`
+	Failures int64  `json:"failures"`
+	Panics   int64  `json:"panics"`
+
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	StoppedAt   *time.Time `json:"stopped_at,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
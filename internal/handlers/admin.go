@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/pkg/errors"
+	"github.com/je265/oceanproxy/internal/service"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// AdminHandler handles operator-facing debugging endpoints
+type AdminHandler struct {
+	cfg       *config.Config
+	logger    *zap.Logger
+	upstreams *service.UpstreamProber
+	geo       *service.GeoVerifier
+	authGuard *service.AuthGuard
+	cleanup   *service.CleanupService
+	ports     *service.PortManager
+	reloader  *service.ConfigReloader
+	workers   *service.Supervisor
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(cfg *config.Config, logger *zap.Logger, upstreams *service.UpstreamProber, geo *service.GeoVerifier, authGuard *service.AuthGuard, cleanup *service.CleanupService, ports *service.PortManager, reloader *service.ConfigReloader, workers *service.Supervisor) *AdminHandler {
+	return &AdminHandler{
+		cfg:       cfg,
+		logger:    logger,
+		upstreams: upstreams,
+		geo:       geo,
+		authGuard: authGuard,
+		cleanup:   cleanup,
+		ports:     ports,
+		reloader:  reloader,
+		workers:   workers,
+	}
+}
+
+// GetConfig returns the effective configuration with secrets redacted
+// @Summary Effective configuration
+// @Description Returns the merged configuration the server is running with; secrets are redacted
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /admin/config [get]
+func (h *AdminHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	h.respondWithJSON(w, http.StatusOK, config.Effective(h.cfg))
+}
+
+// GetLastReload returns the summary of the last configuration reload
+// @Summary Last configuration reload
+// @Description Returns the settings applied and those awaiting a restart by the most recent SIGHUP reload
+// @Tags admin
+// @Produce json
+// @Success 200 {object} domain.ConfigReload
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/config/last-reload [get]
+func (h *AdminHandler) GetLastReload(w http.ResponseWriter, r *http.Request) {
+	reload := h.reloader.LastReload()
+	if reload == nil {
+		h.respondWithJSON(w, http.StatusNotFound, errors.NewNotFoundError("Configuration reload"))
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, reload)
+}
+
+// GetProxiesFoResellerIDs lists the configured Proxies.fo plan type to reseller ID mappings
+// @Summary Proxies.fo reseller IDs
+// @Description Returns the plan type to reseller UUID mappings from providers.proxies_fo.reseller_ids
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /admin/providers/proxies_fo/reseller-ids [get]
+func (h *AdminHandler) GetProxiesFoResellerIDs(w http.ResponseWriter, r *http.Request) {
+	resellerIDs := h.cfg.Providers.ProxiesFo.ResellerIDs
+	if resellerIDs == nil {
+		resellerIDs = map[string]string{}
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"provider":     "proxies_fo",
+		"reseller_ids": resellerIDs,
+	})
+}
+
+// GetUpstreams returns the latest RTT probe results for upstream hosts
+// @Summary Upstream probe results
+// @Description Returns smoothed RTT and health of upstream hosts probed for latency-based selection
+// @Tags admin
+// @Produce json
+// @Success 200 {array} service.UpstreamStats
+// @Security BearerAuth
+// @Router /admin/upstreams [get]
+func (h *AdminHandler) GetUpstreams(w http.ResponseWriter, r *http.Request) {
+	h.respondWithJSON(w, http.StatusOK, h.upstreams.Stats())
+}
+
+// GetWorkers returns the status of supervised background goroutines
+// @Summary Background workers
+// @Description Returns each background worker and one-shot task with its state, restarts, failures, panics and last error
+// @Tags admin
+// @Produce json
+// @Success 200 {array} domain.WorkerStatus
+// @Security BearerAuth
+// @Router /admin/workers [get]
+func (h *AdminHandler) GetWorkers(w http.ResponseWriter, r *http.Request) {
+	h.respondWithJSON(w, http.StatusOK, h.workers.Status())
+}
+
+// GetEgressIPs returns the egress IP inventory
+// @Summary Egress IP inventory
+// @Description Returns every configured egress IP with its plan type and the plans sending traffic from it
+// @Tags admin
+// @Produce json
+// @Success 200 {array} domain.EgressIPAllocation
+// @Security BearerAuth
+// @Router /admin/egress-ips [get]
+func (h *AdminHandler) GetEgressIPs(w http.ResponseWriter, r *http.Request) {
+	h.respondWithJSON(w, http.StatusOK, h.ports.EgressInventory())
+}
+
+// GetGeoMismatches lists plans whose exit IP was found outside their region
+// @Summary Wrong-region plans
+// @Description Returns plans whose latest exit IP geolocation did not match their region
+// @Tags admin
+// @Produce json
+// @Success 200 {array} domain.ProxyPlan
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/geo-mismatches [get]
+func (h *AdminHandler) GetGeoMismatches(w http.ResponseWriter, r *http.Request) {
+	plans, err := h.geo.Mismatches(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list geo mismatches", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to list geo mismatches", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, plans)
+}
+
+// VerifyPlanGeo checks a plan's exit IP location now
+// @Summary Verify plan region
+// @Description Looks up the plan's exit IP location through its upstream and records the result, remediating a mismatch if configured
+// @Tags admin
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Success 200 {object} domain.GeoCheck
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 422 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/plans/{id}/verify-geo [post]
+func (h *AdminHandler) VerifyPlanGeo(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	check, err := h.geo.VerifyPlan(r.Context(), planID)
+	if err != nil {
+		h.respondWithError(w, http.StatusUnprocessableEntity, "Plan cannot be geo verified", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, check)
+}
+
+// GetAuthBlocks lists source IPs blocked for failing proxy authentication
+// @Summary Auth guard blocks
+// @Description Returns source IPs currently blocked by proxy.auth_guard, soonest to expire first
+// @Tags admin
+// @Produce json
+// @Success 200 {array} service.AuthBlock
+// @Security BearerAuth
+// @Router /admin/auth-blocks [get]
+func (h *AdminHandler) GetAuthBlocks(w http.ResponseWriter, r *http.Request) {
+	h.respondWithJSON(w, http.StatusOK, h.authGuard.Blocks())
+}
+
+// DeleteAuthBlock lifts the blocks of a source IP ahead of time
+// @Summary Lift auth guard block
+// @Description Unblocks a source IP on every port it is blocked on
+// @Tags admin
+// @Produce json
+// @Param ip path string true "Source IP"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/auth-blocks/{ip} [delete]
+func (h *AdminHandler) DeleteAuthBlock(w http.ResponseWriter, r *http.Request) {
+	ip := chi.URLParam(r, "ip")
+	if net.ParseIP(ip) == nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid IP address", nil)
+		return
+	}
+
+	lifted, err := h.authGuard.Unblock(r.Context(), ip)
+	if err != nil {
+		h.logger.Error("Failed to lift auth block", zap.String("ip", ip), zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to lift auth block", err)
+		return
+	}
+	if lifted == 0 {
+		h.respondWithError(w, http.StatusNotFound, "IP is not blocked", nil)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"ip":     ip,
+		"lifted": lifted,
+	})
+}
+
+// Cleanup expires plans and tidies up instances, configs and ports
+// @Summary Run cleanup
+// @Description Runs the same cleanup as the CLI cleanup command with the given policy and reports what changed. Without a body, plans are expired and failed instances restarted.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param policy body domain.CleanupPolicy false "Cleanup policy"
+// @Success 200 {object} domain.CleanupReport
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/cleanup [post]
+func (h *AdminHandler) Cleanup(w http.ResponseWriter, r *http.Request) {
+	policy := domain.DefaultCleanupPolicy()
+	if err := decodeJSON(r, &policy); err != nil && err != io.EOF {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if policy.DeleteFailedAfterDays < 0 {
+		h.respondWithError(w, http.StatusBadRequest, "delete_failed_after_days must not be negative", nil)
+		return
+	}
+
+	report, err := h.cleanup.Run(r.Context(), policy, time.Now())
+	if err != nil {
+		h.logger.Error("Cleanup failed", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Cleanup failed", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, report)
+}
+
+// Helper methods
+func (h *AdminHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *AdminHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// AlertHandler exposes the alerts paged to on-call
+type AlertHandler struct {
+	alerts *service.AlertMonitor
+	logger *zap.Logger
+}
+
+// NewAlertHandler creates a new alert handler
+func NewAlertHandler(alerts *service.AlertMonitor, logger *zap.Logger) *AlertHandler {
+	return &AlertHandler{
+		alerts: alerts,
+		logger: logger,
+	}
+}
+
+// GetAlerts lists the active alerts
+// @Summary List active alerts
+// @Description Alerts triggered at PagerDuty or Opsgenie whose condition is still firing, or whose resolve is still being delivered, oldest first
+// @Tags admin
+// @Produce json
+// @Success 200 {array} domain.Alert
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/alerts [get]
+func (h *AlertHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	alerts, err := h.alerts.Active(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list alerts", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to list alerts", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, alerts)
+}
+
+func (h *AlertHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *AlertHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
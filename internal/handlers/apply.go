@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// ApplyHandler handles declarative plan documents
+type ApplyHandler struct {
+	apply  *service.ApplyService
+	logger *zap.Logger
+}
+
+// NewApplyHandler creates a new apply handler
+func NewApplyHandler(apply *service.ApplyService, logger *zap.Logger) *ApplyHandler {
+	return &ApplyHandler{
+		apply:  apply,
+		logger: logger,
+	}
+}
+
+// Apply reconciles plans with a declarative document
+// @Summary Apply plan document
+// @Description Compares the document's plans with the live plans of its customers and creates, tops up, updates or deletes plans to match, returning the plan of changes. The body is JSON, or YAML with a YAML Content-Type. With dry_run nothing is changed; without prune, previously applied plans missing from the document are only reported.
+// @Tags plans
+// @Accept json,yaml
+// @Produce json
+// @Param request body domain.ApplyDocument true "Desired plans"
+// @Param dry_run query bool false "Only compute the changes"
+// @Param prune query bool false "Delete previously applied plans missing from the document"
+// @Success 200 {object} domain.ApplyResult
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/apply [post]
+func (h *ApplyHandler) Apply(w http.ResponseWriter, r *http.Request) {
+	var doc domain.ApplyDocument
+	if err := decodeApplyDocument(r, &doc); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid apply document", err)
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	prune := r.URL.Query().Get("prune") == "true"
+
+	result, err := h.apply.Apply(r.Context(), &doc, dryRun, prune)
+	if err != nil {
+		if stderrors.Is(err, service.ErrInvalidApplyDocument) {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid apply document", err)
+			return
+		}
+		h.logger.Error("Failed to apply plan document", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to apply plan document", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, result)
+}
+
+// decodeApplyDocument decodes a JSON or, going by the Content-Type, YAML
+// apply document
+func decodeApplyDocument(r *http.Request, doc *domain.ApplyDocument) error {
+	if !strings.Contains(r.Header.Get("Content-Type"), "yaml") {
+		return decodeJSON(r, doc)
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return describeDecodeError(err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(doc); err != nil {
+		if err == io.EOF {
+			return io.EOF
+		}
+		return fmt.Errorf("malformed YAML: %v", err)
+	}
+	return nil
+}
+
+// Helper methods
+func (h *ApplyHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *ApplyHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// CapabilityHandler serves the features of the purchasable plan types
+type CapabilityHandler struct {
+	capabilities *service.CapabilityService
+	logger       *zap.Logger
+}
+
+// NewCapabilityHandler creates a new capability handler
+func NewCapabilityHandler(capabilities *service.CapabilityService, logger *zap.Logger) *CapabilityHandler {
+	return &CapabilityHandler{
+		capabilities: capabilities,
+		logger:       logger,
+	}
+}
+
+// GetCapabilities returns the capability matrix
+// @Summary Plan type capabilities
+// @Description Lists, per provider and plan type, the regions it is sold in and which features it offers (geo targeting, sticky sessions, SOCKS5, IP auth, renewal, top-up)
+// @Tags plans
+// @Produce json
+// @Success 200 {object} domain.CapabilityMatrix
+// @Security BearerAuth
+// @Router /capabilities [get]
+func (h *CapabilityHandler) GetCapabilities(w http.ResponseWriter, r *http.Request) {
+	h.respondWithJSON(w, http.StatusOK, h.capabilities.Matrix())
+}
+
+// GetEndpointMap returns the effective endpoint mapping
+// @Summary Endpoint mapping
+// @Description Lists, per configured plan type (provider, plan type and region), the customer-facing host, port and region label its plans are given, as computed from the plan type and region configuration
+// @Tags plans
+// @Produce json
+// @Success 200 {object} domain.EndpointMap
+// @Security BearerAuth
+// @Router /config/endpoint-map [get]
+func (h *CapabilityHandler) GetEndpointMap(w http.ResponseWriter, r *http.Request) {
+	h.respondWithJSON(w, http.StatusOK, h.capabilities.EndpointMap())
+}
+
+func (h *CapabilityHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
@@ -0,0 +1,155 @@
+// Package compat serves the deprecated form-based plan creation routes,
+// POST /plan and POST /nettify/plan. It translates their form fields into a
+// domain.CreatePlanRequest, including the quirks older clients rely on, so
+// the core handlers only deal with the JSON API. The routes are mounted only
+// while api.legacy_routes is enabled.
+package compat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/pkg/errors"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// Handler serves the legacy plan creation routes
+type Handler struct {
+	planService service.PlanService
+	logger      *zap.Logger
+}
+
+// NewHandler creates a new legacy route handler
+func NewHandler(planService service.PlanService, logger *zap.Logger) *Handler {
+	return &Handler{
+		planService: planService,
+		logger:      logger,
+	}
+}
+
+// CreateProxiesFoPlan creates a plan using Proxies.fo provider (legacy endpoint)
+// @Summary Create Proxies.fo plan
+// @Description Create a proxy plan using Proxies.fo provider
+// @Tags plans
+// @Accept application/x-www-form-urlencoded
+// @Produce json
+// @Param reseller formData string true "Plan type (residential, datacenter, isp)"
+// @Param bandwidth formData int true "Bandwidth in GB"
+// @Param username formData string true "Username"
+// @Param password formData string true "Password"
+// @Success 201 {object} domain.CreatePlanResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Deprecated
+// @Router /plan [post]
+func (h *Handler) CreateProxiesFoPlan(w http.ResponseWriter, r *http.Request) {
+	h.createPlan(w, r, ProxiesFoRequest)
+}
+
+// CreateNettifyPlan creates a plan using Nettify provider (legacy endpoint)
+// @Summary Create Nettify plan
+// @Description Create a proxy plan using Nettify provider
+// @Tags plans
+// @Accept application/x-www-form-urlencoded
+// @Produce json
+// @Param plan_type formData string true "Plan type (residential, datacenter, mobile)"
+// @Param bandwidth formData int true "Bandwidth in GB"
+// @Param username formData string true "Username"
+// @Param password formData string true "Password"
+// @Success 201 {object} domain.CreatePlanResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Deprecated
+// @Router /nettify/plan [post]
+func (h *Handler) CreateNettifyPlan(w http.ResponseWriter, r *http.Request) {
+	h.createPlan(w, r, NettifyRequest)
+}
+
+// ProxiesFoRequest translates a legacy /plan form. The plan type is sent as
+// "reseller", and plans are always created in the USA region.
+func ProxiesFoRequest(form url.Values, now time.Time) (*domain.CreatePlanRequest, error) {
+	return legacyRequest(form, now, form.Get("reseller"), domain.ProviderProxiesFo, domain.RegionUSA)
+}
+
+// NettifyRequest translates a legacy /nettify/plan form. Plans are always
+// created in the Alpha region.
+func NettifyRequest(form url.Values, now time.Time) (*domain.CreatePlanRequest, error) {
+	return legacyRequest(form, now, form.Get("plan_type"), domain.ProviderNettify, domain.RegionAlpha)
+}
+
+// legacyRequest builds the request shared by both forms. Without a
+// customer_id, the plan gets a customer of its own named after the time.
+func legacyRequest(form url.Values, now time.Time, planType, provider, region string) (*domain.CreatePlanRequest, error) {
+	bandwidth, err := strconv.Atoi(form.Get("bandwidth"))
+	if err != nil {
+		return nil, fmt.Errorf("bandwidth must be an integer: %w", err)
+	}
+
+	customerID := form.Get("customer_id")
+	if customerID == "" {
+		customerID = "legacy_customer_" + strconv.FormatInt(now.Unix(), 10)
+	}
+
+	return &domain.CreatePlanRequest{
+		CustomerID: customerID,
+		PlanType:   planType,
+		Provider:   provider,
+		Region:     region,
+		Username:   form.Get("username"),
+		Password:   form.Get("password"),
+		Bandwidth:  bandwidth,
+	}, nil
+}
+
+// createPlan parses the form, translates it and creates the plan
+func (h *Handler) createPlan(w http.ResponseWriter, r *http.Request, translate func(url.Values, time.Time) (*domain.CreatePlanRequest, error)) {
+	if err := r.ParseForm(); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Failed to parse form", err)
+		return
+	}
+
+	req, err := translate(r.Form, time.Now())
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid bandwidth", err)
+		return
+	}
+
+	response, err := h.planService.CreatePlan(r.Context(), req)
+	if err != nil {
+		h.logger.Error("Failed to create legacy plan", zap.String("provider", req.Provider), zap.Error(err))
+		switch {
+		case domain.IsPolicyError(err):
+			h.respondWithJSON(w, http.StatusBadRequest, errors.NewValidationError("Plan request violates plan type policy", err.Error()))
+		case domain.IsDuplicateUsername(err):
+			h.respondWithJSON(w, http.StatusConflict, errors.NewConflictError("Username already in use", err.Error()))
+		default:
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to create plan", err)
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, response)
+}
+
+func (h *Handler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *Handler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	errorResponse := errors.NewErrorResponse(message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// corsPolicy is a compiled config.CORSPolicy
+type corsPolicy struct {
+	pathPrefix       string
+	anyOrigin        bool
+	exactOrigins     map[string]bool
+	originPatterns   []*regexp.Regexp
+	allowMethods     string
+	allowHeaders     []string
+	anyHeader        bool
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+func compileCORSPolicy(pathPrefix string, p config.CORSPolicy) (*corsPolicy, error) {
+	policy := &corsPolicy{
+		pathPrefix:       pathPrefix,
+		exactOrigins:     make(map[string]bool),
+		allowMethods:     strings.Join(p.AllowMethods, ", "),
+		exposeHeaders:    strings.Join(p.ExposeHeaders, ", "),
+		allowCredentials: p.AllowCredentials,
+	}
+
+	for _, origin := range p.AllowOrigins {
+		switch {
+		case origin == "*":
+			policy.anyOrigin = true
+		case strings.HasPrefix(origin, "regex:"):
+			re, err := regexp.Compile(strings.TrimPrefix(origin, "regex:"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid CORS origin pattern %q: %w", origin, err)
+			}
+			policy.originPatterns = append(policy.originPatterns, re)
+		case strings.Contains(origin, "*"):
+			pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(strings.ToLower(origin)), `\*`, `[^/]*`) + "$"
+			policy.originPatterns = append(policy.originPatterns, regexp.MustCompile(pattern))
+		default:
+			policy.exactOrigins[strings.ToLower(origin)] = true
+		}
+	}
+
+	for _, header := range p.AllowHeaders {
+		if header == "*" {
+			policy.anyHeader = true
+			continue
+		}
+		policy.allowHeaders = append(policy.allowHeaders, header)
+	}
+
+	if p.MaxAge > 0 {
+		policy.maxAge = strconv.Itoa(int(p.MaxAge.Seconds()))
+	}
+
+	return policy, nil
+}
+
+func (p *corsPolicy) originAllowed(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+
+	lower := strings.ToLower(origin)
+	if p.exactOrigins[lower] {
+		return true
+	}
+	for _, re := range p.originPatterns {
+		if re.MatchString(lower) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewCORSMiddleware returns a spec-compliant CORS middleware. The policy is
+// chosen per request by the longest matching route prefix, falling back to
+// the default policy.
+func NewCORSMiddleware(cfg config.CORS, logger *zap.Logger) (func(http.Handler) http.Handler, error) {
+	defaultPolicy, err := compileCORSPolicy("", cfg.CORSPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make([]*corsPolicy, 0, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		policy, err := compileCORSPolicy(route.PathPrefix, route.CORSPolicy)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, policy)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return len(routes[i].pathPrefix) > len(routes[j].pathPrefix)
+	})
+
+	selectPolicy := func(path string) *corsPolicy {
+		for _, policy := range routes {
+			if strings.HasPrefix(path, policy.pathPrefix) {
+				return policy
+			}
+		}
+		return defaultPolicy
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Origin")
+
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			policy := selectPolicy(r.URL.Path)
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			if !policy.originAllowed(origin) {
+				if preflight {
+					logger.Debug("CORS preflight rejected",
+						zap.String("origin", origin),
+						zap.String("path", r.URL.Path))
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// "*" cannot be combined with credentials, so echo the origin instead
+			if policy.anyOrigin && !policy.allowCredentials {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if policy.allowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if policy.exposeHeaders != "" {
+					w.Header().Set("Access-Control-Expose-Headers", policy.exposeHeaders)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+			if policy.allowMethods != "" {
+				w.Header().Set("Access-Control-Allow-Methods", policy.allowMethods)
+			}
+
+			if policy.anyHeader {
+				if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+					w.Header().Set("Access-Control-Allow-Headers", requested)
+				}
+			} else if len(policy.allowHeaders) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(policy.allowHeaders, ", "))
+			}
+
+			if policy.maxAge != "" {
+				w.Header().Set("Access-Control-Max-Age", policy.maxAge)
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}, nil
+}
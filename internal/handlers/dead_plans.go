@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// DeadPlanHandler serves the dead plan triage report
+type DeadPlanHandler struct {
+	detector *service.DeadPlanDetector
+	logger   *zap.Logger
+}
+
+// NewDeadPlanHandler creates a new dead plan handler
+func NewDeadPlanHandler(detector *service.DeadPlanDetector, logger *zap.Logger) *DeadPlanHandler {
+	return &DeadPlanHandler{
+		detector: detector,
+		logger:   logger,
+	}
+}
+
+// GetDeadPlans lists active plans that look dead
+// @Summary Triage dead plans
+// @Description Active plans whose edge traffic, upstream request failures, provider-reported usage and connection tests do not add up, most findings first
+// @Tags admin
+// @Produce json
+// @Success 200 {object} domain.DeadPlanReport
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/dead-plans [get]
+func (h *DeadPlanHandler) GetDeadPlans(w http.ResponseWriter, r *http.Request) {
+	report, err := h.detector.Triage(r.Context(), time.Now())
+	if err != nil {
+		h.logger.Error("Failed to triage dead plans", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to triage dead plans", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, report)
+}
+
+func (h *DeadPlanHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *DeadPlanHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// DebugSamplingHandler handles request sampling of plans being debugged
+type DebugSamplingHandler struct {
+	sampler     *service.DebugSampler
+	planService service.PlanService
+	logger      *zap.Logger
+}
+
+// NewDebugSamplingHandler creates a new debug sampling handler
+func NewDebugSamplingHandler(sampler *service.DebugSampler, planService service.PlanService, logger *zap.Logger) *DebugSamplingHandler {
+	return &DebugSamplingHandler{
+		sampler:     sampler,
+		planService: planService,
+		logger:      logger,
+	}
+}
+
+// StartDebugSampling starts sampling a plan's requests
+// @Summary Start plan debug sampling
+// @Description Record the method, host, result code, duration and byte counts of a share of the plan's requests, never bodies, for a limited time. Starting again replaces earlier samples.
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param request body domain.DebugSamplingRequest true "Sampling settings"
+// @Success 200 {object} domain.ProxyPlan
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/debug-sampling [put]
+func (h *DebugSamplingHandler) StartDebugSampling(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	var req domain.DebugSamplingRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if _, err := h.planService.GetPlan(r.Context(), planID); err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	plan, err := h.sampler.Start(r.Context(), planID, &req, time.Now())
+	if err != nil {
+		if domain.IsPolicyError(err) {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid debug sampling settings", err)
+			return
+		}
+		h.logger.Error("Failed to start debug sampling", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to start debug sampling", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, plan)
+}
+
+// StopDebugSampling ends a plan's sampling early
+// @Summary Stop plan debug sampling
+// @Description End the plan's sampling window now; samples taken so far stay available
+// @Tags plans
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Success 200 {object} domain.ProxyPlan
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/debug-sampling [delete]
+func (h *DebugSamplingHandler) StopDebugSampling(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	if _, err := h.planService.GetPlan(r.Context(), planID); err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	plan, err := h.sampler.Stop(r.Context(), planID, time.Now())
+	if err != nil {
+		h.logger.Error("Failed to stop debug sampling", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to stop debug sampling", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, plan)
+}
+
+// GetDebugSamples returns a plan's sampled requests
+// @Summary Get plan debug samples
+// @Description The plan's sampling window and its sampled requests, oldest first. Samples are kept in memory and lost on restart.
+// @Tags plans
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Success 200 {object} domain.DebugSamplesResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/debug-samples [get]
+func (h *DebugSamplingHandler) GetDebugSamples(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	samples, err := h.sampler.Samples(r.Context(), planID)
+	if err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, samples)
+}
+
+func (h *DebugSamplingHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *DebugSamplingHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
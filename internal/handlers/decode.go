@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// decodeJSON decodes a request body holding a single JSON value into dst.
+// Unknown fields, trailing data and bodies over the server's size limit are
+// rejected with errors that say what is wrong. An empty body returns io.EOF
+// unwrapped, which handlers with optional bodies ignore.
+func decodeJSON(r *http.Request, dst interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		return describeDecodeError(err)
+	}
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		if err == nil {
+			return fmt.Errorf("body must contain a single JSON value")
+		}
+		return describeDecodeError(err)
+	}
+	return nil
+}
+
+func describeDecodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var sizeErr *http.MaxBytesError
+
+	switch {
+	case err == io.EOF:
+		return io.EOF
+	case stderrors.As(err, &sizeErr):
+		return fmt.Errorf("body exceeds the %d byte limit", sizeErr.Limit)
+	case stderrors.As(err, &syntaxErr):
+		return fmt.Errorf("malformed JSON at byte %d: %v", syntaxErr.Offset, err)
+	case stderrors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("malformed JSON: body ends unexpectedly")
+	case stderrors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return fmt.Errorf("body must be %s, not %s", jsonKind(typeErr.Type), typeErr.Value)
+		}
+		return fmt.Errorf("field %q must be %s, not %s", typeErr.Field, jsonKind(typeErr.Type), typeErr.Value)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Errorf("unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		return err
+	}
+}
+
+// jsonKind describes the JSON value a Go type decodes from
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Pointer:
+		return jsonKind(t.Elem())
+	default:
+		return t.String()
+	}
+}
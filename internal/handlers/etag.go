@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// notModified sets a weak ETag built from repository version counters and
+// reports whether the request's If-None-Match already matches it, in which
+// case 304 Not Modified has been written. Versions must be read before the
+// data they describe, so a concurrent write can only make the tag stale.
+func notModified(w http.ResponseWriter, r *http.Request, versions ...uint64) bool {
+	parts := make([]string, len(versions))
+	for i, v := range versions {
+		parts[i] = fmt.Sprint(v)
+	}
+	etag := `W/"` + strings.Join(parts, "-") + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
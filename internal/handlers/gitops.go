@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// GitOpsHandler exposes the GitOps controller
+type GitOpsHandler struct {
+	gitops *service.GitOpsController
+	logger *zap.Logger
+}
+
+// NewGitOpsHandler creates a new GitOps handler
+func NewGitOpsHandler(gitops *service.GitOpsController, logger *zap.Logger) *GitOpsHandler {
+	return &GitOpsHandler{
+		gitops: gitops,
+		logger: logger,
+	}
+}
+
+// GetReport returns the report of the latest GitOps sync
+// @Summary Get GitOps report
+// @Description The changes the latest sync found and applied; ?format=markdown renders it for a pull request comment
+// @Tags admin
+// @Produce json,plain
+// @Param format query string false "json (default) or markdown"
+// @Success 200 {object} domain.GitOpsReport
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/gitops [get]
+func (h *GitOpsHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.gitops.LastReport(r.Context())
+	if err != nil {
+		if stderrors.Is(err, service.ErrGitOpsNotSynced) {
+			h.respondWithError(w, http.StatusNotFound, "GitOps has not synced yet", err)
+			return
+		}
+		h.logger.Error("Failed to get GitOps report", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to get GitOps report", err)
+		return
+	}
+
+	h.respondWithReport(w, r, http.StatusOK, report)
+}
+
+// Sync pulls the GitOps repository and reconciles it now
+// @Summary Sync GitOps repository
+// @Description Pulls the repository and applies its differences from the live state, or only reports them with gitops.dry_run
+// @Tags admin
+// @Produce json,plain
+// @Param format query string false "json (default) or markdown"
+// @Success 200 {object} domain.GitOpsReport
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 502 {object} domain.GitOpsReport
+// @Security BearerAuth
+// @Router /admin/gitops/sync [post]
+func (h *GitOpsHandler) Sync(w http.ResponseWriter, r *http.Request) {
+	if !h.gitops.Enabled() {
+		h.respondWithError(w, http.StatusConflict, "GitOps is not enabled", nil)
+		return
+	}
+
+	report, err := h.gitops.Sync(r.Context())
+	if err != nil {
+		if report == nil {
+			h.logger.Error("Failed to sync GitOps repository", zap.Error(err))
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to sync GitOps repository", err)
+			return
+		}
+		// The repository could not be pulled or parsed
+		h.respondWithReport(w, r, http.StatusBadGateway, report)
+		return
+	}
+
+	h.respondWithReport(w, r, http.StatusOK, report)
+}
+
+// respondWithReport writes a report as JSON or Markdown
+func (h *GitOpsHandler) respondWithReport(w http.ResponseWriter, r *http.Request, statusCode int, report *domain.GitOpsReport) {
+	if r.URL.Query().Get("format") == "markdown" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.WriteHeader(statusCode)
+		if _, err := w.Write([]byte(service.RenderGitOpsReport(report))); err != nil {
+			h.logger.Error("Failed to write GitOps report", zap.Error(err))
+		}
+		return
+	}
+
+	h.respondWithJSON(w, statusCode, report)
+}
+
+// Helper methods
+func (h *GitOpsHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *GitOpsHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
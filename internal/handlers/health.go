@@ -8,15 +8,30 @@ import (
 	"go.uber.org/zap"
 )
 
+// ReadinessChecker reports whether the application may admit traffic
+type ReadinessChecker interface {
+	IsReady() bool
+	State() string
+}
+
+// BinaryStatus reports the detected 3proxy version and any version check error
+type BinaryStatus interface {
+	Status() (string, error)
+}
+
 // HealthHandler handles health check endpoints
 type HealthHandler struct {
-	logger *zap.Logger
+	logger    *zap.Logger
+	readiness ReadinessChecker
+	binary    BinaryStatus
 }
 
 // NewHealthHandler creates a new health handler
-func NewHealthHandler(logger *zap.Logger) *HealthHandler {
+func NewHealthHandler(logger *zap.Logger, readiness ReadinessChecker, binary BinaryStatus) *HealthHandler {
 	return &HealthHandler{
-		logger: logger,
+		logger:    logger,
+		readiness: readiness,
+		binary:    binary,
 	}
 }
 
@@ -30,9 +45,10 @@ type HealthResponse struct {
 
 // ReadinessResponse represents the readiness check response
 type ReadinessResponse struct {
-	Status    string                 `json:"status"`
-	Timestamp time.Time              `json:"timestamp"`
-	Checks    map[string]CheckResult `json:"checks"`
+	Status       string                 `json:"status"`
+	Timestamp    time.Time              `json:"timestamp"`
+	ProxyVersion string                 `json:"proxy_version,omitempty"`
+	Checks       map[string]CheckResult `json:"checks"`
 }
 
 // CheckResult represents a single health check result
@@ -78,6 +94,13 @@ func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
 	checks := make(map[string]CheckResult)
 	allHealthy := true
 
+	// Check application lifecycle (initialization and reconciliation)
+	lifecycleResult := h.checkLifecycle()
+	checks["lifecycle"] = lifecycleResult
+	if lifecycleResult.Status != "healthy" {
+		allHealthy = false
+	}
+
 	// Check database connectivity
 	dbResult := h.checkDatabase()
 	checks["database"] = dbResult
@@ -99,6 +122,13 @@ func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
 		allHealthy = false
 	}
 
+	// Check the 3proxy binary version
+	binaryResult, proxyVersion := h.checkProxyBinary()
+	checks["proxy_binary"] = binaryResult
+	if binaryResult.Status != "healthy" {
+		allHealthy = false
+	}
+
 	// Check disk space
 	diskResult := h.checkDiskSpace()
 	checks["disk_space"] = diskResult
@@ -121,9 +151,10 @@ func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := ReadinessResponse{
-		Status:    status,
-		Timestamp: time.Now(),
-		Checks:    checks,
+		Status:       status,
+		Timestamp:    time.Now(),
+		ProxyVersion: proxyVersion,
+		Checks:       checks,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -134,6 +165,21 @@ func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// checkLifecycle verifies the application has finished starting up
+func (h *HealthHandler) checkLifecycle() CheckResult {
+	if h.readiness == nil || h.readiness.IsReady() {
+		return CheckResult{
+			Status:  "healthy",
+			Message: "Initialization complete",
+		}
+	}
+
+	return CheckResult{
+		Status:  "unhealthy",
+		Message: "Application is " + h.readiness.State(),
+	}
+}
+
 // checkDatabase verifies database connectivity
 func (h *HealthHandler) checkDatabase() CheckResult {
 	// For JSON file storage, check if the file is accessible
@@ -164,6 +210,23 @@ func (h *HealthHandler) checkProxyProcesses() CheckResult {
 	}
 }
 
+// checkProxyBinary reports the 3proxy version found at startup
+func (h *HealthHandler) checkProxyBinary() (CheckResult, string) {
+	if h.binary == nil {
+		return CheckResult{Status: "healthy", Message: "3proxy version not checked"}, ""
+	}
+
+	version, err := h.binary.Status()
+	if err != nil {
+		return CheckResult{Status: "unhealthy", Message: err.Error()}, version
+	}
+	if version == "" {
+		return CheckResult{Status: "healthy", Message: "3proxy version not checked yet"}, ""
+	}
+
+	return CheckResult{Status: "healthy", Message: "3proxy " + version}, version
+}
+
 // checkDiskSpace verifies available disk space
 func (h *HealthHandler) checkDiskSpace() CheckResult {
 	// Check if there's sufficient disk space for logs and configs
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// IncidentHandler handles incidents opened by monitoring
+type IncidentHandler struct {
+	incidents *service.IncidentService
+	logger    *zap.Logger
+}
+
+// NewIncidentHandler creates a new incident handler
+func NewIncidentHandler(incidents *service.IncidentService, logger *zap.Logger) *IncidentHandler {
+	return &IncidentHandler{
+		incidents: incidents,
+		logger:    logger,
+	}
+}
+
+// GetIncidents lists incidents
+// @Summary List incidents
+// @Description Incidents opened by health monitoring, newest first
+// @Tags admin
+// @Produce json
+// @Param status query string false "open, acknowledged or resolved"
+// @Success 200 {array} domain.Incident
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/incidents [get]
+func (h *IncidentHandler) GetIncidents(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", domain.IncidentOpen, domain.IncidentAcknowledged, domain.IncidentResolved:
+	default:
+		h.respondWithError(w, http.StatusBadRequest, "Invalid status filter", nil)
+		return
+	}
+
+	incidents, err := h.incidents.List(r.Context(), status)
+	if err != nil {
+		h.logger.Error("Failed to list incidents", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to list incidents", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, incidents)
+}
+
+// GetIncident returns an incident with its timeline
+// @Summary Get incident
+// @Description An incident with the plans and instances it affected and its full timeline
+// @Tags admin
+// @Produce json
+// @Param id path string true "Incident ID"
+// @Success 200 {object} domain.Incident
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/incidents/{id} [get]
+func (h *IncidentHandler) GetIncident(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid incident ID", err)
+		return
+	}
+
+	incident, err := h.incidents.Get(r.Context(), id)
+	if err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Incident not found", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, incident)
+}
+
+// AcknowledgeIncident marks an open incident as being worked on
+// @Summary Acknowledge incident
+// @Description Acknowledge an open incident, optionally with a note. Public notes appear on the status page.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Incident ID"
+// @Param request body domain.IncidentNoteRequest false "Note"
+// @Success 200 {object} domain.Incident
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/incidents/{id}/acknowledge [post]
+func (h *IncidentHandler) AcknowledgeIncident(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, false, "acknowledge", h.incidents.Acknowledge)
+}
+
+// ResolveIncident closes an incident
+// @Summary Resolve incident
+// @Description Resolve an incident, optionally with a note. Incidents also resolve themselves once every affected instance recovers.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Incident ID"
+// @Param request body domain.IncidentNoteRequest false "Note"
+// @Success 200 {object} domain.Incident
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/incidents/{id}/resolve [post]
+func (h *IncidentHandler) ResolveIncident(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, false, "resolve", h.incidents.Resolve)
+}
+
+// AnnotateIncident adds a note to an incident's timeline
+// @Summary Annotate incident
+// @Description Add a note to the incident's timeline. Public notes appear on the status page.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Incident ID"
+// @Param request body domain.IncidentNoteRequest true "Note"
+// @Success 200 {object} domain.Incident
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/incidents/{id}/annotations [post]
+func (h *IncidentHandler) AnnotateIncident(w http.ResponseWriter, r *http.Request) {
+	h.change(w, r, true, "annotate", h.incidents.Annotate)
+}
+
+// change decodes a note and applies an incident change. The body may be
+// empty unless a message is required.
+func (h *IncidentHandler) change(
+	w http.ResponseWriter,
+	r *http.Request,
+	messageRequired bool,
+	action string,
+	apply func(ctx context.Context, id uuid.UUID, note *domain.IncidentNoteRequest, now time.Time) (*domain.Incident, error),
+) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid incident ID", err)
+		return
+	}
+
+	var note domain.IncidentNoteRequest
+	if err := decodeJSON(r, &note); err != nil && !stderrors.Is(err, io.EOF) {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	note.Message = strings.TrimSpace(note.Message)
+	if messageRequired && note.Message == "" {
+		h.respondWithError(w, http.StatusBadRequest, "Message is required", nil)
+		return
+	}
+
+	if _, err := h.incidents.Get(r.Context(), id); err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Incident not found", err)
+		return
+	}
+
+	incident, err := apply(r.Context(), id, &note, time.Now())
+	if err != nil {
+		if stderrors.Is(err, service.ErrIncidentTransition) {
+			h.respondWithError(w, http.StatusConflict, "Failed to "+action+" incident", err)
+			return
+		}
+		h.logger.Error("Failed to "+action+" incident", zap.String("incident_id", id.String()), zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to "+action+" incident", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, incident)
+}
+
+func (h *IncidentHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *IncidentHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
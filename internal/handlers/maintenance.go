@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// MaintenanceHandler manages the provider maintenance calendar
+type MaintenanceHandler struct {
+	maintenance *service.MaintenanceService
+	logger      *zap.Logger
+}
+
+// NewMaintenanceHandler creates a new maintenance handler
+func NewMaintenanceHandler(maintenance *service.MaintenanceService, logger *zap.Logger) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		maintenance: maintenance,
+		logger:      logger,
+	}
+}
+
+// GetMaintenance lists maintenance windows
+// @Summary List provider maintenance windows
+// @Description Scheduled provider maintenance registered by operators or read from the configured status feeds, by start time. Alerts for the plan types a window covers are suppressed while it is in progress.
+// @Tags admin
+// @Produce json
+// @Param active query bool false "Only windows in progress now"
+// @Success 200 {array} domain.MaintenanceWindow
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/maintenance [get]
+func (h *MaintenanceHandler) GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	active := r.URL.Query().Get("active") == "true"
+
+	windows, err := h.maintenance.List(r.Context(), active, time.Now())
+	if err != nil {
+		h.respondWithMaintenanceError(w, "list maintenance windows", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, windows)
+}
+
+// CreateMaintenance registers a maintenance window
+// @Summary Register provider maintenance
+// @Description Registers a maintenance window of a provider, limited to some of its plan types when plan_types is set
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body domain.CreateMaintenanceRequest true "Maintenance window"
+// @Success 201 {object} domain.MaintenanceWindow
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/maintenance [post]
+func (h *MaintenanceHandler) CreateMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req domain.CreateMaintenanceRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	window, err := h.maintenance.Create(r.Context(), &req, time.Now())
+	if err != nil {
+		h.respondWithMaintenanceError(w, "register maintenance window", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, window)
+}
+
+// DeleteMaintenance removes a maintenance window
+// @Summary Delete provider maintenance
+// @Description Removes a maintenance window. A window read from a feed returns at the next sync while the feed still lists it.
+// @Tags admin
+// @Param id path string true "Maintenance window ID"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/maintenance/{id} [delete]
+func (h *MaintenanceHandler) DeleteMaintenance(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid maintenance window ID", err)
+		return
+	}
+
+	if err := h.maintenance.Delete(r.Context(), id); err != nil {
+		h.respondWithMaintenanceError(w, "delete maintenance window", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondWithMaintenanceError maps maintenance service errors to responses
+func (h *MaintenanceHandler) respondWithMaintenanceError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case stderrors.Is(err, service.ErrMaintenanceNotFound):
+		h.respondWithError(w, http.StatusNotFound, "Maintenance window not found", err)
+	case stderrors.Is(err, service.ErrInvalidMaintenance):
+		h.respondWithError(w, http.StatusBadRequest, "Invalid maintenance window", err)
+	default:
+		h.logger.Error("Failed to "+action, zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to "+action, err)
+	}
+}
+
+func (h *MaintenanceHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *MaintenanceHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+const (
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	prometheusContentType  = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// MetricsHandler serves customer-scoped usage metrics and the operator's
+// process metrics
+type MetricsHandler struct {
+	metrics  *service.CustomerMetrics
+	operator *service.OperatorMetrics
+	logger   *zap.Logger
+}
+
+// NewMetricsHandler creates a new metrics handler
+func NewMetricsHandler(metrics *service.CustomerMetrics, operator *service.OperatorMetrics, logger *zap.Logger) *MetricsHandler {
+	return &MetricsHandler{
+		metrics:  metrics,
+		operator: operator,
+		logger:   logger,
+	}
+}
+
+// GetCustomerMetrics exposes one customer's plan usage for Prometheus scraping
+// @Summary Customer metrics
+// @Description Prometheus/OpenMetrics text with only the token's customer's plans; the token is the credential
+// @Tags metrics
+// @Produce plain
+// @Param token path string true "Customer metrics token"
+// @Success 200 {string} string
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Router /metrics/customer/{token} [get]
+func (h *MetricsHandler) GetCustomerMetrics(w http.ResponseWriter, r *http.Request) {
+	if !h.metrics.Enabled() {
+		h.respondWithError(w, http.StatusNotFound, "Customer metrics are disabled", nil)
+		return
+	}
+
+	customerID, err := h.metrics.CustomerForToken(chi.URLParam(r, "token"))
+	if err != nil {
+		h.respondWithError(w, http.StatusUnauthorized, "Invalid metrics token", nil)
+		return
+	}
+
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", openMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", prometheusContentType)
+	}
+
+	if err := h.metrics.Render(r.Context(), customerID, w, openMetrics); err != nil {
+		h.logger.Error("Failed to render customer metrics",
+			zap.String("customer_id", customerID),
+			zap.Error(err))
+	}
+}
+
+// GetOperatorMetrics exposes process-wide metrics, such as provider schema
+// drift, for the operator's Prometheus
+// @Summary Operator metrics
+// @Description Prometheus/OpenMetrics text with process-wide counters, including oceanproxy_provider_schema_drift_total, and upstream latency histograms
+// @Tags admin
+// @Produce plain
+// @Success 200 {string} string
+// @Security BearerAuth
+// @Router /admin/metrics [get]
+func (h *MetricsHandler) GetOperatorMetrics(w http.ResponseWriter, r *http.Request) {
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", openMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", prometheusContentType)
+	}
+
+	if err := h.operator.Render(w, openMetrics); err != nil {
+		h.logger.Error("Failed to render operator metrics", zap.Error(err))
+	}
+}
+
+// IssueCustomerToken returns the metrics token and scrape path for a customer
+// @Summary Issue customer metrics token
+// @Description Returns the signed token for a customer's /metrics/customer/{token} endpoint
+// @Tags admin
+// @Produce json
+// @Param customer_id path string true "Customer ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/customers/{customer_id}/metrics-token [get]
+func (h *MetricsHandler) IssueCustomerToken(w http.ResponseWriter, r *http.Request) {
+	customerID := chi.URLParam(r, "customer_id")
+
+	token, err := h.metrics.IssueToken(customerID)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Failed to issue metrics token", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{
+		"customer_id": customerID,
+		"token":       token,
+		"path":        "/metrics/customer/" + token,
+	})
+}
+
+// Helper methods
+func (h *MetricsHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *MetricsHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
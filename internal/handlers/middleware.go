@@ -3,14 +3,21 @@ package handlers
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/je265/oceanproxy/internal/pkg/errors"
+	"github.com/je265/oceanproxy/pkg/config"
 )
 
 // AuthMiddleware provides bearer token authentication - TEMPORARILY ACCEPTS ANY TOKEN
@@ -79,58 +86,237 @@ func NewAuthMiddleware(bearerToken string, logger *zap.Logger) func(http.Handler
 	}
 }
 
-// RateLimitMiddleware provides basic rate limiting
-func NewRateLimitMiddleware(requestsPerMinute int, logger *zap.Logger) func(http.Handler) http.Handler {
-	// Simple in-memory rate limiter (for production, use Redis or similar)
-	type clientData struct {
-		requests  int
-		resetTime time.Time
+// ClientCertMiddleware requires a verified TLS client certificate (mTLS)
+func NewClientCertMiddleware(logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				logger.Warn("Missing or unverified client certificate",
+					zap.String("path", r.URL.Path),
+					zap.String("remote_addr", r.RemoteAddr))
+
+				respondWithError(w, http.StatusForbidden, "Client certificate required", nil)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
 	}
+}
+
+// ReadinessMiddleware rejects mutating requests until the application is ready
+func NewReadinessMiddleware(readiness ReadinessChecker, logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !readiness.IsReady() {
+				logger.Warn("Rejecting request before application is ready",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.String("state", readiness.State()))
+
+				w.Header().Set("Retry-After", "5")
+				respondWithError(w, http.StatusServiceUnavailable, "Service is "+readiness.State()+", try again shortly", nil)
+				return
+			}
 
-	clients := make(map[string]*clientData)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimits holds the per-minute budgets of the rate limit middlewares
+// and the operator bypass token. Set changes them while the server runs;
+// budgets already counted this minute are kept.
+type RateLimits struct {
+	create atomic.Int64
+	write  atomic.Int64
+	read   atomic.Int64
+	public atomic.Int64
+	bypass atomic.Value // string
+}
+
+// NewRateLimits creates rate limit budgets from cfg
+func NewRateLimits(cfg config.RateLimit) *RateLimits {
+	limits := &RateLimits{}
+	limits.Set(cfg)
+	return limits
+}
+
+// Set replaces every budget
+func (l *RateLimits) Set(cfg config.RateLimit) {
+	l.create.Store(int64(cfg.Create))
+	l.write.Store(int64(cfg.Write))
+	l.read.Store(int64(cfg.Read))
+	l.public.Store(int64(cfg.Public))
+	l.bypass.Store(cfg.BypassToken)
+}
+
+// Create returns the plan creation budget
+func (l *RateLimits) Create() int { return int(l.create.Load()) }
+
+// Write returns the budget of other mutations
+func (l *RateLimits) Write() int { return int(l.write.Load()) }
+
+// Read returns the budget of reads
+func (l *RateLimits) Read() int { return int(l.read.Load()) }
+
+// Public returns the budget of unauthenticated routes
+func (l *RateLimits) Public() int { return int(l.public.Load()) }
+
+// BypassToken returns the operator token that skips every budget
+func (l *RateLimits) BypassToken() string { return l.bypass.Load().(string) }
+
+// RateLimitMiddleware limits each client IP to requestsPerMinute() requests
+// in a fixed one-minute window; 0 disables the limit. Requests carrying the
+// operator bypass token in X-RateLimit-Bypass are not limited; the admin
+// bearer token does not exempt a request, as every API client holds it.
+// Each middleware keeps its own budget.
+func NewRateLimitMiddleware(requestsPerMinute func() int, bypassToken func() string, logger *zap.Logger) func(http.Handler) http.Handler {
+	// Simple in-memory rate limiter (for production, use Redis or similar)
+	var mu sync.Mutex
+	clients := make(map[string]*rateLimitClient)
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := requestsPerMinute()
+			if limit <= 0 || isBypassToken(r, bypassToken()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			clientIP := getClientIP(r)
 			now := time.Now()
 
-			// Clean up old entries periodically
-			if len(clients) > 1000 {
-				for ip, data := range clients {
-					if now.After(data.resetTime) {
-						delete(clients, ip)
-					}
-				}
-			}
-
+			mu.Lock()
 			client, exists := clients[clientIP]
 			if !exists || now.After(client.resetTime) {
-				clients[clientIP] = &clientData{
-					requests:  1,
-					resetTime: now.Add(time.Minute),
+				if !exists && len(clients) >= maxRateLimitClients {
+					evictRateLimitClient(clients, now)
 				}
-				next.ServeHTTP(w, r)
-				return
+				client = &rateLimitClient{resetTime: now.Add(time.Minute)}
+				clients[clientIP] = client
+			}
+			limited := client.requests >= limit
+			if !limited {
+				client.requests++
+			}
+			// A lowered limit can leave a client over budget
+			remaining := limit - client.requests
+			if remaining < 0 {
+				remaining = 0
 			}
+			resetTime := client.resetTime
+			mu.Unlock()
 
-			if client.requests >= requestsPerMinute {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetTime.Unix(), 10))
+
+			if limited {
 				logger.Warn("Rate limit exceeded",
 					zap.String("client_ip", clientIP),
-					zap.Int("requests", client.requests),
+					zap.Int("limit", limit),
 					zap.String("path", r.URL.Path))
 
-				w.Header().Set("X-RateLimit-Limit", string(rune(requestsPerMinute)))
-				w.Header().Set("X-RateLimit-Remaining", "0")
-				w.Header().Set("X-RateLimit-Reset", string(rune(client.resetTime.Unix())))
+				retryAfter := int(math.Ceil(resetTime.Sub(now).Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(errors.NewRateLimitError("Rate limit exceeded, retry in " + strconv.Itoa(retryAfter) + "s"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// maxRateLimitClients caps the clients a rate limit middleware tracks
+const maxRateLimitClients = 10000
+
+// rateLimitClient is one client's requests in its current window
+type rateLimitClient struct {
+	requests  int
+	resetTime time.Time
+}
+
+// evictRateLimitClient makes room for a new client: it drops every expired
+// window, or the one closest to expiring when none has
+func evictRateLimitClient(clients map[string]*rateLimitClient, now time.Time) {
+	oldest := ""
+	for ip, data := range clients {
+		if now.After(data.resetTime) {
+			delete(clients, ip)
+		} else if oldest == "" || data.resetTime.Before(clients[oldest].resetTime) {
+			oldest = ip
+		}
+	}
+	if len(clients) >= maxRateLimitClients && oldest != "" {
+		delete(clients, oldest)
+	}
+}
+
+// SplitByMethod sends reads (GET, HEAD, OPTIONS) through read and every
+// other request through write
+func SplitByMethod(read, write func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		reads := read(next)
+		writes := write(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				reads.ServeHTTP(w, r)
+			default:
+				writes.ServeHTTP(w, r)
+			}
+		})
+	}
+}
 
-				respondWithError(w, http.StatusTooManyRequests, "Rate limit exceeded", nil)
+// BodyLimitMiddleware caps request bodies at maxBytes; reading past the cap
+// fails. 0 disables the cap.
+func NewBodyLimitMiddleware(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if maxBytes <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				json.NewEncoder(w).Encode(errors.NewValidationError("Request body too large",
+					"body exceeds the "+strconv.FormatInt(maxBytes, 10)+" byte limit"))
 				return
 			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-			client.requests++
-			w.Header().Set("X-RateLimit-Limit", string(rune(requestsPerMinute)))
-			w.Header().Set("X-RateLimit-Remaining", string(rune(requestsPerMinute-client.requests)))
-			w.Header().Set("X-RateLimit-Reset", string(rune(client.resetTime.Unix())))
+// DeprecationMiddleware marks responses of deprecated routes with the
+// Deprecation header, a Sunset header when sunset is set (RFC 8594) and a
+// link to the successor route
+func NewDeprecationMiddleware(sunset time.Time, successor string, logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			if !sunset.IsZero() {
+				w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			if successor != "" {
+				w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+			}
+
+			logger.Debug("Deprecated route called",
+				zap.String("path", r.URL.Path),
+				zap.String("remote_addr", getClientIP(r)))
 
 			next.ServeHTTP(w, r)
 		})
@@ -228,25 +414,84 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// getClientIP returns the peer address of the request, which
+// NewTrustedProxyMiddleware has replaced with the forwarded client address
+// for requests from a trusted proxy
 func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		parts := strings.Split(xff, ",")
-		return strings.TrimSpace(parts[0])
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
 	}
+	return r.RemoteAddr
+}
 
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+// NewTrustedProxyMiddleware sets r.RemoteAddr to the client a trusted proxy
+// forwarded the request for. X-Forwarded-For is read from the right,
+// skipping trusted proxies, so a client cannot pick its address by sending
+// the header itself; X-Real-IP is used when there is none. Requests from
+// other peers keep their own address and their headers are ignored.
+func NewTrustedProxyMiddleware(trusted []string) func(http.Handler) http.Handler {
+	var networks []*net.IPNet
+	for _, entry := range trusted {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			networks = append(networks, network)
+		} else if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		}
+	}
+	isTrusted := func(addr string) bool {
+		ip := net.ParseIP(addr)
+		for _, network := range networks {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+		}
+		return false
 	}
 
-	// Fall back to remote address
-	ip := r.RemoteAddr
-	if colonIndex := strings.LastIndex(ip, ":"); colonIndex != -1 {
-		ip = ip[:colonIndex]
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isTrusted(getClientIP(r)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client := ""
+			if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+				hops := strings.Split(xff, ",")
+				for i := len(hops) - 1; i >= 0; i-- {
+					hop := strings.TrimSpace(hops[i])
+					if net.ParseIP(hop) == nil {
+						break
+					}
+					client = hop
+					if !isTrusted(hop) {
+						break
+					}
+				}
+			} else if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(xri) != nil {
+				client = xri
+			}
+			if client != "" {
+				r.RemoteAddr = net.JoinHostPort(client, "0")
+			}
+
+			next.ServeHTTP(w, r)
+		})
 	}
+}
 
-	return ip
+// isBypassToken reports whether the request carries the configured rate
+// limit bypass token
+func isBypassToken(r *http.Request, bypassToken string) bool {
+	if bypassToken == "" {
+		return false
+	}
+	token := r.Header.Get("X-RateLimit-Bypass")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(bypassToken)) == 1
 }
 
 func isPublicEndpoint(path string) bool {
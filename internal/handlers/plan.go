@@ -3,8 +3,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -35,14 +37,20 @@ func NewPlanHandler(planService service.PlanService, logger *zap.Logger) *PlanHa
 // @Accept json
 // @Produce json
 // @Param request body domain.CreatePlanRequest true "Plan creation request"
+// @Param format query string false "Also render endpoints in this format (url, host:port:user:pass, user:pass@host:port, curl, python, scrapy, puppeteer, env)"
 // @Success 201 {object} domain.CreatePlanResponse
 // @Failure 400 {object} errors.ErrorResponse
 // @Failure 500 {object} errors.ErrorResponse
 // @Security BearerAuth
 // @Router /plans [post]
 func (h *PlanHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	format, ok := h.endpointFormat(w, r)
+	if !ok {
+		return
+	}
+
     var req domain.CreatePlanRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+    if err := decodeJSON(r, &req); err != nil {
 		h.logger.Error("Invalid request body", zap.Error(err))
 		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
 		return
@@ -52,21 +60,60 @@ func (h *PlanHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
         // Proxies.fo generates credentials; ignore any provided values
         req.Username = ""
         req.Password = ""
-    } else if req.Provider == domain.ProviderNettify {
-        // Nettify requires custom username/password
-        if req.Username == "" || req.Password == "" {
-            h.respondWithError(w, http.StatusBadRequest, "username and password are required for nettify provider", nil)
-            return
-        }
     }
 	response, err := h.planService.CreatePlan(r.Context(), &req)
 	if err != nil {
 		h.logger.Error("Failed to create plan", zap.Error(err))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to create plan", err)
+		h.respondWithCreateError(w, err)
 		return
 	}
 
-	h.respondWithJSON(w, http.StatusCreated, response)
+	h.respondWithCreated(w, response, format)
+}
+
+// GetPlanEndpoints returns a plan's customer-facing endpoints
+// @Summary Get plan endpoints
+// @Description Get a plan's proxy endpoints, optionally rendered for a tool (curl, Python requests, Scrapy, Puppeteer, shell)
+// @Tags plans
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param format query string false "Endpoint format (url, host:port:user:pass, user:pass@host:port, curl, python, scrapy, puppeteer, env)"
+// @Success 200 {object} domain.PlanEndpointsResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/endpoints [get]
+func (h *PlanHandler) GetPlanEndpoints(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	format, ok := h.endpointFormat(w, r)
+	if !ok {
+		return
+	}
+
+	endpoints, err := h.planService.GetPlanEndpoints(r.Context(), planID)
+	if err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	response := &domain.PlanEndpointsResponse{
+		PlanID:  planID.String(),
+		Format:  format,
+		Proxies: endpoints,
+	}
+	if format != "" {
+		if response.Formatted, err = domain.FormatEndpoints(endpoints, format); err != nil {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to format endpoints", err)
+			return
+		}
+	}
+
+	h.respondWithJSON(w, http.StatusOK, response)
 }
 
 // GetPlan retrieves a specific proxy plan
@@ -104,15 +151,26 @@ func (h *PlanHandler) GetPlan(w http.ResponseWriter, r *http.Request) {
 // @Tags plans
 // @Produce json
 // @Param customer_id query string false "Customer ID to filter by"
+// @Param If-None-Match header string false "ETag from a previous response"
 // @Success 200 {array} domain.ProxyPlan
+// @Success 304 "Not modified since the ETag was issued"
 // @Failure 500 {object} errors.ErrorResponse
 // @Security BearerAuth
 // @Router /plans [get]
 func (h *PlanHandler) GetPlans(w http.ResponseWriter, r *http.Request) {
 	customerID := r.URL.Query().Get("customer_id")
 
+	version, err := h.planService.PlansVersion(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to get plans version", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to get plans", err)
+		return
+	}
+	if notModified(w, r, version) {
+		return
+	}
+
 	var plans []*domain.ProxyPlan
-	var err error
 
 	if customerID != "" {
 		plans, err = h.planService.GetPlansByCustomer(r.Context(), customerID)
@@ -129,150 +187,507 @@ func (h *PlanHandler) GetPlans(w http.ResponseWriter, r *http.Request) {
 	h.respondWithJSON(w, http.StatusOK, plans)
 }
 
-// DeletePlan deletes a proxy plan
-// @Summary Delete a proxy plan
-// @Description Delete a proxy plan and all associated instances
+// GetPlanEvents returns a plan's event timeline
+// @Summary Get plan events
+// @Description Get the append-only event history of a plan, oldest first. Events of deleted plans remain available.
 // @Tags plans
+// @Produce json
 // @Param id path string true "Plan ID"
-// @Success 204
+// @Success 200 {array} domain.PlanEvent
 // @Failure 400 {object} errors.ErrorResponse
 // @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
 // @Security BearerAuth
-// @Router /plans/{id} [delete]
-func (h *PlanHandler) DeletePlan(w http.ResponseWriter, r *http.Request) {
-	planIDStr := chi.URLParam(r, "id")
-	planID, err := uuid.Parse(planIDStr)
+// @Router /plans/{id}/events [get]
+func (h *PlanHandler) GetPlanEvents(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
 		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
 		return
 	}
 
-	if err := h.planService.DeletePlan(r.Context(), planID); err != nil {
-		h.logger.Error("Failed to delete plan", zap.Error(err))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to delete plan", err)
+	events, err := h.planService.GetPlanEvents(r.Context(), planID)
+	if err != nil {
+		h.logger.Error("Failed to get plan events", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to get plan events", err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	// A plan with no history at all was never created here
+	if len(events) == 0 {
+		if _, err := h.planService.GetPlan(r.Context(), planID); err != nil {
+			h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+			return
+		}
+	}
+
+	h.respondWithJSON(w, http.StatusOK, events)
 }
 
-// CreateProxiesFoPlan creates a plan using Proxies.fo provider (legacy endpoint)
-// @Summary Create Proxies.fo plan
-// @Description Create a proxy plan using Proxies.fo provider
+// TopUpPlan adds bandwidth to a plan, reactivating it if it was exhausted
+// @Summary Top up a proxy plan
+// @Description Add bandwidth to the plan's upstream provider account; exhausted plans become active again
 // @Tags plans
-// @Accept application/x-www-form-urlencoded
+// @Accept json
 // @Produce json
-// @Param reseller formData string true "Plan type (residential, datacenter, isp)"
-// @Param bandwidth formData int true "Bandwidth in GB"
-// @Param username formData string true "Username"
-// @Param password formData string true "Password"
-// @Success 201 {object} domain.CreatePlanResponse
+// @Param id path string true "Plan ID"
+// @Param request body domain.TopUpPlanRequest true "Bandwidth to add (GB)"
+// @Success 200 {object} domain.ProxyPlan
 // @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
 // @Failure 500 {object} errors.ErrorResponse
 // @Security BearerAuth
-// @Router /plan [post]
-func (h *PlanHandler) CreateProxiesFoPlan(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		h.respondWithError(w, http.StatusBadRequest, "Failed to parse form", err)
+// @Router /plans/{id}/topup [post]
+func (h *PlanHandler) TopUpPlan(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
 		return
 	}
 
-	customerID := r.FormValue("customer_id")
-	if customerID == "" {
-		customerID = "legacy_customer_" + strconv.FormatInt(time.Now().Unix(), 10)
+	var req domain.TopUpPlanRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if req.Bandwidth <= 0 {
+		h.respondWithError(w, http.StatusBadRequest, "bandwidth must be positive", nil)
+		return
 	}
 
-	req := domain.CreatePlanRequest{
-		CustomerID: customerID,
-		PlanType:   r.FormValue("reseller"),
-		Provider:   domain.ProviderProxiesFo,
-		Region:     domain.RegionUSA, // Default to USA for legacy
-		Username:   r.FormValue("username"),
-		Password:   r.FormValue("password"),
+	if _, err := h.planService.GetPlan(r.Context(), planID); err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
 	}
 
-	bandwidth, err := strconv.Atoi(r.FormValue("bandwidth"))
+	plan, err := h.planService.TopUpPlan(r.Context(), planID, req.Bandwidth)
 	if err != nil {
-		h.respondWithError(w, http.StatusBadRequest, "Invalid bandwidth", err)
+		h.logger.Error("Failed to top up plan", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to top up plan", err)
 		return
 	}
-	req.Bandwidth = bandwidth
 
-	response, err := h.planService.CreatePlan(r.Context(), &req)
+	h.respondWithJSON(w, http.StatusOK, plan)
+}
+
+// SetAutoRenew turns automatic renewal of a plan on or off
+// @Summary Set plan auto-renew
+// @Description Enable or disable automatic renewal through the billing integration; enabling clears an abandoned renewal
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param request body domain.AutoRenewRequest true "Auto-renew setting"
+// @Success 200 {object} domain.ProxyPlan
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/auto-renew [put]
+func (h *PlanHandler) SetAutoRenew(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		h.logger.Error("Failed to create Proxies.fo plan", zap.Error(err))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to create plan", err)
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
 		return
 	}
 
-	h.respondWithJSON(w, http.StatusCreated, response)
+	var req domain.AutoRenewRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if _, err := h.planService.GetPlan(r.Context(), planID); err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	plan, err := h.planService.SetAutoRenew(r.Context(), planID, req.AutoRenew)
+	if err != nil {
+		if domain.IsPolicyError(err) {
+			h.respondWithError(w, http.StatusBadRequest, "Auto-renew is not available", err)
+			return
+		}
+		h.logger.Error("Failed to set plan auto-renew", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to set plan auto-renew", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, plan)
 }
 
-// CreateNettifyPlan creates a plan using Nettify provider (legacy endpoint)
-// @Summary Create Nettify plan
-// @Description Create a proxy plan using Nettify provider
+// SetExpiryAction sets what happens to a plan once it expires
+// @Summary Set plan expiry action
+// @Description Choose what the expiry worker does with the plan when it expires: stop its instances and keep it (stop, the default), delete it with its instances and upstream account (delete), or keep it serving as a throttled teaser (downgrade)
 // @Tags plans
-// @Accept application/x-www-form-urlencoded
+// @Accept json
 // @Produce json
-// @Param plan_type formData string true "Plan type (residential, datacenter, mobile)"
-// @Param bandwidth formData int true "Bandwidth in GB"
-// @Param username formData string true "Username"
-// @Param password formData string true "Password"
-// @Success 201 {object} domain.CreatePlanResponse
+// @Param id path string true "Plan ID"
+// @Param request body domain.ExpiryActionRequest true "Expiry action"
+// @Success 200 {object} domain.ProxyPlan
 // @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
 // @Failure 500 {object} errors.ErrorResponse
 // @Security BearerAuth
-// @Router /nettify/plan [post]
-func (h *PlanHandler) CreateNettifyPlan(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		h.respondWithError(w, http.StatusBadRequest, "Failed to parse form", err)
+// @Router /plans/{id}/expiry-action [put]
+func (h *PlanHandler) SetExpiryAction(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
 		return
 	}
 
-	customerID := r.FormValue("customer_id")
-	if customerID == "" {
-		customerID = "legacy_customer_" + strconv.FormatInt(time.Now().Unix(), 10)
+	var req domain.ExpiryActionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
 	}
 
-	req := domain.CreatePlanRequest{
-		CustomerID: customerID,
-		PlanType:   r.FormValue("plan_type"),
-		Provider:   domain.ProviderNettify,
-		Region:     domain.RegionAlpha, // Default to Alpha for Nettify
-		Username:   r.FormValue("username"),
-		Password:   r.FormValue("password"),
+	plan, err := h.planService.SetExpiryAction(r.Context(), planID, req.ExpiryAction)
+	if err != nil {
+		if domain.IsPolicyError(err) {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid expiry action", err)
+			return
+		}
+		h.logger.Error("Failed to set plan expiry action", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to set plan expiry action", err)
+		return
 	}
 
-	bandwidth, err := strconv.Atoi(r.FormValue("bandwidth"))
+	h.respondWithJSON(w, http.StatusOK, plan)
+}
+
+// SetAllowedDestinations replaces a plan's destination allowlist
+// @Summary Set plan allowed destinations
+// @Description Restrict the plan's proxies to the listed host names, wildcard domains, IPs and CIDR networks; every other destination is denied. An empty list lifts the restriction. Running instances are restarted to apply it.
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param request body domain.AllowedDestinationsRequest true "Destination allowlist"
+// @Success 200 {object} domain.ProxyPlan
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/allowed-destinations [put]
+func (h *PlanHandler) SetAllowedDestinations(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
-		h.respondWithError(w, http.StatusBadRequest, "Invalid bandwidth", err)
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
 		return
 	}
-	req.Bandwidth = bandwidth
 
-	response, err := h.planService.CreatePlan(r.Context(), &req)
+	var req domain.AllowedDestinationsRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if _, err := h.planService.GetPlan(r.Context(), planID); err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	plan, err := h.planService.SetAllowedDestinations(r.Context(), planID, req.AllowedDestinations)
 	if err != nil {
-		h.logger.Error("Failed to create Nettify plan", zap.Error(err))
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to create plan", err)
+		if domain.IsPolicyError(err) {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid allowed destinations", err)
+			return
+		}
+		h.logger.Error("Failed to set plan allowed destinations", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to set plan allowed destinations", err)
 		return
 	}
 
-	h.respondWithJSON(w, http.StatusCreated, response)
+	h.respondWithJSON(w, http.StatusOK, plan)
+}
+
+// SetHeaderPolicy replaces a plan's header policy
+// @Summary Set plan header policy
+// @Description Strip and inject headers on the plan's proxied HTTP requests. An empty policy removes it. The 3proxy backend does not apply header policies; it only leaves out forwarding headers such as X-Forwarded-For.
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param request body domain.HeaderPolicy true "Header policy"
+// @Success 200 {object} domain.ProxyPlan
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/headers [put]
+func (h *PlanHandler) SetHeaderPolicy(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	var req domain.HeaderPolicy
+	if err := decodeJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if _, err := h.planService.GetPlan(r.Context(), planID); err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	plan, err := h.planService.SetHeaderPolicy(r.Context(), planID, &req)
+	if err != nil {
+		if domain.IsPolicyError(err) {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid header policy", err)
+			return
+		}
+		h.logger.Error("Failed to set plan header policy", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to set plan header policy", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, plan)
+}
+
+// SetResponseCache turns a plan's response cache off
+// @Summary Set plan response cache
+// @Description Turn a plan's response cache off. The 3proxy backend does not cache, so requests to enable it are rejected with 400 after validation against the proxy.cache limits.
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param request body domain.ResponseCacheRequest true "Response cache settings"
+// @Success 200 {object} domain.ProxyPlan
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/cache [put]
+func (h *PlanHandler) SetResponseCache(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	var req domain.ResponseCacheRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if _, err := h.planService.GetPlan(r.Context(), planID); err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	plan, err := h.planService.SetResponseCache(r.Context(), planID, &req)
+	if err != nil {
+		if domain.IsPolicyError(err) {
+			h.respondWithError(w, http.StatusBadRequest, "Response cache not available", err)
+			return
+		}
+		h.logger.Error("Failed to set plan response cache", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to set plan response cache", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, plan)
+}
+
+// PurgeResponseCache is rejected while no backend caches responses
+// @Summary Purge plan response cache
+// @Description Always 400: the 3proxy backend caches nothing, so there is nothing to purge
+// @Tags plans
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Success 200 {object} domain.ProxyPlan
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/cache/purge [post]
+func (h *PlanHandler) PurgeResponseCache(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	if _, err := h.planService.GetPlan(r.Context(), planID); err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	plan, err := h.planService.PurgeResponseCache(r.Context(), planID)
+	if err != nil {
+		if domain.IsPolicyError(err) {
+			h.respondWithError(w, http.StatusBadRequest, "Response cache not available", err)
+			return
+		}
+		h.logger.Error("Failed to purge plan response cache", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to purge plan response cache", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, plan)
+}
+
+// RotatePassword replaces a plan's proxy password
+// @Summary Rotate plan password
+// @Description Set a new password for a nettify plan, or generate one when the body is empty; running instances are restarted with it
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param request body domain.RotatePasswordRequest false "New password"
+// @Success 200 {object} domain.ProxyPlan
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/rotate-password [post]
+func (h *PlanHandler) RotatePassword(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	var req domain.RotatePasswordRequest
+	if err := decodeJSON(r, &req); err != nil && err != io.EOF {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if _, err := h.planService.GetPlan(r.Context(), planID); err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	plan, err := h.planService.RotatePassword(r.Context(), planID, req.Password)
+	if err != nil {
+		if domain.IsPolicyError(err) {
+			h.respondWithJSON(w, http.StatusBadRequest, errors.NewValidationError("Password rejected", err.Error()))
+			return
+		}
+		h.logger.Error("Failed to rotate plan password", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to rotate plan password", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, plan)
+}
+
+// defaultExpiringWindow is used when GetExpiringPlans is called without ?within
+const defaultExpiringWindow = 72 * time.Hour
+
+// GetExpiringPlans lists active plans that expire within a window
+// @Summary Get expiring proxy plans
+// @Description List active plans expiring within the given window, soonest first, so they can be renewed
+// @Tags plans
+// @Produce json
+// @Param within query string false "Window as a Go duration, e.g. 72h (default 72h)"
+// @Param customer_id query string false "Customer ID to filter by"
+// @Success 200 {array} domain.ProxyPlan
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/expiring [get]
+func (h *PlanHandler) GetExpiringPlans(w http.ResponseWriter, r *http.Request) {
+	within := defaultExpiringWindow
+	if raw := r.URL.Query().Get("within"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid within duration", err)
+			return
+		}
+		within = parsed
+	}
+
+	plans, err := h.planService.GetExpiringPlans(r.Context(), within, r.URL.Query().Get("customer_id"))
+	if err != nil {
+		h.logger.Error("Failed to get expiring plans", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to get expiring plans", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, plans)
 }
 
-// GetStats returns statistics about plans
-func (h *PlanHandler) GetStats(w http.ResponseWriter, r *http.Request) {
-	// This would be implemented to return plan statistics
-	// For now, return placeholder data
-	stats := map[string]interface{}{
-		"total_plans":    0,
-		"active_plans":   0,
-		"expired_plans":  0,
-		"failed_plans":   0,
-		"creating_plans": 0,
+// DeletePlan deletes a proxy plan
+// @Summary Delete a proxy plan
+// @Description Delete a proxy plan and all associated instances
+// @Tags plans
+// @Param id path string true "Plan ID"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id} [delete]
+func (h *PlanHandler) DeletePlan(w http.ResponseWriter, r *http.Request) {
+	planIDStr := chi.URLParam(r, "id")
+	planID, err := uuid.Parse(planIDStr)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	if err := h.planService.DeletePlan(r.Context(), planID); err != nil {
+		h.logger.Error("Failed to delete plan", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to delete plan", err)
+		return
 	}
 
-	h.respondWithJSON(w, http.StatusOK, stats)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ClonePlan creates a new plan with the same settings as an existing one
+// @Summary Clone a proxy plan
+// @Description Create a new plan with the same provider, type, region, bandwidth and duration, with fresh credentials
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param request body domain.ClonePlanRequest false "Optional customer override"
+// @Param format query string false "Also render endpoints in this format"
+// @Success 201 {object} domain.CreatePlanResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/clone [post]
+func (h *PlanHandler) ClonePlan(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	format, ok := h.endpointFormat(w, r)
+	if !ok {
+		return
+	}
+
+	var req domain.ClonePlanRequest
+	if err := decodeJSON(r, &req); err != nil && err != io.EOF {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if _, err := h.planService.GetPlan(r.Context(), planID); err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	response, err := h.planService.ClonePlan(r.Context(), planID, req.CustomerID)
+	if err != nil {
+		h.logger.Error("Failed to clone plan", zap.Error(err))
+		h.respondWithCreateError(w, err)
+		return
+	}
+
+	h.respondWithCreated(w, response, format)
 }
 
 // Helper methods
@@ -285,7 +700,47 @@ func (h *PlanHandler) respondWithJSON(w http.ResponseWriter, statusCode int, dat
 	}
 }
 
+// respondWithCreateError maps plan creation failures to a status code;
+// policy violations are the client's fault and return 400
+// endpointFormat reads and validates the optional ?format= parameter,
+// responding with 400 and returning false when it is unsupported
+func (h *PlanHandler) endpointFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	if format != "" && !domain.IsEndpointFormat(format) {
+		h.respondWithError(w, http.StatusBadRequest, "Unsupported endpoint format",
+			fmt.Errorf("supported formats: %s", strings.Join(domain.EndpointFormats(), ", ")))
+		return "", false
+	}
+	return format, true
+}
+
+// respondWithCreated writes a created plan, rendering its endpoints if a format was requested
+func (h *PlanHandler) respondWithCreated(w http.ResponseWriter, response *domain.CreatePlanResponse, format string) {
+	if format != "" {
+		formatted, err := domain.FormatEndpoints(response.Proxies, format)
+		if err != nil {
+			h.logger.Warn("Failed to format endpoints", zap.Error(err))
+		} else {
+			response.Formatted = formatted
+		}
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, response)
+}
+
+func (h *PlanHandler) respondWithCreateError(w http.ResponseWriter, err error) {
+	if domain.IsPolicyError(err) {
+		h.respondWithJSON(w, http.StatusBadRequest, errors.NewValidationError("Plan request violates plan type policy", err.Error()))
+		return
+	}
+	if domain.IsDuplicateUsername(err) {
+		h.respondWithJSON(w, http.StatusConflict, errors.NewConflictError("Username already in use", err.Error()))
+		return
+	}
+	h.respondWithError(w, http.StatusInternalServerError, "Failed to create plan", err)
+}
+
 func (h *PlanHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
-	errorResponse := errors.NewErrorResponse(message, err)
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
 	h.respondWithJSON(w, statusCode, errorResponse)
 }
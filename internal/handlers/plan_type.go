@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// PlanTypeHandler handles plan type management
+type PlanTypeHandler struct {
+	planTypes *service.PlanTypeService
+	logger    *zap.Logger
+}
+
+// NewPlanTypeHandler creates a new plan type handler
+func NewPlanTypeHandler(planTypes *service.PlanTypeService, logger *zap.Logger) *PlanTypeHandler {
+	return &PlanTypeHandler{
+		planTypes: planTypes,
+		logger:    logger,
+	}
+}
+
+// GetPlanTypes lists plan types
+// @Summary List plan types
+// @Description Every plan type configuration, by key
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]domain.PlanTypeConfig
+// @Security BearerAuth
+// @Router /admin/plan-types [get]
+func (h *PlanTypeHandler) GetPlanTypes(w http.ResponseWriter, r *http.Request) {
+	h.respondWithJSON(w, http.StatusOK, h.planTypes.List())
+}
+
+// GetPlanType returns a plan type
+// @Summary Get plan type
+// @Tags admin
+// @Produce json
+// @Param key path string true "Plan type key (provider_region_plantype)"
+// @Success 200 {object} domain.PlanTypeConfig
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/plan-types/{key} [get]
+func (h *PlanTypeHandler) GetPlanType(w http.ResponseWriter, r *http.Request) {
+	planType, err := h.planTypes.Get(chi.URLParam(r, "key"))
+	if err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan type not found", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, planType)
+}
+
+// CreatePlanType adds a plan type
+// @Summary Create plan type
+// @Description Validates the port range and upstream reachability, creates the plan type's port pool and stores it under provider_region_plantype
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body domain.PlanTypeConfig true "Plan type"
+// @Success 201 {object} domain.PlanTypeConfig
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 422 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/plan-types [post]
+func (h *PlanTypeHandler) CreatePlanType(w http.ResponseWriter, r *http.Request) {
+	var planType domain.PlanTypeConfig
+	if err := decodeJSON(r, &planType); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	created, err := h.planTypes.Create(r.Context(), &planType)
+	if err != nil {
+		h.respondWithPlanTypeError(w, "create", planType.GetPlanTypeKey(), err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, created)
+}
+
+// UpdatePlanType replaces a plan type
+// @Summary Update plan type
+// @Description Replaces a plan type and rebuilds its port pool with the ports already allocated. Provider, region and plan type cannot change.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param key path string true "Plan type key (provider_region_plantype)"
+// @Param request body domain.PlanTypeConfig true "Plan type"
+// @Success 200 {object} domain.PlanTypeConfig
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 422 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/plan-types/{key} [put]
+func (h *PlanTypeHandler) UpdatePlanType(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+
+	var planType domain.PlanTypeConfig
+	if err := decodeJSON(r, &planType); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	updated, err := h.planTypes.Update(r.Context(), key, &planType)
+	if err != nil {
+		h.respondWithPlanTypeError(w, "update", key, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, updated)
+}
+
+// DisablePlanType stops a plan type accepting new plans
+// @Summary Disable plan type
+// @Description New plans of a disabled plan type are refused; existing plans keep running
+// @Tags admin
+// @Produce json
+// @Param key path string true "Plan type key (provider_region_plantype)"
+// @Success 200 {object} domain.PlanTypeConfig
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/plan-types/{key}/disable [post]
+func (h *PlanTypeHandler) DisablePlanType(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, true)
+}
+
+// EnablePlanType lets a disabled plan type accept new plans again
+// @Summary Enable plan type
+// @Tags admin
+// @Produce json
+// @Param key path string true "Plan type key (provider_region_plantype)"
+// @Success 200 {object} domain.PlanTypeConfig
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/plan-types/{key}/enable [post]
+func (h *PlanTypeHandler) EnablePlanType(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, false)
+}
+
+func (h *PlanTypeHandler) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	key := chi.URLParam(r, "key")
+	action := "enable"
+	if disabled {
+		action = "disable"
+	}
+
+	planType, err := h.planTypes.SetDisabled(r.Context(), key, disabled)
+	if err != nil {
+		h.respondWithPlanTypeError(w, action, key, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, planType)
+}
+
+// SetPlanTypePricing sets a plan type's upstream cost and retail price
+// @Summary Set plan type pricing
+// @Description Replaces the plan type's per-GB upstream cost and retail price, used by margin reporting. Update it by hand when a provider changes its rates; a plan type priced below cost is reported in the margin warnings.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param key path string true "Plan type key (provider_region_plantype)"
+// @Param request body domain.Pricing true "Pricing"
+// @Success 200 {object} domain.PlanTypeConfig
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/plan-types/{key}/pricing [put]
+func (h *PlanTypeHandler) SetPlanTypePricing(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+
+	var pricing domain.Pricing
+	if err := decodeJSON(r, &pricing); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	planType, err := h.planTypes.SetPricing(r.Context(), key, &pricing, time.Now())
+	if err != nil {
+		h.respondWithPlanTypeError(w, "set pricing of", key, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, planType)
+}
+
+// DeletePlanTypePricing removes a plan type's pricing
+// @Summary Remove plan type pricing
+// @Description Plans of a plan type without pricing are left out of margin totals
+// @Tags admin
+// @Produce json
+// @Param key path string true "Plan type key (provider_region_plantype)"
+// @Success 200 {object} domain.PlanTypeConfig
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/plan-types/{key}/pricing [delete]
+func (h *PlanTypeHandler) DeletePlanTypePricing(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+
+	planType, err := h.planTypes.SetPricing(r.Context(), key, nil, time.Now())
+	if err != nil {
+		h.respondWithPlanTypeError(w, "remove pricing of", key, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, planType)
+}
+
+// respondWithPlanTypeError maps plan type service errors to responses
+func (h *PlanTypeHandler) respondWithPlanTypeError(w http.ResponseWriter, action, key string, err error) {
+	switch {
+	case stderrors.Is(err, service.ErrPlanTypeNotFound):
+		h.respondWithError(w, http.StatusNotFound, "Plan type not found", err)
+	case stderrors.Is(err, service.ErrPlanTypeExists):
+		h.respondWithError(w, http.StatusConflict, "Plan type already exists", err)
+	case stderrors.Is(err, service.ErrInvalidPlanType):
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan type", err)
+	case stderrors.Is(err, service.ErrUpstreamUnreachable):
+		h.respondWithError(w, http.StatusUnprocessableEntity, "Upstream is not reachable", err)
+	case stderrors.Is(err, service.ErrPlanTypeNotApplied):
+		h.respondWithError(w, http.StatusInternalServerError, "Plan type saved but region nginx configs were not regenerated", err)
+	default:
+		h.logger.Error("Failed to "+action+" plan type", zap.String("plan_type", key), zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to "+action+" plan type", err)
+	}
+}
+
+// Helper methods
+func (h *PlanTypeHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *PlanTypeHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// PortalHandler serves the customer portal API, authenticated with per-plan
+// API keys, and the operator endpoints that manage those keys
+type PortalHandler struct {
+	apiKeys *service.APIKeyService
+	logger  *zap.Logger
+}
+
+// NewPortalHandler creates a new portal handler
+func NewPortalHandler(apiKeys *service.APIKeyService, logger *zap.Logger) *PortalHandler {
+	return &PortalHandler{
+		apiKeys: apiKeys,
+		logger:  logger,
+	}
+}
+
+// GetUsage returns the usage and endpoints of the API key's plan
+// @Summary Get plan usage
+// @Description Usage, status and endpoints of the plan the customer API key was issued for
+// @Tags portal
+// @Produce json
+// @Success 200 {object} domain.PlanUsage
+// @Failure 401 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security APIKeyAuth
+// @Router /portal/v1/usage [get]
+func (h *PortalHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || token == "" {
+		h.respondWithError(w, http.StatusUnauthorized, "Missing API key", nil)
+		return
+	}
+
+	key, err := h.apiKeys.Authenticate(r.Context(), token)
+	if err != nil {
+		h.respondWithError(w, http.StatusUnauthorized, "Invalid API key", nil)
+		return
+	}
+
+	usage, err := h.apiKeys.Usage(r.Context(), key)
+	if err != nil {
+		h.logger.Error("Failed to get plan usage", zap.String("plan_id", key.PlanID.String()), zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to get plan usage", nil)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, usage)
+}
+
+// CreateAPIKey issues a read-only customer API key for a plan
+// @Summary Issue plan API key
+// @Description Issue a read-only key for /portal/v1; the key is only returned in this response
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param request body domain.CreateAPIKeyRequest false "Optional key name"
+// @Success 201 {object} domain.CreateAPIKeyResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/api-keys [post]
+func (h *PortalHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	var req domain.CreateAPIKeyRequest
+	if err := decodeJSON(r, &req); err != nil && err != io.EOF {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	key, token, err := h.apiKeys.IssueKey(r.Context(), planID, req.Name)
+	if err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, &domain.CreateAPIKeyResponse{APIKey: key, Key: token})
+}
+
+// GetAPIKeys lists a plan's customer API keys
+// @Summary List plan API keys
+// @Description List a plan's customer API keys, including revoked ones, newest first. Keys themselves are never returned.
+// @Tags plans
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Success 200 {array} domain.APIKey
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/api-keys [get]
+func (h *PortalHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	keys, err := h.apiKeys.ListKeys(r.Context(), planID)
+	if err != nil {
+		h.logger.Error("Failed to list API keys", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to list API keys", err)
+		return
+	}
+	if keys == nil {
+		keys = []*domain.APIKey{}
+	}
+
+	h.respondWithJSON(w, http.StatusOK, keys)
+}
+
+// RevokeAPIKey revokes a plan's customer API key
+// @Summary Revoke plan API key
+// @Description Revoke a customer API key; it stops authenticating immediately
+// @Tags plans
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param key_id path string true "API key ID"
+// @Success 200 {object} domain.APIKey
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/api-keys/{key_id} [delete]
+func (h *PortalHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+	keyID, err := uuid.Parse(chi.URLParam(r, "key_id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid API key ID", err)
+		return
+	}
+
+	key, err := h.apiKeys.RevokeKey(r.Context(), planID, keyID)
+	if err != nil {
+		h.respondWithError(w, http.StatusNotFound, "API key not found", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, key)
+}
+
+// Helper methods
+func (h *PortalHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *PortalHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// ProviderAccountHandler handles upstream provider account requests
+type ProviderAccountHandler struct {
+	accountService service.ProviderAccountService
+	logger         *zap.Logger
+}
+
+// NewProviderAccountHandler creates a new provider account handler
+func NewProviderAccountHandler(accountService service.ProviderAccountService, logger *zap.Logger) *ProviderAccountHandler {
+	return &ProviderAccountHandler{
+		accountService: accountService,
+		logger:         logger,
+	}
+}
+
+// RenewAccountRequest is the body of a provider account renewal
+type RenewAccountRequest struct {
+	Bandwidth int `json:"bandwidth"` // GB
+}
+
+// GetAccounts lists provider accounts
+// @Summary List provider accounts
+// @Description List upstream provider accounts, optionally filtered by provider and customer
+// @Tags provider-accounts
+// @Produce json
+// @Param provider query string false "Provider to filter by"
+// @Param customer_id query string false "Customer ID to filter by"
+// @Success 200 {array} domain.ProviderAccount
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /provider-accounts [get]
+func (h *ProviderAccountHandler) GetAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.accountService.ListAccounts(r.Context(), r.URL.Query().Get("provider"), r.URL.Query().Get("customer_id"))
+	if err != nil {
+		h.logger.Error("Failed to list provider accounts", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to list provider accounts", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, accounts)
+}
+
+// GetAccount retrieves a provider account
+// @Summary Get a provider account
+// @Tags provider-accounts
+// @Produce json
+// @Param id path string true "Provider account ID"
+// @Success 200 {object} domain.ProviderAccount
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /provider-accounts/{id} [get]
+func (h *ProviderAccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := h.accountID(w, r)
+	if !ok {
+		return
+	}
+
+	account, err := h.accountService.GetAccount(r.Context(), accountID)
+	if err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Provider account not found", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, account)
+}
+
+// RenewAccount tops up a provider account's bandwidth
+// @Summary Renew a provider account
+// @Tags provider-accounts
+// @Accept json
+// @Produce json
+// @Param id path string true "Provider account ID"
+// @Param request body RenewAccountRequest true "Bandwidth to add"
+// @Success 200 {object} domain.ProviderAccount
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 502 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /provider-accounts/{id}/renew [post]
+func (h *ProviderAccountHandler) RenewAccount(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := h.accountID(w, r)
+	if !ok {
+		return
+	}
+
+	var req RenewAccountRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if req.Bandwidth <= 0 {
+		h.respondWithError(w, http.StatusBadRequest, "bandwidth must be positive", nil)
+		return
+	}
+
+	account, err := h.accountService.RenewAccount(r.Context(), accountID, req.Bandwidth)
+	if err != nil {
+		h.logger.Error("Failed to renew provider account", zap.Error(err))
+		h.respondWithError(w, http.StatusBadGateway, "Failed to renew provider account", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, account)
+}
+
+// SyncUsage refreshes a provider account's usage from the provider
+// @Summary Sync provider account usage
+// @Tags provider-accounts
+// @Produce json
+// @Param id path string true "Provider account ID"
+// @Success 200 {object} domain.ProviderAccount
+// @Failure 502 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /provider-accounts/{id}/sync [post]
+func (h *ProviderAccountHandler) SyncUsage(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := h.accountID(w, r)
+	if !ok {
+		return
+	}
+
+	account, err := h.accountService.SyncUsage(r.Context(), accountID)
+	if err != nil {
+		h.logger.Error("Failed to sync provider account usage", zap.Error(err))
+		h.respondWithError(w, http.StatusBadGateway, "Failed to sync provider account usage", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, account)
+}
+
+// DeleteAccount deletes a provider account that no longer serves any plan
+// @Summary Delete a provider account
+// @Tags provider-accounts
+// @Param id path string true "Provider account ID"
+// @Success 204
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 502 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /provider-accounts/{id} [delete]
+func (h *ProviderAccountHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := h.accountID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.accountService.DeleteAccount(r.Context(), accountID); err != nil {
+		if service.IsAccountInUse(err) {
+			h.respondWithError(w, http.StatusConflict, "Provider account is still linked to plans", err)
+			return
+		}
+		h.logger.Error("Failed to delete provider account", zap.Error(err))
+		h.respondWithError(w, http.StatusBadGateway, "Failed to delete provider account", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Helper methods
+func (h *ProviderAccountHandler) accountID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	accountID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid provider account ID", err)
+		return uuid.Nil, false
+	}
+	return accountID, true
+}
+
+func (h *ProviderAccountHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *ProviderAccountHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
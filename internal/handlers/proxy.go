@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,21 +12,24 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/je265/oceanproxy/internal/domain"
-	"github.com/je265/oceanproxy/internal/pkg/errors"
 	"github.com/je265/oceanproxy/internal/service"
 )
 
 // ProxyHandler handles proxy-related HTTP requests
 type ProxyHandler struct {
-	proxyService service.ProxyService
-	logger       *zap.Logger
+	proxyService  service.ProxyService
+	healthChecker *service.HealthChecker
+	overrides     *service.InstanceOverrides
+	logger        *zap.Logger
 }
 
 // NewProxyHandler creates a new proxy handler
-func NewProxyHandler(proxyService service.ProxyService, logger *zap.Logger) *ProxyHandler {
+func NewProxyHandler(proxyService service.ProxyService, healthChecker *service.HealthChecker, overrides *service.InstanceOverrides, logger *zap.Logger) *ProxyHandler {
 	return &ProxyHandler{
-		proxyService: proxyService,
-		logger:       logger,
+		proxyService:  proxyService,
+		healthChecker: healthChecker,
+		overrides:     overrides,
+		logger:        logger,
 	}
 }
 
@@ -36,7 +40,9 @@ func NewProxyHandler(proxyService service.ProxyService, logger *zap.Logger) *Pro
 // @Produce json
 // @Param status query string false "Filter by status"
 // @Param plan_id query string false "Filter by plan ID"
+// @Param If-None-Match header string false "ETag from a previous response"
 // @Success 200 {array} domain.ProxyInstance
+// @Success 304 "Not modified since the ETag was issued"
 // @Failure 500 {object} errors.ErrorResponse
 // @Security BearerAuth
 // @Router /proxies [get]
@@ -44,8 +50,17 @@ func (h *ProxyHandler) GetProxies(w http.ResponseWriter, r *http.Request) {
 	status := r.URL.Query().Get("status")
 	planIDStr := r.URL.Query().Get("plan_id")
 
+	version, err := h.proxyService.InstancesVersion(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to get instances version", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to get proxy instances", err)
+		return
+	}
+	if notModified(w, r, version) {
+		return
+	}
+
 	var instances []*domain.ProxyInstance
-	var err error
 
 	if planIDStr != "" {
 		planID, parseErr := uuid.Parse(planIDStr)
@@ -237,6 +252,45 @@ func (h *ProxyHandler) RestartProxy(w http.ResponseWriter, r *http.Request) {
 	h.respondWithJSON(w, http.StatusOK, response)
 }
 
+// SetProxyOverrides sets the operator overrides of a proxy instance
+// @Summary Set proxy instance overrides
+// @Description Keep automation away from an instance. maintenance drains it from its nginx upstream and exempts it from health checks, alerting and automated restarts; no_autoheal keeps it serving and health checked, but cleanup never restarts or deletes it, geo verification never reroutes it and nginx keeps it in rotation when health checks fail it. Both flags are replaced on every call.
+// @Tags proxies
+// @Accept json
+// @Produce json
+// @Param id path string true "Proxy Instance ID"
+// @Param request body domain.InstanceOverridesRequest true "Overrides"
+// @Success 200 {object} domain.ProxyInstance
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /proxies/{id}/overrides [put]
+func (h *ProxyHandler) SetProxyOverrides(w http.ResponseWriter, r *http.Request) {
+	instanceID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid instance ID", err)
+		return
+	}
+
+	var req domain.InstanceOverridesRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	instance, err := h.overrides.Set(r.Context(), instanceID, &req)
+	if err != nil {
+		h.logger.Error("Failed to set proxy instance overrides",
+			zap.String("instance_id", instanceID.String()),
+			zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to set proxy instance overrides", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, instance)
+}
+
 // GetProxyStatus gets the status of a proxy instance
 // @Summary Get proxy instance status
 // @Description Get the current status of a proxy instance
@@ -281,9 +335,54 @@ func (h *ProxyHandler) GetProxyStatus(w http.ResponseWriter, r *http.Request) {
 		response["health_error"] = healthErr.Error()
 	}
 
+	// Surface the last start failure so operators can see why 3proxy exited
+	if instance, err := h.proxyService.GetInstance(r.Context(), instanceID); err == nil {
+		if instance.LastStartError != "" {
+			response["last_start_error"] = instance.LastStartError
+			response["last_start_error_at"] = instance.LastStartErrorAt
+		}
+		if instance.ResourceViolations != nil {
+			response["resource_violations"] = instance.ResourceViolations
+		}
+	}
+
 	h.respondWithJSON(w, http.StatusOK, response)
 }
 
+// HealthCheckProxies health checks many instances concurrently
+// @Summary Health check proxy instances
+// @Description Health check the given instances, or every running instance when none are given, on a bounded worker pool
+// @Tags proxies
+// @Accept json
+// @Produce json
+// @Param request body domain.HealthCheckRequest false "Instances to check"
+// @Success 200 {object} domain.HealthCheckReport
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /proxies/health-check [post]
+func (h *ProxyHandler) HealthCheckProxies(w http.ResponseWriter, r *http.Request) {
+	var req domain.HealthCheckRequest
+	if err := decodeJSON(r, &req); err != nil && err != io.EOF {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if len(req.InstanceIDs) > 0 {
+		h.respondWithJSON(w, http.StatusOK, h.healthChecker.Check(r.Context(), req.InstanceIDs))
+		return
+	}
+
+	report, err := h.healthChecker.CheckRunning(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to health check proxy instances", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to health check proxy instances", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, report)
+}
+
 // GetProxyLogs gets the logs for a proxy instance
 // @Summary Get proxy instance logs
 // @Description Get the logs for a proxy instance
@@ -366,6 +465,6 @@ func (h *ProxyHandler) respondWithJSON(w http.ResponseWriter, statusCode int, da
 }
 
 func (h *ProxyHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
-	errorResponse := errors.NewErrorResponse(message, err)
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
 	h.respondWithJSON(w, statusCode, errorResponse)
 }
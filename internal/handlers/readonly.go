@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+const (
+	// readOnlyPath is the endpoint that switches read-only mode, which stays
+	// writable so the mode can be switched off again
+	readOnlyPath = "/admin/readonly"
+	// slackCommandsPath takes Slack's POSTs for every slash command, so the
+	// Slack handler refuses the mutating ones itself
+	slackCommandsPath = "/slack/commands"
+)
+
+// ReadOnlyMode is the switch that makes the API reject mutating requests
+type ReadOnlyMode struct {
+	mu     sync.RWMutex
+	status domain.ReadOnlyStatus
+}
+
+// NewReadOnlyMode creates the switch, on when enabled
+func NewReadOnlyMode(enabled bool) *ReadOnlyMode {
+	mode := &ReadOnlyMode{}
+	if enabled {
+		mode.Set(true, "server.read_only", time.Now())
+	}
+	return mode
+}
+
+// Set switches read-only mode at now; switching it on again only updates
+// the reason
+func (m *ReadOnlyMode) Set(enabled bool, reason string, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !enabled {
+		m.status = domain.ReadOnlyStatus{}
+		return
+	}
+	if !m.status.Enabled {
+		m.status.Since = &now
+	}
+	m.status.Enabled = true
+	m.status.Reason = reason
+}
+
+// Status returns the current state of the switch
+func (m *ReadOnlyMode) Status() domain.ReadOnlyStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// NewReadOnlyMiddleware rejects mutating requests while read-only mode is on.
+// Slack commands are let through to SlackHandler, which refuses the ones
+// that change state
+func NewReadOnlyMiddleware(mode *ReadOnlyMode, logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			status := mode.Status()
+			if status.Enabled && r.URL.Path != readOnlyPath && r.URL.Path != slackCommandsPath {
+				logger.Debug("Rejecting request in read-only mode",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path))
+
+				message := "API is in read-only mode"
+				if status.Reason != "" {
+					message += ": " + status.Reason
+				}
+				respondWithError(w, http.StatusServiceUnavailable, message, nil)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ReadOnlyHandler shows and switches read-only mode
+type ReadOnlyHandler struct {
+	mode   *ReadOnlyMode
+	logger *zap.Logger
+}
+
+// NewReadOnlyHandler creates a new read-only mode handler
+func NewReadOnlyHandler(mode *ReadOnlyMode, logger *zap.Logger) *ReadOnlyHandler {
+	return &ReadOnlyHandler{
+		mode:   mode,
+		logger: logger,
+	}
+}
+
+// GetReadOnly returns whether the API is read-only
+// @Summary Get read-only mode
+// @Tags admin
+// @Produce json
+// @Success 200 {object} domain.ReadOnlyStatus
+// @Security BearerAuth
+// @Router /admin/readonly [get]
+func (h *ReadOnlyHandler) GetReadOnly(w http.ResponseWriter, r *http.Request) {
+	h.respondWithJSON(w, http.StatusOK, h.mode.Status())
+}
+
+// SetReadOnly switches read-only mode
+// @Summary Switch read-only mode
+// @Description While on, POST, PUT and DELETE requests other than this one are rejected with 503; Slack commands still run, except restart. Reads, the proxies and background workers keep running, so scheduled jobs such as expiry, health checks and the GitOps sync still change state. The switch is not persisted: after a restart server.read_only applies again.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body domain.SetReadOnlyRequest true "Read-only mode"
+// @Success 200 {object} domain.ReadOnlyStatus
+// @Failure 400 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/readonly [put]
+func (h *ReadOnlyHandler) SetReadOnly(w http.ResponseWriter, r *http.Request) {
+	var req domain.SetReadOnlyRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	h.mode.Set(req.Enabled, req.Reason, time.Now())
+	h.logger.Warn("Read-only mode switched",
+		zap.Bool("enabled", req.Enabled),
+		zap.String("reason", req.Reason),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	h.respondWithJSON(w, http.StatusOK, h.mode.Status())
+}
+
+func (h *ReadOnlyHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *ReadOnlyHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// RegionHandler handles region management
+type RegionHandler struct {
+	regions *service.RegionService
+	logger  *zap.Logger
+}
+
+// NewRegionHandler creates a new region handler
+func NewRegionHandler(regions *service.RegionService, logger *zap.Logger) *RegionHandler {
+	return &RegionHandler{
+		regions: regions,
+		logger:  logger,
+	}
+}
+
+// GetRegions lists regions
+// @Summary List regions
+// @Description Every region, by name
+// @Tags admin
+// @Produce json
+// @Success 200 {array} domain.Region
+// @Security BearerAuth
+// @Router /admin/regions [get]
+func (h *RegionHandler) GetRegions(w http.ResponseWriter, r *http.Request) {
+	h.respondWithJSON(w, http.StatusOK, h.regions.List())
+}
+
+// GetRegion returns a region
+// @Summary Get region
+// @Tags admin
+// @Produce json
+// @Param name path string true "Region name"
+// @Success 200 {object} domain.Region
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/regions/{name} [get]
+func (h *RegionHandler) GetRegion(w http.ResponseWriter, r *http.Request) {
+	region, err := h.regions.Get(chi.URLParam(r, "name"))
+	if err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Region not found", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, region)
+}
+
+// CreateRegion adds a region
+// @Summary Create region
+// @Description Stores a region, writes its nginx config and rewrites the DNS records file
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body domain.Region true "Region"
+// @Success 201 {object} domain.Region
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/regions [post]
+func (h *RegionHandler) CreateRegion(w http.ResponseWriter, r *http.Request) {
+	var region domain.Region
+	if err := decodeJSON(r, &region); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	created, err := h.regions.Create(r.Context(), &region)
+	if err != nil {
+		h.respondWithRegionError(w, "create", region.Name, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, created)
+}
+
+// UpdateRegion replaces a region
+// @Summary Update region
+// @Description Replaces a region, rewrites its nginx config with its running instances and rewrites the DNS records file. The name cannot change.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param name path string true "Region name"
+// @Param request body domain.Region true "Region"
+// @Success 200 {object} domain.Region
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/regions/{name} [put]
+func (h *RegionHandler) UpdateRegion(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var region domain.Region
+	if err := decodeJSON(r, &region); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	updated, err := h.regions.Update(r.Context(), name, &region)
+	if err != nil {
+		h.respondWithRegionError(w, "update", name, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, updated)
+}
+
+// DeleteRegion removes a region
+// @Summary Delete region
+// @Description Removes a region and its nginx config. Regions with plan types or unexpired plans cannot be deleted.
+// @Tags admin
+// @Param name path string true "Region name"
+// @Success 204
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/regions/{name} [delete]
+func (h *RegionHandler) DeleteRegion(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	if err := h.regions.Delete(r.Context(), name); err != nil {
+		h.respondWithRegionError(w, "delete", name, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ExportRegions returns every region in the regions.yaml format
+// @Summary Export regions
+// @Description The regions in the regions.yaml format, for keeping them in version control
+// @Tags admin
+// @Produce plain
+// @Success 200 {string} string
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/regions/export [get]
+func (h *RegionHandler) ExportRegions(w http.ResponseWriter, r *http.Request) {
+	data, err := h.regions.Export()
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to export regions", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("Content-Disposition", `attachment; filename="regions.yaml"`)
+	if _, err := w.Write(data); err != nil {
+		h.logger.Error("Failed to write regions export", zap.Error(err))
+	}
+}
+
+// respondWithRegionError maps region service errors to responses
+func (h *RegionHandler) respondWithRegionError(w http.ResponseWriter, action, name string, err error) {
+	switch {
+	case stderrors.Is(err, service.ErrRegionNotFound):
+		h.respondWithError(w, http.StatusNotFound, "Region not found", err)
+	case stderrors.Is(err, service.ErrRegionExists):
+		h.respondWithError(w, http.StatusConflict, "Region already exists", err)
+	case stderrors.Is(err, service.ErrRegionInUse):
+		h.respondWithError(w, http.StatusConflict, "Region is in use", err)
+	case stderrors.Is(err, service.ErrInvalidRegion):
+		h.respondWithError(w, http.StatusBadRequest, "Invalid region", err)
+	case stderrors.Is(err, service.ErrRegionNotApplied):
+		h.respondWithError(w, http.StatusInternalServerError, "Region saved but nginx config or DNS records were not regenerated", err)
+	default:
+		h.logger.Error("Failed to "+action+" region", zap.String("region", name), zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to "+action+" region", err)
+	}
+}
+
+// Helper methods
+func (h *RegionHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *RegionHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
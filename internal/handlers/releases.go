@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/pkg/config"
+	"github.com/je265/oceanproxy/pkg/selfupdate"
+)
+
+// ReleaseHandler serves the signed release manifest to updating nodes
+type ReleaseHandler struct {
+	cfg    config.Updates
+	logger *zap.Logger
+}
+
+// NewReleaseHandler creates a new release handler
+func NewReleaseHandler(cfg *config.Config, logger *zap.Logger) *ReleaseHandler {
+	return &ReleaseHandler{
+		cfg:    cfg.Updates,
+		logger: logger,
+	}
+}
+
+// GetLatestRelease returns the newest release for a component and platform
+// @Summary Get latest release
+// @Description Newest signed release of a component for a channel, OS and architecture; the manifest file is re-read on every request
+// @Tags releases
+// @Produce json
+// @Param component query string true "server or cli"
+// @Param channel query string false "Release channel (default stable)"
+// @Param os query string true "GOOS, e.g. linux"
+// @Param arch query string true "GOARCH, e.g. amd64"
+// @Success 200 {object} selfupdate.Release
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /releases/latest [get]
+func (h *ReleaseHandler) GetLatestRelease(w http.ResponseWriter, r *http.Request) {
+	if h.cfg.ReleasesFile == "" {
+		h.respondWithError(w, http.StatusNotFound, "No releases are published", nil)
+		return
+	}
+
+	manifest, err := selfupdate.LoadManifest(h.cfg.ReleasesFile)
+	if err != nil {
+		h.logger.Error("Failed to load release manifest", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to load releases", err)
+		return
+	}
+
+	query := r.URL.Query()
+	channel := query.Get("channel")
+	if channel == "" {
+		channel = "stable"
+	}
+
+	release := manifest.Latest(query.Get("component"), channel, query.Get("os"), query.Get("arch"))
+	if release == nil {
+		h.respondWithError(w, http.StatusNotFound, "No matching release", nil)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, release)
+}
+
+func (h *ReleaseHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *ReleaseHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
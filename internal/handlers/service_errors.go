@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"net/http"
+
+	"github.com/je265/oceanproxy/internal/pkg/errors"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// serviceErrorStatus maps a typed service error to the status it answers
+// with. Only 500s are refined; a status a handler chose explicitly stands.
+func serviceErrorStatus(statusCode int, err error) int {
+	if statusCode != http.StatusInternalServerError || err == nil {
+		return statusCode
+	}
+
+	switch {
+	case stderrors.Is(err, service.ErrInvalid):
+		return http.StatusBadRequest
+	case stderrors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case stderrors.Is(err, service.ErrConflict):
+		return http.StatusConflict
+	case stderrors.Is(err, service.ErrPortExhausted):
+		return http.StatusServiceUnavailable
+	case stderrors.Is(err, service.ErrProviderFailure):
+		return http.StatusBadGateway
+	}
+	return statusCode
+}
+
+// newServiceErrorResponse builds the response to a failed request, returning
+// the status refined by serviceErrorStatus and an error code to match
+func newServiceErrorResponse(statusCode int, message string, err error) (int, *errors.ErrorResponse) {
+	statusCode = serviceErrorStatus(statusCode, err)
+	response := errors.NewStatusError(statusCode, message, err)
+
+	switch {
+	case stderrors.Is(err, service.ErrPortExhausted):
+		response.WithCode(errors.CodePortUnavailable)
+	case stderrors.Is(err, service.ErrProviderFailure):
+		response.WithCode(errors.CodeProviderError)
+	}
+	return statusCode, response
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/pkg/errors"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// A service error for something that does not exist answers 404 even where
+// the handler falls back to a 500
+func TestServiceNotFoundErrorsAnswer404(t *testing.T) {
+	ports := service.NewPortManager(zap.NewNop(), service.NewPlanTypeRegistry(map[string]*domain.PlanTypeConfig{
+		"proxies_fo_usa_residential": {
+			Provider:       domain.ProviderProxiesFo,
+			PlanType:       domain.PlanTypeResidential,
+			Region:         "usa",
+			LocalPortRange: domain.PortRange{Start: 10000, End: 10010},
+		},
+	}), nil)
+
+	_, allocateErr := ports.AllocatePort(context.Background(), "missing_plan_type", "plan-1")
+	_, configErr := ports.GetPlanTypeConfig("missing_plan_type")
+	_, findErr := ports.FindPlanTypeByProviderAndRegion(domain.ProviderProxiesFo, "mars", domain.PlanTypeResidential)
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"allocate port", allocateErr},
+		{"plan type config", configErr},
+		{"find plan type", findErr},
+	}
+
+	h := NewPlanHandler(nil, zap.NewNop())
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.err == nil {
+				t.Fatal("expected an error for a missing plan type")
+			}
+
+			rec := httptest.NewRecorder()
+			h.respondWithError(rec, http.StatusInternalServerError, "Failed", tc.err)
+
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want %d (error: %v)", rec.Code, http.StatusNotFound, tc.err)
+			}
+			var response errors.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if response.Error.Code != errors.CodeNotFound {
+				t.Errorf("code = %q, want %q", response.Error.Code, errors.CodeNotFound)
+			}
+		})
+	}
+}
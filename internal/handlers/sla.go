@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// SLAHandler serves uptime reports
+type SLAHandler struct {
+	sla    *service.SLAService
+	logger *zap.Logger
+}
+
+// NewSLAHandler creates a new SLA handler
+func NewSLAHandler(sla *service.SLAService, logger *zap.Logger) *SLAHandler {
+	return &SLAHandler{
+		sla:    sla,
+		logger: logger,
+	}
+}
+
+// GetSLA returns the uptime of every region, plan and instance
+// @Summary Get SLA report
+// @Description Uptime per region, plan and instance from health checks, compared with the configured SLA targets. Covers the last sla.window, a calendar month with ?month, or ?from to ?to.
+// @Tags stats
+// @Produce json
+// @Param month query string false "Calendar month in UTC, YYYY-MM"
+// @Param from query string false "Period start, RFC 3339"
+// @Param to query string false "Period end, RFC 3339 (default now)"
+// @Success 200 {object} domain.SLAReport
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /sla [get]
+func (h *SLAHandler) GetSLA(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	from, to, err := h.period(r, now)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid SLA period", err)
+		return
+	}
+
+	report, err := h.sla.Report(r.Context(), from, to, now)
+	if err != nil {
+		h.logger.Error("Failed to build SLA report", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to build SLA report", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, report)
+}
+
+// period parses ?month or ?from and ?to, defaulting to the last sla.window
+func (h *SLAHandler) period(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+	query := r.URL.Query()
+	if raw := query.Get("month"); raw != "" {
+		month, err := time.Parse("2006-01", raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("month must be YYYY-MM")
+		}
+		if month.After(now) {
+			return time.Time{}, time.Time{}, fmt.Errorf("month is in the future")
+		}
+		from, to := service.MonthBounds(month)
+		return from, to, nil
+	}
+
+	to := now
+	if raw := query.Get("to"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("to: %w", err)
+		}
+		to = parsed
+	}
+
+	from := to.Add(-h.sla.Window())
+	if raw := query.Get("from"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("from: %w", err)
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be before to")
+	}
+
+	return from, to, nil
+}
+
+func (h *SLAHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *SLAHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
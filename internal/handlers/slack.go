@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// SlackHandler serves the Slack app's slash command endpoint
+type SlackHandler struct {
+	slack    *service.SlackService
+	readOnly *ReadOnlyMode
+	logger   *zap.Logger
+}
+
+// NewSlackHandler creates a new Slack handler
+func NewSlackHandler(slack *service.SlackService, readOnly *ReadOnlyMode, logger *zap.Logger) *SlackHandler {
+	return &SlackHandler{
+		slack:    slack,
+		readOnly: readOnly,
+		logger:   logger,
+	}
+}
+
+// Command runs a slash command posted by Slack
+// @Summary Run Slack slash command
+// @Description Slash command endpoint of the Slack app: status, plans expiring [window] and restart <instance>. restart is refused while the API is in read-only mode. Requests must carry a valid X-Slack-Signature made with slack.signing_secret; the user's role comes from slack.roles.
+// @Tags slack
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Success 200 {object} domain.SlackResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 401 {object} errors.ErrorResponse
+// @Router /slack/commands [post]
+func (h *SlackHandler) Command(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if err := h.slack.Verify(r.Header.Get("X-Slack-Request-Timestamp"), r.Header.Get("X-Slack-Signature"), body, time.Now()); err != nil {
+		h.logger.Warn("Rejected Slack command",
+			zap.String("remote_addr", r.RemoteAddr),
+			zap.Error(err))
+		h.respondWithError(w, http.StatusUnauthorized, "Invalid Slack signature", nil)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	response := h.slack.Handle(r.Context(), &domain.SlackCommand{
+		Command:     form.Get("command"),
+		Text:        form.Get("text"),
+		UserID:      form.Get("user_id"),
+		UserName:    form.Get("user_name"),
+		TeamID:      form.Get("team_id"),
+		ChannelID:   form.Get("channel_id"),
+		ResponseURL: form.Get("response_url"),
+		ReadOnly:    h.readOnly.Status().Enabled,
+	}, time.Now())
+
+	h.respondWithJSON(w, http.StatusOK, response)
+}
+
+func (h *SlackHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *SlackHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// defaultStatsWindow is used when stats are requested without ?from
+const defaultStatsWindow = 24 * time.Hour
+
+// defaultReportWindow is used when a report is requested without ?from
+const defaultReportWindow = 7 * 24 * time.Hour
+
+// StatsHandler serves request/usage statistics
+type StatsHandler struct {
+	statsService   *service.StatsService
+	reportService  *service.ReportService
+	bandwidthMeter *service.BandwidthMeter
+	logger         *zap.Logger
+}
+
+// NewStatsHandler creates a new stats handler
+func NewStatsHandler(statsService *service.StatsService, reportService *service.ReportService, bandwidthMeter *service.BandwidthMeter, logger *zap.Logger) *StatsHandler {
+	return &StatsHandler{
+		statsService:   statsService,
+		reportService:  reportService,
+		bandwidthMeter: bandwidthMeter,
+		logger:         logger,
+	}
+}
+
+// GetStats returns system-wide statistics
+// @Summary Get statistics
+// @Description Traffic over the window plus current plan and instance counts; the data resolution (raw, hourly, daily) is chosen from the window's age
+// @Tags stats
+// @Produce json
+// @Param from query string false "Window start, RFC 3339 (default 24h ago)"
+// @Param to query string false "Window end, RFC 3339 (default now)"
+// @Success 200 {object} repository.OverallStats
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /stats [get]
+func (h *StatsHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	from, to, err := statsWindow(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid stats window", err)
+		return
+	}
+
+	stats, err := h.statsService.GetOverallStats(r.Context(), from, to)
+	if err != nil {
+		h.logger.Error("Failed to get stats", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to get stats", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, stats)
+}
+
+// GetMargins returns margin reporting
+// @Summary Get margins
+// @Description Revenue, upstream cost and margin of the bandwidth of plans created in the window, in total and per provider and region, at the plan types' current pricing. Plan types selling below upstream cost are listed in warnings.
+// @Tags stats
+// @Produce json
+// @Param from query string false "Window start, RFC 3339 (default 24h ago)"
+// @Param to query string false "Window end, RFC 3339 (default now)"
+// @Success 200 {object} domain.MarginReport
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /stats/margins [get]
+func (h *StatsHandler) GetMargins(w http.ResponseWriter, r *http.Request) {
+	from, to, err := statsWindow(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid stats window", err)
+		return
+	}
+
+	report, err := h.statsService.GetMargins(r.Context(), from, to)
+	if err != nil {
+		h.logger.Error("Failed to get margins", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to get margins", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, report)
+}
+
+// GetReport returns the operator report
+// @Summary Get operator report
+// @Description The report scheduled weekly for operators, built for the window: new and expired plans, traffic, revenue and provider spend, top customers and instance failures. ?format=html renders it as emailed.
+// @Tags stats
+// @Produce json
+// @Produce html
+// @Param from query string false "Window start, RFC 3339 (default 7 days ago)"
+// @Param to query string false "Window end, RFC 3339 (default now)"
+// @Param format query string false "json (default) or html"
+// @Success 200 {object} domain.OperatorReport
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /stats/report [get]
+func (h *StatsHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	from, to, err := queryWindow(r, defaultReportWindow)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid report window", err)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "html" {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid format", fmt.Errorf("format must be json or html"))
+		return
+	}
+
+	report, err := h.reportService.Build(r.Context(), from, to)
+	if err != nil {
+		h.logger.Error("Failed to build report", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to build report", err)
+		return
+	}
+
+	if format == "html" {
+		body, err := service.RenderReportHTML(report)
+		if err != nil {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to render report", err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, report)
+}
+
+// SendReport delivers the operator report now
+// @Summary Send operator report
+// @Description Builds the operator report for the window and delivers it to the configured email recipients and webhook, as the weekly schedule does
+// @Tags stats
+// @Produce json
+// @Param from query string false "Window start, RFC 3339 (default 7 days ago)"
+// @Param to query string false "Window end, RFC 3339 (default now)"
+// @Success 200 {object} domain.OperatorReport
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Failure 502 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /stats/report/send [post]
+func (h *StatsHandler) SendReport(w http.ResponseWriter, r *http.Request) {
+	from, to, err := queryWindow(r, defaultReportWindow)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid report window", err)
+		return
+	}
+
+	report, err := h.reportService.Build(r.Context(), from, to)
+	if err != nil {
+		h.logger.Error("Failed to build report", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to build report", err)
+		return
+	}
+
+	if err := h.reportService.Send(r.Context(), report); err != nil {
+		h.logger.Error("Failed to send report", zap.Error(err))
+		h.respondWithError(w, http.StatusBadGateway, "Failed to send report", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, report)
+}
+
+// GetPlanStats returns a plan's statistics
+// @Summary Get plan statistics
+// @Description Traffic of the plan's instances over the window; the data resolution is chosen from the window's age
+// @Tags stats
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param from query string false "Window start, RFC 3339 (default 24h ago)"
+// @Param to query string false "Window end, RFC 3339 (default now)"
+// @Success 200 {object} repository.PlanStats
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/stats [get]
+func (h *StatsHandler) GetPlanStats(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	from, to, err := statsWindow(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid stats window", err)
+		return
+	}
+
+	stats, err := h.statsService.GetPlanStats(r.Context(), planID, from, to)
+	if err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, stats)
+}
+
+// GetPlanUsage returns a plan's measured usage
+// @Summary Get plan usage
+// @Description Traffic of the plan counted from its instance logs since it was created or last renewed, in total and per instance, against its bandwidth. Plan types with an accounting schedule count traffic at the rate of the window it was sent in and break it down by window. With stats.enforce_bandwidth set, plans that exceed it are suspended.
+// @Tags stats
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Success 200 {object} domain.EdgeUsage
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/usage [get]
+func (h *StatsHandler) GetPlanUsage(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	usage, err := h.bandwidthMeter.Usage(r.Context(), planID, time.Now())
+	if err != nil {
+		h.logger.Error("Failed to get plan usage", zap.String("plan_id", planID.String()), zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to get plan usage", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, usage)
+}
+
+// statsWindow parses the optional ?from and ?to RFC 3339 parameters
+func statsWindow(r *http.Request) (time.Time, time.Time, error) {
+	return queryWindow(r, defaultStatsWindow)
+}
+
+// queryWindow parses the optional ?from and ?to RFC 3339 parameters; from
+// defaults to window before to
+func queryWindow(r *http.Request, window time.Duration) (time.Time, time.Time, error) {
+	to := time.Now()
+	if raw := r.URL.Query().Get("to"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("to: %w", err)
+		}
+		to = parsed
+	}
+
+	from := to.Add(-window)
+	if raw := r.URL.Query().Get("from"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("from: %w", err)
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be before to")
+	}
+
+	return from, to, nil
+}
+
+func (h *StatsHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *StatsHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+//go:embed templates/status.html.tmpl
+var statusPageTemplate string
+
+var statusPage = template.Must(template.New("status").Funcs(template.FuncMap{
+	"summaryText": func(status string) string {
+		switch status {
+		case domain.StatusOutage:
+			return "Service outage"
+		case domain.StatusDegraded:
+			return "Degraded performance"
+		default:
+			return "All systems operational"
+		}
+	},
+	"statusText": func(status string) string {
+		switch status {
+		case domain.StatusOutage:
+			return "Outage"
+		case domain.StatusDegraded:
+			return "Degraded"
+		default:
+			return "Operational"
+		}
+	},
+}).Parse(statusPageTemplate))
+
+// StatusHandler serves the public status page
+type StatusHandler struct {
+	status *service.StatusPageService
+	logger *zap.Logger
+}
+
+// NewStatusHandler creates a new status page handler
+func NewStatusHandler(status *service.StatusPageService, logger *zap.Logger) *StatusHandler {
+	return &StatusHandler{
+		status: status,
+		logger: logger,
+	}
+}
+
+// GetStatus returns the status of every region
+// @Summary Public status page
+// @Description Current state, uptime and incidents of every region, without authentication. Returns HTML with ?format=html or an Accept header preferring text/html.
+// @Tags status
+// @Produce json,html
+// @Param format query string false "json (default) or html"
+// @Success 200 {object} domain.StatusPage
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /status [get]
+func (h *StatusHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.page(w, r)
+	if !ok {
+		return
+	}
+
+	h.respond(w, r, page)
+}
+
+// GetRegionStatus returns the status of one region
+// @Summary Public region status
+// @Description Current state, uptime and incidents of one region, without authentication; for embedding by resellers
+// @Tags status
+// @Produce json,html
+// @Param region path string true "Region name"
+// @Param format query string false "json (default) or html"
+// @Success 200 {object} domain.StatusPage
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Router /status/{region} [get]
+func (h *StatusHandler) GetRegionStatus(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.page(w, r)
+	if !ok {
+		return
+	}
+
+	name := chi.URLParam(r, "region")
+	for _, region := range page.Regions {
+		if region.Region != name {
+			continue
+		}
+		regionPage := *page
+		regionPage.Status = region.Status
+		regionPage.Regions = []domain.RegionStatus{region}
+		regionPage.Incidents = []domain.PublicIncident{}
+		for _, incident := range page.Incidents {
+			if incident.Region == name {
+				regionPage.Incidents = append(regionPage.Incidents, incident)
+			}
+		}
+		h.respond(w, r, &regionPage)
+		return
+	}
+
+	h.respondWithError(w, http.StatusNotFound, "Region not found", fmt.Errorf("unknown region %q", name))
+}
+
+// page returns the current status page, responding with an error and
+// returning false when it is disabled or cannot be built
+func (h *StatusHandler) page(w http.ResponseWriter, r *http.Request) (*domain.StatusPage, bool) {
+	if !h.status.Enabled() {
+		h.respondWithError(w, http.StatusNotFound, "Status page is disabled", nil)
+		return nil, false
+	}
+
+	page, err := h.status.Page(r.Context(), time.Now())
+	if err != nil {
+		h.logger.Error("Failed to build status page", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to build status page", nil)
+		return nil, false
+	}
+
+	return page, true
+}
+
+// respond writes the page as HTML or JSON, cacheable until it is rebuilt
+func (h *StatusHandler) respond(w http.ResponseWriter, r *http.Request, page *domain.StatusPage) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.status.Refresh().Seconds())))
+
+	format := r.URL.Query().Get("format")
+	if format == "html" || format == "" && strings.HasPrefix(r.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		if err := statusPage.Execute(w, page); err != nil {
+			h.logger.Error("Failed to render status page", zap.Error(err))
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, page)
+}
+
+func (h *StatusHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *StatusHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// SubUserHandler handles the sub-users sharing a plan
+type SubUserHandler struct {
+	subUsers    *service.SubUserService
+	planService service.PlanService
+	logger      *zap.Logger
+}
+
+// NewSubUserHandler creates a new sub-user handler
+func NewSubUserHandler(subUsers *service.SubUserService, planService service.PlanService, logger *zap.Logger) *SubUserHandler {
+	return &SubUserHandler{
+		subUsers:    subUsers,
+		planService: planService,
+		logger:      logger,
+	}
+}
+
+// GetSubUsers lists a plan's sub-users
+// @Summary List plan sub-users
+// @Description Get a plan's sub-users with their credentials, the traffic counted for each and their live activity (connections in the last minute, traffic this hour)
+// @Tags plans
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Success 200 {array} domain.SubUserResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/subusers [get]
+func (h *SubUserHandler) GetSubUsers(w http.ResponseWriter, r *http.Request) {
+	planID, ok := h.planID(w, r)
+	if !ok {
+		return
+	}
+
+	subUsers, err := h.subUsers.List(r.Context(), planID)
+	if err != nil {
+		h.logger.Error("Failed to list sub-users", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to list sub-users", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, subUsers)
+}
+
+// CreateSubUser adds a sub-user to a plan
+// @Summary Create plan sub-user
+// @Description Add a user with its own credentials and, optionally, its own share of the plan's bandwidth. Credentials not given are generated. Running instances are restarted to pick it up.
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param request body domain.SubUserRequest true "Sub-user"
+// @Success 201 {object} domain.SubUserResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/subusers [post]
+func (h *SubUserHandler) CreateSubUser(w http.ResponseWriter, r *http.Request) {
+	planID, ok := h.planID(w, r)
+	if !ok {
+		return
+	}
+
+	var req domain.SubUserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	subUser, err := h.subUsers.Create(r.Context(), planID, &req, time.Now())
+	if err != nil {
+		if domain.IsPolicyError(err) {
+			h.respondWithError(w, http.StatusBadRequest, "Cannot create sub-user", err)
+			return
+		}
+		h.logger.Error("Failed to create sub-user", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to create sub-user", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, subUser)
+}
+
+// GetSubUser returns one of a plan's sub-users
+// @Summary Get plan sub-user
+// @Description Get a sub-user with its credentials and counted traffic
+// @Tags plans
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param subuser_id path string true "Sub-user ID"
+// @Success 200 {object} domain.SubUserResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/subusers/{subuser_id} [get]
+func (h *SubUserHandler) GetSubUser(w http.ResponseWriter, r *http.Request) {
+	planID, subUserID, ok := h.subUserID(w, r)
+	if !ok {
+		return
+	}
+
+	subUser, err := h.subUsers.Get(r.Context(), planID, subUserID)
+	if err != nil {
+		h.respondWithSubUserError(w, "Failed to get sub-user", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, subUser)
+}
+
+// UpdateSubUser changes one of a plan's sub-users
+// @Summary Update plan sub-user
+// @Description Replace a sub-user's name, bandwidth and disabled flag, and its password when one is given. Raising the bandwidth of a sub-user that used its share lets it back in.
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param subuser_id path string true "Sub-user ID"
+// @Param request body domain.SubUserRequest true "Sub-user"
+// @Success 200 {object} domain.SubUserResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/subusers/{subuser_id} [put]
+func (h *SubUserHandler) UpdateSubUser(w http.ResponseWriter, r *http.Request) {
+	planID, subUserID, ok := h.subUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req domain.SubUserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	subUser, err := h.subUsers.Update(r.Context(), planID, subUserID, &req, time.Now())
+	if err != nil {
+		if domain.IsPolicyError(err) {
+			h.respondWithError(w, http.StatusBadRequest, "Cannot update sub-user", err)
+			return
+		}
+		h.respondWithSubUserError(w, "Failed to update sub-user", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, subUser)
+}
+
+// DeleteSubUser removes one of a plan's sub-users
+// @Summary Delete plan sub-user
+// @Description Remove a sub-user and its counted traffic; running instances are restarted without it
+// @Tags plans
+// @Param id path string true "Plan ID"
+// @Param subuser_id path string true "Sub-user ID"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/subusers/{subuser_id} [delete]
+func (h *SubUserHandler) DeleteSubUser(w http.ResponseWriter, r *http.Request) {
+	planID, subUserID, ok := h.subUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.subUsers.Delete(r.Context(), planID, subUserID, time.Now()); err != nil {
+		h.respondWithSubUserError(w, "Failed to delete sub-user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// planID parses the plan ID and checks the plan exists, responding with an
+// error if not
+func (h *SubUserHandler) planID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return uuid.Nil, false
+	}
+
+	if _, err := h.planService.GetPlan(r.Context(), planID); err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return uuid.Nil, false
+	}
+	return planID, true
+}
+
+// subUserID parses the plan and sub-user IDs
+func (h *SubUserHandler) subUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	planID, ok := h.planID(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	subUserID, err := uuid.Parse(chi.URLParam(r, "subuser_id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid sub-user ID", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return planID, subUserID, true
+}
+
+// Helper methods
+func (h *SubUserHandler) respondWithSubUserError(w http.ResponseWriter, message string, err error) {
+	if stderrors.Is(err, service.ErrSubUserNotFound) {
+		h.respondWithError(w, http.StatusNotFound, "Sub-user not found", err)
+		return
+	}
+	h.logger.Error(message, zap.Error(err))
+	h.respondWithError(w, http.StatusInternalServerError, message, err)
+}
+
+func (h *SubUserHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *SubUserHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// TempCredentialHandler handles short-lived plan credentials
+type TempCredentialHandler struct {
+	credentials *service.TempCredentialService
+	planService service.PlanService
+	logger      *zap.Logger
+}
+
+// NewTempCredentialHandler creates a new temporary credential handler
+func NewTempCredentialHandler(credentials *service.TempCredentialService, planService service.PlanService, logger *zap.Logger) *TempCredentialHandler {
+	return &TempCredentialHandler{
+		credentials: credentials,
+		planService: planService,
+		logger:      logger,
+	}
+}
+
+// IssueTempCredential issues a short-lived credential for a plan
+// @Summary Issue temporary plan credential
+// @Description Add a username and password that work on the plan's proxies until they expire, e.g. for a contractor. Running instances are restarted to pick it up, and again when it expires.
+// @Tags plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param request body domain.TempCredentialRequest false "Lifetime and label"
+// @Success 201 {object} domain.TempCredentialResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/temp-credentials [post]
+func (h *TempCredentialHandler) IssueTempCredential(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	var req domain.TempCredentialRequest
+	if err := decodeJSON(r, &req); err != nil && err != io.EOF {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if _, err := h.planService.GetPlan(r.Context(), planID); err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	credential, err := h.credentials.Issue(r.Context(), planID, &req, time.Now())
+	if err != nil {
+		if domain.IsPolicyError(err) {
+			h.respondWithError(w, http.StatusBadRequest, "Cannot issue temporary credential", err)
+			return
+		}
+		h.logger.Error("Failed to issue temporary credential", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to issue temporary credential", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, credential)
+}
+
+// RevokeTempCredential removes a plan's temporary credential early
+// @Summary Revoke temporary plan credential
+// @Description Remove a temporary credential before it expires; running instances are restarted without it
+// @Tags plans
+// @Param id path string true "Plan ID"
+// @Param credential_id path string true "Credential ID"
+// @Success 204
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /plans/{id}/temp-credentials/{credential_id} [delete]
+func (h *TempCredentialHandler) RevokeTempCredential(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+	credentialID, err := uuid.Parse(chi.URLParam(r, "credential_id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid credential ID", err)
+		return
+	}
+
+	if _, err := h.planService.GetPlan(r.Context(), planID); err != nil {
+		h.respondWithError(w, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	if err := h.credentials.Revoke(r.Context(), planID, credentialID, time.Now()); err != nil {
+		if stderrors.Is(err, service.ErrTempCredentialNotFound) {
+			h.respondWithError(w, http.StatusNotFound, "Temporary credential not found", err)
+			return
+		}
+		h.logger.Error("Failed to revoke temporary credential", zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to revoke temporary credential", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Helper methods
+func (h *TempCredentialHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *TempCredentialHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// Page sizes of v2 list endpoints
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// ListEnvelope wraps v2 list responses
+type ListEnvelope struct {
+	Data       interface{} `json:"data"`
+	Pagination Pagination  `json:"pagination"`
+}
+
+// ItemEnvelope wraps v2 single-resource responses
+type ItemEnvelope struct {
+	Data interface{} `json:"data"`
+}
+
+// Pagination describes a page of a v2 list. NextOffset is omitted on the
+// last page.
+type Pagination struct {
+	Limit      int  `json:"limit"`
+	Offset     int  `json:"offset"`
+	Total      int  `json:"total"`
+	NextOffset *int `json:"next_offset,omitempty"`
+}
+
+// V2Handler serves the /api/v2 namespace. Lists are paginated and wrapped
+// in envelopes, and errors carry a code and type matching their status and
+// the request ID.
+type V2Handler struct {
+	planService  service.PlanService
+	proxyService service.ProxyService
+	logger       *zap.Logger
+}
+
+// NewV2Handler creates a new v2 API handler
+func NewV2Handler(planService service.PlanService, proxyService service.ProxyService, logger *zap.Logger) *V2Handler {
+	return &V2Handler{
+		planService:  planService,
+		proxyService: proxyService,
+		logger:       logger,
+	}
+}
+
+// CreatePlan creates a new proxy plan
+// @Summary Create proxy plan (v2)
+// @Description Same as POST /api/v1/plans, with the response wrapped in an envelope and typed errors
+// @Tags v2
+// @Accept json
+// @Produce json
+// @Param request body domain.CreatePlanRequest true "Plan creation request"
+// @Success 201 {object} ItemEnvelope
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /v2/plans [post]
+func (h *V2Handler) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	var req domain.CreatePlanRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.respondWithError(w, r, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	if req.Provider == domain.ProviderProxiesFo {
+		// Proxies.fo generates credentials; ignore any provided values
+		req.Username = ""
+		req.Password = ""
+	}
+
+	response, err := h.planService.CreatePlan(r.Context(), &req)
+	if err != nil {
+		switch {
+		case domain.IsPolicyError(err):
+			h.respondWithError(w, r, http.StatusBadRequest, "Plan request violates plan type policy", err)
+		case domain.IsDuplicateUsername(err):
+			h.respondWithError(w, r, http.StatusConflict, "Username already in use", err)
+		default:
+			h.logger.Error("Failed to create plan", zap.Error(err))
+			h.respondWithError(w, r, http.StatusInternalServerError, "Failed to create plan", err)
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, ItemEnvelope{Data: response})
+}
+
+// GetPlans lists plans a page at a time
+// @Summary List plans (v2)
+// @Description Plans oldest first, paginated
+// @Tags v2
+// @Produce json
+// @Param customer_id query string false "Only this customer's plans"
+// @Param status query string false "Only plans in this status"
+// @Param limit query int false "Page size (default 50, at most 500)"
+// @Param offset query int false "Items to skip"
+// @Success 200 {object} ListEnvelope
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /v2/plans [get]
+func (h *V2Handler) GetPlans(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		h.respondWithError(w, r, http.StatusBadRequest, "Invalid pagination", err)
+		return
+	}
+
+	var plans []*domain.ProxyPlan
+	if customerID := r.URL.Query().Get("customer_id"); customerID != "" {
+		plans, err = h.planService.GetPlansByCustomer(r.Context(), customerID)
+	} else {
+		plans, err = h.planService.GetAllPlans(r.Context())
+	}
+	if err != nil {
+		h.logger.Error("Failed to get plans", zap.Error(err))
+		h.respondWithError(w, r, http.StatusInternalServerError, "Failed to get plans", err)
+		return
+	}
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		filtered := make([]*domain.ProxyPlan, 0, len(plans))
+		for _, plan := range plans {
+			if plan.Status == status {
+				filtered = append(filtered, plan)
+			}
+		}
+		plans = filtered
+	}
+	sort.SliceStable(plans, func(i, j int) bool {
+		return plans[i].CreatedAt.Before(plans[j].CreatedAt)
+	})
+
+	start, end, pagination := paginate(len(plans), limit, offset)
+	h.respondWithJSON(w, http.StatusOK, ListEnvelope{Data: plans[start:end], Pagination: pagination})
+}
+
+// GetPlan returns a plan
+// @Summary Get plan (v2)
+// @Tags v2
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Success 200 {object} ItemEnvelope
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /v2/plans/{id} [get]
+func (h *V2Handler) GetPlan(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, r, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+
+	plan, err := h.planService.GetPlan(r.Context(), planID)
+	if err != nil {
+		h.respondWithError(w, r, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, ItemEnvelope{Data: plan})
+}
+
+// GetPlanEvents lists a plan's events a page at a time
+// @Summary List plan events (v2)
+// @Description The plan's events oldest first, paginated
+// @Tags v2
+// @Produce json
+// @Param id path string true "Plan ID"
+// @Param limit query int false "Page size (default 50, at most 500)"
+// @Param offset query int false "Items to skip"
+// @Success 200 {object} ListEnvelope
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /v2/plans/{id}/events [get]
+func (h *V2Handler) GetPlanEvents(w http.ResponseWriter, r *http.Request) {
+	planID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, r, http.StatusBadRequest, "Invalid plan ID", err)
+		return
+	}
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		h.respondWithError(w, r, http.StatusBadRequest, "Invalid pagination", err)
+		return
+	}
+
+	events, err := h.planService.GetPlanEvents(r.Context(), planID)
+	if err != nil {
+		h.respondWithError(w, r, http.StatusNotFound, "Plan not found", err)
+		return
+	}
+
+	start, end, pagination := paginate(len(events), limit, offset)
+	h.respondWithJSON(w, http.StatusOK, ListEnvelope{Data: events[start:end], Pagination: pagination})
+}
+
+// GetProxies lists proxy instances a page at a time
+// @Summary List proxy instances (v2)
+// @Description Running instances, or every instance of a plan, oldest first and paginated
+// @Tags v2
+// @Produce json
+// @Param plan_id query string false "Only this plan's instances, in any status"
+// @Param status query string false "Only instances in this status"
+// @Param limit query int false "Page size (default 50, at most 500)"
+// @Param offset query int false "Items to skip"
+// @Success 200 {object} ListEnvelope
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /v2/proxies [get]
+func (h *V2Handler) GetProxies(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		h.respondWithError(w, r, http.StatusBadRequest, "Invalid pagination", err)
+		return
+	}
+
+	var instances []*domain.ProxyInstance
+	if raw := r.URL.Query().Get("plan_id"); raw != "" {
+		planID, parseErr := uuid.Parse(raw)
+		if parseErr != nil {
+			h.respondWithError(w, r, http.StatusBadRequest, "Invalid plan ID", parseErr)
+			return
+		}
+		instances, err = h.proxyService.GetInstancesByPlan(r.Context(), planID)
+	} else {
+		instances, err = h.proxyService.GetRunningInstances(r.Context())
+	}
+	if err != nil {
+		h.logger.Error("Failed to get proxy instances", zap.Error(err))
+		h.respondWithError(w, r, http.StatusInternalServerError, "Failed to get proxy instances", err)
+		return
+	}
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		filtered := make([]*domain.ProxyInstance, 0, len(instances))
+		for _, instance := range instances {
+			if instance.Status == status {
+				filtered = append(filtered, instance)
+			}
+		}
+		instances = filtered
+	}
+	sort.SliceStable(instances, func(i, j int) bool {
+		return instances[i].CreatedAt.Before(instances[j].CreatedAt)
+	})
+
+	start, end, pagination := paginate(len(instances), limit, offset)
+	h.respondWithJSON(w, http.StatusOK, ListEnvelope{Data: instances[start:end], Pagination: pagination})
+}
+
+// pageParams parses ?limit and ?offset
+func pageParams(r *http.Request) (int, int, error) {
+	limit := defaultPageLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxPageLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+		limit = parsed
+	}
+
+	offset := 0
+	if raw := r.URL.Query().Get("offset"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+		offset = parsed
+	}
+
+	return limit, offset, nil
+}
+
+// paginate returns the bounds of the requested page of total items
+func paginate(total, limit, offset int) (int, int, Pagination) {
+	pagination := Pagination{Limit: limit, Offset: offset, Total: total}
+
+	start := offset
+	if start > total {
+		start = total
+	}
+	end := start + limit
+	if end > total {
+		end = total
+	}
+	if end < total {
+		next := end
+		pagination.NextOffset = &next
+	}
+
+	return start, end, pagination
+}
+
+func (h *V2Handler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *V2Handler) respondWithError(w http.ResponseWriter, r *http.Request, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse.WithRequestID(middleware.GetReqID(r.Context())))
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/service"
+)
+
+// VoucherHandler handles voucher management
+type VoucherHandler struct {
+	vouchers *service.VoucherService
+	logger   *zap.Logger
+}
+
+// NewVoucherHandler creates a new voucher handler
+func NewVoucherHandler(vouchers *service.VoucherService, logger *zap.Logger) *VoucherHandler {
+	return &VoucherHandler{
+		vouchers: vouchers,
+		logger:   logger,
+	}
+}
+
+// GenerateVouchers creates vouchers
+// @Summary Generate vouchers
+// @Description Creates count vouchers with the same terms: a discount off the plan's price, or a trial granting fixed bandwidth and duration. Constraints limit the plan types, bandwidth, customer, redemptions and validity. Codes are generated as PREFIX-XXXX-XXXX unless code is set for a single voucher.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body domain.GenerateVouchersRequest true "Voucher terms"
+// @Success 201 {object} domain.GenerateVouchersResponse
+// @Failure 400 {object} errors.ErrorResponse
+// @Failure 409 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/vouchers [post]
+func (h *VoucherHandler) GenerateVouchers(w http.ResponseWriter, r *http.Request) {
+	var req domain.GenerateVouchersRequest
+	if err := decodeJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	response, err := h.vouchers.Generate(r.Context(), &req, time.Now())
+	if err != nil {
+		h.respondWithVoucherError(w, "generate vouchers", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, response)
+}
+
+// GetVouchers lists vouchers
+// @Summary List vouchers
+// @Description Every voucher with its status (active, revoked, expired or redeemed), newest first
+// @Tags admin
+// @Produce json
+// @Success 200 {array} domain.VoucherResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/vouchers [get]
+func (h *VoucherHandler) GetVouchers(w http.ResponseWriter, r *http.Request) {
+	vouchers, err := h.vouchers.List(r.Context(), time.Now())
+	if err != nil {
+		h.respondWithVoucherError(w, "list vouchers", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, vouchers)
+}
+
+// GetVoucher returns a voucher with its redemptions
+// @Summary Get voucher
+// @Tags admin
+// @Produce json
+// @Param code path string true "Voucher code"
+// @Success 200 {object} domain.VoucherResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/vouchers/{code} [get]
+func (h *VoucherHandler) GetVoucher(w http.ResponseWriter, r *http.Request) {
+	voucher, err := h.vouchers.Get(r.Context(), chi.URLParam(r, "code"), time.Now())
+	if err != nil {
+		h.respondWithVoucherError(w, "get voucher", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, voucher)
+}
+
+// RevokeVoucher stops a voucher being redeemed
+// @Summary Revoke voucher
+// @Description The voucher can no longer be redeemed; plans already created with it are not affected
+// @Tags admin
+// @Produce json
+// @Param code path string true "Voucher code"
+// @Success 200 {object} domain.VoucherResponse
+// @Failure 404 {object} errors.ErrorResponse
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/vouchers/{code}/revoke [post]
+func (h *VoucherHandler) RevokeVoucher(w http.ResponseWriter, r *http.Request) {
+	voucher, err := h.vouchers.Revoke(r.Context(), chi.URLParam(r, "code"), time.Now())
+	if err != nil {
+		h.respondWithVoucherError(w, "revoke voucher", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, voucher)
+}
+
+// GetRedemptions returns the redemption audit
+// @Summary List voucher redemptions
+// @Description Every voucher redemption, newest first. Redemptions of plans that could not be provisioned are released, giving the use back, and kept with released_at set.
+// @Tags admin
+// @Produce json
+// @Success 200 {array} domain.VoucherRedemption
+// @Failure 500 {object} errors.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/vouchers/redemptions [get]
+func (h *VoucherHandler) GetRedemptions(w http.ResponseWriter, r *http.Request) {
+	redemptions, err := h.vouchers.Redemptions(r.Context())
+	if err != nil {
+		h.respondWithVoucherError(w, "list voucher redemptions", err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, redemptions)
+}
+
+// respondWithVoucherError maps voucher service errors to responses
+func (h *VoucherHandler) respondWithVoucherError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case stderrors.Is(err, service.ErrVoucherNotFound):
+		h.respondWithError(w, http.StatusNotFound, "Voucher not found", err)
+	case stderrors.Is(err, service.ErrVoucherExists):
+		h.respondWithError(w, http.StatusConflict, "Voucher already exists", err)
+	case stderrors.Is(err, service.ErrInvalidVoucher):
+		h.respondWithError(w, http.StatusBadRequest, "Invalid voucher", err)
+	default:
+		h.logger.Error("Failed to "+action, zap.Error(err))
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to "+action, err)
+	}
+}
+
+func (h *VoucherHandler) respondWithJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.logger.Error("Failed to encode JSON response", zap.Error(err))
+	}
+}
+
+func (h *VoucherHandler) respondWithError(w http.ResponseWriter, statusCode int, message string, err error) {
+	statusCode, errorResponse := newServiceErrorResponse(statusCode, message, err)
+	h.respondWithJSON(w, statusCode, errorResponse)
+}
@@ -2,6 +2,7 @@ package errors
 
 import (
 	"fmt"
+	"net/http"
 	"time"
 )
 
@@ -228,6 +229,31 @@ func NewConfigError(message string, err error) *ErrorResponse {
 	}
 }
 
+// NewStatusError creates an error response whose code and type follow the
+// HTTP status code
+func NewStatusError(statusCode int, message string, err error) *ErrorResponse {
+	response := NewErrorResponse(message, err)
+	switch {
+	case statusCode == http.StatusBadRequest:
+		response.Error.Code, response.Error.Type = CodeInvalidInput, TypeValidation
+	case statusCode == http.StatusUnauthorized:
+		response.Error.Code, response.Error.Type = CodeUnauthorized, TypeAuthentication
+	case statusCode == http.StatusForbidden:
+		response.Error.Code, response.Error.Type = CodeForbidden, TypeAuthorization
+	case statusCode == http.StatusNotFound:
+		response.Error.Code, response.Error.Type = CodeNotFound, TypeNotFound
+	case statusCode == http.StatusConflict:
+		response.Error.Code, response.Error.Type = CodeAlreadyExists, TypeConflict
+	case statusCode == http.StatusTooManyRequests:
+		response.Error.Code, response.Error.Type = CodeRateLimitExceeded, TypeRateLimit
+	case statusCode == http.StatusServiceUnavailable:
+		response.Error.Type = TypeServiceUnavailable
+	case statusCode < 500:
+		response.Error.Code, response.Error.Type = CodeInvalidInput, TypeBadRequest
+	}
+	return response
+}
+
 // WithRequestID adds a request ID to the error response
 func (e *ErrorResponse) WithRequestID(requestID string) *ErrorResponse {
 	e.RequestID = requestID
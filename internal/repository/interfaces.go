@@ -11,9 +11,14 @@ import (
 
 // PlanRepository defines the interface for plan data persistence
 type PlanRepository interface {
-	// Create creates a new proxy plan
+	// Create creates a new proxy plan. Create and Update fail with
+	// domain.ErrDuplicateUsername if the plan's username conflicts with
+	// another plan's.
 	Create(ctx context.Context, plan *domain.ProxyPlan) error
 
+	// GetByUsername retrieves the plans using a username
+	GetByUsername(ctx context.Context, username string) ([]*domain.ProxyPlan, error)
+
 	// GetByID retrieves a plan by its ID
 	GetByID(ctx context.Context, id uuid.UUID) (*domain.ProxyPlan, error)
 
@@ -32,6 +37,9 @@ type PlanRepository interface {
 	// GetExpired retrieves all plans that have expired before the given time
 	GetExpired(ctx context.Context, before time.Time) ([]*domain.ProxyPlan, error)
 
+	// GetExpiring retrieves active plans expiring between from and to, soonest first
+	GetExpiring(ctx context.Context, from, to time.Time) ([]*domain.ProxyPlan, error)
+
 	// GetByStatus retrieves all plans with a specific status
 	GetByStatus(ctx context.Context, status string) ([]*domain.ProxyPlan, error)
 
@@ -46,6 +54,15 @@ type PlanRepository interface {
 
 	// CountByStatus returns the number of plans with a specific status
 	CountByStatus(ctx context.Context, status string) (int, error)
+
+	// CountsByStatus returns the number of plans in every status in one pass
+	CountsByStatus(ctx context.Context) (map[string]int, error)
+
+	// Summary returns all plan counters in one pass
+	Summary(ctx context.Context) (*PlanSummary, error)
+
+	// Version returns a counter that changes whenever any plan is written
+	Version(ctx context.Context) (uint64, error)
 }
 
 // InstanceRepository defines the interface for proxy instance data persistence
@@ -86,8 +103,197 @@ type InstanceRepository interface {
 	// CountByStatus returns the number of instances with a specific status
 	CountByStatus(ctx context.Context, status string) (int, error)
 
+	// CountsByStatus returns the number of instances in every status in one pass
+	CountsByStatus(ctx context.Context) (map[string]int, error)
+
+	// Summary returns all instance counters in one pass
+	Summary(ctx context.Context) (*InstanceSummary, error)
+
 	// GetPortsInUse returns all ports currently in use
 	GetPortsInUse(ctx context.Context) ([]int, error)
+
+	// Version returns a counter that changes whenever any instance is written
+	Version(ctx context.Context) (uint64, error)
+}
+
+// ProviderAccountRepository defines the interface for upstream provider account persistence
+type ProviderAccountRepository interface {
+	// Create creates a new provider account
+	Create(ctx context.Context, account *domain.ProviderAccount) error
+
+	// GetByID retrieves a provider account by its ID
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.ProviderAccount, error)
+
+	// GetByCustomer retrieves a customer's accounts with a provider, newest first
+	GetByCustomer(ctx context.Context, provider, customerID string) ([]*domain.ProviderAccount, error)
+
+	// GetByPlanID retrieves the account linked to a plan
+	GetByPlanID(ctx context.Context, planID uuid.UUID) (*domain.ProviderAccount, error)
+
+	// GetAll retrieves all provider accounts
+	GetAll(ctx context.Context) ([]*domain.ProviderAccount, error)
+
+	// Update updates an existing provider account
+	Update(ctx context.Context, account *domain.ProviderAccount) error
+
+	// Delete deletes a provider account by ID
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// APIKeyRepository defines the interface for customer API key persistence
+type APIKeyRepository interface {
+	// Create stores a new API key
+	Create(ctx context.Context, key *domain.APIKey) error
+
+	// GetByID retrieves an API key by its ID
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.APIKey, error)
+
+	// GetByHash retrieves the API key with the given key hash
+	GetByHash(ctx context.Context, hash string) (*domain.APIKey, error)
+
+	// GetByPlanID retrieves a plan's API keys, including revoked ones, newest first
+	GetByPlanID(ctx context.Context, planID uuid.UUID) ([]*domain.APIKey, error)
+
+	// Update updates an existing API key
+	Update(ctx context.Context, key *domain.APIKey) error
+}
+
+// IncidentRepository defines the interface for incident persistence
+type IncidentRepository interface {
+	// Create stores a new incident
+	Create(ctx context.Context, incident *domain.Incident) error
+
+	// GetByID retrieves an incident by its ID
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Incident, error)
+
+	// GetAll retrieves every incident, newest first
+	GetAll(ctx context.Context) ([]*domain.Incident, error)
+
+	// Update updates an existing incident
+	Update(ctx context.Context, incident *domain.Incident) error
+}
+
+// RegionRepository defines the interface for region persistence
+type RegionRepository interface {
+	// Create stores a new region
+	Create(ctx context.Context, region *domain.Region) error
+
+	// GetByName retrieves a region by its name
+	GetByName(ctx context.Context, name string) (*domain.Region, error)
+
+	// GetAll retrieves every region, by name
+	GetAll(ctx context.Context) ([]*domain.Region, error)
+
+	// Update replaces an existing region
+	Update(ctx context.Context, region *domain.Region) error
+
+	// Delete deletes a region by name
+	Delete(ctx context.Context, name string) error
+}
+
+// PlanTypeRepository defines the interface for plan type persistence
+type PlanTypeRepository interface {
+	// Create stores a new plan type under its key
+	Create(ctx context.Context, key string, planType *domain.PlanTypeConfig) error
+
+	// GetByKey retrieves a plan type by its key
+	GetByKey(ctx context.Context, key string) (*domain.PlanTypeConfig, error)
+
+	// GetAll retrieves every plan type, by key
+	GetAll(ctx context.Context) (map[string]*domain.PlanTypeConfig, error)
+
+	// Update replaces an existing plan type
+	Update(ctx context.Context, key string, planType *domain.PlanTypeConfig) error
+}
+
+// GitOpsRepository defines the interface for GitOps controller state persistence
+type GitOpsRepository interface {
+	// GetState retrieves the controller state; it is empty before the first sync
+	GetState(ctx context.Context) (*domain.GitOpsState, error)
+
+	// SaveState replaces the controller state
+	SaveState(ctx context.Context, state *domain.GitOpsState) error
+}
+
+// AppliedPlanRepository defines the interface for tracking the plans created
+// by the apply API
+type AppliedPlanRepository interface {
+	// GetAll retrieves the plan IDs by manifest name
+	GetAll(ctx context.Context) (map[string]uuid.UUID, error)
+
+	// SaveAll replaces the plan IDs by manifest name
+	SaveAll(ctx context.Context, plans map[string]uuid.UUID) error
+}
+
+// SubUserUsageRepository defines the interface for sub-user traffic
+// counters, kept apart from plans so counting never races plan updates
+type SubUserUsageRepository interface {
+	// GetAll retrieves the usage of every sub-user by ID
+	GetAll(ctx context.Context) (map[uuid.UUID]*domain.SubUserUsage, error)
+
+	// Add adds traffic to the counters of sub-users
+	Add(ctx context.Context, traffic map[uuid.UUID]*domain.SubUserUsage) error
+
+	// Delete removes the counters of sub-users
+	Delete(ctx context.Context, ids ...uuid.UUID) error
+}
+
+// VoucherRepository defines the interface for voucher and redemption persistence
+type VoucherRepository interface {
+	// Create stores new vouchers, failing if any code is taken
+	Create(ctx context.Context, vouchers []*domain.Voucher) error
+
+	// GetByCode retrieves a voucher by its normalized code, or nil if there is none
+	GetByCode(ctx context.Context, code string) (*domain.Voucher, error)
+
+	// GetAll retrieves every voucher, newest first
+	GetAll(ctx context.Context) ([]*domain.Voucher, error)
+
+	// Update updates an existing voucher
+	Update(ctx context.Context, voucher *domain.Voucher) error
+
+	// CreateRedemption stores a redemption record
+	CreateRedemption(ctx context.Context, redemption *domain.VoucherRedemption) error
+
+	// UpdateRedemption updates an existing redemption record
+	UpdateRedemption(ctx context.Context, redemption *domain.VoucherRedemption) error
+
+	// GetRedemptions retrieves the redemptions of a voucher, or of every
+	// voucher when code is empty, newest first
+	GetRedemptions(ctx context.Context, code string) ([]*domain.VoucherRedemption, error)
+}
+
+// AlertRepository defines the interface for persisting the alerts that are
+// firing, so they can still be resolved after a restart
+type AlertRepository interface {
+	// GetAll retrieves every alert, oldest first
+	GetAll(ctx context.Context) ([]*domain.Alert, error)
+
+	// Save creates or replaces the alert with the same key
+	Save(ctx context.Context, alert *domain.Alert) error
+
+	// Delete removes the alert with key
+	Delete(ctx context.Context, key string) error
+}
+
+// MaintenanceRepository defines the interface for provider maintenance
+// window persistence
+type MaintenanceRepository interface {
+	// GetAll retrieves every window, by start time
+	GetAll(ctx context.Context) ([]*domain.MaintenanceWindow, error)
+
+	// Create stores a new window
+	Create(ctx context.Context, window *domain.MaintenanceWindow) error
+
+	// Delete removes a window
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// ReplaceSource replaces every window of source with windows in one write
+	ReplaceSource(ctx context.Context, source string, windows []*domain.MaintenanceWindow) error
+
+	// DeleteEndedBefore removes windows that ended before cutoff and
+	// returns how many were removed
+	DeleteEndedBefore(ctx context.Context, cutoff time.Time) (int, error)
 }
 
 // UserRepository defines the interface for user data persistence (future use)
@@ -117,41 +323,128 @@ type UserRepository interface {
 	Count(ctx context.Context) (int, error)
 }
 
-// StatsRepository defines the interface for statistics and metrics
+// Stats resolutions, finest first
+const (
+	StatsResolutionRaw    = "raw" // one-minute buckets
+	StatsResolutionHourly = "hourly"
+	StatsResolutionDaily  = "daily"
+)
+
+// StatsRepository defines the interface for statistics and metrics. Traffic
+// is stored in time buckets per resolution; raw buckets are rolled up into
+// hourly and hourly into daily buckets, after which finer data can be pruned.
 type StatsRepository interface {
 	// RecordRequest records a proxy request
-	RecordRequest(ctx context.Context, instanceID uuid.UUID, bytesIn, bytesOut int64) error
+	RecordRequest(ctx context.Context, planID, instanceID uuid.UUID, bytesIn, bytesOut int64) error
+
+	// RecordTraffic records a batch of collected requests in one write
+	RecordTraffic(ctx context.Context, samples []TrafficSample) error
 
 	// GetInstanceStats retrieves statistics for a specific instance
-	GetInstanceStats(ctx context.Context, instanceID uuid.UUID, from, to time.Time) (*InstanceStats, error)
+	GetInstanceStats(ctx context.Context, instanceID uuid.UUID, from, to time.Time, resolution string) (*InstanceStats, error)
+
+	// GetPlanStats retrieves traffic statistics for a specific plan
+	GetPlanStats(ctx context.Context, planID uuid.UUID, from, to time.Time, resolution string) (*PlanStats, error)
+
+	// GetOverallStats retrieves overall traffic statistics
+	GetOverallStats(ctx context.Context, from, to time.Time, resolution string) (*OverallStats, error)
+
+	// GetPlanTraffic retrieves a plan's traffic bucket by bucket, so it can
+	// be weighed by when it was sent
+	GetPlanTraffic(ctx context.Context, planID uuid.UUID, from, to time.Time, resolution string) ([]TrafficPeriod, error)
+
+	// Rollup aggregates buckets of resolution from into resolution to for
+	// whole periods ending at or before until, returning the buckets written
+	Rollup(ctx context.Context, from, to string, until time.Time) (int, error)
+
+	// Prune deletes buckets of a resolution that start before cutoff
+	Prune(ctx context.Context, resolution string, before time.Time) (int, error)
+}
+
+// StatsBucket is the traffic of one instance during one period
+type StatsBucket struct {
+	PlanID     uuid.UUID `json:"plan_id"`
+	InstanceID uuid.UUID `json:"instance_id"`
+	Start      time.Time `json:"start"`
+	Requests   int64     `json:"requests"`
+	BytesIn    int64     `json:"bytes_in"`
+	BytesOut   int64     `json:"bytes_out"`
+
+	// Reaped counts connections closed on the plan type's idle timeout
+	Reaped int64 `json:"reaped,omitempty"`
+
+	// Per-country breakdowns, keyed by ISO country code
+	ClientCountries      map[string]CountryTraffic `json:"client_countries,omitempty"`
+	DestinationCountries map[string]CountryTraffic `json:"destination_countries,omitempty"`
+}
 
-	// GetPlanStats retrieves statistics for a specific plan
-	GetPlanStats(ctx context.Context, planID uuid.UUID, from, to time.Time) (*PlanStats, error)
+// UnknownCountry is the country code traffic is attributed to when the
+// country of an address is not known, as for relayed or private addresses
+const UnknownCountry = "ZZ"
 
-	// GetOverallStats retrieves overall system statistics
-	GetOverallStats(ctx context.Context, from, to time.Time) (*OverallStats, error)
+// CountryTraffic is the traffic attributed to one country
+type CountryTraffic struct {
+	Requests int64 `json:"requests"`
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+}
+
+// TrafficSample is one proxied request collected from an instance's log.
+// Country codes are empty when the traffic is not broken down by country.
+type TrafficSample struct {
+	PlanID             uuid.UUID
+	InstanceID         uuid.UUID
+	Time               time.Time
+	BytesIn            int64
+	BytesOut           int64
+	ClientCountry      string
+	DestinationCountry string
+
+	// Reaped is set when the connection was closed on the idle timeout
+	Reaped bool
+}
+
+// TrafficPeriod is the traffic of one instance during [Start, End)
+type TrafficPeriod struct {
+	InstanceID uuid.UUID
+	Start      time.Time
+	End        time.Time
+	Requests   int64
+	BytesIn    int64
+	BytesOut   int64
 }
 
 // Statistics data structures
 type InstanceStats struct {
 	InstanceID    uuid.UUID     `json:"instance_id"`
+	Resolution    string        `json:"resolution"`
 	TotalRequests int64         `json:"total_requests"`
 	BytesIn       int64         `json:"bytes_in"`
 	BytesOut      int64         `json:"bytes_out"`
+	Reaped        int64         `json:"reaped_connections"`
 	Uptime        time.Duration `json:"uptime"`
 	LastActivity  time.Time     `json:"last_activity"`
+
+	ClientCountries      map[string]CountryTraffic `json:"client_countries,omitempty"`
+	DestinationCountries map[string]CountryTraffic `json:"destination_countries,omitempty"`
 }
 
 type PlanStats struct {
 	PlanID          uuid.UUID `json:"plan_id"`
+	Resolution      string    `json:"resolution"`
 	TotalRequests   int64     `json:"total_requests"`
 	BytesIn         int64     `json:"bytes_in"`
 	BytesOut        int64     `json:"bytes_out"`
+	Reaped          int64     `json:"reaped_connections"`
 	ActiveInstances int       `json:"active_instances"`
 	TotalInstances  int       `json:"total_instances"`
+
+	ClientCountries      map[string]CountryTraffic `json:"client_countries,omitempty"`
+	DestinationCountries map[string]CountryTraffic `json:"destination_countries,omitempty"`
 }
 
 type OverallStats struct {
+	Resolution       string         `json:"resolution"`
 	TotalPlans       int            `json:"total_plans"`
 	ActivePlans      int            `json:"active_plans"`
 	TotalInstances   int            `json:"total_instances"`
@@ -159,6 +452,34 @@ type OverallStats struct {
 	TotalRequests    int64          `json:"total_requests"`
 	BytesIn          int64          `json:"bytes_in"`
 	BytesOut         int64          `json:"bytes_out"`
+	Reaped           int64          `json:"reaped_connections"`
 	ProvidersUsed    map[string]int `json:"providers_used"`
 	RegionsUsed      map[string]int `json:"regions_used"`
+
+	ClientCountries      map[string]CountryTraffic `json:"client_countries,omitempty"`
+	DestinationCountries map[string]CountryTraffic `json:"destination_countries,omitempty"`
+}
+
+// PlanSummary aggregates plan counters
+type PlanSummary struct {
+	Total      int            `json:"total"`
+	ByStatus   map[string]int `json:"by_status"`
+	ByProvider map[string]int `json:"by_provider"`
+	ByRegion   map[string]int `json:"by_region"`
+}
+
+// InstanceSummary aggregates instance counters
+type InstanceSummary struct {
+	Total      int            `json:"total"`
+	ByStatus   map[string]int `json:"by_status"`
+	ByPlanType map[string]int `json:"by_plan_type"`
+}
+
+// PlanEventRepository defines the interface for the append-only plan event log
+type PlanEventRepository interface {
+	// Append records a new event for its plan
+	Append(ctx context.Context, event *domain.PlanEvent) error
+
+	// GetByPlanID retrieves a plan's events, oldest first
+	GetByPlanID(ctx context.Context, planID uuid.UUID) ([]*domain.PlanEvent, error)
 }
@@ -0,0 +1,148 @@
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// jsonAlertRepository implements AlertRepository using JSON file storage
+type jsonAlertRepository struct {
+	filePath string
+	logger   *zap.Logger
+	lock     *fileLock
+}
+
+type alertStorage struct {
+	schemaHeader
+	Alerts map[string]*domain.Alert `json:"alerts"`
+}
+
+// NewAlertRepository creates a new JSON-based alert repository
+func NewAlertRepository(filePath string, logger *zap.Logger) repository.AlertRepository {
+	return &jsonAlertRepository{
+		filePath: filePath + "_alerts",
+		lock:     newFileLock(filePath + "_alerts"),
+		logger:   logger,
+	}
+}
+
+func (r *jsonAlertRepository) GetAll(ctx context.Context) ([]*domain.Alert, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadAlerts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alerts: %w", err)
+	}
+
+	alerts := make([]*domain.Alert, 0, len(storage.Alerts))
+	for _, alert := range storage.Alerts {
+		alerts = append(alerts, alert)
+	}
+
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].TriggeredAt.Equal(alerts[j].TriggeredAt) {
+			return alerts[i].TriggeredAt.Before(alerts[j].TriggeredAt)
+		}
+		return alerts[i].Key < alerts[j].Key
+	})
+
+	return alerts, nil
+}
+
+func (r *jsonAlertRepository) Save(ctx context.Context, alert *domain.Alert) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadAlerts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load alerts: %w", err)
+	}
+
+	storage.Alerts[alert.Key] = alert
+
+	if err := r.saveAlerts(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save alerts: %w", err)
+	}
+
+	return nil
+}
+
+func (r *jsonAlertRepository) Delete(ctx context.Context, key string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadAlerts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load alerts: %w", err)
+	}
+
+	if _, exists := storage.Alerts[key]; !exists {
+		return nil
+	}
+	delete(storage.Alerts, key)
+
+	if err := r.saveAlerts(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save alerts: %w", err)
+	}
+
+	return nil
+}
+
+func (r *jsonAlertRepository) loadAlerts(ctx context.Context) (*alertStorage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	storage := &alertStorage{
+		Alerts: make(map[string]*domain.Alert),
+	}
+
+	data, err := r.lock.readFile(r.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return storage, nil
+	}
+
+	if err := json.Unmarshal(data, storage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	if err := storage.check(storeAlerts, r.filePath); err != nil {
+		return nil, err
+	}
+
+	if storage.Alerts == nil {
+		storage.Alerts = make(map[string]*domain.Alert)
+	}
+
+	return storage, nil
+}
+
+func (r *jsonAlertRepository) saveAlerts(ctx context.Context, storage *alertStorage) error {
+	// Do not commit a write the caller has already given up on
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage.stamp(storeAlerts)
+	data, err := json.MarshalIndent(storage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if err := r.lock.writeFile(r.filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
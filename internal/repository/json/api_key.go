@@ -0,0 +1,203 @@
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// jsonAPIKeyRepository implements APIKeyRepository using JSON file storage
+type jsonAPIKeyRepository struct {
+	filePath string
+	logger   *zap.Logger
+	lock     *fileLock
+}
+
+// storedAPIKey persists the key hash, which domain.APIKey keeps out of JSON
+// so it never reaches API responses
+type storedAPIKey struct {
+	*domain.APIKey
+	Hash string `json:"hash"`
+}
+
+type apiKeyStorage struct {
+	schemaHeader
+	Keys map[string]*storedAPIKey `json:"keys"`
+}
+
+// NewAPIKeyRepository creates a new JSON-based API key repository
+func NewAPIKeyRepository(filePath string, logger *zap.Logger) repository.APIKeyRepository {
+	return &jsonAPIKeyRepository{
+		filePath: filePath + "_api_keys",
+		lock:     newFileLock(filePath + "_api_keys"),
+		logger:   logger,
+	}
+}
+
+func (r *jsonAPIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	storage.Keys[key.ID.String()] = &storedAPIKey{APIKey: key, Hash: key.Hash}
+
+	if err := r.saveKeys(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save API keys: %w", err)
+	}
+
+	r.logger.Info("API key created",
+		zap.String("key_id", key.ID.String()),
+		zap.String("plan_id", key.PlanID.String()))
+	return nil
+}
+
+func (r *jsonAPIKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.APIKey, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	stored, exists := storage.Keys[id.String()]
+	if !exists {
+		return nil, domain.NotFoundf("API key not found: %s", id.String())
+	}
+
+	return stored.key(), nil
+}
+
+func (r *jsonAPIKeyRepository) GetByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	for _, stored := range storage.Keys {
+		if stored.Hash == hash {
+			return stored.key(), nil
+		}
+	}
+
+	return nil, domain.NotFoundf("API key not found")
+}
+
+func (r *jsonAPIKeyRepository) GetByPlanID(ctx context.Context, planID uuid.UUID) ([]*domain.APIKey, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	var keys []*domain.APIKey
+	for _, stored := range storage.Keys {
+		if stored.PlanID == planID {
+			keys = append(keys, stored.key())
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+func (r *jsonAPIKeyRepository) Update(ctx context.Context, key *domain.APIKey) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	if _, exists := storage.Keys[key.ID.String()]; !exists {
+		return domain.NotFoundf("API key not found: %s", key.ID.String())
+	}
+
+	storage.Keys[key.ID.String()] = &storedAPIKey{APIKey: key, Hash: key.Hash}
+
+	if err := r.saveKeys(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save API keys: %w", err)
+	}
+
+	r.logger.Debug("API key updated", zap.String("key_id", key.ID.String()))
+	return nil
+}
+
+// key returns the domain key with its hash restored
+func (s *storedAPIKey) key() *domain.APIKey {
+	s.APIKey.Hash = s.Hash
+	return s.APIKey
+}
+
+func (r *jsonAPIKeyRepository) loadKeys(ctx context.Context) (*apiKeyStorage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	storage := &apiKeyStorage{
+		Keys: make(map[string]*storedAPIKey),
+	}
+
+	data, err := r.lock.readFile(r.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return storage, nil
+	}
+
+	if err := json.Unmarshal(data, storage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	if err := storage.check(storeAPIKeys, r.filePath); err != nil {
+		return nil, err
+	}
+
+	for _, stored := range storage.Keys {
+		if stored.APIKey == nil {
+			stored.APIKey = &domain.APIKey{}
+		}
+	}
+
+	return storage, nil
+}
+
+func (r *jsonAPIKeyRepository) saveKeys(ctx context.Context, storage *apiKeyStorage) error {
+	// Do not commit a write the caller has already given up on
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage.stamp(storeAPIKeys)
+	data, err := json.MarshalIndent(storage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if err := r.lock.writeFile(r.filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// jsonAppliedPlanRepository implements AppliedPlanRepository using JSON file storage
+type jsonAppliedPlanRepository struct {
+	filePath string
+	logger   *zap.Logger
+	lock     *fileLock
+}
+
+type appliedPlanStorage struct {
+	schemaHeader
+	Plans map[string]uuid.UUID `json:"plans"`
+}
+
+// NewAppliedPlanRepository creates a new JSON-based applied plan repository
+func NewAppliedPlanRepository(filePath string, logger *zap.Logger) repository.AppliedPlanRepository {
+	return &jsonAppliedPlanRepository{
+		filePath: filePath + "_applied_plans",
+		lock:     newFileLock(filePath + "_applied_plans"),
+		logger:   logger,
+	}
+}
+
+func (r *jsonAppliedPlanRepository) GetAll(ctx context.Context) (map[string]uuid.UUID, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	storage := &appliedPlanStorage{}
+	data, err := r.lock.readFile(r.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load applied plans: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, storage); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+		}
+		if err := storage.check(storeAppliedPlans, r.filePath); err != nil {
+			return nil, err
+		}
+	}
+	if storage.Plans == nil {
+		storage.Plans = make(map[string]uuid.UUID)
+	}
+
+	return storage.Plans, nil
+}
+
+func (r *jsonAppliedPlanRepository) SaveAll(ctx context.Context, plans map[string]uuid.UUID) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// Do not commit a write the caller has already given up on
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage := &appliedPlanStorage{Plans: plans}
+	storage.stamp(storeAppliedPlans)
+	data, err := json.MarshalIndent(storage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if err := r.lock.writeFile(r.filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
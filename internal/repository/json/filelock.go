@@ -0,0 +1,166 @@
+package json
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrConcurrentModification is returned when a storage file changed between
+// being read and written back under an exclusive lock, which means some
+// writer is not taking the lock
+var ErrConcurrentModification = errors.New("storage file was modified concurrently")
+
+// fileLock serializes access to one storage file between goroutines and
+// between processes, so the server and the CLI can share the JSON files.
+// Goroutines are serialized by an RWMutex; processes by an advisory lock on
+// a hidden sidecar file next to the storage file, which stays in place while
+// the storage file itself is replaced on every write.
+type fileLock struct {
+	path string
+
+	mu      sync.RWMutex
+	state   sync.Mutex
+	readers int
+	file    *os.File
+
+	// exclusive is set while Lock is held; seen records what the holder
+	// last read so writeFile can verify the file did not change since
+	exclusive bool
+	seen      *fileSnapshot
+}
+
+// fileSnapshot identifies the contents of a storage file at read time
+type fileSnapshot struct {
+	exists bool
+	sum    [sha256.Size]byte
+}
+
+// newFileLock returns the lock for the storage file at path
+func newFileLock(path string) *fileLock {
+	return &fileLock{path: filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".lock")}
+}
+
+// Lock takes the lock for a read-modify-write cycle
+func (l *fileLock) Lock() {
+	l.mu.Lock()
+	l.file = l.acquire(true)
+	l.exclusive = true
+	l.seen = nil
+}
+
+// Unlock releases a lock taken with Lock
+func (l *fileLock) Unlock() {
+	l.exclusive = false
+	l.seen = nil
+	l.release()
+	l.mu.Unlock()
+}
+
+// RLock takes the lock for reading. Readers in this process share a single
+// shared advisory lock, held from the first RLock to the last RUnlock.
+func (l *fileLock) RLock() {
+	l.mu.RLock()
+	l.state.Lock()
+	if l.readers == 0 {
+		l.file = l.acquire(false)
+	}
+	l.readers++
+	l.state.Unlock()
+}
+
+// RUnlock releases a lock taken with RLock
+func (l *fileLock) RUnlock() {
+	l.state.Lock()
+	l.readers--
+	if l.readers == 0 {
+		l.release()
+	}
+	l.state.Unlock()
+	l.mu.RUnlock()
+}
+
+// acquire opens the sidecar file and locks it. If the file cannot be opened,
+// typically because the data directory does not exist yet, only the
+// in-process lock is held; the storage write fails on its own in that case.
+func (l *fileLock) acquire(exclusive bool) *os.File {
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil
+	}
+	if err := lockFile(file, exclusive); err != nil {
+		file.Close()
+		return nil
+	}
+	return file
+}
+
+func (l *fileLock) release() {
+	if l.file == nil {
+		return
+	}
+	_ = unlockFile(l.file)
+	l.file.Close()
+	l.file = nil
+}
+
+// readFile returns the contents of path, or nil if it does not exist
+func (l *fileLock) readFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if l.exclusive {
+		l.seen = &fileSnapshot{exists: err == nil, sum: sha256.Sum256(data)}
+	}
+	return data, nil
+}
+
+// writeFile replaces path with data. Under Lock it first verifies that the
+// file still holds what was last read, so an unlocked writer's changes are
+// reported instead of silently overwritten. The data is written to a
+// temporary file and renamed into place so readers never see a partial file.
+func (l *fileLock) writeFile(path string, data []byte, perm os.FileMode) error {
+	if l.exclusive && l.seen != nil {
+		current, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to verify file: %w", err)
+		}
+		if (err == nil) != l.seen.exists || sha256.Sum256(current) != l.seen.sum {
+			return ErrConcurrentModification
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	if l.exclusive {
+		l.seen = &fileSnapshot{exists: true, sum: sha256.Sum256(data)}
+	}
+	return nil
+}
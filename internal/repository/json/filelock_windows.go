@@ -0,0 +1,15 @@
+//go:build windows
+
+package json
+
+import "os"
+
+// lockFile is a no-op on Windows; only the in-process lock applies there
+func lockFile(file *os.File, exclusive bool) error {
+	return nil
+}
+
+// unlockFile is a no-op on Windows
+func unlockFile(file *os.File) error {
+	return nil
+}
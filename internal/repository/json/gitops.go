@@ -0,0 +1,86 @@
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// jsonGitOpsRepository implements GitOpsRepository using JSON file storage
+type jsonGitOpsRepository struct {
+	filePath string
+	logger   *zap.Logger
+	lock     *fileLock
+}
+
+type gitOpsStorage struct {
+	schemaHeader
+	Plans      map[string]uuid.UUID `json:"plans"`
+	LastReport *domain.GitOpsReport `json:"last_report,omitempty"`
+}
+
+// NewGitOpsRepository creates a new JSON-based GitOps state repository
+func NewGitOpsRepository(filePath string, logger *zap.Logger) repository.GitOpsRepository {
+	return &jsonGitOpsRepository{
+		filePath: filePath + "_gitops",
+		lock:     newFileLock(filePath + "_gitops"),
+		logger:   logger,
+	}
+}
+
+func (r *jsonGitOpsRepository) GetState(ctx context.Context) (*domain.GitOpsState, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	storage := &gitOpsStorage{}
+	data, err := r.lock.readFile(r.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load GitOps state: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, storage); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+		}
+		if err := storage.check(storeGitOps, r.filePath); err != nil {
+			return nil, err
+		}
+	}
+	if storage.Plans == nil {
+		storage.Plans = make(map[string]uuid.UUID)
+	}
+
+	return &domain.GitOpsState{Plans: storage.Plans, LastReport: storage.LastReport}, nil
+}
+
+func (r *jsonGitOpsRepository) SaveState(ctx context.Context, state *domain.GitOpsState) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// Do not commit a write the caller has already given up on
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage := &gitOpsStorage{Plans: state.Plans, LastReport: state.LastReport}
+	storage.stamp(storeGitOps)
+	data, err := json.MarshalIndent(storage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if err := r.lock.writeFile(r.filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// jsonIncidentRepository implements IncidentRepository using JSON file storage
+type jsonIncidentRepository struct {
+	filePath string
+	logger   *zap.Logger
+	lock     *fileLock
+}
+
+type incidentStorage struct {
+	schemaHeader
+	Incidents map[string]*domain.Incident `json:"incidents"`
+}
+
+// NewIncidentRepository creates a new JSON-based incident repository
+func NewIncidentRepository(filePath string, logger *zap.Logger) repository.IncidentRepository {
+	return &jsonIncidentRepository{
+		filePath: filePath + "_incidents",
+		lock:     newFileLock(filePath + "_incidents"),
+		logger:   logger,
+	}
+}
+
+func (r *jsonIncidentRepository) Create(ctx context.Context, incident *domain.Incident) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadIncidents(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load incidents: %w", err)
+	}
+
+	storage.Incidents[incident.ID.String()] = incident
+
+	if err := r.saveIncidents(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save incidents: %w", err)
+	}
+
+	r.logger.Info("Incident created",
+		zap.String("incident_id", incident.ID.String()),
+		zap.String("region", incident.Region))
+	return nil
+}
+
+func (r *jsonIncidentRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Incident, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadIncidents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load incidents: %w", err)
+	}
+
+	incident, exists := storage.Incidents[id.String()]
+	if !exists {
+		return nil, domain.NotFoundf("incident not found: %s", id.String())
+	}
+
+	return incident, nil
+}
+
+func (r *jsonIncidentRepository) GetAll(ctx context.Context) ([]*domain.Incident, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadIncidents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load incidents: %w", err)
+	}
+
+	incidents := make([]*domain.Incident, 0, len(storage.Incidents))
+	for _, incident := range storage.Incidents {
+		incidents = append(incidents, incident)
+	}
+
+	sort.Slice(incidents, func(i, j int) bool {
+		return incidents[i].OpenedAt.After(incidents[j].OpenedAt)
+	})
+
+	return incidents, nil
+}
+
+func (r *jsonIncidentRepository) Update(ctx context.Context, incident *domain.Incident) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadIncidents(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load incidents: %w", err)
+	}
+
+	if _, exists := storage.Incidents[incident.ID.String()]; !exists {
+		return domain.NotFoundf("incident not found: %s", incident.ID.String())
+	}
+
+	storage.Incidents[incident.ID.String()] = incident
+
+	if err := r.saveIncidents(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save incidents: %w", err)
+	}
+
+	r.logger.Debug("Incident updated", zap.String("incident_id", incident.ID.String()))
+	return nil
+}
+
+func (r *jsonIncidentRepository) loadIncidents(ctx context.Context) (*incidentStorage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	storage := &incidentStorage{
+		Incidents: make(map[string]*domain.Incident),
+	}
+
+	data, err := r.lock.readFile(r.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return storage, nil
+	}
+
+	if err := json.Unmarshal(data, storage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	if err := storage.check(storeIncidents, r.filePath); err != nil {
+		return nil, err
+	}
+	if storage.Incidents == nil {
+		storage.Incidents = make(map[string]*domain.Incident)
+	}
+
+	return storage, nil
+}
+
+func (r *jsonIncidentRepository) saveIncidents(ctx context.Context, storage *incidentStorage) error {
+	// Do not commit a write the caller has already given up on
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage.stamp(storeIncidents)
+	data, err := json.MarshalIndent(storage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if err := r.lock.writeFile(r.filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// jsonMaintenanceRepository implements MaintenanceRepository using JSON file storage
+type jsonMaintenanceRepository struct {
+	filePath string
+	logger   *zap.Logger
+	lock     *fileLock
+}
+
+type maintenanceStorage struct {
+	schemaHeader
+	Windows map[string]*domain.MaintenanceWindow `json:"windows"`
+}
+
+// NewMaintenanceRepository creates a new JSON-based maintenance window repository
+func NewMaintenanceRepository(filePath string, logger *zap.Logger) repository.MaintenanceRepository {
+	return &jsonMaintenanceRepository{
+		filePath: filePath + "_maintenance",
+		lock:     newFileLock(filePath + "_maintenance"),
+		logger:   logger,
+	}
+}
+
+func (r *jsonMaintenanceRepository) GetAll(ctx context.Context) ([]*domain.MaintenanceWindow, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadWindows(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load maintenance windows: %w", err)
+	}
+
+	windows := make([]*domain.MaintenanceWindow, 0, len(storage.Windows))
+	for _, window := range storage.Windows {
+		windows = append(windows, window)
+	}
+
+	sort.Slice(windows, func(i, j int) bool {
+		if !windows[i].StartsAt.Equal(windows[j].StartsAt) {
+			return windows[i].StartsAt.Before(windows[j].StartsAt)
+		}
+		return windows[i].ID.String() < windows[j].ID.String()
+	})
+
+	return windows, nil
+}
+
+func (r *jsonMaintenanceRepository) Create(ctx context.Context, window *domain.MaintenanceWindow) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadWindows(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load maintenance windows: %w", err)
+	}
+
+	if _, exists := storage.Windows[window.ID.String()]; exists {
+		return domain.Conflictf("maintenance window already exists: %s", window.ID.String())
+	}
+	storage.Windows[window.ID.String()] = window
+
+	if err := r.saveWindows(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save maintenance windows: %w", err)
+	}
+
+	return nil
+}
+
+func (r *jsonMaintenanceRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadWindows(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load maintenance windows: %w", err)
+	}
+
+	if _, exists := storage.Windows[id.String()]; !exists {
+		return domain.NotFoundf("maintenance window not found: %s", id.String())
+	}
+	delete(storage.Windows, id.String())
+
+	if err := r.saveWindows(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save maintenance windows: %w", err)
+	}
+
+	return nil
+}
+
+func (r *jsonMaintenanceRepository) ReplaceSource(ctx context.Context, source string, windows []*domain.MaintenanceWindow) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadWindows(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load maintenance windows: %w", err)
+	}
+
+	for id, window := range storage.Windows {
+		if window.Source == source {
+			delete(storage.Windows, id)
+		}
+	}
+	for _, window := range windows {
+		storage.Windows[window.ID.String()] = window
+	}
+
+	if err := r.saveWindows(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save maintenance windows: %w", err)
+	}
+
+	return nil
+}
+
+func (r *jsonMaintenanceRepository) DeleteEndedBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadWindows(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load maintenance windows: %w", err)
+	}
+
+	removed := 0
+	for id, window := range storage.Windows {
+		if window.EndsAt.Before(cutoff) {
+			delete(storage.Windows, id)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	if err := r.saveWindows(ctx, storage); err != nil {
+		return 0, fmt.Errorf("failed to save maintenance windows: %w", err)
+	}
+
+	return removed, nil
+}
+
+func (r *jsonMaintenanceRepository) loadWindows(ctx context.Context) (*maintenanceStorage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	storage := &maintenanceStorage{
+		Windows: make(map[string]*domain.MaintenanceWindow),
+	}
+
+	data, err := r.lock.readFile(r.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return storage, nil
+	}
+
+	if err := json.Unmarshal(data, storage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	if err := storage.check(storeMaintenance, r.filePath); err != nil {
+		return nil, err
+	}
+
+	if storage.Windows == nil {
+		storage.Windows = make(map[string]*domain.MaintenanceWindow)
+	}
+
+	return storage, nil
+}
+
+func (r *jsonMaintenanceRepository) saveWindows(ctx context.Context, storage *maintenanceStorage) error {
+	// Do not commit a write the caller has already given up on
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage.stamp(storeMaintenance)
+	data, err := json.MarshalIndent(storage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if err := r.lock.writeFile(r.filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
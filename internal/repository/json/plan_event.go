@@ -0,0 +1,122 @@
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// jsonPlanEventRepository implements PlanEventRepository using JSON file storage
+type jsonPlanEventRepository struct {
+	filePath string
+	logger   *zap.Logger
+	lock     *fileLock
+}
+
+type planEventStorage struct {
+	schemaHeader
+	Events map[string][]*domain.PlanEvent `json:"events"`
+}
+
+// NewPlanEventRepository creates a new JSON-based plan event repository
+func NewPlanEventRepository(filePath string, logger *zap.Logger) repository.PlanEventRepository {
+	return &jsonPlanEventRepository{
+		filePath: filePath + "_plan_events",
+		lock:     newFileLock(filePath + "_plan_events"),
+		logger:   logger,
+	}
+}
+
+func (r *jsonPlanEventRepository) Append(ctx context.Context, event *domain.PlanEvent) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadEvents(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load plan events: %w", err)
+	}
+
+	key := event.PlanID.String()
+	storage.Events[key] = append(storage.Events[key], event)
+
+	if err := r.saveEvents(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save plan events: %w", err)
+	}
+
+	return nil
+}
+
+func (r *jsonPlanEventRepository) GetByPlanID(ctx context.Context, planID uuid.UUID) ([]*domain.PlanEvent, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadEvents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plan events: %w", err)
+	}
+
+	// Events are appended in order, so the stored slice is already chronological
+	events := storage.Events[planID.String()]
+	if events == nil {
+		events = []*domain.PlanEvent{}
+	}
+
+	return events, nil
+}
+
+func (r *jsonPlanEventRepository) loadEvents(ctx context.Context) (*planEventStorage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	storage := &planEventStorage{
+		Events: make(map[string][]*domain.PlanEvent),
+	}
+
+	data, err := r.lock.readFile(r.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return storage, nil
+	}
+
+	if err := json.Unmarshal(data, storage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	if err := storage.check(storePlanEvents, r.filePath); err != nil {
+		return nil, err
+	}
+
+	if storage.Events == nil {
+		storage.Events = make(map[string][]*domain.PlanEvent)
+	}
+
+	return storage, nil
+}
+
+func (r *jsonPlanEventRepository) saveEvents(ctx context.Context, storage *planEventStorage) error {
+	// Do not commit a write the caller has already given up on
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage.stamp(storePlanEvents)
+	data, err := json.MarshalIndent(storage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if err := r.lock.writeFile(r.filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// jsonPlanTypeRepository implements PlanTypeRepository using JSON file storage
+type jsonPlanTypeRepository struct {
+	filePath string
+	logger   *zap.Logger
+	lock     *fileLock
+}
+
+type planTypeStorage struct {
+	schemaHeader
+	PlanTypes map[string]*domain.PlanTypeConfig `json:"plan_types"`
+}
+
+// NewPlanTypeRepository creates a new JSON-based plan type repository
+func NewPlanTypeRepository(filePath string, logger *zap.Logger) repository.PlanTypeRepository {
+	return &jsonPlanTypeRepository{
+		filePath: filePath + "_plan_types",
+		lock:     newFileLock(filePath + "_plan_types"),
+		logger:   logger,
+	}
+}
+
+func (r *jsonPlanTypeRepository) Create(ctx context.Context, key string, planType *domain.PlanTypeConfig) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadPlanTypes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load plan types: %w", err)
+	}
+
+	if _, exists := storage.PlanTypes[key]; exists {
+		return domain.Conflictf("plan type already exists: %s", key)
+	}
+	storage.PlanTypes[key] = planType
+
+	if err := r.savePlanTypes(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save plan types: %w", err)
+	}
+
+	r.logger.Info("Plan type created", zap.String("plan_type", key))
+	return nil
+}
+
+func (r *jsonPlanTypeRepository) GetByKey(ctx context.Context, key string) (*domain.PlanTypeConfig, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadPlanTypes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plan types: %w", err)
+	}
+
+	planType, exists := storage.PlanTypes[key]
+	if !exists {
+		return nil, domain.NotFoundf("plan type not found: %s", key)
+	}
+
+	return planType, nil
+}
+
+func (r *jsonPlanTypeRepository) GetAll(ctx context.Context) (map[string]*domain.PlanTypeConfig, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadPlanTypes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plan types: %w", err)
+	}
+
+	return storage.PlanTypes, nil
+}
+
+func (r *jsonPlanTypeRepository) Update(ctx context.Context, key string, planType *domain.PlanTypeConfig) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadPlanTypes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load plan types: %w", err)
+	}
+
+	if _, exists := storage.PlanTypes[key]; !exists {
+		return domain.NotFoundf("plan type not found: %s", key)
+	}
+	storage.PlanTypes[key] = planType
+
+	if err := r.savePlanTypes(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save plan types: %w", err)
+	}
+
+	r.logger.Info("Plan type updated", zap.String("plan_type", key))
+	return nil
+}
+
+func (r *jsonPlanTypeRepository) loadPlanTypes(ctx context.Context) (*planTypeStorage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	storage := &planTypeStorage{
+		PlanTypes: make(map[string]*domain.PlanTypeConfig),
+	}
+
+	data, err := r.lock.readFile(r.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return storage, nil
+	}
+
+	if err := json.Unmarshal(data, storage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	if err := storage.check(storePlanTypes, r.filePath); err != nil {
+		return nil, err
+	}
+	if storage.PlanTypes == nil {
+		storage.PlanTypes = make(map[string]*domain.PlanTypeConfig)
+	}
+
+	return storage, nil
+}
+
+func (r *jsonPlanTypeRepository) savePlanTypes(ctx context.Context, storage *planTypeStorage) error {
+	// Do not commit a write the caller has already given up on
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage.stamp(storePlanTypes)
+	data, err := json.MarshalIndent(storage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if err := r.lock.writeFile(r.filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// jsonProviderAccountRepository implements ProviderAccountRepository using JSON file storage
+type jsonProviderAccountRepository struct {
+	filePath string
+	logger   *zap.Logger
+	lock     *fileLock
+}
+
+type providerAccountStorage struct {
+	schemaHeader
+	Accounts map[string]*domain.ProviderAccount `json:"accounts"`
+}
+
+// NewProviderAccountRepository creates a new JSON-based provider account repository
+func NewProviderAccountRepository(filePath string, logger *zap.Logger) repository.ProviderAccountRepository {
+	return &jsonProviderAccountRepository{
+		filePath: filePath + "_provider_accounts",
+		lock:     newFileLock(filePath + "_provider_accounts"),
+		logger:   logger,
+	}
+}
+
+func (r *jsonProviderAccountRepository) Create(ctx context.Context, account *domain.ProviderAccount) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadAccounts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load provider accounts: %w", err)
+	}
+
+	storage.Accounts[account.ID.String()] = account
+
+	if err := r.saveAccounts(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save provider accounts: %w", err)
+	}
+
+	r.logger.Info("Provider account created", zap.String("account_id", account.ID.String()))
+	return nil
+}
+
+func (r *jsonProviderAccountRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.ProviderAccount, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load provider accounts: %w", err)
+	}
+
+	account, exists := storage.Accounts[id.String()]
+	if !exists {
+		return nil, domain.NotFoundf("provider account not found: %s", id.String())
+	}
+
+	return account, nil
+}
+
+func (r *jsonProviderAccountRepository) GetByCustomer(ctx context.Context, provider, customerID string) ([]*domain.ProviderAccount, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load provider accounts: %w", err)
+	}
+
+	var accounts []*domain.ProviderAccount
+	for _, account := range storage.Accounts {
+		if account.Provider == provider && account.CustomerID == customerID {
+			accounts = append(accounts, account)
+		}
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].CreatedAt.After(accounts[j].CreatedAt)
+	})
+
+	return accounts, nil
+}
+
+func (r *jsonProviderAccountRepository) GetByPlanID(ctx context.Context, planID uuid.UUID) (*domain.ProviderAccount, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load provider accounts: %w", err)
+	}
+
+	for _, account := range storage.Accounts {
+		for _, id := range account.PlanIDs {
+			if id == planID {
+				return account, nil
+			}
+		}
+	}
+
+	return nil, domain.NotFoundf("provider account not found for plan: %s", planID.String())
+}
+
+func (r *jsonProviderAccountRepository) GetAll(ctx context.Context) ([]*domain.ProviderAccount, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load provider accounts: %w", err)
+	}
+
+	accounts := make([]*domain.ProviderAccount, 0, len(storage.Accounts))
+	for _, account := range storage.Accounts {
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+func (r *jsonProviderAccountRepository) Update(ctx context.Context, account *domain.ProviderAccount) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadAccounts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load provider accounts: %w", err)
+	}
+
+	if _, exists := storage.Accounts[account.ID.String()]; !exists {
+		return domain.NotFoundf("provider account not found: %s", account.ID.String())
+	}
+
+	account.UpdatedAt = time.Now()
+	storage.Accounts[account.ID.String()] = account
+
+	if err := r.saveAccounts(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save provider accounts: %w", err)
+	}
+
+	r.logger.Info("Provider account updated", zap.String("account_id", account.ID.String()))
+	return nil
+}
+
+func (r *jsonProviderAccountRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadAccounts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load provider accounts: %w", err)
+	}
+
+	if _, exists := storage.Accounts[id.String()]; !exists {
+		return domain.NotFoundf("provider account not found: %s", id.String())
+	}
+
+	delete(storage.Accounts, id.String())
+
+	if err := r.saveAccounts(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save provider accounts: %w", err)
+	}
+
+	r.logger.Info("Provider account deleted", zap.String("account_id", id.String()))
+	return nil
+}
+
+func (r *jsonProviderAccountRepository) loadAccounts(ctx context.Context) (*providerAccountStorage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	storage := &providerAccountStorage{
+		Accounts: make(map[string]*domain.ProviderAccount),
+	}
+
+	data, err := r.lock.readFile(r.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return storage, nil
+	}
+
+	if err := json.Unmarshal(data, storage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	if err := storage.check(storeProviderAccounts, r.filePath); err != nil {
+		return nil, err
+	}
+
+	return storage, nil
+}
+
+func (r *jsonProviderAccountRepository) saveAccounts(ctx context.Context, storage *providerAccountStorage) error {
+	// Do not commit a write the caller has already given up on
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage.stamp(storeProviderAccounts)
+	data, err := json.MarshalIndent(storage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if err := r.lock.writeFile(r.filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
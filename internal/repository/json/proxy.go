@@ -4,8 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"sync"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,22 +18,29 @@ import (
 type jsonPlanRepository struct {
 	filePath string
 	logger   *zap.Logger
-	mu       sync.RWMutex
+	lock     *fileLock
+	versions versionCache
 }
 
 // jsonInstanceRepository implements InstanceRepository using JSON file storage
 type jsonInstanceRepository struct {
 	filePath string
 	logger   *zap.Logger
-	mu       sync.RWMutex
+	lock     *fileLock
+	versions versionCache
 }
 
 // Storage structures
+// Version is incremented on every save
 type planStorage struct {
-	Plans map[string]*domain.ProxyPlan `json:"plans"`
+	schemaHeader
+	Version uint64                       `json:"version"`
+	Plans   map[string]*domain.ProxyPlan `json:"plans"`
 }
 
 type instanceStorage struct {
+	schemaHeader
+	Version   uint64                           `json:"version"`
 	Instances map[string]*domain.ProxyInstance `json:"instances"`
 }
 
@@ -42,6 +48,7 @@ type instanceStorage struct {
 func NewPlanRepository(filePath string, logger *zap.Logger) repository.PlanRepository {
 	return &jsonPlanRepository{
 		filePath: filePath,
+		lock:     newFileLock(filePath),
 		logger:   logger,
 	}
 }
@@ -51,6 +58,7 @@ func NewInstanceRepository(filePath string, logger *zap.Logger) repository.Insta
 	instanceFilePath := filePath + "_instances"
 	return &jsonInstanceRepository{
 		filePath: instanceFilePath,
+		lock:     newFileLock(instanceFilePath),
 		logger:   logger,
 	}
 }
@@ -58,17 +66,21 @@ func NewInstanceRepository(filePath string, logger *zap.Logger) repository.Insta
 // Plan Repository Implementation
 
 func (r *jsonPlanRepository) Create(ctx context.Context, plan *domain.ProxyPlan) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	r.lock.Lock()
+	defer r.lock.Unlock()
 
-	storage, err := r.loadPlans()
+	storage, err := r.loadPlans(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to load plans: %w", err)
 	}
 
+	if err := storage.checkUsername(plan); err != nil {
+		return err
+	}
+
 	storage.Plans[plan.ID.String()] = plan
 
-	if err := r.savePlans(storage); err != nil {
+	if err := r.savePlans(ctx, storage); err != nil {
 		return fmt.Errorf("failed to save plans: %w", err)
 	}
 
@@ -77,27 +89,27 @@ func (r *jsonPlanRepository) Create(ctx context.Context, plan *domain.ProxyPlan)
 }
 
 func (r *jsonPlanRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.ProxyPlan, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadPlans()
+	storage, err := r.loadPlans(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load plans: %w", err)
 	}
 
 	plan, exists := storage.Plans[id.String()]
 	if !exists {
-		return nil, fmt.Errorf("plan not found: %s", id.String())
+		return nil, domain.NotFoundf("plan not found: %s", id.String())
 	}
 
 	return plan, nil
 }
 
 func (r *jsonPlanRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.ProxyPlan, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadPlans()
+	storage, err := r.loadPlans(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load plans: %w", err)
 	}
@@ -112,11 +124,30 @@ func (r *jsonPlanRepository) GetByCustomerID(ctx context.Context, customerID str
 	return plans, nil
 }
 
+func (r *jsonPlanRepository) GetByUsername(ctx context.Context, username string) ([]*domain.ProxyPlan, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadPlans(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plans: %w", err)
+	}
+
+	var plans []*domain.ProxyPlan
+	for _, plan := range storage.Plans {
+		if plan.Username == username {
+			plans = append(plans, plan)
+		}
+	}
+
+	return plans, nil
+}
+
 func (r *jsonPlanRepository) GetAll(ctx context.Context) ([]*domain.ProxyPlan, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadPlans()
+	storage, err := r.loadPlans(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load plans: %w", err)
 	}
@@ -130,22 +161,25 @@ func (r *jsonPlanRepository) GetAll(ctx context.Context) ([]*domain.ProxyPlan, e
 }
 
 func (r *jsonPlanRepository) Update(ctx context.Context, plan *domain.ProxyPlan) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	r.lock.Lock()
+	defer r.lock.Unlock()
 
-	storage, err := r.loadPlans()
+	storage, err := r.loadPlans(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to load plans: %w", err)
 	}
 
 	if _, exists := storage.Plans[plan.ID.String()]; !exists {
-		return fmt.Errorf("plan not found: %s", plan.ID.String())
+		return domain.NotFoundf("plan not found: %s", plan.ID.String())
+	}
+	if err := storage.checkUsername(plan); err != nil {
+		return err
 	}
 
 	plan.UpdatedAt = time.Now()
 	storage.Plans[plan.ID.String()] = plan
 
-	if err := r.savePlans(storage); err != nil {
+	if err := r.savePlans(ctx, storage); err != nil {
 		return fmt.Errorf("failed to save plans: %w", err)
 	}
 
@@ -154,21 +188,21 @@ func (r *jsonPlanRepository) Update(ctx context.Context, plan *domain.ProxyPlan)
 }
 
 func (r *jsonPlanRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	r.lock.Lock()
+	defer r.lock.Unlock()
 
-	storage, err := r.loadPlans()
+	storage, err := r.loadPlans(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to load plans: %w", err)
 	}
 
 	if _, exists := storage.Plans[id.String()]; !exists {
-		return fmt.Errorf("plan not found: %s", id.String())
+		return domain.NotFoundf("plan not found: %s", id.String())
 	}
 
 	delete(storage.Plans, id.String())
 
-	if err := r.savePlans(storage); err != nil {
+	if err := r.savePlans(ctx, storage); err != nil {
 		return fmt.Errorf("failed to save plans: %w", err)
 	}
 
@@ -177,10 +211,10 @@ func (r *jsonPlanRepository) Delete(ctx context.Context, id uuid.UUID) error {
 }
 
 func (r *jsonPlanRepository) GetExpired(ctx context.Context, before time.Time) ([]*domain.ProxyPlan, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadPlans()
+	storage, err := r.loadPlans(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load plans: %w", err)
 	}
@@ -195,11 +229,37 @@ func (r *jsonPlanRepository) GetExpired(ctx context.Context, before time.Time) (
 	return expiredPlans, nil
 }
 
+func (r *jsonPlanRepository) GetExpiring(ctx context.Context, from, to time.Time) ([]*domain.ProxyPlan, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadPlans(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plans: %w", err)
+	}
+
+	var expiringPlans []*domain.ProxyPlan
+	for _, plan := range storage.Plans {
+		if plan.Status != domain.PlanStatusActive {
+			continue
+		}
+		if !plan.ExpiresAt.Before(from) && plan.ExpiresAt.Before(to) {
+			expiringPlans = append(expiringPlans, plan)
+		}
+	}
+
+	sort.Slice(expiringPlans, func(i, j int) bool {
+		return expiringPlans[i].ExpiresAt.Before(expiringPlans[j].ExpiresAt)
+	})
+
+	return expiringPlans, nil
+}
+
 func (r *jsonPlanRepository) GetByStatus(ctx context.Context, status string) ([]*domain.ProxyPlan, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadPlans()
+	storage, err := r.loadPlans(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load plans: %w", err)
 	}
@@ -215,10 +275,10 @@ func (r *jsonPlanRepository) GetByStatus(ctx context.Context, status string) ([]
 }
 
 func (r *jsonPlanRepository) GetByProvider(ctx context.Context, provider string) ([]*domain.ProxyPlan, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadPlans()
+	storage, err := r.loadPlans(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load plans: %w", err)
 	}
@@ -234,10 +294,10 @@ func (r *jsonPlanRepository) GetByProvider(ctx context.Context, provider string)
 }
 
 func (r *jsonPlanRepository) GetByRegion(ctx context.Context, region string) ([]*domain.ProxyPlan, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadPlans()
+	storage, err := r.loadPlans(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load plans: %w", err)
 	}
@@ -253,10 +313,10 @@ func (r *jsonPlanRepository) GetByRegion(ctx context.Context, region string) ([]
 }
 
 func (r *jsonPlanRepository) Count(ctx context.Context) (int, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadPlans()
+	storage, err := r.loadPlans(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to load plans: %w", err)
 	}
@@ -265,10 +325,10 @@ func (r *jsonPlanRepository) Count(ctx context.Context) (int, error) {
 }
 
 func (r *jsonPlanRepository) CountByStatus(ctx context.Context, status string) (int, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadPlans()
+	storage, err := r.loadPlans(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to load plans: %w", err)
 	}
@@ -283,20 +343,59 @@ func (r *jsonPlanRepository) CountByStatus(ctx context.Context, status string) (
 	return count, nil
 }
 
+func (r *jsonPlanRepository) CountsByStatus(ctx context.Context) (map[string]int, error) {
+	summary, err := r.Summary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return summary.ByStatus, nil
+}
+
+func (r *jsonPlanRepository) Summary(ctx context.Context) (*repository.PlanSummary, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadPlans(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plans: %w", err)
+	}
+
+	summary := &repository.PlanSummary{
+		Total:      len(storage.Plans),
+		ByStatus:   make(map[string]int),
+		ByProvider: make(map[string]int),
+		ByRegion:   make(map[string]int),
+	}
+	for _, plan := range storage.Plans {
+		summary.ByStatus[plan.Status]++
+		summary.ByProvider[plan.Provider]++
+		summary.ByRegion[plan.Region]++
+	}
+
+	return summary, nil
+}
+
+func (r *jsonPlanRepository) Version(ctx context.Context) (uint64, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.versions.get(r.filePath)
+}
+
 // Instance Repository Implementation
 
 func (r *jsonInstanceRepository) Create(ctx context.Context, instance *domain.ProxyInstance) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	r.lock.Lock()
+	defer r.lock.Unlock()
 
-	storage, err := r.loadInstances()
+	storage, err := r.loadInstances(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to load instances: %w", err)
 	}
 
 	storage.Instances[instance.ID.String()] = instance
 
-	if err := r.saveInstances(storage); err != nil {
+	if err := r.saveInstances(ctx, storage); err != nil {
 		return fmt.Errorf("failed to save instances: %w", err)
 	}
 
@@ -305,27 +404,27 @@ func (r *jsonInstanceRepository) Create(ctx context.Context, instance *domain.Pr
 }
 
 func (r *jsonInstanceRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.ProxyInstance, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadInstances()
+	storage, err := r.loadInstances(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load instances: %w", err)
 	}
 
 	instance, exists := storage.Instances[id.String()]
 	if !exists {
-		return nil, fmt.Errorf("instance not found: %s", id.String())
+		return nil, domain.NotFoundf("instance not found: %s", id.String())
 	}
 
 	return instance, nil
 }
 
 func (r *jsonInstanceRepository) GetByPlanID(ctx context.Context, planID uuid.UUID) ([]*domain.ProxyInstance, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadInstances()
+	storage, err := r.loadInstances(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load instances: %w", err)
 	}
@@ -341,10 +440,10 @@ func (r *jsonInstanceRepository) GetByPlanID(ctx context.Context, planID uuid.UU
 }
 
 func (r *jsonInstanceRepository) GetAll(ctx context.Context) ([]*domain.ProxyInstance, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadInstances()
+	storage, err := r.loadInstances(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load instances: %w", err)
 	}
@@ -358,22 +457,22 @@ func (r *jsonInstanceRepository) GetAll(ctx context.Context) ([]*domain.ProxyIns
 }
 
 func (r *jsonInstanceRepository) Update(ctx context.Context, instance *domain.ProxyInstance) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	r.lock.Lock()
+	defer r.lock.Unlock()
 
-	storage, err := r.loadInstances()
+	storage, err := r.loadInstances(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to load instances: %w", err)
 	}
 
 	if _, exists := storage.Instances[instance.ID.String()]; !exists {
-		return fmt.Errorf("instance not found: %s", instance.ID.String())
+		return domain.NotFoundf("instance not found: %s", instance.ID.String())
 	}
 
 	instance.UpdatedAt = time.Now()
 	storage.Instances[instance.ID.String()] = instance
 
-	if err := r.saveInstances(storage); err != nil {
+	if err := r.saveInstances(ctx, storage); err != nil {
 		return fmt.Errorf("failed to save instances: %w", err)
 	}
 
@@ -382,21 +481,21 @@ func (r *jsonInstanceRepository) Update(ctx context.Context, instance *domain.Pr
 }
 
 func (r *jsonInstanceRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	r.lock.Lock()
+	defer r.lock.Unlock()
 
-	storage, err := r.loadInstances()
+	storage, err := r.loadInstances(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to load instances: %w", err)
 	}
 
 	if _, exists := storage.Instances[id.String()]; !exists {
-		return fmt.Errorf("instance not found: %s", id.String())
+		return domain.NotFoundf("instance not found: %s", id.String())
 	}
 
 	delete(storage.Instances, id.String())
 
-	if err := r.saveInstances(storage); err != nil {
+	if err := r.saveInstances(ctx, storage); err != nil {
 		return fmt.Errorf("failed to save instances: %w", err)
 	}
 
@@ -405,10 +504,10 @@ func (r *jsonInstanceRepository) Delete(ctx context.Context, id uuid.UUID) error
 }
 
 func (r *jsonInstanceRepository) GetByStatus(ctx context.Context, status string) ([]*domain.ProxyInstance, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadInstances()
+	storage, err := r.loadInstances(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load instances: %w", err)
 	}
@@ -424,10 +523,10 @@ func (r *jsonInstanceRepository) GetByStatus(ctx context.Context, status string)
 }
 
 func (r *jsonInstanceRepository) GetByPort(ctx context.Context, port int) (*domain.ProxyInstance, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadInstances()
+	storage, err := r.loadInstances(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load instances: %w", err)
 	}
@@ -438,14 +537,14 @@ func (r *jsonInstanceRepository) GetByPort(ctx context.Context, port int) (*doma
 		}
 	}
 
-	return nil, fmt.Errorf("instance not found for port: %d", port)
+	return nil, domain.NotFoundf("instance not found for port: %d", port)
 }
 
 func (r *jsonInstanceRepository) GetByPlanTypeKey(ctx context.Context, planTypeKey string) ([]*domain.ProxyInstance, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadInstances()
+	storage, err := r.loadInstances(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load instances: %w", err)
 	}
@@ -465,10 +564,10 @@ func (r *jsonInstanceRepository) GetRunning(ctx context.Context) ([]*domain.Prox
 }
 
 func (r *jsonInstanceRepository) Count(ctx context.Context) (int, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadInstances()
+	storage, err := r.loadInstances(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to load instances: %w", err)
 	}
@@ -477,10 +576,10 @@ func (r *jsonInstanceRepository) Count(ctx context.Context) (int, error) {
 }
 
 func (r *jsonInstanceRepository) CountByStatus(ctx context.Context, status string) (int, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadInstances()
+	storage, err := r.loadInstances(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to load instances: %w", err)
 	}
@@ -495,11 +594,41 @@ func (r *jsonInstanceRepository) CountByStatus(ctx context.Context, status strin
 	return count, nil
 }
 
+func (r *jsonInstanceRepository) CountsByStatus(ctx context.Context) (map[string]int, error) {
+	summary, err := r.Summary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return summary.ByStatus, nil
+}
+
+func (r *jsonInstanceRepository) Summary(ctx context.Context) (*repository.InstanceSummary, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadInstances(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load instances: %w", err)
+	}
+
+	summary := &repository.InstanceSummary{
+		Total:      len(storage.Instances),
+		ByStatus:   make(map[string]int),
+		ByPlanType: make(map[string]int),
+	}
+	for _, instance := range storage.Instances {
+		summary.ByStatus[instance.Status]++
+		summary.ByPlanType[instance.PlanTypeKey]++
+	}
+
+	return summary, nil
+}
+
 func (r *jsonInstanceRepository) GetPortsInUse(ctx context.Context) ([]int, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	r.lock.RLock()
+	defer r.lock.RUnlock()
 
-	storage, err := r.loadInstances()
+	storage, err := r.loadInstances(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load instances: %w", err)
 	}
@@ -512,20 +641,37 @@ func (r *jsonInstanceRepository) GetPortsInUse(ctx context.Context) ([]int, erro
 	return ports, nil
 }
 
+func (r *jsonInstanceRepository) Version(ctx context.Context) (uint64, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.versions.get(r.filePath)
+}
+
 // Helper methods for plan repository
 
-func (r *jsonPlanRepository) loadPlans() (*planStorage, error) {
-	storage := &planStorage{
-		Plans: make(map[string]*domain.ProxyPlan),
+// checkUsername fails if plan's username conflicts with another stored plan
+func (s *planStorage) checkUsername(plan *domain.ProxyPlan) error {
+	for _, other := range s.Plans {
+		if domain.UsernameConflicts(plan, other) {
+			return fmt.Errorf("%w: %s", domain.ErrDuplicateUsername, plan.Username)
+		}
 	}
+	return nil
+}
 
-	if _, err := os.Stat(r.filePath); os.IsNotExist(err) {
-		return storage, nil
+func (r *jsonPlanRepository) loadPlans(ctx context.Context) (*planStorage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	data, err := os.ReadFile(r.filePath)
+	storage := &planStorage{
+		Plans: make(map[string]*domain.ProxyPlan),
+	}
+
+	data, err := r.lock.readFile(r.filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, err
 	}
 
 	if len(data) == 0 {
@@ -535,17 +681,27 @@ func (r *jsonPlanRepository) loadPlans() (*planStorage, error) {
 	if err := json.Unmarshal(data, storage); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
 	}
+	if err := storage.check(storePlans, r.filePath); err != nil {
+		return nil, err
+	}
 
 	return storage, nil
 }
 
-func (r *jsonPlanRepository) savePlans(storage *planStorage) error {
+func (r *jsonPlanRepository) savePlans(ctx context.Context, storage *planStorage) error {
+	// Do not commit a write the caller has already given up on
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage.Version++
+	storage.stamp(storePlans)
 	data, err := json.MarshalIndent(storage, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal JSON: %w", err)
 	}
 
-	if err := os.WriteFile(r.filePath, data, 0644); err != nil {
+	if err := r.lock.writeFile(r.filePath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
@@ -554,18 +710,18 @@ func (r *jsonPlanRepository) savePlans(storage *planStorage) error {
 
 // Helper methods for instance repository
 
-func (r *jsonInstanceRepository) loadInstances() (*instanceStorage, error) {
-	storage := &instanceStorage{
-		Instances: make(map[string]*domain.ProxyInstance),
+func (r *jsonInstanceRepository) loadInstances(ctx context.Context) (*instanceStorage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	if _, err := os.Stat(r.filePath); os.IsNotExist(err) {
-		return storage, nil
+	storage := &instanceStorage{
+		Instances: make(map[string]*domain.ProxyInstance),
 	}
 
-	data, err := os.ReadFile(r.filePath)
+	data, err := r.lock.readFile(r.filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return nil, err
 	}
 
 	if len(data) == 0 {
@@ -575,17 +731,27 @@ func (r *jsonInstanceRepository) loadInstances() (*instanceStorage, error) {
 	if err := json.Unmarshal(data, storage); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
 	}
+	if err := storage.check(storeInstances, r.filePath); err != nil {
+		return nil, err
+	}
 
 	return storage, nil
 }
 
-func (r *jsonInstanceRepository) saveInstances(storage *instanceStorage) error {
+func (r *jsonInstanceRepository) saveInstances(ctx context.Context, storage *instanceStorage) error {
+	// Do not commit a write the caller has already given up on
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage.Version++
+	storage.stamp(storeInstances)
 	data, err := json.MarshalIndent(storage, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal JSON: %w", err)
 	}
 
-	if err := os.WriteFile(r.filePath, data, 0644); err != nil {
+	if err := r.lock.writeFile(r.filePath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
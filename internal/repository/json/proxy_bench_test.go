@@ -0,0 +1,140 @@
+package json
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// benchmarkPlanCounts are the store sizes the plan benchmarks run at; every
+// operation reads and rewrites the whole file, so cost grows with them
+var benchmarkPlanCounts = []int{100, 1000}
+
+// newBenchmarkPlanRepository returns a plan repository holding count plans
+// and their IDs
+func newBenchmarkPlanRepository(b *testing.B, count int) (repository.PlanRepository, []uuid.UUID) {
+	b.Helper()
+
+	repo := NewPlanRepository(filepath.Join(b.TempDir(), "oceanproxy.json"), zap.NewNop()).(*jsonPlanRepository)
+
+	// Seeded in one write; creating plans one by one rewrites the file each time
+	storage := &planStorage{Plans: make(map[string]*domain.ProxyPlan, count)}
+	ids := make([]uuid.UUID, 0, count)
+	for i := 0; i < count; i++ {
+		plan := benchmarkPlan(i)
+		storage.Plans[plan.ID.String()] = plan
+		ids = append(ids, plan.ID)
+	}
+	if err := repo.savePlans(context.Background(), storage); err != nil {
+		b.Fatalf("failed to seed plans: %v", err)
+	}
+	return repo, ids
+}
+
+func benchmarkPlan(i int) *domain.ProxyPlan {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &domain.ProxyPlan{
+		ID:          uuid.New(),
+		CustomerID:  fmt.Sprintf("customer-%d", i%50),
+		PlanType:    "residential",
+		PlanTypeKey: "proxies_fo_usa",
+		Provider:    "proxies_fo",
+		Region:      "usa",
+		Username:    fmt.Sprintf("user%06d", i),
+		Password:    "benchmark-password",
+		Bandwidth:   10,
+		Status:      domain.PlanStatusActive,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ExpiresAt:   now.AddDate(0, 1, 0),
+	}
+}
+
+func BenchmarkPlanRepositoryGetByID(b *testing.B) {
+	for _, count := range benchmarkPlanCounts {
+		b.Run(fmt.Sprintf("plans=%d", count), func(b *testing.B) {
+			repo, ids := newBenchmarkPlanRepository(b, count)
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.GetByID(ctx, ids[i%len(ids)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPlanRepositoryGetAll(b *testing.B) {
+	for _, count := range benchmarkPlanCounts {
+		b.Run(fmt.Sprintf("plans=%d", count), func(b *testing.B) {
+			repo, _ := newBenchmarkPlanRepository(b, count)
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.GetAll(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPlanRepositoryUpdate(b *testing.B) {
+	for _, count := range benchmarkPlanCounts {
+		b.Run(fmt.Sprintf("plans=%d", count), func(b *testing.B) {
+			repo, ids := newBenchmarkPlanRepository(b, count)
+			ctx := context.Background()
+			plan, err := repo.GetByID(ctx, ids[0])
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				plan.Bandwidth = i
+				if err := repo.Update(ctx, plan); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPlanRepositoryCreate(b *testing.B) {
+	for _, count := range benchmarkPlanCounts {
+		b.Run(fmt.Sprintf("plans=%d", count), func(b *testing.B) {
+			repo, _ := newBenchmarkPlanRepository(b, count)
+			ctx := context.Background()
+
+			// Creating grows the store; deleting each plan again keeps the
+			// size the benchmark is named for
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				plan := benchmarkPlan(count + i)
+				if err := repo.Create(ctx, plan); err != nil {
+					b.Fatal(err)
+				}
+				b.StopTimer()
+				if err := repo.Delete(ctx, plan.ID); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
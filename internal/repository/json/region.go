@@ -0,0 +1,188 @@
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// jsonRegionRepository implements RegionRepository using JSON file storage
+type jsonRegionRepository struct {
+	filePath string
+	logger   *zap.Logger
+	lock     *fileLock
+}
+
+type regionStorage struct {
+	schemaHeader
+	Regions map[string]*domain.Region `json:"regions"`
+}
+
+// NewRegionRepository creates a new JSON-based region repository
+func NewRegionRepository(filePath string, logger *zap.Logger) repository.RegionRepository {
+	return &jsonRegionRepository{
+		filePath: filePath + "_regions",
+		lock:     newFileLock(filePath + "_regions"),
+		logger:   logger,
+	}
+}
+
+func (r *jsonRegionRepository) Create(ctx context.Context, region *domain.Region) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadRegions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load regions: %w", err)
+	}
+
+	if _, exists := storage.Regions[region.Name]; exists {
+		return domain.Conflictf("region already exists: %s", region.Name)
+	}
+	storage.Regions[region.Name] = region
+
+	if err := r.saveRegions(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save regions: %w", err)
+	}
+
+	r.logger.Info("Region created", zap.String("region", region.Name))
+	return nil
+}
+
+func (r *jsonRegionRepository) GetByName(ctx context.Context, name string) (*domain.Region, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadRegions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load regions: %w", err)
+	}
+
+	region, exists := storage.Regions[name]
+	if !exists {
+		return nil, domain.NotFoundf("region not found: %s", name)
+	}
+
+	return region, nil
+}
+
+func (r *jsonRegionRepository) GetAll(ctx context.Context) ([]*domain.Region, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadRegions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load regions: %w", err)
+	}
+
+	regions := make([]*domain.Region, 0, len(storage.Regions))
+	for _, region := range storage.Regions {
+		regions = append(regions, region)
+	}
+
+	sort.Slice(regions, func(i, j int) bool {
+		return regions[i].Name < regions[j].Name
+	})
+
+	return regions, nil
+}
+
+func (r *jsonRegionRepository) Update(ctx context.Context, region *domain.Region) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadRegions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load regions: %w", err)
+	}
+
+	if _, exists := storage.Regions[region.Name]; !exists {
+		return domain.NotFoundf("region not found: %s", region.Name)
+	}
+	storage.Regions[region.Name] = region
+
+	if err := r.saveRegions(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save regions: %w", err)
+	}
+
+	r.logger.Info("Region updated", zap.String("region", region.Name))
+	return nil
+}
+
+func (r *jsonRegionRepository) Delete(ctx context.Context, name string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadRegions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load regions: %w", err)
+	}
+
+	if _, exists := storage.Regions[name]; !exists {
+		return domain.NotFoundf("region not found: %s", name)
+	}
+	delete(storage.Regions, name)
+
+	if err := r.saveRegions(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save regions: %w", err)
+	}
+
+	r.logger.Info("Region deleted", zap.String("region", name))
+	return nil
+}
+
+func (r *jsonRegionRepository) loadRegions(ctx context.Context) (*regionStorage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	storage := &regionStorage{
+		Regions: make(map[string]*domain.Region),
+	}
+
+	data, err := r.lock.readFile(r.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return storage, nil
+	}
+
+	if err := json.Unmarshal(data, storage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	if err := storage.check(storeRegions, r.filePath); err != nil {
+		return nil, err
+	}
+	if storage.Regions == nil {
+		storage.Regions = make(map[string]*domain.Region)
+	}
+
+	return storage, nil
+}
+
+func (r *jsonRegionRepository) saveRegions(ctx context.Context, storage *regionStorage) error {
+	// Do not commit a write the caller has already given up on
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage.stamp(storeRegions)
+	data, err := json.MarshalIndent(storage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if err := r.lock.writeFile(r.filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,335 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Storage files. Each file carries its own schema version, so one file's
+// shape can change without touching the others.
+const (
+	storePlans            = "plans"
+	storeInstances        = "instances"
+	storeProviderAccounts = "provider_accounts"
+	storePlanEvents       = "plan_events"
+	storeStats            = "stats"
+	storeAPIKeys          = "api_keys"
+	storeIncidents        = "incidents"
+	storeRegions          = "regions"
+	storePlanTypes        = "plan_types"
+	storeGitOps           = "gitops"
+	storeAppliedPlans     = "applied_plans"
+	storeSubUserUsage     = "subuser_usage"
+	storeVouchers         = "vouchers"
+	storeAlerts           = "alerts"
+	storeMaintenance      = "maintenance"
+)
+
+// document is a storage file decoded generically, for migrations
+type document map[string]interface{}
+
+// migration upgrades a document by one schema version. docs holds the other
+// storage files, already migrated if they come earlier in schemas; apply may
+// be nil for a version that only stamps the file.
+type migration struct {
+	description string
+	apply       func(doc document, docs map[string]document) error
+}
+
+// storeSchema lists a storage file's migrations; migrations[i] upgrades
+// version i to i+1, so the current version is len(migrations). Files written
+// before versioning have no schema_version and are version 0.
+type storeSchema struct {
+	name       string
+	suffix     string
+	migrations []migration
+}
+
+// schemas are migrated in this order. To change a file's shape, append a
+// migration to its list; never edit or remove one that has shipped.
+var schemas = []storeSchema{
+	{name: storePlans, suffix: "", migrations: []migration{
+		{"derive plan_type_key and region of plans stored before they were recorded", migratePlansV1},
+	}},
+	{name: storeInstances, suffix: "_instances", migrations: []migration{
+		{"copy plan_type_key from the instance's plan where it is missing", migrateInstancesV1},
+	}},
+	{name: storeProviderAccounts, suffix: "_provider_accounts", migrations: []migration{{"add schema version", nil}}},
+	{name: storePlanEvents, suffix: "_plan_events", migrations: []migration{{"add schema version", nil}}},
+	{name: storeStats, suffix: "_stats", migrations: []migration{
+		{"add schema version", nil},
+		{"add per-country traffic to stats buckets", nil},
+	}},
+	{name: storeAPIKeys, suffix: "_api_keys", migrations: []migration{{"add schema version", nil}}},
+	{name: storeIncidents, suffix: "_incidents", migrations: []migration{{"add schema version", nil}}},
+	{name: storeRegions, suffix: "_regions", migrations: []migration{{"add schema version", nil}}},
+	{name: storePlanTypes, suffix: "_plan_types", migrations: []migration{{"add schema version", nil}}},
+	{name: storeGitOps, suffix: "_gitops", migrations: []migration{{"add schema version", nil}}},
+	{name: storeAppliedPlans, suffix: "_applied_plans", migrations: []migration{{"add schema version", nil}}},
+	{name: storeSubUserUsage, suffix: "_subuser_usage", migrations: []migration{{"add schema version", nil}}},
+	{name: storeVouchers, suffix: "_vouchers", migrations: []migration{{"add schema version", nil}}},
+	{name: storeAlerts, suffix: "_alerts", migrations: []migration{{"add schema version", nil}}},
+	{name: storeMaintenance, suffix: "_maintenance", migrations: []migration{{"add schema version", nil}}},
+}
+
+// schemaVersion returns the current schema version of a storage file
+func schemaVersion(store string) int {
+	for _, schema := range schemas {
+		if schema.name == store {
+			return len(schema.migrations)
+		}
+	}
+	panic("unknown storage file " + store)
+}
+
+// schemaHeader is embedded in every storage structure
+type schemaHeader struct {
+	SchemaVersion int `json:"schema_version"`
+}
+
+// SchemaError is returned when a storage file's schema version does not
+// match this build
+type SchemaError struct {
+	File      string
+	Version   int
+	Supported int
+}
+
+func (e *SchemaError) Error() string {
+	if e.Version > e.Supported {
+		return fmt.Sprintf("%s has schema version %d but this build supports up to %d; it was written by a newer OceanProxy, upgrade before using it",
+			e.File, e.Version, e.Supported)
+	}
+	return fmt.Sprintf("%s has schema version %d but this build needs %d; restart the server or run the CLI to migrate it",
+		e.File, e.Version, e.Supported)
+}
+
+// check verifies a loaded storage file is at the current schema version
+func (h *schemaHeader) check(store, path string) error {
+	if supported := schemaVersion(store); h.SchemaVersion != supported {
+		return &SchemaError{File: path, Version: h.SchemaVersion, Supported: supported}
+	}
+	return nil
+}
+
+// stamp marks storage as written at the current schema version
+func (h *schemaHeader) stamp(store string) {
+	h.SchemaVersion = schemaVersion(store)
+}
+
+// MigrationResult describes one storage file brought up to date
+type MigrationResult struct {
+	File    string
+	From    int
+	To      int
+	Applied []string
+}
+
+// Migrate brings every storage file next to dsn up to the current schema
+// version. The original of each migrated file is kept as a hidden
+// .<file>.schema-v<N>.bak next to it. It refuses to touch anything if a file
+// was written by a newer build. Missing and empty files are left alone.
+func Migrate(dsn string, logger *zap.Logger) ([]MigrationResult, error) {
+	// Check every file first so nothing is migrated next to a file this
+	// build cannot read
+	for _, schema := range schemas {
+		if err := checkNotNewer(dsn+schema.suffix, len(schema.migrations)); err != nil {
+			return nil, err
+		}
+	}
+
+	docs := make(map[string]document)
+	var results []MigrationResult
+
+	for _, schema := range schemas {
+		path := dsn + schema.suffix
+		result, doc, err := migrateFile(path, schema, docs)
+		if err != nil {
+			return results, err
+		}
+		if doc != nil {
+			docs[schema.name] = doc
+		}
+		if result != nil {
+			logger.Info("Migrated storage file",
+				zap.String("file", path),
+				zap.Int("from", result.From),
+				zap.Int("to", result.To),
+				zap.Strings("migrations", result.Applied),
+			)
+			results = append(results, *result)
+		}
+	}
+
+	return results, nil
+}
+
+// checkNotNewer fails if the storage file at path has a schema version
+// above supported
+func checkNotNewer(path string, supported int) error {
+	lock := newFileLock(path)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	data, err := lock.readFile(path)
+	if err != nil || len(bytes.TrimSpace(data)) == 0 {
+		return err
+	}
+	_, version, err := decodeDocument(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	if version > supported {
+		return &SchemaError{File: path, Version: version, Supported: supported}
+	}
+	return nil
+}
+
+// migrateFile migrates one storage file under its lock and returns the
+// migrated document; result is nil if the file was already current
+func migrateFile(path string, schema storeSchema, docs map[string]document) (*MigrationResult, document, error) {
+	lock := newFileLock(path)
+	lock.Lock()
+	defer lock.Unlock()
+
+	data, err := lock.readFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil, nil
+	}
+
+	doc, version, err := decodeDocument(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+
+	current := len(schema.migrations)
+	if version > current {
+		return nil, nil, &SchemaError{File: path, Version: version, Supported: current}
+	}
+	if version == current {
+		return nil, doc, nil
+	}
+
+	result := &MigrationResult{File: path, From: version, To: current}
+	for v := version; v < current; v++ {
+		step := schema.migrations[v]
+		if step.apply != nil {
+			if err := step.apply(doc, docs); err != nil {
+				return nil, nil, fmt.Errorf("failed to migrate %s to schema version %d: %w", path, v+1, err)
+			}
+		}
+		result.Applied = append(result.Applied, step.description)
+	}
+	doc["schema_version"] = current
+
+	migrated, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	backup := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.schema-v%d.bak", filepath.Base(path), version))
+	if err := os.WriteFile(backup, data, 0600); err != nil {
+		return nil, nil, fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	if err := lock.writeFile(path, migrated, info.Mode().Perm()); err != nil {
+		return nil, nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return result, doc, nil
+}
+
+// decodeDocument decodes a storage file, keeping numbers exact, and returns
+// its schema version
+func decodeDocument(data []byte) (document, int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc document
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, 0, err
+	}
+	if doc == nil {
+		doc = document{}
+	}
+
+	version := 0
+	if raw, ok := doc["schema_version"]; ok {
+		number, ok := raw.(json.Number)
+		if !ok {
+			return nil, 0, fmt.Errorf("schema_version is not a number")
+		}
+		v, err := number.Int64()
+		if err != nil || v < 0 {
+			return nil, 0, fmt.Errorf("invalid schema_version %s", number)
+		}
+		version = int(v)
+	}
+
+	return doc, version, nil
+}
+
+// entries returns the objects in a document's collection field
+func (d document) entries(field string) map[string]document {
+	out := make(map[string]document)
+	collection, _ := d[field].(map[string]interface{})
+	for id, raw := range collection {
+		if entry, ok := raw.(map[string]interface{}); ok {
+			out[id] = entry
+		}
+	}
+	return out
+}
+
+// str returns a string field of a document, or "" if it is missing
+func (d document) str(field string) string {
+	s, _ := d[field].(string)
+	return s
+}
+
+// migratePlansV1 fills in plan_type_key and region, which early plans were
+// stored without. Plan type keys are "<provider>_<region>_<plan_type>".
+func migratePlansV1(doc document, _ map[string]document) error {
+	for _, plan := range doc.entries("plans") {
+		provider, region, planType := plan.str("provider"), plan.str("region"), plan.str("plan_type")
+		key := plan.str("plan_type_key")
+
+		if key == "" && provider != "" && region != "" && planType != "" {
+			plan["plan_type_key"] = provider + "_" + region + "_" + planType
+		}
+		if region == "" && provider != "" && planType != "" {
+			prefix, suffix := provider+"_", "_"+planType
+			if strings.HasPrefix(key, prefix) && strings.HasSuffix(key, suffix) && len(key) > len(prefix)+len(suffix) {
+				plan["region"] = strings.TrimSuffix(strings.TrimPrefix(key, prefix), suffix)
+			}
+		}
+	}
+	return nil
+}
+
+// migrateInstancesV1 copies plan_type_key from each instance's plan where
+// the instance was stored without one
+func migrateInstancesV1(doc document, docs map[string]document) error {
+	plans := docs[storePlans].entries("plans")
+	for _, instance := range doc.entries("instances") {
+		if instance.str("plan_type_key") != "" {
+			continue
+		}
+		if plan, ok := plans[instance.str("plan_id")]; ok && plan.str("plan_type_key") != "" {
+			instance["plan_type_key"] = plan.str("plan_type_key")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,460 @@
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// statsPeriods is the bucket length of each resolution
+var statsPeriods = map[string]time.Duration{
+	repository.StatsResolutionRaw:    time.Minute,
+	repository.StatsResolutionHourly: time.Hour,
+	repository.StatsResolutionDaily:  24 * time.Hour,
+}
+
+// finerResolution maps each rolled-up resolution to the one it is built from
+var finerResolution = map[string]string{
+	repository.StatsResolutionHourly: repository.StatsResolutionRaw,
+	repository.StatsResolutionDaily:  repository.StatsResolutionHourly,
+}
+
+// jsonStatsRepository implements StatsRepository using JSON file storage
+type jsonStatsRepository struct {
+	filePath string
+	logger   *zap.Logger
+	lock     *fileLock
+}
+
+type statsStorage struct {
+	schemaHeader
+
+	// Buckets maps resolution to bucket key (instance ID and start) to bucket
+	Buckets map[string]map[string]*repository.StatsBucket `json:"buckets"`
+
+	// RolledUntil is, per rolled-up resolution, the end of the last period
+	// aggregated into it. Queries read finer data after this point.
+	RolledUntil map[string]time.Time `json:"rolled_until"`
+}
+
+// statsTotals accumulates traffic across buckets
+type statsTotals struct {
+	requests             int64
+	bytesIn              int64
+	bytesOut             int64
+	reaped               int64
+	lastActivity         time.Time
+	clientCountries      map[string]repository.CountryTraffic
+	destinationCountries map[string]repository.CountryTraffic
+}
+
+// NewStatsRepository creates a new JSON-based stats repository
+func NewStatsRepository(filePath string, logger *zap.Logger) repository.StatsRepository {
+	return &jsonStatsRepository{
+		filePath: filePath + "_stats",
+		lock:     newFileLock(filePath + "_stats"),
+		logger:   logger,
+	}
+}
+
+func (r *jsonStatsRepository) RecordRequest(ctx context.Context, planID, instanceID uuid.UUID, bytesIn, bytesOut int64) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadStats(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load stats: %w", err)
+	}
+
+	start := time.Now().UTC().Truncate(statsPeriods[repository.StatsResolutionRaw])
+	bucket := storage.bucket(repository.StatsResolutionRaw, planID, instanceID, start)
+	bucket.Requests++
+	bucket.BytesIn += bytesIn
+	bucket.BytesOut += bytesOut
+
+	if err := r.saveStats(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save stats: %w", err)
+	}
+
+	return nil
+}
+
+func (r *jsonStatsRepository) RecordTraffic(ctx context.Context, samples []repository.TrafficSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadStats(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load stats: %w", err)
+	}
+
+	period := statsPeriods[repository.StatsResolutionRaw]
+	for _, sample := range samples {
+		bucket := storage.bucket(repository.StatsResolutionRaw, sample.PlanID, sample.InstanceID, sample.Time.UTC().Truncate(period))
+		bucket.Requests++
+		bucket.BytesIn += sample.BytesIn
+		bucket.BytesOut += sample.BytesOut
+		if sample.Reaped {
+			bucket.Reaped++
+		}
+
+		traffic := repository.CountryTraffic{Requests: 1, BytesIn: sample.BytesIn, BytesOut: sample.BytesOut}
+		if sample.ClientCountry != "" {
+			bucket.ClientCountries = addCountryTraffic(bucket.ClientCountries, sample.ClientCountry, traffic)
+		}
+		if sample.DestinationCountry != "" {
+			bucket.DestinationCountries = addCountryTraffic(bucket.DestinationCountries, sample.DestinationCountry, traffic)
+		}
+	}
+
+	if err := r.saveStats(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save stats: %w", err)
+	}
+
+	return nil
+}
+
+func (r *jsonStatsRepository) GetInstanceStats(ctx context.Context, instanceID uuid.UUID, from, to time.Time, resolution string) (*repository.InstanceStats, error) {
+	totals, err := r.query(ctx, resolution, from, to, func(b *repository.StatsBucket) bool {
+		return b.InstanceID == instanceID
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &repository.InstanceStats{
+		InstanceID:    instanceID,
+		Resolution:    resolution,
+		TotalRequests: totals.requests,
+		BytesIn:       totals.bytesIn,
+		BytesOut:      totals.bytesOut,
+		Reaped:        totals.reaped,
+		LastActivity:  totals.lastActivity,
+
+		ClientCountries:      totals.clientCountries,
+		DestinationCountries: totals.destinationCountries,
+	}, nil
+}
+
+func (r *jsonStatsRepository) GetPlanStats(ctx context.Context, planID uuid.UUID, from, to time.Time, resolution string) (*repository.PlanStats, error) {
+	totals, err := r.query(ctx, resolution, from, to, func(b *repository.StatsBucket) bool {
+		return b.PlanID == planID
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &repository.PlanStats{
+		PlanID:        planID,
+		Resolution:    resolution,
+		TotalRequests: totals.requests,
+		BytesIn:       totals.bytesIn,
+		BytesOut:      totals.bytesOut,
+		Reaped:        totals.reaped,
+
+		ClientCountries:      totals.clientCountries,
+		DestinationCountries: totals.destinationCountries,
+	}, nil
+}
+
+func (r *jsonStatsRepository) GetOverallStats(ctx context.Context, from, to time.Time, resolution string) (*repository.OverallStats, error) {
+	totals, err := r.query(ctx, resolution, from, to, func(*repository.StatsBucket) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	return &repository.OverallStats{
+		Resolution:    resolution,
+		TotalRequests: totals.requests,
+		BytesIn:       totals.bytesIn,
+		BytesOut:      totals.bytesOut,
+		Reaped:        totals.reaped,
+		ProvidersUsed: make(map[string]int),
+		RegionsUsed:   make(map[string]int),
+
+		ClientCountries:      totals.clientCountries,
+		DestinationCountries: totals.destinationCountries,
+	}, nil
+}
+
+func (r *jsonStatsRepository) GetPlanTraffic(ctx context.Context, planID uuid.UUID, from, to time.Time, resolution string) ([]repository.TrafficPeriod, error) {
+	if _, exists := statsPeriods[resolution]; !exists {
+		return nil, fmt.Errorf("unknown stats resolution %q", resolution)
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stats: %w", err)
+	}
+
+	var periods []repository.TrafficPeriod
+	match := func(b *repository.StatsBucket) bool { return b.PlanID == planID }
+	storage.each(resolution, from.UTC(), to.UTC(), match, func(b *repository.StatsBucket, period time.Duration) {
+		periods = append(periods, repository.TrafficPeriod{
+			InstanceID: b.InstanceID,
+			Start:      b.Start,
+			End:        b.Start.Add(period),
+			Requests:   b.Requests,
+			BytesIn:    b.BytesIn,
+			BytesOut:   b.BytesOut,
+		})
+	})
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Start.Before(periods[j].Start) })
+	return periods, nil
+}
+
+func (r *jsonStatsRepository) query(ctx context.Context, resolution string, from, to time.Time, match func(*repository.StatsBucket) bool) (*statsTotals, error) {
+	if _, exists := statsPeriods[resolution]; !exists {
+		return nil, fmt.Errorf("unknown stats resolution %q", resolution)
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stats: %w", err)
+	}
+
+	totals := &statsTotals{}
+	storage.sum(resolution, from.UTC(), to.UTC(), match, totals)
+	return totals, nil
+}
+
+func (r *jsonStatsRepository) Rollup(ctx context.Context, from, to string, until time.Time) (int, error) {
+	if finerResolution[to] != from {
+		return 0, fmt.Errorf("cannot roll %s stats up into %s", from, to)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadStats(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load stats: %w", err)
+	}
+
+	period := statsPeriods[to]
+	until = until.UTC().Truncate(period)
+	watermark := storage.RolledUntil[to]
+	if !until.After(watermark) {
+		return 0, nil
+	}
+
+	written := make(map[string]bool)
+	for _, b := range storage.Buckets[from] {
+		if b.Start.Before(watermark) || !b.Start.Before(until) {
+			continue
+		}
+		start := b.Start.Truncate(period)
+		target := storage.bucket(to, b.PlanID, b.InstanceID, start)
+		target.Requests += b.Requests
+		target.BytesIn += b.BytesIn
+		target.BytesOut += b.BytesOut
+		target.Reaped += b.Reaped
+		for country, traffic := range b.ClientCountries {
+			target.ClientCountries = addCountryTraffic(target.ClientCountries, country, traffic)
+		}
+		for country, traffic := range b.DestinationCountries {
+			target.DestinationCountries = addCountryTraffic(target.DestinationCountries, country, traffic)
+		}
+		written[statsKey(b.InstanceID, start)] = true
+	}
+	storage.RolledUntil[to] = until
+
+	if err := r.saveStats(ctx, storage); err != nil {
+		return 0, fmt.Errorf("failed to save stats: %w", err)
+	}
+
+	return len(written), nil
+}
+
+func (r *jsonStatsRepository) Prune(ctx context.Context, resolution string, before time.Time) (int, error) {
+	if _, exists := statsPeriods[resolution]; !exists {
+		return 0, fmt.Errorf("unknown stats resolution %q", resolution)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadStats(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load stats: %w", err)
+	}
+
+	// Never drop data that hasn't been rolled up into the next resolution yet
+	for coarser, finer := range finerResolution {
+		if finer == resolution && storage.RolledUntil[coarser].Before(before) {
+			before = storage.RolledUntil[coarser]
+		}
+	}
+
+	pruned := 0
+	for key, b := range storage.Buckets[resolution] {
+		if b.Start.Before(before) {
+			delete(storage.Buckets[resolution], key)
+			pruned++
+		}
+	}
+	if pruned == 0 {
+		return 0, nil
+	}
+
+	if err := r.saveStats(ctx, storage); err != nil {
+		return 0, fmt.Errorf("failed to save stats: %w", err)
+	}
+
+	return pruned, nil
+}
+
+// bucket returns the bucket for an instance and period start, creating it
+func (s *statsStorage) bucket(resolution string, planID, instanceID uuid.UUID, start time.Time) *repository.StatsBucket {
+	buckets := s.Buckets[resolution]
+	if buckets == nil {
+		buckets = make(map[string]*repository.StatsBucket)
+		s.Buckets[resolution] = buckets
+	}
+
+	key := statsKey(instanceID, start)
+	b, exists := buckets[key]
+	if !exists {
+		b = &repository.StatsBucket{PlanID: planID, InstanceID: instanceID, Start: start}
+		buckets[key] = b
+	}
+	return b
+}
+
+// sum adds matching traffic in [from, to) at the given resolution. Periods
+// not yet rolled up into it are read from the next finer resolution.
+func (s *statsStorage) sum(resolution string, from, to time.Time, match func(*repository.StatsBucket) bool, totals *statsTotals) {
+	s.each(resolution, from, to, match, func(b *repository.StatsBucket, period time.Duration) {
+		totals.requests += b.Requests
+		totals.bytesIn += b.BytesIn
+		totals.bytesOut += b.BytesOut
+		totals.reaped += b.Reaped
+		for country, traffic := range b.ClientCountries {
+			totals.clientCountries = addCountryTraffic(totals.clientCountries, country, traffic)
+		}
+		for country, traffic := range b.DestinationCountries {
+			totals.destinationCountries = addCountryTraffic(totals.destinationCountries, country, traffic)
+		}
+		if last := b.Start.Add(period); last.After(totals.lastActivity) {
+			totals.lastActivity = last
+		}
+	})
+}
+
+// each calls fn with every matching bucket of resolution in [from, to) and
+// its period. Where resolution has not been rolled up yet, the finer
+// buckets stand in.
+func (s *statsStorage) each(resolution string, from, to time.Time, match func(*repository.StatsBucket) bool, fn func(b *repository.StatsBucket, period time.Duration)) {
+	period := statsPeriods[resolution]
+	end := to
+	finer, rolled := finerResolution[resolution]
+	if rolled && s.RolledUntil[resolution].Before(end) {
+		end = s.RolledUntil[resolution]
+	}
+
+	start := from.Truncate(period)
+	for _, b := range s.Buckets[resolution] {
+		if b.Start.Before(start) || !b.Start.Before(end) || !match(b) {
+			continue
+		}
+		fn(b, period)
+	}
+
+	if rolled && end.Before(to) {
+		if end.After(from) {
+			from = end
+		}
+		s.each(finer, from, to, match, fn)
+	}
+}
+
+// addCountryTraffic adds traffic to a country of a breakdown, creating the
+// breakdown if needed
+func addCountryTraffic(m map[string]repository.CountryTraffic, country string, traffic repository.CountryTraffic) map[string]repository.CountryTraffic {
+	if m == nil {
+		m = make(map[string]repository.CountryTraffic)
+	}
+	total := m[country]
+	total.Requests += traffic.Requests
+	total.BytesIn += traffic.BytesIn
+	total.BytesOut += traffic.BytesOut
+	m[country] = total
+	return m
+}
+
+func statsKey(instanceID uuid.UUID, start time.Time) string {
+	return instanceID.String() + "/" + strconv.FormatInt(start.Unix(), 10)
+}
+
+func (r *jsonStatsRepository) loadStats(ctx context.Context) (*statsStorage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	storage := &statsStorage{
+		Buckets:     make(map[string]map[string]*repository.StatsBucket),
+		RolledUntil: make(map[string]time.Time),
+	}
+
+	data, err := r.lock.readFile(r.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return storage, nil
+	}
+
+	if err := json.Unmarshal(data, storage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	if err := storage.check(storeStats, r.filePath); err != nil {
+		return nil, err
+	}
+
+	if storage.Buckets == nil {
+		storage.Buckets = make(map[string]map[string]*repository.StatsBucket)
+	}
+	if storage.RolledUntil == nil {
+		storage.RolledUntil = make(map[string]time.Time)
+	}
+
+	return storage, nil
+}
+
+func (r *jsonStatsRepository) saveStats(ctx context.Context, storage *statsStorage) error {
+	// Do not commit a write the caller has already given up on
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage.stamp(storeStats)
+	data, err := json.MarshalIndent(storage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if err := r.lock.writeFile(r.filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
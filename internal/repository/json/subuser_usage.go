@@ -0,0 +1,137 @@
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// jsonSubUserUsageRepository implements SubUserUsageRepository using JSON file storage
+type jsonSubUserUsageRepository struct {
+	filePath string
+	logger   *zap.Logger
+	lock     *fileLock
+}
+
+type subUserUsageStorage struct {
+	schemaHeader
+	Usage map[uuid.UUID]*domain.SubUserUsage `json:"usage"`
+}
+
+// NewSubUserUsageRepository creates a new JSON-based sub-user usage repository
+func NewSubUserUsageRepository(filePath string, logger *zap.Logger) repository.SubUserUsageRepository {
+	return &jsonSubUserUsageRepository{
+		filePath: filePath + "_subuser_usage",
+		lock:     newFileLock(filePath + "_subuser_usage"),
+		logger:   logger,
+	}
+}
+
+func (r *jsonSubUserUsageRepository) GetAll(ctx context.Context) (map[uuid.UUID]*domain.SubUserUsage, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	storage, err := r.loadUsage()
+	if err != nil {
+		return nil, err
+	}
+	return storage.Usage, nil
+}
+
+func (r *jsonSubUserUsageRepository) Add(ctx context.Context, traffic map[uuid.UUID]*domain.SubUserUsage) error {
+	if len(traffic) == 0 {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage, err := r.loadUsage()
+	if err != nil {
+		return err
+	}
+
+	for id, delta := range traffic {
+		usage := storage.Usage[id]
+		if usage == nil {
+			usage = &domain.SubUserUsage{}
+			storage.Usage[id] = usage
+		}
+		usage.Requests += delta.Requests
+		usage.BytesIn += delta.BytesIn
+		usage.BytesOut += delta.BytesOut
+		if delta.LastUsedAt != nil && (usage.LastUsedAt == nil || delta.LastUsedAt.After(*usage.LastUsedAt)) {
+			lastUsed := *delta.LastUsedAt
+			usage.LastUsedAt = &lastUsed
+		}
+	}
+
+	return r.saveUsage(storage)
+}
+
+func (r *jsonSubUserUsageRepository) Delete(ctx context.Context, ids ...uuid.UUID) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage, err := r.loadUsage()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		delete(storage.Usage, id)
+	}
+
+	return r.saveUsage(storage)
+}
+
+func (r *jsonSubUserUsageRepository) loadUsage() (*subUserUsageStorage, error) {
+	storage := &subUserUsageStorage{}
+	data, err := r.lock.readFile(r.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sub-user usage: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, storage); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+		}
+		if err := storage.check(storeSubUserUsage, r.filePath); err != nil {
+			return nil, err
+		}
+	}
+	if storage.Usage == nil {
+		storage.Usage = make(map[uuid.UUID]*domain.SubUserUsage)
+	}
+	return storage, nil
+}
+
+func (r *jsonSubUserUsageRepository) saveUsage(storage *subUserUsageStorage) error {
+	storage.stamp(storeSubUserUsage)
+	data, err := json.MarshalIndent(storage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if err := r.lock.writeFile(r.filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// versionCache remembers the version counter of a storage file by its
+// modification time and size, so unchanged files are only stat'ed
+type versionCache struct {
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	version uint64
+	valid   bool
+}
+
+// get returns the version stored in path; a missing file is version 0
+func (c *versionCache) get(path string) (uint64, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.valid && info.ModTime().Equal(c.modTime) && info.Size() == c.size {
+		return c.version, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var header struct {
+		Version uint64 `json:"version"`
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &header); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal JSON: %w", err)
+		}
+	}
+
+	c.modTime, c.size, c.version, c.valid = info.ModTime(), info.Size(), header.Version, true
+	return c.version, nil
+}
@@ -0,0 +1,236 @@
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// jsonVoucherRepository implements VoucherRepository using JSON file storage
+type jsonVoucherRepository struct {
+	filePath string
+	logger   *zap.Logger
+	lock     *fileLock
+}
+
+type voucherStorage struct {
+	schemaHeader
+	Vouchers    map[string]*domain.Voucher           `json:"vouchers"`
+	Redemptions map[string]*domain.VoucherRedemption `json:"redemptions"`
+}
+
+// NewVoucherRepository creates a new JSON-based voucher repository
+func NewVoucherRepository(filePath string, logger *zap.Logger) repository.VoucherRepository {
+	return &jsonVoucherRepository{
+		filePath: filePath + "_vouchers",
+		lock:     newFileLock(filePath + "_vouchers"),
+		logger:   logger,
+	}
+}
+
+func (r *jsonVoucherRepository) Create(ctx context.Context, vouchers []*domain.Voucher) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadVouchers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load vouchers: %w", err)
+	}
+
+	for _, voucher := range vouchers {
+		if _, exists := storage.Vouchers[voucher.Code]; exists {
+			return domain.Conflictf("voucher already exists: %s", voucher.Code)
+		}
+		storage.Vouchers[voucher.Code] = voucher
+	}
+
+	if err := r.saveVouchers(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save vouchers: %w", err)
+	}
+
+	r.logger.Info("Vouchers created", zap.Int("count", len(vouchers)))
+	return nil
+}
+
+func (r *jsonVoucherRepository) GetByCode(ctx context.Context, code string) (*domain.Voucher, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadVouchers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vouchers: %w", err)
+	}
+
+	return storage.Vouchers[code], nil
+}
+
+func (r *jsonVoucherRepository) GetAll(ctx context.Context) ([]*domain.Voucher, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadVouchers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vouchers: %w", err)
+	}
+
+	vouchers := make([]*domain.Voucher, 0, len(storage.Vouchers))
+	for _, voucher := range storage.Vouchers {
+		vouchers = append(vouchers, voucher)
+	}
+
+	sort.Slice(vouchers, func(i, j int) bool {
+		if !vouchers[i].CreatedAt.Equal(vouchers[j].CreatedAt) {
+			return vouchers[i].CreatedAt.After(vouchers[j].CreatedAt)
+		}
+		return vouchers[i].Code < vouchers[j].Code
+	})
+
+	return vouchers, nil
+}
+
+func (r *jsonVoucherRepository) Update(ctx context.Context, voucher *domain.Voucher) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadVouchers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load vouchers: %w", err)
+	}
+
+	if _, exists := storage.Vouchers[voucher.Code]; !exists {
+		return domain.NotFoundf("voucher not found: %s", voucher.Code)
+	}
+
+	storage.Vouchers[voucher.Code] = voucher
+
+	if err := r.saveVouchers(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save vouchers: %w", err)
+	}
+
+	r.logger.Debug("Voucher updated", zap.String("code", voucher.Code))
+	return nil
+}
+
+func (r *jsonVoucherRepository) CreateRedemption(ctx context.Context, redemption *domain.VoucherRedemption) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadVouchers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load vouchers: %w", err)
+	}
+
+	storage.Redemptions[redemption.ID.String()] = redemption
+
+	if err := r.saveVouchers(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save vouchers: %w", err)
+	}
+
+	return nil
+}
+
+func (r *jsonVoucherRepository) UpdateRedemption(ctx context.Context, redemption *domain.VoucherRedemption) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	storage, err := r.loadVouchers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load vouchers: %w", err)
+	}
+
+	if _, exists := storage.Redemptions[redemption.ID.String()]; !exists {
+		return domain.NotFoundf("voucher redemption not found: %s", redemption.ID.String())
+	}
+
+	storage.Redemptions[redemption.ID.String()] = redemption
+
+	if err := r.saveVouchers(ctx, storage); err != nil {
+		return fmt.Errorf("failed to save vouchers: %w", err)
+	}
+
+	return nil
+}
+
+func (r *jsonVoucherRepository) GetRedemptions(ctx context.Context, code string) ([]*domain.VoucherRedemption, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	storage, err := r.loadVouchers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vouchers: %w", err)
+	}
+
+	redemptions := make([]*domain.VoucherRedemption, 0)
+	for _, redemption := range storage.Redemptions {
+		if code == "" || redemption.Code == code {
+			redemptions = append(redemptions, redemption)
+		}
+	}
+
+	sort.Slice(redemptions, func(i, j int) bool {
+		return redemptions[i].RedeemedAt.After(redemptions[j].RedeemedAt)
+	})
+
+	return redemptions, nil
+}
+
+func (r *jsonVoucherRepository) loadVouchers(ctx context.Context) (*voucherStorage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	storage := &voucherStorage{
+		Vouchers:    make(map[string]*domain.Voucher),
+		Redemptions: make(map[string]*domain.VoucherRedemption),
+	}
+
+	data, err := r.lock.readFile(r.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return storage, nil
+	}
+
+	if err := json.Unmarshal(data, storage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	if err := storage.check(storeVouchers, r.filePath); err != nil {
+		return nil, err
+	}
+
+	if storage.Vouchers == nil {
+		storage.Vouchers = make(map[string]*domain.Voucher)
+	}
+	if storage.Redemptions == nil {
+		storage.Redemptions = make(map[string]*domain.VoucherRedemption)
+	}
+
+	return storage, nil
+}
+
+func (r *jsonVoucherRepository) saveVouchers(ctx context.Context, storage *voucherStorage) error {
+	// Do not commit a write the caller has already given up on
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	storage.stamp(storeVouchers)
+	data, err := json.MarshalIndent(storage, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if err := r.lock.writeFile(r.filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
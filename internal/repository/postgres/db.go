@@ -0,0 +1,147 @@
+// Package postgres stores plans and instances in PostgreSQL, so several
+// API servers can share them.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLock is the advisory lock key held while migrating, so servers
+// starting together apply each migration once
+const migrationLock = 0x6f6365616e
+
+// Open connects to the database cfg names, sizes the connection pool and
+// applies pending schema migrations
+func Open(ctx context.Context, cfg config.Database, logger *zap.Logger) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	applied, err := Migrate(ctx, db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if applied > 0 {
+		logger.Info("Database schema migrated", zap.Int("migrations", applied))
+	}
+
+	return db, nil
+}
+
+// Migrate applies the embedded migrations not yet recorded in
+// schema_migrations, in order, each in its own transaction. It returns how
+// many were applied.
+func Migrate(ctx context.Context, db *sql.DB) (int, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLock); err != nil {
+		return 0, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLock)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`); err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	files, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	applied := 0
+	for _, file := range files {
+		version, err := strconv.Atoi(strings.SplitN(file.Name(), "_", 2)[0])
+		if err != nil {
+			return applied, fmt.Errorf("invalid migration name %q", file.Name())
+		}
+		if version <= current {
+			continue
+		}
+
+		script, err := migrations.ReadFile("migrations/" + file.Name())
+		if err != nil {
+			return applied, err
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return applied, fmt.Errorf("failed to begin migration %d: %w", version, err)
+		}
+		if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+			tx.Rollback()
+			return applied, fmt.Errorf("migration %s failed: %w", file.Name(), err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+			tx.Rollback()
+			return applied, fmt.Errorf("failed to record migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return applied, fmt.Errorf("failed to commit migration %d: %w", version, err)
+		}
+		applied++
+	}
+
+	return applied, nil
+}
+
+// bumpVersion increments the version of a table within tx
+func bumpVersion(ctx context.Context, tx *sql.Tx, name string) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE repository_versions SET version = version + 1 WHERE name = $1`, name); err != nil {
+		return fmt.Errorf("failed to bump %s version: %w", name, err)
+	}
+	return nil
+}
+
+// getVersion returns the version of a table
+func getVersion(ctx context.Context, db *sql.DB, name string) (uint64, error) {
+	var version int64
+	err := db.QueryRowContext(ctx, `SELECT version FROM repository_versions WHERE name = $1`, name).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get %s version: %w", name, err)
+	}
+	return uint64(version), nil
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,260 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// instanceRepository implements InstanceRepository on the proxy_instances table
+type instanceRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+// NewInstanceRepository creates a new PostgreSQL-based instance repository
+func NewInstanceRepository(db *sql.DB, logger *zap.Logger) repository.InstanceRepository {
+	return &instanceRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const instanceVersion = "instances"
+
+func (r *instanceRepository) Create(ctx context.Context, instance *domain.ProxyInstance) error {
+	data, err := json.Marshal(instance)
+	if err != nil {
+		return fmt.Errorf("failed to marshal instance: %w", err)
+	}
+
+	err = r.write(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO proxy_instances
+			(id, plan_id, plan_type_key, local_port, status, created_at, updated_at, data)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			instance.ID, instance.PlanID, instance.PlanTypeKey, instance.LocalPort, instance.Status,
+			instance.CreatedAt, instance.UpdatedAt, string(data))
+		if isUniqueViolation(err) {
+			return domain.Conflictf("instance already exists: %s", instance.ID.String())
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	r.logger.Info("Instance created", zap.String("instance_id", instance.ID.String()))
+	return nil
+}
+
+func (r *instanceRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.ProxyInstance, error) {
+	instances, err := r.query(ctx, `WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(instances) == 0 {
+		return nil, domain.NotFoundf("instance not found: %s", id.String())
+	}
+	return instances[0], nil
+}
+
+func (r *instanceRepository) GetByPlanID(ctx context.Context, planID uuid.UUID) ([]*domain.ProxyInstance, error) {
+	return r.query(ctx, `WHERE plan_id = $1`, planID)
+}
+
+func (r *instanceRepository) GetAll(ctx context.Context) ([]*domain.ProxyInstance, error) {
+	return r.query(ctx, ``)
+}
+
+func (r *instanceRepository) Update(ctx context.Context, instance *domain.ProxyInstance) error {
+	updatedAt := time.Now()
+	stored := *instance
+	stored.UpdatedAt = updatedAt
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal instance: %w", err)
+	}
+
+	err = r.write(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `UPDATE proxy_instances SET
+			plan_id = $2, plan_type_key = $3, local_port = $4, status = $5, updated_at = $6, data = $7
+			WHERE id = $1`,
+			instance.ID, instance.PlanID, instance.PlanTypeKey, instance.LocalPort, instance.Status, updatedAt, string(data))
+		if err != nil {
+			return err
+		}
+		return requireRow(result, "instance not found: %s", instance.ID.String())
+	})
+	if err != nil {
+		return err
+	}
+
+	instance.UpdatedAt = updatedAt
+	r.logger.Info("Instance updated", zap.String("instance_id", instance.ID.String()))
+	return nil
+}
+
+func (r *instanceRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	err := r.write(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM proxy_instances WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+		return requireRow(result, "instance not found: %s", id.String())
+	})
+	if err != nil {
+		return err
+	}
+
+	r.logger.Info("Instance deleted", zap.String("instance_id", id.String()))
+	return nil
+}
+
+func (r *instanceRepository) GetByStatus(ctx context.Context, status string) ([]*domain.ProxyInstance, error) {
+	return r.query(ctx, `WHERE status = $1`, status)
+}
+
+func (r *instanceRepository) GetByPort(ctx context.Context, port int) (*domain.ProxyInstance, error) {
+	instances, err := r.query(ctx, `WHERE local_port = $1 LIMIT 1`, port)
+	if err != nil {
+		return nil, err
+	}
+	if len(instances) == 0 {
+		return nil, domain.NotFoundf("instance not found for port: %d", port)
+	}
+	return instances[0], nil
+}
+
+func (r *instanceRepository) GetByPlanTypeKey(ctx context.Context, planTypeKey string) ([]*domain.ProxyInstance, error) {
+	return r.query(ctx, `WHERE plan_type_key = $1`, planTypeKey)
+}
+
+func (r *instanceRepository) GetRunning(ctx context.Context) ([]*domain.ProxyInstance, error) {
+	return r.GetByStatus(ctx, domain.InstanceStatusRunning)
+}
+
+func (r *instanceRepository) Count(ctx context.Context) (int, error) {
+	return count(ctx, r.db, `SELECT COUNT(*) FROM proxy_instances`)
+}
+
+func (r *instanceRepository) CountByStatus(ctx context.Context, status string) (int, error) {
+	return count(ctx, r.db, `SELECT COUNT(*) FROM proxy_instances WHERE status = $1`, status)
+}
+
+func (r *instanceRepository) CountsByStatus(ctx context.Context) (map[string]int, error) {
+	summary, err := r.Summary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return summary.ByStatus, nil
+}
+
+func (r *instanceRepository) Summary(ctx context.Context) (*repository.InstanceSummary, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT status, plan_type_key, COUNT(*) FROM proxy_instances GROUP BY status, plan_type_key`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize instances: %w", err)
+	}
+	defer rows.Close()
+
+	summary := &repository.InstanceSummary{
+		ByStatus:   make(map[string]int),
+		ByPlanType: make(map[string]int),
+	}
+	for rows.Next() {
+		var status, planTypeKey string
+		var n int
+		if err := rows.Scan(&status, &planTypeKey, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan instance summary: %w", err)
+		}
+		summary.Total += n
+		summary.ByStatus[status] += n
+		summary.ByPlanType[planTypeKey] += n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to summarize instances: %w", err)
+	}
+
+	return summary, nil
+}
+
+func (r *instanceRepository) GetPortsInUse(ctx context.Context) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT local_port FROM proxy_instances`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ports in use: %w", err)
+	}
+	defer rows.Close()
+
+	var ports []int
+	for rows.Next() {
+		var port int
+		if err := rows.Scan(&port); err != nil {
+			return nil, fmt.Errorf("failed to scan port: %w", err)
+		}
+		ports = append(ports, port)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get ports in use: %w", err)
+	}
+
+	return ports, nil
+}
+
+func (r *instanceRepository) Version(ctx context.Context) (uint64, error) {
+	return getVersion(ctx, r.db, instanceVersion)
+}
+
+// write runs fn in a transaction that bumps the instances version
+func (r *instanceRepository) write(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := bumpVersion(ctx, tx, instanceVersion); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// query returns the instances matching a WHERE/ORDER BY clause
+func (r *instanceRepository) query(ctx context.Context, clause string, args ...interface{}) ([]*domain.ProxyInstance, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT data FROM proxy_instances `+clause, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query instances: %w", err)
+	}
+	defer rows.Close()
+
+	var instances []*domain.ProxyInstance
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan instance: %w", err)
+		}
+		instance := &domain.ProxyInstance{}
+		if err := json.Unmarshal(data, instance); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal instance: %w", err)
+		}
+		instances = append(instances, instance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read instances: %w", err)
+	}
+
+	return instances, nil
+}
@@ -0,0 +1,46 @@
+-- Plans and instances are stored whole as JSON documents in data; the
+-- other columns copy the fields they are looked up by.
+
+CREATE TABLE proxy_plans (
+    id                  UUID PRIMARY KEY,
+    customer_id         TEXT NOT NULL,
+    username            TEXT NOT NULL,
+    provider            TEXT NOT NULL,
+    region              TEXT NOT NULL,
+    plan_type_key       TEXT NOT NULL,
+    status              TEXT NOT NULL,
+    provider_account_id UUID,
+    expires_at          TIMESTAMPTZ NOT NULL,
+    created_at          TIMESTAMPTZ NOT NULL,
+    updated_at          TIMESTAMPTZ NOT NULL,
+    data                JSONB NOT NULL
+);
+
+CREATE INDEX idx_proxy_plans_customer_id ON proxy_plans (customer_id);
+CREATE INDEX idx_proxy_plans_username ON proxy_plans (username);
+CREATE INDEX idx_proxy_plans_status ON proxy_plans (status);
+CREATE INDEX idx_proxy_plans_expires_at ON proxy_plans (expires_at);
+
+CREATE TABLE proxy_instances (
+    id            UUID PRIMARY KEY,
+    plan_id       UUID NOT NULL,
+    plan_type_key TEXT NOT NULL,
+    local_port    INTEGER NOT NULL,
+    status        TEXT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL,
+    updated_at    TIMESTAMPTZ NOT NULL,
+    data          JSONB NOT NULL
+);
+
+CREATE INDEX idx_proxy_instances_plan_id ON proxy_instances (plan_id);
+CREATE INDEX idx_proxy_instances_plan_type_key ON proxy_instances (plan_type_key);
+CREATE INDEX idx_proxy_instances_local_port ON proxy_instances (local_port);
+CREATE INDEX idx_proxy_instances_status ON proxy_instances (status);
+
+-- Counters bumped by every write to a table, served as repository versions
+CREATE TABLE repository_versions (
+    name    TEXT PRIMARY KEY,
+    version BIGINT NOT NULL
+);
+
+INSERT INTO repository_versions (name, version) VALUES ('plans', 0), ('instances', 0);
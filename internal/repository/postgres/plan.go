@@ -0,0 +1,301 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// planRepository implements PlanRepository on the proxy_plans table
+type planRepository struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+// NewPlanRepository creates a new PostgreSQL-based plan repository
+func NewPlanRepository(db *sql.DB, logger *zap.Logger) repository.PlanRepository {
+	return &planRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const planVersion = "plans"
+
+func (r *planRepository) Create(ctx context.Context, plan *domain.ProxyPlan) error {
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %w", err)
+	}
+
+	err = r.write(ctx, plan, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO proxy_plans
+			(id, customer_id, username, provider, region, plan_type_key, status, provider_account_id, expires_at, created_at, updated_at, data)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			plan.ID, plan.CustomerID, plan.Username, plan.Provider, plan.Region, plan.PlanTypeKey, plan.Status,
+			plan.ProviderAccountID, plan.ExpiresAt, plan.CreatedAt, plan.UpdatedAt, string(data))
+		if isUniqueViolation(err) {
+			return domain.Conflictf("plan already exists: %s", plan.ID.String())
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	r.logger.Info("Plan created", zap.String("plan_id", plan.ID.String()))
+	return nil
+}
+
+func (r *planRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.ProxyPlan, error) {
+	plans, err := r.query(ctx, `WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(plans) == 0 {
+		return nil, domain.NotFoundf("plan not found: %s", id.String())
+	}
+	return plans[0], nil
+}
+
+func (r *planRepository) GetByCustomerID(ctx context.Context, customerID string) ([]*domain.ProxyPlan, error) {
+	return r.query(ctx, `WHERE customer_id = $1`, customerID)
+}
+
+func (r *planRepository) GetByUsername(ctx context.Context, username string) ([]*domain.ProxyPlan, error) {
+	return r.query(ctx, `WHERE username = $1`, username)
+}
+
+func (r *planRepository) GetAll(ctx context.Context) ([]*domain.ProxyPlan, error) {
+	return r.query(ctx, ``)
+}
+
+func (r *planRepository) Update(ctx context.Context, plan *domain.ProxyPlan) error {
+	updatedAt := time.Now()
+	stored := *plan
+	stored.UpdatedAt = updatedAt
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %w", err)
+	}
+
+	err = r.write(ctx, plan, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `UPDATE proxy_plans SET
+			customer_id = $2, username = $3, provider = $4, region = $5, plan_type_key = $6, status = $7,
+			provider_account_id = $8, expires_at = $9, updated_at = $10, data = $11
+			WHERE id = $1`,
+			plan.ID, plan.CustomerID, plan.Username, plan.Provider, plan.Region, plan.PlanTypeKey, plan.Status,
+			plan.ProviderAccountID, plan.ExpiresAt, updatedAt, string(data))
+		if err != nil {
+			return err
+		}
+		return requireRow(result, "plan not found: %s", plan.ID.String())
+	})
+	if err != nil {
+		return err
+	}
+
+	plan.UpdatedAt = updatedAt
+	r.logger.Info("Plan updated", zap.String("plan_id", plan.ID.String()))
+	return nil
+}
+
+func (r *planRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	err := r.write(ctx, nil, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM proxy_plans WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+		return requireRow(result, "plan not found: %s", id.String())
+	})
+	if err != nil {
+		return err
+	}
+
+	r.logger.Info("Plan deleted", zap.String("plan_id", id.String()))
+	return nil
+}
+
+func (r *planRepository) GetExpired(ctx context.Context, before time.Time) ([]*domain.ProxyPlan, error) {
+	return r.query(ctx, `WHERE expires_at < $1`, before)
+}
+
+func (r *planRepository) GetExpiring(ctx context.Context, from, to time.Time) ([]*domain.ProxyPlan, error) {
+	return r.query(ctx, `WHERE status = $1 AND expires_at >= $2 AND expires_at < $3 ORDER BY expires_at`,
+		domain.PlanStatusActive, from, to)
+}
+
+func (r *planRepository) GetByStatus(ctx context.Context, status string) ([]*domain.ProxyPlan, error) {
+	return r.query(ctx, `WHERE status = $1`, status)
+}
+
+func (r *planRepository) GetByProvider(ctx context.Context, provider string) ([]*domain.ProxyPlan, error) {
+	return r.query(ctx, `WHERE provider = $1`, provider)
+}
+
+func (r *planRepository) GetByRegion(ctx context.Context, region string) ([]*domain.ProxyPlan, error) {
+	return r.query(ctx, `WHERE region = $1`, region)
+}
+
+func (r *planRepository) Count(ctx context.Context) (int, error) {
+	return count(ctx, r.db, `SELECT COUNT(*) FROM proxy_plans`)
+}
+
+func (r *planRepository) CountByStatus(ctx context.Context, status string) (int, error) {
+	return count(ctx, r.db, `SELECT COUNT(*) FROM proxy_plans WHERE status = $1`, status)
+}
+
+func (r *planRepository) CountsByStatus(ctx context.Context) (map[string]int, error) {
+	summary, err := r.Summary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return summary.ByStatus, nil
+}
+
+func (r *planRepository) Summary(ctx context.Context) (*repository.PlanSummary, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT status, provider, region, COUNT(*) FROM proxy_plans GROUP BY status, provider, region`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize plans: %w", err)
+	}
+	defer rows.Close()
+
+	summary := &repository.PlanSummary{
+		ByStatus:   make(map[string]int),
+		ByProvider: make(map[string]int),
+		ByRegion:   make(map[string]int),
+	}
+	for rows.Next() {
+		var status, provider, region string
+		var n int
+		if err := rows.Scan(&status, &provider, &region, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan plan summary: %w", err)
+		}
+		summary.Total += n
+		summary.ByStatus[status] += n
+		summary.ByProvider[provider] += n
+		summary.ByRegion[region] += n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to summarize plans: %w", err)
+	}
+
+	return summary, nil
+}
+
+func (r *planRepository) Version(ctx context.Context) (uint64, error) {
+	return getVersion(ctx, r.db, planVersion)
+}
+
+// write runs fn in a transaction that bumps the plans version. When plan
+// is set, its username is locked and checked against other plans first,
+// so concurrent servers cannot hand out a conflicting username.
+func (r *planRepository) write(ctx context.Context, plan *domain.ProxyPlan, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if plan != nil && plan.Username != "" {
+		if err := checkUsername(ctx, tx, plan); err != nil {
+			return err
+		}
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := bumpVersion(ctx, tx, planVersion); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// checkUsername fails if plan's username conflicts with another stored
+// plan. The username stays locked until tx ends.
+func checkUsername(ctx context.Context, tx *sql.Tx, plan *domain.ProxyPlan) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "username:"+plan.Username); err != nil {
+		return fmt.Errorf("failed to lock username: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT data FROM proxy_plans WHERE username = $1`, plan.Username)
+	if err != nil {
+		return fmt.Errorf("failed to get plans by username: %w", err)
+	}
+	others, err := scanPlans(rows)
+	if err != nil {
+		return err
+	}
+
+	for _, other := range others {
+		if domain.UsernameConflicts(plan, other) {
+			return fmt.Errorf("%w: %s", domain.ErrDuplicateUsername, plan.Username)
+		}
+	}
+	return nil
+}
+
+// query returns the plans matching a WHERE/ORDER BY clause
+func (r *planRepository) query(ctx context.Context, clause string, args ...interface{}) ([]*domain.ProxyPlan, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT data FROM proxy_plans `+clause, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query plans: %w", err)
+	}
+	return scanPlans(rows)
+}
+
+// scanPlans decodes and closes rows of plan documents
+func scanPlans(rows *sql.Rows) ([]*domain.ProxyPlan, error) {
+	defer rows.Close()
+
+	var plans []*domain.ProxyPlan
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan plan: %w", err)
+		}
+		plan := &domain.ProxyPlan{}
+		if err := json.Unmarshal(data, plan); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal plan: %w", err)
+		}
+		plans = append(plans, plan)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read plans: %w", err)
+	}
+
+	return plans, nil
+}
+
+// count runs a COUNT query
+func count(ctx context.Context, db *sql.DB, query string, args ...interface{}) (int, error) {
+	var n int
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count: %w", err)
+	}
+	return n, nil
+}
+
+// requireRow fails with a not found error unless result affected a row
+func requireRow(result sql.Result, format string, args ...interface{}) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.NotFoundf(format, args...)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// trafficWeigher totals traffic by accounting window. Slot 0 holds the
+// traffic outside every window, slot i+1 that of window i.
+type trafficWeigher struct {
+	clock *domain.AccountingClock
+	rates []float64
+
+	windows   []domain.WindowUsage
+	instances map[uuid.UUID]*weighedInstance
+}
+
+// weighedInstance is one instance's traffic and its bytes per slot
+type weighedInstance struct {
+	requests, bytesIn, bytesOut int64
+	bytes                       []int64
+}
+
+func newTrafficWeigher(schedule *domain.AccountingSchedule, clock *domain.AccountingClock) *trafficWeigher {
+	w := &trafficWeigher{
+		clock:     clock,
+		rates:     []float64{1},
+		windows:   []domain.WindowUsage{{Window: domain.StandardWindow, Rate: 1}},
+		instances: make(map[uuid.UUID]*weighedInstance),
+	}
+	for _, window := range schedule.Windows {
+		w.rates = append(w.rates, window.Rate)
+		w.windows = append(w.windows, domain.WindowUsage{Window: window.Name, Rate: window.Rate})
+	}
+	return w
+}
+
+// add splits a period's traffic across the slots by the minutes of the
+// period each covers. Buckets coarser than the windows, such as daily
+// rollups, are spread evenly over their period.
+func (w *trafficWeigher) add(period repository.TrafficPeriod) {
+	minutes := make([]int64, len(w.windows))
+	var total int64
+	for t := period.Start; t.Before(period.End); t = t.Add(time.Minute) {
+		minutes[w.clock.Window(t)+1]++
+		total++
+	}
+	if total == 0 {
+		return
+	}
+
+	instance := w.instances[period.InstanceID]
+	if instance == nil {
+		instance = &weighedInstance{bytes: make([]int64, len(w.windows))}
+		w.instances[period.InstanceID] = instance
+	}
+	instance.requests += period.Requests
+	instance.bytesIn += period.BytesIn
+	instance.bytesOut += period.BytesOut
+
+	requests := splitByMinutes(period.Requests, minutes, total)
+	bytesIn := splitByMinutes(period.BytesIn, minutes, total)
+	bytesOut := splitByMinutes(period.BytesOut, minutes, total)
+	for slot := range w.windows {
+		w.windows[slot].Requests += requests[slot]
+		w.windows[slot].BytesIn += bytesIn[slot]
+		w.windows[slot].BytesOut += bytesOut[slot]
+		instance.bytes[slot] += bytesIn[slot] + bytesOut[slot]
+	}
+}
+
+// apply totals the traffic into usage and its instances
+func (w *trafficWeigher) apply(usage *domain.EdgeUsage) {
+	usage.Windows = w.windows
+	for i := range usage.Windows {
+		window := &usage.Windows[i]
+		window.CountedBytes = weighBytes(window.BytesIn+window.BytesOut, window.Rate)
+		usage.Requests += window.Requests
+		usage.BytesIn += window.BytesIn
+		usage.BytesOut += window.BytesOut
+		usage.CountedBytes += window.CountedBytes
+	}
+
+	for i := range usage.Instances {
+		instance := w.instances[usage.Instances[i].InstanceID]
+		if instance == nil {
+			continue
+		}
+		usage.Instances[i].Requests = instance.requests
+		usage.Instances[i].BytesIn = instance.bytesIn
+		usage.Instances[i].BytesOut = instance.bytesOut
+		for slot, bytes := range instance.bytes {
+			usage.Instances[i].CountedBytes += weighBytes(bytes, w.rates[slot])
+		}
+	}
+}
+
+// splitByMinutes shares value out by minutes, giving the rounding
+// remainder to the last slot with any, so the shares add up to value
+func splitByMinutes(value int64, minutes []int64, total int64) []int64 {
+	shares := make([]int64, len(minutes))
+	last, given := -1, int64(0)
+	for slot, count := range minutes {
+		if count == 0 {
+			continue
+		}
+		shares[slot] = value * count / total
+		given += shares[slot]
+		last = slot
+	}
+	if last >= 0 {
+		shares[last] += value - given
+	}
+	return shares
+}
+
+// weighBytes returns the bytes counted of bytes sent at rate
+func weighBytes(bytes int64, rate float64) int64 {
+	return int64(math.Round(float64(bytes) * rate))
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// maxActivationBackoff caps the wait between activation attempts
+const maxActivationBackoff = time.Hour
+
+// ActivationWorker brings up new plans whose first activation failed. A plan
+// stays creating while retries are pending; each attempt starts the plan's
+// instances that are not running and adds them to the nginx upstream. Once
+// proxy.activation.retries is used up the plan is marked failed and
+// operators are notified.
+type ActivationWorker struct {
+	cfg          config.Activation
+	logger       *zap.Logger
+	planRepo     repository.PlanRepository
+	instanceRepo repository.InstanceRepository
+	proxyService ProxyService
+	nginxManager *NginxManager
+	events       *eventRecorder
+	notifier     Notifier
+}
+
+// NewActivationWorker creates a new plan activation worker
+func NewActivationWorker(
+	cfg *config.Config,
+	logger *zap.Logger,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	eventRepo repository.PlanEventRepository,
+	proxyService ProxyService,
+	nginxManager *NginxManager,
+	notifier Notifier,
+) *ActivationWorker {
+	return &ActivationWorker{
+		cfg:          cfg.Proxy.Activation,
+		logger:       logger,
+		planRepo:     planRepo,
+		instanceRepo: instanceRepo,
+		proxyService: proxyService,
+		nginxManager: nginxManager,
+		events:       newEventRecorder(eventRepo, logger),
+		notifier:     notifier,
+	}
+}
+
+// Run retries pending activations every proxy.activation.interval until ctx
+// is cancelled. Pending plans are persisted, so retries resume after a
+// restart.
+func (w *ActivationWorker) Run(ctx context.Context) {
+	if w == nil || w.cfg.Interval <= 0 {
+		return
+	}
+
+	w.logger.Info("Starting plan activation worker",
+		zap.Duration("interval", w.cfg.Interval),
+		zap.Int("retries", w.cfg.Retries))
+
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.RetryDue(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			w.logger.Error("Failed to retry plan activations", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RetryDue retries the activations due at now and returns how many plans
+// were activated
+func (w *ActivationWorker) RetryDue(ctx context.Context, now time.Time) (int, error) {
+	plans, err := w.planRepo.GetAll(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get plans: %w", err)
+	}
+
+	activated := 0
+	for _, plan := range plans {
+		if ctx.Err() != nil {
+			return activated, ctx.Err()
+		}
+
+		state := plan.Activation
+		if plan.Status != domain.PlanStatusCreating || state == nil {
+			continue
+		}
+		if state.NextAttemptAt != nil && now.Before(*state.NextAttemptAt) {
+			continue
+		}
+
+		if err := w.Activate(ctx, plan); err != nil {
+			w.Failed(ctx, plan, err, now)
+			continue
+		}
+		w.activated(ctx, plan, now)
+		activated++
+	}
+
+	return activated, nil
+}
+
+// Activate starts the plan's instances that are not running and adds those
+// not in maintenance to the nginx upstream of their plan type
+func (w *ActivationWorker) Activate(ctx context.Context, plan *domain.ProxyPlan) error {
+	instances, err := w.instanceRepo.GetByPlanID(ctx, plan.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get instances: %w", err)
+	}
+	if len(instances) == 0 {
+		return fmt.Errorf("plan has no instances")
+	}
+
+	for _, instance := range instances {
+		if instance.Status != domain.InstanceStatusRunning {
+			if err := w.proxyService.StartInstance(ctx, instance); err != nil {
+				return fmt.Errorf("failed to start proxy instance: %w", err)
+			}
+		}
+		if instance.Maintenance {
+			continue
+		}
+		if err := w.nginxManager.UpdateUpstream(ctx, instance); err != nil {
+			return fmt.Errorf("failed to update nginx upstream: %w", err)
+		}
+	}
+
+	plan.Instances = instances
+	return nil
+}
+
+// activated marks a plan whose retried activation succeeded active
+func (w *ActivationWorker) activated(ctx context.Context, plan *domain.ProxyPlan, now time.Time) {
+	attempts := plan.Activation.Attempts + 1
+	plan.Status = domain.PlanStatusActive
+	plan.Activation = nil
+	plan.UpdatedAt = now
+	if err := w.planRepo.Update(ctx, plan); err != nil {
+		w.logger.Error("Failed to save activated plan", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+		return
+	}
+
+	w.events.record(ctx, plan.ID, nil, domain.EventPlanActivated, "Plan activated after retrying", map[string]string{
+		"attempt": fmt.Sprint(attempts),
+	})
+	w.logger.Info("Plan activated",
+		zap.String("plan_id", plan.ID.String()),
+		zap.Int("attempt", attempts))
+}
+
+// Failed records a failed activation attempt and schedules the next one, or
+// marks the plan failed and notifies operators once retries are used up.
+// With the worker disabled there are no retries.
+func (w *ActivationWorker) Failed(ctx context.Context, plan *domain.ProxyPlan, cause error, now time.Time) {
+	state := plan.Activation
+	if state == nil {
+		state = &domain.ActivationState{}
+	}
+	state.Attempts++
+	state.LastError = cause.Error()
+	state.LastAttemptAt = now
+	state.NextAttemptAt = nil
+
+	abandoned := w.cfg.Interval <= 0 || state.Attempts > w.cfg.Retries
+	if abandoned {
+		plan.Status = domain.PlanStatusFailed
+	} else {
+		plan.Status = domain.PlanStatusCreating
+		next := now.Add(w.backoff(state.Attempts))
+		state.NextAttemptAt = &next
+	}
+
+	plan.Activation = state
+	plan.UpdatedAt = now
+	if err := w.planRepo.Update(ctx, plan); err != nil {
+		w.logger.Error("Failed to save plan activation state", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+	}
+
+	data := map[string]string{
+		"attempt": fmt.Sprint(state.Attempts),
+		"error":   state.LastError,
+	}
+	if state.NextAttemptAt != nil {
+		data["next_attempt_at"] = state.NextAttemptAt.Format(time.RFC3339)
+	}
+	w.events.record(ctx, plan.ID, nil, domain.EventPlanActivationFailed, "Plan activation failed", data)
+	w.logger.Warn("Plan activation failed",
+		zap.String("plan_id", plan.ID.String()),
+		zap.String("customer_id", plan.CustomerID),
+		zap.Int("attempt", state.Attempts),
+		zap.Bool("abandoned", abandoned),
+		zap.Error(cause),
+	)
+
+	if abandoned {
+		w.notify(ctx, plan, data, now)
+	}
+}
+
+// backoff returns the wait before retrying after the given failed attempt
+func (w *ActivationWorker) backoff(attempt int) time.Duration {
+	backoff := w.cfg.Backoff
+	for i := 1; i < attempt && backoff < maxActivationBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxActivationBackoff {
+		backoff = maxActivationBackoff
+	}
+	return backoff
+}
+
+// notify tells operators that a plan could not be activated
+func (w *ActivationWorker) notify(ctx context.Context, plan *domain.ProxyPlan, data map[string]string, now time.Time) {
+	if w.notifier == nil {
+		return
+	}
+
+	notification := &domain.Notification{
+		Type:       domain.NotificationPlanActivationFailed,
+		PlanID:     plan.ID.String(),
+		CustomerID: plan.CustomerID,
+		Message:    "A new proxy plan could not be activated and was marked failed.",
+		Data:       data,
+		CreatedAt:  now,
+	}
+	if err := w.notifier.Notify(ctx, notification); err != nil {
+		w.logger.Error("Failed to send plan notification",
+			zap.String("type", notification.Type),
+			zap.String("plan_id", plan.ID.String()),
+			zap.Error(err))
+	}
+}
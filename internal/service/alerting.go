@@ -0,0 +1,514 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// Longest alert texts the paging APIs accept
+const (
+	pagerDutyMaxSummary = 1024
+	opsgenieMaxMessage  = 130
+)
+
+// opsgeniePriorities maps alert severities to Opsgenie priorities
+var opsgeniePriorities = map[string]string{
+	domain.AlertSeverityCritical: "P1",
+	domain.AlertSeverityError:    "P2",
+	domain.AlertSeverityWarning:  "P3",
+	domain.AlertSeverityInfo:     "P5",
+}
+
+// alertDestination is a paging service alerts are triggered and resolved at
+type alertDestination interface {
+	Trigger(ctx context.Context, alert *domain.Alert, source string) error
+	Resolve(ctx context.Context, alert *domain.Alert, source string) error
+}
+
+// AlertMonitor pages on-call when a region is down, a provider has an
+// outage or a port pool is exhausted. Every alerting.interval it evaluates
+// the conditions, triggers the ones that started firing at the destinations
+// routed for their severity, and resolves the ones that cleared. Firing
+// alerts are persisted so they are still resolved after a restart.
+// Conditions that only affect plan types under scheduled provider
+// maintenance are not paged.
+type AlertMonitor struct {
+	cfg          config.Alerting
+	logger       *zap.Logger
+	repo         repository.AlertRepository
+	planRepo     repository.PlanRepository
+	instanceRepo repository.InstanceRepository
+	planTypes    *PlanTypeRegistry
+	ports        *PortManager
+	upstreams    *UpstreamProber
+	maintenance  *MaintenanceService
+	destinations map[string]alertDestination
+	source       string
+
+	mu sync.Mutex
+}
+
+// NewAlertMonitor creates a new alert monitor
+func NewAlertMonitor(
+	cfg *config.Config,
+	logger *zap.Logger,
+	repo repository.AlertRepository,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	planTypes *PlanTypeRegistry,
+	ports *PortManager,
+	upstreams *UpstreamProber,
+	maintenance *MaintenanceService,
+) *AlertMonitor {
+	settings := cfg.Alerting
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	destinations := make(map[string]alertDestination)
+	if settings.PagerDuty.RoutingKey != "" {
+		destinations[config.AlertDestinationPagerDuty] = &pagerDutyDestination{cfg: settings.PagerDuty, client: client}
+	}
+	if settings.Opsgenie.APIKey != "" {
+		destinations[config.AlertDestinationOpsgenie] = &opsgenieDestination{cfg: settings.Opsgenie, client: client}
+	}
+
+	source := settings.Source
+	if source == "" {
+		source, _ = os.Hostname()
+	}
+
+	return &AlertMonitor{
+		cfg:          settings,
+		logger:       logger,
+		repo:         repo,
+		planRepo:     planRepo,
+		instanceRepo: instanceRepo,
+		planTypes:    planTypes,
+		ports:        ports,
+		upstreams:    upstreams,
+		maintenance:  maintenance,
+		destinations: destinations,
+		source:       source,
+	}
+}
+
+// Run evaluates the alert conditions every interval until ctx is cancelled
+func (m *AlertMonitor) Run(ctx context.Context) {
+	if m.cfg.Interval <= 0 || len(m.destinations) == 0 {
+		return
+	}
+
+	m.logger.Info("Starting alert monitor",
+		zap.Duration("interval", m.cfg.Interval),
+		zap.Strings("destinations", m.cfg.Destinations()))
+
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Evaluate(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			m.logger.Error("Failed to evaluate alerts", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Active returns the alerts that are firing or still being resolved
+func (m *AlertMonitor) Active(ctx context.Context) ([]*domain.Alert, error) {
+	return m.repo.GetAll(ctx)
+}
+
+// Evaluate triggers the conditions that are firing and resolves the alerts
+// whose condition cleared. Deliveries that fail are retried next time.
+func (m *AlertMonitor) Evaluate(ctx context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	firing, err := m.conditions(ctx)
+	if err != nil {
+		return err
+	}
+	active, err := m.repo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]*domain.Alert, len(active))
+	for _, alert := range active {
+		existing[alert.Key] = alert
+	}
+
+	for _, alert := range firing {
+		if window := m.underMaintenance(ctx, alert, now); window != nil {
+			// Neither page nor resolve; an alert paged before the window
+			// started stays open at the destinations
+			m.logger.Debug("Alert suppressed during provider maintenance",
+				zap.String("key", alert.Key),
+				zap.String("maintenance_id", window.ID.String()))
+			delete(existing, alert.Key)
+			continue
+		}
+		if current := existing[alert.Key]; current != nil && current.ResolvedAt == nil {
+			// Keep the original trigger; only retry destinations it missed
+			alert.TriggeredAt = current.TriggeredAt
+			alert.Destinations = current.Destinations
+		} else {
+			alert.TriggeredAt = now
+			m.logger.Warn("Alert triggered",
+				zap.String("key", alert.Key),
+				zap.String("severity", alert.Severity),
+				zap.String("summary", alert.Summary))
+		}
+		delete(existing, alert.Key)
+		m.trigger(ctx, alert)
+	}
+
+	for _, alert := range existing {
+		m.resolve(ctx, alert, now)
+	}
+	return nil
+}
+
+// trigger sends alert to the routed destinations it has not reached yet
+func (m *AlertMonitor) trigger(ctx context.Context, alert *domain.Alert) {
+	for _, name := range m.cfg.Routes[alert.Severity] {
+		destination := m.destinations[name]
+		if destination == nil || containsString(alert.Destinations, name) {
+			continue
+		}
+		if err := destination.Trigger(ctx, alert, m.source); err != nil {
+			m.logger.Error("Failed to trigger alert",
+				zap.String("key", alert.Key),
+				zap.String("destination", name),
+				zap.Error(err))
+			continue
+		}
+		alert.Destinations = append(alert.Destinations, name)
+	}
+
+	if err := m.repo.Save(ctx, alert); err != nil {
+		m.logger.Error("Failed to save alert", zap.String("key", alert.Key), zap.Error(err))
+	}
+}
+
+// resolve resolves alert at the destinations it was triggered at and
+// forgets it once every one has accepted
+func (m *AlertMonitor) resolve(ctx context.Context, alert *domain.Alert, now time.Time) {
+	if alert.ResolvedAt == nil {
+		alert.ResolvedAt = &now
+		m.logger.Info("Alert resolved", zap.String("key", alert.Key))
+	}
+
+	var pending []string
+	for _, name := range alert.Destinations {
+		destination := m.destinations[name]
+		if destination == nil {
+			continue
+		}
+		if err := destination.Resolve(ctx, alert, m.source); err != nil {
+			m.logger.Error("Failed to resolve alert",
+				zap.String("key", alert.Key),
+				zap.String("destination", name),
+				zap.Error(err))
+			pending = append(pending, name)
+		}
+	}
+	alert.Destinations = pending
+
+	var err error
+	if len(pending) == 0 {
+		err = m.repo.Delete(ctx, alert.Key)
+	} else {
+		err = m.repo.Save(ctx, alert)
+	}
+	if err != nil {
+		m.logger.Error("Failed to save alert", zap.String("key", alert.Key), zap.Error(err))
+	}
+}
+
+// conditions returns an alert for every condition that is firing now
+func (m *AlertMonitor) conditions(ctx context.Context) ([]*domain.Alert, error) {
+	plans, err := m.planRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plans: %w", err)
+	}
+	instances, err := m.instanceRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load instances: %w", err)
+	}
+
+	// Only instances of plans that should be serving count; a failed plan's
+	// instance stays failed and must not hold a region down, nor must an
+	// instance operators put in maintenance
+	serving := make(map[string]bool, len(plans))
+	for _, plan := range plans {
+		if plan.Status == domain.PlanStatusActive || plan.Status == domain.PlanStatusGrace {
+			serving[plan.ID.String()] = true
+		}
+	}
+
+	type health struct{ total, failed int }
+	regions := make(map[string]*health)
+	providers := make(map[string]*health)
+	for _, instance := range instances {
+		if !serving[instance.PlanID.String()] {
+			continue
+		}
+		if instance.Maintenance || (instance.Status != domain.InstanceStatusRunning && instance.Status != domain.InstanceStatusFailed) {
+			continue
+		}
+		planType := m.planTypes.Get(instance.PlanTypeKey)
+		if planType == nil {
+			continue
+		}
+		for key, groups := range map[string]map[string]*health{planType.Region: regions, planType.Provider: providers} {
+			if groups[key] == nil {
+				groups[key] = &health{}
+			}
+			groups[key].total++
+			if instance.Status == domain.InstanceStatusFailed {
+				groups[key].failed++
+			}
+		}
+	}
+
+	var alerts []*domain.Alert
+	for _, region := range sortedKeys(regions) {
+		if h := regions[region]; h.failed == h.total {
+			alerts = append(alerts, m.newAlert(domain.AlertRegionDown, region,
+				fmt.Sprintf("Region %s is down: all %d instances are failing", region, h.total),
+				map[string]string{"region": region, "instances": fmt.Sprint(h.total)}))
+		}
+	}
+
+	unreachable := m.unreachableProviders()
+	for _, provider := range sortedKeys(providers) {
+		if h := providers[provider]; h.failed == h.total {
+			alerts = append(alerts, m.newAlert(domain.AlertProviderOutage, provider,
+				fmt.Sprintf("Provider %s outage: all %d instances are failing", provider, h.total),
+				map[string]string{"provider": provider, "instances": fmt.Sprint(h.total)}))
+			delete(unreachable, provider)
+		}
+	}
+	for _, provider := range sortedKeys(unreachable) {
+		alerts = append(alerts, m.newAlert(domain.AlertProviderOutage, provider,
+			fmt.Sprintf("Provider %s outage: none of its %d upstream hosts is reachable", provider, len(unreachable[provider])),
+			map[string]string{"provider": provider, "upstreams": strings.Join(unreachable[provider], ", ")}))
+	}
+
+	if m.ports != nil {
+		pools := m.ports.GetPoolStats()
+		for _, key := range sortedKeys(pools) {
+			if pool := pools[key]; pool.TotalPorts > 0 && pool.AvailablePorts == 0 {
+				alerts = append(alerts, m.newAlert(domain.AlertPortPoolExhausted, key,
+					fmt.Sprintf("Port pool of plan type %s is exhausted: all %d ports are allocated", key, pool.TotalPorts),
+					map[string]string{"plan_type": key, "ports": fmt.Sprint(pool.TotalPorts)}))
+			}
+		}
+	}
+
+	return alerts, nil
+}
+
+// underMaintenance returns a maintenance window in progress if every plan
+// type the alert concerns is under maintenance, or nil
+func (m *AlertMonitor) underMaintenance(ctx context.Context, alert *domain.Alert, now time.Time) *domain.MaintenanceWindow {
+	var keys []string
+	for key, planType := range m.planTypes.All() {
+		switch alert.Kind {
+		case domain.AlertRegionDown:
+			if planType.Region == alert.Subject {
+				keys = append(keys, key)
+			}
+		case domain.AlertProviderOutage:
+			if planType.Provider == alert.Subject {
+				keys = append(keys, key)
+			}
+		case domain.AlertPortPoolExhausted:
+			if key == alert.Subject {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	var window *domain.MaintenanceWindow
+	for _, key := range keys {
+		window = m.maintenance.Active(ctx, key, now)
+		if window == nil {
+			return nil
+		}
+	}
+	return window
+}
+
+// unreachableProviders returns the providers whose probed upstream hosts
+// all failed their last probe, with those hosts
+func (m *AlertMonitor) unreachableProviders() map[string][]string {
+	unreachable := make(map[string][]string)
+	if m.upstreams == nil {
+		return unreachable
+	}
+
+	probes := make(map[string]UpstreamStats)
+	for _, stats := range m.upstreams.Stats() {
+		probes[stats.Address] = stats
+	}
+
+	reachable := make(map[string]bool)
+	for _, planType := range m.planTypes.All() {
+		for _, address := range upstreamCandidates(planType) {
+			stats, probed := probes[address]
+			if !probed || stats.LastProbedAt.IsZero() {
+				continue
+			}
+			if stats.Healthy() {
+				reachable[planType.Provider] = true
+			} else if !containsString(unreachable[planType.Provider], address) {
+				unreachable[planType.Provider] = append(unreachable[planType.Provider], address)
+			}
+		}
+	}
+	for provider := range reachable {
+		delete(unreachable, provider)
+	}
+	return unreachable
+}
+
+// newAlert returns a firing alert of kind about subject
+func (m *AlertMonitor) newAlert(kind, subject, summary string, details map[string]string) *domain.Alert {
+	severity := m.cfg.Severities[kind]
+	if severity == "" {
+		severity = domain.AlertSeverityCritical
+	}
+	return &domain.Alert{
+		Key:      "oceanproxy:" + kind + ":" + subject,
+		Kind:     kind,
+		Severity: severity,
+		Subject:  subject,
+		Summary:  summary,
+		Details:  details,
+	}
+}
+
+// pagerDutyDestination pages through the PagerDuty Events API v2
+type pagerDutyDestination struct {
+	cfg    config.PagerDutyAlerting
+	client *http.Client
+}
+
+func (d *pagerDutyDestination) Trigger(ctx context.Context, alert *domain.Alert, source string) error {
+	return postAlert(ctx, d.client, d.cfg.URL, nil, map[string]interface{}{
+		"routing_key":  d.cfg.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    alert.Key,
+		"payload": map[string]interface{}{
+			"summary":        truncate(alert.Summary, pagerDutyMaxSummary),
+			"source":         source,
+			"severity":       alert.Severity,
+			"component":      alert.Subject,
+			"group":          alert.Kind,
+			"custom_details": alert.Details,
+		},
+	}, false)
+}
+
+func (d *pagerDutyDestination) Resolve(ctx context.Context, alert *domain.Alert, source string) error {
+	return postAlert(ctx, d.client, d.cfg.URL, nil, map[string]interface{}{
+		"routing_key":  d.cfg.RoutingKey,
+		"event_action": "resolve",
+		"dedup_key":    alert.Key,
+	}, false)
+}
+
+// opsgenieDestination pages through the Opsgenie Alert API, using the
+// dedup key as the alert alias
+type opsgenieDestination struct {
+	cfg    config.OpsgenieAlerting
+	client *http.Client
+}
+
+func (d *opsgenieDestination) Trigger(ctx context.Context, alert *domain.Alert, source string) error {
+	endpoint := strings.TrimRight(d.cfg.URL, "/") + "/v2/alerts"
+	return postAlert(ctx, d.client, endpoint, d.headers(), map[string]interface{}{
+		"message":     truncate(alert.Summary, opsgenieMaxMessage),
+		"alias":       alert.Key,
+		"description": alert.Summary,
+		"priority":    opsgeniePriorities[alert.Severity],
+		"source":      source,
+		"tags":        []string{"oceanproxy", alert.Kind},
+		"details":     alert.Details,
+	}, false)
+}
+
+func (d *opsgenieDestination) Resolve(ctx context.Context, alert *domain.Alert, source string) error {
+	endpoint := fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", strings.TrimRight(d.cfg.URL, "/"), url.PathEscape(alert.Key))
+	// An alert closed by hand is already resolved
+	return postAlert(ctx, d.client, endpoint, d.headers(), map[string]interface{}{
+		"source": source,
+		"note":   "Condition cleared",
+	}, true)
+}
+
+func (d *opsgenieDestination) headers() map[string]string {
+	return map[string]string{"Authorization": "GenieKey " + d.cfg.APIKey}
+}
+
+// postAlert posts a JSON event to a paging API; notFoundOK accepts 404
+func postAlert(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, payload map[string]interface{}, notFoundOK bool) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && notFoundOK {
+		return nil
+	}
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("alert API returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// truncate shortens s to at most limit characters
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// ErrInvalidAPIKey is returned for unknown, revoked or orphaned customer API keys
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+const (
+	// apiKeyPrefix marks OceanProxy customer keys so they are easy to spot in
+	// logs and secret scanners
+	apiKeyPrefix = "opk_"
+
+	// apiKeyDisplayLen is how much of a key is kept to identify it in listings
+	apiKeyDisplayLen = len(apiKeyPrefix) + 8
+
+	// apiKeyTouchInterval limits how often LastUsedAt is written back
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyService issues and verifies read-only API keys that let end
+// customers query their own plan through the portal API
+type APIKeyService struct {
+	logger       *zap.Logger
+	keyRepo      repository.APIKeyRepository
+	planRepo     repository.PlanRepository
+	instanceRepo repository.InstanceRepository
+	accountRepo  repository.ProviderAccountRepository
+	planService  PlanService
+	subUsers     *SubUserService
+	meter        *BandwidthMeter
+	events       *eventRecorder
+}
+
+// NewAPIKeyService creates a new customer API key service
+func NewAPIKeyService(
+	logger *zap.Logger,
+	keyRepo repository.APIKeyRepository,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	accountRepo repository.ProviderAccountRepository,
+	eventRepo repository.PlanEventRepository,
+	planService PlanService,
+	subUsers *SubUserService,
+	meter *BandwidthMeter,
+) *APIKeyService {
+	return &APIKeyService{
+		logger:       logger,
+		keyRepo:      keyRepo,
+		planRepo:     planRepo,
+		instanceRepo: instanceRepo,
+		accountRepo:  accountRepo,
+		planService:  planService,
+		subUsers:     subUsers,
+		meter:        meter,
+		events:       newEventRecorder(eventRepo, logger),
+	}
+}
+
+// IssueKey creates a read-only key for a plan and returns it along with the
+// only copy of the secret
+func (s *APIKeyService) IssueKey(ctx context.Context, planID uuid.UUID, name string) (*domain.APIKey, string, error) {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	token := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key := &domain.APIKey{
+		ID:         uuid.New(),
+		PlanID:     plan.ID,
+		CustomerID: plan.CustomerID,
+		Name:       name,
+		Prefix:     token[:apiKeyDisplayLen],
+		Hash:       hashAPIKey(token),
+		Scope:      domain.APIKeyScopeRead,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.keyRepo.Create(ctx, key); err != nil {
+		return nil, "", fmt.Errorf("failed to store API key: %w", err)
+	}
+
+	s.events.record(ctx, plan.ID, nil, domain.EventAPIKeyIssued, "Customer API key issued", map[string]string{
+		"key_id": key.ID.String(),
+		"prefix": key.Prefix,
+		"scope":  key.Scope,
+	})
+
+	return key, token, nil
+}
+
+// ListKeys returns a plan's keys, including revoked ones, newest first
+func (s *APIKeyService) ListKeys(ctx context.Context, planID uuid.UUID) ([]*domain.APIKey, error) {
+	return s.keyRepo.GetByPlanID(ctx, planID)
+}
+
+// RevokeKey revokes one of a plan's keys; revoking twice is a no-op
+func (s *APIKeyService) RevokeKey(ctx context.Context, planID, keyID uuid.UUID) (*domain.APIKey, error) {
+	key, err := s.keyRepo.GetByID(ctx, keyID)
+	if err != nil || key.PlanID != planID {
+		return nil, domain.NotFoundf("API key not found: %s", keyID)
+	}
+	if !key.Active() {
+		return key, nil
+	}
+
+	now := time.Now()
+	key.RevokedAt = &now
+	if err := s.keyRepo.Update(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	s.events.record(ctx, planID, nil, domain.EventAPIKeyRevoked, "Customer API key revoked", map[string]string{
+		"key_id": key.ID.String(),
+		"prefix": key.Prefix,
+	})
+
+	return key, nil
+}
+
+// Authenticate returns the active key for token. Keys of deleted plans no
+// longer authenticate.
+func (s *APIKeyService) Authenticate(ctx context.Context, token string) (*domain.APIKey, error) {
+	if !strings.HasPrefix(token, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.keyRepo.GetByHash(ctx, hashAPIKey(token))
+	if err != nil || !key.Active() {
+		return nil, ErrInvalidAPIKey
+	}
+	if _, err := s.planRepo.GetByID(ctx, key.PlanID); err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		key.LastUsedAt = &now
+		if err := s.keyRepo.Update(ctx, key); err != nil {
+			s.logger.Debug("Failed to record API key use", zap.String("key_id", key.ID.String()), zap.Error(err))
+		}
+	}
+
+	return key, nil
+}
+
+// Usage returns the usage and endpoints of the plan a key belongs to
+func (s *APIKeyService) Usage(ctx context.Context, key *domain.APIKey) (*domain.PlanUsage, error) {
+	plan, err := s.planRepo.GetByID(ctx, key.PlanID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &domain.PlanUsage{
+		PlanID:      plan.ID,
+		PlanType:    plan.PlanType,
+		Provider:    plan.Provider,
+		Region:      plan.Region,
+		Status:      plan.Status,
+		BandwidthGB: plan.Bandwidth,
+		ExpiresAt:   plan.ExpiresAt,
+		GraceEndsAt: plan.GraceEndsAt,
+		AutoRenew:   plan.AutoRenew,
+		Endpoints:   []domain.ProxyEndpoint{},
+	}
+
+	// Usage is tracked per upstream account and only known after a sync
+	if account, err := s.accountRepo.GetByPlanID(ctx, plan.ID); err == nil && account.UsageSyncedAt != nil {
+		usage.UsedBytes = account.UsedBytes
+		usage.LimitBytes = account.MaxBytes
+		usage.UsageSyncedAt = account.UsageSyncedAt
+	}
+
+	instances, err := s.instanceRepo.GetByPlanID(ctx, plan.ID)
+	if err != nil {
+		s.logger.Debug("Failed to load instances for usage", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+	}
+	for _, instance := range instances {
+		if instance.Status == domain.InstanceStatusRunning {
+			usage.InstancesRunning++
+		}
+	}
+
+	endpoints, err := s.planService.GetPlanEndpoints(ctx, plan.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan endpoints: %w", err)
+	}
+	if endpoints != nil {
+		usage.Endpoints = endpoints
+	}
+
+	// Sub-users are reported without their passwords, which only the plan
+	// API hands out
+	if len(plan.SubUsers) > 0 {
+		subUsers, err := s.subUsers.List(ctx, plan.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sub-users: %w", err)
+		}
+		for i := range subUsers {
+			subUsers[i].Password = ""
+		}
+		usage.SubUsers = subUsers
+	}
+
+	// Traffic measured on the edge, as counted against the bandwidth
+	if s.meter.Metering() {
+		metered, err := s.meter.usage(ctx, plan, time.Now(), false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure plan usage: %w", err)
+		}
+		usage.Metered = metered
+	}
+
+	return usage, nil
+}
+
+// hashAPIKey returns the stored form of a key. Keys carry 256 bits of
+// randomness, so a plain SHA-256 is enough.
+func hashAPIKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// ErrInvalidApplyDocument is returned for an apply document that cannot be
+// reconciled, such as one declaring a plan twice
+var ErrInvalidApplyDocument = errors.New("invalid apply document")
+
+// ApplyService reconciles plans with a declarative document, kubectl-style.
+// Plans it creates are tracked by manifest name, so applying the same
+// document again changes nothing and editing a manifest updates its plan.
+type ApplyService struct {
+	logger *zap.Logger
+	repo   repository.AppliedPlanRepository
+	plans  PlanService
+
+	// mu serializes applies so they do not race on the tracked plans
+	mu sync.Mutex
+}
+
+// NewApplyService creates a new apply service
+func NewApplyService(logger *zap.Logger, repo repository.AppliedPlanRepository, plans PlanService) *ApplyService {
+	return &ApplyService{
+		logger: logger,
+		repo:   repo,
+		plans:  plans,
+	}
+}
+
+// Apply computes the changes that bring the plans of the document's
+// customers in line with it and applies them unless dryRun is set. Plans
+// previously applied for those customers but missing from the document are
+// only reported unless prune is set; plans of other customers are never
+// touched.
+func (s *ApplyService) Apply(ctx context.Context, doc *domain.ApplyDocument, dryRun, prune bool) (*domain.ApplyResult, error) {
+	manifests := doc.Manifests()
+	if err := checkPlanManifests(manifests); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidApplyDocument, err)
+	}
+
+	customers := make(map[string]bool)
+	for _, customer := range doc.Customers {
+		customers[customer.ID] = true
+	}
+	for _, manifest := range manifests {
+		customers[manifest.CustomerID] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tracked, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load applied plans: %w", err)
+	}
+
+	changes := &changeSet{dryRun: dryRun, prune: prune, logger: s.logger}
+	inScope := func(plan *domain.ProxyPlan) bool {
+		return customers[plan.CustomerID]
+	}
+	if err := reconcilePlanManifests(ctx, s.plans, changes, manifests, tracked, inScope); err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		if err := s.repo.SaveAll(ctx, tracked); err != nil {
+			return nil, fmt.Errorf("failed to save applied plans: %w", err)
+		}
+	}
+
+	result := &domain.ApplyResult{
+		DryRun:  dryRun,
+		Changes: changes.list(),
+	}
+	result.Failed = domain.FailedChanges(result.Changes)
+
+	s.logger.Info("Applied plan document",
+		zap.Int("plans", len(manifests)),
+		zap.Int("changes", len(result.Changes)),
+		zap.Int("failed", result.Failed),
+		zap.Bool("dry_run", dryRun),
+		zap.Bool("prune", prune))
+
+	return result, nil
+}
@@ -0,0 +1,403 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+const (
+	// authGuardCommandTimeout bounds each block and unblock command
+	authGuardCommandTimeout = 10 * time.Second
+
+	// authGuardActionACL bans through 3proxy deny rules instead of commands
+	authGuardActionACL = "acl"
+)
+
+// AuthBlock is a source IP blocked on a port for failing authentication
+type AuthBlock struct {
+	IP        string    `json:"ip"`
+	Port      int       `json:"port"`
+	Failures  int       `json:"failures"`
+	BlockedAt time.Time `json:"blocked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// authSource is a client IP connecting to a proxy port
+type authSource struct {
+	ip   string
+	port int
+}
+
+// BanList holds the source IPs banned from proxy ports through 3proxy deny
+// rules. It is read whenever an instance's config is written.
+type BanList struct {
+	mu    sync.RWMutex
+	ports map[int]map[string]bool
+}
+
+// NewBanList creates an empty ban list
+func NewBanList() *BanList {
+	return &BanList{ports: make(map[int]map[string]bool)}
+}
+
+func (b *BanList) add(ip string, port int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.ports[port] == nil {
+		b.ports[port] = make(map[string]bool)
+	}
+	b.ports[port][ip] = true
+}
+
+func (b *BanList) remove(ip string, port int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.ports[port], ip)
+	if len(b.ports[port]) == 0 {
+		delete(b.ports, port)
+	}
+}
+
+// IPs returns the IPs banned from any of ports, sorted
+func (b *BanList) IPs(ports ...int) []string {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var ips []string
+	for _, port := range ports {
+		for ip := range b.ports[port] {
+			if !seen[ip] {
+				seen[ip] = true
+				ips = append(ips, ip)
+			}
+		}
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// AuthGuard watches the 3proxy logs for failed logins and temporarily
+// blocks source IPs that keep failing on a port, fail2ban style. Blocks are
+// firewall commands, or with action acl, deny rules in the 3proxy config of
+// the instance the failures were logged by. 3proxy only sees the real client
+// address on connections that reach it directly; connections relayed by
+// nginx come from loopback, which is never blocked.
+type AuthGuard struct {
+	cfg          config.AuthGuard
+	logDir       string
+	logger       *zap.Logger
+	instanceRepo repository.InstanceRepository
+	proxyService ProxyService
+	bans         *BanList
+	ignore       map[string]bool
+
+	mu        sync.Mutex
+	tailer    *logTailer
+	instances map[authSource]uuid.UUID
+	failures  map[authSource][]time.Time
+	blocks    map[authSource]*AuthBlock
+}
+
+// NewAuthGuard creates an auth failure tracker; it only runs when
+// proxy.auth_guard.enabled is set. bans must be the list proxyService writes
+// configs from.
+func NewAuthGuard(cfg *config.Config, logger *zap.Logger, instanceRepo repository.InstanceRepository, proxyService ProxyService, bans *BanList) *AuthGuard {
+	ignore := make(map[string]bool)
+	for _, ip := range cfg.Proxy.AuthGuard.IgnoreIPs {
+		if parsed := net.ParseIP(ip); parsed != nil {
+			ignore[parsed.String()] = true
+		}
+	}
+
+	return &AuthGuard{
+		cfg:          cfg.Proxy.AuthGuard,
+		logDir:       cfg.Proxy.LogDir,
+		logger:       logger,
+		instanceRepo: instanceRepo,
+		proxyService: proxyService,
+		bans:         bans,
+		ignore:       ignore,
+		tailer:       newLogTailer(),
+		instances:    make(map[authSource]uuid.UUID),
+		failures:     make(map[authSource][]time.Time),
+		blocks:       make(map[authSource]*AuthBlock),
+	}
+}
+
+// Run scans the logs every interval until ctx is cancelled, then lifts all
+// firewall blocks so none outlive the server. ACL bans end with the server's
+// instances, so they are left in place.
+func (g *AuthGuard) Run(ctx context.Context) {
+	if g == nil || !g.cfg.Enabled {
+		return
+	}
+
+	g.logger.Info("Starting proxy auth guard",
+		zap.Duration("interval", g.cfg.Interval),
+		zap.Int("max_failures", g.cfg.MaxFailures),
+		zap.Duration("window", g.cfg.Window),
+		zap.Duration("block_for", g.cfg.BlockFor),
+	)
+
+	ticker := time.NewTicker(g.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := g.Scan(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			g.logger.Error("Failed to scan proxy logs for auth failures", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			g.unblockAll()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan reads new log lines of every running instance, blocks sources that
+// reached max_failures within the window and lifts expired blocks
+func (g *AuthGuard) Scan(ctx context.Context, now time.Time) error {
+	instances, err := g.instanceRepo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get instances: %w", err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, instance := range instances {
+		if instance.Status != domain.InstanceStatusRunning {
+			continue
+		}
+		path := proxyLogPath(g.logDir, instance.ID)
+		if err := g.readLog(path, instance.ID, now); err != nil {
+			g.logger.Debug("Failed to read proxy log", zap.String("path", path), zap.Error(err))
+		}
+	}
+
+	cutoff := now.Add(-g.cfg.Window)
+	for source, times := range g.failures {
+		recent := times[:0]
+		for _, t := range times {
+			if t.After(cutoff) {
+				recent = append(recent, t)
+			}
+		}
+		if len(recent) == 0 {
+			delete(g.failures, source)
+			continue
+		}
+		g.failures[source] = recent
+
+		if len(recent) >= g.cfg.MaxFailures && g.blocks[source] == nil {
+			g.block(ctx, source, len(recent), now)
+		}
+	}
+
+	for source, block := range g.blocks {
+		if !now.Before(block.ExpiresAt) {
+			g.unblock(ctx, source)
+		}
+	}
+
+	return nil
+}
+
+// readLog counts the auth failures logged since the last read. A log seen
+// for the first time is read from its end, so old failures are not counted.
+func (g *AuthGuard) readLog(path string, instanceID uuid.UUID, now time.Time) error {
+	return g.tailer.read(path, false, func(line string) {
+		source, ok := parseAuthFailure(line)
+		if !ok || g.ignored(source.ip) {
+			return
+		}
+		g.failures[source] = append(g.failures[source], now)
+		g.instances[source] = instanceID
+	})
+}
+
+// ignored reports whether ip must never be blocked
+func (g *AuthGuard) ignored(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed == nil || parsed.IsLoopback() || g.ignore[parsed.String()]
+}
+
+// parseAuthFailure extracts the source of a failed login from a 3proxy log
+// line
+func parseAuthFailure(line string) (authSource, bool) {
+	entry, ok := parseProxyLogLine(line)
+	if !ok || !entry.authFailed {
+		return authSource{}, false
+	}
+	return authSource{ip: entry.clientIP, port: entry.port}, true
+}
+
+// block blocks source and records the block
+func (g *AuthGuard) block(ctx context.Context, source authSource, failures int, now time.Time) {
+	if err := g.apply(ctx, source, true); err != nil {
+		g.logger.Error("Failed to block source IP",
+			zap.String("ip", source.ip),
+			zap.Int("port", source.port),
+			zap.Error(err))
+		return
+	}
+
+	g.blocks[source] = &AuthBlock{
+		IP:        source.ip,
+		Port:      source.port,
+		Failures:  failures,
+		BlockedAt: now,
+		ExpiresAt: now.Add(g.cfg.BlockFor),
+	}
+	delete(g.failures, source)
+
+	g.logger.Warn("Blocked source IP after repeated proxy auth failures",
+		zap.String("ip", source.ip),
+		zap.Int("port", source.port),
+		zap.Int("failures", failures),
+		zap.Duration("block_for", g.cfg.BlockFor),
+	)
+}
+
+// unblock lifts the block of source and forgets it. A block that fails to
+// lift is kept and retried on the next scan.
+func (g *AuthGuard) unblock(ctx context.Context, source authSource) error {
+	if err := g.apply(ctx, source, false); err != nil {
+		g.logger.Error("Failed to unblock source IP",
+			zap.String("ip", source.ip),
+			zap.Int("port", source.port),
+			zap.Error(err))
+		return err
+	}
+
+	delete(g.blocks, source)
+	delete(g.instances, source)
+	g.logger.Info("Unblocked source IP", zap.String("ip", source.ip), zap.Int("port", source.port))
+	return nil
+}
+
+// unblockAll lifts every firewall block, for shutdown
+func (g *AuthGuard) unblockAll() {
+	if g.cfg.Action == authGuardActionACL {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), authGuardCommandTimeout)
+	defer cancel()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for source := range g.blocks {
+		g.unblock(ctx, source)
+	}
+}
+
+// apply bans or unbans source with the configured action. An ACL change
+// restarts the instance that logged the failures so 3proxy picks it up; if
+// that fails, or the instance is in maintenance, the ban still applies from
+// the instance's next start.
+func (g *AuthGuard) apply(ctx context.Context, source authSource, ban bool) error {
+	if g.cfg.Action != authGuardActionACL {
+		command := g.cfg.UnblockCommand
+		if ban {
+			command = g.cfg.BlockCommand
+		}
+		return g.run(ctx, command, source)
+	}
+
+	if ban {
+		g.bans.add(source.ip, source.port)
+	} else {
+		g.bans.remove(source.ip, source.port)
+	}
+
+	instanceID, ok := g.instances[source]
+	if !ok {
+		return nil
+	}
+	instance, err := g.proxyService.GetInstance(ctx, instanceID)
+	if err == nil {
+		err = restartAutomated(ctx, g.proxyService, g.logger, instance, "auth guard ACL change")
+	}
+	if err != nil {
+		g.logger.Error("Failed to restart instance to apply ban",
+			zap.String("instance_id", instanceID.String()),
+			zap.String("ip", source.ip),
+			zap.Error(err))
+	}
+	return nil
+}
+
+// run executes a block or unblock command for source
+func (g *AuthGuard) run(ctx context.Context, command []string, source authSource) error {
+	replacer := strings.NewReplacer("{ip}", source.ip, "{port}", strconv.Itoa(source.port))
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = replacer.Replace(arg)
+	}
+
+	_, err := runCommand(ctx, authGuardCommandTimeout, args[0], args[1:]...)
+	return err
+}
+
+// Blocks returns the current blocks, soonest to expire first
+func (g *AuthGuard) Blocks() []AuthBlock {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	blocks := make([]AuthBlock, 0, len(g.blocks))
+	for _, block := range g.blocks {
+		blocks = append(blocks, *block)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].ExpiresAt.Before(blocks[j].ExpiresAt)
+	})
+	return blocks
+}
+
+// Unblock lifts every block of ip ahead of time and returns how many were
+// lifted
+func (g *AuthGuard) Unblock(ctx context.Context, ip string) (int, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return 0, fmt.Errorf("invalid IP address %q", ip)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	lifted := 0
+	for source := range g.blocks {
+		if source.ip != parsed.String() {
+			continue
+		}
+		if err := g.unblock(ctx, source); err != nil {
+			return lifted, err
+		}
+		lifted++
+	}
+	return lifted, nil
+}
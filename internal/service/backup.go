@@ -0,0 +1,447 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/pkg/config"
+	"github.com/je265/oceanproxy/pkg/s3"
+)
+
+const (
+	backupTimeFormat = "20060102T150405Z"
+	backupSuffix     = ".tar.gz.enc"
+	maxBackupFile    = 256 << 20
+)
+
+// backupMagic prefixes encrypted snapshots and is authenticated with them
+var backupMagic = []byte("OPBK1")
+
+// Snapshot archive directories and the config directory each restores into
+const (
+	backupDirData   = "data"
+	backupDir3proxy = "3proxy"
+	backupDirNginx  = "nginx"
+)
+
+// BackupService uploads encrypted snapshots of the repository data files and
+// generated 3proxy and nginx configs to S3-compatible storage, and restores them
+type BackupService struct {
+	cfg    *config.Config
+	logger *zap.Logger
+}
+
+// NewBackupService creates a new backup service
+func NewBackupService(cfg *config.Config, logger *zap.Logger) *BackupService {
+	return &BackupService{
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+func (b *BackupService) client() (*s3.Client, error) {
+	return b.clientFor(b.cfg.Backup.Bucket)
+}
+
+// clientFor uses the configured storage credentials for any bucket
+func (b *BackupService) clientFor(bucket string) (*s3.Client, error) {
+	c := b.cfg.Backup
+	client, err := s3.New(s3.Config{
+		Endpoint:  c.Endpoint,
+		Region:    c.Region,
+		Bucket:    bucket,
+		AccessKey: c.AccessKey,
+		SecretKey: c.SecretKey,
+		PathStyle: c.PathStyle,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup storage: %w", err)
+	}
+	return client, nil
+}
+
+// hostPrefix is where this node's snapshots are stored
+func (b *BackupService) hostPrefix() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return b.cfg.Backup.Prefix + host + "/"
+}
+
+// Run takes a backup every interval until ctx is cancelled
+func (b *BackupService) Run(ctx context.Context) {
+	if !b.cfg.Backup.Enabled || b.cfg.Backup.Interval <= 0 {
+		return
+	}
+
+	b.logger.Info("Starting scheduled backups",
+		zap.String("bucket", b.cfg.Backup.Bucket),
+		zap.Duration("interval", b.cfg.Backup.Interval))
+
+	ticker := time.NewTicker(b.cfg.Backup.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := b.BackupNow(ctx); err != nil {
+			b.logger.Error("Scheduled backup failed", zap.Error(err))
+		}
+	}
+}
+
+// BackupNow uploads a snapshot, applies the retention policy and returns the
+// object key
+func (b *BackupService) BackupNow(ctx context.Context) (string, error) {
+	client, err := b.client()
+	if err != nil {
+		return "", err
+	}
+
+	archive, files, err := b.snapshot()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := b.encrypt(archive)
+	if err != nil {
+		return "", err
+	}
+
+	key := b.hostPrefix() + time.Now().UTC().Format(backupTimeFormat) + backupSuffix
+	if err := client.Put(ctx, key, sealed); err != nil {
+		return "", fmt.Errorf("failed to upload backup: %w", err)
+	}
+
+	b.logger.Info("Backup uploaded",
+		zap.String("key", key),
+		zap.Int("files", files),
+		zap.Int("bytes", len(sealed)))
+
+	if deleted, err := b.Prune(ctx); err != nil {
+		b.logger.Warn("Failed to apply backup retention", zap.Error(err))
+	} else if deleted > 0 {
+		b.logger.Info("Pruned old backups", zap.Int("deleted", deleted))
+	}
+
+	return key, nil
+}
+
+// List returns this node's snapshots, oldest first
+func (b *BackupService) List(ctx context.Context) ([]s3.Object, error) {
+	client, err := b.client()
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := client.List(ctx, b.hostPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var backups []s3.Object
+	for _, object := range objects {
+		if strings.HasSuffix(object.Key, backupSuffix) {
+			backups = append(backups, object)
+		}
+	}
+
+	// Keys embed the UTC timestamp, so lexical order is chronological
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Key < backups[j].Key })
+	return backups, nil
+}
+
+// Prune deletes snapshots beyond backup.keep_last or older than backup.max_age.
+// The newest snapshot is always kept.
+func (b *BackupService) Prune(ctx context.Context) (int, error) {
+	backups, err := b.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	client, err := b.client()
+	if err != nil {
+		return 0, err
+	}
+
+	keepLast := b.cfg.Backup.KeepLast
+	maxAge := b.cfg.Backup.MaxAge
+	now := time.Now()
+
+	deleted := 0
+	for i, object := range backups {
+		newerCount := len(backups) - 1 - i
+		if newerCount == 0 {
+			break
+		}
+
+		expired := (keepLast > 0 && newerCount >= keepLast) ||
+			(maxAge > 0 && now.Sub(backupTime(object)) > maxAge)
+		if !expired {
+			continue
+		}
+
+		if err := client.Delete(ctx, object.Key); err != nil {
+			return deleted, fmt.Errorf("failed to delete backup %s: %w", object.Key, err)
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// backupTime reads the snapshot time from its key, falling back to the
+// object's modification time
+func backupTime(object s3.Object) time.Time {
+	name := strings.TrimSuffix(filepath.Base(object.Key), backupSuffix)
+	if t, err := time.Parse(backupTimeFormat, name); err == nil {
+		return t
+	}
+	return object.LastModified
+}
+
+// Restore downloads a snapshot and writes its files back into the configured
+// directories. source is an s3://bucket/key URL, a key in the backup bucket,
+// or "latest" for this node's newest snapshot. It returns the restored paths.
+func (b *BackupService) Restore(ctx context.Context, source string) ([]string, error) {
+	bucket, key := b.cfg.Backup.Bucket, source
+	switch {
+	case strings.HasPrefix(source, "s3://"):
+		var err error
+		if bucket, key, err = s3.ParseURL(source); err != nil {
+			return nil, err
+		}
+	case source == "latest":
+		backups, err := b.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(backups) == 0 {
+			return nil, fmt.Errorf("no backups found under %s", b.hostPrefix())
+		}
+		key = backups[len(backups)-1].Key
+	}
+
+	client, err := b.clientFor(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := client.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download backup: %w", err)
+	}
+
+	archive, err := b.decrypt(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	restored, err := b.extract(archive)
+	if err != nil {
+		return restored, err
+	}
+
+	b.logger.Info("Backup restored", zap.String("key", key), zap.Int("files", len(restored)))
+	return restored, nil
+}
+
+// backupDirs maps archive directories to the configured directories and
+// the file name patterns collected from them
+func (b *BackupService) backupDirs() map[string]struct{ dir, pattern string } {
+	dataFile := b.cfg.Database.JSONFile()
+	return map[string]struct{ dir, pattern string }{
+		// The JSON repositories store each collection next to the data file
+		// with a suffix; PostgreSQL data is backed up with the database
+		backupDirData:   {filepath.Dir(dataFile), filepath.Base(dataFile) + "*"},
+		backupDir3proxy: {b.cfg.Proxy.ConfigDir, "3proxy_*.cfg"},
+		backupDirNginx:  {b.cfg.Proxy.NginxConfDir, "oceanproxy*.conf"},
+	}
+}
+
+// snapshot builds a gzipped tarball of the files to back up
+func (b *BackupService) snapshot() ([]byte, int, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	files := 0
+	for archiveDir, source := range b.backupDirs() {
+		matches, err := filepath.Glob(filepath.Join(source.dir, source.pattern))
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid backup pattern: %w", err)
+		}
+
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+
+			header := &tar.Header{
+				Name:    archiveDir + "/" + filepath.Base(path),
+				Mode:    int64(info.Mode().Perm()),
+				Size:    int64(len(data)),
+				ModTime: info.ModTime(),
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return nil, 0, fmt.Errorf("failed to write archive: %w", err)
+			}
+			if _, err := tw.Write(data); err != nil {
+				return nil, 0, fmt.Errorf("failed to write archive: %w", err)
+			}
+			files++
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	return buf.Bytes(), files, nil
+}
+
+// extract writes archive entries into their configured directories, each
+// file atomically
+func (b *BackupService) extract(archive []byte) ([]string, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup archive: %w", err)
+	}
+	defer gz.Close()
+
+	dirs := b.backupDirs()
+	var restored []string
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return restored, nil
+		}
+		if err != nil {
+			return restored, fmt.Errorf("failed to read backup archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		archiveDir, name, ok := strings.Cut(header.Name, "/")
+		target, known := dirs[archiveDir]
+		if !ok || !known || name == "" || name != filepath.Base(name) || name == ".." {
+			return restored, fmt.Errorf("unexpected backup entry %q", header.Name)
+		}
+
+		data, err := io.ReadAll(io.LimitReader(tr, maxBackupFile))
+		if err != nil {
+			return restored, fmt.Errorf("failed to read backup entry %s: %w", header.Name, err)
+		}
+
+		path := filepath.Join(target.dir, name)
+		if err := writeFileAtomic(path, data, os.FileMode(header.Mode).Perm()); err != nil {
+			return restored, err
+		}
+		restored = append(restored, path)
+	}
+}
+
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+func (b *BackupService) cipher() (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(b.cfg.Backup.EncryptionKey)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("backup.encryption_key must be a base64 encoded 32-byte key")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt seals data with AES-256-GCM as magic || nonce || ciphertext
+func (b *BackupService) encrypt(data []byte) ([]byte, error) {
+	aead, err := b.cipher()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := append([]byte{}, backupMagic...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, backupMagic), nil
+}
+
+func (b *BackupService) decrypt(sealed []byte) ([]byte, error) {
+	aead, err := b.cipher()
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(sealed, backupMagic) || len(sealed) < len(backupMagic)+aead.NonceSize() {
+		return nil, fmt.Errorf("not an oceanproxy backup")
+	}
+	sealed = sealed[len(backupMagic):]
+
+	data, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], backupMagic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt backup (wrong encryption key?): %w", err)
+	}
+	return data, nil
+}
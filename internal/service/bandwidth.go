@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// BandwidthMeter measures each plan's traffic from the stats collected off
+// its 3proxy logs, independently of what the upstream provider reports.
+// With stats.enforce_bandwidth set, plans that have used their bandwidth
+// since creation or their last renewal are suspended: their instances deny
+// every request, answering plain HTTP with the renewal notice when the
+// teaser page is enabled. Topping up or renewing brings them back. Plan
+// types with an accounting schedule count traffic at the rate of the
+// window it was sent in.
+type BandwidthMeter struct {
+	cfg          config.Stats
+	timezone     string
+	logger       *zap.Logger
+	planRepo     repository.PlanRepository
+	instanceRepo repository.InstanceRepository
+	statsRepo    repository.StatsRepository
+	stats        *StatsService
+	planTypes    *PlanTypeRegistry
+	proxyService ProxyService
+	events       *eventRecorder
+	notifier     Notifier
+}
+
+// NewBandwidthMeter creates a new bandwidth meter
+func NewBandwidthMeter(
+	cfg *config.Config,
+	logger *zap.Logger,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	statsRepo repository.StatsRepository,
+	eventRepo repository.PlanEventRepository,
+	stats *StatsService,
+	planTypes *PlanTypeRegistry,
+	proxyService ProxyService,
+	notifier Notifier,
+) *BandwidthMeter {
+	return &BandwidthMeter{
+		cfg:          cfg.Stats,
+		timezone:     cfg.Billing.Timezone,
+		logger:       logger,
+		planRepo:     planRepo,
+		instanceRepo: instanceRepo,
+		statsRepo:    statsRepo,
+		stats:        stats,
+		planTypes:    planTypes,
+		proxyService: proxyService,
+		events:       newEventRecorder(eventRepo, logger),
+		notifier:     notifier,
+	}
+}
+
+// Metering reports whether traffic is collected for the meter to measure
+func (m *BandwidthMeter) Metering() bool {
+	return m != nil && m.cfg.CollectInterval > 0
+}
+
+// Run enforces plan bandwidth every collect interval until ctx is cancelled
+func (m *BandwidthMeter) Run(ctx context.Context) {
+	if m == nil || !m.cfg.EnforceBandwidth || m.cfg.CollectInterval <= 0 {
+		return
+	}
+
+	m.logger.Info("Starting bandwidth enforcement",
+		zap.Duration("interval", m.cfg.CollectInterval))
+
+	ticker := time.NewTicker(m.cfg.CollectInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.Enforce(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			m.logger.Error("Failed to enforce plan bandwidth", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Usage returns a plan's measured traffic, in total and per instance, since
+// its metering start
+func (m *BandwidthMeter) Usage(ctx context.Context, planID uuid.UUID, now time.Time) (*domain.EdgeUsage, error) {
+	plan, err := m.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	return m.usage(ctx, plan, now, true)
+}
+
+// usage measures plan's traffic; instances are only broken down when asked
+func (m *BandwidthMeter) usage(ctx context.Context, plan *domain.ProxyPlan, now time.Time, instances bool) (*domain.EdgeUsage, error) {
+	since := plan.MeteringStart()
+	usage := &domain.EdgeUsage{
+		PlanID:      plan.ID,
+		Status:      plan.Status,
+		Since:       since,
+		BandwidthGB: plan.Bandwidth,
+		LimitBytes:  plan.LimitBytes(),
+	}
+
+	if instances {
+		planInstances, err := m.instanceRepo.GetByPlanID(ctx, plan.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get plan instances: %w", err)
+		}
+		usage.Instances = make([]domain.InstanceEdgeUsage, 0, len(planInstances))
+		for _, instance := range planInstances {
+			usage.Instances = append(usage.Instances, domain.InstanceEdgeUsage{
+				InstanceID: instance.ID,
+				Status:     instance.Status,
+			})
+		}
+	}
+
+	var err error
+	if planType := m.planTypes.Get(plan.PlanTypeKey); planType != nil && planType.Accounting != nil {
+		err = m.weigh(ctx, usage, planType.Accounting, now)
+	} else {
+		err = m.total(ctx, usage, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	usage.Exceeded = usage.LimitBytes > 0 && usage.CountedBytes >= usage.LimitBytes
+	return usage, nil
+}
+
+// total counts all of the plan's traffic, and that of its instances
+func (m *BandwidthMeter) total(ctx context.Context, usage *domain.EdgeUsage, now time.Time) error {
+	resolution := m.stats.Resolution(usage.Since)
+
+	stats, err := m.statsRepo.GetPlanStats(ctx, usage.PlanID, usage.Since, now, resolution)
+	if err != nil {
+		return fmt.Errorf("failed to get plan stats: %w", err)
+	}
+	usage.Requests = stats.TotalRequests
+	usage.BytesIn = stats.BytesIn
+	usage.BytesOut = stats.BytesOut
+	usage.CountedBytes = usage.UsedBytes()
+
+	for i := range usage.Instances {
+		instance := &usage.Instances[i]
+		stats, err := m.statsRepo.GetInstanceStats(ctx, instance.InstanceID, usage.Since, now, resolution)
+		if err != nil {
+			return fmt.Errorf("failed to get instance stats: %w", err)
+		}
+		instance.Requests = stats.TotalRequests
+		instance.BytesIn = stats.BytesIn
+		instance.BytesOut = stats.BytesOut
+		instance.CountedBytes = stats.BytesIn + stats.BytesOut
+	}
+	return nil
+}
+
+// weigh counts the plan's traffic, and that of its instances, at the rate
+// of the accounting window it was sent in
+func (m *BandwidthMeter) weigh(ctx context.Context, usage *domain.EdgeUsage, schedule *domain.AccountingSchedule, now time.Time) error {
+	fallback := time.Local
+	if m.timezone != "" {
+		var err error
+		if fallback, err = time.LoadLocation(m.timezone); err != nil {
+			return fmt.Errorf("failed to load billing timezone: %w", err)
+		}
+	}
+	clock, err := schedule.Clock(fallback)
+	if err != nil {
+		return err
+	}
+
+	traffic, err := m.statsRepo.GetPlanTraffic(ctx, usage.PlanID, usage.Since, now, m.stats.Resolution(usage.Since))
+	if err != nil {
+		return fmt.Errorf("failed to get plan traffic: %w", err)
+	}
+
+	weigher := newTrafficWeigher(schedule, clock)
+	for _, period := range traffic {
+		weigher.add(period)
+	}
+	weigher.apply(usage)
+	return nil
+}
+
+// Enforce suspends the active and grace plans that have used their
+// bandwidth and returns them
+func (m *BandwidthMeter) Enforce(ctx context.Context, now time.Time) ([]*domain.ProxyPlan, error) {
+	plans, err := m.planRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plans: %w", err)
+	}
+
+	var suspended []*domain.ProxyPlan
+	for _, plan := range plans {
+		if plan.Bandwidth <= 0 || (plan.Status != domain.PlanStatusActive && plan.Status != domain.PlanStatusGrace) {
+			continue
+		}
+
+		usage, err := m.usage(ctx, plan, now, false)
+		if err != nil {
+			m.logger.Error("Failed to measure plan bandwidth", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+			continue
+		}
+		if !usage.Exceeded {
+			continue
+		}
+
+		if m.suspend(ctx, plan, usage, now) {
+			suspended = append(suspended, plan)
+		}
+	}
+
+	return suspended, nil
+}
+
+// suspend moves a plan over its bandwidth to suspended, notifies the
+// customer and restarts its instances to stop serving it
+func (m *BandwidthMeter) suspend(ctx context.Context, plan *domain.ProxyPlan, usage *domain.EdgeUsage, now time.Time) bool {
+	previous := plan.Status
+	plan.Status = domain.PlanStatusSuspended
+	plan.UpdatedAt = now
+	if err := m.planRepo.Update(ctx, plan); err != nil {
+		m.logger.Error("Failed to suspend plan", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+		return false
+	}
+
+	data := map[string]string{
+		"from":          previous,
+		"to":            plan.Status,
+		"used_bytes":    fmt.Sprint(usage.UsedBytes()),
+		"counted_bytes": fmt.Sprint(usage.CountedBytes),
+		"limit_bytes":   fmt.Sprint(usage.LimitBytes),
+		"since":         usage.Since.Format(time.RFC3339),
+		"topup_path":    fmt.Sprintf("/api/v1/plans/%s/topup", plan.ID),
+	}
+	m.events.record(ctx, plan.ID, nil, domain.EventPlanSuspended, "Plan used its bandwidth and was suspended", data)
+	m.logger.Warn("Plan bandwidth exceeded, suspended",
+		zap.String("plan_id", plan.ID.String()),
+		zap.String("customer_id", plan.CustomerID),
+		zap.Int64("used_bytes", usage.UsedBytes()),
+		zap.Int64("counted_bytes", usage.CountedBytes),
+		zap.Int64("limit_bytes", usage.LimitBytes),
+	)
+
+	if m.notifier != nil {
+		notification := &domain.Notification{
+			Type:       domain.NotificationPlanSuspended,
+			PlanID:     plan.ID.String(),
+			CustomerID: plan.CustomerID,
+			Message:    "Your proxy plan has used all of its bandwidth and was suspended. Top it up to restore service.",
+			Data:       data,
+			CreatedAt:  now,
+		}
+		if err := m.notifier.Notify(ctx, notification); err != nil {
+			m.logger.Error("Failed to send suspension notification",
+				zap.String("plan_id", plan.ID.String()),
+				zap.Error(err))
+		}
+	}
+
+	instances, err := m.instanceRepo.GetByPlanID(ctx, plan.ID)
+	if err != nil {
+		m.logger.Error("Failed to get instances of suspended plan", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+		return true
+	}
+	for _, instance := range instances {
+		if instance.Status != domain.InstanceStatusRunning {
+			continue
+		}
+		if err := restartAutomated(ctx, m.proxyService, m.logger, instance, "bandwidth suspension"); err != nil {
+			m.logger.Error("Failed to restart instance of suspended plan",
+				zap.String("plan_id", plan.ID.String()),
+				zap.String("instance_id", instance.ID.String()),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return true
+}
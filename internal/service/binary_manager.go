@@ -0,0 +1,248 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+const (
+	// proxyBinaryName is run from PATH when no binary is configured or managed
+	proxyBinaryName = "3proxy"
+
+	binaryDownloadTimeout = 5 * time.Minute
+	binaryVersionTimeout  = 5 * time.Second
+	maxBinarySize         = 64 << 20
+)
+
+var proxyVersionPattern = regexp.MustCompile(`3proxy[- ]?v?(\d+(?:\.\d+)+)|(\d+\.\d+(?:\.\d+)?)`)
+
+// BinaryManager resolves the 3proxy binary, provisions a pinned release
+// into the managed directory and verifies the version it reports
+type BinaryManager struct {
+	cfg    config.ProxyBinary
+	logger *zap.Logger
+
+	mu      sync.RWMutex
+	version string
+	err     error
+}
+
+// NewBinaryManager creates a new 3proxy binary manager
+func NewBinaryManager(cfg *config.Config, logger *zap.Logger) *BinaryManager {
+	return &BinaryManager{
+		cfg:    cfg.Proxy.Binary,
+		logger: logger,
+	}
+}
+
+// Path returns the binary instances are started with: the configured path,
+// the managed binary of the pinned version once installed, or 3proxy from PATH
+func (m *BinaryManager) Path() string {
+	if m == nil {
+		return proxyBinaryName
+	}
+	if m.cfg.Path != "" {
+		return m.cfg.Path
+	}
+	if m.cfg.Version != "" {
+		if _, err := os.Stat(m.managedPath()); err == nil {
+			return m.managedPath()
+		}
+	}
+	return proxyBinaryName
+}
+
+func (m *BinaryManager) managedPath() string {
+	return filepath.Join(m.cfg.ManagedDir, "3proxy-"+m.cfg.Version, proxyBinaryName)
+}
+
+// Ensure installs the pinned release if it is missing, then checks the
+// version the binary reports. The result is kept for Status.
+func (m *BinaryManager) Ensure(ctx context.Context) error {
+	err := m.ensure(ctx)
+
+	m.mu.Lock()
+	m.err = err
+	m.mu.Unlock()
+
+	return err
+}
+
+func (m *BinaryManager) ensure(ctx context.Context) error {
+	if m.cfg.Version != "" && m.cfg.Path == "" {
+		if _, err := os.Stat(m.managedPath()); os.IsNotExist(err) {
+			if err := m.install(ctx); err != nil {
+				return fmt.Errorf("failed to install 3proxy %s: %w", m.cfg.Version, err)
+			}
+		}
+	}
+
+	path := m.Path()
+	version, err := m.detectVersion(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.version = version
+	m.mu.Unlock()
+
+	if m.cfg.Version != "" && version != m.cfg.Version {
+		return fmt.Errorf("3proxy at %s reports version %s, pinned %s", path, version, m.cfg.Version)
+	}
+
+	m.logger.Info("Using 3proxy binary", zap.String("path", path), zap.String("version", version))
+	return nil
+}
+
+// Status returns the detected version and the last Ensure error
+func (m *BinaryManager) Status() (string, error) {
+	if m == nil {
+		return "", nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.version, m.err
+}
+
+// detectVersion parses the version from the binary's usage banner. 3proxy
+// has no version flag; an unknown argument makes it print the banner and exit.
+func (m *BinaryManager) detectVersion(ctx context.Context, path string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, binaryVersionTimeout)
+	defer cancel()
+
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return "", fmt.Errorf("3proxy binary not found: %w", err)
+	}
+
+	// The exit status is non-zero for the usage banner, so only the output matters
+	output, _ := exec.CommandContext(ctx, resolved, "--help").CombinedOutput()
+	match := proxyVersionPattern.FindStringSubmatch(string(output))
+	if match == nil {
+		return "", fmt.Errorf("could not determine version of %s", resolved)
+	}
+	if match[1] != "" {
+		return match[1], nil
+	}
+	return match[2], nil
+}
+
+// install downloads the pinned release, verifies its checksum and moves the
+// binary into place atomically
+func (m *BinaryManager) install(ctx context.Context) error {
+	if m.cfg.URL == "" {
+		return fmt.Errorf("proxy.binary.url is not set")
+	}
+	url := strings.ReplaceAll(m.cfg.URL, "{version}", m.cfg.Version)
+
+	m.logger.Info("Downloading 3proxy release", zap.String("version", m.cfg.Version), zap.String("url", url))
+
+	ctx, cancel := context.WithTimeout(ctx, binaryDownloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build download request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBinarySize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read download: %w", err)
+	}
+	if len(data) > maxBinarySize {
+		return fmt.Errorf("download exceeds %d bytes", maxBinarySize)
+	}
+
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, m.cfg.SHA256) {
+		return fmt.Errorf("checksum mismatch: got %s, want %s", got, m.cfg.SHA256)
+	}
+
+	binary := data
+	if strings.HasSuffix(url, ".tar.gz") || strings.HasSuffix(url, ".tgz") {
+		if binary, err = extractBinary(data, proxyBinaryName); err != nil {
+			return err
+		}
+	}
+
+	dir := filepath.Dir(m.managedPath())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create managed dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".3proxy-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write binary: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return fmt.Errorf("failed to make binary executable: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.managedPath()); err != nil {
+		return fmt.Errorf("failed to install binary: %w", err)
+	}
+
+	m.logger.Info("Installed 3proxy release", zap.String("path", m.managedPath()))
+	return nil
+}
+
+// extractBinary returns the regular file named name from a gzipped tarball
+func extractBinary(archive []byte, name string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("archive has no %s binary", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg || filepath.Base(header.Name) != name {
+			continue
+		}
+		return io.ReadAll(io.LimitReader(tr, maxBinarySize))
+	}
+}
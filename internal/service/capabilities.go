@@ -0,0 +1,76 @@
+package service
+
+import (
+	"sort"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// CapabilityService builds the capability matrix and endpoint map from the
+// configured plan types, their regions and the features their providers declare
+type CapabilityService struct {
+	providers ProviderService
+	planTypes *PlanTypeRegistry
+	regions   *RegionRegistry
+	payments  bool
+}
+
+// NewCapabilityService creates a new capability service
+func NewCapabilityService(cfg *config.Config, providers ProviderService, planTypes *PlanTypeRegistry, regions *RegionRegistry) *CapabilityService {
+	return &CapabilityService{
+		providers: providers,
+		planTypes: planTypes,
+		regions:   regions,
+		payments:  cfg.Billing.Payment.URL != "",
+	}
+}
+
+// Matrix lists every configured plan type its provider sells, with the
+// regions it is offered in. Providers and plan types are sorted by name.
+func (s *CapabilityService) Matrix() *domain.CapabilityMatrix {
+	byProvider := make(map[string]map[string]*domain.PlanTypeCapabilities)
+	for _, planType := range s.planTypes.All() {
+		features, ok := s.providers.Capabilities(planType.Provider, planType.PlanType)
+		if !ok {
+			continue
+		}
+
+		planTypes := byProvider[planType.Provider]
+		if planTypes == nil {
+			planTypes = make(map[string]*domain.PlanTypeCapabilities)
+			byProvider[planType.Provider] = planTypes
+		}
+		capabilities := planTypes[planType.PlanType]
+		if capabilities == nil {
+			// Renewal charges the customer, so it needs billing.payment
+			features.Renewal = features.Renewal && s.payments
+			capabilities = &domain.PlanTypeCapabilities{PlanType: planType.PlanType, Features: features}
+			planTypes[planType.PlanType] = capabilities
+		}
+
+		socks := planType.Proxy != nil && planType.Proxy.SOCKS != nil && planType.Proxy.SOCKS.Enabled
+		capabilities.Regions = append(capabilities.Regions, domain.RegionCapabilities{Region: planType.Region, SOCKS5: socks})
+		capabilities.Features.SOCKS5 = capabilities.Features.SOCKS5 || socks
+	}
+
+	matrix := &domain.CapabilityMatrix{Providers: []domain.ProviderCapabilities{}}
+	for provider, planTypes := range byProvider {
+		entry := domain.ProviderCapabilities{Provider: provider}
+		for _, capabilities := range planTypes {
+			sort.Slice(capabilities.Regions, func(i, j int) bool {
+				return capabilities.Regions[i].Region < capabilities.Regions[j].Region
+			})
+			entry.PlanTypes = append(entry.PlanTypes, *capabilities)
+		}
+		sort.Slice(entry.PlanTypes, func(i, j int) bool {
+			return entry.PlanTypes[i].PlanType < entry.PlanTypes[j].PlanType
+		})
+		matrix.Providers = append(matrix.Providers, entry)
+	}
+	sort.Slice(matrix.Providers, func(i, j int) bool {
+		return matrix.Providers[i].Provider < matrix.Providers[j].Provider
+	})
+
+	return matrix
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+// cpuPeriodMicros is the cgroup v2 cpu.max period used for all instances
+const cpuPeriodMicros = 100000
+
+// cgroupManager places 3proxy processes into per-instance cgroup v2 groups
+type cgroupManager struct {
+	root   string
+	logger *zap.Logger
+}
+
+func newCgroupManager(root string, logger *zap.Logger) *cgroupManager {
+	return &cgroupManager{
+		root:   root,
+		logger: logger,
+	}
+}
+
+// Prepare creates the instance's cgroup and writes its limits. It returns
+// the opened cgroup directory, for the process to be started straight into
+// it with launchIntoCgroup; the caller closes it once the process started.
+func (m *cgroupManager) Prepare(instanceID string, limits *domain.ResourceLimits) (*os.File, error) {
+	if m.root == "" {
+		return nil, fmt.Errorf("cgroup root is not configured")
+	}
+
+	if err := os.MkdirAll(m.root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cgroup root: %w", err)
+	}
+
+	// Delegate the cpu and memory controllers to instance groups
+	if err := writeCgroupFile(filepath.Join(m.root, "cgroup.subtree_control"), "+cpu +memory"); err != nil {
+		return nil, fmt.Errorf("failed to enable cgroup controllers: %w", err)
+	}
+
+	dir := m.path(instanceID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create instance cgroup: %w", err)
+	}
+
+	if limits.CPUPercent > 0 {
+		quota := limits.CPUPercent * cpuPeriodMicros / 100
+		if err := writeCgroupFile(filepath.Join(dir, "cpu.max"), fmt.Sprintf("%d %d", quota, cpuPeriodMicros)); err != nil {
+			return nil, fmt.Errorf("failed to set cpu limit: %w", err)
+		}
+	}
+
+	if limits.MemoryMB > 0 {
+		bytes := int64(limits.MemoryMB) * 1024 * 1024
+		if err := writeCgroupFile(filepath.Join(dir, "memory.max"), strconv.FormatInt(bytes, 10)); err != nil {
+			return nil, fmt.Errorf("failed to set memory limit: %w", err)
+		}
+	}
+
+	group, err := os.Open(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open instance cgroup: %w", err)
+	}
+
+	m.logger.Debug("Prepared cgroup limits",
+		zap.String("instance_id", instanceID),
+		zap.Int("cpu_percent", limits.CPUPercent),
+		zap.Int("memory_mb", limits.MemoryMB))
+
+	return group, nil
+}
+
+// Violations reads memory.events and cpu.stat for the instance's cgroup
+func (m *cgroupManager) Violations(instanceID string) (*domain.ResourceViolations, error) {
+	dir := m.path(instanceID)
+
+	memEvents, err := readCgroupKeyed(filepath.Join(dir, "memory.events"))
+	if err != nil {
+		return nil, err
+	}
+	cpuStat, err := readCgroupKeyed(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.ResourceViolations{
+		MemoryMaxEvents: memEvents["max"],
+		OOMKills:        memEvents["oom_kill"],
+		CPUThrottled:    cpuStat["nr_throttled"],
+		CheckedAt:       time.Now(),
+	}, nil
+}
+
+// Remove deletes the instance's cgroup; it must no longer contain processes
+func (m *cgroupManager) Remove(instanceID string) error {
+	if err := os.Remove(m.path(instanceID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (m *cgroupManager) path(instanceID string) string {
+	return filepath.Join(m.root, "instance-"+instanceID)
+}
+
+func writeCgroupFile(path, value string) error {
+	return os.WriteFile(path, []byte(value), 0644)
+}
+
+// readCgroupKeyed parses flat-keyed cgroup files ("key value" per line)
+func readCgroupKeyed(path string) (map[string]int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]int64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			values[fields[0]] = v
+		}
+	}
+
+	return values, scanner.Err()
+}
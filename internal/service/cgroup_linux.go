@@ -0,0 +1,21 @@
+//go:build linux
+
+package service
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// launchIntoCgroup makes cmd start directly inside the cgroup directory
+// group (clone3 with CLONE_INTO_CGROUP, Linux 5.7+), so no process of it
+// ever runs outside the group
+func launchIntoCgroup(cmd *exec.Cmd, group *os.File) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(group.Fd())
+	return nil
+}
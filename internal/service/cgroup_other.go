@@ -0,0 +1,15 @@
+//go:build !linux
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// launchIntoCgroup is only implemented on Linux, where cgroups exist
+func launchIntoCgroup(cmd *exec.Cmd, group *os.File) error {
+	return fmt.Errorf("cgroup resource limits are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// CleanupService runs the cleanup behind both the CLI cleanup command and
+// POST /admin/cleanup. The port and nginx managers are optional; without
+// them deleted instances keep their port and upstream entry.
+type CleanupService struct {
+	configDir    string
+	logger       *zap.Logger
+	planRepo     repository.PlanRepository
+	instanceRepo repository.InstanceRepository
+	events       *eventRecorder
+	proxyService ProxyService
+	portManager  *PortManager
+	nginxManager *NginxManager
+}
+
+// NewCleanupService creates a new cleanup service
+func NewCleanupService(
+	cfg *config.Config,
+	logger *zap.Logger,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	eventRepo repository.PlanEventRepository,
+	proxyService ProxyService,
+	portManager *PortManager,
+	nginxManager *NginxManager,
+) *CleanupService {
+	return &CleanupService{
+		configDir:    cfg.Proxy.ConfigDir,
+		logger:       logger,
+		planRepo:     planRepo,
+		instanceRepo: instanceRepo,
+		events:       newEventRecorder(eventRepo, logger),
+		proxyService: proxyService,
+		portManager:  portManager,
+		nginxManager: nginxManager,
+	}
+}
+
+// Run applies policy and reports what changed. Failures to restart or
+// delete single instances are reported rather than returned; an error means
+// the run stopped early, and the report covers what was done until then.
+func (c *CleanupService) Run(ctx context.Context, policy domain.CleanupPolicy, now time.Time) (*domain.CleanupReport, error) {
+	if policy.DeleteFailedAfterDays < 0 {
+		return nil, fmt.Errorf("delete_failed_after_days must not be negative")
+	}
+
+	report := &domain.CleanupReport{
+		Policy:             policy,
+		ExpiredPlans:       []uuid.UUID{},
+		StoppedInstances:   []uuid.UUID{},
+		RestartedInstances: []uuid.UUID{},
+		FailedRestarts:     []domain.CleanupFailure{},
+		DeletedInstances:   []uuid.UUID{},
+		FailedDeletes:      []domain.CleanupFailure{},
+		SkippedInstances:   []uuid.UUID{},
+		PurgedConfigs:      []string{},
+		ReleasedPorts:      []domain.ReleasedPort{},
+	}
+
+	if policy.ExpirePlans {
+		if err := c.expirePlans(ctx, report, now); err != nil {
+			return report, err
+		}
+	}
+	if policy.RestartFailed || policy.DeleteFailedAfterDays > 0 {
+		if err := c.failedInstances(ctx, policy, report, now); err != nil {
+			return report, err
+		}
+	}
+	if policy.PurgeOrphanConfigs {
+		if err := c.purgeOrphanConfigs(ctx, report); err != nil {
+			return report, err
+		}
+	}
+	if policy.ReleaseLeakedPorts && c.portManager != nil {
+		if err := c.releaseLeakedPorts(ctx, report); err != nil {
+			return report, err
+		}
+	}
+
+	c.logger.Info("Cleanup completed",
+		zap.Int("expired_plans", len(report.ExpiredPlans)),
+		zap.Int("stopped_instances", len(report.StoppedInstances)),
+		zap.Int("restarted_instances", len(report.RestartedInstances)),
+		zap.Int("deleted_instances", len(report.DeletedInstances)),
+		zap.Int("purged_configs", len(report.PurgedConfigs)),
+		zap.Int("released_ports", len(report.ReleasedPorts)),
+	)
+
+	return report, nil
+}
+
+// expirePlans marks plans past their expiry expired and stops their running
+// instances. Plans in grace keep serving until it ends, and teasers until
+// they are renewed.
+func (c *CleanupService) expirePlans(ctx context.Context, report *domain.CleanupReport, now time.Time) error {
+	plans, err := c.planRepo.GetExpired(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to get expired plans: %w", err)
+	}
+
+	for _, plan := range plans {
+		if plan.Status == domain.PlanStatusGrace && plan.GraceEndsAt != nil && now.Before(*plan.GraceEndsAt) {
+			continue
+		}
+		// Teasers were downgraded at expiry and are meant to keep serving
+		if plan.Status == domain.PlanStatusTeaser {
+			continue
+		}
+
+		previous := plan.Status
+		plan.Status = domain.PlanStatusExpired
+		plan.UpdatedAt = now
+		if err := c.planRepo.Update(ctx, plan); err != nil {
+			return fmt.Errorf("failed to expire plan %s: %w", plan.ID, err)
+		}
+		c.events.record(ctx, plan.ID, nil, domain.EventPlanExpired, "Plan expired (cleanup)", map[string]string{
+			"from": previous,
+			"to":   domain.PlanStatusExpired,
+		})
+		report.ExpiredPlans = append(report.ExpiredPlans, plan.ID)
+
+		instances, err := c.instanceRepo.GetByPlanID(ctx, plan.ID)
+		if err != nil {
+			c.logger.Error("Failed to get instances of expired plan", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+			continue
+		}
+		for _, instance := range instances {
+			if instance.Status != domain.InstanceStatusRunning {
+				continue
+			}
+			if err := c.proxyService.StopInstance(ctx, instance.ID); err != nil {
+				c.logger.Error("Failed to stop instance of expired plan",
+					zap.String("plan_id", plan.ID.String()),
+					zap.String("instance_id", instance.ID.String()),
+					zap.Error(err),
+				)
+				continue
+			}
+			report.StoppedInstances = append(report.StoppedInstances, instance.ID)
+		}
+	}
+
+	return nil
+}
+
+// failedInstances deletes instances failed for longer than the policy
+// allows and tries to restart the rest. Instances operators exempted from
+// autoheal are left alone.
+func (c *CleanupService) failedInstances(ctx context.Context, policy domain.CleanupPolicy, report *domain.CleanupReport, now time.Time) error {
+	instances, err := c.instanceRepo.GetByStatus(ctx, domain.InstanceStatusFailed)
+	if err != nil {
+		return fmt.Errorf("failed to get failed instances: %w", err)
+	}
+
+	deleteBefore := now.AddDate(0, 0, -policy.DeleteFailedAfterDays)
+	for _, instance := range instances {
+		if !instance.Autoheals() {
+			report.SkippedInstances = append(report.SkippedInstances, instance.ID)
+			continue
+		}
+
+		if policy.DeleteFailedAfterDays > 0 && !instance.UpdatedAt.After(deleteBefore) {
+			if err := c.deleteInstance(ctx, instance); err != nil {
+				report.FailedDeletes = append(report.FailedDeletes, domain.CleanupFailure{InstanceID: instance.ID, Error: err.Error()})
+				continue
+			}
+			report.DeletedInstances = append(report.DeletedInstances, instance.ID)
+			continue
+		}
+
+		if !policy.RestartFailed {
+			continue
+		}
+		if err := c.proxyService.RestartInstance(ctx, instance.ID); err != nil {
+			report.FailedRestarts = append(report.FailedRestarts, domain.CleanupFailure{InstanceID: instance.ID, Error: err.Error()})
+			continue
+		}
+		report.RestartedInstances = append(report.RestartedInstances, instance.ID)
+	}
+
+	return nil
+}
+
+// deleteInstance removes a failed instance with its port, upstream entry
+// and config file
+func (c *CleanupService) deleteInstance(ctx context.Context, instance *domain.ProxyInstance) error {
+	if err := c.instanceRepo.Delete(ctx, instance.ID); err != nil {
+		return fmt.Errorf("failed to delete instance: %w", err)
+	}
+
+	if c.portManager != nil {
+		if err := c.portManager.ReleasePort(ctx, instance.PlanTypeKey, instance.LocalPort); err != nil {
+			c.logger.Warn("Failed to release port of deleted instance",
+				zap.String("instance_id", instance.ID.String()),
+				zap.Int("port", instance.LocalPort),
+				zap.Error(err))
+		}
+		if err := c.portManager.ReleaseEgressIP(ctx, instance.PlanTypeKey, instance.EgressIP, instance.PlanID.String()); err != nil {
+			c.logger.Warn("Failed to release egress IP of deleted instance",
+				zap.String("instance_id", instance.ID.String()),
+				zap.String("egress_ip", instance.EgressIP),
+				zap.Error(err))
+		}
+	}
+	if c.nginxManager != nil {
+		if err := c.nginxManager.RemoveFromUpstream(ctx, instance); err != nil {
+			c.logger.Warn("Failed to remove deleted instance from nginx upstream",
+				zap.String("instance_id", instance.ID.String()),
+				zap.Int("port", instance.LocalPort),
+				zap.Error(err))
+		}
+	}
+	if err := os.Remove(proxyConfigPath(c.configDir, instance.ID.String())); err != nil && !os.IsNotExist(err) {
+		c.logger.Warn("Failed to remove config of deleted instance",
+			zap.String("instance_id", instance.ID.String()),
+			zap.Error(err))
+	}
+
+	c.events.record(ctx, instance.PlanID, &instance.ID, domain.EventInstanceDeleted, "Failed instance deleted (cleanup)", map[string]string{
+		"port":       fmt.Sprint(instance.LocalPort),
+		"last_error": instance.LastStartError,
+	})
+	return nil
+}
+
+// purgeOrphanConfigs removes 3proxy config files whose instance no longer
+// exists
+func (c *CleanupService) purgeOrphanConfigs(ctx context.Context, report *domain.CleanupReport) error {
+	instances, err := c.instanceRepo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get instances: %w", err)
+	}
+	known := make(map[uuid.UUID]bool, len(instances))
+	for _, instance := range instances {
+		known[instance.ID] = true
+	}
+
+	paths, err := filepath.Glob(proxyConfigPath(c.configDir, "*"))
+	if err != nil {
+		return fmt.Errorf("failed to list proxy configs: %w", err)
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "3proxy_"), ".cfg")
+		id, err := uuid.Parse(name)
+		if err != nil || known[id] {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			c.logger.Warn("Failed to remove orphan proxy config", zap.String("path", path), zap.Error(err))
+			continue
+		}
+		report.PurgedConfigs = append(report.PurgedConfigs, path)
+	}
+
+	return nil
+}
+
+// releaseLeakedPorts frees allocated ports no instance uses. Ports of plans
+// still creating are kept, as their instance may not be saved yet.
+func (c *CleanupService) releaseLeakedPorts(ctx context.Context, report *domain.CleanupReport) error {
+	instances, err := c.instanceRepo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get instances: %w", err)
+	}
+	plans, err := c.planRepo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get plans: %w", err)
+	}
+
+	used := make(map[string]map[int]bool)
+	for _, instance := range instances {
+		if used[instance.PlanTypeKey] == nil {
+			used[instance.PlanTypeKey] = make(map[int]bool)
+		}
+		used[instance.PlanTypeKey][instance.LocalPort] = true
+	}
+	creating := make(map[string]bool)
+	for _, plan := range plans {
+		if plan.Status == domain.PlanStatusCreating {
+			creating[plan.ID.String()] = true
+		}
+	}
+
+	allocations := c.portManager.Allocations()
+	keys := make([]string, 0, len(allocations))
+	for key := range allocations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		ports := make([]int, 0, len(allocations[key]))
+		for port := range allocations[key] {
+			ports = append(ports, port)
+		}
+		sort.Ints(ports)
+
+		for _, port := range ports {
+			planID := allocations[key][port]
+			if used[key][port] || creating[planID] {
+				continue
+			}
+			if err := c.portManager.ReleasePort(ctx, key, port); err != nil {
+				continue
+			}
+			report.ReleasedPorts = append(report.ReleasedPorts, domain.ReleasedPort{PlanTypeKey: key, Port: port, PlanID: planID})
+		}
+	}
+
+	return nil
+}
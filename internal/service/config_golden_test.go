@@ -0,0 +1,365 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"github.com/je265/oceanproxy/configs"
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// Config generation is checked against the files in testdata/golden. After
+// an intended change to the templates or the code filling them in, rewrite
+// them with
+//
+//	go test ./internal/service -run Golden -update
+//
+// and review the diff. With -validate the generated configs are also checked
+// by the real binaries, 3proxy --test and nginx -t, when they are installed.
+var (
+	updateGolden   = flag.Bool("update", false, "rewrite the golden files")
+	validateGolden = flag.Bool("validate", false, "check generated configs with 3proxy --test and nginx -t")
+)
+
+// goldenTime is when golden configs are generated
+var goldenTime = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// goldenValidateTimeout bounds each validation command
+const goldenValidateTimeout = 10 * time.Second
+
+// threeProxyGoldenCase is one instance config to generate
+type threeProxyGoldenCase struct {
+	name     string
+	planType string
+	// settings replaces the plan type's 3proxy settings when set
+	settings    *domain.ProxySettings
+	connections *domain.ConnectionPolicy
+	instance    func(*domain.ProxyInstance)
+	plan        func(*domain.ProxyPlan)
+	banned      []string
+}
+
+func TestGolden3ProxyConfigs(t *testing.T) {
+	planTypes := goldenPlanTypes(t)
+	tmpl := load3ProxyTemplate(t.TempDir(), zap.NewNop())
+
+	keys := make([]string, 0, len(planTypes))
+	for key := range planTypes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var cases []threeProxyGoldenCase
+	for _, key := range keys {
+		cases = append(cases, threeProxyGoldenCase{name: "plan_type_" + key, planType: key})
+	}
+
+	const base = "proxies_fo_usa_residential"
+	socks := &domain.ProxySettings{
+		MaxConn:    200,
+		BandLimIn:  10000000,
+		BandLimOut: 10000000,
+		NSCache:    65536,
+		NSCache6:   65536,
+		NServers:   []string{"1.1.1.1", "8.8.8.8"},
+		Rules: []domain.ACLRule{
+			{Action: "deny", Targets: []string{"10.0.0.0/8", "192.168.0.0/16"}},
+			{Action: "allow", Ports: []string{"80", "443"}},
+		},
+		SOCKS: &domain.SOCKSBlock{Enabled: true, PortOffset: 50000},
+	}
+	cases = append(cases,
+		threeProxyGoldenCase{name: "socks", planType: base, settings: socks},
+		threeProxyGoldenCase{name: "socks_disabled", planType: base, settings: &domain.ProxySettings{
+			SOCKS: &domain.SOCKSBlock{Enabled: false, PortOffset: 50000},
+		}},
+		threeProxyGoldenCase{name: "bind_address", planType: base, instance: func(i *domain.ProxyInstance) {
+			i.LocalHost = "10.0.0.5"
+		}},
+		threeProxyGoldenCase{name: "egress", planType: base, instance: func(i *domain.ProxyInstance) {
+			i.EgressIP = "203.0.113.10"
+		}},
+		threeProxyGoldenCase{name: "egress_socks_rules", planType: base, settings: socks, instance: func(i *domain.ProxyInstance) {
+			i.EgressIP = "203.0.113.10"
+		}},
+		threeProxyGoldenCase{name: "extra_users", planType: base, plan: func(p *domain.ProxyPlan) {
+			p.TempCredentials = []domain.TempCredential{
+				{Username: "temp_active", Password: "temp-pass", ExpiresAt: goldenTime.Add(time.Hour)},
+				{Username: "temp_expired", Password: "temp-pass", ExpiresAt: goldenTime.Add(-time.Hour)},
+			}
+			p.SubUsers = []domain.SubUser{
+				{Username: "sub_enabled", Password: "sub-pass"},
+				{Username: "sub_disabled", Password: "sub-pass", Disabled: true},
+				{Username: "sub_exhausted", Password: "sub-pass", Exhausted: true},
+			}
+		}},
+		threeProxyGoldenCase{name: "extra_users_rules", planType: base, settings: socks, plan: func(p *domain.ProxyPlan) {
+			p.SubUsers = []domain.SubUser{{Username: "sub_enabled", Password: "sub-pass"}}
+		}},
+		threeProxyGoldenCase{name: "banned", planType: base, settings: socks, banned: []string{"198.51.100.7", "198.51.100.8"}},
+		threeProxyGoldenCase{name: "idle_timeout", planType: base, connections: &domain.ConnectionPolicy{IdleTimeout: 10 * time.Minute}},
+		threeProxyGoldenCase{name: "allowed_destinations", planType: base, settings: socks, plan: func(p *domain.ProxyPlan) {
+			p.AllowedDestinations = []string{"example.com", "*.example.org"}
+		}},
+		threeProxyGoldenCase{name: "status_grace", planType: base, plan: func(p *domain.ProxyPlan) {
+			p.Status = domain.PlanStatusGrace
+		}},
+		threeProxyGoldenCase{name: "status_teaser", planType: base, plan: func(p *domain.ProxyPlan) {
+			p.Status = domain.PlanStatusTeaser
+		}},
+		threeProxyGoldenCase{name: "status_exhausted", planType: base, plan: func(p *domain.ProxyPlan) {
+			p.Status = domain.PlanStatusExhausted
+		}},
+		threeProxyGoldenCase{name: "status_suspended", planType: base, settings: socks, plan: func(p *domain.ProxyPlan) {
+			p.Status = domain.PlanStatusSuspended
+		}},
+	)
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			planType := *planTypes[tc.planType]
+			if tc.settings != nil {
+				planType.Proxy = tc.settings
+			}
+			if tc.connections != nil {
+				planType.Connections = tc.connections
+			}
+			s := goldenProxyService(map[string]*domain.PlanTypeConfig{tc.planType: &planType})
+
+			instance := &domain.ProxyInstance{
+				ID:          goldenUUID(tc.name + "/instance"),
+				PlanID:      goldenUUID(tc.name + "/plan"),
+				PlanTypeKey: tc.planType,
+				LocalPort:   planType.LocalPortRange.Start,
+				AuthHost:    planType.UpstreamHost,
+				AuthPort:    planType.UpstreamPort,
+			}
+			if tc.instance != nil {
+				tc.instance(instance)
+			}
+			plan := &domain.ProxyPlan{
+				ID:       instance.PlanID,
+				Username: "oceanuser",
+				Password: "oceanpass",
+				Status:   domain.PlanStatusActive,
+			}
+			if tc.plan != nil {
+				tc.plan(plan)
+			}
+
+			ports := []int{instance.LocalPort}
+			if planType.Proxy != nil && planType.Proxy.SOCKS != nil && planType.Proxy.SOCKS.Enabled {
+				ports = append(ports, instance.LocalPort+planType.Proxy.SOCKS.PortOffset)
+			}
+			for _, ip := range tc.banned {
+				s.bans.add(ip, ports[len(ports)-1])
+			}
+
+			data, err := s.threeProxyTemplateData(instance, plan, goldenTime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data.GeneratedAt = goldenTime.Format(time.RFC3339)
+			content, err := render3ProxyConfig(tmpl, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := checkGolden(t, filepath.Join("3proxy", tc.name+".cfg"), content)
+			if *validateGolden {
+				validate3ProxyConfig(t, path)
+			}
+		})
+	}
+}
+
+func TestGoldenNginxRegionConfigs(t *testing.T) {
+	planTypes := goldenPlanTypes(t)
+	regions := goldenRegions(t)
+	tmpl, err := template.ParseFiles(filepath.Join("..", "..", "scripts", "nginx", "templates", "stream.conf.tmpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A region relaying several plan types, some with idle timeouts
+	regions["multi"] = &domain.Region{
+		Name:            "multi",
+		Subdomain:       "multi",
+		DomainSuffix:    "oceanproxy.io",
+		OutboundPort:    1400,
+		Description:     "Several plan types",
+		PlanTypes:       []string{"proxies_fo_usa_residential", "proxies_fo_usa_datacenter", "missing_plan_type"},
+		NginxConfigFile: "oceanproxy_multi.conf",
+	}
+	residential := *planTypes["proxies_fo_usa_residential"]
+	residential.Connections = &domain.ConnectionPolicy{IdleTimeout: 10 * time.Minute}
+	datacenter := *planTypes["proxies_fo_usa_datacenter"]
+	datacenter.Connections = &domain.ConnectionPolicy{IdleTimeout: 30 * time.Minute}
+	planTypes["proxies_fo_usa_residential"] = &residential
+	planTypes["proxies_fo_usa_datacenter"] = &datacenter
+
+	// A region without plan types has no upstream to pass to
+	regions["empty"] = &domain.Region{
+		Name:            "empty",
+		Subdomain:       "empty",
+		DomainSuffix:    "oceanproxy.io",
+		OutboundPort:    1401,
+		NginxConfigFile: "oceanproxy_empty.conf",
+	}
+
+	names := make([]string, 0, len(regions))
+	for name := range regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nm := &NginxManager{logger: zap.NewNop(), planTypes: NewPlanTypeRegistry(planTypes)}
+	for _, name := range names {
+		region := regions[name]
+		t.Run(name, func(t *testing.T) {
+			content, err := renderRegionConfig(tmpl, nm.regionTemplateData(region))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := checkGolden(t, filepath.Join("nginx", region.Name+".conf"), content)
+			if *validateGolden && len(region.PlanTypes) > 0 {
+				validateNginxConfig(t, path)
+			}
+		})
+	}
+}
+
+// goldenProxyService returns a proxy service that can fill in instance
+// configs for planTypes, without repositories or processes
+func goldenProxyService(planTypes map[string]*domain.PlanTypeConfig) *proxyService {
+	cfg := &config.Config{}
+	cfg.Proxy.LogDir = "/var/log/oceanproxy"
+	cfg.Billing.GraceThrottle = 2000000
+	cfg.Billing.TeaserThrottle = 1000000
+	cfg.Billing.TeaserPage.Listen = "127.0.0.1:8089"
+
+	return &proxyService{
+		cfg:       cfg,
+		logger:    zap.NewNop(),
+		planTypes: NewPlanTypeRegistry(planTypes),
+		bans:      NewBanList(),
+	}
+}
+
+// goldenPlanTypes returns the sample plan types
+func goldenPlanTypes(t *testing.T) map[string]*domain.PlanTypeConfig {
+	t.Helper()
+	var file struct {
+		PlanTypes map[string]*domain.PlanTypeConfig `yaml:"plan_types"`
+	}
+	if err := yaml.Unmarshal(configs.ProxyPlans, &file); err != nil {
+		t.Fatalf("failed to parse sample plan types: %v", err)
+	}
+	return file.PlanTypes
+}
+
+// goldenRegions returns the sample regions
+func goldenRegions(t *testing.T) map[string]*domain.Region {
+	t.Helper()
+	var file struct {
+		Regions map[string]*domain.Region `yaml:"regions"`
+	}
+	if err := yaml.Unmarshal(configs.Regions, &file); err != nil {
+		t.Fatalf("failed to parse sample regions: %v", err)
+	}
+	for name, region := range file.Regions {
+		if region.Name == "" {
+			region.Name = name
+		}
+	}
+	return file.Regions
+}
+
+// goldenUUID derives a stable ID from name
+func goldenUUID(name string) uuid.UUID {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(name))
+}
+
+// checkGolden compares content with testdata/golden/name, or rewrites it
+// with -update, and returns the file's path
+func checkGolden(t *testing.T, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name)
+
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(content, want) {
+		t.Errorf("%s differs from the generated config (run with -update and review the diff):\n--- got\n%s\n--- want\n%s", path, content, want)
+	}
+	return path
+}
+
+// validate3ProxyConfig checks a generated instance config with 3proxy --test
+func validate3ProxyConfig(t *testing.T, path string) {
+	t.Helper()
+	binary := lookPathOrSkip(t, "3proxy")
+	if _, err := runCommand(context.Background(), goldenValidateTimeout, binary, "--test", path); err != nil {
+		t.Errorf("3proxy rejected %s: %v", path, err)
+	}
+}
+
+// validateNginxConfig checks a generated region config with nginx -t, with
+// the region included in the stream block of an otherwise empty nginx.conf
+func validateNginxConfig(t *testing.T, path string) {
+	t.Helper()
+	binary := lookPathOrSkip(t, "nginx")
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := t.TempDir()
+	conf := filepath.Join(prefix, "nginx.conf")
+	nginxConf := fmt.Sprintf("pid %s;\nerror_log %s;\nevents {}\nstream {\n    include %s;\n}\n",
+		filepath.Join(prefix, "nginx.pid"), filepath.Join(prefix, "error.log"), absPath)
+	if err := os.WriteFile(conf, []byte(nginxConf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runCommand(context.Background(), goldenValidateTimeout, binary, "-t", "-p", prefix, "-c", conf); err != nil {
+		t.Errorf("nginx rejected %s: %v", path, err)
+	}
+}
+
+// lookPathOrSkip returns the path of a binary, skipping the test when it is
+// not installed
+func lookPathOrSkip(t *testing.T, name string) string {
+	t.Helper()
+	path, err := exec.LookPath(name)
+	if err != nil {
+		t.Skipf("%s is not installed; not validating", name)
+	}
+	return path
+}
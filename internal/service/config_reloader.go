@@ -0,0 +1,136 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// ConfigReloader re-reads the configuration while the server runs and hands
+// the settings that can change at runtime to the components using them.
+// Everything else, including proxy instances, is left as it is.
+type ConfigReloader struct {
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	startup  *config.Config
+	current  *config.Config
+	appliers []configApplier
+	last     *domain.ConfigReload
+}
+
+// configApplier applies the keys it registered for, given the new config
+type configApplier struct {
+	keys  []string
+	apply func(next *config.Config)
+}
+
+// NewConfigReloader creates a reloader for the configuration the server
+// started with
+func NewConfigReloader(cfg *config.Config, logger *zap.Logger) *ConfigReloader {
+	return &ConfigReloader{
+		logger:  logger,
+		startup: cfg,
+		current: cfg,
+	}
+}
+
+// Register makes keys reloadable. apply is called with the new configuration
+// when any of them, or any key under them, changes.
+func (r *ConfigReloader) Register(apply func(next *config.Config), keys ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.appliers = append(r.appliers, configApplier{keys: keys, apply: apply})
+}
+
+// Reload loads the configuration and applies the reloadable changes since
+// the previous reload. Other keys that differ from the startup configuration
+// are reported as requiring a restart. A configuration that fails to load
+// changes nothing.
+func (r *ConfigReloader) Reload(trigger string, load func() (*config.Config, error)) *domain.ConfigReload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reload := &domain.ConfigReload{
+		ReloadedAt:      time.Now(),
+		Trigger:         trigger,
+		Applied:         []domain.ConfigChange{},
+		RestartRequired: []domain.ConfigChange{},
+	}
+	r.last = reload
+
+	next, err := load()
+	if err != nil {
+		reload.Error = err.Error()
+		r.logger.Error("Configuration reload failed, keeping the running configuration", zap.Error(err))
+		return reload
+	}
+
+	changes := config.Diff(r.current, next)
+	for _, applier := range r.appliers {
+		changed := false
+		for _, change := range changes {
+			if applier.covers(change.Key) {
+				reload.Applied = append(reload.Applied, domain.ConfigChange(change))
+				changed = true
+			}
+		}
+		if changed {
+			applier.apply(next)
+		}
+	}
+
+	for _, change := range config.Diff(r.startup, next) {
+		if !r.reloadable(change.Key) {
+			reload.RestartRequired = append(reload.RestartRequired, domain.ConfigChange(change))
+		}
+	}
+	r.current = next
+
+	applied := make([]string, len(reload.Applied))
+	for i, change := range reload.Applied {
+		applied[i] = change.Key
+	}
+	pending := make([]string, len(reload.RestartRequired))
+	for i, change := range reload.RestartRequired {
+		pending[i] = change.Key
+	}
+	r.logger.Info("Configuration reloaded",
+		zap.String("trigger", trigger),
+		zap.Strings("applied", applied),
+		zap.Strings("restart_required", pending))
+
+	return reload
+}
+
+// LastReload returns the summary of the most recent reload, or nil
+func (r *ConfigReloader) LastReload() *domain.ConfigReload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.last
+}
+
+func (r *ConfigReloader) reloadable(key string) bool {
+	for _, applier := range r.appliers {
+		if applier.covers(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a configApplier) covers(key string) bool {
+	for _, prefix := range a.keys {
+		if key == prefix || strings.HasPrefix(key, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// CrashLoopDetector alerts operators when an instance keeps going down,
+// failing to start or being marked unhealthy notifications.crash_loop_threshold
+// times within notifications.crash_loop_window. Each alert starts the count
+// over, so a loop that goes on is alerted again once per threshold. Loops
+// during scheduled maintenance of the instance's provider are not alerted.
+type CrashLoopDetector struct {
+	threshold   int
+	window      time.Duration
+	notifier    Notifier
+	maintenance *MaintenanceService
+	logger      *zap.Logger
+
+	mu    sync.Mutex
+	downs map[uuid.UUID][]time.Time
+}
+
+// NewCrashLoopDetector creates a detector; it never alerts when the
+// threshold is 0
+func NewCrashLoopDetector(cfg *config.Config, logger *zap.Logger, notifier Notifier, maintenance *MaintenanceService) *CrashLoopDetector {
+	return &CrashLoopDetector{
+		threshold:   cfg.Notifications.CrashLoopThreshold,
+		window:      cfg.Notifications.CrashLoopWindow,
+		notifier:    notifier,
+		maintenance: maintenance,
+		logger:      logger,
+		downs:       make(map[uuid.UUID][]time.Time),
+	}
+}
+
+// InstanceDown records that instance went down for reason at now
+func (d *CrashLoopDetector) InstanceDown(ctx context.Context, instance *domain.ProxyInstance, reason string, now time.Time) {
+	if d == nil || d.threshold <= 0 {
+		return
+	}
+
+	d.mu.Lock()
+	cutoff := now.Add(-d.window)
+	for id, downs := range d.downs {
+		if !downs[len(downs)-1].After(cutoff) {
+			delete(d.downs, id)
+		}
+	}
+
+	var downs []time.Time
+	for _, at := range d.downs[instance.ID] {
+		if at.After(cutoff) {
+			downs = append(downs, at)
+		}
+	}
+	downs = append(downs, now)
+	looping := len(downs) >= d.threshold
+	if looping {
+		delete(d.downs, instance.ID)
+	} else {
+		d.downs[instance.ID] = downs
+	}
+	d.mu.Unlock()
+
+	if !looping {
+		return
+	}
+
+	if window := d.maintenance.Active(ctx, instance.PlanTypeKey, now); window != nil {
+		d.logger.Info("Proxy instance is crash looping during provider maintenance",
+			zap.String("instance_id", instance.ID.String()),
+			zap.String("maintenance_id", window.ID.String()),
+			zap.Int("downs", len(downs)))
+		return
+	}
+
+	d.logger.Warn("Proxy instance is crash looping",
+		zap.String("instance_id", instance.ID.String()),
+		zap.String("plan_id", instance.PlanID.String()),
+		zap.Int("downs", len(downs)),
+		zap.Duration("window", d.window),
+		zap.String("reason", reason))
+
+	notification := &domain.Notification{
+		Type:    domain.NotificationInstanceCrashLoop,
+		PlanID:  instance.PlanID.String(),
+		Message: fmt.Sprintf("A proxy instance went down %d times within %s.", len(downs), d.window),
+		Data: map[string]string{
+			"instance_id":   instance.ID.String(),
+			"plan_type_key": instance.PlanTypeKey,
+			"port":          fmt.Sprint(instance.LocalPort),
+			"last_error":    reason,
+		},
+		CreatedAt: now,
+	}
+	if err := d.notifier.Notify(ctx, notification); err != nil {
+		d.logger.Error("Failed to send crash loop notification",
+			zap.String("instance_id", instance.ID.String()),
+			zap.Error(err))
+	}
+}
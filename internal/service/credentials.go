@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+const (
+	credentialAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	// usernameAttempts bounds retries when a generated username is taken
+	usernameAttempts = 5
+)
+
+// credentialPolicy validates and generates credentials for providers that
+// require caller-chosen ones, following the usernames and passwords config
+type credentialPolicy struct {
+	usernames config.Usernames
+	passwords config.Passwords
+	charset   string
+}
+
+func newCredentialPolicy(cfg *config.Config) *credentialPolicy {
+	return &credentialPolicy{
+		usernames: cfg.Usernames,
+		passwords: cfg.Passwords,
+		charset:   config.UsernameCharsets[cfg.Usernames.Charset],
+	}
+}
+
+// GenerateUsername returns a random username satisfying the policy
+func (p *credentialPolicy) GenerateUsername() (string, error) {
+	suffix, err := randomString(p.charset, p.usernames.Length)
+	if err != nil {
+		return "", err
+	}
+	return p.usernames.Prefix + suffix, nil
+}
+
+// ValidateUsername checks a requested username against the policy
+func (p *credentialPolicy) ValidateUsername(planTypeKey, username string) error {
+	reject := func(reason string) error {
+		return &domain.PolicyError{PlanType: planTypeKey, Field: "username", Reason: reason}
+	}
+
+	if len(username) < p.usernames.MinLength || len(username) > p.usernames.MaxLength {
+		return reject(fmt.Sprintf("must be %d to %d characters", p.usernames.MinLength, p.usernames.MaxLength))
+	}
+	for _, r := range username {
+		if !strings.ContainsRune(p.charset, r) {
+			return reject(fmt.Sprintf("may only contain %s characters", p.usernames.Charset))
+		}
+	}
+	if p.usernames.RequirePrefix && !strings.HasPrefix(username, p.usernames.Prefix) {
+		return reject(fmt.Sprintf("must start with %q", p.usernames.Prefix))
+	}
+	return nil
+}
+
+// GeneratePassword returns a random alphanumeric password
+func (p *credentialPolicy) GeneratePassword() (string, error) {
+	return randomString(credentialAlphabet, p.passwords.Length)
+}
+
+// ValidatePassword checks a requested password against the strength policy.
+// Passwords end up in 3proxy configs, so whitespace, control characters,
+// colons and the characters 3proxy's parser treats as quoting, escapes or
+// file inclusion (", \ and $) are never allowed.
+func (p *credentialPolicy) ValidatePassword(planTypeKey, password string) error {
+	reject := func(reason string) error {
+		return &domain.PolicyError{PlanType: planTypeKey, Field: "password", Reason: reason}
+	}
+
+	if len(password) < p.passwords.MinLength {
+		return reject(fmt.Sprintf("must be at least %d characters", p.passwords.MinLength))
+	}
+	for _, r := range password {
+		if strings.ContainsRune(`:"\$`, r) || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return reject(`may not contain whitespace, control characters or any of : " \ $`)
+		}
+	}
+	if bits := passwordEntropy(password); bits < p.passwords.MinEntropy {
+		return reject(fmt.Sprintf("is too guessable (about %.0f bits of entropy, %.0f required)", bits, p.passwords.MinEntropy))
+	}
+	return nil
+}
+
+// passwordEntropy estimates the entropy of a password in bits: log2 of the
+// size of the character classes it draws from, per distinct character, so
+// repeated characters add nothing
+func passwordEntropy(password string) float64 {
+	var lower, upper, digit, other bool
+	distinct := make(map[rune]bool)
+	for _, r := range password {
+		distinct[r] = true
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if other {
+		pool += 32
+	}
+	if pool == 0 {
+		return 0
+	}
+	return float64(len(distinct)) * math.Log2(float64(pool))
+}
+
+// assignCredentials fills in the username and password of a request for a
+// provider that takes caller-chosen credentials. Requested usernames must
+// satisfy the policy and be free; omitted ones are generated until one is.
+// Requested passwords must be strong enough; omitted ones are generated.
+func (p *credentialPolicy) assignCredentials(ctx context.Context, planRepo repository.PlanRepository, planTypeKey string, req *domain.CreatePlanRequest) error {
+	if req.Username != "" {
+		if err := p.ValidateUsername(planTypeKey, req.Username); err != nil {
+			return err
+		}
+		taken, err := usernameTaken(ctx, planRepo, req.Username)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return fmt.Errorf("%w: %s", domain.ErrDuplicateUsername, req.Username)
+		}
+	} else {
+		for attempt := 1; ; attempt++ {
+			username, err := p.GenerateUsername()
+			if err != nil {
+				return err
+			}
+			taken, err := usernameTaken(ctx, planRepo, username)
+			if err != nil {
+				return err
+			}
+			if !taken {
+				req.Username = username
+				break
+			}
+			if attempt == usernameAttempts {
+				return fmt.Errorf("failed to generate a free username after %d attempts", usernameAttempts)
+			}
+		}
+	}
+
+	if req.Password != "" {
+		return p.ValidatePassword(planTypeKey, req.Password)
+	}
+	password, err := p.GeneratePassword()
+	if err != nil {
+		return err
+	}
+	req.Password = password
+	return nil
+}
+
+// usernameTaken reports whether any plan already uses username
+func usernameTaken(ctx context.Context, planRepo repository.PlanRepository, username string) (bool, error) {
+	plans, err := planRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up username: %w", err)
+	}
+	return len(plans) > 0, nil
+}
+
+func randomString(alphabet string, n int) (string, error) {
+	max := big.NewInt(int64(len(alphabet)))
+	b := make([]byte, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate random credential: %w", err)
+		}
+		b[i] = alphabet[idx.Int64()]
+	}
+	return string(b), nil
+}
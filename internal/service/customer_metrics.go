@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// ErrInvalidMetricsToken is returned for malformed or forged customer metrics tokens
+var ErrInvalidMetricsToken = errors.New("invalid metrics token")
+
+// CustomerMetrics renders one customer's plan usage in Prometheus/OpenMetrics
+// text format. Customers are identified by signed tokens, so no token state
+// is stored; rotating metrics.token_secret revokes every issued token.
+type CustomerMetrics struct {
+	cfg          config.Metrics
+	logger       *zap.Logger
+	planRepo     repository.PlanRepository
+	instanceRepo repository.InstanceRepository
+	accountRepo  repository.ProviderAccountRepository
+	statsRepo    repository.StatsRepository
+}
+
+// NewCustomerMetrics creates a new customer metrics exporter
+func NewCustomerMetrics(
+	cfg *config.Config,
+	logger *zap.Logger,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	accountRepo repository.ProviderAccountRepository,
+	statsRepo repository.StatsRepository,
+) *CustomerMetrics {
+	return &CustomerMetrics{
+		cfg:          cfg.Metrics,
+		logger:       logger,
+		planRepo:     planRepo,
+		instanceRepo: instanceRepo,
+		accountRepo:  accountRepo,
+		statsRepo:    statsRepo,
+	}
+}
+
+// Enabled reports whether the customer metrics endpoint is configured
+func (m *CustomerMetrics) Enabled() bool {
+	return m.cfg.CustomerEndpoint && m.cfg.TokenSecret != ""
+}
+
+// IssueToken returns the metrics token for a customer
+func (m *CustomerMetrics) IssueToken(customerID string) (string, error) {
+	if !m.Enabled() {
+		return "", fmt.Errorf("customer metrics are disabled")
+	}
+	if customerID == "" {
+		return "", fmt.Errorf("customer ID is required")
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(customerID))
+	return encoded + "." + m.sign(customerID), nil
+}
+
+// CustomerForToken verifies a token and returns the customer it was issued for
+func (m *CustomerMetrics) CustomerForToken(token string) (string, error) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		return "", ErrInvalidMetricsToken
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(raw) == 0 {
+		return "", ErrInvalidMetricsToken
+	}
+
+	customerID := string(raw)
+	if !hmac.Equal([]byte(signature), []byte(m.sign(customerID))) {
+		return "", ErrInvalidMetricsToken
+	}
+
+	return customerID, nil
+}
+
+func (m *CustomerMetrics) sign(customerID string) string {
+	mac := hmac.New(sha256.New, []byte(m.cfg.TokenSecret))
+	mac.Write([]byte("customer-metrics:" + customerID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Render writes the customer's plan metrics. With openMetrics the output is
+// terminated with "# EOF" as the OpenMetrics format requires.
+func (m *CustomerMetrics) Render(ctx context.Context, customerID string, w io.Writer, openMetrics bool) error {
+	plans, err := m.planRepo.GetByCustomerID(ctx, customerID)
+	if err != nil {
+		return fmt.Errorf("failed to load plans: %w", err)
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].CreatedAt.Before(plans[j].CreatedAt) })
+
+	var info, bandwidth, expiry, running, upstreamUsed, upstreamLimit []string
+	var clientCountries, destinationCountries []string
+	for _, plan := range plans {
+		id := escapeLabel(plan.ID.String())
+
+		info = append(info, fmt.Sprintf(`oceanproxy_plan_info{plan_id="%s",provider="%s",plan_type="%s",region="%s",status="%s"} 1`,
+			id, escapeLabel(plan.Provider), escapeLabel(plan.PlanType), escapeLabel(plan.Region), escapeLabel(plan.Status)))
+		bandwidth = append(bandwidth, fmt.Sprintf(`oceanproxy_plan_bandwidth_limit_gigabytes{plan_id="%s"} %d`, id, plan.Bandwidth))
+		expiry = append(expiry, fmt.Sprintf(`oceanproxy_plan_expiry_timestamp_seconds{plan_id="%s"} %d`, id, plan.ExpiresAt.Unix()))
+
+		instances, err := m.instanceRepo.GetByPlanID(ctx, plan.ID)
+		if err != nil {
+			m.logger.Debug("Failed to load instances for metrics", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+		}
+		count := 0
+		for _, instance := range instances {
+			if instance.Status == domain.InstanceStatusRunning {
+				count++
+			}
+		}
+		running = append(running, fmt.Sprintf(`oceanproxy_plan_instances_running{plan_id="%s"} %d`, id, count))
+
+		// Country breakdowns are totals since the plan was created, read from
+		// daily rollups and the finer data not rolled up yet
+		stats, err := m.statsRepo.GetPlanStats(ctx, plan.ID, plan.CreatedAt, time.Now(), repository.StatsResolutionDaily)
+		if err != nil {
+			m.logger.Debug("Failed to load plan stats for metrics", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+		} else {
+			clientCountries = append(clientCountries, countrySamples("oceanproxy_plan_client_country_bytes_total", id, stats.ClientCountries)...)
+			destinationCountries = append(destinationCountries, countrySamples("oceanproxy_plan_destination_country_bytes_total", id, stats.DestinationCountries)...)
+		}
+
+		// Usage is tracked per upstream account, which reused accounts share between plans
+		account, err := m.accountRepo.GetByPlanID(ctx, plan.ID)
+		if err != nil || account.UsageSyncedAt == nil {
+			continue
+		}
+		accountID := escapeLabel(account.ID.String())
+		upstreamUsed = append(upstreamUsed, fmt.Sprintf(`oceanproxy_plan_upstream_used_bytes{plan_id="%s",account_id="%s"} %d`, id, accountID, account.UsedBytes))
+		if account.MaxBytes > 0 {
+			upstreamLimit = append(upstreamLimit, fmt.Sprintf(`oceanproxy_plan_upstream_limit_bytes{plan_id="%s",account_id="%s"} %d`, id, accountID, account.MaxBytes))
+		}
+	}
+
+	var b strings.Builder
+	writeMetricFamily(&b, "oceanproxy_plan_info", "Plan metadata; always 1", info)
+	writeMetricFamily(&b, "oceanproxy_plan_bandwidth_limit_gigabytes", "Bandwidth purchased for the plan in GB", bandwidth)
+	writeMetricFamily(&b, "oceanproxy_plan_expiry_timestamp_seconds", "Plan expiry as a Unix timestamp", expiry)
+	writeMetricFamily(&b, "oceanproxy_plan_instances_running", "Running proxy instances serving the plan", running)
+	writeMetricFamily(&b, "oceanproxy_plan_upstream_used_bytes", "Bytes used on the plan's upstream account at the last usage sync", upstreamUsed)
+	writeMetricFamily(&b, "oceanproxy_plan_upstream_limit_bytes", "Byte allowance of the plan's upstream account", upstreamLimit)
+	writeCounterFamily(&b, "oceanproxy_plan_client_country_bytes", "Bytes proxied for clients in each country; ZZ is unknown", clientCountries, openMetrics)
+	writeCounterFamily(&b, "oceanproxy_plan_destination_country_bytes", "Bytes proxied to destinations in each country; ZZ is unknown", destinationCountries, openMetrics)
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// writeMetricFamily writes a gauge family; families without samples are skipped
+func writeMetricFamily(b *strings.Builder, name, help string, samples []string) {
+	if len(samples) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	for _, sample := range samples {
+		b.WriteString(sample)
+		b.WriteByte('\n')
+	}
+}
+
+// writeCounterFamily writes a counter family whose samples are named
+// <name>_total. OpenMetrics declares counters without the suffix.
+func writeCounterFamily(b *strings.Builder, name, help string, samples []string, openMetrics bool) {
+	if len(samples) == 0 {
+		return
+	}
+	if !openMetrics {
+		name += "_total"
+	}
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	for _, sample := range samples {
+		b.WriteString(sample)
+		b.WriteByte('\n')
+	}
+}
+
+// writeHistogramFamily writes a histogram family whose samples are named
+// <name>_bucket, <name>_sum and <name>_count
+func writeHistogramFamily(b *strings.Builder, name, help string, samples []string) {
+	if len(samples) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for _, sample := range samples {
+		b.WriteString(sample)
+		b.WriteByte('\n')
+	}
+}
+
+// countrySamples returns a plan's per-country byte totals, sorted by country
+func countrySamples(metric, planID string, countries map[string]repository.CountryTraffic) []string {
+	codes := make([]string, 0, len(countries))
+	for code := range countries {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	samples := make([]string, 0, len(codes))
+	for _, code := range codes {
+		traffic := countries[code]
+		samples = append(samples, fmt.Sprintf(`%s{plan_id="%s",country="%s"} %d`,
+			metric, planID, escapeLabel(code), traffic.BytesIn+traffic.BytesOut))
+	}
+	return samples
+}
+
+// escapeLabel escapes a Prometheus label value
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
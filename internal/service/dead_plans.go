@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// requestTally counts the requests of a plan logged in one minute
+type requestTally struct {
+	minute   time.Time
+	requests int64
+	failed   int64
+}
+
+// DeadPlanDetector finds plans customers believe work but do not, by
+// comparing what the edge sees with what the upstream does: requests
+// logged by the plan's instances against how many failed upstream, traffic
+// recorded in the stats against the usage the provider reports, and the
+// health monitor's connection tests. Request outcomes are tallied in
+// memory, so after a restart the window fills up again.
+type DeadPlanDetector struct {
+	cfg          config.DeadPlans
+	logDir       string
+	logger       *zap.Logger
+	planRepo     repository.PlanRepository
+	instanceRepo repository.InstanceRepository
+	accountRepo  repository.ProviderAccountRepository
+	eventRepo    repository.PlanEventRepository
+	stats        *StatsService
+
+	mu      sync.Mutex
+	tailer  *logTailer
+	primed  bool
+	tallies map[uuid.UUID][]requestTally
+}
+
+// NewDeadPlanDetector creates a new dead plan detector
+func NewDeadPlanDetector(
+	cfg *config.Config,
+	logger *zap.Logger,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	accountRepo repository.ProviderAccountRepository,
+	eventRepo repository.PlanEventRepository,
+	stats *StatsService,
+) *DeadPlanDetector {
+	return &DeadPlanDetector{
+		cfg:          cfg.DeadPlans,
+		logDir:       cfg.Proxy.LogDir,
+		logger:       logger,
+		planRepo:     planRepo,
+		instanceRepo: instanceRepo,
+		accountRepo:  accountRepo,
+		eventRepo:    eventRepo,
+		stats:        stats,
+		tailer:       newLogTailer(),
+		tallies:      make(map[uuid.UUID][]requestTally),
+	}
+}
+
+// Window returns the period findings are based on
+func (d *DeadPlanDetector) Window() time.Duration {
+	return d.cfg.Window
+}
+
+// Run tallies request outcomes every collect interval until ctx is cancelled
+func (d *DeadPlanDetector) Run(ctx context.Context) {
+	if d.cfg.CollectInterval <= 0 {
+		return
+	}
+
+	d.logger.Info("Starting dead plan detection", zap.Duration("interval", d.cfg.CollectInterval))
+
+	ticker := time.NewTicker(d.cfg.CollectInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.Collect(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			d.logger.Error("Failed to collect request outcomes", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect tallies the requests logged since the last collection and drops
+// tallies older than the window
+func (d *DeadPlanDetector) Collect(ctx context.Context, now time.Time) error {
+	instances, err := d.instanceRepo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get instances: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, instance := range instances {
+		if instance.Status != domain.InstanceStatusRunning {
+			continue
+		}
+		path := proxyLogPath(d.logDir, instance.ID)
+		err := d.tailer.read(path, d.primed, func(line string) {
+			entry, ok := parseProxyLogLine(line)
+			// Refused credentials are the customer's problem, not the plan's
+			if !ok || entry.authFailed {
+				return
+			}
+			at := entry.time
+			if at.IsZero() {
+				at = now
+			}
+			d.tally(instance.PlanID, at.Truncate(time.Minute), entry.upstreamFailed)
+		})
+		if err != nil && !os.IsNotExist(err) {
+			d.logger.Debug("Failed to read proxy log", zap.String("path", path), zap.Error(err))
+		}
+	}
+	d.primed = true
+
+	cutoff := now.Add(-d.cfg.Window)
+	for planID, tallies := range d.tallies {
+		kept := tallies[:0]
+		for _, tally := range tallies {
+			if !tally.minute.Before(cutoff) {
+				kept = append(kept, tally)
+			}
+		}
+		if len(kept) == 0 {
+			delete(d.tallies, planID)
+		} else {
+			d.tallies[planID] = kept
+		}
+	}
+	return nil
+}
+
+func (d *DeadPlanDetector) tally(planID uuid.UUID, minute time.Time, failed bool) {
+	tallies := d.tallies[planID]
+	if n := len(tallies); n == 0 || !tallies[n-1].minute.Equal(minute) {
+		tallies = append(tallies, requestTally{minute: minute})
+		d.tallies[planID] = tallies
+	}
+	last := &tallies[len(tallies)-1]
+	last.requests++
+	if failed {
+		last.failed++
+	}
+}
+
+// requests returns a plan's requests and upstream failures since from
+func (d *DeadPlanDetector) requests(planID uuid.UUID, from time.Time) (requests, failed int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, tally := range d.tallies[planID] {
+		if !tally.minute.Before(from) {
+			requests += tally.requests
+			failed += tally.failed
+		}
+	}
+	return requests, failed
+}
+
+// Triage checks every active plan and reports the ones with findings
+func (d *DeadPlanDetector) Triage(ctx context.Context, now time.Time) (*domain.DeadPlanReport, error) {
+	plans, err := d.planRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plans: %w", err)
+	}
+
+	report := &domain.DeadPlanReport{
+		GeneratedAt: now,
+		Window:      d.cfg.Window,
+		Plans:       []*domain.DeadPlan{},
+	}
+	for _, plan := range plans {
+		if plan.Status != domain.PlanStatusActive && plan.Status != domain.PlanStatusGrace {
+			continue
+		}
+		report.Checked++
+
+		dead, err := d.check(ctx, plan, now)
+		if err != nil {
+			return nil, err
+		}
+		if len(dead.Findings) > 0 {
+			report.Plans = append(report.Plans, dead)
+		}
+	}
+
+	sort.SliceStable(report.Plans, func(i, j int) bool {
+		if len(report.Plans[i].Findings) != len(report.Plans[j].Findings) {
+			return len(report.Plans[i].Findings) > len(report.Plans[j].Findings)
+		}
+		return report.Plans[i].EdgeRequests > report.Plans[j].EdgeRequests
+	})
+	return report, nil
+}
+
+// check gathers the evidence for one plan and flags what does not add up
+func (d *DeadPlanDetector) check(ctx context.Context, plan *domain.ProxyPlan, now time.Time) (*domain.DeadPlan, error) {
+	from := now.Add(-d.cfg.Window)
+	dead := &domain.DeadPlan{
+		PlanID:      plan.ID,
+		CustomerID:  plan.CustomerID,
+		PlanTypeKey: plan.PlanTypeKey,
+		Status:      plan.Status,
+		Findings:    []string{},
+	}
+
+	dead.EdgeRequests, dead.FailedRequests = d.requests(plan.ID, from)
+	if dead.EdgeRequests >= int64(d.cfg.MinRequests) && dead.EdgeRequests > 0 &&
+		float64(dead.FailedRequests)/float64(dead.EdgeRequests) >= d.cfg.FailureRatio {
+		dead.Findings = append(dead.Findings, domain.DeadPlanUpstreamFailing)
+	}
+
+	if err := d.checkConnectionTests(ctx, plan, from, dead); err != nil {
+		return nil, err
+	}
+
+	stats, err := d.stats.GetPlanStats(ctx, plan.ID, plan.CreatedAt, now)
+	if err != nil {
+		return nil, err
+	}
+	dead.EdgeBytes = stats.BytesIn + stats.BytesOut
+
+	// Plans without a synced provider account have no usage to compare
+	account, err := d.accountRepo.GetByPlanID(ctx, plan.ID)
+	if err != nil || account.UsageSyncedAt == nil {
+		return dead, nil
+	}
+	used := account.UsedBytes
+	dead.ProviderUsedBytes = &used
+	dead.UsageSyncedAt = account.UsageSyncedAt
+
+	// Usage is reported per account, which may serve other plans too
+	accountBytes := dead.EdgeBytes
+	for _, id := range account.PlanIDs {
+		if id == plan.ID {
+			continue
+		}
+		other, err := d.planRepo.GetByID(ctx, id)
+		if err != nil {
+			continue
+		}
+		stats, err := d.stats.GetPlanStats(ctx, id, other.CreatedAt, now)
+		if err != nil {
+			return nil, err
+		}
+		accountBytes += stats.BytesIn + stats.BytesOut
+	}
+	if accountBytes != dead.EdgeBytes {
+		dead.AccountEdgeBytes = accountBytes
+	}
+
+	if accountBytes > 0 && accountBytes >= d.cfg.MinBytes && float64(used) < float64(accountBytes)*d.cfg.UsageRatio {
+		dead.Findings = append(dead.Findings, domain.DeadPlanNoProviderUsage)
+	}
+
+	return dead, nil
+}
+
+// checkConnectionTests flags a plan with an instance whose latest
+// connection test in the window failed and was not followed by a recovery
+func (d *DeadPlanDetector) checkConnectionTests(ctx context.Context, plan *domain.ProxyPlan, from time.Time, dead *domain.DeadPlan) error {
+	events, err := d.eventRepo.GetByPlanID(ctx, plan.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get plan events: %w", err)
+	}
+
+	var last *domain.PlanEvent
+	failing := make(map[uuid.UUID]*domain.PlanEvent)
+	for _, event := range events {
+		if event.CreatedAt.Before(from) || event.InstanceID == nil {
+			continue
+		}
+		switch event.Type {
+		case domain.EventHealthCheckFailed:
+			dead.ConnectionTestFailures++
+			last = event
+			failing[*event.InstanceID] = event
+		case domain.EventInstanceRecovered, domain.EventInstanceStarted, domain.EventInstanceRestarted:
+			delete(failing, *event.InstanceID)
+		}
+	}
+	if last != nil {
+		dead.LastConnectionError = last.Message
+		dead.LastConnectionFailedAt = &last.CreatedAt
+	}
+	if len(failing) == 0 {
+		return nil
+	}
+
+	finding := domain.DeadPlanConnectionTestFailing
+	for _, event := range failing {
+		if isUpstreamAuthError(event.Message) {
+			finding = domain.DeadPlanUpstreamAuthFailed
+		}
+	}
+	dead.Findings = append(dead.Findings, finding)
+	return nil
+}
+
+// isUpstreamAuthError reports whether a connection test error is the
+// upstream proxy refusing the plan's credentials
+func isUpstreamAuthError(message string) bool {
+	return strings.Contains(message, "status 407") || strings.Contains(message, "Proxy Authentication Required")
+}
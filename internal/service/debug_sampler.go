@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// DebugSampler records a share of the requests of plans put in debug
+// sampling, read from their 3proxy instance logs. Only the method, host,
+// result code, duration and byte counts are kept, never bodies or client
+// addresses. Samples are held in memory, so they do not survive a restart;
+// the sampling window is saved on the plan and carries on.
+type DebugSampler struct {
+	cfg          config.DebugSampling
+	logDir       string
+	logger       *zap.Logger
+	planRepo     repository.PlanRepository
+	instanceRepo repository.InstanceRepository
+	events       *eventRecorder
+
+	mu      sync.Mutex
+	tailer  *logTailer
+	samples map[uuid.UUID][]domain.DebugSample
+	random  func() float64
+}
+
+// NewDebugSampler creates a new debug sampler; it only collects when
+// proxy.debug_sampling.interval is set
+func NewDebugSampler(
+	cfg *config.Config,
+	logger *zap.Logger,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	eventRepo repository.PlanEventRepository,
+) *DebugSampler {
+	return &DebugSampler{
+		cfg:          cfg.Proxy.DebugSampling,
+		logDir:       cfg.Proxy.LogDir,
+		logger:       logger,
+		planRepo:     planRepo,
+		instanceRepo: instanceRepo,
+		events:       newEventRecorder(eventRepo, logger),
+		tailer:       newLogTailer(),
+		samples:      make(map[uuid.UUID][]domain.DebugSample),
+		random:       rand.Float64,
+	}
+}
+
+// Run collects samples every interval until ctx is cancelled
+func (d *DebugSampler) Run(ctx context.Context) {
+	if d == nil || d.cfg.Interval <= 0 {
+		return
+	}
+
+	d.logger.Info("Starting debug sampling", zap.Duration("interval", d.cfg.Interval))
+
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.Collect(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			d.logger.Error("Failed to collect debug samples", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Start samples percent of a plan's requests from now on, replacing any
+// earlier samples. Zero minutes sample for proxy.debug_sampling.max_window.
+func (d *DebugSampler) Start(ctx context.Context, planID uuid.UUID, req *domain.DebugSamplingRequest, now time.Time) (*domain.ProxyPlan, error) {
+	plan, err := d.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.cfg.Interval <= 0 {
+		return nil, &domain.PolicyError{PlanType: plan.PlanTypeKey, Field: "debug_sampling", Reason: "is disabled (proxy.debug_sampling.interval is 0)"}
+	}
+	if req.Percent <= 0 || req.Percent > 100 {
+		return nil, &domain.PolicyError{PlanType: plan.PlanTypeKey, Field: "percent", Reason: "must be above 0 and at most 100"}
+	}
+	maxMinutes := int(d.cfg.MaxWindow / time.Minute)
+	if req.Minutes < 0 || req.Minutes > maxMinutes {
+		return nil, &domain.PolicyError{PlanType: plan.PlanTypeKey, Field: "minutes", Reason: fmt.Sprintf("must be between 1 and %d", maxMinutes)}
+	}
+	window := d.cfg.MaxWindow
+	if req.Minutes > 0 {
+		window = time.Duration(req.Minutes) * time.Minute
+	}
+
+	instances, err := d.instanceRepo.GetByPlanID(ctx, plan.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan instances: %w", err)
+	}
+
+	plan.DebugSampling = &domain.DebugSampling{
+		Percent:   req.Percent,
+		StartedAt: now,
+		Until:     now.Add(window),
+	}
+	plan.UpdatedAt = now
+	if err := d.planRepo.Update(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to update plan: %w", err)
+	}
+
+	d.mu.Lock()
+	delete(d.samples, plan.ID)
+	// Skip what the instances logged before sampling started
+	for _, instance := range instances {
+		d.tailer.read(proxyLogPath(d.logDir, instance.ID), false, func(string) {})
+	}
+	d.mu.Unlock()
+
+	d.events.record(ctx, plan.ID, nil, domain.EventDebugSamplingStarted, "Debug sampling started", map[string]string{
+		"percent": fmt.Sprint(req.Percent),
+		"until":   plan.DebugSampling.Until.Format(time.RFC3339),
+	})
+	d.logger.Info("Started debug sampling",
+		zap.String("plan_id", plan.ID.String()),
+		zap.Float64("percent", req.Percent),
+		zap.Time("until", plan.DebugSampling.Until))
+
+	return plan, nil
+}
+
+// Stop ends a plan's sampling early; its samples stay available
+func (d *DebugSampler) Stop(ctx context.Context, planID uuid.UUID, now time.Time) (*domain.ProxyPlan, error) {
+	plan, err := d.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	if !plan.DebugSampling.Active(now) {
+		return plan, nil
+	}
+
+	plan.DebugSampling.Until = now
+	plan.UpdatedAt = now
+	if err := d.planRepo.Update(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to update plan: %w", err)
+	}
+
+	d.events.record(ctx, plan.ID, nil, domain.EventDebugSamplingStopped, "Debug sampling stopped", nil)
+	d.logger.Info("Stopped debug sampling", zap.String("plan_id", plan.ID.String()))
+
+	return plan, nil
+}
+
+// Samples returns a plan's sampling window and the requests sampled in it
+func (d *DebugSampler) Samples(ctx context.Context, planID uuid.UUID) (*domain.DebugSamplesResponse, error) {
+	plan, err := d.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	samples := append([]domain.DebugSample{}, d.samples[plan.ID]...)
+	d.mu.Unlock()
+
+	return &domain.DebugSamplesResponse{
+		PlanID:   plan.ID,
+		Sampling: plan.DebugSampling,
+		Samples:  samples,
+	}, nil
+}
+
+// Collect samples the requests logged since the last collection by the
+// running instances of plans being sampled
+func (d *DebugSampler) Collect(ctx context.Context, now time.Time) error {
+	plans, err := d.planRepo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get plans: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, plan := range plans {
+		sampling := plan.DebugSampling
+		if !sampling.Active(now) {
+			continue
+		}
+
+		instances, err := d.instanceRepo.GetByPlanID(ctx, plan.ID)
+		if err != nil {
+			d.logger.Error("Failed to get instances of sampled plan", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+			continue
+		}
+		for _, instance := range instances {
+			if instance.Status != domain.InstanceStatusRunning {
+				continue
+			}
+			path := proxyLogPath(d.logDir, instance.ID)
+			err := d.tailer.read(path, false, func(line string) {
+				entry, ok := parseProxyLogLine(line)
+				if !ok || entry.request == "" {
+					return
+				}
+				if !entry.time.IsZero() && (entry.time.Before(sampling.StartedAt) || !entry.time.Before(sampling.Until)) {
+					return
+				}
+				if d.random()*100 >= sampling.Percent {
+					return
+				}
+
+				sample := domain.DebugSample{
+					Time:       entry.time,
+					InstanceID: instance.ID,
+					Method:     requestMethod(entry.request),
+					Host:       requestHost(entry.request),
+					Code:       entry.code,
+					DurationMs: entry.durationMs,
+					BytesIn:    entry.bytesIn,
+					BytesOut:   entry.bytesOut,
+				}
+				if sample.Time.IsZero() {
+					sample.Time = now
+				}
+				d.add(plan.ID, sample)
+			})
+			if err != nil && !os.IsNotExist(err) {
+				d.logger.Debug("Failed to read proxy log", zap.String("path", path), zap.Error(err))
+			}
+		}
+	}
+
+	return nil
+}
+
+// add keeps a sample, dropping the oldest beyond max_samples
+func (d *DebugSampler) add(planID uuid.UUID, sample domain.DebugSample) {
+	samples := append(d.samples[planID], sample)
+	if excess := len(samples) - d.cfg.MaxSamples; excess > 0 {
+		samples = append(samples[:0:0], samples[excess:]...)
+	}
+	d.samples[planID] = samples
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/pkg/config"
+	"github.com/je265/oceanproxy/pkg/doh"
+)
+
+// DNSForwarders serves the plan types that resolve through DNS-over-HTTPS.
+// 3proxy only speaks classic DNS, so each distinct list of DoH resolvers gets
+// a local forwarder that instances use as their only nserver; queries never
+// reach the host's resolver. Addresses follow from the configuration alone,
+// so configs written by the CLI point at the server's forwarders.
+type DNSForwarders struct {
+	cfg       config.DoHForwarding
+	logger    *zap.Logger
+	addresses map[string]string
+	resolvers map[string][]string
+}
+
+// NewDNSForwarders assigns forwarder addresses to the DoH resolver lists of
+// the plan types
+func NewDNSForwarders(cfg *config.Config, logger *zap.Logger, planTypes *PlanTypeRegistry) *DNSForwarders {
+	resolvers := make(map[string][]string)
+	for _, planType := range planTypes.All() {
+		if planType.Proxy != nil && len(planType.Proxy.DoH) > 0 {
+			resolvers[dohKey(planType.Proxy.DoH)] = planType.Proxy.DoH
+		}
+	}
+
+	keys := make([]string, 0, len(resolvers))
+	for key := range resolvers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	addresses := make(map[string]string, len(keys))
+	for i, key := range keys {
+		addresses[key] = net.JoinHostPort(cfg.Proxy.DoH.ListenHost, strconv.Itoa(cfg.Proxy.DoH.PortStart+i))
+	}
+
+	return &DNSForwarders{
+		cfg:       cfg.Proxy.DoH,
+		logger:    logger,
+		addresses: addresses,
+		resolvers: resolvers,
+	}
+}
+
+// Address returns the forwarder serving a DoH resolver list. Lists not
+// known at startup have none, so instances using them fail to start rather
+// than fall back to the host's resolver.
+func (d *DNSForwarders) Address(resolvers []string) (string, error) {
+	if d == nil {
+		return "", fmt.Errorf("DNS-over-HTTPS forwarding is not available")
+	}
+	address, ok := d.addresses[dohKey(resolvers)]
+	if !ok {
+		return "", fmt.Errorf("no DNS-over-HTTPS forwarder for resolvers %s; restart the server to add one", strings.Join(resolvers, ", "))
+	}
+	return address, nil
+}
+
+// Start binds every forwarder and serves queries until ctx is cancelled
+func (d *DNSForwarders) Start(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+
+	for key, address := range d.addresses {
+		resolvers := d.resolvers[key]
+		forwarder, err := doh.NewForwarder(resolvers, d.cfg.Timeout)
+		if err != nil {
+			return err
+		}
+
+		onError := func(err error) {
+			d.logger.Warn("DNS-over-HTTPS forwarding failed", zap.String("address", address), zap.Error(err))
+		}
+		if err := forwarder.Listen(ctx, address, onError); err != nil {
+			return fmt.Errorf("failed to start DNS-over-HTTPS forwarder: %w", err)
+		}
+
+		d.logger.Info("Started DNS-over-HTTPS forwarder",
+			zap.String("address", address),
+			zap.Strings("resolvers", resolvers))
+	}
+
+	return nil
+}
+
+// dohKey identifies a resolver list; order matters, as resolvers are tried
+// in turn
+func dohKey(resolvers []string) string {
+	return strings.Join(resolvers, " ")
+}
+
+// dohSettings returns a copy of settings resolving through the forwarder at
+// address instead of any configured nservers
+func dohSettings(settings *domain.ProxySettings, address string) *domain.ProxySettings {
+	resolved := *settings
+	resolved.NServers = []string{address}
+	return &resolved
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"sort"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+// EndpointMap lists the customer-facing endpoint of every configured plan
+// type, as plans of it are handed out, sorted by provider, plan type and
+// region. A plan type whose endpoint cannot be resolved is listed with the
+// error instead.
+func (s *CapabilityService) EndpointMap() *domain.EndpointMap {
+	endpointMap := &domain.EndpointMap{Endpoints: []domain.EndpointMapping{}}
+	for key, planType := range s.planTypes.All() {
+		mapping := domain.EndpointMapping{
+			PlanTypeKey: key,
+			Provider:    planType.Provider,
+			PlanType:    planType.PlanType,
+			Region:      planType.Region,
+			Disabled:    planType.Disabled,
+		}
+		host, port, label, err := resolveEndpoint(s.regions, planType.Provider, planType.PlanType, planType.Region)
+		if err != nil {
+			mapping.Error = err.Error()
+		} else {
+			mapping.Host, mapping.Port, mapping.Label = host, port, label
+		}
+		endpointMap.Endpoints = append(endpointMap.Endpoints, mapping)
+	}
+
+	sort.Slice(endpointMap.Endpoints, func(i, j int) bool {
+		a, b := endpointMap.Endpoints[i], endpointMap.Endpoints[j]
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.PlanType != b.PlanType {
+			return a.PlanType < b.PlanType
+		}
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		return a.PlanTypeKey < b.PlanTypeKey
+	})
+
+	return endpointMap
+}
+
+// resolveEndpoint determines the customer-facing host, port, and region label
+// based on provider, plan type, and requested region.
+func resolveEndpoint(regions *RegionRegistry, provider, planType, reqRegion string) (string, int, string, error) {
+	switch provider {
+	case domain.ProviderProxiesFo:
+		switch planType {
+		case domain.PlanTypeResidential:
+			// usa -> usa.oceanproxy.io, eu -> eu.oceanproxy.io
+			region := regions.Get(reqRegion)
+			if region == nil {
+				return "", 0, "", domain.NotFoundf("region %s not found", reqRegion)
+			}
+			return region.GetFullDomain(), region.OutboundPort, region.Name, nil
+		case domain.PlanTypeDatacenter:
+			// datacenter.oceanproxy.io with port from requested region
+			region := regions.Get(reqRegion)
+			if region == nil {
+				return "", 0, "", domain.NotFoundf("region %s not found", reqRegion)
+			}
+			return "datacenter.oceanproxy.io", region.OutboundPort, "datacenter", nil
+		case domain.PlanTypeISP:
+			// isp.oceanproxy.io with port from requested region
+			region := regions.Get(reqRegion)
+			if region == nil {
+				return "", 0, "", domain.NotFoundf("region %s not found", reqRegion)
+			}
+			return "isp.oceanproxy.io", region.OutboundPort, "isp", nil
+		default:
+			// fallback to requested region
+			region := regions.Get(reqRegion)
+			if region == nil {
+				return "", 0, "", domain.NotFoundf("region %s not found", reqRegion)
+			}
+			return region.GetFullDomain(), region.OutboundPort, region.Name, nil
+		}
+	case domain.ProviderNettify:
+		switch planType {
+		case domain.PlanTypeResidential:
+			// alpha.oceanproxy.io (use alpha port)
+			alpha := regions.Get(domain.RegionAlpha)
+			if alpha == nil {
+				return "", 0, "", domain.NotFoundf("region %s not found", domain.RegionAlpha)
+			}
+			return "alpha.oceanproxy.io", alpha.OutboundPort, "alpha", nil
+		case domain.PlanTypeDatacenter:
+			// beta.oceanproxy.io (use beta port)
+			beta := regions.Get(domain.RegionBeta)
+			if beta == nil {
+				return "", 0, "", domain.NotFoundf("region %s not found", domain.RegionBeta)
+			}
+			return "beta.oceanproxy.io", beta.OutboundPort, "beta", nil
+		case domain.PlanTypeMobile:
+			// mobile.oceanproxy.io (use alpha port as base if mobile not defined)
+			// Try a region named "mobile" if present; otherwise fall back to alpha's port
+			if mobile := regions.Get("mobile"); mobile != nil {
+				return "mobile.oceanproxy.io", mobile.OutboundPort, "mobile", nil
+			}
+			alpha := regions.Get(domain.RegionAlpha)
+			if alpha == nil {
+				return "", 0, "", domain.NotFoundf("region %s not found", domain.RegionAlpha)
+			}
+			return "mobile.oceanproxy.io", alpha.OutboundPort, "mobile", nil
+		case domain.PlanTypeUnlimited:
+			// unlim.oceanproxy.io (use alpha port as base if unlim not defined)
+			if unlim := regions.Get("unlim"); unlim != nil {
+				return "unlim.oceanproxy.io", unlim.OutboundPort, "unlim", nil
+			}
+			alpha := regions.Get(domain.RegionAlpha)
+			if alpha == nil {
+				return "", 0, "", domain.NotFoundf("region %s not found", domain.RegionAlpha)
+			}
+			return "unlim.oceanproxy.io", alpha.OutboundPort, "unlim", nil
+		default:
+			alpha := regions.Get(domain.RegionAlpha)
+			if alpha == nil {
+				return "", 0, "", domain.NotFoundf("region %s not found", domain.RegionAlpha)
+			}
+			return alpha.GetFullDomain(), alpha.OutboundPort, alpha.Name, nil
+		}
+	}
+
+	// Unknown provider; default to requested region
+	region := regions.Get(reqRegion)
+	if region == nil {
+		return "", 0, "", domain.NotFoundf("region %s not found", reqRegion)
+	}
+	return region.GetFullDomain(), region.OutboundPort, region.Name, nil
+}
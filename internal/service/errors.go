@@ -0,0 +1,23 @@
+package service
+
+import "github.com/je265/oceanproxy/internal/domain"
+
+// Typed service errors. Services return errors classified under these kinds
+// (see domain.NotFoundf and friends) and handlers map them to HTTP statuses
+// with errors.Is, rather than answering every failure with a 500.
+var (
+	// ErrNotFound is matched by errors for plans, instances and other
+	// resources that do not exist
+	ErrNotFound = domain.ErrNotFound
+	// ErrConflict is matched by errors for changes that clash with existing
+	// state, such as a duplicate username or an already allocated port
+	ErrConflict = domain.ErrConflict
+	// ErrPortExhausted is matched by errors for a plan type whose port or
+	// egress IP pool is full
+	ErrPortExhausted = domain.ErrPortExhausted
+	// ErrProviderFailure is matched by errors an upstream provider returned
+	ErrProviderFailure = domain.ErrProviderFailure
+	// ErrInvalid is matched by errors for requests the caller got wrong,
+	// such as an unknown provider
+	ErrInvalid = domain.ErrInvalid
+)
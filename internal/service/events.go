@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// eventRecorder appends plan events. Recording is best effort: a failed write
+// is logged and never fails the operation being recorded.
+type eventRecorder struct {
+	repo   repository.PlanEventRepository
+	logger *zap.Logger
+}
+
+func newEventRecorder(repo repository.PlanEventRepository, logger *zap.Logger) *eventRecorder {
+	return &eventRecorder{repo: repo, logger: logger}
+}
+
+// record appends an event for planID; instanceID and data are optional
+func (r *eventRecorder) record(ctx context.Context, planID uuid.UUID, instanceID *uuid.UUID, eventType, message string, data map[string]string) {
+	if r == nil || r.repo == nil {
+		return
+	}
+
+	event := &domain.PlanEvent{
+		ID:         uuid.New(),
+		PlanID:     planID,
+		InstanceID: instanceID,
+		Type:       eventType,
+		Message:    message,
+		Data:       data,
+		CreatedAt:  time.Now(),
+	}
+
+	if err := r.repo.Append(ctx, event); err != nil {
+		r.logger.Warn("Failed to record plan event",
+			zap.String("plan_id", planID.String()),
+			zap.String("event_type", eventType),
+			zap.Error(err),
+		)
+	}
+}
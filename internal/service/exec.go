@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// runCommand runs a helper command bounded by ctx and timeout (zero for no
+// extra bound) and returns its combined output. Errors include the output.
+func runCommand(ctx context.Context, timeout time.Duration, name string, args ...string) ([]byte, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return output, fmt.Errorf("%s did not finish: %w", name, ctxErr)
+	}
+	if err != nil {
+		return output, fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(string(output)))
+	}
+
+	return output, nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// ErrQuotaExceeded is returned when the provider reports the upstream
+// bandwidth of an account as used up
+var ErrQuotaExceeded = errors.New("upstream bandwidth quota exceeded")
+
+// IsQuotaExceeded reports whether err was caused by upstream quota exhaustion
+func IsQuotaExceeded(err error) bool {
+	return errors.Is(err, ErrQuotaExceeded)
+}
+
+// quotaMarkers are lower-case fragments providers use in quota errors
+var quotaMarkers = []string{
+	"quota exceeded",
+	"quota reached",
+	"bandwidth exceeded",
+	"bandwidth limit",
+	"traffic limit",
+	"traffic exceeded",
+	"out of bandwidth",
+	"no bandwidth",
+	"insufficient bandwidth",
+}
+
+// isQuotaResponse classifies a provider or proxy response as quota exhaustion
+func isQuotaResponse(statusCode int, body string) bool {
+	if statusCode == http.StatusPaymentRequired {
+		return true
+	}
+
+	body = strings.ToLower(body)
+	for _, marker := range quotaMarkers {
+		if strings.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExhaustionMonitor moves plans whose upstream bandwidth ran out to the
+// exhausted status and notifies the customer with a top-up path
+type ExhaustionMonitor struct {
+	logger   *zap.Logger
+	planRepo repository.PlanRepository
+	events   *eventRecorder
+	notifier Notifier
+}
+
+// NewExhaustionMonitor creates a new exhaustion monitor
+func NewExhaustionMonitor(
+	logger *zap.Logger,
+	planRepo repository.PlanRepository,
+	eventRepo repository.PlanEventRepository,
+	notifier Notifier,
+) *ExhaustionMonitor {
+	return &ExhaustionMonitor{
+		logger:   logger,
+		planRepo: planRepo,
+		events:   newEventRecorder(eventRepo, logger),
+		notifier: notifier,
+	}
+}
+
+// MarkExhausted transitions an active plan to exhausted and reports whether
+// it did. Plans in any other status are left alone.
+func (m *ExhaustionMonitor) MarkExhausted(ctx context.Context, planID uuid.UUID, reason string) (bool, error) {
+	if m == nil {
+		return false, nil
+	}
+
+	plan, err := m.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return false, err
+	}
+	if plan.Status != domain.PlanStatusActive {
+		return false, nil
+	}
+
+	plan.Status = domain.PlanStatusExhausted
+	if err := m.planRepo.Update(ctx, plan); err != nil {
+		return false, fmt.Errorf("failed to mark plan exhausted: %w", err)
+	}
+
+	m.logger.Warn("Plan upstream bandwidth exhausted",
+		zap.String("plan_id", plan.ID.String()),
+		zap.String("customer_id", plan.CustomerID),
+		zap.String("reason", reason),
+	)
+	m.events.record(ctx, plan.ID, nil, domain.EventPlanExhausted, reason, nil)
+
+	topUpPath := fmt.Sprintf("/api/v1/plans/%s/topup", plan.ID)
+	notification := &domain.Notification{
+		Type:       domain.NotificationPlanExhausted,
+		PlanID:     plan.ID.String(),
+		CustomerID: plan.CustomerID,
+		Message:    "Your proxy plan has used all of its bandwidth. Top it up to restore service.",
+		Data: map[string]string{
+			"reason":     reason,
+			"topup_path": topUpPath,
+		},
+		CreatedAt: time.Now(),
+	}
+	if m.notifier != nil {
+		if err := m.notifier.Notify(ctx, notification); err != nil {
+			m.logger.Error("Failed to send exhaustion notification",
+				zap.String("plan_id", plan.ID.String()),
+				zap.Error(err))
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// ExpiryWorker moves plans past their ExpiresAt to the expired status and
+// carries out their expiry action: stopping their instances, deleting them,
+// or downgrading them to a throttled teaser. Active plans whose plan type
+// has a grace period are first flagged grace, and keep serving, possibly
+// throttled, until it ends.
+// ExpiresAt already carries the plan's time zone and billing anchor, so the
+// worker only compares instants. Plans set to auto-renew are charged and
+// extended instead, and only expire once renewal retries run out.
+type ExpiryWorker struct {
+	cfg            *config.Config
+	logger         *zap.Logger
+	planRepo       repository.PlanRepository
+	instanceRepo   repository.InstanceRepository
+	proxyService   ProxyService
+	accountService ProviderAccountService
+	plans          PlanService
+	payments       PaymentProvider
+	events         *eventRecorder
+	notifier       Notifier
+	planTypes      *PlanTypeRegistry
+}
+
+// NewExpiryWorker creates a new plan expiry worker
+func NewExpiryWorker(
+	cfg *config.Config,
+	logger *zap.Logger,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	eventRepo repository.PlanEventRepository,
+	proxyService ProxyService,
+	accountService ProviderAccountService,
+	plans PlanService,
+	payments PaymentProvider,
+	notifier Notifier,
+	planTypes *PlanTypeRegistry,
+) *ExpiryWorker {
+	return &ExpiryWorker{
+		cfg:            cfg,
+		logger:         logger,
+		planRepo:       planRepo,
+		instanceRepo:   instanceRepo,
+		proxyService:   proxyService,
+		accountService: accountService,
+		plans:          plans,
+		payments:       payments,
+		events:         newEventRecorder(eventRepo, logger),
+		notifier:       notifier,
+		planTypes:      planTypes,
+	}
+}
+
+// Run expires due plans every billing.expiry_interval until ctx is cancelled
+func (w *ExpiryWorker) Run(ctx context.Context) {
+	if w == nil || w.cfg.Billing.ExpiryInterval <= 0 {
+		return
+	}
+
+	w.logger.Info("Starting plan expiry worker",
+		zap.Duration("interval", w.cfg.Billing.ExpiryInterval))
+
+	ticker := time.NewTicker(w.cfg.Billing.ExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.ExpireDue(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			w.logger.Error("Failed to expire plans", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// gracePeriodFor returns the plan type's grace period, or the billing
+// defaults when it does not set one
+func gracePeriodFor(cfg *config.Config, planType *domain.PlanTypeConfig) domain.GracePeriod {
+	if planType != nil && planType.Grace != nil {
+		return *planType.Grace
+	}
+	return domain.GracePeriod{Period: cfg.Billing.GracePeriod, Throttle: cfg.Billing.GraceThrottle}
+}
+
+// throttledSettings returns a copy of settings with bandwidth capped at limit
+// bits per second in each direction
+func throttledSettings(settings *domain.ProxySettings, limit int64) *domain.ProxySettings {
+	throttled := domain.ProxySettings{}
+	if settings != nil {
+		throttled = *settings
+	}
+	if throttled.BandLimIn == 0 || throttled.BandLimIn > limit {
+		throttled.BandLimIn = limit
+	}
+	if throttled.BandLimOut == 0 || throttled.BandLimOut > limit {
+		throttled.BandLimOut = limit
+	}
+	return &throttled
+}
+
+// expirable reports whether a plan in status can still expire
+func expirable(status string) bool {
+	switch status {
+	case domain.PlanStatusActive, domain.PlanStatusExhausted, domain.PlanStatusSuspended, domain.PlanStatusGrace:
+		return true
+	}
+	return false
+}
+
+// ExpireDue expires every plan whose ExpiresAt is before now, unless it is
+// renewed, awaiting a renewal retry, or in or entering a grace period, and
+// returns the plans it expired. Teasers are only retried for renewal, which
+// restores them.
+func (w *ExpiryWorker) ExpireDue(ctx context.Context, now time.Time) ([]*domain.ProxyPlan, error) {
+	plans, err := w.planRepo.GetExpired(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expired plans: %w", err)
+	}
+
+	var expired []*domain.ProxyPlan
+	for _, plan := range plans {
+		if !expirable(plan.Status) && plan.Status != domain.PlanStatusTeaser {
+			continue
+		}
+
+		outcome := w.renew(ctx, plan, now)
+		if outcome == renewalRenewed {
+			continue
+		}
+
+		// Teasers already expired; they stay until renewed
+		if plan.Status == domain.PlanStatusTeaser {
+			continue
+		}
+
+		if plan.Status == domain.PlanStatusGrace {
+			if plan.GraceEndsAt != nil && now.Before(*plan.GraceEndsAt) {
+				continue
+			}
+		} else if plan.Status == domain.PlanStatusActive {
+			grace := gracePeriodFor(w.cfg, w.planTypes.Get(plan.PlanTypeKey))
+			if graceEndsAt := plan.ExpiresAt.Add(grace.Period); now.Before(graceEndsAt) {
+				w.startGrace(ctx, plan, graceEndsAt, grace, now)
+				continue
+			}
+		}
+
+		// Keep serving while a payment retry is outstanding
+		if outcome == renewalPending {
+			continue
+		}
+
+		if plan.ExpiryAction == domain.ExpiryActionDowngrade {
+			if w.downgrade(ctx, plan, now) {
+				expired = append(expired, plan)
+			}
+			continue
+		}
+
+		previous := plan.Status
+		plan.Status = domain.PlanStatusExpired
+		plan.UpdatedAt = now
+		if err := w.planRepo.Update(ctx, plan); err != nil {
+			w.logger.Error("Failed to expire plan", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+			continue
+		}
+		expired = append(expired, plan)
+
+		w.events.record(ctx, plan.ID, nil, domain.EventPlanExpired, "Plan expired", map[string]string{
+			"from":       previous,
+			"to":         domain.PlanStatusExpired,
+			"expires_at": plan.ExpiresAt.Format(time.RFC3339),
+		})
+		w.logger.Info("Plan expired",
+			zap.String("plan_id", plan.ID.String()),
+			zap.String("customer_id", plan.CustomerID),
+			zap.Time("expires_at", plan.ExpiresAt),
+		)
+
+		if plan.ExpiryAction == domain.ExpiryActionDelete {
+			w.deletePlan(ctx, plan)
+			continue
+		}
+		w.stopInstances(ctx, plan)
+	}
+
+	return expired, nil
+}
+
+// downgrade turns an expired plan into a teaser capped at
+// billing.teaser_throttle and restarts its instances to apply the cap
+func (w *ExpiryWorker) downgrade(ctx context.Context, plan *domain.ProxyPlan, now time.Time) bool {
+	previous := plan.Status
+	plan.Status = domain.PlanStatusTeaser
+	plan.UpdatedAt = now
+	if err := w.planRepo.Update(ctx, plan); err != nil {
+		w.logger.Error("Failed to downgrade plan", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+		return false
+	}
+
+	throttle := fmt.Sprint(w.cfg.Billing.TeaserThrottle)
+	w.events.record(ctx, plan.ID, nil, domain.EventPlanDowngraded, "Plan expired and downgraded to teaser", map[string]string{
+		"from":         previous,
+		"to":           domain.PlanStatusTeaser,
+		"expires_at":   plan.ExpiresAt.Format(time.RFC3339),
+		"throttle_bps": throttle,
+	})
+	w.logger.Info("Plan expired and downgraded to teaser",
+		zap.String("plan_id", plan.ID.String()),
+		zap.String("customer_id", plan.CustomerID),
+		zap.Int64("throttle_bps", w.cfg.Billing.TeaserThrottle),
+	)
+
+	w.notify(ctx, plan, domain.NotificationPlanDowngraded,
+		"Your proxy plan has expired and now runs at a reduced speed; renew it to restore full service.",
+		map[string]string{
+			"expires_at":   plan.ExpiresAt.Format(time.RFC3339),
+			"throttle_bps": throttle,
+		}, now)
+
+	w.restartInstances(ctx, plan)
+	return true
+}
+
+// deletePlan deletes an expired plan and then its upstream account, which
+// is kept while other plans still share it
+func (w *ExpiryWorker) deletePlan(ctx context.Context, plan *domain.ProxyPlan) {
+	if err := w.plans.DeletePlan(ctx, plan.ID); err != nil {
+		w.logger.Error("Failed to delete expired plan", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+		return
+	}
+	w.logger.Info("Deleted expired plan", zap.String("plan_id", plan.ID.String()))
+
+	if plan.ProviderAccountID == nil {
+		return
+	}
+	if err := w.accountService.DeleteAccount(ctx, *plan.ProviderAccountID); err != nil {
+		if IsAccountInUse(err) {
+			w.logger.Info("Kept provider account of deleted plan; other plans still use it",
+				zap.String("plan_id", plan.ID.String()),
+				zap.String("account_id", plan.ProviderAccountID.String()))
+			return
+		}
+		w.logger.Error("Failed to delete provider account of expired plan",
+			zap.String("plan_id", plan.ID.String()),
+			zap.String("account_id", plan.ProviderAccountID.String()),
+			zap.Error(err))
+	}
+}
+
+// startGrace flags an expired plan grace, warns the customer and throttles
+// its instances if the plan type asks for it
+func (w *ExpiryWorker) startGrace(ctx context.Context, plan *domain.ProxyPlan, graceEndsAt time.Time, grace domain.GracePeriod, now time.Time) {
+	plan.Status = domain.PlanStatusGrace
+	plan.GraceEndsAt = &graceEndsAt
+	plan.UpdatedAt = now
+	if err := w.planRepo.Update(ctx, plan); err != nil {
+		w.logger.Error("Failed to start plan grace period", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+		return
+	}
+
+	w.events.record(ctx, plan.ID, nil, domain.EventPlanGraceStarted, "Plan expired, grace period started", map[string]string{
+		"from":          domain.PlanStatusActive,
+		"to":            domain.PlanStatusGrace,
+		"expires_at":    plan.ExpiresAt.Format(time.RFC3339),
+		"grace_ends_at": graceEndsAt.Format(time.RFC3339),
+		"throttle_bps":  fmt.Sprint(grace.Throttle),
+	})
+	w.logger.Warn("Plan expired, grace period started",
+		zap.String("plan_id", plan.ID.String()),
+		zap.String("customer_id", plan.CustomerID),
+		zap.Time("grace_ends_at", graceEndsAt),
+		zap.Int64("throttle_bps", grace.Throttle),
+	)
+
+	w.notify(ctx, plan, domain.NotificationPlanGrace,
+		"Your proxy plan has expired. Service continues until the grace period ends; renew it to avoid interruption.",
+		map[string]string{
+			"expires_at":    plan.ExpiresAt.Format(time.RFC3339),
+			"grace_ends_at": graceEndsAt.Format(time.RFC3339),
+		}, now)
+
+	// Restarting renders the throttled 3proxy config
+	if grace.Throttle > 0 {
+		w.restartInstances(ctx, plan)
+	}
+}
+
+// notify sends a notification about plan, logging delivery failures
+func (w *ExpiryWorker) notify(ctx context.Context, plan *domain.ProxyPlan, notificationType, message string, data map[string]string, now time.Time) {
+	if w.notifier == nil {
+		return
+	}
+
+	notification := &domain.Notification{
+		Type:       notificationType,
+		PlanID:     plan.ID.String(),
+		CustomerID: plan.CustomerID,
+		Message:    message,
+		Data:       data,
+		CreatedAt:  now,
+	}
+	if err := w.notifier.Notify(ctx, notification); err != nil {
+		w.logger.Error("Failed to send plan notification",
+			zap.String("type", notificationType),
+			zap.String("plan_id", plan.ID.String()),
+			zap.Error(err))
+	}
+}
+
+// restartInstances restarts the running instances of a plan
+func (w *ExpiryWorker) restartInstances(ctx context.Context, plan *domain.ProxyPlan) {
+	instances, err := w.instanceRepo.GetByPlanID(ctx, plan.ID)
+	if err != nil {
+		w.logger.Error("Failed to get instances of plan", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+		return
+	}
+
+	for _, instance := range instances {
+		if instance.Status != domain.InstanceStatusRunning {
+			continue
+		}
+		if err := restartAutomated(ctx, w.proxyService, w.logger, instance, "plan status change"); err != nil {
+			w.logger.Error("Failed to restart instance of plan",
+				zap.String("plan_id", plan.ID.String()),
+				zap.String("instance_id", instance.ID.String()),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// stopInstances stops the running instances of an expired plan
+func (w *ExpiryWorker) stopInstances(ctx context.Context, plan *domain.ProxyPlan) {
+	instances, err := w.instanceRepo.GetByPlanID(ctx, plan.ID)
+	if err != nil {
+		w.logger.Error("Failed to get instances of expired plan", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+		return
+	}
+
+	for _, instance := range instances {
+		if instance.Status != domain.InstanceStatusRunning {
+			continue
+		}
+		if err := w.proxyService.StopInstance(ctx, instance.ID); err != nil {
+			w.logger.Error("Failed to stop instance of expired plan",
+				zap.String("plan_id", plan.ID.String()),
+				zap.String("instance_id", instance.ID.String()),
+				zap.Error(err),
+			)
+		}
+	}
+}
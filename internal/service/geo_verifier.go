@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// GeoVerifier checks that plans exit from the countries their region
+// promises, flagging and optionally remediating mismatches
+type GeoVerifier struct {
+	cfg          config.GeoCheck
+	logger       *zap.Logger
+	planRepo     repository.PlanRepository
+	instanceRepo repository.InstanceRepository
+	proxyService ProxyService
+	events       *eventRecorder
+	regions      *RegionRegistry
+	planTypes    *PlanTypeRegistry
+}
+
+// NewGeoVerifier creates a new geo verifier
+func NewGeoVerifier(
+	cfg *config.Config,
+	logger *zap.Logger,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	eventRepo repository.PlanEventRepository,
+	proxyService ProxyService,
+	regions *RegionRegistry,
+	planTypes *PlanTypeRegistry,
+) *GeoVerifier {
+	return &GeoVerifier{
+		cfg:          cfg.GeoCheck,
+		logger:       logger,
+		planRepo:     planRepo,
+		instanceRepo: instanceRepo,
+		proxyService: proxyService,
+		events:       newEventRecorder(eventRepo, logger),
+		regions:      regions,
+		planTypes:    planTypes,
+	}
+}
+
+// Enabled reports whether geo checks are configured
+func (v *GeoVerifier) Enabled() bool {
+	return v != nil && v.cfg.Enabled
+}
+
+// Run re-verifies all active plans every interval until ctx is cancelled
+func (v *GeoVerifier) Run(ctx context.Context) {
+	if !v.Enabled() || v.cfg.Interval <= 0 {
+		return
+	}
+
+	v.logger.Info("Starting geo verification job", zap.Duration("interval", v.cfg.Interval))
+
+	ticker := time.NewTicker(v.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			v.verifyAll(ctx)
+		}
+	}
+}
+
+func (v *GeoVerifier) verifyAll(ctx context.Context) {
+	plans, err := v.planRepo.GetByStatus(ctx, domain.PlanStatusActive)
+	if err != nil {
+		v.logger.Error("Failed to load plans for geo verification", zap.Error(err))
+		return
+	}
+
+	mismatches := 0
+	for _, plan := range plans {
+		if ctx.Err() != nil {
+			return
+		}
+		check, err := v.VerifyPlan(ctx, plan.ID)
+		if err != nil {
+			v.logger.Debug("Geo verification skipped", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+			continue
+		}
+		if check.Mismatch {
+			mismatches++
+		}
+	}
+
+	v.logger.Info("Geo verification completed",
+		zap.Int("plans", len(plans)),
+		zap.Int("mismatches", mismatches),
+	)
+}
+
+// VerifyPlan looks up the exit location of a plan's upstream, records the
+// result on the plan and remediates a mismatch according to configuration
+func (v *GeoVerifier) VerifyPlan(ctx context.Context, planID uuid.UUID) (*domain.GeoCheck, error) {
+	plan, err := v.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	region := v.regions.Get(plan.Region)
+	if region == nil || len(region.Countries) == 0 {
+		return nil, fmt.Errorf("region %s has no expected countries", plan.Region)
+	}
+
+	instances, err := v.instanceRepo.GetByPlanID(ctx, planID)
+	if err != nil || len(instances) == 0 {
+		return nil, fmt.Errorf("plan %s has no instances", planID)
+	}
+	instance := instances[0]
+
+	check := &domain.GeoCheck{
+		Expected:  region.Countries,
+		CheckedAt: time.Now(),
+	}
+
+	exitIP, country, err := v.lookup(ctx, instance.AuthHost, instance.AuthPort, plan.Username, plan.Password)
+	if err != nil {
+		// A failed lookup says nothing about the region; keep the previous verdict
+		check.Error = err.Error()
+		if plan.GeoCheck != nil {
+			check.Mismatch = plan.GeoCheck.Mismatch
+		}
+	} else {
+		check.ExitIP = exitIP
+		check.Country = country
+		check.Mismatch = !region.ExpectsCountry(country)
+	}
+
+	plan.GeoCheck = check
+	if err := v.planRepo.Update(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to record geo check: %w", err)
+	}
+
+	if check.Error != "" {
+		return check, nil
+	}
+
+	data := map[string]string{"exit_ip": exitIP, "country": country, "upstream": instance.AuthHost}
+	if !check.Mismatch {
+		v.events.record(ctx, plan.ID, &instance.ID, domain.EventGeoVerified, "Exit IP is in the expected region", data)
+		return check, nil
+	}
+
+	v.logger.Warn("Plan exits from the wrong region",
+		zap.String("plan_id", plan.ID.String()),
+		zap.String("region", plan.Region),
+		zap.String("country", country),
+		zap.Strings("expected", region.Countries),
+		zap.String("upstream", instance.AuthHost),
+	)
+	v.events.record(ctx, plan.ID, &instance.ID, domain.EventGeoMismatch,
+		fmt.Sprintf("Exit IP geolocates to %s, expected one of %v", country, region.Countries), data)
+
+	if v.cfg.Remediation == domain.GeoRemediationResetUpstream {
+		v.resetUpstream(ctx, plan, instance)
+	}
+
+	return check, nil
+}
+
+// Mismatches returns plans whose latest geo check found a wrong-region exit
+func (v *GeoVerifier) Mismatches(ctx context.Context) ([]*domain.ProxyPlan, error) {
+	plans, err := v.planRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mismatched := make([]*domain.ProxyPlan, 0)
+	for _, plan := range plans {
+		if plan.GeoCheck != nil && plan.GeoCheck.Mismatch {
+			mismatched = append(mismatched, plan)
+		}
+	}
+
+	return mismatched, nil
+}
+
+// resetUpstream points the instance back at its plan type's configured
+// upstream host, which is region specific, and restarts it
+func (v *GeoVerifier) resetUpstream(ctx context.Context, plan *domain.ProxyPlan, instance *domain.ProxyInstance) {
+	planType := v.planTypes.Get(instance.PlanTypeKey)
+	if planType == nil || planType.UpstreamHost == "" || planType.UpstreamHost == instance.AuthHost {
+		return
+	}
+	if !instance.Autoheals() {
+		v.logger.Info("Not resetting upstream of instance exempt from autoheal",
+			zap.String("instance_id", instance.ID.String()))
+		return
+	}
+
+	previous := instance.AuthHost
+	instance.AuthHost = planType.UpstreamHost
+	if err := v.instanceRepo.Update(ctx, instance); err != nil {
+		v.logger.Error("Failed to reset instance upstream", zap.Error(err))
+		return
+	}
+
+	if err := v.proxyService.RestartInstance(ctx, instance.ID); err != nil {
+		v.logger.Error("Failed to restart instance after upstream reset",
+			zap.String("instance_id", instance.ID.String()),
+			zap.Error(err))
+		return
+	}
+
+	v.events.record(ctx, plan.ID, &instance.ID, domain.EventGeoRemediated, "Upstream reset to the plan type's configured host", map[string]string{
+		"from": previous,
+		"to":   planType.UpstreamHost,
+	})
+}
+
+// geoLookupResponse covers the field names of common IP geolocation APIs
+type geoLookupResponse struct {
+	Status      string `json:"status"`
+	Query       string `json:"query"`
+	IP          string `json:"ip"`
+	CountryCode string `json:"countryCode"`
+	Country2    string `json:"country_code"`
+	Country     string `json:"country"`
+}
+
+// lookup fetches the geolocation endpoint through the given upstream proxy
+func (v *GeoVerifier) lookup(ctx context.Context, host string, port int, username, password string) (string, string, error) {
+	proxyURL := &url.URL{
+		Scheme: "http",
+		User:   url.UserPassword(username, password),
+		Host:   net.JoinHostPort(host, strconv.Itoa(port)),
+	}
+	client := &http.Client{
+		Timeout:   v.cfg.Timeout,
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.LookupURL, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to build geo lookup request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("geo lookup through upstream failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read geo lookup response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("geo lookup returned status %d", resp.StatusCode)
+	}
+
+	var result geoLookupResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", "", fmt.Errorf("failed to parse geo lookup response: %w", err)
+	}
+	if result.Status != "" && result.Status != "success" {
+		return "", "", fmt.Errorf("geo lookup reported status %q", result.Status)
+	}
+
+	exitIP := result.Query
+	if exitIP == "" {
+		exitIP = result.IP
+	}
+	country := result.CountryCode
+	if country == "" {
+		country = result.Country2
+	}
+	if country == "" {
+		country = result.Country
+	}
+	if country == "" {
+		return "", "", fmt.Errorf("geo lookup response has no country code")
+	}
+
+	return exitIP, country, nil
+}
@@ -0,0 +1,529 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// ErrGitOpsNotSynced is returned for the report of a controller that has
+// not synced yet
+var ErrGitOpsNotSynced = errors.New("gitops has not synced yet")
+
+// gitOpsDesired is the state declared in the repository. A nil field is a
+// kind of resource the repository does not manage.
+type gitOpsDesired struct {
+	regions   map[string]*domain.Region
+	planTypes map[string]*domain.PlanTypeConfig
+	plans     []domain.PlanManifest
+}
+
+// GitOpsController reconciles regions, plan types and plans from a Git
+// repository. Each sync pulls the configured branch, compares the files in
+// it with the live state and applies the differences through the same
+// services as the admin API, then records a report of what it found.
+type GitOpsController struct {
+	cfg       config.GitOps
+	logger    *zap.Logger
+	repo      repository.GitOpsRepository
+	regions   *RegionService
+	planTypes *PlanTypeService
+	plans     PlanService
+
+	// mu serializes syncs
+	mu sync.Mutex
+}
+
+// NewGitOpsController creates a new GitOps controller
+func NewGitOpsController(
+	cfg *config.Config,
+	logger *zap.Logger,
+	repo repository.GitOpsRepository,
+	regions *RegionService,
+	planTypes *PlanTypeService,
+	plans PlanService,
+) *GitOpsController {
+	return &GitOpsController{
+		cfg:       cfg.GitOps,
+		logger:    logger,
+		repo:      repo,
+		regions:   regions,
+		planTypes: planTypes,
+		plans:     plans,
+	}
+}
+
+// Enabled reports whether a repository is configured
+func (c *GitOpsController) Enabled() bool {
+	return c.cfg.Enabled
+}
+
+// Run syncs every interval until ctx is cancelled
+func (c *GitOpsController) Run(ctx context.Context) {
+	if !c.cfg.Enabled || c.cfg.Interval <= 0 {
+		return
+	}
+
+	c.logger.Info("Starting GitOps sync",
+		zap.String("repo", c.cfg.Repo),
+		zap.String("branch", c.cfg.Branch),
+		zap.Duration("interval", c.cfg.Interval),
+		zap.Bool("dry_run", c.cfg.DryRun))
+
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := c.Sync(ctx); err != nil && ctx.Err() == nil {
+			c.logger.Error("GitOps sync failed", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync pulls the repository and reconciles the live state with it. The
+// report is stored, and written to gitops.report_file, even when the sync
+// fails.
+func (c *GitOpsController) Sync(ctx context.Context) (*domain.GitOpsReport, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, err := c.repo.GetState(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load GitOps state: %w", err)
+	}
+
+	report := &domain.GitOpsReport{
+		Repo:      c.cfg.Repo,
+		Branch:    c.cfg.Branch,
+		DryRun:    c.cfg.DryRun,
+		StartedAt: time.Now(),
+	}
+	changes := &changeSet{dryRun: c.cfg.DryRun, prune: c.cfg.Prune, logger: c.logger}
+	syncErr := c.sync(ctx, state, report, changes)
+	report.Changes = changes.list()
+	if syncErr != nil {
+		report.Error = syncErr.Error()
+	}
+	report.FinishedAt = time.Now()
+
+	state.LastReport = report
+	if err := c.repo.SaveState(ctx, state); err != nil {
+		c.logger.Error("Failed to save GitOps state", zap.Error(err))
+	}
+	if c.cfg.ReportFile != "" {
+		if err := writeFileAtomic(c.cfg.ReportFile, []byte(RenderGitOpsReport(report)), 0644); err != nil {
+			c.logger.Error("Failed to write GitOps report", zap.String("file", c.cfg.ReportFile), zap.Error(err))
+		}
+	}
+
+	c.logger.Info("GitOps sync finished",
+		zap.String("commit", report.Commit),
+		zap.Int("changes", len(report.Changes)),
+		zap.Int("failed", report.Failed()),
+		zap.Bool("dry_run", report.DryRun))
+
+	return report, syncErr
+}
+
+// LastReport returns the report of the latest sync
+func (c *GitOpsController) LastReport(ctx context.Context) (*domain.GitOpsReport, error) {
+	state, err := c.repo.GetState(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load GitOps state: %w", err)
+	}
+	if state.LastReport == nil {
+		return nil, ErrGitOpsNotSynced
+	}
+	return state.LastReport, nil
+}
+
+func (c *GitOpsController) sync(ctx context.Context, state *domain.GitOpsState, report *domain.GitOpsReport, changes *changeSet) error {
+	commit, err := c.pull(ctx)
+	if err != nil {
+		return err
+	}
+	report.Commit = commit
+
+	desired, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	// Regions list plan types and plan types name a region, so new regions
+	// are created with the plan types that already exist and completed once
+	// the plan types are in place. Dry runs change nothing in between and
+	// compare once.
+	if desired.regions != nil && !c.cfg.DryRun {
+		c.reconcileRegions(ctx, desired.regions, changes, true)
+	}
+	if desired.planTypes != nil {
+		c.reconcilePlanTypes(ctx, desired.planTypes, changes)
+	}
+	if desired.regions != nil {
+		c.reconcileRegions(ctx, desired.regions, changes, false)
+	}
+	if desired.plans != nil {
+		// Manifests are tied to plans by name in the controller state
+		if err := reconcilePlanManifests(ctx, c.plans, changes, desired.plans, state.Plans, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pull clones the branch into the checkout directory, or fetches it and
+// resets the checkout to it, and returns the commit checked out
+func (c *GitOpsController) pull(ctx context.Context) (string, error) {
+	dir := c.cfg.CheckoutDir
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return "", fmt.Errorf("failed to create GitOps checkout directory: %w", err)
+		}
+		if _, err := runCommand(ctx, c.cfg.GitTimeout, "git", "clone", "--depth", "1", "--branch", c.cfg.Branch, c.cfg.Repo, dir); err != nil {
+			return "", err
+		}
+	} else {
+		// Fetching the configured URL rather than origin follows a changed gitops.repo
+		if _, err := runCommand(ctx, c.cfg.GitTimeout, "git", "-C", dir, "fetch", "--depth", "1", c.cfg.Repo, c.cfg.Branch); err != nil {
+			return "", err
+		}
+		if _, err := runCommand(ctx, c.cfg.GitTimeout, "git", "-C", dir, "reset", "--hard", "FETCH_HEAD"); err != nil {
+			return "", err
+		}
+	}
+
+	output, err := runCommand(ctx, c.cfg.GitTimeout, "git", "-C", dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// load parses the files of the checkout
+func (c *GitOpsController) load() (*gitOpsDesired, error) {
+	desired := &gitOpsDesired{}
+
+	if c.cfg.RegionsFile != "" {
+		var file struct {
+			Regions map[string]*domain.Region `yaml:"regions"`
+		}
+		found, err := readYAMLFile(filepath.Join(c.cfg.CheckoutDir, c.cfg.RegionsFile), &file)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			desired.regions = make(map[string]*domain.Region, len(file.Regions))
+			for name, region := range file.Regions {
+				if region == nil {
+					return nil, fmt.Errorf("%s: region %s is empty", c.cfg.RegionsFile, name)
+				}
+				desired.regions[name] = region
+			}
+		}
+	}
+
+	if c.cfg.PlanTypesFile != "" {
+		var file struct {
+			PlanTypes map[string]*domain.PlanTypeConfig `yaml:"plan_types"`
+		}
+		found, err := readYAMLFile(filepath.Join(c.cfg.CheckoutDir, c.cfg.PlanTypesFile), &file)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			desired.planTypes = make(map[string]*domain.PlanTypeConfig, len(file.PlanTypes))
+			for key, planType := range file.PlanTypes {
+				if planType == nil {
+					return nil, fmt.Errorf("%s: plan type %s is empty", c.cfg.PlanTypesFile, key)
+				}
+				desired.planTypes[key] = planType
+			}
+		}
+	}
+
+	if c.cfg.PlansDir != "" {
+		plans, err := loadPlanManifests(filepath.Join(c.cfg.CheckoutDir, c.cfg.PlansDir))
+		if err != nil {
+			return nil, err
+		}
+		desired.plans = plans
+	}
+
+	return desired, nil
+}
+
+// reconcileRegions creates and updates regions to match the repository. A
+// partial pass leaves out plan types that do not exist yet and prunes
+// nothing.
+func (c *GitOpsController) reconcileRegions(ctx context.Context, desired map[string]*domain.Region, cs *changeSet, partial bool) {
+	for _, name := range sortedKeys(desired) {
+		want := *desired[name]
+		want.Name = name
+		setRegionDefaults(&want)
+		if partial {
+			want.PlanTypes = nil
+			for _, key := range desired[name].PlanTypes {
+				if _, err := c.planTypes.Get(key); err == nil {
+					want.PlanTypes = append(want.PlanTypes, key)
+				}
+			}
+		}
+
+		live, err := c.regions.Get(name)
+		if err != nil {
+			cs.record(domain.StateChange{Kind: domain.ResourceRegion, Name: name, Action: domain.ChangeCreate}, func() error {
+				_, err := c.regions.Create(ctx, &want)
+				return err
+			})
+			continue
+		}
+		if changed := changedFields(live, &want); len(changed) > 0 {
+			cs.record(domain.StateChange{Kind: domain.ResourceRegion, Name: name, Action: domain.ChangeUpdate, Detail: strings.Join(changed, ", ")}, func() error {
+				_, err := c.regions.Update(ctx, name, &want)
+				return err
+			})
+		}
+	}
+
+	if partial {
+		return
+	}
+	for _, region := range c.regions.List() {
+		if _, exists := desired[region.Name]; exists {
+			continue
+		}
+		name := region.Name
+		cs.remove(domain.StateChange{Kind: domain.ResourceRegion, Name: name, Action: domain.ChangeDelete}, func() error {
+			return c.regions.Delete(ctx, name)
+		})
+	}
+}
+
+// reconcilePlanTypes creates and updates plan types to match the
+// repository. Plan types cannot be deleted, so pruning disables them.
+func (c *GitOpsController) reconcilePlanTypes(ctx context.Context, desired map[string]*domain.PlanTypeConfig, cs *changeSet) {
+	for _, key := range sortedKeys(desired) {
+		want := desired[key]
+		if want.GetPlanTypeKey() != key {
+			cs.conflict(domain.ResourcePlanType, key,
+				fmt.Sprintf("key does not match provider, region and plan_type (%s)", want.GetPlanTypeKey()))
+			continue
+		}
+		setPlanTypeDefaults(key, want)
+
+		live, err := c.planTypes.Get(key)
+		if err != nil {
+			cs.record(domain.StateChange{Kind: domain.ResourcePlanType, Name: key, Action: domain.ChangeCreate}, func() error {
+				_, err := c.planTypes.Create(ctx, want)
+				return err
+			})
+			continue
+		}
+		if changed := changedFields(live, want); len(changed) > 0 {
+			cs.record(domain.StateChange{Kind: domain.ResourcePlanType, Name: key, Action: domain.ChangeUpdate, Detail: strings.Join(changed, ", ")}, func() error {
+				_, err := c.planTypes.Update(ctx, key, want)
+				return err
+			})
+		}
+	}
+
+	live := c.planTypes.List()
+	for _, key := range sortedKeys(live) {
+		if _, exists := desired[key]; exists || live[key].Disabled {
+			continue
+		}
+		key := key
+		cs.remove(domain.StateChange{Kind: domain.ResourcePlanType, Name: key, Action: domain.ChangeDisable}, func() error {
+			_, err := c.planTypes.SetDisabled(ctx, key, true)
+			return err
+		})
+	}
+}
+
+// RenderGitOpsReport renders a sync report as Markdown, for posting on the
+// pull request that changed the repository
+func RenderGitOpsReport(report *domain.GitOpsReport) string {
+	var b strings.Builder
+
+	b.WriteString("## OceanProxy GitOps sync\n\n")
+	commit := report.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	fmt.Fprintf(&b, "- Repository: `%s` (branch `%s`)\n", report.Repo, report.Branch)
+	if commit != "" {
+		fmt.Fprintf(&b, "- Commit: `%s`\n", commit)
+	}
+	fmt.Fprintf(&b, "- Finished: %s (%s)\n", report.FinishedAt.UTC().Format(time.RFC3339), report.FinishedAt.Sub(report.StartedAt).Round(time.Millisecond))
+	if report.DryRun {
+		b.WriteString("- Dry run: nothing was applied\n")
+	}
+	if report.Error != "" {
+		fmt.Fprintf(&b, "\n**Sync failed:** %s\n", markdownCell(report.Error))
+	}
+
+	if len(report.Changes) == 0 {
+		if report.Error == "" {
+			b.WriteString("\nLive state matches the repository.\n")
+		}
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "\n%d change(s), %d failed\n\n", len(report.Changes), report.Failed())
+	b.WriteString("| Kind | Name | Action | Result | Detail |\n")
+	b.WriteString("|------|------|--------|--------|--------|\n")
+	for _, change := range report.Changes {
+		result := "applied"
+		switch {
+		case change.Error != "":
+			result = "failed: " + change.Error
+		case change.Action == domain.ChangeConflict || change.Action == domain.ChangeUnmanaged:
+			result = "skipped"
+		case !change.Applied:
+			result = "planned"
+		}
+		fmt.Fprintf(&b, "| %s | `%s` | %s | %s | %s |\n",
+			change.Kind, change.Name, change.Action, markdownCell(result), markdownCell(change.Detail))
+	}
+	return b.String()
+}
+
+// loadPlanManifests reads the plan manifests of every YAML file in dir. A
+// missing directory returns nil, leaving plans unmanaged.
+func loadPlanManifests(dir string) ([]domain.PlanManifest, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	manifests := []domain.PlanManifest{}
+	seen := make(map[string]string)
+	for _, path := range files {
+		var file struct {
+			Plans []domain.PlanManifest `yaml:"plans"`
+		}
+		if _, err := readYAMLFile(path, &file); err != nil {
+			return nil, err
+		}
+		for _, manifest := range file.Plans {
+			if manifest.Name == "" {
+				return nil, fmt.Errorf("%s: plan manifest without a name", filepath.Base(path))
+			}
+			if other, exists := seen[manifest.Name]; exists {
+				return nil, fmt.Errorf("%s: plan %s is also declared in %s", filepath.Base(path), manifest.Name, other)
+			}
+			seen[manifest.Name] = filepath.Base(path)
+			manifests = append(manifests, manifest)
+		}
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].Name < manifests[j].Name
+	})
+	return manifests, nil
+}
+
+// readYAMLFile decodes a YAML file into v and reports whether it exists
+func readYAMLFile(path string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+	}
+	return true, nil
+}
+
+// changedFields returns the top-level YAML fields that differ between two
+// configurations, so an empty list and a missing one compare equal
+func changedFields(live, want interface{}) []string {
+	liveFields, wantFields := yamlFields(live), yamlFields(want)
+
+	var changed []string
+	for name, value := range wantFields {
+		if !reflect.DeepEqual(value, liveFields[name]) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range liveFields {
+		if _, exists := wantFields[name]; !exists {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func yamlFields(v interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	_ = yaml.Unmarshal(data, &fields)
+	for name, value := range fields {
+		if isEmptyYAML(value) {
+			delete(fields, name)
+		}
+	}
+	return fields
+}
+
+func isEmptyYAML(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+// defaultHealthCheckWorkers is used when proxy.health_check_workers is not set
+const defaultHealthCheckWorkers = 16
+
+// HealthChecker runs instance health checks concurrently on a bounded pool
+// of workers and aggregates the results
+type HealthChecker struct {
+	proxyService ProxyService
+	workers      int
+}
+
+// NewHealthChecker creates a health checker running at most workers checks at once
+func NewHealthChecker(proxyService ProxyService, workers int) *HealthChecker {
+	if workers <= 0 {
+		workers = defaultHealthCheckWorkers
+	}
+	return &HealthChecker{
+		proxyService: proxyService,
+		workers:      workers,
+	}
+}
+
+// Check health checks the instances and returns their results in the same
+// order. Instances not checked before ctx is cancelled fail with its error.
+func (c *HealthChecker) Check(ctx context.Context, instanceIDs []uuid.UUID) *domain.HealthCheckReport {
+	start := time.Now()
+	results := make([]domain.HealthCheckResult, len(instanceIDs))
+
+	workers := c.workers
+	if workers > len(instanceIDs) {
+		workers = len(instanceIDs)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = c.check(ctx, instanceIDs[i])
+			}
+		}()
+	}
+
+	for i := range instanceIDs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := &domain.HealthCheckReport{
+		Total:    len(results),
+		Duration: time.Since(start),
+		Results:  results,
+	}
+	for _, result := range results {
+		if result.Healthy {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+	}
+
+	return report
+}
+
+// CheckRunning health checks every running instance
+func (c *HealthChecker) CheckRunning(ctx context.Context) (*domain.HealthCheckReport, error) {
+	instances, err := c.proxyService.GetRunningInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(instances))
+	for i, instance := range instances {
+		ids[i] = instance.ID
+	}
+	return c.Check(ctx, ids), nil
+}
+
+func (c *HealthChecker) check(ctx context.Context, instanceID uuid.UUID) domain.HealthCheckResult {
+	result := domain.HealthCheckResult{InstanceID: instanceID}
+	if err := ctx.Err(); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	err := c.proxyService.HealthCheck(ctx, instanceID)
+	result.Duration = time.Since(start)
+
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Healthy = true
+	}
+	return result
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// healthMonitorTick is how often the monitor looks for instances due a check
+const healthMonitorTick = 5 * time.Second
+
+// instanceHealth is the flap-damping state of one instance
+type instanceHealth struct {
+	failures  int
+	successes int
+	unhealthy bool
+	nextCheck time.Time
+}
+
+// HealthMonitor schedules health checks of running instances using each
+// plan type's interval, and only changes an instance's status after the
+// plan type's failure or success threshold is reached. Instances in
+// maintenance are not checked.
+type HealthMonitor struct {
+	cfg          *config.Config
+	logger       *zap.Logger
+	instanceRepo repository.InstanceRepository
+	checker      *HealthChecker
+	events       *eventRecorder
+	incidents    *IncidentService
+	planTypes    *PlanTypeRegistry
+	crashLoops   *CrashLoopDetector
+	maintenance  *MaintenanceService
+
+	mu    sync.Mutex
+	state map[uuid.UUID]*instanceHealth
+}
+
+// NewHealthMonitor creates a new health check scheduler
+func NewHealthMonitor(
+	cfg *config.Config,
+	logger *zap.Logger,
+	instanceRepo repository.InstanceRepository,
+	eventRepo repository.PlanEventRepository,
+	checker *HealthChecker,
+	incidents *IncidentService,
+	planTypes *PlanTypeRegistry,
+	crashLoops *CrashLoopDetector,
+	maintenance *MaintenanceService,
+) *HealthMonitor {
+	return &HealthMonitor{
+		cfg:          cfg,
+		logger:       logger,
+		instanceRepo: instanceRepo,
+		checker:      checker,
+		events:       newEventRecorder(eventRepo, logger),
+		incidents:    incidents,
+		planTypes:    planTypes,
+		crashLoops:   crashLoops,
+		maintenance:  maintenance,
+		state:        make(map[uuid.UUID]*instanceHealth),
+	}
+}
+
+// Enabled reports whether any plan type has a health check interval
+func (m *HealthMonitor) Enabled() bool {
+	if m == nil {
+		return false
+	}
+	if m.cfg.Proxy.HealthCheckInterval > 0 {
+		return true
+	}
+	for _, planType := range m.planTypes.All() {
+		if planType.HealthCheck != nil && planType.HealthCheck.Interval > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Run checks instances as they fall due until ctx is cancelled
+func (m *HealthMonitor) Run(ctx context.Context) {
+	if !m.Enabled() {
+		return
+	}
+
+	m.logger.Info("Starting health check scheduler",
+		zap.Duration("default_interval", m.cfg.Proxy.HealthCheckInterval))
+
+	ticker := time.NewTicker(healthMonitorTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.checkDue(ctx, now)
+		}
+	}
+}
+
+func (m *HealthMonitor) checkDue(ctx context.Context, now time.Time) {
+	instances, err := m.instanceRepo.GetAll(ctx)
+	if err != nil {
+		m.logger.Error("Failed to load instances for health checks", zap.Error(err))
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[uuid.UUID]bool, len(instances))
+	var due []*domain.ProxyInstance
+	for _, instance := range instances {
+		// Operators work on instances in maintenance without them being failed
+		if instance.Maintenance {
+			continue
+		}
+
+		state := m.state[instance.ID]
+		// Failed instances stay monitored only if this monitor failed them
+		monitored := instance.Status == domain.InstanceStatusRunning ||
+			(instance.Status == domain.InstanceStatusFailed && state != nil && state.unhealthy)
+		if !monitored {
+			continue
+		}
+
+		settings := healthCheckSettings(m.cfg, m.planTypes, instance.PlanTypeKey)
+		if settings.Interval <= 0 {
+			continue
+		}
+
+		seen[instance.ID] = true
+		if state == nil {
+			state = &instanceHealth{}
+			m.state[instance.ID] = state
+		}
+		if now.Before(state.nextCheck) {
+			continue
+		}
+		state.nextCheck = now.Add(settings.Interval)
+		due = append(due, instance)
+	}
+
+	for id := range m.state {
+		if !seen[id] {
+			delete(m.state, id)
+		}
+	}
+
+	if len(due) == 0 {
+		return
+	}
+
+	ids := make([]uuid.UUID, len(due))
+	for i, instance := range due {
+		ids[i] = instance.ID
+	}
+	report := m.checker.Check(ctx, ids)
+	if ctx.Err() != nil {
+		return
+	}
+
+	for i, instance := range due {
+		var checkErr error
+		if result := report.Results[i]; !result.Healthy {
+			checkErr = errors.New(result.Error)
+		}
+		settings := healthCheckSettings(m.cfg, m.planTypes, instance.PlanTypeKey)
+		m.observe(ctx, instance, m.state[instance.ID], settings, checkErr)
+	}
+}
+
+// observe applies one check result, changing the instance status once a threshold is crossed
+func (m *HealthMonitor) observe(ctx context.Context, instance *domain.ProxyInstance, state *instanceHealth, settings domain.HealthCheckSettings, checkErr error) {
+	if checkErr != nil {
+		state.failures++
+		state.successes = 0
+		if state.unhealthy || state.failures < settings.FailureThreshold {
+			return
+		}
+
+		state.unhealthy = true
+		m.logger.Warn("Instance marked unhealthy",
+			zap.String("instance_id", instance.ID.String()),
+			zap.Int("consecutive_failures", state.failures),
+			zap.Error(checkErr))
+		m.setStatus(ctx, instance, domain.InstanceStatusFailed)
+
+		reason := checkErr.Error()
+		data := map[string]string{
+			"consecutive_failures": fmt.Sprint(state.failures),
+		}
+		// It stays in rotation and is not restarted
+		if instance.NoAutoheal {
+			data["no_autoheal"] = "true"
+		}
+		// Failures during provider maintenance say so, so nobody chases them
+		if window := m.maintenance.Active(ctx, instance.PlanTypeKey, time.Now()); window != nil {
+			reason = fmt.Sprintf("%s (%s)", reason, maintenanceNote(window))
+			for key, value := range maintenanceData(window) {
+				data[key] = value
+			}
+		}
+		m.events.record(ctx, instance.PlanID, &instance.ID, domain.EventInstanceUnhealthy, reason, data)
+		m.incidents.InstanceUnhealthy(ctx, instance, reason, time.Now())
+		m.crashLoops.InstanceDown(ctx, instance, checkErr.Error(), time.Now())
+		return
+	}
+
+	state.successes++
+	state.failures = 0
+	if !state.unhealthy || state.successes < settings.SuccessThreshold {
+		return
+	}
+
+	state.unhealthy = false
+	m.logger.Info("Instance recovered", zap.String("instance_id", instance.ID.String()))
+	m.setStatus(ctx, instance, domain.InstanceStatusRunning)
+	m.events.record(ctx, instance.PlanID, &instance.ID, domain.EventInstanceRecovered, "Health checks passing again", map[string]string{
+		"consecutive_successes": fmt.Sprint(state.successes),
+	})
+}
+
+func (m *HealthMonitor) setStatus(ctx context.Context, instance *domain.ProxyInstance, status string) {
+	instance.Status = status
+	instance.UpdatedAt = time.Now()
+	if err := m.instanceRepo.Update(ctx, instance); err != nil {
+		m.logger.Error("Failed to update instance health status",
+			zap.String("instance_id", instance.ID.String()),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// ErrIncidentTransition is returned for a status change an incident does not allow
+var ErrIncidentTransition = errors.New("invalid incident transition")
+
+// IncidentService keeps incident records for monitoring alerts. The health
+// monitor reports instances crossing their failure and success thresholds;
+// operators acknowledge, annotate and resolve incidents through the API.
+type IncidentService struct {
+	logger       *zap.Logger
+	incidentRepo repository.IncidentRepository
+	planTypes    *PlanTypeRegistry
+
+	// mu serializes read-modify-write cycles on incidents
+	mu sync.Mutex
+}
+
+// NewIncidentService creates a new incident service
+func NewIncidentService(logger *zap.Logger, incidentRepo repository.IncidentRepository, planTypes *PlanTypeRegistry) *IncidentService {
+	return &IncidentService{
+		logger:       logger,
+		incidentRepo: incidentRepo,
+		planTypes:    planTypes,
+	}
+}
+
+// InstanceUnhealthy records an instance marked unhealthy. It joins the
+// region's unresolved incident, or opens one.
+func (s *IncidentService) InstanceUnhealthy(ctx context.Context, instance *domain.ProxyInstance, reason string, now time.Time) {
+	if s == nil {
+		return
+	}
+	region := s.region(instance)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	incident, err := s.unresolved(ctx, region)
+	if err != nil {
+		s.logger.Error("Failed to look up incidents", zap.String("region", region), zap.Error(err))
+		return
+	}
+
+	update := domain.IncidentUpdate{
+		At:         now,
+		Message:    "Instance failed health checks: " + reason,
+		PlanID:     &instance.PlanID,
+		InstanceID: &instance.ID,
+	}
+
+	if incident == nil {
+		incident = &domain.Incident{
+			ID:       uuid.New(),
+			Title:    fmt.Sprintf("Degraded service in region %s", region),
+			Status:   domain.IncidentOpen,
+			Region:   region,
+			OpenedAt: now,
+		}
+		update.Status = domain.IncidentOpen
+		incident.Timeline = append(incident.Timeline, domain.IncidentUpdate{
+			At:      now,
+			Status:  domain.IncidentOpen,
+			Message: "We are investigating degraded service in this region.",
+			Public:  true,
+		})
+		s.affect(incident, instance)
+		incident.Timeline = append(incident.Timeline, update)
+		incident.UpdatedAt = now
+		if err := s.incidentRepo.Create(ctx, incident); err != nil {
+			s.logger.Error("Failed to open incident", zap.String("region", region), zap.Error(err))
+			return
+		}
+		s.logger.Warn("Incident opened",
+			zap.String("incident_id", incident.ID.String()),
+			zap.String("region", region),
+			zap.String("instance_id", instance.ID.String()))
+		return
+	}
+
+	update.Status = incident.Status
+	s.affect(incident, instance)
+	incident.Timeline = append(incident.Timeline, update)
+	incident.UpdatedAt = now
+	if err := s.incidentRepo.Update(ctx, incident); err != nil {
+		s.logger.Error("Failed to update incident", zap.String("incident_id", incident.ID.String()), zap.Error(err))
+	}
+}
+
+// InstanceRecovered records an instance passing health checks again. The
+// incident it belongs to is resolved once no affected instance is failing.
+func (s *IncidentService) InstanceRecovered(ctx context.Context, instance *domain.ProxyInstance, now time.Time) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	incident, err := s.unresolved(ctx, s.region(instance))
+	if err != nil {
+		s.logger.Error("Failed to look up incidents", zap.Error(err))
+		return
+	}
+	if incident == nil || !containsID(incident.Unhealthy, instance.ID) {
+		return
+	}
+
+	incident.Unhealthy = removeID(incident.Unhealthy, instance.ID)
+	incident.Timeline = append(incident.Timeline, domain.IncidentUpdate{
+		At:         now,
+		Status:     incident.Status,
+		Message:    "Instance recovered",
+		PlanID:     &instance.PlanID,
+		InstanceID: &instance.ID,
+	})
+	if len(incident.Unhealthy) == 0 {
+		s.resolve(incident, "", "All affected instances recovered.", true, now)
+	}
+	incident.UpdatedAt = now
+
+	if err := s.incidentRepo.Update(ctx, incident); err != nil {
+		s.logger.Error("Failed to update incident", zap.String("incident_id", incident.ID.String()), zap.Error(err))
+		return
+	}
+	if incident.Status == domain.IncidentResolved {
+		s.logger.Info("Incident resolved", zap.String("incident_id", incident.ID.String()))
+	}
+}
+
+// List returns incidents newest first, optionally only those in status
+func (s *IncidentService) List(ctx context.Context, status string) ([]*domain.Incident, error) {
+	incidents, err := s.incidentRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if status == "" {
+		return incidents, nil
+	}
+
+	filtered := []*domain.Incident{}
+	for _, incident := range incidents {
+		if incident.Status == status {
+			filtered = append(filtered, incident)
+		}
+	}
+	return filtered, nil
+}
+
+// Get returns an incident
+func (s *IncidentService) Get(ctx context.Context, id uuid.UUID) (*domain.Incident, error) {
+	return s.incidentRepo.GetByID(ctx, id)
+}
+
+// Acknowledge marks an open incident as being worked on
+func (s *IncidentService) Acknowledge(ctx context.Context, id uuid.UUID, note *domain.IncidentNoteRequest, now time.Time) (*domain.Incident, error) {
+	return s.change(ctx, id, now, func(incident *domain.Incident) error {
+		if incident.Status != domain.IncidentOpen {
+			return fmt.Errorf("%w: only open incidents can be acknowledged, this one is %s", ErrIncidentTransition, incident.Status)
+		}
+		incident.Status = domain.IncidentAcknowledged
+		incident.AcknowledgedAt = &now
+		message := note.Message
+		if message == "" {
+			message = "Incident acknowledged"
+		}
+		incident.Timeline = append(incident.Timeline, domain.IncidentUpdate{
+			At:      now,
+			Status:  domain.IncidentAcknowledged,
+			Author:  note.Author,
+			Message: message,
+			Public:  note.Public,
+		})
+		return nil
+	})
+}
+
+// Resolve closes an incident. Instances still failing stay failed; if they
+// fail again after recovering, a new incident is opened.
+func (s *IncidentService) Resolve(ctx context.Context, id uuid.UUID, note *domain.IncidentNoteRequest, now time.Time) (*domain.Incident, error) {
+	return s.change(ctx, id, now, func(incident *domain.Incident) error {
+		if incident.Status == domain.IncidentResolved {
+			return fmt.Errorf("%w: the incident is already resolved", ErrIncidentTransition)
+		}
+		message := note.Message
+		if message == "" {
+			message = "Incident resolved"
+		}
+		s.resolve(incident, note.Author, message, note.Public, now)
+		return nil
+	})
+}
+
+// Annotate adds a note to an incident's timeline
+func (s *IncidentService) Annotate(ctx context.Context, id uuid.UUID, note *domain.IncidentNoteRequest, now time.Time) (*domain.Incident, error) {
+	return s.change(ctx, id, now, func(incident *domain.Incident) error {
+		incident.Timeline = append(incident.Timeline, domain.IncidentUpdate{
+			At:      now,
+			Status:  incident.Status,
+			Author:  note.Author,
+			Message: note.Message,
+			Public:  note.Public,
+		})
+		return nil
+	})
+}
+
+// Public returns the incidents for the status page: every unresolved one
+// and those resolved since
+func (s *IncidentService) Public(ctx context.Context, since time.Time) ([]domain.PublicIncident, error) {
+	if s == nil {
+		return []domain.PublicIncident{}, nil
+	}
+
+	incidents, err := s.incidentRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incidents: %w", err)
+	}
+
+	public := []domain.PublicIncident{}
+	for _, incident := range incidents {
+		if incident.ResolvedAt != nil && incident.ResolvedAt.Before(since) {
+			continue
+		}
+		public = append(public, incident.Public())
+	}
+	return public, nil
+}
+
+// change applies fn to an incident and saves it
+func (s *IncidentService) change(ctx context.Context, id uuid.UUID, now time.Time, fn func(*domain.Incident) error) (*domain.Incident, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	incident, err := s.incidentRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(incident); err != nil {
+		return nil, err
+	}
+	incident.UpdatedAt = now
+	if err := s.incidentRepo.Update(ctx, incident); err != nil {
+		return nil, fmt.Errorf("failed to update incident: %w", err)
+	}
+	return incident, nil
+}
+
+func (s *IncidentService) resolve(incident *domain.Incident, author, message string, public bool, now time.Time) {
+	incident.Status = domain.IncidentResolved
+	incident.ResolvedAt = &now
+	incident.Timeline = append(incident.Timeline, domain.IncidentUpdate{
+		At:      now,
+		Status:  domain.IncidentResolved,
+		Author:  author,
+		Message: message,
+		Public:  public,
+	})
+}
+
+// unresolved returns the region's unresolved incident, if any
+func (s *IncidentService) unresolved(ctx context.Context, region string) (*domain.Incident, error) {
+	incidents, err := s.incidentRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, incident := range incidents {
+		if incident.Region == region && incident.Status != domain.IncidentResolved {
+			return incident, nil
+		}
+	}
+	return nil, nil
+}
+
+// affect adds an unhealthy instance and its plan to an incident
+func (s *IncidentService) affect(incident *domain.Incident, instance *domain.ProxyInstance) {
+	if !containsID(incident.PlanIDs, instance.PlanID) {
+		incident.PlanIDs = append(incident.PlanIDs, instance.PlanID)
+	}
+	if !containsID(incident.InstanceIDs, instance.ID) {
+		incident.InstanceIDs = append(incident.InstanceIDs, instance.ID)
+	}
+	if !containsID(incident.Unhealthy, instance.ID) {
+		incident.Unhealthy = append(incident.Unhealthy, instance.ID)
+	}
+}
+
+// region returns the region of an instance's plan type
+func (s *IncidentService) region(instance *domain.ProxyInstance) string {
+	if planType := s.planTypes.Get(instance.PlanTypeKey); planType != nil {
+		return planType.Region
+	}
+	return instance.PlanTypeKey
+}
+
+func containsID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
+
+func removeID(ids []uuid.UUID, id uuid.UUID) []uuid.UUID {
+	kept := ids[:0]
+	for _, existing := range ids {
+		if existing != id {
+			kept = append(kept, existing)
+		}
+	}
+	return kept
+}
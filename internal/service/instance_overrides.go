@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// InstanceOverrides sets the flags operators use to keep automation away
+// from an instance while they debug it or work around upstream quirks.
+// Putting an instance in maintenance drains it from its nginx upstream and
+// clearing the flag adds it back; the health monitor, cleanup, geo
+// verification and alerting read the flags from the instance. Automated
+// restarts skip an instance in maintenance, so clearing the flag restarts a
+// running instance to apply the config changes it missed.
+type InstanceOverrides struct {
+	logger       *zap.Logger
+	instanceRepo repository.InstanceRepository
+	proxyService ProxyService
+	nginx        *NginxManager
+	events       *eventRecorder
+}
+
+// NewInstanceOverrides creates a new instance override service
+func NewInstanceOverrides(
+	logger *zap.Logger,
+	instanceRepo repository.InstanceRepository,
+	eventRepo repository.PlanEventRepository,
+	proxyService ProxyService,
+	nginx *NginxManager,
+) *InstanceOverrides {
+	return &InstanceOverrides{
+		logger:       logger,
+		instanceRepo: instanceRepo,
+		proxyService: proxyService,
+		nginx:        nginx,
+		events:       newEventRecorder(eventRepo, logger),
+	}
+}
+
+// Set replaces the overrides of an instance and moves it into or out of
+// its nginx upstream to match. The overrides are kept when nginx cannot be
+// updated; the error says so.
+func (o *InstanceOverrides) Set(ctx context.Context, instanceID uuid.UUID, req *domain.InstanceOverridesRequest) (*domain.ProxyInstance, error) {
+	instance, err := o.instanceRepo.GetByID(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	wasInRotation, wasInMaintenance := instance.InRotation(), instance.Maintenance
+	instance.Maintenance = req.Maintenance
+	instance.NoAutoheal = req.NoAutoheal
+	instance.OverrideNote = req.Note
+	if err := o.instanceRepo.Update(ctx, instance); err != nil {
+		return nil, fmt.Errorf("failed to update instance: %w", err)
+	}
+
+	o.events.record(ctx, instance.PlanID, &instance.ID, domain.EventInstanceOverridesSet, "Instance overrides set", map[string]string{
+		"maintenance": fmt.Sprint(instance.Maintenance),
+		"no_autoheal": fmt.Sprint(instance.NoAutoheal),
+		"note":        instance.OverrideNote,
+	})
+	o.logger.Info("Set instance overrides",
+		zap.String("instance_id", instance.ID.String()),
+		zap.Bool("maintenance", instance.Maintenance),
+		zap.Bool("no_autoheal", instance.NoAutoheal),
+		zap.String("note", instance.OverrideNote),
+	)
+
+	// Restart before adding the instance back, so no traffic reaches the
+	// config it had in maintenance
+	if wasInMaintenance && !instance.Maintenance && instance.Status == domain.InstanceStatusRunning {
+		if err := o.proxyService.RestartInstance(ctx, instance.ID); err != nil {
+			return instance, fmt.Errorf("overrides set, but failed to restart instance out of maintenance: %w", err)
+		}
+	}
+
+	switch inRotation := instance.InRotation(); {
+	case wasInRotation && !inRotation:
+		if err := o.nginx.RemoveFromUpstream(ctx, instance); err != nil {
+			return instance, fmt.Errorf("overrides set, but failed to drain instance from nginx: %w", err)
+		}
+	case !wasInRotation && inRotation:
+		if err := o.nginx.UpdateUpstream(ctx, instance); err != nil {
+			return instance, fmt.Errorf("overrides set, but failed to add instance back to nginx: %w", err)
+		}
+	}
+
+	return instance, nil
+}
+
+// restartAutomated restarts an instance for a change automation made, such
+// as a suspension or a new ban. An instance in maintenance is left alone and
+// picks the change up when InstanceOverrides takes it out of maintenance.
+func restartAutomated(ctx context.Context, proxyService ProxyService, logger *zap.Logger, instance *domain.ProxyInstance, reason string) error {
+	if instance.Maintenance {
+		logger.Info("Deferred restart of instance in maintenance",
+			zap.String("instance_id", instance.ID.String()),
+			zap.String("reason", reason))
+		return nil
+	}
+	return proxyService.RestartInstance(ctx, instance.ID)
+}
@@ -3,9 +3,11 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/pkg/config"
 )
 
 // PlanService defines the interface for plan management
@@ -16,7 +18,20 @@ type PlanService interface {
 	GetAllPlans(ctx context.Context) ([]*domain.ProxyPlan, error)
 	UpdatePlanStatus(ctx context.Context, planID uuid.UUID, status string) error
 	DeletePlan(ctx context.Context, planID uuid.UUID) error
+	TopUpPlan(ctx context.Context, planID uuid.UUID, bandwidthGB int) (*domain.ProxyPlan, error)
+	GetPlanEndpoints(ctx context.Context, planID uuid.UUID) ([]domain.ProxyEndpoint, error)
+	GetPlanEvents(ctx context.Context, planID uuid.UUID) ([]*domain.PlanEvent, error)
+	ClonePlan(ctx context.Context, planID uuid.UUID, customerID string) (*domain.CreatePlanResponse, error)
+	SetAutoRenew(ctx context.Context, planID uuid.UUID, enabled bool) (*domain.ProxyPlan, error)
+	SetExpiryAction(ctx context.Context, planID uuid.UUID, action string) (*domain.ProxyPlan, error)
+	SetAllowedDestinations(ctx context.Context, planID uuid.UUID, destinations []string) (*domain.ProxyPlan, error)
+	SetHeaderPolicy(ctx context.Context, planID uuid.UUID, policy *domain.HeaderPolicy) (*domain.ProxyPlan, error)
+	SetResponseCache(ctx context.Context, planID uuid.UUID, req *domain.ResponseCacheRequest) (*domain.ProxyPlan, error)
+	PurgeResponseCache(ctx context.Context, planID uuid.UUID) (*domain.ProxyPlan, error)
+	RotatePassword(ctx context.Context, planID uuid.UUID, password string) (*domain.ProxyPlan, error)
 	CheckExpiredPlans(ctx context.Context) ([]*domain.ProxyPlan, error)
+	GetExpiringPlans(ctx context.Context, within time.Duration, customerID string) ([]*domain.ProxyPlan, error)
+	PlansVersion(ctx context.Context) (uint64, error)
 }
 
 // ProxyService defines the interface for proxy instance management
@@ -29,6 +44,7 @@ type ProxyService interface {
 	GetInstance(ctx context.Context, instanceID uuid.UUID) (*domain.ProxyInstance, error)
 	GetInstancesByPlan(ctx context.Context, planID uuid.UUID) ([]*domain.ProxyInstance, error)
 	HealthCheck(ctx context.Context, instanceID uuid.UUID) error
+	InstancesVersion(ctx context.Context) (uint64, error)
 }
 
 // ProviderService defines the interface for upstream provider integration
@@ -37,6 +53,20 @@ type ProviderService interface {
 	GetAccountInfo(ctx context.Context, provider, accountID string) (*ProviderAccount, error)
 	DeleteAccount(ctx context.Context, provider, accountID string) error
 	TestConnection(ctx context.Context, provider string, account *ProviderAccount) error
+	TopUpAccount(ctx context.Context, provider, accountID string, bandwidthGB int) error
+	Capabilities(provider, planType string) (domain.PlanFeatures, bool)
+	Reconfigure(cfg config.Providers)
+}
+
+// ProviderAccountService manages upstream provider accounts independently of plans
+type ProviderAccountService interface {
+	AcquireAccount(ctx context.Context, req *domain.CreatePlanRequest, planTypeKey string, planID uuid.UUID) (*domain.ProviderAccount, error)
+	ReleasePlan(ctx context.Context, planID uuid.UUID) error
+	GetAccount(ctx context.Context, accountID uuid.UUID) (*domain.ProviderAccount, error)
+	ListAccounts(ctx context.Context, provider, customerID string) ([]*domain.ProviderAccount, error)
+	RenewAccount(ctx context.Context, accountID uuid.UUID, bandwidthGB int) (*domain.ProviderAccount, error)
+	SyncUsage(ctx context.Context, accountID uuid.UUID) (*domain.ProviderAccount, error)
+	DeleteAccount(ctx context.Context, accountID uuid.UUID) error
 }
 
 // ProviderAccount represents an account with an upstream provider
@@ -48,6 +78,10 @@ type ProviderAccount struct {
 	Host     string `json:"host"`
 	Port     int    `json:"port"`
 	Region   string `json:"region"`
+
+	// Usage, when the provider reports it
+	MaxBytes  int64 `json:"max_bytes,omitempty"`
+	UsedBytes int64 `json:"used_bytes,omitempty"`
 }
 
 // PoolStats represents statistics for a port pool
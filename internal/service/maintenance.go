@@ -0,0 +1,417 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+var (
+	// ErrMaintenanceNotFound is returned for a maintenance window that does not exist
+	ErrMaintenanceNotFound = errors.New("maintenance window not found")
+	// ErrInvalidMaintenance is returned for a maintenance window that fails validation
+	ErrInvalidMaintenance = errors.New("invalid maintenance window")
+)
+
+// maxFeedBytes bounds how much of a maintenance feed is read
+const maxFeedBytes = 4 << 20
+
+// MaintenanceService keeps the calendar of scheduled provider maintenance,
+// registered by operators or read from provider status feeds. Other
+// services ask it whether a plan type is under maintenance to suppress
+// alerts and annotate health check failures.
+type MaintenanceService struct {
+	cfg       config.Maintenance
+	logger    *zap.Logger
+	repo      repository.MaintenanceRepository
+	planTypes *PlanTypeRegistry
+	client    *http.Client
+
+	// windows caches the repository for the lookups made on every check
+	mu      sync.RWMutex
+	windows []*domain.MaintenanceWindow
+	loaded  bool
+}
+
+// NewMaintenanceService creates a new maintenance service
+func NewMaintenanceService(
+	cfg *config.Config,
+	logger *zap.Logger,
+	repo repository.MaintenanceRepository,
+	planTypes *PlanTypeRegistry,
+) *MaintenanceService {
+	return &MaintenanceService{
+		cfg:       cfg.Maintenance,
+		logger:    logger,
+		repo:      repo,
+		planTypes: planTypes,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Run fetches the feeds and prunes old windows every fetch interval until
+// ctx is cancelled
+func (s *MaintenanceService) Run(ctx context.Context) {
+	if s.cfg.FetchInterval <= 0 {
+		return
+	}
+
+	s.logger.Info("Starting maintenance calendar sync",
+		zap.Int("feeds", len(s.cfg.Feeds)),
+		zap.Duration("interval", s.cfg.FetchInterval))
+
+	ticker := time.NewTicker(s.cfg.FetchInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Sync(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			s.logger.Error("Failed to sync maintenance calendar", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync replaces each feed's windows with the ones it lists now and removes
+// windows that ended more than the retention ago. A feed that cannot be
+// fetched keeps its previous windows.
+func (s *MaintenanceService) Sync(ctx context.Context, now time.Time) error {
+	current, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	created := make(map[uuid.UUID]time.Time, len(current))
+	for _, window := range current {
+		created[window.ID] = window.CreatedAt
+	}
+
+	for _, feed := range s.cfg.Feeds {
+		windows, err := s.fetch(ctx, feed)
+		if err != nil {
+			s.logger.Warn("Failed to fetch maintenance feed",
+				zap.String("feed", feed.Name),
+				zap.Error(err))
+			continue
+		}
+		for _, window := range windows {
+			window.CreatedAt = now
+			if at, exists := created[window.ID]; exists {
+				window.CreatedAt = at
+			}
+		}
+		if err := s.repo.ReplaceSource(ctx, feed.Name, windows); err != nil {
+			return err
+		}
+		s.logger.Debug("Maintenance feed synced",
+			zap.String("feed", feed.Name),
+			zap.Int("windows", len(windows)))
+	}
+
+	if s.cfg.Retention > 0 {
+		if _, err := s.repo.DeleteEndedBefore(ctx, now.Add(-s.cfg.Retention)); err != nil {
+			return err
+		}
+	}
+
+	return s.reload(ctx)
+}
+
+// List returns the maintenance windows by start time; with inProgress only
+// those in progress at now
+func (s *MaintenanceService) List(ctx context.Context, inProgress bool, now time.Time) ([]*domain.MaintenanceWindow, error) {
+	windows, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !inProgress {
+		return windows, nil
+	}
+
+	active := []*domain.MaintenanceWindow{}
+	for _, window := range windows {
+		if window.InProgress(now) {
+			active = append(active, window)
+		}
+	}
+	return active, nil
+}
+
+// Create registers a maintenance window by hand
+func (s *MaintenanceService) Create(ctx context.Context, req *domain.CreateMaintenanceRequest, now time.Time) (*domain.MaintenanceWindow, error) {
+	if strings.TrimSpace(req.Summary) == "" {
+		return nil, fmt.Errorf("%w: summary is required", ErrInvalidMaintenance)
+	}
+	if req.StartsAt.IsZero() || !req.EndsAt.After(req.StartsAt) {
+		return nil, fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidMaintenance)
+	}
+	if !req.EndsAt.After(now) {
+		return nil, fmt.Errorf("%w: the window has already ended", ErrInvalidMaintenance)
+	}
+
+	known := false
+	for _, planType := range s.planTypes.All() {
+		if planType.Provider == req.Provider {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return nil, fmt.Errorf("%w: no plan type uses provider %q", ErrInvalidMaintenance, req.Provider)
+	}
+	for _, key := range req.PlanTypes {
+		planType := s.planTypes.Get(key)
+		if planType == nil || planType.Provider != req.Provider {
+			return nil, fmt.Errorf("%w: %q is not a plan type of provider %s", ErrInvalidMaintenance, key, req.Provider)
+		}
+	}
+
+	window := &domain.MaintenanceWindow{
+		ID:        uuid.New(),
+		Provider:  req.Provider,
+		PlanTypes: req.PlanTypes,
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.EndsAt,
+		Summary:   req.Summary,
+		URL:       req.URL,
+		Source:    domain.MaintenanceSourceManual,
+		CreatedAt: now,
+	}
+	if err := s.repo.Create(ctx, window); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Maintenance window registered",
+		zap.String("id", window.ID.String()),
+		zap.String("provider", window.Provider),
+		zap.Time("starts_at", window.StartsAt),
+		zap.Time("ends_at", window.EndsAt))
+
+	return window, s.reload(ctx)
+}
+
+// Delete removes a maintenance window. Windows read from a feed come back
+// at the next sync while the feed still lists them.
+func (s *MaintenanceService) Delete(ctx context.Context, id uuid.UUID) error {
+	windows, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, window := range windows {
+		if window.ID == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ErrMaintenanceNotFound
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	return s.reload(ctx)
+}
+
+// Active returns the maintenance window covering the plan type at now, or
+// nil when there is none or the calendar cannot be read
+func (s *MaintenanceService) Active(ctx context.Context, planTypeKey string, now time.Time) *domain.MaintenanceWindow {
+	if s == nil {
+		return nil
+	}
+	planType := s.planTypes.Get(planTypeKey)
+	if planType == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	loaded := s.loaded
+	s.mu.RUnlock()
+	if !loaded {
+		if err := s.reload(ctx); err != nil {
+			s.logger.Warn("Failed to load maintenance windows", zap.Error(err))
+			return nil
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, window := range s.windows {
+		if window.InProgress(now) && window.Covers(planType.Provider, planTypeKey) {
+			return window
+		}
+	}
+	return nil
+}
+
+func (s *MaintenanceService) reload(ctx context.Context) error {
+	windows, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.windows = windows
+	s.loaded = true
+	s.mu.Unlock()
+	return nil
+}
+
+// maintenanceNote describes a window for health check annotations
+func maintenanceNote(window *domain.MaintenanceWindow) string {
+	return fmt.Sprintf("during %s maintenance until %s: %s",
+		window.Provider, window.EndsAt.UTC().Format(time.RFC3339), window.Summary)
+}
+
+// maintenanceData returns the event data annotating a failure with window
+func maintenanceData(window *domain.MaintenanceWindow) map[string]string {
+	return map[string]string{
+		"maintenance_id":      window.ID.String(),
+		"maintenance_summary": window.Summary,
+		"maintenance_ends_at": window.EndsAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// fetch reads the windows a feed lists now
+func (s *MaintenanceService) fetch(ctx context.Context, feed config.MaintenanceFeed) ([]*domain.MaintenanceWindow, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+
+	if feed.Format == config.MaintenanceFeedRSS {
+		return parseRSSMaintenance(feed, body)
+	}
+	return parseStatuspageMaintenance(feed, body)
+}
+
+// feedWindow returns a window of feed with an ID that stays the same
+// across syncs
+func feedWindow(feed config.MaintenanceFeed, externalID string) *domain.MaintenanceWindow {
+	return &domain.MaintenanceWindow{
+		ID:         uuid.NewSHA1(uuid.NameSpaceURL, []byte(feed.Name+"/"+externalID)),
+		Provider:   feed.Provider,
+		PlanTypes:  feed.PlanTypes,
+		Source:     feed.Name,
+		ExternalID: externalID,
+	}
+}
+
+// parseStatuspageMaintenance reads a Statuspage scheduled-maintenances.json.
+// Completed maintenance is left out so its window ends when it does.
+func parseStatuspageMaintenance(feed config.MaintenanceFeed, body []byte) ([]*domain.MaintenanceWindow, error) {
+	var page struct {
+		ScheduledMaintenances []struct {
+			ID             string    `json:"id"`
+			Name           string    `json:"name"`
+			Status         string    `json:"status"`
+			Shortlink      string    `json:"shortlink"`
+			ScheduledFor   time.Time `json:"scheduled_for"`
+			ScheduledUntil time.Time `json:"scheduled_until"`
+		} `json:"scheduled_maintenances"`
+	}
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, fmt.Errorf("failed to parse Statuspage feed: %w", err)
+	}
+
+	windows := []*domain.MaintenanceWindow{}
+	for _, item := range page.ScheduledMaintenances {
+		if item.ID == "" || item.Status == "completed" || !item.ScheduledUntil.After(item.ScheduledFor) {
+			continue
+		}
+		window := feedWindow(feed, item.ID)
+		window.StartsAt = item.ScheduledFor
+		window.EndsAt = item.ScheduledUntil
+		window.Summary = item.Name
+		window.URL = item.Shortlink
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// parseRSSMaintenance reads an RSS feed; only items with the event module's
+// ev:startdate and ev:enddate are maintenance windows
+func parseRSSMaintenance(feed config.MaintenanceFeed, body []byte) ([]*domain.MaintenanceWindow, error) {
+	var rss struct {
+		Items []struct {
+			Title     string `xml:"title"`
+			Link      string `xml:"link"`
+			GUID      string `xml:"guid"`
+			StartDate string `xml:"http://purl.org/rss/1.0/modules/event/ startdate"`
+			EndDate   string `xml:"http://purl.org/rss/1.0/modules/event/ enddate"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(body, &rss); err != nil {
+		return nil, fmt.Errorf("failed to parse RSS feed: %w", err)
+	}
+
+	windows := []*domain.MaintenanceWindow{}
+	for _, item := range rss.Items {
+		starts, startErr := parseFeedTime(item.StartDate)
+		ends, endErr := parseFeedTime(item.EndDate)
+		if startErr != nil || endErr != nil || !ends.After(starts) {
+			continue
+		}
+
+		externalID := strings.TrimSpace(item.GUID)
+		if externalID == "" {
+			externalID = strings.TrimSpace(item.Link)
+		}
+		if externalID == "" {
+			externalID = starts.UTC().Format(time.RFC3339) + " " + strings.TrimSpace(item.Title)
+		}
+
+		window := feedWindow(feed, externalID)
+		window.StartsAt = starts
+		window.EndsAt = ends
+		window.Summary = strings.TrimSpace(item.Title)
+		window.URL = strings.TrimSpace(item.Link)
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// parseFeedTime parses the W3C date-times of the RSS event module, or RFC
+// 1123 dates as used elsewhere in RSS
+func parseFeedTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC1123Z, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC1123, value)
+}
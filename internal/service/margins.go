@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+// NegativeMargins returns a warning for every plan type whose upstream cost
+// exceeds its retail price, sorted by key
+func (r *PlanTypeRegistry) NegativeMargins() []domain.MarginWarning {
+	var warnings []domain.MarginWarning
+	for _, key := range r.Keys() {
+		planType := r.Get(key)
+		if planType == nil || planType.Pricing == nil || !planType.Pricing.Negative() {
+			continue
+		}
+		warnings = append(warnings, domain.NewMarginWarning(key, planType))
+	}
+	return warnings
+}
+
+// GetMargins reports the revenue, upstream cost and margin of the bandwidth
+// of plans created in [from, to), in total and per provider and region, at
+// the plan types' current prices less voucher discounts. Plans of plan types without pricing are
+// counted but left out of the totals.
+func (s *StatsService) GetMargins(ctx context.Context, from, to time.Time) (*domain.MarginReport, error) {
+	plans, err := s.planRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plans: %w", err)
+	}
+
+	report := &domain.MarginReport{
+		From:       from,
+		To:         to,
+		ByProvider: make(map[string]*domain.Margin),
+		ByRegion:   make(map[string]*domain.Margin),
+		Warnings:   s.planTypes.NegativeMargins(),
+	}
+
+	unpriced := make(map[string]bool)
+	for _, plan := range plans {
+		if plan.CreatedAt.Before(from) || !plan.CreatedAt.Before(to) {
+			continue
+		}
+
+		planType := s.planTypes.Get(plan.PlanTypeKey)
+		if planType == nil || planType.Pricing == nil {
+			report.UnpricedPlans++
+			unpriced[plan.PlanTypeKey] = true
+			continue
+		}
+
+		var discount float64
+		if plan.Voucher != nil {
+			discount = plan.Voucher.DiscountPercent
+		}
+		report.Total.Add(plan.Bandwidth, planType.Pricing, discount)
+		addMargin(report.ByProvider, plan.Provider, plan.Bandwidth, planType.Pricing, discount)
+		addMargin(report.ByRegion, plan.Region, plan.Bandwidth, planType.Pricing, discount)
+	}
+
+	for key := range unpriced {
+		report.UnpricedPlanTypes = append(report.UnpricedPlanTypes, key)
+	}
+	sort.Strings(report.UnpricedPlanTypes)
+
+	return report, nil
+}
+
+// addMargin counts a plan in the margin of group name
+func addMargin(margins map[string]*domain.Margin, name string, bandwidthGB int, pricing *domain.Pricing, discountPercent float64) {
+	margin, exists := margins[name]
+	if !exists {
+		margin = &domain.Margin{}
+		margins[name] = margin
+	}
+	margin.Add(bandwidthGB, pricing, discountPercent)
+}
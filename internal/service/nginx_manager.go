@@ -1,12 +1,14 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
 	"text/template"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -18,8 +20,8 @@ import (
 type NginxManager struct {
 	logger      *zap.Logger
 	cfg         *config.Config
-	regions     map[string]*domain.Region
-	planTypes   map[string]*domain.PlanTypeConfig
+	regions     *RegionRegistry
+	planTypes   *PlanTypeRegistry
 	configDir   string
 	templateDir string
 }
@@ -28,8 +30,8 @@ type NginxManager struct {
 func NewNginxManager(
 	logger *zap.Logger,
 	cfg *config.Config,
-	regions map[string]*domain.Region,
-	planTypes map[string]*domain.PlanTypeConfig,
+	regions *RegionRegistry,
+	planTypes *PlanTypeRegistry,
 ) *NginxManager {
 	return &NginxManager{
 		logger:      logger,
@@ -41,16 +43,17 @@ func NewNginxManager(
 	}
 }
 
-// UpdateUpstream adds a new server to an nginx upstream
-func (nm *NginxManager) UpdateUpstream(ctx context.Context, planTypeKey string, localPort int) error {
-	planType, exists := nm.planTypes[planTypeKey]
+// UpdateUpstream adds an instance to its plan type's nginx upstream
+func (nm *NginxManager) UpdateUpstream(ctx context.Context, instance *domain.ProxyInstance) error {
+	planTypeKey := instance.PlanTypeKey
+	planType, exists := nm.planTypes.Lookup(planTypeKey)
 	if !exists {
-		return fmt.Errorf("plan type %s not found", planTypeKey)
+		return domain.NotFoundf("plan type %s not found", planTypeKey)
 	}
 
-	region, exists := nm.regions[planType.Region]
-	if !exists {
-		return fmt.Errorf("region %s not found", planType.Region)
+	region := nm.regions.Get(planType.Region)
+	if region == nil {
+		return domain.NotFoundf("region %s not found", planType.Region)
 	}
 
 	configFile := filepath.Join(nm.configDir, region.NginxConfigFile)
@@ -63,52 +66,53 @@ func (nm *NginxManager) UpdateUpstream(ctx context.Context, planTypeKey string,
 	}
 
 	// Add server to upstream
-	if err := nm.addServerToUpstream(configFile, planType.NginxUpstreamName, localPort); err != nil {
+	if err := nm.addServerToUpstream(ctx, configFile, planType.NginxUpstreamName, instance.LocalAddress()); err != nil {
 		return fmt.Errorf("failed to add server to upstream: %w", err)
 	}
 
 	// Test and reload nginx
-	if err := nm.testAndReloadNginx(); err != nil {
+	if err := nm.testAndReloadNginx(ctx); err != nil {
 		return fmt.Errorf("failed to reload nginx: %w", err)
 	}
 
 	nm.logger.Info("Updated nginx upstream",
 		zap.String("plan_type", planTypeKey),
 		zap.String("upstream", planType.NginxUpstreamName),
-		zap.Int("local_port", localPort),
+		zap.String("address", instance.LocalAddress()),
 	)
 
 	return nil
 }
 
-// RemoveFromUpstream removes a server from an nginx upstream
-func (nm *NginxManager) RemoveFromUpstream(ctx context.Context, planTypeKey string, localPort int) error {
-	planType, exists := nm.planTypes[planTypeKey]
+// RemoveFromUpstream removes an instance from its plan type's nginx upstream
+func (nm *NginxManager) RemoveFromUpstream(ctx context.Context, instance *domain.ProxyInstance) error {
+	planTypeKey := instance.PlanTypeKey
+	planType, exists := nm.planTypes.Lookup(planTypeKey)
 	if !exists {
-		return fmt.Errorf("plan type %s not found", planTypeKey)
+		return domain.NotFoundf("plan type %s not found", planTypeKey)
 	}
 
-	region, exists := nm.regions[planType.Region]
-	if !exists {
-		return fmt.Errorf("region %s not found", planType.Region)
+	region := nm.regions.Get(planType.Region)
+	if region == nil {
+		return domain.NotFoundf("region %s not found", planType.Region)
 	}
 
 	configFile := filepath.Join(nm.configDir, region.NginxConfigFile)
 
 	// Remove server from upstream
-	if err := nm.removeServerFromUpstream(configFile, planType.NginxUpstreamName, localPort); err != nil {
+	if err := nm.removeServerFromUpstream(ctx, configFile, planType.NginxUpstreamName, instance.LocalAddress()); err != nil {
 		return fmt.Errorf("failed to remove server from upstream: %w", err)
 	}
 
 	// Test and reload nginx
-	if err := nm.testAndReloadNginx(); err != nil {
+	if err := nm.testAndReloadNginx(ctx); err != nil {
 		return fmt.Errorf("failed to reload nginx: %w", err)
 	}
 
 	nm.logger.Info("Removed from nginx upstream",
 		zap.String("plan_type", planTypeKey),
 		zap.String("upstream", planType.NginxUpstreamName),
-		zap.Int("local_port", localPort),
+		zap.String("address", instance.LocalAddress()),
 	)
 
 	return nil
@@ -125,104 +129,125 @@ func (nm *NginxManager) createRegionConfig(region *domain.Region) error {
 		return fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	// Get plan types for this region
+	content, err := renderRegionConfig(tmpl, nm.regionTemplateData(region))
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(configFile, content, 0644); err != nil {
+		return fmt.Errorf("failed to create config file: %w", err)
+	}
+
+	nm.logger.Info("Created nginx region config",
+		zap.String("region", region.Name),
+		zap.String("config_file", configFile),
+	)
+
+	return nil
+}
+
+// regionTemplateData lists the upstreams of a region's plan types. The
+// region's server relays every plan type, so it keeps connections for the
+// longest idle timeout; 3proxy closes each plan type's own.
+func (nm *NginxManager) regionTemplateData(region *domain.Region) *RegionTemplateData {
 	var upstreams []UpstreamConfig
+	idleTimeout := 0
 	for _, planTypeKey := range region.PlanTypes {
-		if planType, exists := nm.planTypes[planTypeKey]; exists {
+		if planType, exists := nm.planTypes.Lookup(planTypeKey); exists {
 			upstreams = append(upstreams, UpstreamConfig{
 				Name:     planType.NginxUpstreamName,
 				PlanType: planTypeKey,
 			})
+			if planType.Connections != nil {
+				if seconds := int(planType.Connections.IdleTimeout / time.Second); seconds > idleTimeout {
+					idleTimeout = seconds
+				}
+			}
 		}
 	}
 
-	data := RegionTemplateData{
-		Region:    region,
-		Upstreams: upstreams,
-	}
-
-	// Create config file
-	file, err := os.Create(configFile)
-	if err != nil {
-		return fmt.Errorf("failed to create config file: %w", err)
+	return &RegionTemplateData{
+		Region:      region,
+		Upstreams:   upstreams,
+		IdleTimeout: idleTimeout,
 	}
-	defer file.Close()
+}
 
-	if err := tmpl.Execute(file, data); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+// renderRegionConfig executes the stream template for a region
+func renderRegionConfig(tmpl *template.Template, data *RegionTemplateData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
 	}
-
-	nm.logger.Info("Created nginx region config",
-		zap.String("region", region.Name),
-		zap.String("config_file", configFile),
-	)
-
-	return nil
+	return buf.Bytes(), nil
 }
 
-// addServerToUpstream adds a server to an nginx upstream
-func (nm *NginxManager) addServerToUpstream(configFile, upstreamName string, port int) error {
+// addServerToUpstream adds a server address to an nginx upstream
+func (nm *NginxManager) addServerToUpstream(ctx context.Context, configFile, upstreamName, address string) error {
 	// Read current config
 	content, err := os.ReadFile(configFile)
 	if err != nil {
 		return err
 	}
 
-	serverLine := fmt.Sprintf("    server 127.0.0.1:%d;", port)
+	serverLine := fmt.Sprintf("    server %s;", address)
 
 	// Check if server already exists
 	if contains(string(content), serverLine) {
 		nm.logger.Debug("Server already exists in upstream",
 			zap.String("upstream", upstreamName),
-			zap.Int("port", port),
+			zap.String("address", address),
 		)
 		return nil
 	}
 
 	// Use sed to add server to upstream
-	cmd := exec.Command("sed", "-i",
-		fmt.Sprintf("/upstream %s {/a\\    server 127.0.0.1:%d;", upstreamName, port),
+	_, err = runCommand(ctx, nm.cfg.Timeouts.Exec, "sed", "-i",
+		fmt.Sprintf("/upstream %s {/a\\%s", upstreamName, serverLine),
 		configFile,
 	)
-
-	if err := cmd.Run(); err != nil {
+	if err != nil {
 		return fmt.Errorf("failed to add server to upstream: %w", err)
 	}
 
 	return nil
 }
 
-// removeServerFromUpstream removes a server from an nginx upstream
-func (nm *NginxManager) removeServerFromUpstream(configFile, upstreamName string, port int) error {
-	serverLine := fmt.Sprintf("    server 127.0.0.1:%d;", port)
+// removeServerFromUpstream removes a server address from an nginx upstream
+func (nm *NginxManager) removeServerFromUpstream(ctx context.Context, configFile, upstreamName, address string) error {
+	// Dots and IPv6 brackets would otherwise match other addresses
+	serverLine := regexp.QuoteMeta(fmt.Sprintf("    server %s;", address))
 
 	// Use sed to remove server from upstream
-	cmd := exec.Command("sed", "-i",
+	_, err := runCommand(ctx, nm.cfg.Timeouts.Exec, "sed", "-i",
 		fmt.Sprintf("/%s/d", serverLine),
 		configFile,
 	)
-
-	if err := cmd.Run(); err != nil {
+	if err != nil {
 		return fmt.Errorf("failed to remove server from upstream: %w", err)
 	}
 
 	return nil
 }
 
-// testAndReloadNginx tests nginx configuration and reloads if valid
-func (nm *NginxManager) testAndReloadNginx() error {
+// testAndReloadNginx tests nginx configuration and reloads if valid. Each
+// command is bounded by timeouts.nginx_reload so a hung reload cannot block
+// the request.
+func (nm *NginxManager) testAndReloadNginx(ctx context.Context) error {
+	timeout := nm.cfg.Timeouts.NginxReload
+
 	// Test nginx configuration
-	cmd := exec.Command("nginx", "-t")
-	if err := cmd.Run(); err != nil {
+	if _, err := runCommand(ctx, timeout, "nginx", "-t"); err != nil {
 		return fmt.Errorf("nginx configuration test failed: %w", err)
 	}
 
 	// Reload nginx
-	cmd = exec.Command("systemctl", "reload", "nginx")
-	if err := cmd.Run(); err != nil {
+	if _, err := runCommand(ctx, timeout, "systemctl", "reload", "nginx"); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to reload nginx: %w", err)
+		}
 		// Try alternative reload method
-		cmd = exec.Command("service", "nginx", "reload")
-		if err := cmd.Run(); err != nil {
+		if _, err := runCommand(ctx, timeout, "service", "nginx", "reload"); err != nil {
 			return fmt.Errorf("failed to reload nginx: %w", err)
 		}
 	}
@@ -230,21 +255,66 @@ func (nm *NginxManager) testAndReloadNginx() error {
 	return nil
 }
 
+// SyncRegion rewrites a region's nginx config and adds back the instances
+// of its plan types, which regenerating the file drops. Instances out of
+// rotation are skipped: those in maintenance, and those not running unless
+// exempt from autoheal.
+func (nm *NginxManager) SyncRegion(ctx context.Context, region *domain.Region, instances []*domain.ProxyInstance) error {
+	if err := nm.createRegionConfig(region); err != nil {
+		return fmt.Errorf("failed to create region config: %w", err)
+	}
+
+	configFile := filepath.Join(nm.configDir, region.NginxConfigFile)
+	for _, instance := range instances {
+		if !instance.InRotation() || !containsString(region.PlanTypes, instance.PlanTypeKey) {
+			continue
+		}
+		planType, exists := nm.planTypes.Lookup(instance.PlanTypeKey)
+		if !exists {
+			continue
+		}
+		if err := nm.addServerToUpstream(ctx, configFile, planType.NginxUpstreamName, instance.LocalAddress()); err != nil {
+			return fmt.Errorf("failed to add instance %s to upstream: %w", instance.ID, err)
+		}
+	}
+
+	return nm.testAndReloadNginx(ctx)
+}
+
+// RemoveRegionConfig deletes a region's nginx config file and reloads nginx
+func (nm *NginxManager) RemoveRegionConfig(ctx context.Context, region *domain.Region) error {
+	configFile := filepath.Join(nm.configDir, region.NginxConfigFile)
+	if err := os.Remove(configFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove region config: %w", err)
+	}
+
+	nm.logger.Info("Removed nginx region config",
+		zap.String("region", region.Name),
+		zap.String("config_file", configFile),
+	)
+
+	return nm.testAndReloadNginx(ctx)
+}
+
 // RegenerateAllConfigs regenerates all nginx configurations
 func (nm *NginxManager) RegenerateAllConfigs(ctx context.Context) error {
-	for _, region := range nm.regions {
+	for _, region := range nm.regions.List() {
 		if err := nm.createRegionConfig(region); err != nil {
 			return fmt.Errorf("failed to create config for region %s: %w", region.Name, err)
 		}
 	}
 
-	return nm.testAndReloadNginx()
+	return nm.testAndReloadNginx(ctx)
 }
 
 // Template data structures
 type RegionTemplateData struct {
 	Region    *domain.Region
 	Upstreams []UpstreamConfig
+
+	// IdleTimeout, in seconds, is the longest idle timeout of the region's
+	// plan types; 0 when none sets one
+	IdleTimeout int
 }
 
 type UpstreamConfig struct {
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// Notifier delivers plan notifications
+type Notifier interface {
+	Notify(ctx context.Context, notification *domain.Notification) error
+}
+
+// Longest message text the chat APIs accept
+const (
+	telegramMaxText = 4096
+	discordMaxText  = 2000
+)
+
+// NewNotifier returns a notifier delivering to the configured webhook,
+// Telegram and Discord channels along notifications.routes, or one that only
+// logs when no channel is configured
+func NewNotifier(cfg *config.Config, logger *zap.Logger) Notifier {
+	settings := cfg.Notifications
+	client := &http.Client{Timeout: settings.Timeout}
+
+	channels := make(map[string]Notifier)
+	if settings.WebhookURL != "" {
+		channels[config.NotificationChannelWebhook] = &webhookNotifier{
+			url:    settings.WebhookURL,
+			client: client,
+			logger: logger,
+		}
+	}
+	if settings.Telegram.BotToken != "" {
+		channels[config.NotificationChannelTelegram] = &telegramNotifier{
+			cfg:    settings.Telegram,
+			client: client,
+		}
+	}
+	if settings.Discord.BotToken != "" {
+		channels[config.NotificationChannelDiscord] = &discordNotifier{
+			cfg:    settings.Discord,
+			client: client,
+		}
+	}
+
+	log := &logNotifier{logger: logger}
+	if len(channels) == 0 {
+		return log
+	}
+
+	return &routingNotifier{
+		order:    settings.Channels(),
+		channels: channels,
+		routes:   settings.Routes,
+		log:      log,
+	}
+}
+
+// routingNotifier delivers each notification to the channels its type is
+// routed to, logging the ones routed nowhere
+type routingNotifier struct {
+	order    []string
+	channels map[string]Notifier
+	routes   []config.NotificationRoute
+	log      Notifier
+}
+
+func (n *routingNotifier) Notify(ctx context.Context, notification *domain.Notification) error {
+	targets := n.targets(notification.Type)
+	if len(targets) == 0 {
+		return n.log.Notify(ctx, notification)
+	}
+
+	// Deliver to every channel even if one fails
+	var errs []error
+	for _, name := range targets {
+		if err := n.channels[name].Notify(ctx, notification); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// targets returns the channels notificationType is routed to
+func (n *routingNotifier) targets(notificationType string) []string {
+	if len(n.routes) == 0 {
+		return n.order
+	}
+
+	routed := make(map[string]bool)
+	for _, route := range n.routes {
+		for _, pattern := range route.Types {
+			if !matchNotificationType(pattern, notificationType) {
+				continue
+			}
+			for _, channel := range route.Channels {
+				routed[channel] = true
+			}
+			break
+		}
+	}
+
+	var targets []string
+	for _, channel := range n.order {
+		if routed[channel] {
+			targets = append(targets, channel)
+		}
+	}
+	return targets
+}
+
+// matchNotificationType matches a type exactly, by category ("plan.*") or "*"
+func matchNotificationType(pattern, notificationType string) bool {
+	if pattern == "*" || pattern == notificationType {
+		return true
+	}
+	category, ok := strings.CutSuffix(pattern, "*")
+	return ok && strings.HasSuffix(category, ".") && strings.HasPrefix(notificationType, category)
+}
+
+type logNotifier struct {
+	logger *zap.Logger
+}
+
+func (n *logNotifier) Notify(ctx context.Context, notification *domain.Notification) error {
+	n.logger.Info("Notification",
+		zap.String("type", notification.Type),
+		zap.String("plan_id", notification.PlanID),
+		zap.String("customer_id", notification.CustomerID),
+		zap.String("message", notification.Message),
+	)
+	return nil
+}
+
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+	logger *zap.Logger
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, notification *domain.Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	n.logger.Debug("Notification delivered",
+		zap.String("type", notification.Type),
+		zap.String("plan_id", notification.PlanID),
+	)
+	return nil
+}
+
+// telegramNotifier posts notifications to a Telegram chat through the Bot API
+type telegramNotifier struct {
+	cfg    config.TelegramNotifications
+	client *http.Client
+}
+
+func (n *telegramNotifier) Notify(ctx context.Context, notification *domain.Notification) error {
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimRight(n.cfg.APIURL, "/"), n.cfg.BotToken)
+	return postChatMessage(ctx, n.client, endpoint, nil, map[string]interface{}{
+		"chat_id":                  n.cfg.ChatID,
+		"text":                     notificationText(notification, telegramMaxText),
+		"disable_web_page_preview": true,
+	})
+}
+
+// discordNotifier posts notifications to a Discord channel as a bot
+type discordNotifier struct {
+	cfg    config.DiscordNotifications
+	client *http.Client
+}
+
+func (n *discordNotifier) Notify(ctx context.Context, notification *domain.Notification) error {
+	endpoint := fmt.Sprintf("%s/channels/%s/messages", strings.TrimRight(n.cfg.APIURL, "/"), url.PathEscape(n.cfg.ChannelID))
+	return postChatMessage(ctx, n.client, endpoint, map[string]string{"Authorization": "Bot " + n.cfg.BotToken}, map[string]interface{}{
+		"content": notificationText(notification, discordMaxText),
+		// Never ping @everyone or roles from notification text
+		"allowed_mentions": map[string]interface{}{"parse": []string{}},
+	})
+}
+
+// postChatMessage posts a chat API message, returning the API's error
+// description on failure. Errors never include the endpoint, which may
+// carry the bot token.
+func postChatMessage(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, payload map[string]interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("failed to create chat request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		// Telegram describes errors in "description", Discord in "message"
+		var apiErr struct {
+			Description string `json:"description"`
+			Message     string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&apiErr)
+		if detail := apiErr.Description + apiErr.Message; detail != "" {
+			return fmt.Errorf("chat API returned status %d: %s", resp.StatusCode, detail)
+		}
+		return fmt.Errorf("chat API returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// notificationText renders a notification as a chat message of at most
+// limit characters
+func notificationText(notification *domain.Notification, limit int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s", notification.Type, notification.Message)
+	if notification.PlanID != "" {
+		fmt.Fprintf(&b, "\nplan_id: %s", notification.PlanID)
+	}
+	if notification.CustomerID != "" {
+		fmt.Fprintf(&b, "\ncustomer_id: %s", notification.CustomerID)
+	}
+
+	keys := make([]string, 0, len(notification.Data))
+	for key := range notification.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "\n%s: %s", key, notification.Data[key])
+	}
+
+	text := []rune(b.String())
+	if len(text) > limit {
+		return string(text[:limit-1]) + "…"
+	}
+	return string(text)
+}
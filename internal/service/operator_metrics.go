@@ -0,0 +1,40 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/je265/oceanproxy/internal/service/provider"
+)
+
+// OperatorMetrics renders process-wide metrics for the operator's Prometheus
+// in Prometheus/OpenMetrics text format
+type OperatorMetrics struct {
+	schemaGuard *provider.SchemaGuard
+	latency     *UpstreamLatency
+}
+
+// NewOperatorMetrics creates a new operator metrics exporter
+func NewOperatorMetrics(schemaGuard *provider.SchemaGuard, latency *UpstreamLatency) *OperatorMetrics {
+	return &OperatorMetrics{schemaGuard: schemaGuard, latency: latency}
+}
+
+// Render writes the operator metrics to w
+func (m *OperatorMetrics) Render(w io.Writer, openMetrics bool) error {
+	var drift []string
+	for _, count := range m.schemaGuard.Counts() {
+		drift = append(drift, fmt.Sprintf(`oceanproxy_provider_schema_drift_total{provider="%s",endpoint="%s",kind="%s"} %d`,
+			escapeLabel(count.Provider), escapeLabel(count.Endpoint), escapeLabel(count.Kind), count.Count))
+	}
+
+	var b strings.Builder
+	writeCounterFamily(&b, "oceanproxy_provider_schema_drift", "Provider responses that differed from the expected shape, by endpoint and drift kind", drift, openMetrics)
+	m.latency.render(&b)
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
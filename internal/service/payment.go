@@ -0,0 +1,92 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// PaymentProvider bills customers for plan renewals
+type PaymentProvider interface {
+	// Charge bills one renewal term and returns the provider's charge ID
+	Charge(ctx context.Context, charge *domain.RenewalCharge) (string, error)
+}
+
+// NewPaymentProvider returns a provider that POSTs charges to
+// billing.payment.url, or nil when no billing integration is configured
+func NewPaymentProvider(cfg *config.Config, logger *zap.Logger) PaymentProvider {
+	if cfg.Billing.Payment.URL == "" {
+		return nil
+	}
+
+	return &webhookPaymentProvider{
+		url:    cfg.Billing.Payment.URL,
+		token:  cfg.Billing.Payment.Token,
+		client: &http.Client{Timeout: cfg.Billing.Payment.Timeout},
+		logger: logger,
+	}
+}
+
+type webhookPaymentProvider struct {
+	url    string
+	token  string
+	client *http.Client
+	logger *zap.Logger
+}
+
+// chargeResponse is the optional body of a successful charge
+type chargeResponse struct {
+	ChargeID string `json:"charge_id"`
+}
+
+func (p *webhookPaymentProvider) Charge(ctx context.Context, charge *domain.RenewalCharge) (string, error) {
+	body, err := json.Marshal(charge)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal charge: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create charge request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", charge.IdempotencyKey)
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send charge: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 300 {
+		if msg := strings.TrimSpace(string(respBody)); msg != "" {
+			return "", fmt.Errorf("payment provider returned status %d: %s", resp.StatusCode, msg)
+		}
+		return "", fmt.Errorf("payment provider returned status %d", resp.StatusCode)
+	}
+
+	var result chargeResponse
+	if len(respBody) > 0 {
+		_ = json.Unmarshal(respBody, &result)
+	}
+
+	p.logger.Debug("Renewal charged",
+		zap.String("plan_id", charge.PlanID),
+		zap.String("charge_id", result.ChargeID),
+		zap.Int("attempt", charge.Attempt),
+	)
+	return result.ChargeID, nil
+}
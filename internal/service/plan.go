@@ -3,7 +3,10 @@ package service
 
 import (
     "context"
+    "errors"
     "fmt"
+    "sort"
+    "strings"
     "time"
 
     "github.com/google/uuid"
@@ -11,6 +14,7 @@ import (
 
     "github.com/je265/oceanproxy/internal/domain"
     "github.com/je265/oceanproxy/internal/repository"
+    "github.com/je265/oceanproxy/internal/service/provider"
     "github.com/je265/oceanproxy/pkg/config"
 )
 
@@ -19,11 +23,20 @@ type planService struct {
 	logger          *zap.Logger
 	planRepo        repository.PlanRepository
 	instanceRepo    repository.InstanceRepository
+	eventRepo       repository.PlanEventRepository
+	events          *eventRecorder
+	accountService  ProviderAccountService
 	providerService ProviderService
 	proxyService    ProxyService
 	portManager     *PortManager
 	nginxManager    *NginxManager
-	regions         map[string]*domain.Region
+	geoVerifier     *GeoVerifier
+	regions         *RegionRegistry
+	credentials     *credentialPolicy
+	activation      *ActivationWorker
+	supervisor      *Supervisor
+	vouchers        *VoucherService
+	notifier        Notifier
 }
 
 func NewPlanService(
@@ -31,22 +44,38 @@ func NewPlanService(
 	logger *zap.Logger,
 	planRepo repository.PlanRepository,
 	instanceRepo repository.InstanceRepository,
+	eventRepo repository.PlanEventRepository,
+	accountService ProviderAccountService,
 	providerService ProviderService,
 	proxyService ProxyService,
 	portManager *PortManager,
 	nginxManager *NginxManager,
-	regions map[string]*domain.Region,
+	geoVerifier *GeoVerifier,
+	regions *RegionRegistry,
+	activation *ActivationWorker,
+	supervisor *Supervisor,
+	vouchers *VoucherService,
+	notifier Notifier,
 ) PlanService {
 	return &planService{
 		cfg:             cfg,
 		logger:          logger,
 		planRepo:        planRepo,
 		instanceRepo:    instanceRepo,
+		eventRepo:       eventRepo,
+		events:          newEventRecorder(eventRepo, logger),
+		accountService:  accountService,
 		providerService: providerService,
 		proxyService:    proxyService,
 		portManager:     portManager,
 		nginxManager:    nginxManager,
+		geoVerifier:     geoVerifier,
 		regions:         regions,
+		credentials:     newCredentialPolicy(cfg),
+		activation:      activation,
+		supervisor:      supervisor,
+		vouchers:        vouchers,
+		notifier:        notifier,
 	}
 }
 
@@ -65,14 +94,70 @@ func (s *planService) CreatePlan(ctx context.Context, req *domain.CreatePlanRequ
 	}
 
 	// Get plan type config for upstream details
-	_, err = s.portManager.GetPlanTypeConfig(planTypeKey)
+	planTypeConfig, err := s.portManager.GetPlanTypeConfig(planTypeKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get plan type config: %w", err)
 	}
 
+	// Apply the plan type's defaults and limits before anything is provisioned
+	policy := planTypeConfig.Policy
+	if policy == nil {
+		policy = domain.DefaultPlanPolicy(req.Provider, req.PlanType)
+	}
+	if err := policy.Apply(planTypeKey, req); err != nil {
+		return nil, fmt.Errorf("plan request rejected: %w", err)
+	}
+
+	anchor := req.BillingAnchor
+	if anchor.Timezone == "" {
+		anchor.Timezone = s.cfg.Billing.Timezone
+	}
+	if err := anchor.Validate(); err != nil {
+		return nil, fmt.Errorf("plan request rejected: %w", &domain.PolicyError{PlanType: planTypeKey, Field: "billing", Reason: "is invalid (" + err.Error() + ")"})
+	}
+	if req.AutoRenew && s.cfg.Billing.Payment.URL == "" {
+		return nil, fmt.Errorf("plan request rejected: %w", errAutoRenewUnavailable(planTypeKey))
+	}
+	if !domain.IsExpiryAction(req.ExpiryAction) {
+		return nil, fmt.Errorf("plan request rejected: %w", errInvalidExpiryAction(planTypeKey, req.ExpiryAction))
+	}
+	destinations, err := normalizeDestinations(planTypeKey, req.AllowedDestinations)
+	if err != nil {
+		return nil, fmt.Errorf("plan request rejected: %w", err)
+	}
+	headerPolicy, err := normalizeHeaderPolicy(planTypeKey, req.HeaderPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("plan request rejected: %w", err)
+	}
+
+	// Nettify requires caller-chosen credentials; Proxies.fo generates its own
+	if req.Provider == domain.ProviderNettify {
+		if err := s.credentials.assignCredentials(ctx, s.planRepo, planTypeKey, req); err != nil {
+			return nil, fmt.Errorf("plan request rejected: %w", err)
+		}
+	}
+
+	// Redeem the voucher last, once nothing else can reject the request. If
+	// the plan is not provisioned, its use is given back.
+	planID := uuid.New()
+	provisioned := false
+	var voucher *domain.PlanVoucher
+	if req.Voucher != "" {
+		redemption, applied, err := s.vouchers.Redeem(ctx, req.Voucher, planID, planTypeKey, req, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("voucher not redeemed: %w", err)
+		}
+		voucher = applied
+		defer func() {
+			if !provisioned {
+				s.vouchers.Release(context.WithoutCancel(ctx), redemption, "plan creation failed", time.Now())
+			}
+		}()
+	}
+
     // Create plan record (username/password may be overridden by provider)
     plan := &domain.ProxyPlan{
-		ID:          uuid.New(),
+		ID:          planID,
         CustomerID:  req.CustomerID,
 		PlanType:    req.PlanType,
 		Provider:    req.Provider,
@@ -84,13 +169,26 @@ func (s *planService) CreatePlan(ctx context.Context, req *domain.CreatePlanRequ
 		Bandwidth:   req.Bandwidth,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
+
+		BillingAnchor:       anchor,
+		AutoRenew:           req.AutoRenew,
+		ExpiryAction:        req.ExpiryAction,
+		AllowedDestinations: destinations,
+		HeaderPolicy:        headerPolicy,
+		Voucher:             voucher,
 	}
 
 	// Set expiration
-	if req.Duration > 0 {
-		plan.ExpiresAt = time.Now().AddDate(0, 0, req.Duration)
-	} else {
-		plan.ExpiresAt = time.Now().AddDate(0, 0, 30) // Default to 30 days
+	duration := req.Duration
+	if duration <= 0 {
+		duration = 30 // Default to 30 days
+	}
+	plan.Duration = duration
+	if anchor.Cycle == domain.BillingCycleMonthly {
+		plan.Months = max(req.Months, 1)
+	}
+	if plan.ExpiresAt, err = anchor.Expiry(plan.CreatedAt, duration, req.Months); err != nil {
+		return nil, fmt.Errorf("failed to compute expiry: %w", err)
 	}
 
 	// Save plan to repository
@@ -98,14 +196,41 @@ func (s *planService) CreatePlan(ctx context.Context, req *domain.CreatePlanRequ
 		return nil, fmt.Errorf("failed to create plan: %w", err)
 	}
 
-	// Create upstream provider account
-	providerAccount, err := s.providerService.CreateAccount(ctx, req.Provider, req)
+	// The remaining steps have upstream and system side effects. Finish them
+	// even if the caller goes away so nothing is left half-provisioned; each
+	// step is still bounded by its own timeout.
+	ctx = context.WithoutCancel(ctx)
+	s.events.record(ctx, plan.ID, nil, domain.EventPlanCreated, "Plan created", map[string]string{
+		"customer_id":   plan.CustomerID,
+		"plan_type_key": planTypeKey,
+		"bandwidth_gb":  fmt.Sprint(plan.Bandwidth),
+		"expires_at":    plan.ExpiresAt.Format(time.RFC3339),
+	})
+	if voucher != nil {
+		s.events.record(ctx, plan.ID, nil, domain.EventVoucherRedeemed, "Voucher redeemed", map[string]string{
+			"code":             voucher.Code,
+			"kind":             voucher.Kind,
+			"discount_percent": fmt.Sprint(voucher.DiscountPercent),
+		})
+	}
+
+	// Create (or reuse) the upstream provider account and link it to the plan
+	providerAccount, err := s.accountService.AcquireAccount(ctx, req, planTypeKey, plan.ID)
 	if err != nil {
-		plan.Status = domain.PlanStatusFailed
-		s.planRepo.Update(ctx, plan)
+		s.creationFailed(ctx, plan, "provider_account", err)
 		return nil, fmt.Errorf("failed to create provider account: %w", err)
 	}
 
+	// Freshly created accounts have never been topped up
+	accountEvent, accountMessage := domain.EventProviderAccountCreated, "Provider account created"
+	if providerAccount.TopUps > 0 {
+		accountEvent, accountMessage = domain.EventProviderAccountReused, "Existing provider account topped up and reused"
+	}
+	s.events.record(ctx, plan.ID, nil, accountEvent, accountMessage, map[string]string{
+		"account_id":  providerAccount.ID.String(),
+		"upstream_id": providerAccount.UpstreamID,
+	})
+
     // Use provider-generated credentials and customer association if provided
     if providerAccount != nil {
         if providerAccount.Username != "" {
@@ -114,18 +239,29 @@ func (s *planService) CreatePlan(ctx context.Context, req *domain.CreatePlanRequ
         if providerAccount.Password != "" {
             plan.Password = providerAccount.Password
         }
-        if providerAccount.CustomerID != "" {
-            plan.CustomerID = providerAccount.CustomerID
+        if providerAccount.UpstreamCustomerID != "" {
+            plan.CustomerID = providerAccount.UpstreamCustomerID
         }
+        plan.ProviderAccountID = &providerAccount.ID
     }
 
 	// Allocate local port
 	localPort, err := s.portManager.AllocatePort(ctx, planTypeKey, plan.ID.String())
 	if err != nil {
-		plan.Status = domain.PlanStatusFailed
-		s.planRepo.Update(ctx, plan)
+		s.creationFailed(ctx, plan, "port", err)
 		return nil, fmt.Errorf("failed to allocate port: %w", err)
 	}
+	s.events.record(ctx, plan.ID, nil, domain.EventPortAllocated, "Local port allocated", map[string]string{
+		"port": fmt.Sprint(localPort),
+	})
+
+	// Allocate the egress IP of plan types that pin traffic to one
+	egressIP, err := s.portManager.AllocateEgressIP(ctx, planTypeKey, plan.ID.String())
+	if err != nil {
+		s.portManager.ReleasePort(ctx, planTypeKey, localPort)
+		s.creationFailed(ctx, plan, "egress_ip", err)
+		return nil, fmt.Errorf("failed to allocate egress IP: %w", err)
+	}
 
 	// Create proxy instance
 	instance := &domain.ProxyInstance{
@@ -133,6 +269,8 @@ func (s *planService) CreatePlan(ctx context.Context, req *domain.CreatePlanRequ
 		PlanID:      plan.ID,
 		PlanTypeKey: planTypeKey,
 		LocalPort:   localPort,
+		LocalHost:   planTypeConfig.BindAddress,
+		EgressIP:    egressIP,
 		AuthHost:    providerAccount.Host,
 		AuthPort:    providerAccount.Port,
 		Status:      domain.InstanceStatusStarting,
@@ -142,52 +280,51 @@ func (s *planService) CreatePlan(ctx context.Context, req *domain.CreatePlanRequ
 
 	if err := s.instanceRepo.Create(ctx, instance); err != nil {
 		s.portManager.ReleasePort(ctx, planTypeKey, localPort)
-		plan.Status = domain.PlanStatusFailed
-		s.planRepo.Update(ctx, plan)
+		s.portManager.ReleaseEgressIP(ctx, planTypeKey, egressIP, plan.ID.String())
+		s.creationFailed(ctx, plan, "instance", err)
 		return nil, fmt.Errorf("failed to create instance: %w", err)
 	}
+	provisioned = true
 
-	// Start 3proxy instance
-	if err := s.proxyService.StartInstance(ctx, instance); err != nil {
-		s.logger.Error("Failed to start proxy instance", zap.Error(err))
-		// Continue - we can retry later
+	// Start the 3proxy instance and add it to the nginx upstream. A failure
+	// leaves the plan creating and is retried with backoff, after which the
+	// plan is marked failed.
+	plan.Instances = []*domain.ProxyInstance{instance}
+	if err := s.activation.Activate(ctx, plan); err != nil {
+		s.activation.Failed(ctx, plan, err, time.Now())
+	} else {
+		plan.Status = domain.PlanStatusActive
+		if err := s.planRepo.Update(ctx, plan); err != nil {
+			s.logger.Error("Failed to update plan status", zap.Error(err))
+		}
 	}
 
-	// Update nginx configuration
-	if err := s.nginxManager.UpdateUpstream(ctx, planTypeKey, localPort); err != nil {
-		s.logger.Error("Failed to update nginx upstream", zap.Error(err))
-		// Continue - nginx can be updated manually if needed
+	// Confirm the provider handed back an endpoint in the requested region
+	if s.geoVerifier.Enabled() {
+		planID := plan.ID
+		s.supervisor.Spawn(context.Background(), "initial_geo_verification", func(ctx context.Context) error {
+			if _, err := s.geoVerifier.VerifyPlan(ctx, planID); err != nil {
+				s.logger.Debug("Initial geo verification skipped", zap.String("plan_id", planID.String()), zap.Error(err))
+			}
+			return nil
+		})
 	}
 
-	// Update plan status to active
-	plan.Status = domain.PlanStatusActive
-	plan.Instances = []*domain.ProxyInstance{instance}
-	if err := s.planRepo.Update(ctx, plan); err != nil {
-		s.logger.Error("Failed to update plan status", zap.Error(err))
+	// Build response with customer-facing endpoint mapping rules
+	endpoints, err := s.planEndpoints(plan)
+	if err != nil {
+		return nil, err
 	}
 
-    // Build response with customer-facing endpoint mapping rules
-    host, port, displayRegion, err := s.resolveEndpointHostPort(req.Provider, req.PlanType, req.Region)
-    if err != nil {
-        return nil, err
-    }
-
-    endpointURL := fmt.Sprintf("http://%s:%s@%s:%d", plan.Username, plan.Password, host, port)
-
-    response := &domain.CreatePlanResponse{
+	response := &domain.CreatePlanResponse{
 		Success:   true,
 		PlanID:    plan.ID,
 		Username:  plan.Username,
 		Password:  plan.Password,
 		ExpiresAt: plan.ExpiresAt,
-		Proxies: []domain.ProxyEndpoint{
-			{
-                URL:      endpointURL,
-                Region:   displayRegion,
-				Username: plan.Username,
-				Password: plan.Password,
-			},
-		},
+		Proxies:   endpoints,
+		Status:    plan.Status,
+		Voucher:   plan.Voucher,
 	}
 
 	s.logger.Info("Successfully created proxy plan",
@@ -200,93 +337,61 @@ func (s *planService) CreatePlan(ctx context.Context, req *domain.CreatePlanRequ
 	return response, nil
 }
 
-// resolveEndpointHostPort determines the customer-facing host, port, and region label
-// based on provider, plan type, and requested region.
-func (s *planService) resolveEndpointHostPort(provider, planType, reqRegion string) (string, int, string, error) {
-    switch provider {
-    case domain.ProviderProxiesFo:
-        switch planType {
-        case domain.PlanTypeResidential:
-            // usa -> usa.oceanproxy.io, eu -> eu.oceanproxy.io
-            region := s.regions[reqRegion]
-            if region == nil {
-                return "", 0, "", fmt.Errorf("region %s not found", reqRegion)
-            }
-            return region.GetFullDomain(), region.OutboundPort, region.Name, nil
-        case domain.PlanTypeDatacenter:
-            // datacenter.oceanproxy.io with port from requested region
-            region := s.regions[reqRegion]
-            if region == nil {
-                return "", 0, "", fmt.Errorf("region %s not found", reqRegion)
-            }
-            return "datacenter.oceanproxy.io", region.OutboundPort, "datacenter", nil
-        case domain.PlanTypeISP:
-            // isp.oceanproxy.io with port from requested region
-            region := s.regions[reqRegion]
-            if region == nil {
-                return "", 0, "", fmt.Errorf("region %s not found", reqRegion)
-            }
-            return "isp.oceanproxy.io", region.OutboundPort, "isp", nil
-        default:
-            // fallback to requested region
-            region := s.regions[reqRegion]
-            if region == nil {
-                return "", 0, "", fmt.Errorf("region %s not found", reqRegion)
-            }
-            return region.GetFullDomain(), region.OutboundPort, region.Name, nil
-        }
-    case domain.ProviderNettify:
-        switch planType {
-        case domain.PlanTypeResidential:
-            // alpha.oceanproxy.io (use alpha port)
-            alpha := s.regions[domain.RegionAlpha]
-            if alpha == nil {
-                return "", 0, "", fmt.Errorf("region %s not found", domain.RegionAlpha)
-            }
-            return "alpha.oceanproxy.io", alpha.OutboundPort, "alpha", nil
-        case domain.PlanTypeDatacenter:
-            // beta.oceanproxy.io (use beta port)
-            beta := s.regions[domain.RegionBeta]
-            if beta == nil {
-                return "", 0, "", fmt.Errorf("region %s not found", domain.RegionBeta)
-            }
-            return "beta.oceanproxy.io", beta.OutboundPort, "beta", nil
-        case domain.PlanTypeMobile:
-            // mobile.oceanproxy.io (use alpha port as base if mobile not defined)
-            // Try a region named "mobile" if present; otherwise fall back to alpha's port
-            if mobile := s.regions["mobile"]; mobile != nil {
-                return "mobile.oceanproxy.io", mobile.OutboundPort, "mobile", nil
-            }
-            alpha := s.regions[domain.RegionAlpha]
-            if alpha == nil {
-                return "", 0, "", fmt.Errorf("region %s not found", domain.RegionAlpha)
-            }
-            return "mobile.oceanproxy.io", alpha.OutboundPort, "mobile", nil
-        case domain.PlanTypeUnlimited:
-            // unlim.oceanproxy.io (use alpha port as base if unlim not defined)
-            if unlim := s.regions["unlim"]; unlim != nil {
-                return "unlim.oceanproxy.io", unlim.OutboundPort, "unlim", nil
-            }
-            alpha := s.regions[domain.RegionAlpha]
-            if alpha == nil {
-                return "", 0, "", fmt.Errorf("region %s not found", domain.RegionAlpha)
-            }
-            return "unlim.oceanproxy.io", alpha.OutboundPort, "unlim", nil
-        default:
-            alpha := s.regions[domain.RegionAlpha]
-            if alpha == nil {
-                return "", 0, "", fmt.Errorf("region %s not found", domain.RegionAlpha)
-            }
-            return alpha.GetFullDomain(), alpha.OutboundPort, alpha.Name, nil
-        }
-    }
+// creationFailed marks a plan failed at step and alerts operators. A
+// provider refusing the order for lack of balance is alerted as such.
+func (s *planService) creationFailed(ctx context.Context, plan *domain.ProxyPlan, step string, cause error) {
+	plan.Status = domain.PlanStatusFailed
+	s.planRepo.Update(ctx, plan)
+
+	notification := &domain.Notification{
+		Type:       domain.NotificationPlanCreationFailed,
+		PlanID:     plan.ID.String(),
+		CustomerID: plan.CustomerID,
+		Message:    "A new proxy plan could not be created and was marked failed.",
+		Data: map[string]string{
+			"plan_type_key": plan.PlanTypeKey,
+			"step":          step,
+			"error":         cause.Error(),
+		},
+		CreatedAt: time.Now(),
+	}
+	if errors.Is(cause, provider.ErrInsufficientBalance) {
+		notification.Type = domain.NotificationProviderBalanceLow
+		notification.Message = fmt.Sprintf("%s refused a new plan for insufficient balance; top up the reseller account.", plan.Provider)
+		notification.Data["provider"] = plan.Provider
+	}
+	if err := s.notifier.Notify(ctx, notification); err != nil {
+		s.logger.Error("Failed to send plan notification",
+			zap.String("type", notification.Type),
+			zap.String("plan_id", plan.ID.String()),
+			zap.Error(err))
+	}
+}
 
-    // Unknown provider; default to requested region
-    region := s.regions[reqRegion]
-    if region == nil {
-        return "", 0, "", fmt.Errorf("region %s not found", reqRegion)
-    }
-    return region.GetFullDomain(), region.OutboundPort, region.Name, nil
+// planEndpoints returns the customer-facing endpoints of a plan
+func (s *planService) planEndpoints(plan *domain.ProxyPlan) ([]domain.ProxyEndpoint, error) {
+	host, port, displayRegion, err := resolveEndpoint(s.regions, plan.Provider, plan.PlanType, plan.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	return []domain.ProxyEndpoint{
+		{
+			URL:      fmt.Sprintf("http://%s:%s@%s:%d", plan.Username, plan.Password, host, port),
+			Region:   displayRegion,
+			Username: plan.Username,
+			Password: plan.Password,
+		},
+	}, nil
+}
+
+// GetPlanEndpoints returns the customer-facing endpoints of an existing plan
+func (s *planService) GetPlanEndpoints(ctx context.Context, planID uuid.UUID) ([]domain.ProxyEndpoint, error) {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	return s.planEndpoints(plan)
 }
 
 func (s *planService) GetPlan(ctx context.Context, planID uuid.UUID) (*domain.ProxyPlan, error) {
@@ -301,16 +406,35 @@ func (s *planService) GetAllPlans(ctx context.Context) ([]*domain.ProxyPlan, err
 	return s.planRepo.GetAll(ctx)
 }
 
+// PlansVersion changes whenever any plan is written
+func (s *planService) PlansVersion(ctx context.Context) (uint64, error) {
+	return s.planRepo.Version(ctx)
+}
+
 func (s *planService) UpdatePlanStatus(ctx context.Context, planID uuid.UUID, status string) error {
 	updatedPlan, err := s.planRepo.GetByID(ctx, planID)
 	if err != nil {
 		return err
 	}
 
+	previous := updatedPlan.Status
 	updatedPlan.Status = status
 	updatedPlan.UpdatedAt = time.Now()
 
-	return s.planRepo.Update(ctx, updatedPlan)
+	if err := s.planRepo.Update(ctx, updatedPlan); err != nil {
+		return err
+	}
+
+	eventType := domain.EventPlanStatusChanged
+	if status == domain.PlanStatusExpired {
+		eventType = domain.EventPlanExpired
+	}
+	s.events.record(ctx, planID, nil, eventType, fmt.Sprintf("Status changed from %s to %s", previous, status), map[string]string{
+		"from": previous,
+		"to":   status,
+	})
+
+	return nil
 }
 
 func (s *planService) DeletePlan(ctx context.Context, planID uuid.UUID) error {
@@ -343,8 +467,17 @@ func (s *planService) DeletePlan(ctx context.Context, planID uuid.UUID) error {
 			)
 		}
 
+		// Release egress IP
+		if err := s.portManager.ReleaseEgressIP(ctx, instance.PlanTypeKey, instance.EgressIP, planID.String()); err != nil {
+			s.logger.Error("Failed to release egress IP during plan deletion",
+				zap.String("instance_id", instance.ID.String()),
+				zap.String("egress_ip", instance.EgressIP),
+				zap.Error(err),
+			)
+		}
+
 		// Remove from nginx upstream
-		if err := s.nginxManager.RemoveFromUpstream(ctx, instance.PlanTypeKey, instance.LocalPort); err != nil {
+		if err := s.nginxManager.RemoveFromUpstream(ctx, instance); err != nil {
 			s.logger.Error("Failed to remove from nginx upstream during plan deletion",
 				zap.String("instance_id", instance.ID.String()),
 				zap.Error(err),
@@ -360,15 +493,424 @@ func (s *planService) DeletePlan(ctx context.Context, planID uuid.UUID) error {
 		}
 	}
 
+	// Detach the plan from its provider account; the account itself is
+	// managed separately and may still serve other plans
+	if err := s.accountService.ReleasePlan(ctx, planID); err != nil {
+		s.logger.Warn("Failed to unlink plan from provider account",
+			zap.String("plan_id", planID.String()),
+			zap.Error(err),
+		)
+	}
+
 	s.logger.Info("Plan deletion completed",
 		zap.String("plan_id", planToDelete.ID.String()),
 		zap.String("customer_id", planToDelete.CustomerID),
 	)
 
-	// Delete plan from repository
-	return s.planRepo.Delete(ctx, planID)
+	// Delete plan from repository; its events are kept for later inspection
+	if err := s.planRepo.Delete(ctx, planID); err != nil {
+		return err
+	}
+	s.events.record(ctx, planID, nil, domain.EventPlanDeleted, "Plan deleted", nil)
+
+	return nil
+}
+
+// TopUpPlan adds bandwidth to the plan's provider account and reactivates
+// the plan if it was exhausted or suspended
+func (s *planService) TopUpPlan(ctx context.Context, planID uuid.UUID, bandwidthGB int) (*domain.ProxyPlan, error) {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	if plan.ProviderAccountID == nil {
+		return nil, fmt.Errorf("plan %s has no linked provider account", planID)
+	}
+
+	if _, err := s.accountService.RenewAccount(ctx, *plan.ProviderAccountID, bandwidthGB); err != nil {
+		return nil, err
+	}
+
+	previous := plan.Status
+	plan.Bandwidth += bandwidthGB
+	if plan.Status == domain.PlanStatusExhausted || plan.Status == domain.PlanStatusSuspended {
+		plan.Status = domain.PlanStatusActive
+	}
+	if err := s.planRepo.Update(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to update plan: %w", err)
+	}
+
+	s.events.record(ctx, plan.ID, nil, domain.EventPlanToppedUp, fmt.Sprintf("Added %d GB", bandwidthGB), map[string]string{
+		"bandwidth_added_gb": fmt.Sprint(bandwidthGB),
+		"from":               previous,
+		"to":                 plan.Status,
+	})
+
+	s.logger.Info("Topped up proxy plan",
+		zap.String("plan_id", plan.ID.String()),
+		zap.Int("bandwidth_added", bandwidthGB),
+		zap.String("status", plan.Status),
+	)
+
+	// Take the instances of a formerly exhausted plan off the teaser page,
+	// and serve a formerly suspended one again
+	if (previous == domain.PlanStatusExhausted && s.cfg.Billing.TeaserPage.Listen != "") || previous == domain.PlanStatusSuspended {
+		if err := s.restartRunningInstances(ctx, plan.ID); err != nil {
+			s.logger.Warn("Failed to restart instances of topped up plan",
+				zap.String("plan_id", plan.ID.String()),
+				zap.Error(err))
+		}
+	}
+
+	return plan, nil
+}
+
+// GetPlanEvents returns a plan's event history, oldest first. History outlives
+// the plan, so events of deleted plans are still returned.
+func (s *planService) GetPlanEvents(ctx context.Context, planID uuid.UUID) ([]*domain.PlanEvent, error) {
+	return s.eventRepo.GetByPlanID(ctx, planID)
+}
+
+// ClonePlan creates a new plan with the same provider, type, region, bandwidth
+// and duration as an existing one, with fresh credentials. An empty customerID
+// keeps the source plan's customer.
+func (s *planService) ClonePlan(ctx context.Context, planID uuid.UUID, customerID string) (*domain.CreatePlanResponse, error) {
+	source, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	if customerID == "" {
+		customerID = source.CustomerID
+	}
+
+	duration, months := source.Term()
+
+	req := &domain.CreatePlanRequest{
+		CustomerID:      customerID,
+		PlanType:        source.PlanType,
+		Provider:        source.Provider,
+		Region:          source.Region,
+		Bandwidth:       source.Bandwidth,
+		Duration:        duration,
+		BillingAnchor:   source.BillingAnchor,
+		Months:          months,
+		ForceNewAccount: true,
+
+		ExpiryAction:        source.ExpiryAction,
+		AllowedDestinations: source.AllowedDestinations,
+		HeaderPolicy:        source.HeaderPolicy,
+	}
+
+	s.logger.Info("Cloning proxy plan",
+		zap.String("source_plan_id", source.ID.String()),
+		zap.String("customer_id", customerID),
+	)
+
+	return s.CreatePlan(ctx, req)
+}
+
+// SetAutoRenew turns automatic renewal of a plan on or off. Turning it on
+// clears any abandoned renewal so the expiry worker tries again.
+func (s *planService) SetAutoRenew(ctx context.Context, planID uuid.UUID, enabled bool) (*domain.ProxyPlan, error) {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	if enabled && s.cfg.Billing.Payment.URL == "" {
+		return nil, errAutoRenewUnavailable(plan.PlanTypeKey)
+	}
+
+	plan.AutoRenew = enabled
+	if enabled {
+		plan.Renewal = nil
+	}
+	plan.UpdatedAt = time.Now()
+	if err := s.planRepo.Update(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to update plan: %w", err)
+	}
+
+	s.logger.Info("Updated plan auto-renew",
+		zap.String("plan_id", plan.ID.String()),
+		zap.Bool("auto_renew", enabled),
+	)
+
+	return plan, nil
+}
+
+// SetExpiryAction sets what the expiry worker does with a plan once it
+// expires. It only applies from the next expiry on.
+func (s *planService) SetExpiryAction(ctx context.Context, planID uuid.UUID, action string) (*domain.ProxyPlan, error) {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !domain.IsExpiryAction(action) {
+		return nil, errInvalidExpiryAction(plan.PlanTypeKey, action)
+	}
+
+	plan.ExpiryAction = action
+	plan.UpdatedAt = time.Now()
+	if err := s.planRepo.Update(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to update plan: %w", err)
+	}
+
+	s.logger.Info("Updated plan expiry action",
+		zap.String("plan_id", plan.ID.String()),
+		zap.String("expiry_action", action),
+	)
+
+	return plan, nil
+}
+
+// RotatePassword replaces the password of a plan with caller-chosen
+// credentials and restarts its running instances so 3proxy picks it up. An
+// empty password is generated; a given one must pass the password policy.
+func (s *planService) RotatePassword(ctx context.Context, planID uuid.UUID, password string) (*domain.ProxyPlan, error) {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Proxies.fo generates its own credentials
+	if plan.Provider != domain.ProviderNettify {
+		return nil, &domain.PolicyError{PlanType: plan.PlanTypeKey, Field: "password", Reason: "cannot be rotated; the provider generates credentials"}
+	}
+
+	if password == "" {
+		if password, err = s.credentials.GeneratePassword(); err != nil {
+			return nil, err
+		}
+	} else if err := s.credentials.ValidatePassword(plan.PlanTypeKey, password); err != nil {
+		return nil, err
+	}
+
+	plan.Password = password
+	plan.UpdatedAt = time.Now()
+	if err := s.planRepo.Update(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to update plan: %w", err)
+	}
+
+	s.events.record(ctx, plan.ID, nil, domain.EventPlanPasswordRotated, "Plan password rotated", nil)
+	s.logger.Info("Rotated plan password", zap.String("plan_id", plan.ID.String()))
+
+	if err := s.restartRunningInstances(ctx, plan.ID); err != nil {
+		return nil, fmt.Errorf("failed to apply the new password: %w", err)
+	}
+
+	return plan, nil
+}
+
+// SetAllowedDestinations replaces the destination allowlist of a plan and
+// restarts its running instances so 3proxy enforces it. An empty list lifts
+// the restriction.
+func (s *planService) SetAllowedDestinations(ctx context.Context, planID uuid.UUID, destinations []string) (*domain.ProxyPlan, error) {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized, err := normalizeDestinations(plan.PlanTypeKey, destinations)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.AllowedDestinations = normalized
+	plan.UpdatedAt = time.Now()
+	if err := s.planRepo.Update(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to update plan: %w", err)
+	}
+
+	message := "Destination allowlist removed"
+	if len(normalized) > 0 {
+		message = fmt.Sprintf("Destinations restricted to %d allowed targets", len(normalized))
+	}
+	s.events.record(ctx, plan.ID, nil, domain.EventPlanDestinationsSet, message, map[string]string{
+		"allowed_destinations": strings.Join(normalized, ","),
+	})
+	s.logger.Info("Updated plan allowed destinations",
+		zap.String("plan_id", plan.ID.String()),
+		zap.Strings("allowed_destinations", normalized),
+	)
+
+	if err := s.restartRunningInstances(ctx, plan.ID); err != nil {
+		return nil, fmt.Errorf("failed to apply the allowed destinations: %w", err)
+	}
+
+	return plan, nil
+}
+
+// SetHeaderPolicy replaces the header policy of a plan. An empty policy
+// removes it. The 3proxy backend does not rewrite request headers, so the
+// policy is stored for engines that do and running instances are left as
+// they are.
+func (s *planService) SetHeaderPolicy(ctx context.Context, planID uuid.UUID, policy *domain.HeaderPolicy) (*domain.ProxyPlan, error) {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized, err := normalizeHeaderPolicy(plan.PlanTypeKey, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.HeaderPolicy = normalized
+	plan.UpdatedAt = time.Now()
+	if err := s.planRepo.Update(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to update plan: %w", err)
+	}
+
+	message := "Header policy removed"
+	data := map[string]string{}
+	if normalized != nil {
+		message = fmt.Sprintf("Header policy set: %d stripped, %d injected", len(normalized.Strip), len(normalized.Inject))
+		injected := make([]string, 0, len(normalized.Inject))
+		for name := range normalized.Inject {
+			injected = append(injected, name)
+		}
+		sort.Strings(injected)
+		data["strip"] = strings.Join(normalized.Strip, ",")
+		data["inject"] = strings.Join(injected, ",")
+	}
+	s.events.record(ctx, plan.ID, nil, domain.EventPlanHeadersSet, message, data)
+	s.logger.Info("Updated plan header policy",
+		zap.String("plan_id", plan.ID.String()),
+		zap.Bool("removed", normalized == nil),
+	)
+
+	return plan, nil
+}
+
+// SetResponseCache turns response caching of a plan off. 3proxy does not
+// cache, so enabling it is refused once the request is validated, and only
+// a cache stored before can be cleared.
+func (s *planService) SetResponseCache(ctx context.Context, planID uuid.UUID, req *domain.ResponseCacheRequest) (*domain.ProxyPlan, error) {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.validateResponseCache(plan, req); err != nil {
+		return nil, err
+	}
+
+	plan.ResponseCache = nil
+	plan.UpdatedAt = time.Now()
+	if err := s.planRepo.Update(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to update plan: %w", err)
+	}
+
+	s.events.record(ctx, plan.ID, nil, domain.EventPlanCacheSet, "Response cache disabled", nil)
+	s.logger.Info("Disabled plan response cache", zap.String("plan_id", plan.ID.String()))
+
+	return plan, nil
+}
+
+// validateResponseCache checks a response cache request against the
+// proxy.cache limits. A valid request to enable the cache is still refused,
+// as no backend applies it.
+func (s *planService) validateResponseCache(plan *domain.ProxyPlan, req *domain.ResponseCacheRequest) error {
+	if !req.Enabled {
+		return nil
+	}
+
+	limits := s.cfg.Proxy.Cache
+	if plan.PlanType != domain.PlanTypeDatacenter {
+		return &domain.PolicyError{PlanType: plan.PlanTypeKey, Field: "response_cache", Reason: "is only available for datacenter plans"}
+	}
+	maxTTL := int(limits.MaxTTL / time.Second)
+	if req.TTLSeconds < 0 || req.TTLSeconds > maxTTL {
+		return &domain.PolicyError{PlanType: plan.PlanTypeKey, Field: "ttl_seconds", Reason: fmt.Sprintf("must be between 1 and %d", maxTTL)}
+	}
+	if req.MaxSizeMB < 0 || req.MaxSizeMB > limits.MaxSizeMB {
+		return &domain.PolicyError{PlanType: plan.PlanTypeKey, Field: "max_size_mb", Reason: fmt.Sprintf("must be between 1 and %d", limits.MaxSizeMB)}
+	}
+	return &domain.PolicyError{PlanType: plan.PlanTypeKey, Field: "response_cache", Reason: "is not supported by the 3proxy backend"}
+}
+
+// PurgeResponseCache is refused: 3proxy caches nothing, so there is nothing
+// to purge
+func (s *planService) PurgeResponseCache(ctx context.Context, planID uuid.UUID) (*domain.ProxyPlan, error) {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	return nil, &domain.PolicyError{PlanType: plan.PlanTypeKey, Field: "response_cache", Reason: "is not supported by the 3proxy backend"}
+}
+
+// restartRunningInstances restarts a plan's running instances so their
+// configs are rewritten from the plan
+func (s *planService) restartRunningInstances(ctx context.Context, planID uuid.UUID) error {
+	instances, err := s.instanceRepo.GetByPlanID(ctx, planID)
+	if err != nil {
+		return fmt.Errorf("failed to get plan instances: %w", err)
+	}
+	for _, instance := range instances {
+		if instance.Status != domain.InstanceStatusRunning {
+			continue
+		}
+		if err := restartAutomated(ctx, s.proxyService, s.logger, instance, "plan change"); err != nil {
+			return fmt.Errorf("failed to restart instance %s: %w", instance.ID, err)
+		}
+	}
+	return nil
+}
+
+// normalizeDestinations validates a requested destination allowlist
+func normalizeDestinations(planTypeKey string, destinations []string) ([]string, error) {
+	normalized, err := domain.NormalizeDestinations(destinations)
+	if err != nil {
+		return nil, &domain.PolicyError{PlanType: planTypeKey, Field: "allowed_destinations", Reason: err.Error()}
+	}
+	return normalized, nil
+}
+
+// normalizeHeaderPolicy validates a requested header policy
+func normalizeHeaderPolicy(planTypeKey string, policy *domain.HeaderPolicy) (*domain.HeaderPolicy, error) {
+	normalized, err := domain.NormalizeHeaderPolicy(policy)
+	if err != nil {
+		return nil, &domain.PolicyError{PlanType: planTypeKey, Field: "header_policy", Reason: err.Error()}
+	}
+	return normalized, nil
+}
+
+// errAutoRenewUnavailable rejects auto-renew when no billing integration is configured
+func errAutoRenewUnavailable(planTypeKey string) error {
+	return &domain.PolicyError{PlanType: planTypeKey, Field: "auto_renew", Reason: "requires billing.payment.url to be configured"}
+}
+
+// errInvalidExpiryAction rejects an unknown plan expiry action
+func errInvalidExpiryAction(planTypeKey, action string) error {
+	return &domain.PolicyError{PlanType: planTypeKey, Field: "expiry_action", Reason: fmt.Sprintf("%q is not stop, delete or downgrade", action)}
 }
 
 func (s *planService) CheckExpiredPlans(ctx context.Context) ([]*domain.ProxyPlan, error) {
 	return s.planRepo.GetExpired(ctx, time.Now())
 }
+
+// GetExpiringPlans returns active plans that expire within the given window,
+// optionally limited to one customer, so they can be chased for renewal
+func (s *planService) GetExpiringPlans(ctx context.Context, within time.Duration, customerID string) ([]*domain.ProxyPlan, error) {
+	now := time.Now()
+	plans, err := s.planRepo.GetExpiring(ctx, now, now.Add(within))
+	if err != nil {
+		return nil, err
+	}
+
+	if customerID == "" {
+		return plans, nil
+	}
+
+	filtered := make([]*domain.ProxyPlan, 0, len(plans))
+	for _, plan := range plans {
+		if plan.CustomerID == customerID {
+			filtered = append(filtered, plan)
+		}
+	}
+
+	return filtered, nil
+}
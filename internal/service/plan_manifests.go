@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+// changeSet collects the changes found while reconciling declared state,
+// applying each one unless it is a dry run. Without prune, resources that
+// are no longer declared are only reported.
+type changeSet struct {
+	dryRun  bool
+	prune   bool
+	logger  *zap.Logger
+	changes []domain.StateChange
+}
+
+// record adds a change, applying it unless this is a dry run
+func (cs *changeSet) record(change domain.StateChange, apply func() error) {
+	if !cs.dryRun {
+		if err := apply(); err != nil {
+			change.Error = err.Error()
+			cs.logger.Warn("Failed to apply declared state change",
+				zap.String("kind", change.Kind),
+				zap.String("name", change.Name),
+				zap.String("action", change.Action),
+				zap.Error(err))
+		} else {
+			change.Applied = true
+		}
+	}
+	cs.changes = append(cs.changes, change)
+}
+
+// conflict adds a difference that cannot be applied
+func (cs *changeSet) conflict(kind, name, detail string) {
+	cs.changes = append(cs.changes, domain.StateChange{Kind: kind, Name: name, Action: domain.ChangeConflict, Detail: detail})
+}
+
+// remove records a resource that is no longer declared, removing it when
+// pruning
+func (cs *changeSet) remove(change domain.StateChange, apply func() error) {
+	if !cs.prune {
+		change.Detail = strings.TrimSpace("would " + change.Action + " " + change.Detail)
+		change.Action = domain.ChangeUnmanaged
+		cs.changes = append(cs.changes, change)
+		return
+	}
+	cs.record(change, apply)
+}
+
+// list returns the changes, never nil
+func (cs *changeSet) list() []domain.StateChange {
+	if cs.changes == nil {
+		return []domain.StateChange{}
+	}
+	return cs.changes
+}
+
+// reconcilePlanManifests creates the plans of new manifests and brings
+// existing ones in line. tracked maps manifest names to the plans created for
+// them and is updated as plans are created and deleted; a manifest whose plan
+// no longer exists gets a new one. Tracked plans no longer declared are
+// removed when inScope accepts them, or always when inScope is nil.
+func reconcilePlanManifests(
+	ctx context.Context,
+	planService PlanService,
+	cs *changeSet,
+	manifests []domain.PlanManifest,
+	tracked map[string]uuid.UUID,
+	inScope func(plan *domain.ProxyPlan) bool,
+) error {
+	all, err := planService.GetAllPlans(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get plans: %w", err)
+	}
+	plans := make(map[uuid.UUID]*domain.ProxyPlan, len(all))
+	for _, plan := range all {
+		plans[plan.ID] = plan
+	}
+
+	declared := make(map[string]bool, len(manifests))
+	for _, manifest := range manifests {
+		manifest := manifest
+		declared[manifest.Name] = true
+
+		plan := plans[tracked[manifest.Name]]
+		if plan == nil {
+			cs.record(domain.StateChange{Kind: domain.ResourcePlan, Name: manifest.Name, Action: domain.ChangeCreate}, func() error {
+				resp, err := planService.CreatePlan(ctx, manifest.Request())
+				if err != nil {
+					return err
+				}
+				tracked[manifest.Name] = resp.PlanID
+				return nil
+			})
+			continue
+		}
+		reconcilePlanManifest(ctx, planService, cs, manifest, plan)
+	}
+
+	for _, name := range sortedKeys(tracked) {
+		if declared[name] {
+			continue
+		}
+		planID := tracked[name]
+		plan := plans[planID]
+		if plan == nil {
+			delete(tracked, name)
+			continue
+		}
+		if inScope != nil && !inScope(plan) {
+			continue
+		}
+		name := name
+		cs.remove(domain.StateChange{Kind: domain.ResourcePlan, Name: name, Action: domain.ChangeDelete, Detail: planID.String()}, func() error {
+			if err := planService.DeletePlan(ctx, planID); err != nil {
+				return err
+			}
+			delete(tracked, name)
+			return nil
+		})
+	}
+	return nil
+}
+
+// reconcilePlanManifest applies the differences between a manifest and its
+// plan that can be changed in place
+func reconcilePlanManifest(ctx context.Context, planService PlanService, cs *changeSet, manifest domain.PlanManifest, plan *domain.ProxyPlan) {
+	change := func(action, detail string) domain.StateChange {
+		return domain.StateChange{Kind: domain.ResourcePlan, Name: manifest.Name, Action: action, Detail: detail}
+	}
+
+	for _, field := range []struct{ name, live, want string }{
+		{"customer_id", plan.CustomerID, manifest.CustomerID},
+		{"provider", plan.Provider, manifest.Provider},
+		{"region", plan.Region, manifest.Region},
+		{"plan_type", plan.PlanType, manifest.PlanType},
+	} {
+		if field.live != field.want {
+			cs.conflict(domain.ResourcePlan, manifest.Name,
+				fmt.Sprintf("%s is %s, manifest has %s; rename the manifest to replace the plan", field.name, field.live, field.want))
+		}
+	}
+
+	switch {
+	case manifest.Bandwidth > plan.Bandwidth:
+		topUp := manifest.Bandwidth - plan.Bandwidth
+		cs.record(change(domain.ChangeTopUp, fmt.Sprintf("%d GB", topUp)), func() error {
+			_, err := planService.TopUpPlan(ctx, plan.ID, topUp)
+			return err
+		})
+	case manifest.Bandwidth < plan.Bandwidth:
+		cs.conflict(domain.ResourcePlan, manifest.Name,
+			fmt.Sprintf("bandwidth is %d GB, manifest has %d GB; bandwidth cannot be reduced", plan.Bandwidth, manifest.Bandwidth))
+	}
+
+	if manifest.AutoRenew != plan.AutoRenew {
+		cs.record(change(domain.ChangeUpdate, "auto_renew"), func() error {
+			_, err := planService.SetAutoRenew(ctx, plan.ID, manifest.AutoRenew)
+			return err
+		})
+	}
+	if manifest.ExpiryAction != plan.ExpiryAction {
+		cs.record(change(domain.ChangeUpdate, "expiry_action"), func() error {
+			_, err := planService.SetExpiryAction(ctx, plan.ID, manifest.ExpiryAction)
+			return err
+		})
+	}
+	if !sameStrings(manifest.AllowedDestinations, plan.AllowedDestinations) {
+		cs.record(change(domain.ChangeUpdate, "allowed_destinations"), func() error {
+			_, err := planService.SetAllowedDestinations(ctx, plan.ID, manifest.AllowedDestinations)
+			return err
+		})
+	}
+}
+
+// checkPlanManifests requires every manifest to have a unique name and a
+// customer
+func checkPlanManifests(manifests []domain.PlanManifest) error {
+	seen := make(map[string]bool, len(manifests))
+	for _, manifest := range manifests {
+		if manifest.Name == "" {
+			return fmt.Errorf("plan manifest without a name")
+		}
+		if seen[manifest.Name] {
+			return fmt.Errorf("plan %s is declared twice", manifest.Name)
+		}
+		seen[manifest.Name] = true
+		if manifest.CustomerID == "" {
+			return fmt.Errorf("plan %s has no customer_id", manifest.Name)
+		}
+	}
+	return nil
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,404 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+var (
+	// ErrPlanTypeNotFound is returned for a plan type that does not exist
+	ErrPlanTypeNotFound = errors.New("plan type not found")
+	// ErrPlanTypeExists is returned when creating a plan type whose key is taken
+	ErrPlanTypeExists = errors.New("plan type already exists")
+	// ErrInvalidPlanType is returned for a plan type that fails validation
+	ErrInvalidPlanType = errors.New("invalid plan type")
+	// ErrUpstreamUnreachable is returned when a plan type's upstream does not
+	// accept connections
+	ErrUpstreamUnreachable = errors.New("upstream unreachable")
+	// ErrPlanTypeNotApplied is returned when a plan type was saved but the
+	// nginx config of a region listing it could not be regenerated
+	ErrPlanTypeNotApplied = errors.New("plan type saved but not applied")
+)
+
+// minLocalPort keeps plan type port ranges clear of privileged ports
+const minLocalPort = 1024
+
+var (
+	planTypeNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	upstreamNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+// PlanTypeService manages plan types at runtime. Changes are checked
+// against the other plan types and the upstream, applied to the port
+// manager's pools, stored in the plan type repository and swapped into the
+// registry the other services look plan types up in.
+type PlanTypeService struct {
+	probeTimeout time.Duration
+	logger       *zap.Logger
+	repo         repository.PlanTypeRepository
+	instanceRepo repository.InstanceRepository
+	planTypes    *PlanTypeRegistry
+	regions      *RegionRegistry
+	forwarders   *DNSForwarders
+	ports        *PortManager
+	nginx        *NginxManager
+
+	// mu serializes changes so validation sees every earlier change
+	mu sync.Mutex
+}
+
+// NewPlanTypeService creates a new plan type service
+func NewPlanTypeService(
+	cfg *config.Config,
+	logger *zap.Logger,
+	repo repository.PlanTypeRepository,
+	instanceRepo repository.InstanceRepository,
+	planTypes *PlanTypeRegistry,
+	regions *RegionRegistry,
+	forwarders *DNSForwarders,
+	ports *PortManager,
+	nginx *NginxManager,
+) *PlanTypeService {
+	return &PlanTypeService{
+		probeTimeout: cfg.Proxy.UpstreamProbeTimeout,
+		logger:       logger,
+		repo:         repo,
+		instanceRepo: instanceRepo,
+		planTypes:    planTypes,
+		regions:      regions,
+		forwarders:   forwarders,
+		ports:        ports,
+		nginx:        nginx,
+	}
+}
+
+// List returns every plan type by key
+func (s *PlanTypeService) List() map[string]*domain.PlanTypeConfig {
+	return s.planTypes.All()
+}
+
+// Get returns a plan type by key
+func (s *PlanTypeService) Get(key string) (*domain.PlanTypeConfig, error) {
+	planType := s.planTypes.Get(key)
+	if planType == nil {
+		return nil, ErrPlanTypeNotFound
+	}
+	return planType, nil
+}
+
+// Create validates a new plan type, stores it under its
+// provider_region_plantype key and applies it
+func (s *PlanTypeService) Create(ctx context.Context, planType *domain.PlanTypeConfig) (*domain.PlanTypeConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := planType.GetPlanTypeKey()
+	if s.planTypes.Get(key) != nil {
+		return nil, ErrPlanTypeExists
+	}
+	if err := s.validate(ctx, key, planType); err != nil {
+		return nil, err
+	}
+
+	if err := s.ports.ApplyPlanType(key, planType); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPlanType, err)
+	}
+	if err := s.repo.Create(ctx, key, planType); err != nil {
+		return nil, fmt.Errorf("failed to store plan type: %w", err)
+	}
+	s.planTypes.Set(key, planType)
+
+	s.logger.Info("Created plan type", zap.String("plan_type", key))
+	warnNegativeMargin(s.logger, key, planType)
+	return planType, s.apply(ctx, key)
+}
+
+// Update replaces a plan type and applies it. Provider, region and plan
+// type make up the key and cannot change; the port range may move as long
+// as it still holds every allocated port.
+func (s *PlanTypeService) Update(ctx context.Context, key string, planType *domain.PlanTypeConfig) (*domain.PlanTypeConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.planTypes.Get(key)
+	if previous == nil {
+		return nil, ErrPlanTypeNotFound
+	}
+	if planType.Provider == "" && planType.Region == "" && planType.PlanType == "" {
+		planType.Provider, planType.Region, planType.PlanType = previous.Provider, previous.Region, previous.PlanType
+	}
+	if planType.GetPlanTypeKey() != key {
+		return nil, fmt.Errorf("%w: provider, region and plan_type cannot be changed", ErrInvalidPlanType)
+	}
+	if err := s.validate(ctx, key, planType); err != nil {
+		return nil, err
+	}
+
+	err := s.replace(ctx, key, planType, previous)
+	if err == nil || errors.Is(err, ErrPlanTypeNotApplied) {
+		warnNegativeMargin(s.logger, key, planType)
+	}
+	return planType, err
+}
+
+// SetDisabled disables or re-enables a plan type. Disabled plan types
+// accept no new plans; plans already using them keep running.
+func (s *PlanTypeService) SetDisabled(ctx context.Context, key string, disabled bool) (*domain.PlanTypeConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.planTypes.Get(key)
+	if previous == nil {
+		return nil, ErrPlanTypeNotFound
+	}
+	if previous.Disabled == disabled {
+		return previous, nil
+	}
+
+	// Registry entries are shared with readers, so change a copy
+	planType := *previous
+	planType.Disabled = disabled
+	if err := s.repo.Update(ctx, key, &planType); err != nil {
+		return nil, fmt.Errorf("failed to store plan type: %w", err)
+	}
+	s.planTypes.Set(key, &planType)
+
+	s.logger.Info("Changed plan type state", zap.String("plan_type", key), zap.Bool("disabled", disabled))
+	return &planType, nil
+}
+
+// SetPricing replaces a plan type's upstream cost and retail price, or
+// removes them when pricing is nil. Prices are changed by hand when a
+// provider changes its rates; a warning is logged if the plan type then
+// sells below cost.
+func (s *PlanTypeService) SetPricing(ctx context.Context, key string, pricing *domain.Pricing, now time.Time) (*domain.PlanTypeConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.planTypes.Get(key)
+	if previous == nil {
+		return nil, ErrPlanTypeNotFound
+	}
+	if pricing != nil {
+		if err := pricing.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPlanType, err)
+		}
+		updatedAt := now.UTC()
+		pricing.UpdatedAt = &updatedAt
+	}
+
+	// Registry entries are shared with readers, so change a copy
+	planType := *previous
+	planType.Pricing = pricing
+	if err := s.repo.Update(ctx, key, &planType); err != nil {
+		return nil, fmt.Errorf("failed to store plan type: %w", err)
+	}
+	s.planTypes.Set(key, &planType)
+
+	if pricing == nil {
+		s.logger.Info("Removed plan type pricing", zap.String("plan_type", key))
+	} else {
+		s.logger.Info("Changed plan type pricing",
+			zap.String("plan_type", key),
+			zap.Float64("upstream_cost_per_gb", pricing.UpstreamCostPerGB),
+			zap.Float64("retail_price_per_gb", pricing.RetailPricePerGB))
+	}
+	warnNegativeMargin(s.logger, key, &planType)
+	return &planType, nil
+}
+
+// warnNegativeMargin logs a warning when a plan type sells below its
+// upstream cost
+func warnNegativeMargin(logger *zap.Logger, key string, planType *domain.PlanTypeConfig) {
+	if planType.Pricing == nil || !planType.Pricing.Negative() {
+		return
+	}
+	logger.Warn("Plan type sells below upstream cost",
+		zap.String("plan_type", key),
+		zap.Float64("upstream_cost_per_gb", planType.Pricing.UpstreamCostPerGB),
+		zap.Float64("retail_price_per_gb", planType.Pricing.RetailPricePerGB),
+		zap.Float64("margin_per_gb", planType.Pricing.MarginPerGB()))
+}
+
+// replace applies an updated plan type's pools, stores it and swaps it in.
+// The previous pools are restored if it cannot be stored.
+func (s *PlanTypeService) replace(ctx context.Context, key string, planType, previous *domain.PlanTypeConfig) error {
+	if err := s.ports.ApplyPlanType(key, planType); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPlanType, err)
+	}
+	if err := s.repo.Update(ctx, key, planType); err != nil {
+		if restoreErr := s.ports.ApplyPlanType(key, previous); restoreErr != nil {
+			s.logger.Error("Failed to restore plan type pools", zap.String("plan_type", key), zap.Error(restoreErr))
+		}
+		return fmt.Errorf("failed to store plan type: %w", err)
+	}
+	s.planTypes.Set(key, planType)
+
+	s.logger.Info("Updated plan type", zap.String("plan_type", key))
+	return s.apply(ctx, key)
+}
+
+// apply regenerates the nginx config of every region listing a plan type,
+// so a new or renamed upstream takes effect
+func (s *PlanTypeService) apply(ctx context.Context, key string) error {
+	var regions []*domain.Region
+	for _, region := range s.regions.List() {
+		if containsString(region.PlanTypes, key) {
+			regions = append(regions, region)
+		}
+	}
+	if len(regions) == 0 {
+		return nil
+	}
+
+	instances, err := s.instanceRepo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: failed to get instances: %w", ErrPlanTypeNotApplied, err)
+	}
+
+	var errs []error
+	for _, region := range regions {
+		if err := s.nginx.SyncRegion(ctx, region, instances); err != nil {
+			errs = append(errs, fmt.Errorf("region %s: %w", region.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		s.logger.Error("Failed to apply plan type", zap.String("plan_type", key), zap.Errors("errors", errs))
+		return fmt.Errorf("%w: %w", ErrPlanTypeNotApplied, errors.Join(errs...))
+	}
+	return nil
+}
+
+// validate fills in a plan type's defaults and checks it against the other
+// plan types. Port ranges and egress IPs must not collide with other plan
+// types, and the upstream must accept connections.
+func (s *PlanTypeService) validate(ctx context.Context, key string, planType *domain.PlanTypeConfig) error {
+	if planType.Provider != domain.ProviderProxiesFo && planType.Provider != domain.ProviderNettify {
+		return fmt.Errorf("%w: unknown provider %q", ErrInvalidPlanType, planType.Provider)
+	}
+	if s.regions.Get(planType.Region) == nil {
+		return fmt.Errorf("%w: unknown region %q", ErrInvalidPlanType, planType.Region)
+	}
+	if !planTypeNamePattern.MatchString(planType.PlanType) {
+		return fmt.Errorf("%w: plan_type must be lowercase letters, digits, - and _", ErrInvalidPlanType)
+	}
+	setPlanTypeDefaults(key, planType)
+	if !upstreamNamePattern.MatchString(planType.NginxUpstreamName) {
+		return fmt.Errorf("%w: nginx_upstream_name must be letters, digits and _", ErrInvalidPlanType)
+	}
+	if planType.UpstreamHost == "" {
+		return fmt.Errorf("%w: upstream_host is required", ErrInvalidPlanType)
+	}
+	if planType.UpstreamPort < 1 || planType.UpstreamPort > 65535 {
+		return fmt.Errorf("%w: upstream_port must be between 1 and 65535", ErrInvalidPlanType)
+	}
+	if planType.OutboundPort < 1 || planType.OutboundPort > 65535 {
+		return fmt.Errorf("%w: outbound_port must be between 1 and 65535", ErrInvalidPlanType)
+	}
+
+	ports := planType.LocalPortRange
+	if ports.Start < minLocalPort || ports.End > 65535 || ports.Start > ports.End {
+		return fmt.Errorf("%w: local_port_range must lie between %d and 65535 with start <= end", ErrInvalidPlanType, minLocalPort)
+	}
+	if planType.BindAddress != "" && net.ParseIP(planType.BindAddress) == nil {
+		return fmt.Errorf("%w: bind_address %q is not an IP address", ErrInvalidPlanType, planType.BindAddress)
+	}
+
+	egressIPs := make(map[string]bool)
+	if planType.Egress != nil {
+		for _, ip := range planType.Egress.IPs {
+			if net.ParseIP(ip) == nil {
+				return fmt.Errorf("%w: egress IP %q is not an IP address", ErrInvalidPlanType, ip)
+			}
+			if egressIPs[ip] {
+				return fmt.Errorf("%w: egress IP %s is listed twice", ErrInvalidPlanType, ip)
+			}
+			egressIPs[ip] = true
+		}
+	}
+
+	for otherKey, other := range s.planTypes.All() {
+		if otherKey == key {
+			continue
+		}
+		if ports.Start <= other.LocalPortRange.End && other.LocalPortRange.Start <= ports.End {
+			return fmt.Errorf("%w: local_port_range %d-%d overlaps plan type %s (%d-%d)", ErrInvalidPlanType,
+				ports.Start, ports.End, otherKey, other.LocalPortRange.Start, other.LocalPortRange.End)
+		}
+		if other.Egress == nil {
+			continue
+		}
+		for _, ip := range other.Egress.IPs {
+			if egressIPs[ip] {
+				return fmt.Errorf("%w: egress IP %s is also listed by %s", ErrInvalidPlanType, ip, otherKey)
+			}
+		}
+	}
+
+	if planType.Pricing != nil {
+		if err := planType.Pricing.Validate(); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidPlanType, err)
+		}
+	}
+
+	if planType.Connections != nil {
+		if err := planType.Connections.Validate(); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidPlanType, err)
+		}
+	}
+
+	if planType.Accounting != nil {
+		if err := planType.Accounting.Validate(); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidPlanType, err)
+		}
+	}
+
+	if planType.Proxy != nil && len(planType.Proxy.DoH) > 0 {
+		if _, err := s.forwarders.Address(planType.Proxy.DoH); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidPlanType, err)
+		}
+	}
+
+	return s.checkUpstreams(ctx, planType)
+}
+
+// checkUpstreams dials the upstream host and any latency-selection hosts
+func (s *PlanTypeService) checkUpstreams(ctx context.Context, planType *domain.PlanTypeConfig) error {
+	hosts := upstreamCandidates(planType)
+	if len(hosts) == 0 {
+		hosts = []string{planType.UpstreamHost}
+	}
+
+	dialer := net.Dialer{Timeout: s.probeTimeout}
+	for _, host := range hosts {
+		address := net.JoinHostPort(host, strconv.Itoa(planType.UpstreamPort))
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrUpstreamUnreachable, address, err)
+		}
+		conn.Close()
+	}
+	return nil
+}
+
+// setPlanTypeDefaults fills in a plan type's name and nginx upstream name
+func setPlanTypeDefaults(key string, planType *domain.PlanTypeConfig) {
+	if planType.Name == "" {
+		planType.Name = key
+	}
+	if planType.NginxUpstreamName == "" {
+		planType.NginxUpstreamName = fmt.Sprintf("oceanproxy_%s_%s", planType.Region, planType.PlanType)
+	}
+}
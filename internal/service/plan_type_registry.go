@@ -0,0 +1,74 @@
+package service
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+// PlanTypeRegistry holds the plan type configurations services look
+// instances up by. Plan types can be changed through the admin API while
+// requests are served, so access is locked; a changed plan type is swapped in
+// whole, never edited in place.
+type PlanTypeRegistry struct {
+	mu        sync.RWMutex
+	planTypes map[string]*domain.PlanTypeConfig
+}
+
+// NewPlanTypeRegistry creates a registry holding planTypes by key
+func NewPlanTypeRegistry(planTypes map[string]*domain.PlanTypeConfig) *PlanTypeRegistry {
+	registry := &PlanTypeRegistry{planTypes: make(map[string]*domain.PlanTypeConfig, len(planTypes))}
+	for key, planType := range planTypes {
+		registry.planTypes[key] = planType
+	}
+	return registry
+}
+
+// Get returns a plan type by key, or nil if there is none
+func (r *PlanTypeRegistry) Get(key string) *domain.PlanTypeConfig {
+	planType, _ := r.Lookup(key)
+	return planType
+}
+
+// Lookup returns a plan type by key and whether it exists
+func (r *PlanTypeRegistry) Lookup(key string) (*domain.PlanTypeConfig, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	planType, exists := r.planTypes[key]
+	return planType, exists
+}
+
+// All returns a snapshot of every plan type by key
+func (r *PlanTypeRegistry) All() map[string]*domain.PlanTypeConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	planTypes := make(map[string]*domain.PlanTypeConfig, len(r.planTypes))
+	for key, planType := range r.planTypes {
+		planTypes[key] = planType
+	}
+	return planTypes
+}
+
+// Keys returns every plan type key, sorted
+func (r *PlanTypeRegistry) Keys() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]string, 0, len(r.planTypes))
+	for key := range r.planTypes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Set adds or replaces a plan type
+func (r *PlanTypeRegistry) Set(key string, planType *domain.PlanTypeConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.planTypes[key] = planType
+}
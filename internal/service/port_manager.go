@@ -4,6 +4,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 
 	"go.uber.org/zap"
@@ -11,24 +12,32 @@ import (
 	"github.com/je265/oceanproxy/internal/domain"
 )
 
-// PortManager manages port pools for different plan types
+// PortManager manages port and egress IP pools for different plan types.
+// Allocations are kept in the pools, or in store when one is given so that
+// several API replicas can allocate from the same pools; the pools then
+// only hold each plan type's port range and egress IPs.
 type PortManager struct {
-	mu        sync.RWMutex
-	logger    *zap.Logger
-	pools     map[string]*domain.PortPool // plan_type_key -> port_pool
-	planTypes map[string]*domain.PlanTypeConfig
+	mu          sync.RWMutex
+	logger      *zap.Logger
+	pools       map[string]*domain.PortPool   // plan_type_key -> port_pool
+	egressPools map[string]*domain.EgressPool // plan_type_key -> egress_pool
+	planTypes   *PlanTypeRegistry
+	store       PortStore
 }
 
-// NewPortManager creates a new port manager
-func NewPortManager(logger *zap.Logger, planTypes map[string]*domain.PlanTypeConfig) *PortManager {
+// NewPortManager creates a new port manager; store is nil to keep
+// allocations in the process
+func NewPortManager(logger *zap.Logger, planTypes *PlanTypeRegistry, store PortStore) *PortManager {
 	pm := &PortManager{
-		logger:    logger,
-		pools:     make(map[string]*domain.PortPool),
-		planTypes: planTypes,
+		logger:      logger,
+		pools:       make(map[string]*domain.PortPool),
+		egressPools: make(map[string]*domain.EgressPool),
+		planTypes:   planTypes,
+		store:       store,
 	}
 
 	// Initialize port pools for each plan type
-	for key, planType := range planTypes {
+	for key, planType := range planTypes.All() {
 		pool := domain.NewPortPool(key, planType.LocalPortRange)
 		pm.pools[key] = pool
 
@@ -38,6 +47,16 @@ func NewPortManager(logger *zap.Logger, planTypes map[string]*domain.PlanTypeCon
 			zap.Int("end_port", planType.LocalPortRange.End),
 			zap.Int("pool_size", planType.LocalPortRange.Size()),
 		)
+
+		if planType.Egress != nil && len(planType.Egress.IPs) > 0 {
+			pm.egressPools[key] = domain.NewEgressPool(key, *planType.Egress)
+
+			logger.Info("Initialized egress IP pool",
+				zap.String("plan_type", key),
+				zap.Strings("ips", planType.Egress.IPs),
+				zap.Bool("shared", planType.Egress.Shared),
+			)
+		}
 	}
 
 	return pm
@@ -45,15 +64,23 @@ func NewPortManager(logger *zap.Logger, planTypes map[string]*domain.PlanTypeCon
 
 // AllocatePort allocates a port for a specific plan type
 func (pm *PortManager) AllocatePort(ctx context.Context, planTypeKey, planID string) (int, error) {
+	// Holding the read lock keeps ApplyPlanType from swapping the pool mid-change
 	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
 	pool, exists := pm.pools[planTypeKey]
-	pm.mu.RUnlock()
 
 	if !exists {
-		return 0, fmt.Errorf("plan type %s not found", planTypeKey)
+		return 0, domain.NotFoundf("plan type %s not found", planTypeKey)
 	}
 
-	port, err := pool.AllocatePort(planID)
+	var port int
+	var err error
+	if pm.store != nil {
+		port, err = pm.store.AllocatePort(ctx, planTypeKey, pool.Range(), planID)
+	} else {
+		port, err = pool.AllocatePort(planID)
+	}
 	if err != nil {
 		pm.logger.Error("Failed to allocate port",
 			zap.String("plan_type", planTypeKey),
@@ -74,15 +101,23 @@ func (pm *PortManager) AllocatePort(ctx context.Context, planTypeKey, planID str
 
 // ReleasePort releases a port back to its pool
 func (pm *PortManager) ReleasePort(ctx context.Context, planTypeKey string, port int) error {
+	// Holding the read lock keeps ApplyPlanType from swapping the pool mid-change
 	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
 	pool, exists := pm.pools[planTypeKey]
-	pm.mu.RUnlock()
 
 	if !exists {
-		return fmt.Errorf("plan type %s not found", planTypeKey)
+		return domain.NotFoundf("plan type %s not found", planTypeKey)
 	}
 
-	if err := pool.ReleasePort(port); err != nil {
+	var err error
+	if pm.store != nil {
+		err = pm.store.ReleasePort(ctx, planTypeKey, port)
+	} else {
+		err = pool.ReleasePort(port)
+	}
+	if err != nil {
 		pm.logger.Error("Failed to release port",
 			zap.String("plan_type", planTypeKey),
 			zap.Int("port", port),
@@ -99,14 +134,315 @@ func (pm *PortManager) ReleasePort(ctx context.Context, planTypeKey string, port
 	return nil
 }
 
+// AllocateEgressIP allocates an egress IP for a plan. Plan types without
+// egress IPs return an empty string.
+func (pm *PortManager) AllocateEgressIP(ctx context.Context, planTypeKey, planID string) (string, error) {
+	// Holding the read lock keeps ApplyPlanType from swapping the pool mid-change
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	pool, exists := pm.egressPools[planTypeKey]
+
+	if !exists {
+		return "", nil
+	}
+
+	var ip string
+	var err error
+	if pm.store != nil {
+		ip, err = pm.store.AllocateEgressIP(ctx, planTypeKey, pool.Settings(), planID)
+	} else {
+		ip, err = pool.AllocateIP(planID)
+	}
+	if err != nil {
+		pm.logger.Error("Failed to allocate egress IP",
+			zap.String("plan_type", planTypeKey),
+			zap.String("plan_id", planID),
+			zap.Error(err),
+		)
+		return "", err
+	}
+
+	pm.logger.Info("Allocated egress IP",
+		zap.String("plan_type", planTypeKey),
+		zap.String("plan_id", planID),
+		zap.String("egress_ip", ip),
+	)
+
+	return ip, nil
+}
+
+// ReleaseEgressIP releases a plan's egress IP back to its pool. An empty IP
+// is a no-op.
+func (pm *PortManager) ReleaseEgressIP(ctx context.Context, planTypeKey, ip, planID string) error {
+	if ip == "" {
+		return nil
+	}
+
+	// Holding the read lock keeps ApplyPlanType from swapping the pool mid-change
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	pool, exists := pm.egressPools[planTypeKey]
+
+	if !exists {
+		return fmt.Errorf("plan type %s has no egress IPs", planTypeKey)
+	}
+
+	var err error
+	if pm.store != nil {
+		err = pm.store.ReleaseEgressIP(ctx, planTypeKey, ip, planID)
+	} else {
+		err = pool.ReleaseIP(ip, planID)
+	}
+	if err != nil {
+		pm.logger.Error("Failed to release egress IP",
+			zap.String("plan_type", planTypeKey),
+			zap.String("egress_ip", ip),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	pm.logger.Info("Released egress IP",
+		zap.String("plan_type", planTypeKey),
+		zap.String("plan_id", planID),
+		zap.String("egress_ip", ip),
+	)
+
+	return nil
+}
+
+// EgressInventory returns every egress IP with the plans using it, by plan
+// type then IP in configuration order
+func (pm *PortManager) EgressInventory() []domain.EgressIPAllocation {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	keys := make([]string, 0, len(pm.egressPools))
+	for key := range pm.egressPools {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	inventory := []domain.EgressIPAllocation{}
+	for _, key := range keys {
+		allocations, err := pm.egressAllocations(context.Background(), key, pm.egressPools[key])
+		if err != nil {
+			pm.logger.Error("Failed to load egress IP allocations", zap.String("plan_type", key), zap.Error(err))
+			continue
+		}
+		inventory = append(inventory, allocations...)
+	}
+	return inventory
+}
+
+// egressAllocations returns the IPs of an egress pool with the plans using them
+func (pm *PortManager) egressAllocations(ctx context.Context, key string, pool *domain.EgressPool) ([]domain.EgressIPAllocation, error) {
+	if pm.store == nil {
+		return pool.Allocations(), nil
+	}
+
+	settings := pool.Settings()
+	plans, err := pm.store.EgressIPs(ctx, key, settings.IPs)
+	if err != nil {
+		return nil, err
+	}
+	allocations := make([]domain.EgressIPAllocation, 0, len(settings.IPs))
+	for _, ip := range settings.IPs {
+		planIDs := plans[ip]
+		if planIDs == nil {
+			planIDs = []string{}
+		}
+		allocations = append(allocations, domain.EgressIPAllocation{
+			PlanTypeKey: key,
+			IP:          ip,
+			Shared:      settings.Shared,
+			PlanIDs:     planIDs,
+		})
+	}
+	return allocations, nil
+}
+
+// allocatedPorts returns the allocated ports of a pool mapped to their plans
+func (pm *PortManager) allocatedPorts(ctx context.Context, key string, pool *domain.PortPool) (map[int]string, error) {
+	if pm.store == nil {
+		return pool.GetAllocatedPorts(), nil
+	}
+	return pm.store.Ports(ctx, key)
+}
+
+// CheckStore checks the store allocations are kept in can be reached
+func (pm *PortManager) CheckStore(ctx context.Context) error {
+	if pm.store == nil {
+		return nil
+	}
+	return pm.store.Ping(ctx)
+}
+
+// Reconcile reserves the ports of existing instances so new allocations
+// cannot collide with them. It returns the number of ports reserved.
+func (pm *PortManager) Reconcile(ctx context.Context, instances []*domain.ProxyInstance) int {
+	reserved := 0
+	for _, instance := range instances {
+		pm.mu.RLock()
+		pool, exists := pm.pools[instance.PlanTypeKey]
+		pm.mu.RUnlock()
+
+		if !exists {
+			pm.logger.Warn("Instance references unknown plan type, port not reserved",
+				zap.String("instance_id", instance.ID.String()),
+				zap.String("plan_type", instance.PlanTypeKey),
+				zap.Int("port", instance.LocalPort),
+			)
+			continue
+		}
+
+		var err error
+		if pm.store != nil {
+			err = pm.store.ReservePort(ctx, instance.PlanTypeKey, instance.LocalPort, instance.PlanID.String())
+		} else {
+			err = pool.ReservePort(instance.LocalPort, instance.PlanID.String())
+		}
+		if err != nil {
+			pm.logger.Error("Failed to reserve port for existing instance",
+				zap.String("instance_id", instance.ID.String()),
+				zap.String("plan_type", instance.PlanTypeKey),
+				zap.Int("port", instance.LocalPort),
+				zap.Error(err),
+			)
+			continue
+		}
+		reserved++
+
+		if instance.EgressIP == "" {
+			continue
+		}
+		pm.mu.RLock()
+		egressPool, exists := pm.egressPools[instance.PlanTypeKey]
+		pm.mu.RUnlock()
+		if !exists {
+			pm.logger.Warn("Instance egress IP is no longer configured for its plan type",
+				zap.String("instance_id", instance.ID.String()),
+				zap.String("plan_type", instance.PlanTypeKey),
+				zap.String("egress_ip", instance.EgressIP),
+			)
+			continue
+		}
+		if pm.store != nil {
+			err = pm.store.ReserveEgressIP(ctx, instance.PlanTypeKey, egressPool.Settings(), instance.EgressIP, instance.PlanID.String())
+		} else {
+			err = egressPool.ReserveIP(instance.EgressIP, instance.PlanID.String())
+		}
+		if err != nil {
+			pm.logger.Error("Failed to reserve egress IP for existing instance",
+				zap.String("instance_id", instance.ID.String()),
+				zap.String("plan_type", instance.PlanTypeKey),
+				zap.String("egress_ip", instance.EgressIP),
+				zap.Error(err),
+			)
+		}
+	}
+
+	pm.logger.Info("Reconciled port allocations",
+		zap.Int("instances", len(instances)),
+		zap.Int("reserved", reserved),
+	)
+
+	return reserved
+}
+
+// ApplyPlanType creates the pools of a new plan type, or rebuilds those of
+// a changed one with their allocations. It changes nothing and fails if a
+// port or egress IP in use falls outside the new configuration.
+func (pm *PortManager) ApplyPlanType(key string, planType *domain.PlanTypeConfig) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	ctx := context.Background()
+	pool := domain.NewPortPool(key, planType.LocalPortRange)
+	if previous, exists := pm.pools[key]; exists {
+		ports, err := pm.allocatedPorts(ctx, key, previous)
+		if err != nil {
+			return fmt.Errorf("failed to load port allocations: %w", err)
+		}
+		for port, planID := range ports {
+			// Allocations in a store stay there; the new pool only checks them
+			if err := pool.ReservePort(port, planID); err != nil {
+				return fmt.Errorf("port %d of plan %s does not fit the new port range: %w", port, planID, err)
+			}
+		}
+	}
+
+	var egressPool *domain.EgressPool
+	if planType.Egress != nil && len(planType.Egress.IPs) > 0 {
+		egressPool = domain.NewEgressPool(key, *planType.Egress)
+	}
+	if previous, exists := pm.egressPools[key]; exists {
+		allocations, err := pm.egressAllocations(ctx, key, previous)
+		if err != nil {
+			return fmt.Errorf("failed to load egress IP allocations: %w", err)
+		}
+		for _, allocation := range allocations {
+			for _, planID := range allocation.PlanIDs {
+				if egressPool == nil {
+					return fmt.Errorf("egress IP %s is in use by plan %s", allocation.IP, planID)
+				}
+				if err := egressPool.ReserveIP(allocation.IP, planID); err != nil {
+					return fmt.Errorf("egress IP %s of plan %s does not fit the new egress IPs: %w", allocation.IP, planID, err)
+				}
+			}
+		}
+	}
+
+	if pm.store != nil {
+		pool = domain.NewPortPool(key, planType.LocalPortRange)
+		if egressPool != nil {
+			egressPool = domain.NewEgressPool(key, *planType.Egress)
+		}
+	}
+	pm.pools[key] = pool
+	if egressPool != nil {
+		pm.egressPools[key] = egressPool
+	} else {
+		delete(pm.egressPools, key)
+	}
+
+	pm.logger.Info("Applied plan type pools",
+		zap.String("plan_type", key),
+		zap.Int("start_port", planType.LocalPortRange.Start),
+		zap.Int("end_port", planType.LocalPortRange.End),
+	)
+
+	return nil
+}
+
+// Allocations returns the allocated ports of every pool by plan type, each
+// mapped to the plan it was allocated for
+func (pm *PortManager) Allocations() map[string]map[int]string {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	allocations := make(map[string]map[int]string, len(pm.pools))
+	for key, pool := range pm.pools {
+		ports, err := pm.allocatedPorts(context.Background(), key, pool)
+		if err != nil {
+			pm.logger.Error("Failed to load port allocations", zap.String("plan_type", key), zap.Error(err))
+			continue
+		}
+		allocations[key] = ports
+	}
+	return allocations
+}
+
 // GetPlanTypeConfig returns the configuration for a plan type
 func (pm *PortManager) GetPlanTypeConfig(planTypeKey string) (*domain.PlanTypeConfig, error) {
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
 
-	config, exists := pm.planTypes[planTypeKey]
+	config, exists := pm.planTypes.Lookup(planTypeKey)
 	if !exists {
-		return nil, fmt.Errorf("plan type %s not found", planTypeKey)
+		return nil, domain.NotFoundf("plan type %s not found", planTypeKey)
 	}
 
 	return config, nil
@@ -118,7 +454,7 @@ func (pm *PortManager) GetAvailablePlanTypes() []string {
 	defer pm.mu.RUnlock()
 
 	var planTypes []string
-	for key := range pm.planTypes {
+	for key := range pm.planTypes.All() {
 		planTypes = append(planTypes, key)
 	}
 
@@ -132,11 +468,27 @@ func (pm *PortManager) GetPoolStats() map[string]PoolStats {
 
 	stats := make(map[string]PoolStats)
 	for key, pool := range pm.pools {
+		portRange := pool.Range()
+		total := portRange.Size()
+		allocated := pool.GetAllocatedCount()
+		if pm.store != nil {
+			ports, err := pm.store.Ports(context.Background(), key)
+			if err != nil {
+				pm.logger.Error("Failed to load port allocations", zap.String("plan_type", key), zap.Error(err))
+				continue
+			}
+			allocated = 0
+			for port := range ports {
+				if portRange.Contains(port) {
+					allocated++
+				}
+			}
+		}
 		stats[key] = PoolStats{
 			PlanType:       key,
-			TotalPorts:     pm.planTypes[key].LocalPortRange.Size(),
-			AllocatedPorts: pool.GetAllocatedCount(),
-			AvailablePorts: pool.GetAvailableCount(),
+			TotalPorts:     total,
+			AllocatedPorts: allocated,
+			AvailablePorts: total - allocated,
 		}
 	}
 
@@ -149,9 +501,12 @@ func (pm *PortManager) FindPlanTypeByProviderAndRegion(provider, region, planTyp
 	defer pm.mu.RUnlock()
 
 	key := fmt.Sprintf("%s_%s_%s", provider, region, planType)
-	if _, exists := pm.planTypes[key]; exists {
+	if config, exists := pm.planTypes.Lookup(key); exists {
+		if config.Disabled {
+			return "", domain.Invalidf("plan type %s is disabled", key)
+		}
 		return key, nil
 	}
 
-	return "", fmt.Errorf("plan type not found: provider=%s, region=%s, type=%s", provider, region, planType)
+	return "", domain.NotFoundf("plan type not found: provider=%s, region=%s, type=%s", provider, region, planType)
 }
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+// newBenchmarkPortManager returns a port manager with one plan type of
+// size ports
+func newBenchmarkPortManager(size int) *PortManager {
+	registry := NewPlanTypeRegistry(map[string]*domain.PlanTypeConfig{
+		"bench": {LocalPortRange: domain.PortRange{Start: 20000, End: 20000 + size - 1}},
+	})
+	return NewPortManager(zap.NewNop(), registry, nil)
+}
+
+func BenchmarkAllocatePort(b *testing.B) {
+	pm := newBenchmarkPortManager(10000)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		port, err := pm.AllocatePort(ctx, "bench", "plan")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := pm.ReleasePort(ctx, "bench", port); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkAllocatePortContended allocates and releases from one pool on
+// every P at once, as concurrent plan creations and deletions do
+func BenchmarkAllocatePortContended(b *testing.B) {
+	pm := newBenchmarkPortManager(10000)
+	ctx := context.Background()
+	var plans atomic.Int64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		planID := "plan-" + strconv.FormatInt(plans.Add(1), 10)
+		for pb.Next() {
+			port, err := pm.AllocatePort(ctx, "bench", planID)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			if err := pm.ReleasePort(ctx, "bench", port); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkReconcilePorts restores the allocations of existing instances,
+// as every server start does
+func BenchmarkReconcilePorts(b *testing.B) {
+	const count = 1000
+	instances := make([]*domain.ProxyInstance, 0, count)
+	for i := 0; i < count; i++ {
+		instances = append(instances, &domain.ProxyInstance{
+			PlanTypeKey: "bench",
+			LocalPort:   20000 + i,
+		})
+	}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		pm := newBenchmarkPortManager(10000)
+		b.StartTimer()
+		pm.Reconcile(ctx, instances)
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/pkg/redis"
+)
+
+// PortStore keeps port and egress IP allocations outside the process, so
+// that API replicas sharing it never allocate the same port or dedicated
+// egress IP. Every change is a single atomic operation on the store.
+type PortStore interface {
+	// AllocatePort allocates the lowest free port of portRange to planID
+	AllocatePort(ctx context.Context, planTypeKey string, portRange domain.PortRange, planID string) (int, error)
+	// ReservePort allocates a specific port to planID unless another plan has it
+	ReservePort(ctx context.Context, planTypeKey string, port int, planID string) error
+	ReleasePort(ctx context.Context, planTypeKey string, port int) error
+	// Ports returns the allocated ports of a plan type mapped to their plans
+	Ports(ctx context.Context, planTypeKey string) (map[int]string, error)
+
+	// AllocateEgressIP allocates one of the egress IPs as EgressPool does:
+	// the first free one, or for shared IPs the least used
+	AllocateEgressIP(ctx context.Context, planTypeKey string, egress domain.EgressSettings, planID string) (string, error)
+	ReserveEgressIP(ctx context.Context, planTypeKey string, egress domain.EgressSettings, ip, planID string) error
+	ReleaseEgressIP(ctx context.Context, planTypeKey, ip, planID string) error
+	// EgressIPs returns the plans using each of ips
+	EgressIPs(ctx context.Context, planTypeKey string, ips []string) (map[string][]string, error)
+
+	// Ping checks the store can be reached
+	Ping(ctx context.Context) error
+}
+
+// allocatePortScript sets the first free port of the range in the hash
+const allocatePortScript = `
+local first, last = tonumber(ARGV[1]), tonumber(ARGV[2])
+for port = first, last do
+  if redis.call('HSETNX', KEYS[1], port, ARGV[3]) == 1 then
+    return port
+  end
+end
+return -1
+`
+
+const reservePortScript = `
+local owner = redis.call('HGET', KEYS[1], ARGV[1])
+if owner and owner ~= ARGV[2] then
+  return redis.error_reply('port ' .. ARGV[1] .. ' is already allocated to plan ' .. owner)
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+return 1
+`
+
+// allocateEgressScript picks from one set of plans per IP, in configuration
+// order, and returns the index of the IP allocated
+const allocateEgressScript = `
+local chosen, fewest
+for i, key in ipairs(KEYS) do
+  if redis.call('SISMEMBER', key, ARGV[2]) == 1 then
+    return i
+  end
+  local count = redis.call('SCARD', key)
+  if (ARGV[1] == '1' or count == 0) and (chosen == nil or count < fewest) then
+    chosen, fewest = i, count
+  end
+end
+if chosen == nil then
+  return -1
+end
+redis.call('SADD', KEYS[chosen], ARGV[2])
+return chosen
+`
+
+const reserveEgressScript = `
+if ARGV[1] ~= '1' and redis.call('SISMEMBER', KEYS[1], ARGV[2]) == 0 then
+  local owner = redis.call('SRANDMEMBER', KEYS[1])
+  if owner then
+    return redis.error_reply('egress IP ' .. ARGV[3] .. ' is already allocated to plan ' .. owner)
+  end
+end
+redis.call('SADD', KEYS[1], ARGV[2])
+return 1
+`
+
+// redisPortStore keeps a hash of port -> plan ID per plan type, and a set
+// of plan IDs per plan type and egress IP
+type redisPortStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisPortStore creates a port store on a redis server; every key
+// starts with prefix
+func NewRedisPortStore(client *redis.Client, prefix string) PortStore {
+	return &redisPortStore{client: client, prefix: prefix}
+}
+
+func (s *redisPortStore) portsKey(planTypeKey string) string {
+	return s.prefix + "ports:" + planTypeKey
+}
+
+func (s *redisPortStore) egressKey(planTypeKey, ip string) string {
+	return s.prefix + "egress:" + planTypeKey + ":" + ip
+}
+
+func (s *redisPortStore) AllocatePort(ctx context.Context, planTypeKey string, portRange domain.PortRange, planID string) (int, error) {
+	port, err := redis.Int(s.client.Eval(ctx, allocatePortScript, []string{s.portsKey(planTypeKey)},
+		portRange.Start, portRange.End, planID))
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate port: %w", err)
+	}
+	if port < 0 {
+		return 0, domain.PortExhaustedf("no available ports in range %d-%d for plan type %s",
+			portRange.Start, portRange.End, planTypeKey)
+	}
+	return int(port), nil
+}
+
+func (s *redisPortStore) ReservePort(ctx context.Context, planTypeKey string, port int, planID string) error {
+	_, err := s.client.Eval(ctx, reservePortScript, []string{s.portsKey(planTypeKey)}, port, planID)
+	return err
+}
+
+func (s *redisPortStore) ReleasePort(ctx context.Context, planTypeKey string, port int) error {
+	removed, err := redis.Int(s.client.Do(ctx, "HDEL", s.portsKey(planTypeKey), port))
+	if err != nil {
+		return fmt.Errorf("failed to release port: %w", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("port %d is not allocated", port)
+	}
+	return nil
+}
+
+func (s *redisPortStore) Ports(ctx context.Context, planTypeKey string) (map[int]string, error) {
+	values, err := redis.Strings(s.client.Do(ctx, "HGETALL", s.portsKey(planTypeKey)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ports: %w", err)
+	}
+
+	ports := make(map[int]string, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		port, err := strconv.Atoi(values[i])
+		if err != nil {
+			continue
+		}
+		ports[port] = values[i+1]
+	}
+	return ports, nil
+}
+
+func (s *redisPortStore) AllocateEgressIP(ctx context.Context, planTypeKey string, egress domain.EgressSettings, planID string) (string, error) {
+	keys := make([]string, len(egress.IPs))
+	for i, ip := range egress.IPs {
+		keys[i] = s.egressKey(planTypeKey, ip)
+	}
+
+	index, err := redis.Int(s.client.Eval(ctx, allocateEgressScript, keys, sharedFlag(egress.Shared), planID))
+	if err != nil {
+		return "", fmt.Errorf("failed to allocate egress IP: %w", err)
+	}
+	if index < 1 || int(index) > len(egress.IPs) {
+		return "", domain.PortExhaustedf("no available egress IPs for plan type %s", planTypeKey)
+	}
+	return egress.IPs[index-1], nil
+}
+
+func (s *redisPortStore) ReserveEgressIP(ctx context.Context, planTypeKey string, egress domain.EgressSettings, ip, planID string) error {
+	if !containsString(egress.IPs, ip) {
+		return fmt.Errorf("egress IP %s is not in the pool of plan type %s", ip, planTypeKey)
+	}
+	_, err := s.client.Eval(ctx, reserveEgressScript, []string{s.egressKey(planTypeKey, ip)},
+		sharedFlag(egress.Shared), planID, ip)
+	return err
+}
+
+func (s *redisPortStore) ReleaseEgressIP(ctx context.Context, planTypeKey, ip, planID string) error {
+	removed, err := redis.Int(s.client.Do(ctx, "SREM", s.egressKey(planTypeKey, ip), planID))
+	if err != nil {
+		return fmt.Errorf("failed to release egress IP: %w", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("egress IP %s is not allocated to plan %s", ip, planID)
+	}
+	return nil
+}
+
+func (s *redisPortStore) EgressIPs(ctx context.Context, planTypeKey string, ips []string) (map[string][]string, error) {
+	plans := make(map[string][]string, len(ips))
+	for _, ip := range ips {
+		planIDs, err := redis.Strings(s.client.Do(ctx, "SMEMBERS", s.egressKey(planTypeKey, ip)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load egress IP %s: %w", ip, err)
+		}
+		sort.Strings(planIDs)
+		plans[ip] = planIDs
+	}
+	return plans, nil
+}
+
+func (s *redisPortStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx)
+}
+
+func sharedFlag(shared bool) string {
+	if shared {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,104 @@
+//go:build !windows
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+// setProcessGroup places the child in its own process group so it can be cleaned up independently
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminateProcess asks the process to exit, forcing it if the signal cannot be delivered
+func terminateProcess(process *os.Process) error {
+	if err := process.Signal(syscall.SIGTERM); err != nil {
+		return process.Signal(syscall.SIGKILL)
+	}
+	return nil
+}
+
+// processExists reports whether a process with the given PID is alive
+func processExists(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	// Send signal 0 to check if process exists
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// findPIDsOnPort returns the PIDs listening on a local TCP port using lsof (Linux and macOS)
+func findPIDsOnPort(ctx context.Context, port int) ([]int, error) {
+	output, err := exec.CommandContext(ctx, "lsof", "-t", "-iTCP:"+strconv.Itoa(port), "-sTCP:LISTEN").Output()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		// lsof exits non-zero when nothing matches
+		return nil, nil
+	}
+
+	var pids []int
+	for _, line := range strings.Fields(string(output)) {
+		pid, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, err
+		}
+		pids = append(pids, pid)
+	}
+
+	return pids, nil
+}
+
+// applySandbox configures the child's credentials and chroot before it is started
+func applySandbox(cmd *exec.Cmd, sandbox *domain.SandboxSettings) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	if sandbox.Group != "" && sandbox.User == "" {
+		return fmt.Errorf("sandbox group %q requires a user", sandbox.Group)
+	}
+
+	if sandbox.User != "" {
+		u, err := user.Lookup(sandbox.User)
+		if err != nil {
+			return fmt.Errorf("failed to look up sandbox user %q: %w", sandbox.User, err)
+		}
+		uid, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid uid for user %q: %w", sandbox.User, err)
+		}
+		gid, err := strconv.ParseUint(u.Gid, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid gid for user %q: %w", sandbox.User, err)
+		}
+
+		if sandbox.Group != "" {
+			g, err := user.LookupGroup(sandbox.Group)
+			if err != nil {
+				return fmt.Errorf("failed to look up sandbox group %q: %w", sandbox.Group, err)
+			}
+			if gid, err = strconv.ParseUint(g.Gid, 10, 32); err != nil {
+				return fmt.Errorf("invalid gid for group %q: %w", sandbox.Group, err)
+			}
+		}
+
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	}
+
+	cmd.SysProcAttr.Chroot = sandbox.Chroot
+	return nil
+}
@@ -0,0 +1,66 @@
+//go:build windows
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+// setProcessGroup is a no-op on Windows; children are terminated individually
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcess kills the process; Windows has no SIGTERM equivalent for console-less children
+func terminateProcess(process *os.Process) error {
+	return process.Kill()
+}
+
+// processExists reports whether a process with the given PID is alive.
+// On Windows FindProcess opens a handle and fails for unknown PIDs.
+func processExists(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
+
+// findPIDsOnPort returns the PIDs listening on a local TCP port by parsing netstat output
+func findPIDsOnPort(ctx context.Context, port int) ([]int, error) {
+	output, err := exec.CommandContext(ctx, "netstat", "-ano", "-p", "TCP").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := ":" + strconv.Itoa(port)
+	seen := make(map[int]bool)
+	var pids []int
+
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		// Proto  Local Address  Foreign Address  State  PID
+		if len(fields) != 5 || fields[3] != "LISTENING" || !strings.HasSuffix(fields[1], suffix) {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[4])
+		if err != nil || seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		pids = append(pids, pid)
+	}
+
+	return pids, nil
+}
+
+// applySandbox is unsupported on Windows; any sandbox settings are rejected
+func applySandbox(cmd *exec.Cmd, sandbox *domain.SandboxSettings) error {
+	return fmt.Errorf("process sandboxing is not supported on windows")
+}
@@ -0,0 +1,208 @@
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// newTransport returns a pooled transport for a provider's API calls. All
+// of a provider's calls share it, so connections are kept alive and reused
+// instead of opened per call.
+func newTransport(cfg config.ProviderHTTP, proxy config.OutboundProxy) (*http.Transport, error) {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+		TLSHandshakeTimeout: cfg.TLSHandshakeTimeout,
+	}
+
+	switch proxy.URL {
+	case "":
+	case config.DirectHTTPProxy:
+		transport.Proxy = nil
+	default:
+		proxyURL, err := url.Parse(proxy.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		bypass := newNoProxy(proxy.NoProxy)
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if bypass.match(req.URL) {
+				return nil, nil
+			}
+			return proxyURL, nil
+		}
+	}
+
+	if cfg.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		caPEM, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	return transport, nil
+}
+
+// newAPIClient returns the HTTP client for a provider's API calls. If the
+// configured transport cannot be built, e.g. the CA file went missing after
+// startup validation, the error is logged and a pooled transport without
+// the custom CA and proxy is used.
+func newAPIClient(name string, timeout time.Duration, cfg config.ProviderHTTP, proxy config.OutboundProxy, logger *zap.Logger) *http.Client {
+	transport, err := newTransport(cfg, proxy)
+	if err != nil {
+		logger.Error("Failed to configure provider HTTP client, using defaults",
+			zap.String("provider", name),
+			zap.Error(err))
+		cfg.CAFile = ""
+		transport, _ = newTransport(cfg, config.OutboundProxy{})
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// noProxy matches the hosts reached without the outbound proxy. Entries
+// follow NO_PROXY: "*" matches every host, an IP or CIDR matches addresses,
+// "example.com" matches the domain and its subdomains, ".example.com" only
+// its subdomains, and a ":port" suffix limits an entry to that port.
+type noProxy struct {
+	all     bool
+	ips     []noProxyIP
+	cidrs   []*net.IPNet
+	domains []noProxyDomain
+}
+
+type noProxyIP struct {
+	ip   net.IP
+	port string
+}
+
+type noProxyDomain struct {
+	name       string
+	subdomains bool // only subdomains of name match
+	port       string
+}
+
+func newNoProxy(entries []string) *noProxy {
+	n := &noProxy{}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			n.all = true
+			continue
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			n.cidrs = append(n.cidrs, cidr)
+			continue
+		}
+
+		host, port := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			host, port = h, p
+		}
+		if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+			n.ips = append(n.ips, noProxyIP{ip: ip, port: port})
+			continue
+		}
+
+		host = strings.TrimPrefix(host, "*")
+		domain := noProxyDomain{name: strings.TrimPrefix(host, "."), port: port}
+		domain.subdomains = strings.HasPrefix(host, ".")
+		n.domains = append(n.domains, domain)
+	}
+	return n
+}
+
+// match reports whether u is reached without the proxy
+func (n *noProxy) match(u *url.URL) bool {
+	if n.all {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, cidr := range n.cidrs {
+			if cidr.Contains(ip) {
+				return true
+			}
+		}
+		for _, entry := range n.ips {
+			if entry.ip.Equal(ip) && (entry.port == "" || entry.port == port) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, domain := range n.domains {
+		if domain.port != "" && domain.port != port {
+			continue
+		}
+		if strings.HasSuffix(host, "."+domain.name) || (!domain.subdomains && host == domain.name) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxDrainBytes bounds how much of an unread response body is discarded to
+// keep its connection; larger bodies close the connection instead
+const maxDrainBytes = 64 * 1024
+
+// closeBody discards what is left of a response body and closes it, so the
+// connection goes back to the pool rather than being closed
+func closeBody(body io.ReadCloser) {
+	_, _ = io.CopyN(io.Discard, body, maxDrainBytes)
+	body.Close()
+}
+
+// testConnectionClient returns a client that sends one request through an
+// account's proxy. Its connections are not kept, since every account has
+// its own proxy.
+func testConnectionClient(proxyURL *url.URL) *http.Client {
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy:             http.ProxyURL(proxyURL),
+			DisableKeepAlives: true,
+		},
+	}
+}
@@ -3,8 +3,10 @@ package provider
 
 import (
 	"context"
+	"errors"
 
 	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/pkg/config"
 )
 
 // Provider represents a generic proxy provider
@@ -13,6 +15,14 @@ type Provider interface {
 	GetAccountInfo(ctx context.Context, accountID string) (*ProviderAccount, error)
 	DeleteAccount(ctx context.Context, accountID string) error
 	TestConnection(ctx context.Context, account *ProviderAccount) error
+	TopUpAccount(ctx context.Context, accountID string, bandwidthGB int) error
+
+	// Capabilities returns the upstream features of a plan type, or false if
+	// the provider does not sell it
+	Capabilities(planType string) (domain.PlanFeatures, bool)
+
+	// Reconfigure applies reloaded provider settings to later requests
+	Reconfigure(cfg config.Providers)
 }
 
 // ProviderAccount represents an account with an upstream provider
@@ -24,6 +34,10 @@ type ProviderAccount struct {
 	Host     string `json:"host"`
 	Port     int    `json:"port"`
 	Region   string `json:"region"`
+
+	// Usage, when the provider reports it
+	MaxBytes  int64 `json:"max_bytes,omitempty"`
+	UsedBytes int64 `json:"used_bytes,omitempty"`
 }
 
 // Manager handles multiple providers
@@ -63,6 +77,23 @@ func (m *Manager) GetAccountInfo(ctx context.Context, providerName, accountID st
 	return provider.GetAccountInfo(ctx, accountID)
 }
 
+// Capabilities returns the upstream features of a plan type of the specified provider
+func (m *Manager) Capabilities(providerName, planType string) (domain.PlanFeatures, bool) {
+	provider, exists := m.providers[providerName]
+	if !exists {
+		return domain.PlanFeatures{}, false
+	}
+
+	return provider.Capabilities(planType)
+}
+
+// Reconfigure applies reloaded provider settings to every provider
+func (m *Manager) Reconfigure(cfg config.Providers) {
+	for _, provider := range m.providers {
+		provider.Reconfigure(cfg)
+	}
+}
+
 // DeleteAccount deletes an account from the specified provider
 func (m *Manager) DeleteAccount(ctx context.Context, providerName, accountID string) error {
 	provider, exists := m.providers[providerName]
@@ -73,6 +104,16 @@ func (m *Manager) DeleteAccount(ctx context.Context, providerName, accountID str
 	return provider.DeleteAccount(ctx, accountID)
 }
 
+// TopUpAccount adds bandwidth to an existing account with the specified provider
+func (m *Manager) TopUpAccount(ctx context.Context, providerName, accountID string, bandwidthGB int) error {
+	provider, exists := m.providers[providerName]
+	if !exists {
+		return ErrProviderNotFound{Provider: providerName}
+	}
+
+	return provider.TopUpAccount(ctx, accountID, bandwidthGB)
+}
+
 // TestConnection tests connectivity to the specified provider
 func (m *Manager) TestConnection(ctx context.Context, providerName string, account *ProviderAccount) error {
 	provider, exists := m.providers[providerName]
@@ -83,6 +124,16 @@ func (m *Manager) TestConnection(ctx context.Context, providerName string, accou
 	return provider.TestConnection(ctx, account)
 }
 
+// ErrInsufficientBalance is returned when a provider refuses an order with
+// 402 Payment Required because the reseller balance is too low
+var ErrInsufficientBalance = errors.New("insufficient provider balance")
+
+// ErrAccountNotFound is returned when a provider does not know an account ID
+var ErrAccountNotFound = errors.New("provider account not found")
+
+// ErrUnsupportedPlanType is returned for a plan type a provider does not sell
+var ErrUnsupportedPlanType = errors.New("unsupported plan type")
+
 // Custom error types
 type ErrProviderNotFound struct {
 	Provider string
@@ -6,9 +6,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
-	"time"
+	"sync"
 
 	"go.uber.org/zap"
 
@@ -17,21 +18,39 @@ import (
 )
 
 type NettifyProvider struct {
-	cfg    *config.NettifyConfig
+	mu     sync.RWMutex
+	cfg    config.NettifyConfig
 	logger *zap.Logger
 	client *http.Client
+	guard  *SchemaGuard
 }
 
-func NewNettifyProvider(cfg *config.NettifyConfig, logger *zap.Logger) *NettifyProvider {
+func NewNettifyProvider(cfg *config.NettifyConfig, proxy config.OutboundProxy, logger *zap.Logger, guard *SchemaGuard) *NettifyProvider {
 	return &NettifyProvider{
-		cfg:    cfg,
+		cfg:    *cfg,
 		logger: logger,
-		client: &http.Client{
-			Timeout: cfg.Timeout,
-		},
+		client: newAPIClient("nettify", cfg.Timeout, cfg.HTTP, proxy, logger),
+		guard: guard,
 	}
 }
 
+// Reconfigure replaces the API key and base URL used by later requests
+func (n *NettifyProvider) Reconfigure(cfg config.Providers) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.cfg.APIKey = cfg.Nettify.APIKey
+	n.cfg.BaseURL = cfg.Nettify.BaseURL
+}
+
+// config returns the provider settings in effect
+func (n *NettifyProvider) config() config.NettifyConfig {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.cfg
+}
+
 // NettifyCreateResponse represents the API response from Nettify
 type NettifyCreateResponse struct {
 	PlanID   string `json:"plan_id"`
@@ -52,6 +71,38 @@ type NettifyPlanDetails struct {
 	LastUsed  string `json:"last_used"`
 }
 
+// nettifyCreateShape is the expected form of a plan create response
+var nettifyCreateShape = &Shape{
+	Kinds: []JSONKind{KindObject},
+	Fields: map[string]Field{
+		"plan_id":  {Shape: Shape{Kinds: []JSONKind{KindString}}, Required: true},
+		"username": {Shape: Shape{Kinds: []JSONKind{KindString}}},
+		"message":  {Shape: Shape{Kinds: []JSONKind{KindString}}},
+	},
+}
+
+// nettifyPlanShape is the expected form of a plan's details
+var nettifyPlanShape = &Shape{
+	Kinds: []JSONKind{KindObject},
+	Fields: map[string]Field{
+		"plan_id":    {Shape: Shape{Kinds: []JSONKind{KindString}}, Required: true},
+		"username":   {Shape: Shape{Kinds: []JSONKind{KindString}}, Required: true},
+		"password":   {Shape: Shape{Kinds: []JSONKind{KindString}}, Required: true},
+		"plan_type":  {Shape: Shape{Kinds: []JSONKind{KindString}}},
+		"max_bytes":  {Shape: Shape{Kinds: []JSONKind{KindNumber}}},
+		"used_bytes": {Shape: Shape{Kinds: []JSONKind{KindNumber}}},
+		"enabled":    {Shape: Shape{Kinds: []JSONKind{KindBool}}},
+		"active":     {Shape: Shape{Kinds: []JSONKind{KindBool}}},
+		"last_used":  {Shape: Shape{Kinds: []JSONKind{KindString}}},
+	},
+}
+
+// nettifyPlanListShape is the expected form of the plan list
+var nettifyPlanListShape = &Shape{
+	Kinds: []JSONKind{KindArray},
+	Items: nettifyPlanShape,
+}
+
 func (n *NettifyProvider) CreateAccount(ctx context.Context, req *domain.CreatePlanRequest) (*ProviderAccount, error) {
 	n.logger.Info("Creating Nettify account",
 		zap.String("customer_id", req.CustomerID),
@@ -98,13 +149,13 @@ func (n *NettifyProvider) CreateAccount(ctx context.Context, req *domain.CreateP
 	}
 
 	// Make API request
-	apiURL := fmt.Sprintf("%s/plans/create", n.cfg.BaseURL)
+	apiURL := fmt.Sprintf("%s/plans/create", n.config().BaseURL)
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	httpReq.Header.Set("Authorization", "Bearer "+n.cfg.APIKey)
+	httpReq.Header.Set("Authorization", "Bearer "+n.config().APIKey)
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	n.logger.Debug("Sending request to Nettify API",
@@ -116,8 +167,11 @@ func (n *NettifyProvider) CreateAccount(ctx context.Context, req *domain.CreateP
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
+	if resp.StatusCode == http.StatusPaymentRequired {
+		return nil, fmt.Errorf("Nettify API error (%d): %w", resp.StatusCode, ErrInsufficientBalance)
+	}
 	if resp.StatusCode != 200 {
 		var errorResp map[string]interface{}
 		json.NewDecoder(resp.Body).Decode(&errorResp)
@@ -128,8 +182,14 @@ func (n *NettifyProvider) CreateAccount(ctx context.Context, req *domain.CreateP
 		return nil, fmt.Errorf("Nettify API error: status code %d", resp.StatusCode)
 	}
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	n.guard.Check(domain.ProviderNettify, "create", body, nettifyCreateShape)
+
 	var result NettifyCreateResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
@@ -166,27 +226,36 @@ func (n *NettifyProvider) CreateAccount(ctx context.Context, req *domain.CreateP
 }
 
 func (n *NettifyProvider) getPlanDetails(ctx context.Context, planID string) (*NettifyPlanDetails, error) {
-	apiURL := fmt.Sprintf("%s/plans/%s", n.cfg.BaseURL, planID)
+	apiURL := fmt.Sprintf("%s/plans/%s", n.config().BaseURL, planID)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+n.cfg.APIKey)
+	req.Header.Set("Authorization", "Bearer "+n.config().APIKey)
 
 	resp, err := n.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("failed to get plan details: status code %d: %w", resp.StatusCode, ErrAccountNotFound)
+	}
 	if resp.StatusCode != 200 {
 		return nil, fmt.Errorf("failed to get plan details: status code %d", resp.StatusCode)
 	}
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan details: %w", err)
+	}
+	n.guard.Check(domain.ProviderNettify, "plan_details", body, nettifyPlanShape)
+
 	var details NettifyPlanDetails
-	if err := json.NewDecoder(resp.Body).Decode(&details); err != nil {
+	if err := json.Unmarshal(body, &details); err != nil {
 		return nil, fmt.Errorf("failed to decode plan details: %w", err)
 	}
 
@@ -218,11 +287,13 @@ func (n *NettifyProvider) GetAccountInfo(ctx context.Context, accountID string)
 	upstreamHost, upstreamPort := n.getUpstreamConfig(details.PlanType)
 
 	return &ProviderAccount{
-		ID:       details.PlanID,
-		Username: details.Username,
-		Password: details.Password,
-		Host:     upstreamHost,
-		Port:     upstreamPort,
+		ID:        details.PlanID,
+		Username:  details.Username,
+		Password:  details.Password,
+		Host:      upstreamHost,
+		Port:      upstreamPort,
+		MaxBytes:  details.MaxBytes,
+		UsedBytes: details.UsedBytes,
 	}, nil
 }
 
@@ -232,6 +303,62 @@ func (n *NettifyProvider) DeleteAccount(ctx context.Context, accountID string) e
 	return fmt.Errorf("DeleteAccount not implemented for Nettify")
 }
 
+func (n *NettifyProvider) TopUpAccount(ctx context.Context, accountID string, bandwidthGB int) error {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"bandwidth_mb": bandwidthGB * 1024,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request data: %w", err)
+	}
+
+	apiURL := fmt.Sprintf("%s/plans/%s/topup", n.config().BaseURL, accountID)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+n.config().APIKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode == http.StatusPaymentRequired {
+		return fmt.Errorf("Nettify API error: top-up returned status code %d: %w", resp.StatusCode, ErrInsufficientBalance)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("Nettify API error: top-up returned status code %d: %w", resp.StatusCode, ErrAccountNotFound)
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Nettify API error: top-up returned status code %d", resp.StatusCode)
+	}
+
+	n.logger.Info("Topped up Nettify account",
+		zap.String("account_id", accountID),
+		zap.Int("bandwidth_gb", bandwidthGB),
+	)
+
+	return nil
+}
+
+// Capabilities reports the Nettify plan types. Rotating residential and
+// mobile exits can be geo targeted and held for a session; every plan but
+// unlimited has bandwidth that can be topped up, which renewal relies on.
+func (n *NettifyProvider) Capabilities(planType string) (domain.PlanFeatures, bool) {
+	switch planType {
+	case domain.PlanTypeResidential, domain.PlanTypeMobile:
+		return domain.PlanFeatures{GeoTargeting: true, StickySessions: true, TopUp: true, Renewal: true}, true
+	case domain.PlanTypeDatacenter:
+		return domain.PlanFeatures{TopUp: true, Renewal: true}, true
+	case domain.PlanTypeUnlimited:
+		return domain.PlanFeatures{}, true
+	}
+	return domain.PlanFeatures{}, false
+}
+
 func (n *NettifyProvider) TestConnection(ctx context.Context, account *ProviderAccount) error {
 	// Test the proxy connection
 	proxyURL := fmt.Sprintf("http://%s:%s@%s:%d",
@@ -239,10 +366,6 @@ func (n *NettifyProvider) TestConnection(ctx context.Context, account *ProviderA
 
 	testURL := "http://httpbin.org/ip"
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
 	// Create request with proxy
 	req, err := http.NewRequestWithContext(ctx, "GET", testURL, nil)
 	if err != nil {
@@ -255,11 +378,7 @@ func (n *NettifyProvider) TestConnection(ctx context.Context, account *ProviderA
 		return fmt.Errorf("failed to parse proxy URL: %w", err)
 	}
 
-	client.Transport = &http.Transport{
-		Proxy: http.ProxyURL(proxyURLParsed),
-	}
-
-	resp, err := client.Do(req)
+	resp, err := testConnectionClient(proxyURLParsed).Do(req)
 	if err != nil {
 		return fmt.Errorf("proxy connection test failed: %w", err)
 	}
@@ -280,27 +399,33 @@ func (n *NettifyProvider) TestConnection(ctx context.Context, account *ProviderA
 
 // GetAllPlans retrieves all plans from Nettify API
 func (n *NettifyProvider) GetAllPlans(ctx context.Context) ([]NettifyPlanDetails, error) {
-	apiURL := fmt.Sprintf("%s/plans", n.cfg.BaseURL)
+	apiURL := fmt.Sprintf("%s/plans", n.config().BaseURL)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+n.cfg.APIKey)
+	req.Header.Set("Authorization", "Bearer "+n.config().APIKey)
 
 	resp, err := n.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
 	if resp.StatusCode != 200 {
 		return nil, fmt.Errorf("failed to get plans: status code %d", resp.StatusCode)
 	}
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plans: %w", err)
+	}
+	n.guard.Check(domain.ProviderNettify, "plans", body, nettifyPlanListShape)
+
 	var plans []NettifyPlanDetails
-	if err := json.NewDecoder(resp.Body).Decode(&plans); err != nil {
+	if err := json.Unmarshal(body, &plans); err != nil {
 		return nil, fmt.Errorf("failed to decode plans: %w", err)
 	}
 
@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -20,9 +21,11 @@ import (
 )
 
 type ProxiesFoProvider struct {
-	cfg    *config.ProxiesFoConfig
+	mu     sync.RWMutex
+	cfg    config.ProxiesFoConfig
 	logger *zap.Logger
 	client *http.Client
+	guard  *SchemaGuard
 }
 
 // Temporary debug log path (will be removed later)
@@ -82,16 +85,34 @@ func sanitizeForm(v url.Values) string {
     return copyVals.Encode()
 }
 
-func NewProxiesFoProvider(cfg *config.ProxiesFoConfig, logger *zap.Logger) *ProxiesFoProvider {
+func NewProxiesFoProvider(cfg *config.ProxiesFoConfig, proxy config.OutboundProxy, logger *zap.Logger, guard *SchemaGuard) *ProxiesFoProvider {
 	return &ProxiesFoProvider{
-		cfg:    cfg,
+		cfg:    *cfg,
 		logger: logger,
-		client: &http.Client{
-			Timeout: cfg.Timeout,
-		},
+		client: newAPIClient("proxies_fo", cfg.Timeout, cfg.HTTP, proxy, logger),
+		guard: guard,
 	}
 }
 
+// Reconfigure replaces the API key, base URL and reseller IDs used by later
+// requests
+func (p *ProxiesFoProvider) Reconfigure(cfg config.Providers) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cfg.APIKey = cfg.ProxiesFo.APIKey
+	p.cfg.BaseURL = cfg.ProxiesFo.BaseURL
+	p.cfg.ResellerIDs = cfg.ProxiesFo.ResellerIDs
+}
+
+// config returns the provider settings in effect
+func (p *ProxiesFoProvider) config() config.ProxiesFoConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.cfg
+}
+
 // ProxiesFoResponse represents the API response from Proxies.fo
 // ProxiesFoResponse represents the API response from Proxies.fo.
 // "Data" may be either an object or an array depending on endpoint/inputs.
@@ -102,6 +123,32 @@ type ProxiesFoResponse struct {
 }
 
 // ProxiesFoDataAny accepts either a single object or an array of objects
+// proxiesFoCreateShape is the expected form of a create response. Data has
+// been returned both as an object and as an array, so either is accepted.
+var proxiesFoCreateShape = &Shape{
+	Kinds:  []JSONKind{KindObject},
+	Closed: true,
+	Fields: map[string]Field{
+		"Success": {Shape: Shape{Kinds: []JSONKind{KindBool}}, Required: true},
+		"Error":   {Shape: Shape{Kinds: []JSONKind{KindString}}},
+		"Data": {Shape: Shape{
+			Kinds: []JSONKind{KindObject, KindArray},
+			Items: &Shape{
+				Kinds: []JSONKind{KindObject},
+				Fields: map[string]Field{
+					"ID":           {Shape: Shape{Kinds: []JSONKind{KindString}}, Required: true},
+					"User":         {Shape: Shape{Kinds: []JSONKind{KindString}}},
+					"AuthUsername": {Shape: Shape{Kinds: []JSONKind{KindString}}, Required: true},
+					"AuthPassword": {Shape: Shape{Kinds: []JSONKind{KindString}}, Required: true},
+					"AuthHostname": {Shape: Shape{Kinds: []JSONKind{KindString}}},
+					"AuthPort":     {Shape: Shape{Kinds: []JSONKind{KindNumber}}, Required: true},
+					"EndsDate":     {Shape: Shape{Kinds: []JSONKind{KindNumber}}},
+				},
+			},
+		}},
+	},
+}
+
 type ProxiesFoDataAny struct {
     Items []ProxiesFoData
 }
@@ -142,19 +189,13 @@ func (p *ProxiesFoProvider) CreateAccount(ctx context.Context, req *domain.Creat
 	)
 
     // TEMP DEBUG: Begin request context
-    debugLogf("CreateAccount start: customer_id=%q plan_type=%q region=%q base_url=%q", req.CustomerID, req.PlanType, req.Region, p.cfg.BaseURL)
-
-	// Map plan types to Proxies.fo reseller IDs
-	resellerMap := map[string]string{
-		"residential": "7c9ea873-63f9-4013-9147-3807cc6f0553",
-		"isp":         "3471aa35-7922-488a-a7a9-b92a5510080e",
-		"datacenter":  "b3fd0f3c-693d-4ec5-b49f-c77feaab0b72",
-	}
+    debugLogf("CreateAccount start: customer_id=%q plan_type=%q region=%q base_url=%q", req.CustomerID, req.PlanType, req.Region, p.config().BaseURL)
 
-	resellerID, ok := resellerMap[req.PlanType]
+	// Map plan types to Proxies.fo reseller IDs (providers.proxies_fo.reseller_ids)
+	resellerID, ok := p.config().ResellerIDs[req.PlanType]
 	if !ok {
         debugLogf("Unsupported plan type: %q", req.PlanType)
-		return nil, fmt.Errorf("unsupported plan type: %s", req.PlanType)
+		return nil, fmt.Errorf("%w: %s (no reseller ID configured)", ErrUnsupportedPlanType, req.PlanType)
 	}
 
 	// Prepare form data
@@ -166,15 +207,24 @@ func (p *ProxiesFoProvider) CreateAccount(ctx context.Context, req *domain.Creat
 
 	// Set plan-specific parameters
     if req.PlanType == "datacenter" {
+		// Duration and Threads defaults come from the plan type policy
 		duration := req.Duration
 		if duration == 0 {
 			duration = 1 // Default to 1 day
 		}
+		threads := req.Threads
+		if threads == 0 {
+			threads = 500 // Default thread limit
+		}
         formData.Set("Duration", strconv.Itoa(duration))
-        formData.Set("Threads", "500") // Default thread limit
+        formData.Set("Threads", strconv.Itoa(threads))
 	} else {
 		// Residential/ISP plans
-        formData.Set("Duration", "180") // 180 days
+		duration := req.Duration
+		if duration == 0 {
+			duration = 180 // 180 days
+		}
+        formData.Set("Duration", strconv.Itoa(duration))
 		bandwidth := req.Bandwidth
 		if bandwidth == 0 {
 			bandwidth = 1 // Default to 1GB
@@ -184,7 +234,7 @@ func (p *ProxiesFoProvider) CreateAccount(ctx context.Context, req *domain.Creat
 	}
 
 	// Make API request
-	apiURL := fmt.Sprintf("%s/api/plans/new", p.cfg.BaseURL)
+	apiURL := fmt.Sprintf("%s/api/plans/new", p.config().BaseURL)
     debugLogf("Request URL: %s", apiURL)
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(formData.Encode()))
 	if err != nil {
@@ -192,11 +242,11 @@ func (p *ProxiesFoProvider) CreateAccount(ctx context.Context, req *domain.Creat
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	httpReq.Header.Set("X-Api-Auth", p.cfg.APIKey)
+	httpReq.Header.Set("X-Api-Auth", p.config().APIKey)
 	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
     // TEMP DEBUG: Log masked headers and form
-    debugLogf("Headers: X-Api-Auth=%s, Content-Type=%s", maskKey(p.cfg.APIKey), httpReq.Header.Get("Content-Type"))
+    debugLogf("Headers: X-Api-Auth=%s, Content-Type=%s", maskKey(p.config().APIKey), httpReq.Header.Get("Content-Type"))
     debugLogf("Form (sanitized): %s", sanitizeForm(formData))
 
 	p.logger.Debug("Sending request to Proxies.fo API",
@@ -209,7 +259,7 @@ func (p *ProxiesFoProvider) CreateAccount(ctx context.Context, req *domain.Creat
         debugLogf("HTTP error: %v", err)
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
-	defer resp.Body.Close()
+	defer closeBody(resp.Body)
 
 	// Read the response body for debugging and parsing
 	body, err := io.ReadAll(resp.Body)
@@ -223,6 +273,10 @@ func (p *ProxiesFoProvider) CreateAccount(ctx context.Context, req *domain.Creat
     debugLogf("Raw body: %s", string(body))
 
 	p.logger.Debug("Raw API response", zap.String("body", string(body)))
+	if resp.StatusCode == http.StatusPaymentRequired {
+		return nil, fmt.Errorf("Proxies.fo API error (%d): %w", resp.StatusCode, ErrInsufficientBalance)
+	}
+	p.guard.Check(domain.ProviderProxiesFo, "create", body, proxiesFoCreateShape)
 
 	var result ProxiesFoResponse
 	if err := json.Unmarshal(body, &result); err != nil {
@@ -300,6 +354,27 @@ func (p *ProxiesFoProvider) DeleteAccount(ctx context.Context, accountID string)
 	return fmt.Errorf("DeleteAccount not implemented for Proxies.fo")
 }
 
+func (p *ProxiesFoProvider) TopUpAccount(ctx context.Context, accountID string, bandwidthGB int) error {
+	// Proxies.fo does not expose a bandwidth top-up endpoint to resellers;
+	// callers fall back to creating a new account
+	return fmt.Errorf("TopUpAccount not implemented for Proxies.fo")
+}
+
+// Capabilities reports the plan types with a reseller ID configured.
+// Residential exits can be pinned to a country and a session through the
+// username; bandwidth cannot be topped up, so plans cannot renew either.
+func (p *ProxiesFoProvider) Capabilities(planType string) (domain.PlanFeatures, bool) {
+	if _, ok := p.config().ResellerIDs[planType]; !ok {
+		return domain.PlanFeatures{}, false
+	}
+
+	residential := planType == domain.PlanTypeResidential
+	return domain.PlanFeatures{
+		GeoTargeting:   residential,
+		StickySessions: residential,
+	}, true
+}
+
 func (p *ProxiesFoProvider) TestConnection(ctx context.Context, account *ProviderAccount) error {
 	// Test the proxy connection
 	proxyURL := fmt.Sprintf("http://%s:%s@%s:%d",
@@ -307,10 +382,6 @@ func (p *ProxiesFoProvider) TestConnection(ctx context.Context, account *Provide
 
 	testURL := "http://httpbin.org/ip"
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
 	// Create request with proxy
 	req, err := http.NewRequestWithContext(ctx, "GET", testURL, nil)
 	if err != nil {
@@ -323,11 +394,7 @@ func (p *ProxiesFoProvider) TestConnection(ctx context.Context, account *Provide
 		return fmt.Errorf("failed to parse proxy URL: %w", err)
 	}
 
-	client.Transport = &http.Transport{
-		Proxy: http.ProxyURL(proxyURLParsed),
-	}
-
-	resp, err := client.Do(req)
+	resp, err := testConnectionClient(proxyURLParsed).Do(req)
 	if err != nil {
 		return fmt.Errorf("proxy connection test failed: %w", err)
 	}
@@ -0,0 +1,324 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// driftSampleInterval limits payload samples to one per provider endpoint
+	driftSampleInterval = 10 * time.Minute
+	// driftSampleLimit truncates logged payload samples
+	driftSampleLimit = 2048
+)
+
+// JSONKind is the type of a JSON value
+type JSONKind string
+
+const (
+	KindString JSONKind = "string"
+	KindNumber JSONKind = "number"
+	KindBool   JSONKind = "bool"
+	KindObject JSONKind = "object"
+	KindArray  JSONKind = "array"
+	KindNull   JSONKind = "null"
+)
+
+// Drift kinds counted by the schema guard
+const (
+	DriftInvalidJSON     = "invalid_json"
+	DriftUnexpectedType  = "unexpected_type"
+	DriftMissingField    = "missing_field"
+	DriftUnexpectedField = "unexpected_field"
+)
+
+// Shape is the expected form of a provider response value. A value matches
+// when its kind is one of Kinds; objects are checked against Fields and
+// arrays (and objects-or-arrays) have each element checked against Items.
+// Closed objects also report members missing from Fields; leave it unset
+// where the provider returns more than is modelled.
+type Shape struct {
+	Kinds  []JSONKind
+	Fields map[string]Field
+	Closed bool
+	Items  *Shape
+}
+
+// Field is an expected object member
+type Field struct {
+	Shape
+	Required bool
+}
+
+// Drift is one difference between a response and its expected shape
+type Drift struct {
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	Expected string `json:"expected,omitempty"`
+	Got      string `json:"got,omitempty"`
+}
+
+// DriftCount is the number of drifts seen for a provider endpoint and kind
+type DriftCount struct {
+	Provider string `json:"provider"`
+	Endpoint string `json:"endpoint"`
+	Kind     string `json:"kind"`
+	Count    int64  `json:"count"`
+}
+
+type driftKey struct {
+	provider string
+	endpoint string
+	kind     string
+}
+
+// SchemaGuard validates provider responses against their expected shapes so
+// upstream API changes surface in logs and metrics before they break plans.
+// Checks never fail a request; decoding stays as lenient as before.
+type SchemaGuard struct {
+	logger *zap.Logger
+
+	mu          sync.Mutex
+	counts      map[driftKey]int64
+	lastSampled map[string]time.Time
+}
+
+// NewSchemaGuard creates a new schema guard
+func NewSchemaGuard(logger *zap.Logger) *SchemaGuard {
+	return &SchemaGuard{
+		logger:      logger,
+		counts:      make(map[driftKey]int64),
+		lastSampled: make(map[string]time.Time),
+	}
+}
+
+// Check validates body against shape, counting and logging any drift. A nil
+// guard checks nothing.
+func (g *SchemaGuard) Check(provider, endpoint string, body []byte, shape *Shape) []Drift {
+	if g == nil || shape == nil {
+		return nil
+	}
+
+	var drifts []Drift
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		drifts = []Drift{{Path: "$", Kind: DriftInvalidJSON, Got: err.Error()}}
+	} else {
+		drifts = checkShape("$", value, shape, nil)
+	}
+	if len(drifts) == 0 {
+		return nil
+	}
+
+	g.mu.Lock()
+	for _, drift := range drifts {
+		g.counts[driftKey{provider: provider, endpoint: endpoint, kind: drift.Kind}]++
+	}
+	sampleKey := provider + " " + endpoint
+	sample := time.Since(g.lastSampled[sampleKey]) >= driftSampleInterval
+	if sample {
+		g.lastSampled[sampleKey] = time.Now()
+	}
+	g.mu.Unlock()
+
+	fields := []zap.Field{
+		zap.String("provider", provider),
+		zap.String("endpoint", endpoint),
+		zap.Any("drifts", drifts),
+	}
+	if sample {
+		fields = append(fields, zap.String("payload_sample", sanitizePayload(body)))
+	}
+	g.logger.Warn("Provider response schema drift", fields...)
+
+	return drifts
+}
+
+// Counts returns the drift counters sorted by provider, endpoint and kind
+func (g *SchemaGuard) Counts() []DriftCount {
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	counts := make([]DriftCount, 0, len(g.counts))
+	for key, count := range g.counts {
+		counts = append(counts, DriftCount{
+			Provider: key.provider,
+			Endpoint: key.endpoint,
+			Kind:     key.kind,
+			Count:    count,
+		})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Provider != counts[j].Provider {
+			return counts[i].Provider < counts[j].Provider
+		}
+		if counts[i].Endpoint != counts[j].Endpoint {
+			return counts[i].Endpoint < counts[j].Endpoint
+		}
+		return counts[i].Kind < counts[j].Kind
+	})
+	return counts
+}
+
+// checkShape appends the drifts of value at path against shape
+func checkShape(path string, value interface{}, shape *Shape, drifts []Drift) []Drift {
+	kind := kindOf(value)
+	if len(shape.Kinds) > 0 && !hasKind(shape.Kinds, kind) {
+		return append(drifts, Drift{
+			Path:     path,
+			Kind:     DriftUnexpectedType,
+			Expected: joinKinds(shape.Kinds),
+			Got:      string(kind),
+		})
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if shape.Fields != nil {
+			drifts = checkFields(path, v, shape.Fields, shape.Closed, drifts)
+		} else if shape.Items != nil {
+			// An object standing in for a one-element array
+			drifts = checkShape(path, v, shape.Items, drifts)
+		}
+	case []interface{}:
+		if shape.Items != nil {
+			for i, item := range v {
+				drifts = checkShape(fmt.Sprintf("%s[%d]", path, i), item, shape.Items, drifts)
+			}
+		}
+	}
+	return drifts
+}
+
+// checkFields appends missing, mistyped and unexpected members of an object
+func checkFields(path string, object map[string]interface{}, fields map[string]Field, closed bool, drifts []Drift) []Drift {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field := fields[name]
+		value, ok := object[name]
+		if !ok {
+			if field.Required {
+				drifts = append(drifts, Drift{Path: path + "." + name, Kind: DriftMissingField, Expected: joinKinds(field.Kinds)})
+			}
+			continue
+		}
+		// Optional members may be null
+		if value == nil && !field.Required {
+			continue
+		}
+		drifts = checkShape(path+"."+name, value, &field.Shape, drifts)
+	}
+
+	if !closed {
+		return drifts
+	}
+	var unexpected []string
+	for name := range object {
+		if _, ok := fields[name]; !ok {
+			unexpected = append(unexpected, name)
+		}
+	}
+	sort.Strings(unexpected)
+	for _, name := range unexpected {
+		drifts = append(drifts, Drift{Path: path + "." + name, Kind: DriftUnexpectedField, Got: string(kindOf(object[name]))})
+	}
+	return drifts
+}
+
+func kindOf(value interface{}) JSONKind {
+	switch value.(type) {
+	case string:
+		return KindString
+	case float64:
+		return KindNumber
+	case bool:
+		return KindBool
+	case map[string]interface{}:
+		return KindObject
+	case []interface{}:
+		return KindArray
+	default:
+		return KindNull
+	}
+}
+
+func hasKind(kinds []JSONKind, kind JSONKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func joinKinds(kinds []JSONKind) string {
+	names := make([]string, len(kinds))
+	for i, kind := range kinds {
+		names[i] = string(kind)
+	}
+	return strings.Join(names, "|")
+}
+
+// sanitizePayload masks credential members of a response and truncates it
+// for logging. Payloads that are not JSON are only truncated.
+func sanitizePayload(body []byte) string {
+	var value interface{}
+	sample := string(body)
+	if err := json.Unmarshal(body, &value); err == nil {
+		if masked, err := json.Marshal(maskSecrets(value)); err == nil {
+			sample = string(masked)
+		}
+	}
+	if len(sample) > driftSampleLimit {
+		sample = sample[:driftSampleLimit] + "...(truncated)"
+	}
+	return sample
+}
+
+func maskSecrets(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(v))
+		for name, member := range v {
+			if isSecretKey(name) {
+				masked[name] = "<masked>"
+				continue
+			}
+			masked[name] = maskSecrets(member)
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(v))
+		for i, item := range v {
+			masked[i] = maskSecrets(item)
+		}
+		return masked
+	default:
+		return value
+	}
+}
+
+func isSecretKey(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range []string{"password", "secret", "token", "apikey", "api_key"} {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// ErrAccountInUse is returned when deleting an account that still serves plans
+var ErrAccountInUse = errors.New("provider account is still linked to plans")
+
+// IsAccountInUse reports whether err was caused by deleting an in-use account
+func IsAccountInUse(err error) bool {
+	return errors.Is(err, ErrAccountInUse)
+}
+
+type providerAccountService struct {
+	cfg             *config.Config
+	logger          *zap.Logger
+	accountRepo     repository.ProviderAccountRepository
+	providerService ProviderService
+	exhaustion      *ExhaustionMonitor
+}
+
+// NewProviderAccountService creates a new provider account service
+func NewProviderAccountService(
+	cfg *config.Config,
+	logger *zap.Logger,
+	accountRepo repository.ProviderAccountRepository,
+	providerService ProviderService,
+	exhaustion *ExhaustionMonitor,
+) ProviderAccountService {
+	return &providerAccountService{
+		cfg:             cfg,
+		logger:          logger,
+		accountRepo:     accountRepo,
+		providerService: providerService,
+		exhaustion:      exhaustion,
+	}
+}
+
+// AcquireAccount reuses the customer's existing upstream account when enabled
+// for the provider, otherwise creates and records a new one. Either way the
+// account is linked to planID.
+func (s *providerAccountService) AcquireAccount(ctx context.Context, req *domain.CreatePlanRequest, planTypeKey string, planID uuid.UUID) (*domain.ProviderAccount, error) {
+	if account := s.reuseAccount(ctx, req, planTypeKey, planID); account != nil {
+		return account, nil
+	}
+
+	created, err := s.providerService.CreateAccount(ctx, req.Provider, req)
+	if err != nil {
+		return nil, err
+	}
+
+	account := &domain.ProviderAccount{
+		ID:                 uuid.New(),
+		Provider:           req.Provider,
+		CustomerID:         req.CustomerID,
+		PlanTypeKey:        planTypeKey,
+		PlanType:           req.PlanType,
+		Region:             req.Region,
+		UpstreamID:         created.ID,
+		UpstreamCustomerID: created.CustomerID,
+		Username:           created.Username,
+		Password:           created.Password,
+		Host:               created.Host,
+		Port:               created.Port,
+		Status:             domain.ProviderAccountStatusActive,
+		Bandwidth:          req.Bandwidth,
+		PlanIDs:            []uuid.UUID{planID},
+		CreatedAt:          time.Now(),
+		UpdatedAt:          time.Now(),
+	}
+	if err := s.accountRepo.Create(ctx, account); err != nil {
+		// The plan still works; the account just can't be managed or reused later
+		s.logger.Warn("Failed to record provider account", zap.Error(err))
+	}
+
+	return account, nil
+}
+
+// reuseAccount tops up the customer's most recent active account for the
+// same plan type. It returns nil when reuse is disabled or not possible.
+func (s *providerAccountService) reuseAccount(ctx context.Context, req *domain.CreatePlanRequest, planTypeKey string, planID uuid.UUID) *domain.ProviderAccount {
+	if req.ForceNewAccount || !s.cfg.Providers.ReuseAccounts(req.Provider) || req.CustomerID == "" || !domain.IsBandwidthMetered(req.Provider, req.PlanType) {
+		return nil
+	}
+
+	accounts, err := s.accountRepo.GetByCustomer(ctx, req.Provider, req.CustomerID)
+	if err != nil {
+		s.logger.Warn("Failed to look up provider accounts for reuse", zap.Error(err))
+		return nil
+	}
+
+	for _, account := range accounts {
+		if account.PlanTypeKey != planTypeKey || account.Status != domain.ProviderAccountStatusActive {
+			continue
+		}
+
+		if err := s.providerService.TopUpAccount(ctx, req.Provider, account.UpstreamID, req.Bandwidth); err != nil {
+			s.logger.Warn("Failed to top up provider account, creating a new one",
+				zap.String("account_id", account.ID.String()),
+				zap.String("provider", req.Provider),
+				zap.Error(err),
+			)
+			return nil
+		}
+
+		account.Bandwidth += req.Bandwidth
+		account.TopUps++
+		account.LinkPlan(planID)
+		if err := s.accountRepo.Update(ctx, account); err != nil {
+			s.logger.Warn("Failed to update provider account", zap.Error(err))
+		}
+
+		s.logger.Info("Reused provider account",
+			zap.String("account_id", account.ID.String()),
+			zap.String("provider", req.Provider),
+			zap.String("customer_id", req.CustomerID),
+			zap.Int("bandwidth_added", req.Bandwidth),
+		)
+
+		return account
+	}
+
+	return nil
+}
+
+// ReleasePlan unlinks a deleted plan from its account. The upstream account
+// is kept; delete it explicitly once no plans use it.
+func (s *providerAccountService) ReleasePlan(ctx context.Context, planID uuid.UUID) error {
+	account, err := s.accountRepo.GetByPlanID(ctx, planID)
+	if err != nil {
+		return err
+	}
+
+	account.UnlinkPlan(planID)
+	return s.accountRepo.Update(ctx, account)
+}
+
+func (s *providerAccountService) GetAccount(ctx context.Context, accountID uuid.UUID) (*domain.ProviderAccount, error) {
+	return s.accountRepo.GetByID(ctx, accountID)
+}
+
+// ListAccounts returns accounts, optionally filtered by provider and customer
+func (s *providerAccountService) ListAccounts(ctx context.Context, provider, customerID string) ([]*domain.ProviderAccount, error) {
+	all, err := s.accountRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]*domain.ProviderAccount, 0, len(all))
+	for _, account := range all {
+		if provider != "" && account.Provider != provider {
+			continue
+		}
+		if customerID != "" && account.CustomerID != customerID {
+			continue
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+// RenewAccount tops up an account's bandwidth with the provider
+func (s *providerAccountService) RenewAccount(ctx context.Context, accountID uuid.UUID, bandwidthGB int) (*domain.ProviderAccount, error) {
+	if bandwidthGB <= 0 {
+		return nil, fmt.Errorf("bandwidth must be positive")
+	}
+
+	account, err := s.activeAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.providerService.TopUpAccount(ctx, account.Provider, account.UpstreamID, bandwidthGB); err != nil {
+		return nil, fmt.Errorf("failed to top up provider account: %w", err)
+	}
+
+	account.Bandwidth += bandwidthGB
+	account.TopUps++
+	if err := s.accountRepo.Update(ctx, account); err != nil {
+		return nil, fmt.Errorf("failed to update provider account: %w", err)
+	}
+
+	s.logger.Info("Renewed provider account",
+		zap.String("account_id", account.ID.String()),
+		zap.String("provider", account.Provider),
+		zap.Int("bandwidth_added", bandwidthGB),
+	)
+
+	return account, nil
+}
+
+// SyncUsage refreshes the account's usage counters from the provider
+func (s *providerAccountService) SyncUsage(ctx context.Context, accountID uuid.UUID) (*domain.ProviderAccount, error) {
+	account, err := s.activeAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.providerService.GetAccountInfo(ctx, account.Provider, account.UpstreamID)
+	if err != nil {
+		if isQuotaResponse(0, err.Error()) {
+			s.markPlansExhausted(ctx, account, err.Error())
+		}
+		return nil, fmt.Errorf("failed to get provider account info: %w", err)
+	}
+
+	now := time.Now()
+	account.MaxBytes = info.MaxBytes
+	account.UsedBytes = info.UsedBytes
+	account.UsageSyncedAt = &now
+	if err := s.accountRepo.Update(ctx, account); err != nil {
+		return nil, fmt.Errorf("failed to update provider account: %w", err)
+	}
+
+	if account.MaxBytes > 0 && account.UsedBytes >= account.MaxBytes {
+		s.markPlansExhausted(ctx, account, fmt.Sprintf("used %d of %d bytes", account.UsedBytes, account.MaxBytes))
+	}
+
+	return account, nil
+}
+
+// markPlansExhausted moves every plan served by the account to exhausted
+func (s *providerAccountService) markPlansExhausted(ctx context.Context, account *domain.ProviderAccount, reason string) {
+	for _, planID := range account.PlanIDs {
+		if _, err := s.exhaustion.MarkExhausted(ctx, planID, reason); err != nil {
+			s.logger.Error("Failed to mark plan exhausted",
+				zap.String("plan_id", planID.String()),
+				zap.String("account_id", account.ID.String()),
+				zap.Error(err))
+		}
+	}
+}
+
+// DeleteAccount deletes the upstream account and marks the record deleted.
+// Accounts still linked to plans are refused with ErrAccountInUse.
+func (s *providerAccountService) DeleteAccount(ctx context.Context, accountID uuid.UUID) error {
+	account, err := s.activeAccount(ctx, accountID)
+	if err != nil {
+		return err
+	}
+
+	if len(account.PlanIDs) > 0 {
+		return fmt.Errorf("%w (%d plans)", ErrAccountInUse, len(account.PlanIDs))
+	}
+
+	if err := s.providerService.DeleteAccount(ctx, account.Provider, account.UpstreamID); err != nil {
+		return fmt.Errorf("failed to delete provider account: %w", err)
+	}
+
+	now := time.Now()
+	account.Status = domain.ProviderAccountStatusDeleted
+	account.DeletedAt = &now
+	if err := s.accountRepo.Update(ctx, account); err != nil {
+		return fmt.Errorf("failed to update provider account: %w", err)
+	}
+
+	s.logger.Info("Deleted provider account",
+		zap.String("account_id", account.ID.String()),
+		zap.String("provider", account.Provider),
+	)
+
+	return nil
+}
+
+func (s *providerAccountService) activeAccount(ctx context.Context, accountID uuid.UUID) (*domain.ProviderAccount, error) {
+	account, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.Status == domain.ProviderAccountStatusDeleted {
+		return nil, fmt.Errorf("provider account %s is deleted", accountID)
+	}
+	return account, nil
+}
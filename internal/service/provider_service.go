@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 
 	"go.uber.org/zap"
 
@@ -15,13 +16,13 @@ type providerService struct {
 	providerManager *provider.Manager
 }
 
-func NewProviderService(cfg *config.Config, logger *zap.Logger) ProviderService {
+func NewProviderService(cfg *config.Config, logger *zap.Logger, guard *provider.SchemaGuard) ProviderService {
 	// Create provider manager
 	manager := provider.NewManager()
 
 	// Register providers
-	proxiesFoProvider := provider.NewProxiesFoProvider(&cfg.Providers.ProxiesFo, logger)
-	nettifyProvider := provider.NewNettifyProvider(&cfg.Providers.Nettify, logger)
+	proxiesFoProvider := provider.NewProxiesFoProvider(&cfg.Providers.ProxiesFo, cfg.Providers.OutboundProxy("proxies_fo"), logger, guard)
+	nettifyProvider := provider.NewNettifyProvider(&cfg.Providers.Nettify, cfg.Providers.OutboundProxy("nettify"), logger, guard)
 
 	manager.RegisterProvider(domain.ProviderProxiesFo, proxiesFoProvider)
 	manager.RegisterProvider(domain.ProviderNettify, nettifyProvider)
@@ -36,7 +37,7 @@ func (s *providerService) CreateAccount(ctx context.Context, providerName string
 	// Use the provider manager to create account
 	account, err := s.providerManager.CreateAccount(ctx, providerName, req)
 	if err != nil {
-		return nil, err
+		return nil, classifyProviderError(err)
 	}
 
 	// Convert provider.ProviderAccount to service.ProviderAccount
@@ -55,22 +56,32 @@ func (s *providerService) GetAccountInfo(ctx context.Context, providerName, acco
 	// Use the provider manager to get account info
 	account, err := s.providerManager.GetAccountInfo(ctx, providerName, accountID)
 	if err != nil {
-		return nil, err
+		return nil, classifyProviderError(err)
 	}
 
 	// Convert provider.ProviderAccount to service.ProviderAccount
 	return &ProviderAccount{
-		ID:       account.ID,
-		Username: account.Username,
-		Password: account.Password,
-		Host:     account.Host,
-		Port:     account.Port,
-		Region:   account.Region,
+		ID:        account.ID,
+		Username:  account.Username,
+		Password:  account.Password,
+		Host:      account.Host,
+		Port:      account.Port,
+		Region:    account.Region,
+		MaxBytes:  account.MaxBytes,
+		UsedBytes: account.UsedBytes,
 	}, nil
 }
 
 func (s *providerService) DeleteAccount(ctx context.Context, providerName, accountID string) error {
-	return s.providerManager.DeleteAccount(ctx, providerName, accountID)
+	return classifyProviderError(s.providerManager.DeleteAccount(ctx, providerName, accountID))
+}
+
+func (s *providerService) Capabilities(providerName, planType string) (domain.PlanFeatures, bool) {
+	return s.providerManager.Capabilities(providerName, planType)
+}
+
+func (s *providerService) TopUpAccount(ctx context.Context, providerName, accountID string, bandwidthGB int) error {
+	return classifyProviderError(s.providerManager.TopUpAccount(ctx, providerName, accountID, bandwidthGB))
 }
 
 func (s *providerService) TestConnection(ctx context.Context, providerName string, account *ProviderAccount) error {
@@ -86,3 +97,23 @@ func (s *providerService) TestConnection(ctx context.Context, providerName strin
 
 	return s.providerManager.TestConnection(ctx, providerName, providerAccount)
 }
+
+func (s *providerService) Reconfigure(cfg config.Providers) {
+	s.providerManager.Reconfigure(cfg)
+}
+
+// classifyProviderError sorts a provider manager error by whose fault it
+// is: an unknown provider or plan type is the caller's, an unknown account
+// is not found, and anything else failed upstream
+func classifyProviderError(err error) error {
+	var unknown provider.ErrProviderNotFound
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &unknown), errors.Is(err, provider.ErrUnsupportedPlanType):
+		return domain.Invalidf("%w", err)
+	case errors.Is(err, provider.ErrAccountNotFound):
+		return domain.NotFoundf("%w", err)
+	}
+	return domain.ProviderFailure(err)
+}
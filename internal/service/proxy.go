@@ -4,10 +4,14 @@ package service
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
-	"strconv"
-	"syscall"
+	"path/filepath"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,10 +23,22 @@ import (
 )
 
 type proxyService struct {
-	cfg          *config.Config
-	logger       *zap.Logger
-	instanceRepo repository.InstanceRepository
-	planRepo     repository.PlanRepository
+	cfg            *config.Config
+	logger         *zap.Logger
+	instanceRepo   repository.InstanceRepository
+	planRepo       repository.PlanRepository
+	events         *eventRecorder
+	planTypes      *PlanTypeRegistry
+	upstreams      *UpstreamProber
+	exhaustion     *ExhaustionMonitor
+	binaries       *BinaryManager
+	configTemplate *template.Template
+	cgroups        *cgroupManager
+	bans           *BanList
+	dns            *DNSForwarders
+	supervisor     *Supervisor
+	crashLoops     *CrashLoopDetector
+	maintenance    *MaintenanceService
 }
 
 func NewProxyService(
@@ -30,12 +46,34 @@ func NewProxyService(
 	logger *zap.Logger,
 	instanceRepo repository.InstanceRepository,
 	planRepo repository.PlanRepository,
+	eventRepo repository.PlanEventRepository,
+	planTypes *PlanTypeRegistry,
+	upstreams *UpstreamProber,
+	exhaustion *ExhaustionMonitor,
+	binaries *BinaryManager,
+	bans *BanList,
+	dns *DNSForwarders,
+	supervisor *Supervisor,
+	crashLoops *CrashLoopDetector,
+	maintenance *MaintenanceService,
 ) ProxyService {
 	return &proxyService{
-		cfg:          cfg,
-		logger:       logger,
-		instanceRepo: instanceRepo,
-		planRepo:     planRepo,
+		cfg:            cfg,
+		logger:         logger,
+		instanceRepo:   instanceRepo,
+		planRepo:       planRepo,
+		events:         newEventRecorder(eventRepo, logger),
+		planTypes:      planTypes,
+		upstreams:      upstreams,
+		exhaustion:     exhaustion,
+		binaries:       binaries,
+		configTemplate: load3ProxyTemplate(cfg.Proxy.ScriptDir, logger),
+		cgroups:        newCgroupManager(cfg.Proxy.CgroupRoot, logger),
+		bans:           bans,
+		dns:            dns,
+		supervisor:     supervisor,
+		crashLoops:     crashLoops,
+		maintenance:    maintenance,
 	}
 }
 
@@ -47,7 +85,7 @@ func (s *proxyService) StartInstance(ctx context.Context, instance *domain.Proxy
 		zap.Int("auth_port", instance.AuthPort))
 
 	// Kill any existing process on the port
-	if err := s.killProcessOnPort(instance.LocalPort); err != nil {
+	if err := s.killProcessOnPort(ctx, instance.LocalPort); err != nil {
 		s.logger.Warn("Failed to kill existing process on port",
 			zap.Int("port", instance.LocalPort),
 			zap.Error(err))
@@ -59,22 +97,90 @@ func (s *proxyService) StartInstance(ctx context.Context, instance *domain.Proxy
 		return fmt.Errorf("failed to get plan for instance: %w", err)
 	}
 
+	// Route to the best-performing upstream host if the plan type allows a choice
+	if host := s.upstreams.Select(s.planTypes.Get(instance.PlanTypeKey), instance.AuthHost); host != instance.AuthHost {
+		s.logger.Info("Selected lower-latency upstream host",
+			zap.String("instance_id", instance.ID.String()),
+			zap.String("previous_host", instance.AuthHost),
+			zap.String("host", host))
+		instance.AuthHost = host
+	}
+
 	// Create 3proxy configuration file
-	configPath, err := s.create3ProxyConfig(instance, plan.Username, plan.Password)
+	configPath, err := s.create3ProxyConfig(instance, plan)
 	if err != nil {
 		return fmt.Errorf("failed to create 3proxy config: %w", err)
 	}
 
-	// Start 3proxy process
-	cmd := exec.CommandContext(ctx, "3proxy", configPath)
-	cmd.Dir = s.cfg.Proxy.ConfigDir
+	sandbox := s.sandboxFor(instance)
+
+	// Inside a chroot the config path must be given relative to the new root
+	configArg, workDir := configPath, s.cfg.Proxy.ConfigDir
+	if sandbox != nil && sandbox.Chroot != "" {
+		rel, err := filepath.Rel(sandbox.Chroot, configPath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			s.recordStartFailure(ctx, instance, "config dir is outside the sandbox chroot")
+			return fmt.Errorf("config path %s is outside chroot %s", configPath, sandbox.Chroot)
+		}
+		configArg, workDir = "/"+filepath.ToSlash(rel), "/"
+	}
+
+	// Set the file limit before exec, so the daemon child 3proxy forks
+	// inherits it
+	name, args := s.binaries.Path(), []string{configArg}
+	if sandbox != nil && sandbox.NoFile > 0 {
+		if name, args, err = withNoFileLimit(sandbox.NoFile, name, args); err != nil {
+			s.recordStartFailure(ctx, instance, err.Error())
+			return fmt.Errorf("failed to set file limit: %w", err)
+		}
+	}
+
+	// Start 3proxy process. It must outlive the request that started it, so
+	// it is not bound to ctx.
+	cmd := exec.Command(name, args...)
+	cmd.Dir = workDir
 
 	// Set process group to handle cleanup better
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	setProcessGroup(cmd)
+
+	// Drop privileges and chroot if the plan type asks for it
+	if sandbox != nil {
+		if err := applySandbox(cmd, sandbox); err != nil {
+			s.recordStartFailure(ctx, instance, err.Error())
+			return fmt.Errorf("failed to apply sandbox: %w", err)
+		}
+	}
+
+	// Cap CPU and memory. The launcher starts inside the cgroup, so the
+	// daemon child it forks can never run outside the limits.
+	if limits := s.resourcesFor(instance); limits != nil {
+		group, err := s.cgroups.Prepare(instance.ID.String(), limits)
+		if err == nil {
+			defer group.Close()
+			err = launchIntoCgroup(cmd, group)
+		}
+		if err != nil {
+			s.recordStartFailure(ctx, instance, err.Error())
+			return fmt.Errorf("failed to apply resource limits: %w", err)
+		}
+	}
+
+	// Capture child output so immediate failures can be diagnosed
+	output, err := openStartOutput(s.cfg.Proxy.LogDir, instance.ID)
+	if err != nil {
+		s.recordStartFailure(ctx, instance, err.Error())
+		return fmt.Errorf("failed to open 3proxy output: %w", err)
+	}
+	cmd.Stdout = output
+	cmd.Stderr = output
 
-	if err := cmd.Start(); err != nil {
+	err = cmd.Start()
+	output.Close()
+	if err != nil {
+		s.recordStartFailure(ctx, instance, err.Error())
 		return fmt.Errorf("failed to start 3proxy: %w", err)
 	}
+	outputPath := startOutputPath(s.cfg.Proxy.LogDir, instance.ID)
 
 	processID := cmd.Process.Pid
 	s.logger.Info("3proxy process started",
@@ -82,9 +188,48 @@ func (s *proxyService) StartInstance(ctx context.Context, instance *domain.Proxy
 		zap.Int("pid", processID),
 		zap.String("config", configPath))
 
+	// 3proxy daemonizes, so the launcher exits quickly. A non-zero exit
+	// within the grace period means the instance never came up.
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	select {
+	case waitErr := <-exited:
+		if waitErr != nil {
+			reason := startFailureReason(waitErr, readTail(outputPath, startOutputLimit))
+			s.logger.Error("3proxy exited during startup",
+				zap.String("instance_id", instance.ID.String()),
+				zap.Int("pid", processID),
+				zap.String("reason", reason))
+			s.recordStartFailure(ctx, instance, reason)
+			return fmt.Errorf("3proxy exited during startup: %s", reason)
+		}
+	case <-time.After(startGracePeriod):
+	}
+
+	// The daemon child binds the port after the launcher has exited, so a
+	// busy port only shows as nothing listening. The daemon logs the cause.
+	if err := waitListening(instance.LocalAddress(), startListenTimeout); err != nil {
+		output := readTail(outputPath, startOutputLimit)
+		if strings.TrimSpace(output) == "" {
+			output = lastLine(readTail(proxyLogPath(s.cfg.Proxy.LogDir, instance.ID), startOutputLimit))
+		}
+		reason := startFailureReason(err, output)
+		s.logger.Error("3proxy did not start listening",
+			zap.String("instance_id", instance.ID.String()),
+			zap.Int("pid", processID),
+			zap.String("reason", reason))
+		// Kill is a no-op once cmd.Wait has reaped the launcher
+		cmd.Process.Kill()
+		s.recordStartFailure(ctx, instance, reason)
+		return fmt.Errorf("3proxy did not start listening: %s", reason)
+	}
+
 	// Update instance with process ID and status
 	instance.ProcessID = processID
 	instance.Status = domain.InstanceStatusRunning
+	instance.LastStartError = ""
+	instance.LastStartErrorAt = nil
 	instance.UpdatedAt = time.Now()
 
 	if err := s.instanceRepo.Update(ctx, instance); err != nil {
@@ -93,19 +238,25 @@ func (s *proxyService) StartInstance(ctx context.Context, instance *domain.Proxy
 		s.killProcess(processID)
 		return fmt.Errorf("failed to update instance: %w", err)
 	}
+	s.events.record(ctx, instance.PlanID, &instance.ID, domain.EventInstanceStarted, "Proxy instance started", map[string]string{
+		"pid":  fmt.Sprint(processID),
+		"port": fmt.Sprint(instance.LocalPort),
+	})
 
 	// Test the proxy connection
-	go func() {
+	s.supervisor.Spawn(context.Background(), "proxy_connection_test", func(ctx context.Context) error {
 		time.Sleep(2 * time.Second)
-		if err := s.testProxyConnection(instance, plan.Username, plan.Password); err != nil {
+		if err := s.testProxyConnection(ctx, instance, plan.Username, plan.Password); err != nil {
 			s.logger.Error("Proxy connection test failed",
 				zap.String("instance_id", instance.ID.String()),
 				zap.Error(err))
-		} else {
-			s.logger.Info("Proxy connection test successful",
-				zap.String("instance_id", instance.ID.String()))
+			s.handleQuotaError(ctx, instance, err)
+			return fmt.Errorf("instance %s: %w", instance.ID, err)
 		}
-	}()
+		s.logger.Info("Proxy connection test successful",
+			zap.String("instance_id", instance.ID.String()))
+		return nil
+	})
 
 	return nil
 }
@@ -130,12 +281,20 @@ func (s *proxyService) StopInstance(ctx context.Context, instanceID uuid.UUID) e
 	}
 
 	// Kill any process on the port as backup
-	if err := s.killProcessOnPort(instance.LocalPort); err != nil {
+	if err := s.killProcessOnPort(ctx, instance.LocalPort); err != nil {
 		s.logger.Warn("Failed to kill process on port",
 			zap.Int("port", instance.LocalPort),
 			zap.Error(err))
 	}
 
+	if s.resourcesFor(instance) != nil {
+		if err := s.cgroups.Remove(instance.ID.String()); err != nil {
+			s.logger.Warn("Failed to remove instance cgroup",
+				zap.String("instance_id", instanceID.String()),
+				zap.Error(err))
+		}
+	}
+
 	// Update instance status
 	instance.Status = domain.InstanceStatusStopped
 	instance.ProcessID = 0
@@ -155,6 +314,7 @@ func (s *proxyService) StopInstance(ctx context.Context, instanceID uuid.UUID) e
 
 	s.logger.Info("Proxy instance stopped successfully",
 		zap.String("instance_id", instanceID.String()))
+	s.events.record(ctx, instance.PlanID, &instance.ID, domain.EventInstanceStopped, "Proxy instance stopped", nil)
 
 	return nil
 }
@@ -183,6 +343,7 @@ func (s *proxyService) RestartInstance(ctx context.Context, instanceID uuid.UUID
 
 	s.logger.Info("Proxy instance restarted successfully",
 		zap.String("instance_id", instanceID.String()))
+	s.events.record(ctx, instance.PlanID, &instance.ID, domain.EventInstanceRestarted, "Proxy instance restarted", nil)
 
 	return nil
 }
@@ -196,6 +357,7 @@ func (s *proxyService) GetInstanceStatus(ctx context.Context, instanceID uuid.UU
 	// Check if the process is actually running
 	if instance.ProcessID > 0 {
 		if s.isProcessRunning(instance.ProcessID) {
+			s.refreshResourceViolations(ctx, instance)
 			return domain.InstanceStatusRunning, nil
 		} else {
 			// Process died, update status
@@ -214,6 +376,11 @@ func (s *proxyService) GetRunningInstances(ctx context.Context) ([]*domain.Proxy
 	return s.instanceRepo.GetRunning(ctx)
 }
 
+// InstancesVersion changes whenever any instance is written
+func (s *proxyService) InstancesVersion(ctx context.Context) (uint64, error) {
+	return s.instanceRepo.Version(ctx)
+}
+
 func (s *proxyService) HealthCheck(ctx context.Context, instanceID uuid.UUID) error {
 	instance, err := s.instanceRepo.GetByID(ctx, instanceID)
 	if err != nil {
@@ -222,6 +389,7 @@ func (s *proxyService) HealthCheck(ctx context.Context, instanceID uuid.UUID) er
 
 	// Check if process is running
 	if instance.ProcessID <= 0 || !s.isProcessRunning(instance.ProcessID) {
+		s.recordHealthCheckFailed(ctx, instance, "process not running")
 		return fmt.Errorf("process not running")
 	}
 
@@ -232,7 +400,24 @@ func (s *proxyService) HealthCheck(ctx context.Context, instanceID uuid.UUID) er
 	}
 
 	// Test proxy connection
-	return s.testProxyConnection(instance, plan.Username, plan.Password)
+	if err := s.testProxyConnection(ctx, instance, plan.Username, plan.Password); err != nil {
+		s.recordHealthCheckFailed(ctx, instance, err.Error())
+		s.handleQuotaError(ctx, instance, err)
+		return err
+	}
+
+	return nil
+}
+
+// recordHealthCheckFailed records a failed connection test, noting the
+// provider maintenance it happened during
+func (s *proxyService) recordHealthCheckFailed(ctx context.Context, instance *domain.ProxyInstance, reason string) {
+	var data map[string]string
+	if window := s.maintenance.Active(ctx, instance.PlanTypeKey, time.Now()); window != nil {
+		reason = fmt.Sprintf("%s (%s)", reason, maintenanceNote(window))
+		data = maintenanceData(window)
+	}
+	s.events.record(ctx, instance.PlanID, &instance.ID, domain.EventHealthCheckFailed, reason, data)
 }
 
 func (s *proxyService) GetInstance(ctx context.Context, instanceID uuid.UUID) (*domain.ProxyInstance, error) {
@@ -245,39 +430,79 @@ func (s *proxyService) GetInstancesByPlan(ctx context.Context, planID uuid.UUID)
 
 // Helper methods
 
-func (s *proxyService) create3ProxyConfig(instance *domain.ProxyInstance, username, password string) (string, error) {
+// sandboxFor returns the sandbox settings for the instance's plan type, if any
+func (s *proxyService) sandboxFor(instance *domain.ProxyInstance) *domain.SandboxSettings {
+	if planType, exists := s.planTypes.Lookup(instance.PlanTypeKey); exists {
+		return planType.Sandbox
+	}
+	return nil
+}
+
+// resourcesFor returns the cgroup limits for the instance's plan type, if any
+func (s *proxyService) resourcesFor(instance *domain.ProxyInstance) *domain.ResourceLimits {
+	if planType, exists := s.planTypes.Lookup(instance.PlanTypeKey); exists {
+		return planType.Resources
+	}
+	return nil
+}
+
+// refreshResourceViolations records cgroup limit events on the instance when any occurred
+func (s *proxyService) refreshResourceViolations(ctx context.Context, instance *domain.ProxyInstance) {
+	if s.resourcesFor(instance) == nil {
+		return
+	}
+
+	violations, err := s.cgroups.Violations(instance.ID.String())
+	if err != nil {
+		s.logger.Debug("Failed to read cgroup stats",
+			zap.String("instance_id", instance.ID.String()),
+			zap.Error(err))
+		return
+	}
+	if !violations.Any() {
+		return
+	}
+
+	instance.ResourceViolations = violations
+	if err := s.instanceRepo.Update(ctx, instance); err != nil {
+		s.logger.Error("Failed to record resource violations",
+			zap.String("instance_id", instance.ID.String()),
+			zap.Error(err))
+	}
+}
+
+// recordStartFailure marks the instance failed and persists the failure reason
+func (s *proxyService) recordStartFailure(ctx context.Context, instance *domain.ProxyInstance, reason string) {
+	now := time.Now()
+	instance.Status = domain.InstanceStatusFailed
+	instance.ProcessID = 0
+	instance.LastStartError = reason
+	instance.LastStartErrorAt = &now
+	instance.UpdatedAt = now
+
+	if err := s.instanceRepo.Update(ctx, instance); err != nil {
+		s.logger.Error("Failed to record instance start failure",
+			zap.String("instance_id", instance.ID.String()),
+			zap.Error(err))
+	}
+	s.events.record(ctx, instance.PlanID, &instance.ID, domain.EventInstanceStartFailed, reason, nil)
+	s.crashLoops.InstanceDown(ctx, instance, reason, now)
+}
+
+func (s *proxyService) create3ProxyConfig(instance *domain.ProxyInstance, plan *domain.ProxyPlan) (string, error) {
 	configPath := s.getConfigPath(instance.ID.String())
 
-	configContent := fmt.Sprintf(`# 3proxy configuration for instance %s
-# Generated on %s
-
-daemon
-log %s/3proxy_%s.log D
-logformat "- +_L%%t.%%. %%N.%%p %%E %%U %%C:%%c %%R:%%r %%O %%I %%h %%T"
-rotate 30
-
-# Authentication
-users %s:CL:%s
-
-# Allow access for authenticated users
-allow %s
-
-# HTTP proxy forwarding to upstream
-proxy -p%d -a -e%s:%d
-`,
-		instance.ID.String(),
-		time.Now().Format(time.RFC3339),
-		s.cfg.Proxy.LogDir,
-		instance.ID.String(),
-		username,
-		password,
-		username,
-		instance.LocalPort,
-		instance.AuthHost,
-		instance.AuthPort,
-	)
-
-	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+	data, err := s.threeProxyTemplateData(instance, plan, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	configContent, err := render3ProxyConfig(s.configTemplate, data)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(configPath, configContent, 0644); err != nil {
 		return "", fmt.Errorf("failed to write config file: %w", err)
 	}
 
@@ -288,8 +513,104 @@ proxy -p%d -a -e%s:%d
 	return configPath, nil
 }
 
+// threeProxyTemplateData builds the template data of an instance's config
+// from its plan type's settings and the plan's users and status
+func (s *proxyService) threeProxyTemplateData(instance *domain.ProxyInstance, plan *domain.ProxyPlan, now time.Time) (*ThreeProxyTemplateData, error) {
+	data := &ThreeProxyTemplateData{
+		InstanceID:   instance.ID.String(),
+		LogDir:       s.cfg.Proxy.LogDir,
+		Username:     plan.Username,
+		Password:     plan.Password,
+		LocalPort:    instance.LocalPort,
+		LocalHost:    instance.LocalHost,
+		EgressIP:     instance.EgressIP,
+		UpstreamHost: instance.AuthHost,
+		UpstreamPort: instance.AuthPort,
+	}
+	for _, credential := range plan.TempCredentials {
+		if credential.Active(now) {
+			data.ExtraUsers = append(data.ExtraUsers, ThreeProxyUser{Username: credential.Username, Password: credential.Password})
+		}
+	}
+	for _, subUser := range plan.SubUsers {
+		if subUser.Enabled() {
+			data.ExtraUsers = append(data.ExtraUsers, ThreeProxyUser{Username: subUser.Username, Password: subUser.Password})
+		}
+	}
+	planType := s.planTypes.Get(instance.PlanTypeKey)
+	if planType != nil {
+		data.Settings = planType.Proxy
+	}
+	if planType != nil && planType.Connections != nil {
+		data.IdleTimeout = int(planType.Connections.IdleTimeout / time.Second)
+	}
+
+	ports := []int{instance.LocalPort}
+	if data.Settings != nil && data.Settings.SOCKS != nil && data.Settings.SOCKS.Enabled {
+		ports = append(ports, instance.LocalPort+data.Settings.SOCKS.PortOffset)
+	}
+	data.Banned = s.bans.IPs(ports...)
+
+	// DoH plan types resolve only through their local forwarder
+	if data.Settings != nil && len(data.Settings.DoH) > 0 {
+		address, err := s.dns.Address(data.Settings.DoH)
+		if err != nil {
+			return nil, err
+		}
+		data.Settings = dohSettings(data.Settings, address)
+	}
+
+	// Plans in grace may be throttled until they are renewed or stopped
+	if plan.Status == domain.PlanStatusGrace {
+		if grace := gracePeriodFor(s.cfg, planType); grace.Throttle > 0 {
+			data.Settings = throttledSettings(data.Settings, grace.Throttle)
+		}
+	}
+
+	// Downgraded plans keep serving as a throttled teaser until renewed
+	if plan.Status == domain.PlanStatusTeaser {
+		data.Settings = throttledSettings(data.Settings, s.cfg.Billing.TeaserThrottle)
+	}
+
+	// Teaser, exhausted and suspended plans answer plain HTTP with the
+	// renewal notice
+	if plan.Status == domain.PlanStatusTeaser || plan.Status == domain.PlanStatusExhausted || plan.Status == domain.PlanStatusSuspended {
+		data.TeaserPageHost, data.TeaserPagePort, _ = teaserPageParent(s.cfg.Billing.TeaserPage.Listen)
+	}
+
+	// Restricted plans fail closed: destinations off the allowlist are denied
+	if len(plan.AllowedDestinations) > 0 {
+		data.Settings = restrictedSettings(data.Settings, plan.AllowedDestinations)
+	}
+
+	// Suspended plans are denied everything else
+	if plan.Status == domain.PlanStatusSuspended {
+		data.Settings = suspendedSettings(data.Settings)
+	}
+
+	// 3proxy cannot rewrite request headers; it only runs anonymous (-a),
+	// which already leaves out the forwarding headers
+	if !plan.HeaderPolicy.Empty() {
+		s.logger.Warn("Plan header policy is not applied by the 3proxy backend",
+			zap.String("plan_id", plan.ID.String()),
+			zap.String("instance_id", instance.ID.String()))
+	}
+	if plan.ResponseCache != nil {
+		s.logger.Warn("Plan response cache is not applied by the 3proxy backend",
+			zap.String("plan_id", plan.ID.String()),
+			zap.String("instance_id", instance.ID.String()))
+	}
+
+	return data, nil
+}
+
 func (s *proxyService) getConfigPath(instanceID string) string {
-	return fmt.Sprintf("%s/3proxy_%s.cfg", s.cfg.Proxy.ConfigDir, instanceID)
+	return proxyConfigPath(s.cfg.Proxy.ConfigDir, instanceID)
+}
+
+// proxyConfigPath returns the 3proxy config file of an instance
+func proxyConfigPath(configDir, instanceID string) string {
+	return fmt.Sprintf("%s/3proxy_%s.cfg", configDir, instanceID)
 }
 
 func (s *proxyService) killProcess(pid int) error {
@@ -298,13 +619,8 @@ func (s *proxyService) killProcess(pid int) error {
 		return fmt.Errorf("failed to find process: %w", err)
 	}
 
-	// Try graceful shutdown first
-	if err := process.Signal(syscall.SIGTERM); err != nil {
-		s.logger.Debug("SIGTERM failed, trying SIGKILL", zap.Int("pid", pid))
-		// Force kill if graceful shutdown fails
-		if err := process.Signal(syscall.SIGKILL); err != nil {
-			return fmt.Errorf("failed to kill process: %w", err)
-		}
+	if err := terminateProcess(process); err != nil {
+		return fmt.Errorf("failed to kill process: %w", err)
 	}
 
 	// Wait for process to die
@@ -312,51 +628,115 @@ func (s *proxyService) killProcess(pid int) error {
 	return nil
 }
 
-func (s *proxyService) killProcessOnPort(port int) error {
-	// Use lsof to find process using the port
-	cmd := exec.Command("lsof", "-ti:"+strconv.Itoa(port))
-	output, err := cmd.Output()
-	if err != nil {
-		// No process found on port, which is fine
-		return nil
+func (s *proxyService) killProcessOnPort(ctx context.Context, port int) error {
+	if timeout := s.cfg.Timeouts.Exec; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
-	pidStr := string(output)
-	pidStr = pidStr[:len(pidStr)-1] // Remove newline
-
-	pid, err := strconv.Atoi(pidStr)
+	pids, err := findPIDsOnPort(ctx, port)
 	if err != nil {
-		return fmt.Errorf("failed to parse PID: %w", err)
+		return fmt.Errorf("failed to look up process on port: %w", err)
 	}
 
-	return s.killProcess(pid)
+	for _, pid := range pids {
+		if err := s.killProcess(pid); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (s *proxyService) isProcessRunning(pid int) bool {
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		return false
-	}
+	return processExists(pid)
+}
 
-	// Send signal 0 to check if process exists
-	if err := process.Signal(syscall.Signal(0)); err != nil {
-		return false
+// healthCheckSettings resolves a plan type's health check parameters over
+// the global proxy defaults
+func healthCheckSettings(cfg *config.Config, planTypes *PlanTypeRegistry, planTypeKey string) domain.HealthCheckSettings {
+	defaults := domain.HealthCheckSettings{
+		TestURL:          cfg.Proxy.ConnectionTestURL,
+		Timeout:          cfg.Proxy.HealthCheckTimeout,
+		Interval:         cfg.Proxy.HealthCheckInterval,
+		FailureThreshold: cfg.Proxy.HealthCheckFailureThreshold,
+		SuccessThreshold: cfg.Proxy.HealthCheckSuccessThreshold,
 	}
 
-	return true
+	var settings *domain.HealthCheckSettings
+	if planType, exists := planTypes.Lookup(planTypeKey); exists {
+		settings = planType.HealthCheck
+	}
+	return settings.Merge(defaults)
 }
 
-func (s *proxyService) testProxyConnection(instance *domain.ProxyInstance, username, password string) error {
-	// Test the proxy by making a simple HTTP request through it
-	// This is a placeholder implementation
+// testProxyConnection fetches the plan type's health check URL through the
+// instance and applies its success criteria. Without a test URL only the
+// process check done by callers applies.
+func (s *proxyService) testProxyConnection(ctx context.Context, instance *domain.ProxyInstance, username, password string) error {
+	settings := healthCheckSettings(s.cfg, s.planTypes, instance.PlanTypeKey)
+	if settings.TestURL == "" {
+		return nil
+	}
+
 	s.logger.Debug("Testing proxy connection",
 		zap.String("instance_id", instance.ID.String()),
 		zap.Int("local_port", instance.LocalPort))
 
-	// In a real implementation, you would:
-	// 1. Make an HTTP request through the proxy
-	// 2. Verify the response
-	// 3. Check that the request was forwarded to the upstream
+	proxyURL := &url.URL{
+		Scheme: "http",
+		User:   url.UserPassword(username, password),
+		Host:   instance.LocalAddress(),
+	}
+	client := &http.Client{
+		Timeout:   settings.Timeout,
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+	}
 
-	return nil
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, settings.TestURL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid health check URL: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		// Quota errors surface in the CONNECT or transport error text for HTTPS targets
+		if isQuotaResponse(0, err.Error()) {
+			return fmt.Errorf("%w: %v", ErrQuotaExceeded, err)
+		}
+		return fmt.Errorf("connection test failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	latency := time.Since(start)
+
+	if resp.StatusCode >= 400 && isQuotaResponse(resp.StatusCode, string(body)) {
+		return fmt.Errorf("%w: status %d", ErrQuotaExceeded, resp.StatusCode)
+	}
+
+	return settings.Evaluate(resp.StatusCode, string(body), latency)
+}
+
+// handleQuotaError marks the instance's plan exhausted if err reports quota exhaustion
+func (s *proxyService) handleQuotaError(ctx context.Context, instance *domain.ProxyInstance, err error) {
+	if !IsQuotaExceeded(err) {
+		return
+	}
+	marked, markErr := s.exhaustion.MarkExhausted(ctx, instance.PlanID, err.Error())
+	if markErr != nil {
+		s.logger.Error("Failed to mark plan exhausted",
+			zap.String("plan_id", instance.PlanID.String()),
+			zap.Error(markErr))
+		return
+	}
+
+	// Restart the instance onto the teaser page so customers learn why
+	if _, _, ok := teaserPageParent(s.cfg.Billing.TeaserPage.Listen); marked && ok {
+		s.supervisor.Spawn(context.Background(), "teaser_page_restart", func(ctx context.Context) error {
+			return restartAutomated(ctx, s, s.logger, instance, "teaser page")
+		})
+	}
 }
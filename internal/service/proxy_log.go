@@ -0,0 +1,200 @@
+package service
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// proxyLogEntry is one line of a 3proxy instance log in the logformat the
+// instance configs set:
+//
+//	<time> <service>.<port> <error> <user> <client ip>:<port> <remote ip>:<port> <bytes out> <bytes in> <hops> <duration ms> <request>
+//
+// Logs written before the duration was added lack it.
+type proxyLogEntry struct {
+	time       time.Time
+	port       int
+	code       int
+	user       string
+	clientIP   string
+	remoteIP   string
+	bytesOut   int64
+	bytesIn    int64
+	durationMs int64
+	request    string
+	authFailed bool
+
+	// upstreamFailed is set for requests that could not be relayed
+	upstreamFailed bool
+
+	// reaped is set for connections 3proxy closed on its idle timeout
+	reaped bool
+}
+
+// parseProxyLogLine parses a 3proxy log line. Only the fields up to the
+// client address are required; traffic fields are left zero when missing.
+func parseProxyLogLine(line string) (proxyLogEntry, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return proxyLogEntry{}, false
+	}
+
+	var entry proxyLogEntry
+	var err error
+
+	// Times are logged as Unix seconds with milliseconds
+	if seconds, err := strconv.ParseFloat(fields[0], 64); err == nil && seconds > 0 {
+		entry.time = time.UnixMilli(int64(seconds * 1000))
+	}
+
+	dot := strings.LastIndexByte(fields[1], '.')
+	if dot < 0 {
+		return proxyLogEntry{}, false
+	}
+	if entry.port, err = strconv.Atoi(fields[1][dot+1:]); err != nil {
+		return proxyLogEntry{}, false
+	}
+	if entry.code, err = strconv.Atoi(fields[2]); err != nil {
+		return proxyLogEntry{}, false
+	}
+	// 3proxy error codes 5 to 8 are unknown users and wrong passwords
+	entry.authFailed = entry.code >= 5 && entry.code <= 8
+	// Codes above 10 are failures to connect or relay through the parent
+	// proxy or to the destination
+	entry.upstreamFailed = entry.code > 10
+	// Code 92 is a connection terminated by timeout
+	entry.reaped = entry.code == 92
+	entry.user = fields[3]
+
+	if entry.clientIP = logAddressIP(fields[4]); entry.clientIP == "" {
+		return proxyLogEntry{}, false
+	}
+
+	if len(fields) >= 8 {
+		entry.remoteIP = logAddressIP(fields[5])
+		entry.bytesOut, _ = strconv.ParseInt(fields[6], 10, 64)
+		entry.bytesIn, _ = strconv.ParseInt(fields[7], 10, 64)
+	}
+	if len(fields) >= 10 {
+		rest := fields[9:]
+		// Request lines start with the method, never a number
+		if duration, err := strconv.ParseInt(rest[0], 10, 64); err == nil {
+			entry.durationMs = duration
+			rest = rest[1:]
+		}
+		entry.request = strings.Join(rest, " ")
+	}
+
+	return entry, true
+}
+
+// logAddressIP returns the normalized IP of an "<ip>:<port>" log field, or
+// "" if it has none
+func logAddressIP(field string) string {
+	colon := strings.LastIndexByte(field, ':')
+	if colon < 0 {
+		return ""
+	}
+	ip := net.ParseIP(strings.Trim(field[:colon], "[]"))
+	if ip == nil || ip.IsUnspecified() {
+		return ""
+	}
+	return ip.String()
+}
+
+// requestMethod returns the method of a logged request line
+func requestMethod(request string) string {
+	if i := strings.IndexByte(request, ' '); i > 0 {
+		return request[:i]
+	}
+	return request
+}
+
+// requestHost returns the destination host of a logged request line, such
+// as "CONNECT example.com:443 HTTP/1.1" or "GET http://example.com/ HTTP/1.1"
+func requestHost(request string) string {
+	fields := strings.Fields(request)
+	if len(fields) < 2 {
+		return ""
+	}
+
+	target := fields[1]
+	if i := strings.Index(target, "://"); i >= 0 {
+		target = target[i+3:]
+	}
+	if i := strings.IndexAny(target, "/?#"); i >= 0 {
+		target = target[:i]
+	}
+	if i := strings.LastIndexByte(target, '@'); i >= 0 {
+		target = target[i+1:]
+	}
+	if host, _, err := net.SplitHostPort(target); err == nil {
+		return host
+	}
+	return strings.Trim(target, "[]")
+}
+
+// proxyLogPath returns the log file of an instance
+func proxyLogPath(logDir string, instanceID uuid.UUID) string {
+	return filepath.Join(logDir, "3proxy_"+instanceID.String()+".log")
+}
+
+// logTailer reads the lines appended to log files since its last read. It
+// is not safe for concurrent use.
+type logTailer struct {
+	offsets map[string]int64
+}
+
+func newLogTailer() *logTailer {
+	return &logTailer{offsets: make(map[string]int64)}
+}
+
+// read calls fn with every complete line written to path since the last
+// read. A file seen for the first time is read from its end unless
+// fromStart is set.
+func (t *logTailer) read(path string, fromStart bool, fn func(line string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	offset, seen := t.offsets[path]
+	switch {
+	case !seen && !fromStart:
+		offset = info.Size()
+	case info.Size() < offset:
+		// Rotated or truncated since the last read
+		offset = 0
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// Leave a partly written last line for the next read
+			break
+		}
+		offset += int64(len(line))
+		fn(line)
+	}
+	t.offsets[path] = offset
+
+	return nil
+}
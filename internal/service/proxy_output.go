@@ -0,0 +1,89 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// startOutputLimit bounds how much child output is reported per start attempt
+	startOutputLimit = 4096
+
+	// startGracePeriod is how long to watch a freshly started 3proxy for an early exit
+	startGracePeriod = 1 * time.Second
+
+	// startListenTimeout is how long, after the grace period, a started
+	// 3proxy has to accept connections on its port
+	startListenTimeout = 5 * time.Second
+)
+
+// startOutputPath returns the file an instance's 3proxy writes its output
+// to. It is a file rather than a pipe because the daemon child inherits it:
+// a pipe would stay open, and cmd.Wait blocked, for as long as the daemon runs.
+func startOutputPath(logDir string, instanceID uuid.UUID) string {
+	return filepath.Join(logDir, "3proxy_"+instanceID.String()+".start.log")
+}
+
+// openStartOutput truncates and opens an instance's start output file
+func openStartOutput(logDir string, instanceID uuid.UUID) (*os.File, error) {
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log dir: %w", err)
+	}
+	return os.OpenFile(startOutputPath(logDir, instanceID), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+}
+
+// readTail returns up to the last limit bytes of a file, empty when it
+// cannot be read
+func readTail(path string, limit int64) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err == nil && info.Size() > limit {
+		if _, err := file.Seek(-limit, io.SeekEnd); err != nil {
+			return ""
+		}
+	}
+	data, _ := io.ReadAll(io.LimitReader(file, limit))
+	return string(data)
+}
+
+// lastLine returns the last non-empty line of text
+func lastLine(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// waitListening dials address until it accepts a connection or timeout passes
+func waitListening(address string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", address, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not listening on %s after %s", address, timeout)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// startFailureReason combines the failure with any captured output
+func startFailureReason(err error, output string) string {
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return err.Error()
+	}
+	return err.Error() + ": " + output
+}
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+var (
+	// ErrRegionNotFound is returned for a region that does not exist
+	ErrRegionNotFound = errors.New("region not found")
+	// ErrRegionExists is returned when creating a region whose name is taken
+	ErrRegionExists = errors.New("region already exists")
+	// ErrRegionInUse is returned when deleting a region plans or plan types still use
+	ErrRegionInUse = errors.New("region in use")
+	// ErrInvalidRegion is returned for a region that fails validation
+	ErrInvalidRegion = errors.New("invalid region")
+	// ErrRegionNotApplied is returned when a region was saved but its nginx
+	// config or DNS records could not be regenerated
+	ErrRegionNotApplied = errors.New("region saved but not applied")
+)
+
+var regionNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// RegionService manages regions at runtime. Changes are stored in the region
+// repository, swapped into the registry the other services route by, and
+// applied by regenerating the region's nginx config and the DNS records file.
+type RegionService struct {
+	cfg          config.DNSRecords
+	logger       *zap.Logger
+	regionRepo   repository.RegionRepository
+	planRepo     repository.PlanRepository
+	instanceRepo repository.InstanceRepository
+	regions      *RegionRegistry
+	planTypes    *PlanTypeRegistry
+	nginx        *NginxManager
+
+	// mu serializes changes so validation sees every earlier change
+	mu sync.Mutex
+}
+
+// NewRegionService creates a new region service
+func NewRegionService(
+	cfg *config.Config,
+	logger *zap.Logger,
+	regionRepo repository.RegionRepository,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	regions *RegionRegistry,
+	planTypes *PlanTypeRegistry,
+	nginx *NginxManager,
+) *RegionService {
+	return &RegionService{
+		cfg:          cfg.Proxy.DNSRecords,
+		logger:       logger,
+		regionRepo:   regionRepo,
+		planRepo:     planRepo,
+		instanceRepo: instanceRepo,
+		regions:      regions,
+		planTypes:    planTypes,
+		nginx:        nginx,
+	}
+}
+
+// List returns every region, by name
+func (s *RegionService) List() []*domain.Region {
+	return s.regions.List()
+}
+
+// Get returns a region by name
+func (s *RegionService) Get(name string) (*domain.Region, error) {
+	region := s.regions.Get(name)
+	if region == nil {
+		return nil, ErrRegionNotFound
+	}
+	return region, nil
+}
+
+// Create stores a new region and applies it
+func (s *RegionService) Create(ctx context.Context, region *domain.Region) (*domain.Region, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.regions.Get(region.Name) != nil {
+		return nil, ErrRegionExists
+	}
+	if err := s.validate(region); err != nil {
+		return nil, err
+	}
+
+	if err := s.regionRepo.Create(ctx, region); err != nil {
+		return nil, fmt.Errorf("failed to store region: %w", err)
+	}
+	s.regions.Set(region)
+
+	return region, s.apply(ctx, region, nil)
+}
+
+// Update replaces a region and applies it. The name cannot change.
+func (s *RegionService) Update(ctx context.Context, name string, region *domain.Region) (*domain.Region, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.regions.Get(name)
+	if previous == nil {
+		return nil, ErrRegionNotFound
+	}
+	if region.Name == "" {
+		region.Name = name
+	}
+	if region.Name != name {
+		return nil, fmt.Errorf("%w: name cannot be changed", ErrInvalidRegion)
+	}
+	if err := s.validate(region); err != nil {
+		return nil, err
+	}
+
+	if err := s.regionRepo.Update(ctx, region); err != nil {
+		return nil, fmt.Errorf("failed to store region: %w", err)
+	}
+	s.regions.Set(region)
+
+	return region, s.apply(ctx, region, previous)
+}
+
+// Delete removes a region no plan type or unexpired plan uses, with its
+// nginx config
+func (s *RegionService) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	region := s.regions.Get(name)
+	if region == nil {
+		return ErrRegionNotFound
+	}
+
+	for key, planType := range s.planTypes.All() {
+		if planType.Region == name {
+			return fmt.Errorf("%w: plan type %s is in the region", ErrRegionInUse, key)
+		}
+	}
+	plans, err := s.planRepo.GetByRegion(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get region plans: %w", err)
+	}
+	for _, plan := range plans {
+		if plan.Status != domain.PlanStatusExpired && plan.Status != domain.PlanStatusFailed {
+			return fmt.Errorf("%w: plan %s is %s", ErrRegionInUse, plan.ID, plan.Status)
+		}
+	}
+
+	if err := s.regionRepo.Delete(ctx, name); err != nil {
+		return fmt.Errorf("failed to delete region: %w", err)
+	}
+	s.regions.Delete(name)
+
+	var errs []error
+	if err := s.nginx.RemoveRegionConfig(ctx, region); err != nil {
+		errs = append(errs, err)
+	}
+	if err := s.WriteDNSRecords(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrRegionNotApplied, errors.Join(errs...))
+	}
+	return nil
+}
+
+// Export renders every region in the regions.yaml format, for operators who
+// keep their configuration in version control
+func (s *RegionService) Export() ([]byte, error) {
+	regions := make(map[string]*domain.Region)
+	for _, region := range s.regions.List() {
+		regions[region.Name] = region
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Exported by OceanProxy at %s\n", time.Now().UTC().Format(time.RFC3339))
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(struct {
+		Regions map[string]*domain.Region `yaml:"regions"`
+	}{regions}); err != nil {
+		return nil, fmt.Errorf("failed to marshal regions: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal regions: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// WriteDNSRecords rewrites the DNS records file with a record for each
+// region's domain. It does nothing when no file is configured.
+func (s *RegionService) WriteDNSRecords() error {
+	if s.cfg.File == "" {
+		return nil
+	}
+
+	recordType, target := "CNAME", dnsName(s.cfg.Target)
+	if ip := net.ParseIP(s.cfg.Target); ip != nil {
+		recordType, target = "A", ip.String()
+		if ip.To4() == nil {
+			recordType = "AAAA"
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("; OceanProxy region records\n; Generated automatically - do not edit manually\n")
+	for _, region := range s.regions.List() {
+		fmt.Fprintf(&b, "%s\t%d\tIN\t%s\t%s\n", dnsName(region.GetFullDomain()), s.cfg.TTL, recordType, target)
+	}
+
+	if err := writeFileAtomic(s.cfg.File, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write DNS records: %w", err)
+	}
+
+	s.logger.Info("Wrote region DNS records", zap.String("file", s.cfg.File))
+	return nil
+}
+
+// apply regenerates the nginx config of a created or updated region and the
+// DNS records. A renamed nginx config file replaces the previous one.
+func (s *RegionService) apply(ctx context.Context, region, previous *domain.Region) error {
+	var errs []error
+
+	instances, err := s.instanceRepo.GetAll(ctx)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to get instances: %w", err))
+	} else if err := s.nginx.SyncRegion(ctx, region, instances); err != nil {
+		errs = append(errs, err)
+	}
+	if previous != nil && previous.NginxConfigFile != region.NginxConfigFile {
+		if err := s.nginx.RemoveRegionConfig(ctx, previous); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := s.WriteDNSRecords(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		s.logger.Error("Failed to apply region", zap.String("region", region.Name), zap.Errors("errors", errs))
+		return fmt.Errorf("%w: %w", ErrRegionNotApplied, errors.Join(errs...))
+	}
+
+	s.logger.Info("Applied region", zap.String("region", region.Name))
+	return nil
+}
+
+// validate fills in a region's defaults and checks it.
+// Outbound ports and nginx config files must not collide with other regions.
+func (s *RegionService) validate(region *domain.Region) error {
+	if !regionNamePattern.MatchString(region.Name) {
+		return fmt.Errorf("%w: name must be lowercase letters, digits, - and _", ErrInvalidRegion)
+	}
+	if region.Subdomain == "" || region.DomainSuffix == "" {
+		return fmt.Errorf("%w: subdomain and domain_suffix are required", ErrInvalidRegion)
+	}
+	if region.OutboundPort < 1 || region.OutboundPort > 65535 {
+		return fmt.Errorf("%w: outbound_port must be between 1 and 65535", ErrInvalidRegion)
+	}
+	setRegionDefaults(region)
+	if filepath.Base(region.NginxConfigFile) != region.NginxConfigFile || !strings.HasSuffix(region.NginxConfigFile, ".conf") {
+		return fmt.Errorf("%w: nginx_config_file must be a .conf file name without a directory", ErrInvalidRegion)
+	}
+	for _, key := range region.PlanTypes {
+		if _, exists := s.planTypes.Lookup(key); !exists {
+			return fmt.Errorf("%w: unknown plan type %s", ErrInvalidRegion, key)
+		}
+	}
+	for _, country := range region.Countries {
+		if len(country) != 2 {
+			return fmt.Errorf("%w: %q is not an ISO country code", ErrInvalidRegion, country)
+		}
+	}
+
+	for _, other := range s.regions.List() {
+		if other.Name == region.Name {
+			continue
+		}
+		if other.OutboundPort == region.OutboundPort {
+			return fmt.Errorf("%w: outbound_port %d is used by region %s", ErrInvalidRegion, region.OutboundPort, other.Name)
+		}
+		if other.NginxConfigFile == region.NginxConfigFile {
+			return fmt.Errorf("%w: nginx_config_file %s is used by region %s", ErrInvalidRegion, region.NginxConfigFile, other.Name)
+		}
+	}
+	return nil
+}
+
+// setRegionDefaults fills in a region's nginx config file and uppercases its
+// countries
+func setRegionDefaults(region *domain.Region) {
+	if region.NginxConfigFile == "" {
+		region.NginxConfigFile = fmt.Sprintf("oceanproxy_%s.conf", region.Name)
+	}
+	for i, country := range region.Countries {
+		region.Countries[i] = strings.ToUpper(country)
+	}
+}
+
+// dnsName returns a fully qualified zone file name
+func dnsName(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+// RegionRegistry holds the regions services route plans by. Regions can be
+// changed through the admin API while requests are served, so access is
+// locked; a changed region is swapped in whole, never edited in place.
+type RegionRegistry struct {
+	mu      sync.RWMutex
+	regions map[string]*domain.Region
+}
+
+// NewRegionRegistry creates a registry holding regions
+func NewRegionRegistry(regions map[string]*domain.Region) *RegionRegistry {
+	registry := &RegionRegistry{regions: make(map[string]*domain.Region, len(regions))}
+	for name, region := range regions {
+		registry.regions[name] = region
+	}
+	return registry
+}
+
+// Get returns a region by name, or nil if there is none
+func (r *RegionRegistry) Get(name string) *domain.Region {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.regions[name]
+}
+
+// List returns every region, by name
+func (r *RegionRegistry) List() []*domain.Region {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	regions := make([]*domain.Region, 0, len(r.regions))
+	for _, region := range r.regions {
+		regions = append(regions, region)
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		return regions[i].Name < regions[j].Name
+	})
+	return regions
+}
+
+// Set adds or replaces a region
+func (r *RegionRegistry) Set(region *domain.Region) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.regions[region.Name] = region
+}
+
+// Delete removes a region
+func (r *RegionRegistry) Delete(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.regions, name)
+}
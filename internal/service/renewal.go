@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/service/provider"
+)
+
+// renewalOutcome is the result of an automatic renewal attempt
+type renewalOutcome int
+
+const (
+	// renewalSkipped means the plan does not auto-renew or retries ran out
+	renewalSkipped renewalOutcome = iota
+	// renewalRenewed means the plan was charged and extended
+	renewalRenewed
+	// renewalPending means a retry is scheduled; the plan must not expire
+	renewalPending
+)
+
+// renewable reports whether a plan in status can be renewed automatically
+func renewable(status string) bool {
+	switch status {
+	case domain.PlanStatusActive, domain.PlanStatusExhausted, domain.PlanStatusSuspended, domain.PlanStatusGrace, domain.PlanStatusTeaser:
+		return true
+	}
+	return false
+}
+
+// renewBackoff returns the wait before retrying after the given failed attempt
+func (w *ExpiryWorker) renewBackoff(attempt int) time.Duration {
+	backoff := w.cfg.Billing.RenewBackoff
+	for i := 1; i < attempt && backoff < 24*time.Hour; i++ {
+		backoff *= 2
+	}
+	return backoff
+}
+
+// renew charges an expired auto-renewing plan for another term, tops up its
+// upstream account and extends ExpiresAt. Failures are retried with
+// exponential backoff until billing.renew_retries is used up.
+func (w *ExpiryWorker) renew(ctx context.Context, plan *domain.ProxyPlan, now time.Time) renewalOutcome {
+	if !plan.AutoRenew || w.payments == nil || !renewable(plan.Status) {
+		return renewalSkipped
+	}
+
+	state := plan.Renewal
+	if state == nil {
+		state = &domain.RenewalState{}
+	}
+	if state.Abandoned {
+		return renewalSkipped
+	}
+	if state.NextAttemptAt != nil && now.Before(*state.NextAttemptAt) {
+		return renewalPending
+	}
+
+	days, months := plan.Term()
+	periodEnd, err := plan.BillingAnchor.Expiry(plan.ExpiresAt, days, months)
+	if err != nil {
+		return w.renewalFailed(ctx, plan, state, fmt.Errorf("failed to compute renewal expiry: %w", err), now)
+	}
+
+	charge := &domain.RenewalCharge{
+		IdempotencyKey: fmt.Sprintf("%s:%d", plan.ID, plan.ExpiresAt.Unix()),
+		PlanID:         plan.ID.String(),
+		CustomerID:     plan.CustomerID,
+		PlanType:       plan.PlanType,
+		PlanTypeKey:    plan.PlanTypeKey,
+		Provider:       plan.Provider,
+		Region:         plan.Region,
+		Bandwidth:      plan.Bandwidth,
+		Duration:       days,
+		Months:         months,
+		PeriodStart:    plan.ExpiresAt,
+		PeriodEnd:      periodEnd,
+		Attempt:        state.Attempts + 1,
+	}
+	if plan.Cycle == domain.BillingCycleMonthly {
+		charge.Duration = 0
+	} else {
+		charge.Months = 0
+	}
+
+	chargeID, err := w.payments.Charge(ctx, charge)
+	if err != nil {
+		return w.renewalFailed(ctx, plan, state, err, now)
+	}
+
+	// A failure past this point is retried with the same idempotency key, so
+	// the payment provider must not bill the term twice
+	if plan.ProviderAccountID != nil && w.accountService != nil {
+		if _, err := w.accountService.RenewAccount(ctx, *plan.ProviderAccountID, plan.Bandwidth); err != nil {
+			return w.renewalFailed(ctx, plan, state, fmt.Errorf("charged %s but upstream renewal failed: %w", chargeID, err), now)
+		}
+	}
+
+	previous := plan.Status
+	previousExpiry := plan.ExpiresAt
+	plan.Status = domain.PlanStatusActive
+	plan.ExpiresAt = periodEnd
+	plan.GraceEndsAt = nil
+	plan.Renewal = nil
+	plan.MeteredSince = &now
+	plan.UpdatedAt = now
+	if err := w.planRepo.Update(ctx, plan); err != nil {
+		w.logger.Error("Failed to save renewed plan",
+			zap.String("plan_id", plan.ID.String()),
+			zap.String("charge_id", chargeID),
+			zap.Error(err))
+		return renewalPending
+	}
+
+	data := map[string]string{
+		"from":                previous,
+		"to":                  plan.Status,
+		"charge_id":           chargeID,
+		"attempt":             fmt.Sprint(charge.Attempt),
+		"previous_expires_at": previousExpiry.Format(time.RFC3339),
+		"expires_at":          plan.ExpiresAt.Format(time.RFC3339),
+	}
+	w.events.record(ctx, plan.ID, nil, domain.EventPlanRenewed, "Plan renewed automatically", data)
+	w.logger.Info("Plan renewed",
+		zap.String("plan_id", plan.ID.String()),
+		zap.String("customer_id", plan.CustomerID),
+		zap.String("charge_id", chargeID),
+		zap.Time("expires_at", plan.ExpiresAt),
+	)
+	w.notify(ctx, plan, domain.NotificationPlanRenewed,
+		"Your proxy plan has been renewed.", data, now)
+
+	// Lift a grace or teaser throttle and bring back instances of an
+	// exhausted or suspended plan
+	if previous != domain.PlanStatusActive {
+		w.restartInstances(ctx, plan)
+	}
+
+	return renewalRenewed
+}
+
+// renewalFailed records a failed renewal attempt and schedules the next one,
+// or gives up once billing.renew_retries is exhausted
+func (w *ExpiryWorker) renewalFailed(ctx context.Context, plan *domain.ProxyPlan, state *domain.RenewalState, cause error, now time.Time) renewalOutcome {
+	state.Attempts++
+	state.LastError = cause.Error()
+	state.LastAttemptAt = now
+	state.NextAttemptAt = nil
+
+	outcome := renewalPending
+	if state.Attempts > w.cfg.Billing.RenewRetries {
+		state.Abandoned = true
+		outcome = renewalSkipped
+	} else {
+		next := now.Add(w.renewBackoff(state.Attempts))
+		state.NextAttemptAt = &next
+	}
+
+	plan.Renewal = state
+	plan.UpdatedAt = now
+	if err := w.planRepo.Update(ctx, plan); err != nil {
+		w.logger.Error("Failed to save plan renewal state", zap.String("plan_id", plan.ID.String()), zap.Error(err))
+	}
+
+	data := map[string]string{
+		"attempt": fmt.Sprint(state.Attempts),
+		"error":   state.LastError,
+	}
+	if state.NextAttemptAt != nil {
+		data["next_attempt_at"] = state.NextAttemptAt.Format(time.RFC3339)
+	}
+	w.events.record(ctx, plan.ID, nil, domain.EventPlanRenewalFailed, "Automatic renewal failed", data)
+	w.logger.Warn("Plan renewal failed",
+		zap.String("plan_id", plan.ID.String()),
+		zap.String("customer_id", plan.CustomerID),
+		zap.Int("attempt", state.Attempts),
+		zap.Bool("abandoned", state.Abandoned),
+		zap.Error(cause),
+	)
+
+	if state.Abandoned {
+		w.notify(ctx, plan, domain.NotificationPlanRenewalAbandoned,
+			"We could not renew your proxy plan and have stopped retrying. Renew it manually to keep your proxies.", data, now)
+	} else {
+		w.notify(ctx, plan, domain.NotificationPlanRenewalFailed,
+			"We could not renew your proxy plan and will try again.", data, now)
+	}
+	if errors.Is(cause, provider.ErrInsufficientBalance) {
+		w.notify(ctx, plan, domain.NotificationProviderBalanceLow,
+			fmt.Sprintf("%s refused a renewal top-up for insufficient balance; top up the reseller account.", plan.Provider),
+			map[string]string{"provider": plan.Provider, "error": state.LastError}, now)
+	}
+
+	return outcome
+}
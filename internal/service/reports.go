@@ -0,0 +1,486 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+	"github.com/je265/oceanproxy/pkg/mail"
+)
+
+// reportPeriod is the window a scheduled report covers
+const reportPeriod = 7 * 24 * time.Hour
+
+// ReportService builds the weekly operator report from the stats, margins
+// and plan event log, and delivers it by email as HTML with CSV
+// attachments and by webhook as JSON
+type ReportService struct {
+	cfg       config.Reports
+	timezone  string
+	logger    *zap.Logger
+	planRepo  repository.PlanRepository
+	eventRepo repository.PlanEventRepository
+	stats     *StatsService
+	client    *http.Client
+	mailer    *mail.Client
+}
+
+// NewReportService creates a new report service
+func NewReportService(
+	cfg *config.Config,
+	logger *zap.Logger,
+	planRepo repository.PlanRepository,
+	eventRepo repository.PlanEventRepository,
+	stats *StatsService,
+) *ReportService {
+	s := &ReportService{
+		cfg:       cfg.Reports,
+		timezone:  cfg.Billing.Timezone,
+		logger:    logger,
+		planRepo:  planRepo,
+		eventRepo: eventRepo,
+		stats:     stats,
+		client:    &http.Client{Timeout: cfg.Reports.Timeout},
+	}
+
+	if email := cfg.Reports.Email; len(email.To) > 0 {
+		mailer, err := mail.New(mail.Config{
+			Addr:     email.SMTPAddr,
+			Username: email.Username,
+			Password: email.Password,
+			From:     email.From,
+		})
+		if err != nil {
+			logger.Error("Report email disabled", zap.Error(err))
+		}
+		s.mailer = mailer
+	}
+
+	return s
+}
+
+// Run builds and delivers the report at every scheduled time until ctx is
+// cancelled. A report missed while the server was down is not sent late.
+func (s *ReportService) Run(ctx context.Context) {
+	if s == nil || !s.cfg.Enabled() {
+		return
+	}
+
+	for {
+		next, err := s.NextRun(time.Now())
+		if err != nil {
+			s.logger.Error("Failed to schedule operator report", zap.Error(err))
+			return
+		}
+		s.logger.Info("Operator report scheduled", zap.Time("at", next))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		report, err := s.Build(ctx, next.Add(-reportPeriod), next)
+		if err == nil {
+			err = s.Send(ctx, report)
+		}
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error("Failed to send operator report", zap.Error(err))
+		}
+	}
+}
+
+// NextRun returns the first scheduled time after now, on the configured
+// weekday and hour in the billing timezone
+func (s *ReportService) NextRun(now time.Time) (time.Time, error) {
+	weekday, err := s.cfg.ScheduledWeekday()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	loc := time.Local
+	if s.timezone != "" {
+		if loc, err = time.LoadLocation(s.timezone); err != nil {
+			return time.Time{}, fmt.Errorf("failed to load billing timezone: %w", err)
+		}
+	}
+
+	local := now.In(loc)
+	next := time.Date(local.Year(), local.Month(), local.Day(), s.cfg.Hour, 0, 0, 0, loc)
+	next = next.AddDate(0, 0, (int(weekday)-int(next.Weekday())+7)%7)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next, nil
+}
+
+// Build assembles the operator report for [from, to)
+func (s *ReportService) Build(ctx context.Context, from, to time.Time) (*domain.OperatorReport, error) {
+	plans, err := s.planRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plans: %w", err)
+	}
+
+	overall, err := s.stats.GetOverallStats(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	margins, err := s.stats.GetMargins(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.OperatorReport{
+		From:          from,
+		To:            to,
+		Requests:      overall.TotalRequests,
+		BytesIn:       overall.BytesIn,
+		BytesOut:      overall.BytesOut,
+		Revenue:       margins.Total,
+		ProviderSpend: margins.ByProvider,
+		UnpricedPlans: margins.UnpricedPlans,
+	}
+
+	resolution := s.stats.Resolution(from)
+	customers := make(map[string]*domain.CustomerUsage)
+	for _, plan := range plans {
+		if !plan.CreatedAt.Before(from) && plan.CreatedAt.Before(to) {
+			report.NewPlans = append(report.NewPlans, domain.NewReportPlan(plan))
+		}
+		if !plan.ExpiresAt.Before(from) && plan.ExpiresAt.Before(to) && plan.Status != domain.PlanStatusActive {
+			report.ExpiredPlans = append(report.ExpiredPlans, domain.NewReportPlan(plan))
+		}
+
+		// Only plans that existed during the window have traffic or events in it
+		if !plan.CreatedAt.Before(to) {
+			continue
+		}
+
+		stats, err := s.stats.statsRepo.GetPlanStats(ctx, plan.ID, from, to, resolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stats of plan %s: %w", plan.ID, err)
+		}
+		if stats.TotalRequests > 0 || stats.BytesIn > 0 || stats.BytesOut > 0 {
+			usage, exists := customers[plan.CustomerID]
+			if !exists {
+				usage = &domain.CustomerUsage{CustomerID: plan.CustomerID}
+				customers[plan.CustomerID] = usage
+			}
+			usage.Plans++
+			usage.Requests += stats.TotalRequests
+			usage.BytesIn += stats.BytesIn
+			usage.BytesOut += stats.BytesOut
+		}
+
+		events, err := s.eventRepo.GetByPlanID(ctx, plan.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get events of plan %s: %w", plan.ID, err)
+		}
+		failures := domain.InstanceFailures{PlanID: plan.ID, CustomerID: plan.CustomerID}
+		for _, event := range events {
+			if event.CreatedAt.Before(from) || !event.CreatedAt.Before(to) {
+				continue
+			}
+			switch event.Type {
+			case domain.EventInstanceStartFailed:
+				failures.StartFailures++
+			case domain.EventInstanceUnhealthy:
+				failures.Unhealthy++
+			}
+		}
+		if failures.Total() > 0 {
+			report.InstanceFailures = append(report.InstanceFailures, failures)
+		}
+	}
+
+	for _, usage := range customers {
+		report.TopCustomers = append(report.TopCustomers, *usage)
+	}
+	sort.Slice(report.TopCustomers, func(i, j int) bool {
+		a, b := report.TopCustomers[i], report.TopCustomers[j]
+		if a.Bytes() != b.Bytes() {
+			return a.Bytes() > b.Bytes()
+		}
+		return a.CustomerID < b.CustomerID
+	})
+	if len(report.TopCustomers) > s.cfg.TopCustomers && s.cfg.TopCustomers > 0 {
+		report.TopCustomers = report.TopCustomers[:s.cfg.TopCustomers]
+	}
+
+	sort.Slice(report.InstanceFailures, func(i, j int) bool {
+		a, b := report.InstanceFailures[i], report.InstanceFailures[j]
+		if a.Total() != b.Total() {
+			return a.Total() > b.Total()
+		}
+		return a.PlanID.String() < b.PlanID.String()
+	})
+	sortReportPlans(report.NewPlans)
+	sortReportPlans(report.ExpiredPlans)
+
+	return report, nil
+}
+
+// sortReportPlans orders plans by creation, oldest first
+func sortReportPlans(plans []domain.ReportPlan) {
+	sort.Slice(plans, func(i, j int) bool {
+		return plans[i].CreatedAt.Before(plans[j].CreatedAt)
+	})
+}
+
+// Send delivers report to every configured destination. A failed
+// destination does not keep the report from the others.
+func (s *ReportService) Send(ctx context.Context, report *domain.OperatorReport) error {
+	if s.cfg.WebhookURL == "" && s.mailer == nil {
+		return fmt.Errorf("no report destination is configured")
+	}
+
+	var errs []error
+	if s.cfg.WebhookURL != "" {
+		if err := s.postWebhook(ctx, report); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		}
+	}
+	if s.mailer != nil {
+		if err := s.email(report); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	s.logger.Info("Operator report sent",
+		zap.Time("from", report.From),
+		zap.Time("to", report.To),
+		zap.Int("new_plans", len(report.NewPlans)),
+		zap.Int("expired_plans", len(report.ExpiredPlans)),
+	)
+	return nil
+}
+
+// postWebhook posts report as JSON to the report webhook
+func (s *ReportService) postWebhook(ctx context.Context, report *domain.OperatorReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// email sends report as HTML with a CSV attachment per table
+func (s *ReportService) email(report *domain.OperatorReport) error {
+	html, err := RenderReportHTML(report)
+	if err != nil {
+		return err
+	}
+	attachments, err := RenderReportCSV(report)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(&mail.Message{
+		To:          s.cfg.Email.To,
+		Subject:     reportTitle(report),
+		HTML:        string(html),
+		Attachments: attachments,
+	})
+}
+
+// reportTitle names the report by its window
+func reportTitle(report *domain.OperatorReport) string {
+	return fmt.Sprintf("OceanProxy weekly report %s – %s",
+		report.From.Format("2006-01-02"), report.To.Add(-time.Nanosecond).Format("2006-01-02"))
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"bytes": formatReportBytes,
+	"money": func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) },
+	"date":  func(t time.Time) string { return t.Format("2006-01-02") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body style="font-family: sans-serif">
+<h1>{{.Title}}</h1>
+{{with .Report}}
+<h2>Summary</h2>
+<table cellpadding="4">
+<tr><td>New plans</td><td>{{len .NewPlans}}</td></tr>
+<tr><td>Expired plans</td><td>{{len .ExpiredPlans}}</td></tr>
+<tr><td>Requests</td><td>{{.Requests}}</td></tr>
+<tr><td>Traffic in / out</td><td>{{bytes .BytesIn}} / {{bytes .BytesOut}}</td></tr>
+<tr><td>Revenue</td><td>{{money .Revenue.Revenue}}</td></tr>
+<tr><td>Upstream cost</td><td>{{money .Revenue.UpstreamCost}}</td></tr>
+<tr><td>Margin</td><td>{{money .Revenue.Margin}}</td></tr>
+{{if .UnpricedPlans}}<tr><td>Unpriced plans</td><td>{{.UnpricedPlans}}</td></tr>{{end}}
+</table>
+
+<h2>Provider spend</h2>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Provider</th><th>Plans</th><th>Bandwidth (GB)</th><th>Upstream cost</th><th>Revenue</th></tr>
+{{range $provider, $margin := .ProviderSpend}}<tr><td>{{$provider}}</td><td>{{$margin.Plans}}</td><td>{{$margin.BandwidthGB}}</td><td>{{money $margin.UpstreamCost}}</td><td>{{money $margin.Revenue}}</td></tr>
+{{else}}<tr><td colspan="5">No priced plans sold</td></tr>
+{{end}}</table>
+
+<h2>Top customers</h2>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Customer</th><th>Plans</th><th>Requests</th><th>Traffic</th></tr>
+{{range .TopCustomers}}<tr><td>{{.CustomerID}}</td><td>{{.Plans}}</td><td>{{.Requests}}</td><td>{{bytes .Bytes}}</td></tr>
+{{else}}<tr><td colspan="4">No traffic</td></tr>
+{{end}}</table>
+
+<h2>Instance failures</h2>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Plan</th><th>Customer</th><th>Start failures</th><th>Unhealthy</th></tr>
+{{range .InstanceFailures}}<tr><td>{{.PlanID}}</td><td>{{.CustomerID}}</td><td>{{.StartFailures}}</td><td>{{.Unhealthy}}</td></tr>
+{{else}}<tr><td colspan="4">None</td></tr>
+{{end}}</table>
+
+<h2>New plans</h2>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Plan</th><th>Customer</th><th>Type</th><th>Region</th><th>Bandwidth (GB)</th><th>Created</th></tr>
+{{range .NewPlans}}<tr><td>{{.ID}}</td><td>{{.CustomerID}}</td><td>{{.PlanTypeKey}}</td><td>{{.Region}}</td><td>{{.Bandwidth}}</td><td>{{date .CreatedAt}}</td></tr>
+{{else}}<tr><td colspan="6">None</td></tr>
+{{end}}</table>
+
+<h2>Expired plans</h2>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Plan</th><th>Customer</th><th>Type</th><th>Status</th><th>Expired</th></tr>
+{{range .ExpiredPlans}}<tr><td>{{.ID}}</td><td>{{.CustomerID}}</td><td>{{.PlanTypeKey}}</td><td>{{.Status}}</td><td>{{date .ExpiresAt}}</td></tr>
+{{else}}<tr><td colspan="5">None</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// RenderReportHTML renders report as an HTML document
+func RenderReportHTML(report *domain.OperatorReport) ([]byte, error) {
+	var buf bytes.Buffer
+	err := reportTemplate.Execute(&buf, struct {
+		Title  string
+		Report *domain.OperatorReport
+	}{reportTitle(report), report})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render report: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderReportCSV renders the report's tables as CSV attachments
+func RenderReportCSV(report *domain.OperatorReport) ([]mail.Attachment, error) {
+	plans := [][]string{{"kind", "plan_id", "customer_id", "plan_type", "provider", "region", "bandwidth_gb", "status", "created_at", "expires_at"}}
+	addPlans := func(kind string, list []domain.ReportPlan) {
+		for _, plan := range list {
+			plans = append(plans, []string{
+				kind, plan.ID.String(), plan.CustomerID, plan.PlanTypeKey, plan.Provider, plan.Region,
+				strconv.Itoa(plan.Bandwidth), plan.Status,
+				plan.CreatedAt.Format(time.RFC3339), plan.ExpiresAt.Format(time.RFC3339),
+			})
+		}
+	}
+	addPlans("new", report.NewPlans)
+	addPlans("expired", report.ExpiredPlans)
+
+	providers := [][]string{{"provider", "plans", "bandwidth_gb", "revenue", "upstream_cost", "margin"}}
+	names := make([]string, 0, len(report.ProviderSpend))
+	for name := range report.ProviderSpend {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		margin := report.ProviderSpend[name]
+		providers = append(providers, []string{
+			name, strconv.Itoa(margin.Plans), strconv.Itoa(margin.BandwidthGB),
+			strconv.FormatFloat(margin.Revenue, 'f', 2, 64),
+			strconv.FormatFloat(margin.UpstreamCost, 'f', 2, 64),
+			strconv.FormatFloat(margin.Margin, 'f', 2, 64),
+		})
+	}
+
+	customers := [][]string{{"customer_id", "plans", "requests", "bytes_in", "bytes_out"}}
+	for _, usage := range report.TopCustomers {
+		customers = append(customers, []string{
+			usage.CustomerID, strconv.Itoa(usage.Plans), strconv.FormatInt(usage.Requests, 10),
+			strconv.FormatInt(usage.BytesIn, 10), strconv.FormatInt(usage.BytesOut, 10),
+		})
+	}
+
+	failures := [][]string{{"plan_id", "customer_id", "start_failures", "unhealthy"}}
+	for _, failure := range report.InstanceFailures {
+		failures = append(failures, []string{
+			failure.PlanID.String(), failure.CustomerID,
+			strconv.Itoa(failure.StartFailures), strconv.Itoa(failure.Unhealthy),
+		})
+	}
+
+	tables := []struct {
+		name string
+		rows [][]string
+	}{
+		{"plans.csv", plans},
+		{"provider_spend.csv", providers},
+		{"top_customers.csv", customers},
+		{"instance_failures.csv", failures},
+	}
+
+	attachments := make([]mail.Attachment, 0, len(tables))
+	for _, table := range tables {
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		if err := writer.WriteAll(table.rows); err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", table.name, err)
+		}
+		attachments = append(attachments, mail.Attachment{
+			Filename:    table.name,
+			ContentType: "text/csv; charset=utf-8",
+			Data:        buf.Bytes(),
+		})
+	}
+	return attachments, nil
+}
+
+// formatReportBytes formats a byte count with a binary unit
+func formatReportBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,13 @@
+//go:build linux
+
+package service
+
+import "strconv"
+
+// withNoFileLimit wraps a command in prlimit so RLIMIT_NOFILE is set before
+// 3proxy runs and is inherited by the daemon child it forks. In a chroot,
+// prlimit must be on the same path inside it.
+func withNoFileLimit(limit uint64, name string, args []string) (string, []string, error) {
+	value := strconv.FormatUint(limit, 10)
+	return "prlimit", append([]string{"--nofile=" + value + ":" + value, "--", name}, args...), nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package service
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// withNoFileLimit is only implemented on Linux, where prlimit is available
+func withNoFileLimit(limit uint64, name string, args []string) (string, []string, error) {
+	return "", nil, fmt.Errorf("per-process file limits are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// SLAService reports uptime per instance, plan and region from the health
+// monitor's events, compares regions and plans with their SLA targets and
+// notifies operators of violations in the current month.
+type SLAService struct {
+	cfg          config.SLA
+	logger       *zap.Logger
+	planRepo     repository.PlanRepository
+	instanceRepo repository.InstanceRepository
+	eventRepo    repository.PlanEventRepository
+	notifier     Notifier
+	planTypes    *PlanTypeRegistry
+
+	// alerted holds the violations already notified, by month; it is not
+	// persisted, so a restart notifies ongoing violations once more
+	mu      sync.Mutex
+	alerted map[string]bool
+}
+
+// NewSLAService creates a new SLA service
+func NewSLAService(
+	cfg *config.Config,
+	logger *zap.Logger,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	eventRepo repository.PlanEventRepository,
+	notifier Notifier,
+	planTypes *PlanTypeRegistry,
+) *SLAService {
+	return &SLAService{
+		cfg:          cfg.SLA,
+		logger:       logger,
+		planRepo:     planRepo,
+		instanceRepo: instanceRepo,
+		eventRepo:    eventRepo,
+		notifier:     notifier,
+		planTypes:    planTypes,
+		alerted:      make(map[string]bool),
+	}
+}
+
+// Window returns the period of the default report
+func (s *SLAService) Window() time.Duration {
+	return s.cfg.Window
+}
+
+// Run checks the current month against the SLA targets every
+// sla.check_interval until ctx is cancelled
+func (s *SLAService) Run(ctx context.Context) {
+	if s.cfg.CheckInterval <= 0 || s.cfg.RegionTarget == 0 && s.cfg.PlanTarget == 0 && len(s.cfg.RegionTargets) == 0 {
+		return
+	}
+
+	s.logger.Info("Starting SLA checks", zap.Duration("interval", s.cfg.CheckInterval))
+
+	ticker := time.NewTicker(s.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Check(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			s.logger.Error("Failed to check SLA targets", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check notifies each region and plan whose uptime this month is below its
+// target, once per month
+func (s *SLAService) Check(ctx context.Context, now time.Time) error {
+	from, _ := MonthBounds(now)
+	report, err := s.Report(ctx, from, now, now)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	month := from.Format("2006-01")
+	for _, entry := range report.Violations {
+		key := month + "/" + entry.ID
+		if s.alerted[key] {
+			continue
+		}
+		s.alerted[key] = true
+
+		subject := "region " + entry.Region
+		if entry.PlanID != "" {
+			subject = "plan " + entry.PlanID
+		}
+		s.logger.Warn("SLA target missed",
+			zap.String("subject", subject),
+			zap.Float64("uptime_percent", entry.UptimePercent),
+			zap.Float64("target_percent", entry.TargetPercent))
+
+		notification := &domain.Notification{
+			Type:       domain.NotificationSLAViolated,
+			PlanID:     entry.PlanID,
+			CustomerID: entry.CustomerID,
+			Message:    fmt.Sprintf("Uptime of %s is %.3f%% this month, below its %g%% target.", subject, entry.UptimePercent, entry.TargetPercent),
+			Data: map[string]string{
+				"region":         entry.Region,
+				"month":          month,
+				"uptime_percent": fmt.Sprintf("%.3f", entry.UptimePercent),
+				"target_percent": fmt.Sprintf("%g", entry.TargetPercent),
+			},
+			CreatedAt: now,
+		}
+		if err := s.notifier.Notify(ctx, notification); err != nil {
+			s.logger.Error("Failed to send SLA notification", zap.String("subject", subject), zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// Report returns the uptime of every instance, plan and region between from
+// and to. Deleted instances and plans are not reported.
+func (s *SLAService) Report(ctx context.Context, from, to, now time.Time) (*domain.SLAReport, error) {
+	if to.After(now) {
+		to = now
+	}
+
+	instances, err := s.instanceRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instances: %w", err)
+	}
+	plans, err := s.planRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plans: %w", err)
+	}
+	customers := make(map[uuid.UUID]string, len(plans))
+	for _, plan := range plans {
+		customers[plan.ID] = plan.CustomerID
+	}
+	outages, err := instanceOutages(ctx, s.eventRepo, instances, now)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.SLAReport{
+		From:       from,
+		To:         to,
+		Regions:    []domain.UptimeEntry{},
+		Plans:      []domain.UptimeEntry{},
+		Instances:  []domain.UptimeEntry{},
+		Violations: []domain.UptimeEntry{},
+	}
+
+	regions := make(map[string]*uptime)
+	planUptimes := make(map[uuid.UUID]*uptime)
+	planRegions := make(map[uuid.UUID]string)
+	for _, instance := range instances {
+		region := instance.PlanTypeKey
+		if planType := s.planTypes.Get(instance.PlanTypeKey); planType != nil {
+			region = planType.Region
+		}
+
+		u := measureUptime(instance, outages[instance.ID], from, to)
+		report.Instances = append(report.Instances, s.entry(instance.ID.String(), region, instance.PlanID.String(), customers[instance.PlanID], u, 0))
+
+		if regions[region] == nil {
+			regions[region] = &uptime{}
+		}
+		regions[region].add(u)
+		if planUptimes[instance.PlanID] == nil {
+			planUptimes[instance.PlanID] = &uptime{}
+		}
+		planUptimes[instance.PlanID].add(u)
+		planRegions[instance.PlanID] = region
+	}
+
+	for name, u := range regions {
+		target := s.cfg.RegionTarget
+		if override, ok := s.cfg.RegionTargets[name]; ok {
+			target = override
+		}
+		report.Regions = append(report.Regions, s.entry(name, name, "", "", *u, target))
+	}
+	for planID, u := range planUptimes {
+		report.Plans = append(report.Plans, s.entry(planID.String(), planRegions[planID], planID.String(), customers[planID], *u, s.cfg.PlanTarget))
+	}
+
+	for _, entries := range [][]domain.UptimeEntry{report.Regions, report.Plans, report.Instances} {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Region != entries[j].Region {
+				return entries[i].Region < entries[j].Region
+			}
+			return entries[i].ID < entries[j].ID
+		})
+	}
+	for _, entries := range [][]domain.UptimeEntry{report.Regions, report.Plans} {
+		for _, entry := range entries {
+			if entry.Violated {
+				report.Violations = append(report.Violations, entry)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// entry builds a report entry; a target of 0 is never violated
+func (s *SLAService) entry(id, region, planID, customerID string, u uptime, target float64) domain.UptimeEntry {
+	percent := u.percent()
+	return domain.UptimeEntry{
+		ID:              id,
+		Region:          region,
+		PlanID:          planID,
+		CustomerID:      customerID,
+		UptimePercent:   percent,
+		DowntimeSeconds: int64(u.downtime.Seconds()),
+		Outages:         len(mergeOutagePeriods(u.periods)),
+		TargetPercent:   target,
+		Violated:        target > 0 && u.exposure > 0 && percent < target,
+	}
+}
+
+// MonthBounds returns the start of t's calendar month and of the next, in UTC
+func MonthBounds(t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
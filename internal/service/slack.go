@@ -0,0 +1,350 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// ErrSlackSignature is returned for requests not signed with the Slack
+// app's signing secret, or signed too long ago
+var ErrSlackSignature = errors.New("invalid slack request signature")
+
+const (
+	// slackExpiringWindow is used when "plans expiring" is run without a window
+	slackExpiringWindow = 72 * time.Hour
+	// slackMaxListed caps the plans listed in one response
+	slackMaxListed = 20
+	// slackResponseURLPrefix is where Slack's delayed response URLs point
+	slackResponseURLPrefix = "https://hooks.slack.com/"
+	// slackInstancePrefixLen is the shortest instance ID prefix accepted
+	slackInstancePrefixLen = 8
+)
+
+const slackUsage = "Usage:\n" +
+	"• `/oceanproxy status`: plans, instances and open incidents\n" +
+	"• `/oceanproxy plans expiring [72h]`: active plans expiring within the window\n" +
+	"• `/oceanproxy restart <instance-id>`: restart a proxy instance (operators only; an ID prefix of 8+ characters will do)"
+
+// SlackService runs the slash commands of the Slack app so on-call
+// operators can check on and act on the server from Slack
+type SlackService struct {
+	cfg          *config.Config
+	logger       *zap.Logger
+	planRepo     repository.PlanRepository
+	instanceRepo repository.InstanceRepository
+	plans        PlanService
+	proxies      ProxyService
+	incidents    *IncidentService
+	supervisor   *Supervisor
+	client       *http.Client
+}
+
+// NewSlackService creates a new Slack slash command service
+func NewSlackService(
+	cfg *config.Config,
+	logger *zap.Logger,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	plans PlanService,
+	proxies ProxyService,
+	incidents *IncidentService,
+	supervisor *Supervisor,
+) *SlackService {
+	return &SlackService{
+		cfg:          cfg,
+		logger:       logger,
+		planRepo:     planRepo,
+		instanceRepo: instanceRepo,
+		plans:        plans,
+		proxies:      proxies,
+		incidents:    incidents,
+		supervisor:   supervisor,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Verify checks a request's X-Slack-Request-Timestamp and X-Slack-Signature
+// headers against its raw body
+func (s *SlackService) Verify(timestamp, signature string, body []byte, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrSlackSignature
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > s.cfg.Slack.MaxSkew || skew < -s.cfg.Slack.MaxSkew {
+		return ErrSlackSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.cfg.Slack.SigningSecret))
+	fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrSlackSignature
+	}
+	return nil
+}
+
+// Handle runs a verified slash command and returns the reply
+func (s *SlackService) Handle(ctx context.Context, cmd *domain.SlackCommand, now time.Time) *domain.SlackResponse {
+	if s.cfg.Slack.TeamID != "" && cmd.TeamID != s.cfg.Slack.TeamID {
+		return ephemeral("This workspace cannot run OceanProxy commands.")
+	}
+
+	role := s.cfg.Slack.Role(cmd.UserID)
+	args := strings.Fields(cmd.Text)
+	s.logger.Info("Slack command",
+		zap.String("user_id", cmd.UserID),
+		zap.String("user_name", cmd.UserName),
+		zap.String("channel_id", cmd.ChannelID),
+		zap.String("role", role),
+		zap.String("text", cmd.Text))
+
+	if role == "" {
+		return ephemeral("You are not allowed to run OceanProxy commands. Ask an admin to add your Slack user ID to `slack.roles`.")
+	}
+	if len(args) == 0 {
+		return ephemeral(slackUsage)
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "status":
+		return s.status(ctx)
+	case "plans":
+		if len(args) >= 2 && strings.EqualFold(args[1], "expiring") {
+			return s.expiring(ctx, args[2:], now)
+		}
+	case "restart":
+		if role != config.SlackRoleOperator {
+			return ephemeral("Restarting instances needs the operator role.")
+		}
+		if cmd.ReadOnly {
+			return ephemeral("The API is in read-only mode; instances cannot be restarted until it is switched off.")
+		}
+		if len(args) == 2 {
+			return s.restart(ctx, cmd, args[1])
+		}
+	}
+	return ephemeral(slackUsage)
+}
+
+// status summarizes plans, instances and open incidents
+func (s *SlackService) status(ctx context.Context) *domain.SlackResponse {
+	plans, err := s.planRepo.GetAll(ctx)
+	if err != nil {
+		s.logger.Error("Failed to load plans for Slack status", zap.Error(err))
+		return ephemeral("Failed to load plans.")
+	}
+	instances, err := s.instanceRepo.GetAll(ctx)
+	if err != nil {
+		s.logger.Error("Failed to load instances for Slack status", zap.Error(err))
+		return ephemeral("Failed to load instances.")
+	}
+
+	planCounts := make(map[string]int)
+	for _, plan := range plans {
+		planCounts[plan.Status]++
+	}
+	instanceCounts := make(map[string]int)
+	for _, instance := range instances {
+		instanceCounts[instance.Status]++
+	}
+
+	var b strings.Builder
+	b.WriteString("*OceanProxy status*\n")
+	fmt.Fprintf(&b, "Plans: %d (%s)\n", len(plans), formatCounts(planCounts))
+	fmt.Fprintf(&b, "Instances: %d (%s)\n", len(instances), formatCounts(instanceCounts))
+
+	var open []*domain.Incident
+	if s.incidents != nil {
+		all, err := s.incidents.List(ctx, "")
+		if err != nil {
+			s.logger.Error("Failed to load incidents for Slack status", zap.Error(err))
+		}
+		for _, incident := range all {
+			if incident.Status != domain.IncidentResolved {
+				open = append(open, incident)
+			}
+		}
+	}
+	fmt.Fprintf(&b, "Open incidents: %d", len(open))
+	for _, incident := range open {
+		fmt.Fprintf(&b, "\n• %s (%s, %s, %d unhealthy)", incident.Title, incident.Region, incident.Status, len(incident.Unhealthy))
+	}
+
+	return ephemeral(b.String())
+}
+
+// expiring lists active plans expiring within the window in args
+func (s *SlackService) expiring(ctx context.Context, args []string, now time.Time) *domain.SlackResponse {
+	within := slackExpiringWindow
+	if len(args) > 0 {
+		parsed, err := time.ParseDuration(args[0])
+		if err != nil || parsed <= 0 {
+			return ephemeral(fmt.Sprintf("Invalid window %q; use a duration such as 72h.", args[0]))
+		}
+		within = parsed
+	}
+
+	plans, err := s.plans.GetExpiringPlans(ctx, within, "")
+	if err != nil {
+		s.logger.Error("Failed to get expiring plans for Slack", zap.Error(err))
+		return ephemeral("Failed to get expiring plans.")
+	}
+	if len(plans) == 0 {
+		return ephemeral(fmt.Sprintf("No active plans expire within %s.", within))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d plans expire within %s*", len(plans), within)
+	for i, plan := range plans {
+		if i == slackMaxListed {
+			fmt.Fprintf(&b, "\n…and %d more", len(plans)-slackMaxListed)
+			break
+		}
+		fmt.Fprintf(&b, "\n• `%s` %s, %s, expires in %s (%s)",
+			plan.ID, plan.CustomerID, plan.PlanTypeKey,
+			plan.ExpiresAt.Sub(now).Round(time.Minute), plan.ExpiresAt.UTC().Format(time.RFC3339))
+		if plan.AutoRenew {
+			b.WriteString(", auto-renews")
+		}
+	}
+	return ephemeral(b.String())
+}
+
+// restart restarts an instance in the background, as it may outlast
+// Slack's 3 second deadline, and posts the outcome to the response URL
+func (s *SlackService) restart(ctx context.Context, cmd *domain.SlackCommand, id string) *domain.SlackResponse {
+	instance, reply := s.findInstance(ctx, id)
+	if instance == nil {
+		return ephemeral(reply)
+	}
+
+	instanceID, planID, responseURL := instance.ID, instance.PlanID, cmd.ResponseURL
+	user := cmd.UserID
+	s.supervisor.Spawn(context.Background(), "slack_restart", func(ctx context.Context) error {
+		text := fmt.Sprintf("Instance `%s` restarted.", instanceID)
+		err := s.proxies.RestartInstance(ctx, instanceID)
+		if err != nil {
+			text = fmt.Sprintf("Instance `%s` failed to restart: %s", instanceID, err)
+		}
+		s.logger.Info("Slack restart finished",
+			zap.String("instance_id", instanceID.String()),
+			zap.String("plan_id", planID.String()),
+			zap.String("user_id", user),
+			zap.Error(err))
+		s.respond(ctx, responseURL, &domain.SlackResponse{ResponseType: domain.SlackInChannel, Text: text})
+		return err
+	})
+
+	return &domain.SlackResponse{
+		ResponseType: domain.SlackInChannel,
+		Text: fmt.Sprintf("<@%s> is restarting instance `%s` (plan `%s`, port %d, %s).",
+			cmd.UserID, instance.ID, instance.PlanID, instance.LocalPort, instance.Status),
+	}
+}
+
+// findInstance returns the instance with id, or the only one whose ID
+// starts with it; otherwise it returns the reply explaining why not
+func (s *SlackService) findInstance(ctx context.Context, id string) (*domain.ProxyInstance, string) {
+	if parsed, err := uuid.Parse(id); err == nil {
+		instance, err := s.instanceRepo.GetByID(ctx, parsed)
+		if err != nil {
+			return nil, fmt.Sprintf("Instance `%s` not found.", id)
+		}
+		return instance, ""
+	}
+
+	id = strings.ToLower(id)
+	if len(id) < slackInstancePrefixLen {
+		return nil, fmt.Sprintf("Give the instance ID or at least its first %d characters.", slackInstancePrefixLen)
+	}
+
+	instances, err := s.instanceRepo.GetAll(ctx)
+	if err != nil {
+		s.logger.Error("Failed to load instances for Slack restart", zap.Error(err))
+		return nil, "Failed to load instances."
+	}
+	var matches []*domain.ProxyInstance
+	for _, instance := range instances {
+		if strings.HasPrefix(instance.ID.String(), id) {
+			matches = append(matches, instance)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Sprintf("No instance ID starts with `%s`.", id)
+	case 1:
+		return matches[0], ""
+	default:
+		return nil, fmt.Sprintf("%d instance IDs start with `%s`; give more of it.", len(matches), id)
+	}
+}
+
+// respond posts a delayed response to a command's response URL
+func (s *SlackService) respond(ctx context.Context, responseURL string, response *domain.SlackResponse) {
+	if !strings.HasPrefix(responseURL, slackResponseURLPrefix) {
+		s.logger.Warn("Slack response URL is not a Slack webhook, not responding", zap.String("response_url", responseURL))
+		return
+	}
+
+	body, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		s.logger.Warn("Failed to create Slack response", zap.Error(err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.Warn("Failed to send Slack response", zap.Error(err))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		s.logger.Warn("Slack response rejected", zap.Int("status", resp.StatusCode))
+	}
+}
+
+func ephemeral(text string) *domain.SlackResponse {
+	return &domain.SlackResponse{ResponseType: domain.SlackEphemeral, Text: text}
+}
+
+// formatCounts renders counts by status as "active 3, failed 1"
+func formatCounts(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "none"
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s %d", key, counts[key])
+	}
+	return strings.Join(parts, ", ")
+}
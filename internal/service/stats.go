@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// StatsService serves request/usage statistics and runs the downsampling
+// pipeline: raw samples are rolled up hourly and daily, and each tier is
+// pruned once it is older than its retention. Queries use the finest
+// resolution still retained for the start of the requested window.
+type StatsService struct {
+	cfg          config.Stats
+	logger       *zap.Logger
+	statsRepo    repository.StatsRepository
+	planRepo     repository.PlanRepository
+	instanceRepo repository.InstanceRepository
+	planTypes    *PlanTypeRegistry
+}
+
+// NewStatsService creates a new stats service
+func NewStatsService(
+	cfg *config.Config,
+	logger *zap.Logger,
+	statsRepo repository.StatsRepository,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	planTypes *PlanTypeRegistry,
+) *StatsService {
+	return &StatsService{
+		cfg:          cfg.Stats,
+		logger:       logger,
+		statsRepo:    statsRepo,
+		planRepo:     planRepo,
+		instanceRepo: instanceRepo,
+		planTypes:    planTypes,
+	}
+}
+
+// Resolution returns the resolution that covers a window starting at from
+func (s *StatsService) Resolution(from time.Time) string {
+	age := time.Since(from)
+	switch {
+	case s.cfg.RawRetention <= 0 || age <= s.cfg.RawRetention:
+		return repository.StatsResolutionRaw
+	case s.cfg.HourlyRetention <= 0 || age <= s.cfg.HourlyRetention:
+		return repository.StatsResolutionHourly
+	default:
+		return repository.StatsResolutionDaily
+	}
+}
+
+// GetPlanStats returns a plan's traffic and instance counts for [from, to)
+func (s *StatsService) GetPlanStats(ctx context.Context, planID uuid.UUID, from, to time.Time) (*repository.PlanStats, error) {
+	if _, err := s.planRepo.GetByID(ctx, planID); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.statsRepo.GetPlanStats(ctx, planID, from, to, s.Resolution(from))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan stats: %w", err)
+	}
+
+	instances, err := s.instanceRepo.GetByPlanID(ctx, planID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan instances: %w", err)
+	}
+	stats.TotalInstances = len(instances)
+	for _, instance := range instances {
+		if instance.Status == domain.InstanceStatusRunning {
+			stats.ActiveInstances++
+		}
+	}
+
+	return stats, nil
+}
+
+// GetOverallStats returns system-wide traffic for [from, to) with current
+// plan and instance counts
+func (s *StatsService) GetOverallStats(ctx context.Context, from, to time.Time) (*repository.OverallStats, error) {
+	stats, err := s.statsRepo.GetOverallStats(ctx, from, to, s.Resolution(from))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get overall stats: %w", err)
+	}
+
+	plans, err := s.planRepo.Summary(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize plans: %w", err)
+	}
+	stats.TotalPlans = plans.Total
+	stats.ActivePlans = plans.ByStatus[domain.PlanStatusActive]
+	for provider, count := range plans.ByProvider {
+		stats.ProvidersUsed[provider] += count
+	}
+	for region, count := range plans.ByRegion {
+		stats.RegionsUsed[region] += count
+	}
+
+	instances, err := s.instanceRepo.Summary(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize instances: %w", err)
+	}
+	stats.TotalInstances = instances.Total
+	stats.RunningInstances = instances.ByStatus[domain.InstanceStatusRunning]
+
+	return stats, nil
+}
+
+// Run downsamples and prunes stats every rollup interval until ctx is cancelled
+func (s *StatsService) Run(ctx context.Context) {
+	if s == nil || s.cfg.RollupInterval <= 0 {
+		return
+	}
+
+	s.logger.Info("Starting stats downsampling",
+		zap.Duration("interval", s.cfg.RollupInterval),
+		zap.Duration("raw_retention", s.cfg.RawRetention),
+		zap.Duration("hourly_retention", s.cfg.HourlyRetention),
+	)
+
+	ticker := time.NewTicker(s.cfg.RollupInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Downsample(ctx, time.Now()); err != nil {
+			s.logger.Error("Stats downsampling failed", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Downsample rolls completed hours and days up and prunes expired tiers
+func (s *StatsService) Downsample(ctx context.Context, now time.Time) error {
+	hourly, err := s.statsRepo.Rollup(ctx, repository.StatsResolutionRaw, repository.StatsResolutionHourly, now)
+	if err != nil {
+		return fmt.Errorf("failed to roll up hourly stats: %w", err)
+	}
+	daily, err := s.statsRepo.Rollup(ctx, repository.StatsResolutionHourly, repository.StatsResolutionDaily, now)
+	if err != nil {
+		return fmt.Errorf("failed to roll up daily stats: %w", err)
+	}
+
+	var prunedRaw, prunedHourly int
+	if s.cfg.RawRetention > 0 {
+		if prunedRaw, err = s.statsRepo.Prune(ctx, repository.StatsResolutionRaw, now.Add(-s.cfg.RawRetention)); err != nil {
+			return fmt.Errorf("failed to prune raw stats: %w", err)
+		}
+	}
+	if s.cfg.HourlyRetention > 0 {
+		if prunedHourly, err = s.statsRepo.Prune(ctx, repository.StatsResolutionHourly, now.Add(-s.cfg.HourlyRetention)); err != nil {
+			return fmt.Errorf("failed to prune hourly stats: %w", err)
+		}
+	}
+
+	if hourly+daily+prunedRaw+prunedHourly > 0 {
+		s.logger.Debug("Downsampled stats",
+			zap.Int("hourly_buckets", hourly),
+			zap.Int("daily_buckets", daily),
+			zap.Int("raw_pruned", prunedRaw),
+			zap.Int("hourly_pruned", prunedHourly),
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// StatusPageService builds the public status page from instance health.
+// Uptime and incident counts come from the instance_unhealthy and
+// instance_recovered events the health monitor records, so they survive
+// restarts; the current state comes from the instances themselves. The
+// incident records unresolved or resolved within the window are listed
+// with their public updates.
+type StatusPageService struct {
+	cfg          config.StatusPage
+	logger       *zap.Logger
+	instanceRepo repository.InstanceRepository
+	eventRepo    repository.PlanEventRepository
+	incidents    *IncidentService
+	regions      *RegionRegistry
+	planTypes    *PlanTypeRegistry
+
+	mu   sync.Mutex
+	page *domain.StatusPage
+}
+
+// NewStatusPageService creates a new status page service
+func NewStatusPageService(
+	cfg *config.Config,
+	logger *zap.Logger,
+	instanceRepo repository.InstanceRepository,
+	eventRepo repository.PlanEventRepository,
+	incidents *IncidentService,
+	regions *RegionRegistry,
+	planTypes *PlanTypeRegistry,
+) *StatusPageService {
+	return &StatusPageService{
+		cfg:          cfg.StatusPage,
+		logger:       logger,
+		instanceRepo: instanceRepo,
+		eventRepo:    eventRepo,
+		incidents:    incidents,
+		regions:      regions,
+		planTypes:    planTypes,
+	}
+}
+
+// Enabled reports whether the public status page is served
+func (s *StatusPageService) Enabled() bool {
+	return s.cfg.Enabled
+}
+
+// Refresh returns how long a built page is served before it is rebuilt
+func (s *StatusPageService) Refresh() time.Duration {
+	return s.cfg.Refresh
+}
+
+// Page returns the status page, rebuilding it when older than the refresh
+// interval. The page is shared; callers must not modify it.
+func (s *StatusPageService) Page(ctx context.Context, now time.Time) (*domain.StatusPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.page != nil && now.Sub(s.page.GeneratedAt) < s.cfg.Refresh {
+		return s.page, nil
+	}
+
+	page, err := s.build(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	s.page = page
+	return page, nil
+}
+
+func (s *StatusPageService) build(ctx context.Context, now time.Time) (*domain.StatusPage, error) {
+	instances, err := s.instanceRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instances: %w", err)
+	}
+	outages, err := instanceOutages(ctx, s.eventRepo, instances, now)
+	if err != nil {
+		return nil, err
+	}
+
+	windowStart := now.Add(-s.cfg.Window)
+	type regionTotals struct {
+		uptime
+		monitored int
+		unhealthy int
+	}
+	regions := s.regions.List()
+	totals := make(map[string]*regionTotals, len(regions))
+	descriptions := make(map[string]string, len(regions))
+	for _, region := range regions {
+		totals[region.Name] = &regionTotals{}
+		descriptions[region.Name] = region.Description
+	}
+
+	for _, instance := range instances {
+		planType := s.planTypes.Get(instance.PlanTypeKey)
+		if planType == nil || totals[planType.Region] == nil {
+			continue
+		}
+		region := totals[planType.Region]
+		periods := outages[instance.ID]
+
+		region.add(measureUptime(instance, periods, windowStart, now))
+		if instance.Status == domain.InstanceStatusRunning || instance.Status == domain.InstanceStatusFailed {
+			region.monitored++
+			if len(periods) > 0 && periods[len(periods)-1].end.Equal(now) {
+				region.unhealthy++
+			}
+		}
+	}
+
+	page := &domain.StatusPage{
+		Title:       s.cfg.Title,
+		Status:      domain.StatusOperational,
+		WindowDays:  math.Round(s.cfg.Window.Hours()/24*100) / 100,
+		GeneratedAt: now,
+		Regions:     []domain.RegionStatus{},
+	}
+	for name, region := range totals {
+		status := domain.RegionStatus{
+			Region:        name,
+			Description:   descriptions[name],
+			Status:        domain.StatusOperational,
+			UptimePercent: region.percent(),
+		}
+		switch {
+		case region.unhealthy > 0 && region.unhealthy == region.monitored:
+			status.Status = domain.StatusOutage
+		case region.unhealthy > 0:
+			status.Status = domain.StatusDegraded
+		}
+
+		incidents := mergeOutagePeriods(region.periods)
+		status.Incidents = len(incidents)
+		if len(incidents) > 0 {
+			last := incidents[len(incidents)-1].start
+			status.LastIncidentAt = &last
+		}
+
+		page.Status = domain.WorseStatus(page.Status, status.Status)
+		page.Regions = append(page.Regions, status)
+	}
+	sort.Slice(page.Regions, func(i, j int) bool {
+		return page.Regions[i].Region < page.Regions[j].Region
+	})
+
+	if page.Incidents, err = s.incidents.Public(ctx, windowStart); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+// subUserLiveWindow is how far back live connection counts look
+const subUserLiveWindow = time.Minute
+
+// connectionSpan is when a logged connection was open
+type connectionSpan struct {
+	start time.Time
+	end   time.Time
+}
+
+// subUserActivity is the recent traffic of a sub-user behind its live view
+type subUserActivity struct {
+	spans    []connectionSpan
+	hour     time.Time
+	requests int64
+	bytes    int64
+}
+
+// record adds a connection that closed at end after lasting duration
+func (a *subUserActivity) record(end time.Time, duration time.Duration, bytes int64) {
+	a.spans = append(a.spans, connectionSpan{start: end.Add(-duration), end: end})
+
+	hour := end.Truncate(time.Hour)
+	if hour.After(a.hour) {
+		a.hour = hour
+		a.requests = 0
+		a.bytes = 0
+	}
+	if hour.Equal(a.hour) {
+		a.requests++
+		a.bytes += bytes
+	}
+}
+
+// prune drops connections that closed before the live window
+func (a *subUserActivity) prune(now time.Time) {
+	since := now.Add(-subUserLiveWindow)
+	kept := a.spans[:0]
+	for _, span := range a.spans {
+		if !span.end.Before(since) {
+			kept = append(kept, span)
+		}
+	}
+	a.spans = kept
+}
+
+// live returns the activity as of now
+func (a *subUserActivity) live(now, updatedAt time.Time) domain.SubUserLive {
+	live := domain.SubUserLive{
+		Hour:      now.Truncate(time.Hour),
+		UpdatedAt: updatedAt,
+	}
+	if a.hour.Equal(live.Hour) {
+		live.RequestsThisHour = a.requests
+		live.BytesThisHour = a.bytes
+	}
+
+	since := now.Add(-subUserLiveWindow)
+	type edge struct {
+		at    time.Time
+		delta int
+	}
+	edges := make([]edge, 0, 2*len(a.spans))
+	for _, span := range a.spans {
+		if span.end.Before(since) {
+			continue
+		}
+		live.Connections++
+		edges = append(edges, edge{span.start, 1}, edge{span.end, -1})
+	}
+
+	// Sweep the connection starts and ends in order, closing before opening
+	// at the same instant, for the most connections open at once
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].at.Equal(edges[j].at) {
+			return edges[i].delta < edges[j].delta
+		}
+		return edges[i].at.Before(edges[j].at)
+	})
+	open := 0
+	for _, e := range edges {
+		open += e.delta
+		if open > live.ActiveConnections {
+			live.ActiveConnections = open
+		}
+	}
+	return live
+}
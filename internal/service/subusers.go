@@ -0,0 +1,513 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// maxSubUserNameLength bounds sub-user names
+const maxSubUserNameLength = 64
+
+// ErrSubUserNotFound is returned for a sub-user the plan does not have
+var ErrSubUserNotFound = errors.New("sub-user not found")
+
+// SubUserService splits plans among sub-users. Each sub-user is an extra
+// user on the plan's instances with its own credentials; its traffic is
+// counted from the instance logs, where 3proxy records the user of every
+// request, and a sub-user over its bandwidth is locked out of the
+// instances. Running instances are restarted whenever the users change.
+// Recent activity for the live view is kept in memory only.
+type SubUserService struct {
+	cfg          config.SubUsers
+	logDir       string
+	logger       *zap.Logger
+	planRepo     repository.PlanRepository
+	instanceRepo repository.InstanceRepository
+	usageRepo    repository.SubUserUsageRepository
+	proxyService ProxyService
+	credentials  *credentialPolicy
+	events       *eventRecorder
+
+	mu          sync.Mutex
+	tailer      *logTailer
+	primed      bool
+	activity    map[uuid.UUID]*subUserActivity
+	collectedAt time.Time
+}
+
+// NewSubUserService creates a new sub-user service; it only counts traffic
+// when proxy.subusers.interval is set
+func NewSubUserService(
+	cfg *config.Config,
+	logger *zap.Logger,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	usageRepo repository.SubUserUsageRepository,
+	eventRepo repository.PlanEventRepository,
+	proxyService ProxyService,
+) *SubUserService {
+	return &SubUserService{
+		cfg:          cfg.Proxy.SubUsers,
+		logDir:       cfg.Proxy.LogDir,
+		logger:       logger,
+		planRepo:     planRepo,
+		instanceRepo: instanceRepo,
+		usageRepo:    usageRepo,
+		proxyService: proxyService,
+		credentials:  newCredentialPolicy(cfg),
+		events:       newEventRecorder(eventRepo, logger),
+		tailer:       newLogTailer(),
+		activity:     make(map[uuid.UUID]*subUserActivity),
+	}
+}
+
+// Run counts sub-user traffic every interval until ctx is cancelled
+func (s *SubUserService) Run(ctx context.Context) {
+	if s == nil || s.cfg.Interval <= 0 {
+		return
+	}
+
+	s.logger.Info("Starting sub-user accounting", zap.Duration("interval", s.cfg.Interval))
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Collect(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			s.logger.Error("Failed to count sub-user traffic", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// List returns a plan's sub-users with their usage
+func (s *SubUserService) List(ctx context.Context, planID uuid.UUID) ([]domain.SubUserResponse, error) {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	return s.withUsage(ctx, plan.SubUsers)
+}
+
+// Get returns one of a plan's sub-users with its usage
+func (s *SubUserService) Get(ctx context.Context, planID, subUserID uuid.UUID) (*domain.SubUserResponse, error) {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	index := subUserIndex(plan, subUserID)
+	if index < 0 {
+		return nil, ErrSubUserNotFound
+	}
+
+	responses, err := s.withUsage(ctx, plan.SubUsers[index:index+1])
+	if err != nil {
+		return nil, err
+	}
+	return &responses[0], nil
+}
+
+// Create adds a sub-user to a plan. Credentials not given are generated.
+func (s *SubUserService) Create(ctx context.Context, planID uuid.UUID, req *domain.SubUserRequest, now time.Time) (*domain.SubUserResponse, error) {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(plan.SubUsers) >= s.cfg.MaxPerPlan {
+		return nil, &domain.PolicyError{PlanType: plan.PlanTypeKey, Field: "subusers", Reason: fmt.Sprintf("at most %d per plan", s.cfg.MaxPerPlan)}
+	}
+	if err := s.validate(plan, req, uuid.Nil); err != nil {
+		return nil, err
+	}
+
+	subUser := domain.SubUser{
+		ID:          uuid.New(),
+		Name:        req.Name,
+		Username:    req.Username,
+		Password:    req.Password,
+		BandwidthGB: req.BandwidthGB,
+		Disabled:    req.Disabled,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if subUser.Username == "" {
+		if subUser.Username, err = generatePlanUsername(s.credentials, plan); err != nil {
+			return nil, err
+		}
+	} else if planUsernames(plan)[subUser.Username] {
+		return nil, &domain.PolicyError{PlanType: plan.PlanTypeKey, Field: "username", Reason: "is already used by this plan"}
+	}
+	if subUser.Password == "" {
+		if subUser.Password, err = s.credentials.GeneratePassword(); err != nil {
+			return nil, err
+		}
+	}
+
+	plan.SubUsers = append(plan.SubUsers, subUser)
+	if err := s.save(ctx, plan, now); err != nil {
+		return nil, err
+	}
+
+	s.events.record(ctx, plan.ID, nil, domain.EventSubUserCreated, "Sub-user created", map[string]string{
+		"subuser_id":   subUser.ID.String(),
+		"name":         subUser.Name,
+		"username":     subUser.Username,
+		"bandwidth_gb": fmt.Sprint(subUser.BandwidthGB),
+	})
+	s.logger.Info("Created sub-user",
+		zap.String("plan_id", plan.ID.String()),
+		zap.String("subuser_id", subUser.ID.String()))
+
+	if err := restartPlanInstances(ctx, s.instanceRepo, s.proxyService, s.logger, plan.ID); err != nil {
+		return nil, fmt.Errorf("failed to apply the sub-user: %w", err)
+	}
+
+	return &domain.SubUserResponse{SubUser: subUser}, nil
+}
+
+// Update replaces a sub-user's name, bandwidth and disabled flag, and its
+// password when one is given. Raising the bandwidth of an exhausted
+// sub-user above its usage lets it back in.
+func (s *SubUserService) Update(ctx context.Context, planID, subUserID uuid.UUID, req *domain.SubUserRequest, now time.Time) (*domain.SubUserResponse, error) {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	index := subUserIndex(plan, subUserID)
+	if index < 0 {
+		return nil, ErrSubUserNotFound
+	}
+	subUser := &plan.SubUsers[index]
+
+	if req.Username != "" && req.Username != subUser.Username {
+		return nil, &domain.PolicyError{PlanType: plan.PlanTypeKey, Field: "username", Reason: "cannot be changed"}
+	}
+	if err := s.validate(plan, req, subUser.ID); err != nil {
+		return nil, err
+	}
+
+	usage, err := s.usageRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sub-user usage: %w", err)
+	}
+
+	subUser.Name = req.Name
+	subUser.BandwidthGB = req.BandwidthGB
+	subUser.Disabled = req.Disabled
+	if req.Password != "" {
+		subUser.Password = req.Password
+	}
+	subUser.Exhausted = overLimit(subUser, usage[subUser.ID])
+	subUser.UpdatedAt = now
+
+	updated := *subUser
+	if err := s.save(ctx, plan, now); err != nil {
+		return nil, err
+	}
+
+	s.events.record(ctx, plan.ID, nil, domain.EventSubUserUpdated, "Sub-user updated", map[string]string{
+		"subuser_id":   updated.ID.String(),
+		"bandwidth_gb": fmt.Sprint(updated.BandwidthGB),
+		"disabled":     fmt.Sprint(updated.Disabled),
+	})
+	s.logger.Info("Updated sub-user",
+		zap.String("plan_id", plan.ID.String()),
+		zap.String("subuser_id", updated.ID.String()))
+
+	if err := restartPlanInstances(ctx, s.instanceRepo, s.proxyService, s.logger, plan.ID); err != nil {
+		return nil, fmt.Errorf("failed to apply the sub-user: %w", err)
+	}
+
+	responses, err := s.withUsage(ctx, []domain.SubUser{updated})
+	if err != nil {
+		return nil, err
+	}
+	return &responses[0], nil
+}
+
+// Delete removes a sub-user and its usage
+func (s *SubUserService) Delete(ctx context.Context, planID, subUserID uuid.UUID, now time.Time) error {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return err
+	}
+	index := subUserIndex(plan, subUserID)
+	if index < 0 {
+		return ErrSubUserNotFound
+	}
+
+	plan.SubUsers = append(plan.SubUsers[:index], plan.SubUsers[index+1:]...)
+	if err := s.save(ctx, plan, now); err != nil {
+		return err
+	}
+	if err := s.usageRepo.Delete(ctx, subUserID); err != nil {
+		s.logger.Warn("Failed to delete sub-user usage",
+			zap.String("subuser_id", subUserID.String()),
+			zap.Error(err))
+	}
+
+	s.events.record(ctx, plan.ID, nil, domain.EventSubUserDeleted, "Sub-user deleted", map[string]string{
+		"subuser_id": subUserID.String(),
+	})
+	s.logger.Info("Deleted sub-user",
+		zap.String("plan_id", plan.ID.String()),
+		zap.String("subuser_id", subUserID.String()))
+
+	if err := restartPlanInstances(ctx, s.instanceRepo, s.proxyService, s.logger, plan.ID); err != nil {
+		return fmt.Errorf("failed to remove the sub-user: %w", err)
+	}
+	return nil
+}
+
+// Collect counts the sub-user requests logged since the last collection,
+// then locks out sub-users that have used their bandwidth. As with the
+// traffic collector, existing logs are read from their end on the first
+// collection.
+func (s *SubUserService) Collect(ctx context.Context, now time.Time) error {
+	plans, err := s.planRepo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get plans: %w", err)
+	}
+
+	// Sub-users by plan and username
+	subUsers := make(map[uuid.UUID]map[string]uuid.UUID)
+	subUserPlans := make(map[uuid.UUID]uuid.UUID)
+	for _, plan := range plans {
+		if len(plan.SubUsers) == 0 {
+			continue
+		}
+		byUsername := make(map[string]uuid.UUID, len(plan.SubUsers))
+		for _, subUser := range plan.SubUsers {
+			byUsername[subUser.Username] = subUser.ID
+			subUserPlans[subUser.ID] = plan.ID
+		}
+		subUsers[plan.ID] = byUsername
+	}
+
+	instances, err := s.instanceRepo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get instances: %w", err)
+	}
+
+	s.mu.Lock()
+	traffic := make(map[uuid.UUID]*domain.SubUserUsage)
+	for _, instance := range instances {
+		if instance.Status != domain.InstanceStatusRunning {
+			continue
+		}
+		byUsername := subUsers[instance.PlanID]
+		path := proxyLogPath(s.logDir, instance.ID)
+		err := s.tailer.read(path, s.primed, func(line string) {
+			if byUsername == nil {
+				return
+			}
+			entry, ok := parseProxyLogLine(line)
+			if !ok || entry.authFailed {
+				return
+			}
+			id, ok := byUsername[entry.user]
+			if !ok {
+				return
+			}
+
+			usage := traffic[id]
+			if usage == nil {
+				usage = &domain.SubUserUsage{}
+				traffic[id] = usage
+			}
+			usage.Requests++
+			usage.BytesIn += entry.bytesIn
+			usage.BytesOut += entry.bytesOut
+			at := entry.time
+			if at.IsZero() {
+				at = now
+			}
+
+			activity := s.activity[id]
+			if activity == nil {
+				activity = &subUserActivity{}
+				s.activity[id] = activity
+			}
+			activity.record(at, time.Duration(entry.durationMs)*time.Millisecond, entry.bytesIn+entry.bytesOut)
+			if usage.LastUsedAt == nil || at.After(*usage.LastUsedAt) {
+				usage.LastUsedAt = &at
+			}
+		})
+		if err != nil && !os.IsNotExist(err) {
+			s.logger.Debug("Failed to read proxy log", zap.String("path", path), zap.Error(err))
+		}
+	}
+	s.primed = true
+	for id, activity := range s.activity {
+		if _, ok := subUserPlans[id]; !ok {
+			delete(s.activity, id)
+			continue
+		}
+		activity.prune(now)
+	}
+	s.collectedAt = now
+	s.mu.Unlock()
+
+	if err := s.usageRepo.Add(ctx, traffic); err != nil {
+		return fmt.Errorf("failed to record sub-user traffic: %w", err)
+	}
+	if len(traffic) == 0 {
+		return nil
+	}
+
+	usage, err := s.usageRepo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get sub-user usage: %w", err)
+	}
+	for _, plan := range plans {
+		s.enforceLimits(ctx, plan, usage, now)
+	}
+	return nil
+}
+
+// enforceLimits locks out the plan's sub-users that have used their
+// bandwidth
+func (s *SubUserService) enforceLimits(ctx context.Context, plan *domain.ProxyPlan, usage map[uuid.UUID]*domain.SubUserUsage, now time.Time) {
+	var exhausted []domain.SubUser
+	for i := range plan.SubUsers {
+		subUser := &plan.SubUsers[i]
+		if subUser.Exhausted || !overLimit(subUser, usage[subUser.ID]) {
+			continue
+		}
+		subUser.Exhausted = true
+		subUser.UpdatedAt = now
+		exhausted = append(exhausted, *subUser)
+	}
+	if len(exhausted) == 0 {
+		return
+	}
+
+	if err := s.save(ctx, plan, now); err != nil {
+		s.logger.Error("Failed to lock out exhausted sub-users",
+			zap.String("plan_id", plan.ID.String()),
+			zap.Error(err))
+		return
+	}
+	for _, subUser := range exhausted {
+		s.events.record(ctx, plan.ID, nil, domain.EventSubUserExhausted, "Sub-user used its bandwidth", map[string]string{
+			"subuser_id":   subUser.ID.String(),
+			"bandwidth_gb": fmt.Sprint(subUser.BandwidthGB),
+		})
+		s.logger.Info("Sub-user used its bandwidth",
+			zap.String("plan_id", plan.ID.String()),
+			zap.String("subuser_id", subUser.ID.String()))
+	}
+
+	if err := restartPlanInstances(ctx, s.instanceRepo, s.proxyService, s.logger, plan.ID); err != nil {
+		s.logger.Error("Failed to restart instances after sub-users were locked out",
+			zap.String("plan_id", plan.ID.String()),
+			zap.Error(err))
+	}
+}
+
+// validate checks a sub-user request against the plan. Sub-user bandwidths
+// together may not exceed the plan's.
+func (s *SubUserService) validate(plan *domain.ProxyPlan, req *domain.SubUserRequest, subUserID uuid.UUID) error {
+	reject := func(field, reason string) error {
+		return &domain.PolicyError{PlanType: plan.PlanTypeKey, Field: field, Reason: reason}
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxSubUserNameLength {
+		return reject("name", fmt.Sprintf("must be 1 to %d characters", maxSubUserNameLength))
+	}
+	if req.Username != "" {
+		if err := s.credentials.ValidateUsername(plan.PlanTypeKey, req.Username); err != nil {
+			return err
+		}
+	}
+	if req.Password != "" {
+		if err := s.credentials.ValidatePassword(plan.PlanTypeKey, req.Password); err != nil {
+			return err
+		}
+	}
+
+	if req.BandwidthGB < 0 {
+		return reject("bandwidth_gb", "must not be negative")
+	}
+	allocated := req.BandwidthGB
+	for _, subUser := range plan.SubUsers {
+		if subUser.ID != subUserID {
+			allocated += subUser.BandwidthGB
+		}
+	}
+	if allocated > plan.Bandwidth {
+		return reject("bandwidth_gb", fmt.Sprintf("sub-users would share %d GB of the plan's %d GB", allocated, plan.Bandwidth))
+	}
+	return nil
+}
+
+// save stores a plan whose sub-users changed
+func (s *SubUserService) save(ctx context.Context, plan *domain.ProxyPlan, now time.Time) error {
+	plan.UpdatedAt = now
+	if err := s.planRepo.Update(ctx, plan); err != nil {
+		return fmt.Errorf("failed to update plan: %w", err)
+	}
+	return nil
+}
+
+// withUsage pairs sub-users with their counted usage and live activity
+func (s *SubUserService) withUsage(ctx context.Context, subUsers []domain.SubUser) ([]domain.SubUserResponse, error) {
+	usage, err := s.usageRepo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sub-user usage: %w", err)
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	responses := make([]domain.SubUserResponse, 0, len(subUsers))
+	for _, subUser := range subUsers {
+		response := domain.SubUserResponse{SubUser: subUser}
+		if counted := usage[subUser.ID]; counted != nil {
+			response.Usage = *counted
+			response.UsedBytes = counted.UsedBytes()
+		}
+		if activity := s.activity[subUser.ID]; activity != nil {
+			live := activity.live(now, s.collectedAt)
+			response.Live = &live
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// subUserIndex returns the position of a sub-user in its plan, or -1
+func subUserIndex(plan *domain.ProxyPlan, subUserID uuid.UUID) int {
+	for i, subUser := range plan.SubUsers {
+		if subUser.ID == subUserID {
+			return i
+		}
+	}
+	return -1
+}
+
+// overLimit reports whether a sub-user has used its bandwidth
+func overLimit(subUser *domain.SubUser, usage *domain.SubUserUsage) bool {
+	limit := subUser.LimitBytes()
+	return limit > 0 && usage != nil && usage.UsedBytes() >= limit
+}
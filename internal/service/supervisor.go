@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+// RestartPolicy decides whether a supervised worker runs again after it
+// returns
+type RestartPolicy string
+
+const (
+	// RestartNever leaves the worker stopped
+	RestartNever RestartPolicy = "never"
+	// RestartOnFailure restarts a worker that panicked or returned an error
+	RestartOnFailure RestartPolicy = "on_failure"
+	// RestartAlways restarts a worker however it returned, until ctx ends
+	RestartAlways RestartPolicy = "always"
+)
+
+// Restart backoff bounds; the delay doubles after each consecutive failure
+const (
+	supervisorMinBackoff = time.Second
+	supervisorMaxBackoff = time.Minute
+)
+
+// Supervisor runs background goroutines, recovering panics, restarting
+// workers per their policy and recording the status of each. Wait returns
+// once every goroutine has returned, like an errgroup.
+type Supervisor struct {
+	logger *zap.Logger
+
+	wg       sync.WaitGroup
+	errOnce  sync.Once
+	firstErr error
+
+	mu      sync.Mutex
+	workers map[string]*domain.WorkerStatus
+}
+
+// NewSupervisor creates a new supervisor
+func NewSupervisor(logger *zap.Logger) *Supervisor {
+	return &Supervisor{
+		logger:  logger,
+		workers: make(map[string]*domain.WorkerStatus),
+	}
+}
+
+// Go runs a long-lived worker until ctx is cancelled. A panic is recovered
+// and treated as an error. Workers that fail are restarted after a backoff
+// unless policy is RestartNever; a worker that stops for good with an error
+// makes Wait return it.
+func (s *Supervisor) Go(ctx context.Context, name string, policy RestartPolicy, run func(ctx context.Context) error) {
+	s.mu.Lock()
+	s.workers[name] = &domain.WorkerStatus{
+		Name:   name,
+		Kind:   domain.WorkerKindWorker,
+		Policy: string(policy),
+		State:  domain.WorkerStateRunning,
+	}
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		backoff := supervisorMinBackoff
+		for {
+			err := s.run(ctx, name, run)
+			if ctx.Err() != nil {
+				s.finish(name, domain.WorkerStateStopped)
+				return
+			}
+
+			restart := policy == RestartAlways || (policy == RestartOnFailure && err != nil)
+			if !restart {
+				if err != nil {
+					s.finish(name, domain.WorkerStateFailed)
+					s.errOnce.Do(func() { s.firstErr = fmt.Errorf("worker %s: %w", name, err) })
+				} else {
+					s.finish(name, domain.WorkerStateStopped)
+				}
+				return
+			}
+
+			if err == nil {
+				backoff = supervisorMinBackoff
+			}
+			s.update(name, func(status *domain.WorkerStatus) {
+				status.State = domain.WorkerStateRestarting
+				status.Restarts++
+			})
+			s.logger.Warn("Restarting background worker",
+				zap.String("worker", name),
+				zap.Duration("backoff", backoff),
+				zap.Error(err))
+
+			select {
+			case <-ctx.Done():
+				s.finish(name, domain.WorkerStateStopped)
+				return
+			case <-time.After(backoff):
+			}
+			if err != nil {
+				backoff *= 2
+				if backoff > supervisorMaxBackoff {
+					backoff = supervisorMaxBackoff
+				}
+			}
+		}
+	}()
+}
+
+// Spawn runs a one-shot task. A panic is recovered, and failures are logged
+// and counted under name; they never reach Wait.
+func (s *Supervisor) Spawn(ctx context.Context, name string, run func(ctx context.Context) error) {
+	s.mu.Lock()
+	if s.workers[name] == nil {
+		s.workers[name] = &domain.WorkerStatus{
+			Name:  name,
+			Kind:  domain.WorkerKindTask,
+			State: domain.WorkerStateIdle,
+		}
+	}
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		if err := s.run(ctx, name, run); err != nil {
+			s.logger.Warn("Background task failed", zap.String("task", name), zap.Error(err))
+		}
+	}()
+}
+
+// Wait blocks until every goroutine has returned or ctx is done. It returns
+// the error of the first worker that stopped for good, if any.
+func (s *Supervisor) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return s.firstErr
+	case <-ctx.Done():
+		return fmt.Errorf("background goroutines still running: %w", ctx.Err())
+	}
+}
+
+// Status returns every worker and task, workers first, by name
+func (s *Supervisor) Status() []domain.WorkerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]domain.WorkerStatus, 0, len(s.workers))
+	for _, status := range s.workers {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Kind != statuses[j].Kind {
+			return statuses[i].Kind == domain.WorkerKindWorker
+		}
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// run calls fn once, turning a panic into an error and recording the outcome
+func (s *Supervisor) run(ctx context.Context, name string, fn func(ctx context.Context) error) (err error) {
+	now := time.Now()
+	s.update(name, func(status *domain.WorkerStatus) {
+		status.State = domain.WorkerStateRunning
+		status.Running++
+		status.Runs++
+		status.StartedAt = &now
+	})
+
+	defer func() {
+		panicked := false
+		if recovered := recover(); recovered != nil {
+			panicked = true
+			err = fmt.Errorf("panic: %v", recovered)
+			s.logger.Error("Background goroutine panicked",
+				zap.String("name", name),
+				zap.Any("panic", recovered),
+				zap.ByteString("stack", debug.Stack()))
+		}
+
+		ended := time.Now()
+		s.update(name, func(status *domain.WorkerStatus) {
+			status.Running--
+			if status.Kind == domain.WorkerKindTask && status.Running == 0 {
+				status.State = domain.WorkerStateIdle
+			}
+			if panicked {
+				status.Panics++
+			}
+			if err != nil && ctx.Err() == nil {
+				status.Failures++
+				status.LastError = err.Error()
+				status.LastErrorAt = &ended
+			}
+		})
+	}()
+
+	return fn(ctx)
+}
+
+// finish records that a worker will not run again
+func (s *Supervisor) finish(name, state string) {
+	now := time.Now()
+	s.update(name, func(status *domain.WorkerStatus) {
+		status.State = state
+		status.StoppedAt = &now
+	})
+}
+
+func (s *Supervisor) update(name string, fn func(status *domain.WorkerStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if status := s.workers[name]; status != nil {
+		fn(status)
+	}
+}
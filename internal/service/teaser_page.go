@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// TeaserPage serves the renewal notice teaser and exhausted plans' 3proxy
+// instances forward plain HTTP requests to. Every request, whatever its URL,
+// is answered with billing.teaser_page.status and message, so customers see
+// why their proxy stopped working and how to restore it.
+type TeaserPage struct {
+	cfg    *config.Config
+	logger *zap.Logger
+}
+
+// NewTeaserPage creates the teaser page server
+func NewTeaserPage(cfg *config.Config, logger *zap.Logger) *TeaserPage {
+	return &TeaserPage{cfg: cfg, logger: logger}
+}
+
+// Run serves the page on billing.teaser_page.listen until ctx is cancelled
+func (p *TeaserPage) Run(ctx context.Context) {
+	if p == nil || p.cfg.Billing.TeaserPage.Listen == "" {
+		return
+	}
+
+	server := &http.Server{
+		Addr:              p.cfg.Billing.TeaserPage.Listen,
+		Handler:           p,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	p.logger.Info("Serving teaser page", zap.String("listen", server.Addr))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		p.logger.Error("Teaser page server failed", zap.Error(err))
+	}
+}
+
+// ServeHTTP answers any proxied request with the renewal notice
+func (p *TeaserPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	page := p.cfg.Billing.TeaserPage
+	if page.Status == http.StatusProxyAuthRequired {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="proxy"`)
+	}
+	w.Header().Set("Content-Type", page.ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(page.Status)
+	io.WriteString(w, page.Message)
+}
+
+// teaserPageParent returns the host and port 3proxy instances reach the
+// teaser page on, or false when it is disabled
+func teaserPageParent(listen string) (string, int, bool) {
+	if listen == "" {
+		return "", 0, false
+	}
+	host, portText, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", 0, false
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil || port <= 0 {
+		return "", 0, false
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = domain.DefaultLocalHost
+	}
+	return host, port, true
+}
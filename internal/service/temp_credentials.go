@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// defaultTempCredentialTTL is how long a credential lasts when the request
+// does not say
+const defaultTempCredentialTTL = time.Hour
+
+// ErrTempCredentialNotFound is returned for a credential the plan does not have
+var ErrTempCredentialNotFound = errors.New("temporary credential not found")
+
+// TempCredentialService issues short-lived credentials for plans. Each one
+// is an extra user on the plan's instances, sharing the plan's rules and
+// upstream, so the plan's own password never has to be handed out. Running
+// instances are restarted to add and remove users, and expired credentials
+// are swept every proxy.temp_credentials.interval.
+type TempCredentialService struct {
+	cfg          config.TempCredentials
+	logger       *zap.Logger
+	planRepo     repository.PlanRepository
+	instanceRepo repository.InstanceRepository
+	planService  PlanService
+	proxyService ProxyService
+	credentials  *credentialPolicy
+	events       *eventRecorder
+}
+
+// NewTempCredentialService creates a new temporary credential service
+func NewTempCredentialService(
+	cfg *config.Config,
+	logger *zap.Logger,
+	planRepo repository.PlanRepository,
+	instanceRepo repository.InstanceRepository,
+	eventRepo repository.PlanEventRepository,
+	planService PlanService,
+	proxyService ProxyService,
+) *TempCredentialService {
+	return &TempCredentialService{
+		cfg:          cfg.Proxy.TempCredentials,
+		logger:       logger,
+		planRepo:     planRepo,
+		instanceRepo: instanceRepo,
+		planService:  planService,
+		proxyService: proxyService,
+		credentials:  newCredentialPolicy(cfg),
+		events:       newEventRecorder(eventRepo, logger),
+	}
+}
+
+// Run removes expired credentials every interval until ctx is cancelled
+func (s *TempCredentialService) Run(ctx context.Context) {
+	if s == nil || s.cfg.Interval <= 0 {
+		return
+	}
+
+	s.logger.Info("Starting temporary credential expiry", zap.Duration("interval", s.cfg.Interval))
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.ExpireDue(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			s.logger.Error("Failed to expire temporary credentials", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Issue adds a credential to an active plan and restarts its running
+// instances so they accept it
+func (s *TempCredentialService) Issue(ctx context.Context, planID uuid.UUID, req *domain.TempCredentialRequest, now time.Time) (*domain.TempCredentialResponse, error) {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	reject := func(field, reason string) error {
+		return &domain.PolicyError{PlanType: plan.PlanTypeKey, Field: field, Reason: reason}
+	}
+	if plan.Status != domain.PlanStatusActive {
+		return nil, reject("status", fmt.Sprintf("is %s; temporary credentials need an active plan", plan.Status))
+	}
+	maxMinutes := int(s.cfg.MaxTTL / time.Minute)
+	if req.Minutes < 0 || req.Minutes > maxMinutes {
+		return nil, reject("minutes", fmt.Sprintf("must be between 1 and %d", maxMinutes))
+	}
+	ttl := defaultTempCredentialTTL
+	if req.Minutes > 0 {
+		ttl = time.Duration(req.Minutes) * time.Minute
+	} else if ttl > s.cfg.MaxTTL {
+		ttl = s.cfg.MaxTTL
+	}
+
+	active := activeTempCredentials(plan.TempCredentials, now)
+	if len(active) >= s.cfg.MaxPerPlan {
+		return nil, reject("temp_credentials", fmt.Sprintf("at most %d may be active at once", s.cfg.MaxPerPlan))
+	}
+
+	credential := domain.TempCredential{
+		ID:        uuid.New(),
+		Label:     req.Label,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if credential.Username, err = generatePlanUsername(s.credentials, plan); err != nil {
+		return nil, err
+	}
+	if credential.Password, err = s.credentials.GeneratePassword(); err != nil {
+		return nil, err
+	}
+
+	// Expired credentials are dropped along the way
+	plan.TempCredentials = append(active, credential)
+	plan.UpdatedAt = now
+	if err := s.planRepo.Update(ctx, plan); err != nil {
+		return nil, fmt.Errorf("failed to update plan: %w", err)
+	}
+
+	s.events.record(ctx, plan.ID, nil, domain.EventTempCredentialIssued, "Temporary credential issued", map[string]string{
+		"credential_id": credential.ID.String(),
+		"username":      credential.Username,
+		"label":         credential.Label,
+		"expires_at":    credential.ExpiresAt.Format(time.RFC3339),
+	})
+	s.logger.Info("Issued temporary credential",
+		zap.String("plan_id", plan.ID.String()),
+		zap.String("credential_id", credential.ID.String()),
+		zap.Time("expires_at", credential.ExpiresAt))
+
+	if err := restartPlanInstances(ctx, s.instanceRepo, s.proxyService, s.logger, plan.ID); err != nil {
+		return nil, fmt.Errorf("failed to apply the temporary credential: %w", err)
+	}
+
+	endpoints, err := s.planService.GetPlanEndpoints(ctx, plan.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan endpoints: %w", err)
+	}
+
+	return &domain.TempCredentialResponse{
+		PlanID:         plan.ID,
+		TempCredential: credential,
+		Proxies:        tempCredentialEndpoints(endpoints, &credential),
+	}, nil
+}
+
+// Revoke removes a credential before it expires
+func (s *TempCredentialService) Revoke(ctx context.Context, planID, credentialID uuid.UUID, now time.Time) error {
+	plan, err := s.planRepo.GetByID(ctx, planID)
+	if err != nil {
+		return err
+	}
+
+	kept := make([]domain.TempCredential, 0, len(plan.TempCredentials))
+	found := false
+	for _, credential := range plan.TempCredentials {
+		if credential.ID == credentialID {
+			found = true
+			continue
+		}
+		kept = append(kept, credential)
+	}
+	if !found {
+		return ErrTempCredentialNotFound
+	}
+
+	plan.TempCredentials = kept
+	plan.UpdatedAt = now
+	if err := s.planRepo.Update(ctx, plan); err != nil {
+		return fmt.Errorf("failed to update plan: %w", err)
+	}
+
+	s.events.record(ctx, plan.ID, nil, domain.EventTempCredentialRevoked, "Temporary credential revoked", map[string]string{
+		"credential_id": credentialID.String(),
+	})
+	s.logger.Info("Revoked temporary credential",
+		zap.String("plan_id", plan.ID.String()),
+		zap.String("credential_id", credentialID.String()))
+
+	if err := restartPlanInstances(ctx, s.instanceRepo, s.proxyService, s.logger, plan.ID); err != nil {
+		return fmt.Errorf("failed to remove the temporary credential: %w", err)
+	}
+	return nil
+}
+
+// ExpireDue removes the credentials expired at now from their plans and
+// restarts the plans' running instances, returning how many were removed
+func (s *TempCredentialService) ExpireDue(ctx context.Context, now time.Time) (int, error) {
+	plans, err := s.planRepo.GetAll(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get plans: %w", err)
+	}
+
+	expired := 0
+	for _, plan := range plans {
+		active := activeTempCredentials(plan.TempCredentials, now)
+		if len(active) == len(plan.TempCredentials) {
+			continue
+		}
+
+		for _, credential := range plan.TempCredentials {
+			if !credential.Active(now) {
+				s.events.record(ctx, plan.ID, nil, domain.EventTempCredentialExpired, "Temporary credential expired", map[string]string{
+					"credential_id": credential.ID.String(),
+				})
+			}
+		}
+		removed := len(plan.TempCredentials) - len(active)
+		plan.TempCredentials = active
+		plan.UpdatedAt = now
+		if err := s.planRepo.Update(ctx, plan); err != nil {
+			s.logger.Error("Failed to remove expired temporary credentials",
+				zap.String("plan_id", plan.ID.String()),
+				zap.Error(err))
+			continue
+		}
+		expired += removed
+
+		if err := restartPlanInstances(ctx, s.instanceRepo, s.proxyService, s.logger, plan.ID); err != nil {
+			s.logger.Error("Failed to restart instances after temporary credentials expired",
+				zap.String("plan_id", plan.ID.String()),
+				zap.Error(err))
+		}
+	}
+
+	if expired > 0 {
+		s.logger.Info("Expired temporary credentials", zap.Int("count", expired))
+	}
+	return expired, nil
+}
+
+// planUsernames returns every username a plan's instances accept
+func planUsernames(plan *domain.ProxyPlan) map[string]bool {
+	taken := map[string]bool{plan.Username: true}
+	for _, credential := range plan.TempCredentials {
+		taken[credential.Username] = true
+	}
+	for _, subUser := range plan.SubUsers {
+		taken[subUser.Username] = true
+	}
+	return taken
+}
+
+// generatePlanUsername returns a username the plan does not use yet
+func generatePlanUsername(credentials *credentialPolicy, plan *domain.ProxyPlan) (string, error) {
+	taken := planUsernames(plan)
+	for attempt := 0; attempt < usernameAttempts; attempt++ {
+		username, err := credentials.GenerateUsername()
+		if err != nil {
+			return "", err
+		}
+		if !taken[username] {
+			return username, nil
+		}
+	}
+	return "", fmt.Errorf("failed to generate a unique username after %d attempts", usernameAttempts)
+}
+
+// restartPlanInstances restarts a plan's running instances so their 3proxy
+// configs pick up the plan's current users
+func restartPlanInstances(ctx context.Context, instanceRepo repository.InstanceRepository, proxyService ProxyService, logger *zap.Logger, planID uuid.UUID) error {
+	instances, err := instanceRepo.GetByPlanID(ctx, planID)
+	if err != nil {
+		return fmt.Errorf("failed to get plan instances: %w", err)
+	}
+	for _, instance := range instances {
+		if instance.Status != domain.InstanceStatusRunning {
+			continue
+		}
+		if err := restartAutomated(ctx, proxyService, logger, instance, "plan users changed"); err != nil {
+			return fmt.Errorf("failed to restart instance %s: %w", instance.ID, err)
+		}
+	}
+	return nil
+}
+
+// activeTempCredentials returns the credentials still valid at now
+func activeTempCredentials(credentials []domain.TempCredential, now time.Time) []domain.TempCredential {
+	active := make([]domain.TempCredential, 0, len(credentials))
+	for _, credential := range credentials {
+		if credential.Active(now) {
+			active = append(active, credential)
+		}
+	}
+	return active
+}
+
+// tempCredentialEndpoints renders a plan's endpoints for a credential
+func tempCredentialEndpoints(endpoints []domain.ProxyEndpoint, credential *domain.TempCredential) []domain.ProxyEndpoint {
+	rendered := make([]domain.ProxyEndpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		endpoint.Username = credential.Username
+		endpoint.Password = credential.Password
+		if u, err := url.Parse(endpoint.URL); err == nil {
+			u.User = url.UserPassword(credential.Username, credential.Password)
+			endpoint.URL = u.String()
+		}
+		rendered = append(rendered, endpoint)
+	}
+	return rendered
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+//go:embed templates/3proxy.cfg.tmpl
+var default3ProxyTemplate string
+
+// ThreeProxyTemplateData is the data passed to the 3proxy config template
+type ThreeProxyTemplateData struct {
+	InstanceID   string
+	GeneratedAt  string
+	LogDir       string
+	Username     string
+	Password     string
+	LocalPort    int
+	LocalHost    string
+	EgressIP     string
+	UpstreamHost string
+	UpstreamPort int
+	SOCKSPort    int
+	Settings     *domain.ProxySettings
+
+	// Banned source IPs are denied before any other rule
+	Banned []string
+
+	// ExtraUsers, the plan's temporary credentials and sub-users,
+	// authenticate alongside Username and share its rules and upstream
+	ExtraUsers []ThreeProxyUser
+
+	// TeaserPageHost and TeaserPagePort, when set, take plain HTTP requests
+	// to the renewal notice instead of the upstream
+	TeaserPageHost string
+	TeaserPagePort int
+
+	// IdleTimeout, in seconds, closes connections that pass no data for
+	// that long; 0 keeps the 3proxy default
+	IdleTimeout int
+}
+
+// ThreeProxyUser is an extra user of an instance
+type ThreeProxyUser struct {
+	Username string
+	Password string
+}
+
+var threeProxyTemplateFuncs = template.FuncMap{
+	// list renders a 3proxy comma-separated list, using * for "any"
+	"list": func(items []string) string {
+		if len(items) == 0 {
+			return "*"
+		}
+		return strings.Join(items, ",")
+	},
+}
+
+// load3ProxyTemplate parses the on-disk override if present, otherwise the embedded default
+func load3ProxyTemplate(scriptDir string, logger *zap.Logger) *template.Template {
+	overridePath := filepath.Join(scriptDir, "3proxy", "templates", "3proxy.cfg.tmpl")
+
+	if data, err := os.ReadFile(overridePath); err == nil {
+		tmpl, err := template.New("3proxy.cfg").Funcs(threeProxyTemplateFuncs).Parse(string(data))
+		if err == nil {
+			logger.Info("Using 3proxy config template override", zap.String("path", overridePath))
+			return tmpl
+		}
+		logger.Warn("Failed to parse 3proxy template override, using embedded default",
+			zap.String("path", overridePath),
+			zap.Error(err))
+	}
+
+	return template.Must(template.New("3proxy.cfg").Funcs(threeProxyTemplateFuncs).Parse(default3ProxyTemplate))
+}
+
+// restrictedSettings returns a copy of settings whose rules only reach the
+// allowed destinations and deny everything else. Deny rules are kept as they
+// are; allow rules keep their sources and ports but have their targets
+// narrowed to the part of the allowlist they cover, so a plan type rule can
+// never widen it. Allow rules left with no targets are dropped.
+func restrictedSettings(settings *domain.ProxySettings, allowed []string) *domain.ProxySettings {
+	restricted := domain.ProxySettings{}
+	if settings != nil {
+		restricted = *settings
+	}
+
+	rules := make([]domain.ACLRule, 0, len(restricted.Rules)+2)
+	for _, rule := range restricted.Rules {
+		if rule.Action == "allow" {
+			if rule.Targets = intersectTargets(rule.Targets, allowed); len(rule.Targets) == 0 {
+				continue
+			}
+		}
+		rules = append(rules, rule)
+	}
+	if len(restricted.Rules) == 0 {
+		rules = append(rules, domain.ACLRule{Action: "allow", Targets: allowed})
+	}
+	restricted.Rules = append(rules, domain.ACLRule{Action: "deny"})
+
+	return &restricted
+}
+
+// intersectTargets returns the destinations reachable through both target
+// lists, keeping the narrower of each pair where one covers the other. An
+// empty targets list means any destination, so the allowlist is returned.
+func intersectTargets(targets, allowed []string) []string {
+	if len(targets) == 0 {
+		return allowed
+	}
+
+	var both []string
+	seen := make(map[string]bool)
+	add := func(target string) {
+		if !seen[target] {
+			seen[target] = true
+			both = append(both, target)
+		}
+	}
+	for _, target := range targets {
+		for _, allow := range allowed {
+			switch {
+			case targetCovers(allow, target):
+				add(target)
+			case targetCovers(target, allow):
+				add(allow)
+			}
+		}
+	}
+	return both
+}
+
+// targetCovers reports whether every destination matched by inner is also
+// matched by outer. Targets are host names, *.domain wildcards, IPs and CIDR
+// networks, or * for any.
+func targetCovers(outer, inner string) bool {
+	outer, inner = strings.ToLower(outer), strings.ToLower(inner)
+	switch {
+	case outer == "*" || outer == inner:
+		return true
+	case strings.HasPrefix(outer, "*."):
+		return strings.HasSuffix(inner, outer[1:])
+	}
+
+	_, outerNet, err := net.ParseCIDR(outer)
+	if err != nil {
+		return false
+	}
+	if ip := net.ParseIP(inner); ip != nil {
+		return outerNet.Contains(ip)
+	}
+	_, innerNet, err := net.ParseCIDR(inner)
+	if err != nil {
+		return false
+	}
+	outerBits, _ := outerNet.Mask.Size()
+	innerBits, _ := innerNet.Mask.Size()
+	return outerBits <= innerBits && outerNet.Contains(innerNet.IP)
+}
+
+// suspendedSettings returns a copy of settings that denies every request
+func suspendedSettings(settings *domain.ProxySettings) *domain.ProxySettings {
+	suspended := domain.ProxySettings{}
+	if settings != nil {
+		suspended = *settings
+	}
+	suspended.Rules = []domain.ACLRule{{Action: "deny"}}
+	return &suspended
+}
+
+// render3ProxyConfig executes the template for a single instance
+func render3ProxyConfig(tmpl *template.Template, data *ThreeProxyTemplateData) ([]byte, error) {
+	if data.Settings != nil {
+		for _, rule := range data.Settings.Rules {
+			if rule.Action != "allow" && rule.Action != "deny" {
+				return nil, fmt.Errorf("invalid ACL action %q", rule.Action)
+			}
+		}
+		if data.Settings.SOCKS != nil && data.Settings.SOCKS.Enabled {
+			if data.Settings.SOCKS.PortOffset == 0 {
+				return nil, fmt.Errorf("socks port_offset must be non-zero")
+			}
+			data.SOCKSPort = data.LocalPort + data.Settings.SOCKS.PortOffset
+		}
+	}
+	if data.GeneratedAt == "" {
+		data.GeneratedAt = time.Now().Format(time.RFC3339)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute 3proxy template: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+// BenchmarkRender3ProxyConfig renders an instance config with a typical
+// plan type's settings and a plan's temporary credentials and sub-users
+func BenchmarkRender3ProxyConfig(b *testing.B) {
+	tmpl := load3ProxyTemplate(b.TempDir(), zap.NewNop())
+
+	extraUsers := make([]ThreeProxyUser, 0, 10)
+	for i := 0; i < cap(extraUsers); i++ {
+		extraUsers = append(extraUsers, ThreeProxyUser{Username: fmt.Sprintf("sub%02d", i), Password: "benchmark-password"})
+	}
+	settings := &domain.ProxySettings{
+		MaxConn:    200,
+		BandLimIn:  100000000,
+		BandLimOut: 100000000,
+		NSCache:    65536,
+		NServers:   []string{"1.1.1.1", "8.8.8.8"},
+		Rules: []domain.ACLRule{
+			{Action: "deny", Targets: []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}},
+			{Action: "allow", Ports: []string{"80", "443"}},
+		},
+		SOCKS: &domain.SOCKSBlock{Enabled: true, PortOffset: 1000},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data := &ThreeProxyTemplateData{
+			InstanceID:   "bench",
+			GeneratedAt:  "2026-01-01T00:00:00Z",
+			LogDir:       "/var/log/oceanproxy",
+			Username:     "user000001",
+			Password:     "benchmark-password",
+			LocalPort:    10000,
+			LocalHost:    "0.0.0.0",
+			UpstreamHost: "upstream.example.com",
+			UpstreamPort: 8080,
+			Settings:     settings,
+			Banned:       []string{"203.0.113.7", "203.0.113.8"},
+			ExtraUsers:   extraUsers,
+		}
+		if _, err := render3ProxyConfig(tmpl, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
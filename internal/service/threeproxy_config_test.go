@@ -0,0 +1,134 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/je265/oceanproxy/internal/domain"
+)
+
+func TestTargetCovers(t *testing.T) {
+	tests := []struct {
+		name         string
+		outer, inner string
+		want         bool
+	}{
+		{"any covers host", "*", "example.com", true},
+		{"any covers network", "*", "10.0.0.0/8", true},
+		{"same host", "example.com", "example.com", true},
+		{"host case", "Example.com", "example.COM", true},
+		{"other host", "example.com", "example.org", false},
+		{"host does not cover any", "example.com", "*", false},
+		{"wildcard covers subdomain", "*.example.com", "api.example.com", true},
+		{"wildcard covers nested wildcard", "*.example.com", "*.api.example.com", true},
+		{"wildcard excludes apex", "*.example.com", "example.com", false},
+		{"wildcard excludes lookalike", "*.example.com", "badexample.com", false},
+		{"subdomain does not cover wildcard", "api.example.com", "*.example.com", false},
+		{"network covers ip", "10.0.0.0/8", "10.1.2.3", true},
+		{"network excludes ip", "10.0.0.0/8", "192.168.1.1", false},
+		{"network covers subnet", "10.0.0.0/8", "10.1.0.0/16", true},
+		{"subnet does not cover network", "10.1.0.0/16", "10.0.0.0/8", false},
+		{"overlapping networks", "10.0.0.0/15", "10.1.0.0/16", true},
+		{"disjoint networks", "10.0.0.0/16", "10.1.0.0/16", false},
+		{"ipv6 network covers ip", "2001:db8::/32", "2001:db8::1", true},
+		{"ipv6 network excludes ip", "2001:db8::/32", "2001:db9::1", false},
+		{"network does not cover host", "10.0.0.0/8", "example.com", false},
+		{"ip does not cover network", "10.1.2.3", "10.0.0.0/8", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := targetCovers(tc.outer, tc.inner); got != tc.want {
+				t.Errorf("targetCovers(%q, %q) = %v, want %v", tc.outer, tc.inner, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIntersectTargets(t *testing.T) {
+	tests := []struct {
+		name             string
+		targets, allowed []string
+		want             []string
+	}{
+		{"no targets means the allowlist", nil, []string{"10.0.0.0/8", "example.com"}, []string{"10.0.0.0/8", "example.com"}},
+		{"both empty", nil, nil, nil},
+		{"empty allowlist", []string{"10.0.0.0/8"}, nil, nil},
+		{"any target", []string{"*"}, []string{"10.0.0.0/8"}, []string{"10.0.0.0/8"}},
+		{"narrower target kept", []string{"10.1.0.0/16"}, []string{"10.0.0.0/8"}, []string{"10.1.0.0/16"}},
+		{"narrower allowlist kept", []string{"10.0.0.0/8"}, []string{"10.1.0.0/16", "10.2.0.0/16"}, []string{"10.1.0.0/16", "10.2.0.0/16"}},
+		{"disjoint networks", []string{"192.168.0.0/16"}, []string{"10.0.0.0/8"}, nil},
+		{"partly disjoint", []string{"192.168.0.0/16", "10.1.2.3"}, []string{"10.0.0.0/8"}, []string{"10.1.2.3"}},
+		{"wildcard target", []string{"*.example.com"}, []string{"api.example.com", "example.org"}, []string{"api.example.com"}},
+		{"wildcard allowlist", []string{"api.example.com", "example.com"}, []string{"*.example.com"}, []string{"api.example.com"}},
+		{"duplicates dropped", []string{"10.1.2.3", "10.1.0.0/16"}, []string{"10.1.2.3"}, []string{"10.1.2.3"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := intersectTargets(tc.targets, tc.allowed)
+			if len(got) != len(tc.want) || (len(got) > 0 && !reflect.DeepEqual(got, tc.want)) {
+				t.Errorf("intersectTargets(%v, %v) = %v, want %v", tc.targets, tc.allowed, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRestrictedSettings(t *testing.T) {
+	allowed := []string{"10.1.0.0/16"}
+	deny := domain.ACLRule{Action: "deny"}
+
+	tests := []struct {
+		name     string
+		settings *domain.ProxySettings
+		want     []domain.ACLRule
+	}{
+		{
+			name:     "no settings",
+			settings: nil,
+			want:     []domain.ACLRule{{Action: "allow", Targets: allowed}, deny},
+		},
+		{
+			name:     "no rules",
+			settings: &domain.ProxySettings{},
+			want:     []domain.ACLRule{{Action: "allow", Targets: allowed}, deny},
+		},
+		{
+			name: "port ranges kept on narrowed rule",
+			settings: &domain.ProxySettings{Rules: []domain.ACLRule{
+				{Action: "allow", Sources: []string{"203.0.113.0/24"}, Targets: []string{"10.0.0.0/8"}, Ports: []string{"80", "8000-9000"}},
+			}},
+			want: []domain.ACLRule{
+				{Action: "allow", Sources: []string{"203.0.113.0/24"}, Targets: allowed, Ports: []string{"80", "8000-9000"}},
+				deny,
+			},
+		},
+		{
+			name: "disjoint allow rule dropped",
+			settings: &domain.ProxySettings{Rules: []domain.ACLRule{
+				{Action: "allow", Targets: []string{"192.168.0.0/16"}, Ports: []string{"443"}},
+				{Action: "deny", Targets: []string{"10.1.2.3"}, Ports: []string{"25"}},
+			}},
+			want: []domain.ACLRule{
+				{Action: "deny", Targets: []string{"10.1.2.3"}, Ports: []string{"25"}},
+				deny,
+			},
+		},
+		{
+			name: "allow rule without targets gets the allowlist",
+			settings: &domain.ProxySettings{Rules: []domain.ACLRule{
+				{Action: "allow", Ports: []string{"1-1024"}},
+			}},
+			want: []domain.ACLRule{{Action: "allow", Targets: allowed, Ports: []string{"1-1024"}}, deny},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := restrictedSettings(tc.settings, allowed)
+			if !reflect.DeepEqual(got.Rules, tc.want) {
+				t.Errorf("rules = %+v, want %+v", got.Rules, tc.want)
+			}
+		})
+	}
+}
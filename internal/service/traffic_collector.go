@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+	"github.com/je265/oceanproxy/pkg/config"
+	"github.com/je265/oceanproxy/pkg/geoip"
+)
+
+const (
+	// destinationCacheTTL is how long a resolved destination country is kept
+	destinationCacheTTL = time.Hour
+
+	// destinationCacheSize bounds the destination cache; it is cleared when full
+	destinationCacheSize = 10000
+
+	// destinationLookupTimeout bounds resolving one destination host
+	destinationLookupTimeout = 2 * time.Second
+)
+
+// destinationCountry is a cached destination host country
+type destinationCountry struct {
+	country string
+	expires time.Time
+}
+
+// TrafficCollector reads the requests logged by every running 3proxy
+// instance and records their traffic in the stats. With a GeoIP database
+// configured, each request is also attributed to the country of the client
+// and of the destination host, which is resolved when it is a name. Clients
+// relayed by nginx reach 3proxy from loopback and are counted as unknown.
+type TrafficCollector struct {
+	cfg          config.Stats
+	logDir       string
+	logger       *zap.Logger
+	instanceRepo repository.InstanceRepository
+	statsRepo    repository.StatsRepository
+	latency      *UpstreamLatency
+	resolver     *net.Resolver
+
+	mu           sync.Mutex
+	tailer       *logTailer
+	primed       bool
+	geo          *geoip.Reader
+	geoModTime   time.Time
+	destinations map[string]destinationCountry
+}
+
+// NewTrafficCollector creates a new traffic collector; it only runs when
+// stats.collect_interval is set
+func NewTrafficCollector(
+	cfg *config.Config,
+	logger *zap.Logger,
+	instanceRepo repository.InstanceRepository,
+	statsRepo repository.StatsRepository,
+	latency *UpstreamLatency,
+) *TrafficCollector {
+	return &TrafficCollector{
+		cfg:          cfg.Stats,
+		logDir:       cfg.Proxy.LogDir,
+		logger:       logger,
+		instanceRepo: instanceRepo,
+		statsRepo:    statsRepo,
+		latency:      latency,
+		resolver:     net.DefaultResolver,
+		tailer:       newLogTailer(),
+		destinations: make(map[string]destinationCountry),
+	}
+}
+
+// Run collects traffic every collect interval until ctx is cancelled
+func (c *TrafficCollector) Run(ctx context.Context) {
+	if c == nil || c.cfg.CollectInterval <= 0 {
+		return
+	}
+
+	c.logger.Info("Starting traffic collection",
+		zap.Duration("interval", c.cfg.CollectInterval),
+		zap.String("geoip_database", c.cfg.GeoIPDatabase),
+	)
+
+	ticker := time.NewTicker(c.cfg.CollectInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Collect(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			c.logger.Error("Failed to collect traffic", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect records the requests logged since the last collection. On the
+// first collection existing logs are read from their end, so traffic from
+// before a restart is not counted twice; logs of instances started later are
+// read from their start.
+func (c *TrafficCollector) Collect(ctx context.Context, now time.Time) error {
+	instances, err := c.instanceRepo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get instances: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reloadGeoIP()
+
+	var samples []repository.TrafficSample
+	for _, instance := range instances {
+		if instance.Status != domain.InstanceStatusRunning {
+			continue
+		}
+		path := proxyLogPath(c.logDir, instance.ID)
+		err := c.tailer.read(path, c.primed, func(line string) {
+			entry, ok := parseProxyLogLine(line)
+			if !ok || entry.authFailed {
+				return
+			}
+			c.latency.ObserveRequest(instance.PlanTypeKey, entry)
+
+			sample := repository.TrafficSample{
+				PlanID:     instance.PlanID,
+				InstanceID: instance.ID,
+				Time:       entry.time,
+				BytesIn:    entry.bytesIn,
+				BytesOut:   entry.bytesOut,
+				Reaped:     entry.reaped,
+			}
+			if sample.Time.IsZero() {
+				sample.Time = now
+			}
+			if c.geo != nil {
+				sample.ClientCountry = c.ipCountry(net.ParseIP(entry.clientIP))
+				sample.DestinationCountry = c.hostCountry(ctx, requestHost(entry.request), now)
+			}
+			samples = append(samples, sample)
+		})
+		if err != nil && !os.IsNotExist(err) {
+			c.logger.Debug("Failed to read proxy log", zap.String("path", path), zap.Error(err))
+		}
+	}
+	c.primed = true
+
+	if err := c.statsRepo.RecordTraffic(ctx, samples); err != nil {
+		return fmt.Errorf("failed to record traffic: %w", err)
+	}
+	return nil
+}
+
+// reloadGeoIP opens the GeoIP database when it first appears or changes.
+// A database that fails to load is logged and the previous one kept.
+func (c *TrafficCollector) reloadGeoIP() {
+	if c.cfg.GeoIPDatabase == "" {
+		return
+	}
+
+	info, err := os.Stat(c.cfg.GeoIPDatabase)
+	if err != nil {
+		if c.geo == nil {
+			c.logger.Warn("GeoIP database unavailable; traffic is not broken down by country",
+				zap.String("path", c.cfg.GeoIPDatabase), zap.Error(err))
+		}
+		return
+	}
+	if c.geo != nil && info.ModTime().Equal(c.geoModTime) {
+		return
+	}
+
+	geo, err := geoip.Open(c.cfg.GeoIPDatabase)
+	if err != nil {
+		c.logger.Error("Failed to load GeoIP database", zap.Error(err))
+		return
+	}
+	c.geo = geo
+	c.geoModTime = info.ModTime()
+	// Countries resolved from the previous database may have changed
+	c.destinations = make(map[string]destinationCountry)
+
+	metadata := geo.Metadata()
+	c.logger.Info("Loaded GeoIP database",
+		zap.String("path", c.cfg.GeoIPDatabase),
+		zap.String("type", metadata.DatabaseType),
+		zap.Time("built", time.Unix(int64(metadata.BuildEpoch), 0)),
+	)
+}
+
+// ipCountry returns the country of ip, or UnknownCountry
+func (c *TrafficCollector) ipCountry(ip net.IP) string {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() {
+		return repository.UnknownCountry
+	}
+
+	country, err := c.geo.Country(ip)
+	if err != nil || country == "" {
+		return repository.UnknownCountry
+	}
+	return country
+}
+
+// hostCountry returns the country of a destination host, resolving names
+// through a cache
+func (c *TrafficCollector) hostCountry(ctx context.Context, host string, now time.Time) string {
+	if host == "" {
+		return repository.UnknownCountry
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return c.ipCountry(ip)
+	}
+
+	if cached, ok := c.destinations[host]; ok && now.Before(cached.expires) {
+		return cached.country
+	}
+
+	country := repository.UnknownCountry
+	lookupCtx, cancel := context.WithTimeout(ctx, destinationLookupTimeout)
+	ips, err := c.resolver.LookupIP(lookupCtx, "ip", host)
+	cancel()
+	if err == nil && len(ips) > 0 {
+		country = c.ipCountry(ips[0])
+	}
+
+	if len(c.destinations) >= destinationCacheSize {
+		c.destinations = make(map[string]destinationCountry)
+	}
+	c.destinations[host] = destinationCountry{country: country, expires: now.Add(destinationCacheTTL)}
+	return country
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// latencyKey labels an upstream latency histogram
+type latencyKey struct {
+	provider string
+	planType string
+}
+
+// latencyHistogram is a cumulative Prometheus histogram
+type latencyHistogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *latencyHistogram) observe(buckets []float64, seconds float64) {
+	for i, bound := range buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// UpstreamLatency samples upstream latency per provider and plan type into
+// Prometheus histograms, so slow upstream pools show up in the operator
+// metrics. Connect latency comes from the upstream prober's TCP connects.
+// Request latency comes from the 3proxy logs. 3proxy only logs how long a
+// whole request took, so only successful plain HTTP requests are observed:
+// their duration covers the upstream connect, time to first byte and the
+// transfer. CONNECT tunnels last as long as the client keeps them open and
+// are skipped.
+type UpstreamLatency struct {
+	sampleRate float64
+	buckets    []float64
+	planTypes  *PlanTypeRegistry
+	random     func() float64
+
+	mu       sync.Mutex
+	connect  map[latencyKey]*latencyHistogram
+	requests map[latencyKey]*latencyHistogram
+}
+
+// NewUpstreamLatency creates a new upstream latency sampler
+func NewUpstreamLatency(cfg *config.Config, planTypes *PlanTypeRegistry) *UpstreamLatency {
+	return &UpstreamLatency{
+		sampleRate: cfg.Metrics.LatencySampleRate,
+		buckets:    cfg.Metrics.LatencyBuckets,
+		planTypes:  planTypes,
+		random:     rand.Float64,
+		connect:    make(map[latencyKey]*latencyHistogram),
+		requests:   make(map[latencyKey]*latencyHistogram),
+	}
+}
+
+// ObserveConnect records a successful probe of an upstream address for
+// every plan type that may use it
+func (l *UpstreamLatency) ObserveConnect(address string, rtt time.Duration) {
+	if l == nil {
+		return
+	}
+
+	var keys []latencyKey
+	for key, planType := range l.planTypes.All() {
+		for _, host := range upstreamCandidates(planType) {
+			if net.JoinHostPort(host, strconv.Itoa(planType.UpstreamPort)) == address {
+				keys = append(keys, latencyKey{provider: planType.Provider, planType: key})
+				break
+			}
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		l.histogram(l.connect, key).observe(l.buckets, rtt.Seconds())
+	}
+}
+
+// ObserveRequest samples the duration of a request logged by an instance
+// of the plan type
+func (l *UpstreamLatency) ObserveRequest(planTypeKey string, entry proxyLogEntry) {
+	if l == nil || l.sampleRate <= 0 {
+		return
+	}
+	if entry.code != 0 || entry.durationMs <= 0 || requestMethod(entry.request) == "CONNECT" {
+		return
+	}
+	if l.sampleRate < 1 && l.random() >= l.sampleRate {
+		return
+	}
+	planType := l.planTypes.Get(planTypeKey)
+	if planType == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := latencyKey{provider: planType.Provider, planType: planTypeKey}
+	l.histogram(l.requests, key).observe(l.buckets, float64(entry.durationMs)/1000)
+}
+
+func (l *UpstreamLatency) histogram(histograms map[latencyKey]*latencyHistogram, key latencyKey) *latencyHistogram {
+	h, exists := histograms[key]
+	if !exists {
+		h = &latencyHistogram{counts: make([]uint64, len(l.buckets))}
+		histograms[key] = h
+	}
+	return h
+}
+
+// render writes the latency histograms to b
+func (l *UpstreamLatency) render(b *strings.Builder) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	writeHistogramFamily(b, "oceanproxy_upstream_connect_seconds",
+		"TCP connect time to upstream hosts measured by the prober, by provider and plan type",
+		l.samples("oceanproxy_upstream_connect_seconds", l.connect))
+	writeHistogramFamily(b, "oceanproxy_upstream_request_seconds",
+		"Duration of sampled successful plain HTTP requests through the upstream, by provider and plan type",
+		l.samples("oceanproxy_upstream_request_seconds", l.requests))
+}
+
+// samples returns the bucket, sum and count samples of the histograms,
+// ordered by provider and plan type
+func (l *UpstreamLatency) samples(name string, histograms map[latencyKey]*latencyHistogram) []string {
+	keys := make([]latencyKey, 0, len(histograms))
+	for key := range histograms {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].provider != keys[j].provider {
+			return keys[i].provider < keys[j].provider
+		}
+		return keys[i].planType < keys[j].planType
+	})
+
+	var samples []string
+	for _, key := range keys {
+		h := histograms[key]
+		labels := fmt.Sprintf(`provider="%s",plan_type="%s"`, escapeLabel(key.provider), escapeLabel(key.planType))
+		for i, bound := range l.buckets {
+			samples = append(samples, fmt.Sprintf(`%s_bucket{%s,le="%s"} %d`, name, labels, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i]))
+		}
+		samples = append(samples,
+			fmt.Sprintf(`%s_bucket{%s,le="+Inf"} %d`, name, labels, h.count),
+			fmt.Sprintf(`%s_sum{%s} %s`, name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64)),
+			fmt.Sprintf(`%s_count{%s} %d`, name, labels, h.count))
+	}
+	return samples
+}
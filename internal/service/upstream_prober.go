@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// rttSmoothing weights the newest probe in the moving RTT average
+const rttSmoothing = 0.3
+
+// UpstreamStats is the probe state of one upstream host:port
+type UpstreamStats struct {
+	Address      string        `json:"address"`
+	RTT          time.Duration `json:"rtt"`
+	LastRTT      time.Duration `json:"last_rtt"`
+	Failures     int           `json:"consecutive_failures"`
+	LastError    string        `json:"last_error,omitempty"`
+	LastProbedAt time.Time     `json:"last_probed_at"`
+}
+
+// Healthy reports whether the last probe succeeded
+func (s *UpstreamStats) Healthy() bool {
+	return !s.LastProbedAt.IsZero() && s.Failures == 0
+}
+
+// UpstreamProber measures TCP connect RTT to the upstream hosts of plan types
+// using latency-based selection, and picks the best host for an instance
+type UpstreamProber struct {
+	logger    *zap.Logger
+	interval  time.Duration
+	timeout   time.Duration
+	planTypes *PlanTypeRegistry
+	latency   *UpstreamLatency
+
+	mu    sync.RWMutex
+	stats map[string]*UpstreamStats
+}
+
+// NewUpstreamProber creates a prober for every latency-selected plan type
+func NewUpstreamProber(cfg *config.Config, logger *zap.Logger, planTypes *PlanTypeRegistry, latency *UpstreamLatency) *UpstreamProber {
+	return &UpstreamProber{
+		logger:    logger,
+		interval:  cfg.Proxy.UpstreamProbeInterval,
+		timeout:   cfg.Proxy.UpstreamProbeTimeout,
+		planTypes: planTypes,
+		latency:   latency,
+		stats:     make(map[string]*UpstreamStats),
+	}
+}
+
+// targets returns the upstream addresses of the latency-selected plan types,
+// sorted. Plan types are read each time so ones added at runtime are probed.
+func (p *UpstreamProber) targets() []string {
+	seen := make(map[string]bool)
+	var targets []string
+	for _, planType := range p.planTypes.All() {
+		for _, host := range upstreamCandidates(planType) {
+			address := net.JoinHostPort(host, strconv.Itoa(planType.UpstreamPort))
+			if !seen[address] {
+				seen[address] = true
+				targets = append(targets, address)
+			}
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// upstreamCandidates returns the hosts a latency-selected plan type may use
+func upstreamCandidates(planType *domain.PlanTypeConfig) []string {
+	selection := planType.UpstreamSelection
+	if selection == nil || selection.Strategy != domain.UpstreamStrategyLatency {
+		return nil
+	}
+
+	hosts := selection.Hosts
+	if planType.UpstreamHost != "" && !containsString(hosts, planType.UpstreamHost) {
+		hosts = append([]string{planType.UpstreamHost}, hosts...)
+	}
+	return hosts
+}
+
+// Run probes all targets every interval until ctx is cancelled
+func (p *UpstreamProber) Run(ctx context.Context) {
+	if p.interval <= 0 {
+		return
+	}
+
+	p.logger.Info("Starting upstream latency probing",
+		zap.Int("targets", len(p.targets())),
+		zap.Duration("interval", p.interval),
+	)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.probeAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *UpstreamProber) probeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, address := range p.targets() {
+		wg.Add(1)
+		go func(address string) {
+			defer wg.Done()
+			p.probe(ctx, address)
+		}(address)
+	}
+	wg.Wait()
+}
+
+func (p *UpstreamProber) probe(ctx context.Context, address string) {
+	dialer := net.Dialer{Timeout: p.timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	rtt := time.Since(start)
+	if err == nil {
+		conn.Close()
+		p.latency.ObserveConnect(address, rtt)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats, exists := p.stats[address]
+	if !exists {
+		stats = &UpstreamStats{Address: address}
+		p.stats[address] = stats
+	}
+	stats.LastProbedAt = time.Now()
+
+	if err != nil {
+		stats.Failures++
+		stats.LastError = err.Error()
+		if stats.Failures == 1 {
+			p.logger.Warn("Upstream probe failed", zap.String("address", address), zap.Error(err))
+		}
+		return
+	}
+
+	if stats.Failures > 0 {
+		p.logger.Info("Upstream recovered", zap.String("address", address), zap.Duration("rtt", rtt))
+	}
+	stats.Failures = 0
+	stats.LastError = ""
+	stats.LastRTT = rtt
+	if stats.RTT == 0 {
+		stats.RTT = rtt
+	} else {
+		stats.RTT = time.Duration((1-rttSmoothing)*float64(stats.RTT) + rttSmoothing*float64(rtt))
+	}
+}
+
+// Select returns the healthy candidate host with the lowest RTT for the plan
+// type, or current when the plan type doesn't use latency selection or no
+// candidate has been probed healthy yet
+func (p *UpstreamProber) Select(planType *domain.PlanTypeConfig, current string) string {
+	if p == nil || planType == nil {
+		return current
+	}
+
+	candidates := upstreamCandidates(planType)
+	if len(candidates) == 0 {
+		return current
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	best, bestRTT := current, time.Duration(0)
+	for _, host := range candidates {
+		stats, exists := p.stats[net.JoinHostPort(host, strconv.Itoa(planType.UpstreamPort))]
+		if !exists || !stats.Healthy() {
+			continue
+		}
+		if bestRTT == 0 || stats.RTT < bestRTT {
+			best, bestRTT = host, stats.RTT
+		}
+	}
+
+	return best
+}
+
+// Stats returns the probe state of every target, ordered by address
+func (p *UpstreamProber) Stats() []UpstreamStats {
+	targets := p.targets()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stats := make([]UpstreamStats, 0, len(targets))
+	for _, address := range targets {
+		if s, exists := p.stats[address]; exists {
+			stats = append(stats, *s)
+		} else {
+			stats = append(stats, UpstreamStats{Address: address})
+		}
+	}
+	return stats
+}
+
+func containsString(values []string, v string) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
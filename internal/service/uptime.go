@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+// outagePeriod is a span in which an instance failed its health checks
+type outagePeriod struct {
+	start time.Time
+	end   time.Time
+}
+
+// uptime sums the monitored and unhealthy time of one or more instances
+// over a period
+type uptime struct {
+	exposure time.Duration
+	downtime time.Duration
+	periods  []outagePeriod
+}
+
+// measureUptime returns an instance's uptime between from and to, given its
+// outage periods. Stopped instances serve nobody, so only their outages
+// count.
+func measureUptime(instance *domain.ProxyInstance, periods []outagePeriod, from, to time.Time) uptime {
+	var u uptime
+	if instance.Status == domain.InstanceStatusRunning || instance.Status == domain.InstanceStatusFailed {
+		start := instance.CreatedAt
+		if start.Before(from) {
+			start = from
+		}
+		if start.Before(to) {
+			u.exposure = to.Sub(start)
+		}
+	}
+
+	for _, period := range periods {
+		if period.start.Before(from) {
+			period.start = from
+		}
+		if period.end.After(to) {
+			period.end = to
+		}
+		if !period.end.After(period.start) {
+			continue
+		}
+		u.downtime += period.end.Sub(period.start)
+		u.periods = append(u.periods, period)
+	}
+	return u
+}
+
+// add adds another instance's uptime
+func (u *uptime) add(other uptime) {
+	u.exposure += other.exposure
+	u.downtime += other.downtime
+	u.periods = append(u.periods, other.periods...)
+}
+
+// percent returns the share of monitored time spent healthy, 100 when
+// nothing was monitored
+func (u *uptime) percent() float64 {
+	if u.exposure <= 0 {
+		return 100
+	}
+	percent := 100 * (1 - float64(u.downtime)/float64(u.exposure))
+	return math.Max(0, math.Round(percent*1000)/1000)
+}
+
+// instanceOutages returns each instance's unhealthy periods, oldest first,
+// from the instance_unhealthy and instance_recovered events the health
+// monitor records. A period still open ends at now while the instance is
+// failed; if the instance was restarted since, it ends at the instance's
+// last update. Instances not passed in are left out.
+func instanceOutages(ctx context.Context, eventRepo repository.PlanEventRepository, instances []*domain.ProxyInstance, now time.Time) (map[uuid.UUID][]outagePeriod, error) {
+	byID := make(map[uuid.UUID]*domain.ProxyInstance, len(instances))
+	plans := make(map[uuid.UUID]bool)
+	for _, instance := range instances {
+		byID[instance.ID] = instance
+		plans[instance.PlanID] = true
+	}
+
+	outages := make(map[uuid.UUID][]outagePeriod)
+	open := make(map[uuid.UUID]time.Time)
+	for planID := range plans {
+		events, err := eventRepo.GetByPlanID(ctx, planID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get plan events: %w", err)
+		}
+		for _, event := range events {
+			if event.InstanceID == nil || byID[*event.InstanceID] == nil {
+				continue
+			}
+			id := *event.InstanceID
+			switch event.Type {
+			case domain.EventInstanceUnhealthy:
+				if _, down := open[id]; !down {
+					open[id] = event.CreatedAt
+				}
+			case domain.EventInstanceRecovered:
+				if start, down := open[id]; down {
+					outages[id] = append(outages[id], outagePeriod{start: start, end: event.CreatedAt})
+					delete(open, id)
+				}
+			}
+		}
+	}
+
+	for id, start := range open {
+		end := now
+		if instance := byID[id]; instance.Status != domain.InstanceStatusFailed && instance.UpdatedAt.After(start) {
+			end = instance.UpdatedAt
+		}
+		outages[id] = append(outages[id], outagePeriod{start: start, end: end})
+	}
+
+	return outages, nil
+}
+
+// mergeOutagePeriods merges overlapping periods, sorted by start
+func mergeOutagePeriods(periods []outagePeriod) []outagePeriod {
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].start.Before(periods[j].start)
+	})
+
+	var merged []outagePeriod
+	for _, period := range periods {
+		if n := len(merged); n > 0 && !period.start.After(merged[n-1].end) {
+			if period.end.After(merged[n-1].end) {
+				merged[n-1].end = period.end
+			}
+			continue
+		}
+		merged = append(merged, period)
+	}
+	return merged
+}
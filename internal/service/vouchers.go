@@ -0,0 +1,366 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/internal/domain"
+	"github.com/je265/oceanproxy/internal/repository"
+)
+
+var (
+	// ErrVoucherNotFound is returned for a voucher code that does not exist
+	ErrVoucherNotFound = errors.New("voucher not found")
+	// ErrVoucherExists is returned when generating a voucher whose code is taken
+	ErrVoucherExists = errors.New("voucher already exists")
+	// ErrInvalidVoucher is returned for a voucher request that fails validation
+	ErrInvalidVoucher = errors.New("invalid voucher")
+)
+
+const (
+	// maxVoucherBatch bounds how many vouchers one request generates
+	maxVoucherBatch = 1000
+
+	// defaultVoucherPrefix starts generated codes without a prefix given
+	defaultVoucherPrefix = "OCEAN"
+
+	// voucherCodeAlphabet leaves out characters easily misread, like 0/O and 1/I
+	voucherCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+var voucherCodePattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9-]{2,63}$`)
+
+// VoucherService generates vouchers and redeems them when plans are
+// created. Redemptions are checked and counted under one lock, so a
+// single-use voucher is never redeemed twice.
+type VoucherService struct {
+	logger *zap.Logger
+	repo   repository.VoucherRepository
+
+	mu sync.Mutex
+}
+
+// NewVoucherService creates a new voucher service
+func NewVoucherService(logger *zap.Logger, repo repository.VoucherRepository) *VoucherService {
+	return &VoucherService{
+		logger: logger,
+		repo:   repo,
+	}
+}
+
+// Generate creates req.Count vouchers with the request's terms
+func (s *VoucherService) Generate(ctx context.Context, req *domain.GenerateVouchersRequest, now time.Time) (*domain.GenerateVouchersResponse, error) {
+	if err := validateVoucherRequest(req, now); err != nil {
+		return nil, err
+	}
+
+	count := req.Count
+	if count == 0 {
+		count = 1
+	}
+	maxRedemptions := req.MaxRedemptions
+	if req.SingleUse {
+		maxRedemptions = 1
+	}
+	prefix := strings.ToUpper(strings.TrimSpace(req.Prefix))
+	if prefix == "" {
+		prefix = defaultVoucherPrefix
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := uuid.New().String()
+	vouchers := make([]*domain.Voucher, 0, count)
+	codes := make(map[string]bool, count)
+	for len(vouchers) < count {
+		code := domain.NormalizeVoucherCode(req.Code)
+		if code == "" {
+			var err error
+			if code, err = generateVoucherCode(prefix); err != nil {
+				return nil, err
+			}
+		}
+
+		existing, err := s.repo.GetByCode(ctx, code)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil || codes[code] {
+			if req.Code != "" {
+				return nil, fmt.Errorf("%w: %s", ErrVoucherExists, code)
+			}
+			continue
+		}
+		codes[code] = true
+
+		voucher := &domain.Voucher{
+			Code:           code,
+			Kind:           req.Kind,
+			PlanTypes:      req.PlanTypes,
+			MinBandwidth:   req.MinBandwidth,
+			MaxBandwidth:   req.MaxBandwidth,
+			CustomerID:     req.CustomerID,
+			MaxRedemptions: maxRedemptions,
+			ExpiresAt:      req.ExpiresAt,
+			Batch:          batch,
+			Note:           req.Note,
+			CreatedAt:      now.UTC(),
+		}
+		if req.Kind == domain.VoucherKindTrial {
+			voucher.TrialBandwidth, voucher.TrialDuration = req.TrialBandwidth, req.TrialDuration
+		} else {
+			voucher.DiscountPercent = req.DiscountPercent
+		}
+		vouchers = append(vouchers, voucher)
+	}
+
+	if err := s.repo.Create(ctx, vouchers); err != nil {
+		return nil, fmt.Errorf("failed to store vouchers: %w", err)
+	}
+
+	s.logger.Info("Generated vouchers",
+		zap.String("batch", batch),
+		zap.String("kind", req.Kind),
+		zap.Int("count", len(vouchers)))
+	return &domain.GenerateVouchersResponse{Batch: batch, Vouchers: vouchers}, nil
+}
+
+// validateVoucherRequest checks a generate request's kind, terms and
+// constraints
+func validateVoucherRequest(req *domain.GenerateVouchersRequest, now time.Time) error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidVoucher, fmt.Sprintf(format, args...))
+	}
+
+	if req.Count < 0 || req.Count > maxVoucherBatch {
+		return invalid("count must be between 1 and %d", maxVoucherBatch)
+	}
+	if req.Code != "" {
+		if req.Count > 1 {
+			return invalid("code can only be set for a single voucher")
+		}
+		if !voucherCodePattern.MatchString(domain.NormalizeVoucherCode(req.Code)) {
+			return invalid("code must be 3 to 64 letters, digits and -")
+		}
+	}
+	if req.Prefix != "" && !voucherCodePattern.MatchString(strings.ToUpper(strings.TrimSpace(req.Prefix))) {
+		return invalid("prefix must be 3 to 64 letters, digits and -")
+	}
+
+	switch req.Kind {
+	case domain.VoucherKindDiscount:
+		if req.DiscountPercent <= 0 || req.DiscountPercent > 100 {
+			return invalid("discount_percent must be above 0 and at most 100")
+		}
+	case domain.VoucherKindTrial:
+		if req.TrialBandwidth < 1 {
+			return invalid("trial_bandwidth must be at least 1 GB")
+		}
+		if req.TrialDuration < 1 || req.TrialDuration > 365 {
+			return invalid("trial_duration must be between 1 and 365 days")
+		}
+	default:
+		return invalid("kind must be %q or %q", domain.VoucherKindDiscount, domain.VoucherKindTrial)
+	}
+
+	if req.MinBandwidth < 0 || req.MaxBandwidth < 0 || (req.MaxBandwidth > 0 && req.MinBandwidth > req.MaxBandwidth) {
+		return invalid("min_bandwidth and max_bandwidth must not be negative, with min <= max")
+	}
+	if req.MaxRedemptions < 0 {
+		return invalid("max_redemptions must not be negative")
+	}
+	if req.SingleUse && req.MaxRedemptions > 1 {
+		return invalid("single_use conflicts with max_redemptions %d", req.MaxRedemptions)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return invalid("expires_at must be in the future")
+	}
+	return nil
+}
+
+// generateVoucherCode returns PREFIX-XXXX-XXXX with random characters
+func generateVoucherCode(prefix string) (string, error) {
+	var code strings.Builder
+	code.WriteString(prefix)
+	max := big.NewInt(int64(len(voucherCodeAlphabet)))
+	for i := 0; i < 8; i++ {
+		if i%4 == 0 {
+			code.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate voucher code: %w", err)
+		}
+		code.WriteByte(voucherCodeAlphabet[n.Int64()])
+	}
+	return code.String(), nil
+}
+
+// List returns every voucher with its status, newest first
+func (s *VoucherService) List(ctx context.Context, now time.Time) ([]*domain.VoucherResponse, error) {
+	vouchers, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*domain.VoucherResponse, len(vouchers))
+	for i, voucher := range vouchers {
+		responses[i] = &domain.VoucherResponse{Voucher: voucher, Status: voucher.Status(now)}
+	}
+	return responses, nil
+}
+
+// Get returns a voucher with its status and redemptions
+func (s *VoucherService) Get(ctx context.Context, code string, now time.Time) (*domain.VoucherResponse, error) {
+	voucher, err := s.get(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	redemptions, err := s.repo.GetRedemptions(ctx, voucher.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.VoucherResponse{Voucher: voucher, Status: voucher.Status(now), RedemptionRecords: redemptions}, nil
+}
+
+// Redemptions returns every redemption, newest first
+func (s *VoucherService) Redemptions(ctx context.Context) ([]*domain.VoucherRedemption, error) {
+	return s.repo.GetRedemptions(ctx, "")
+}
+
+// Revoke stops a voucher being redeemed; plans already created with it
+// are not affected
+func (s *VoucherService) Revoke(ctx context.Context, code string, now time.Time) (*domain.VoucherResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	voucher, err := s.get(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if voucher.RevokedAt == nil {
+		revokedAt := now.UTC()
+		voucher.RevokedAt = &revokedAt
+		if err := s.repo.Update(ctx, voucher); err != nil {
+			return nil, fmt.Errorf("failed to store voucher: %w", err)
+		}
+		s.logger.Info("Revoked voucher", zap.String("code", voucher.Code))
+	}
+
+	return &domain.VoucherResponse{Voucher: voucher, Status: voucher.Status(now)}, nil
+}
+
+// Redeem checks a voucher against a plan request and counts its use by
+// planID. Trial vouchers replace the request's bandwidth and duration with
+// their own, on a days cycle. Rejections are *domain.PolicyError.
+func (s *VoucherService) Redeem(ctx context.Context, code string, planID uuid.UUID, planTypeKey string, req *domain.CreatePlanRequest, now time.Time) (*domain.VoucherRedemption, *domain.PlanVoucher, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	voucher, err := s.repo.GetByCode(ctx, domain.NormalizeVoucherCode(code))
+	if err != nil {
+		return nil, nil, err
+	}
+	if voucher == nil {
+		return nil, nil, &domain.PolicyError{PlanType: planTypeKey, Field: "voucher", Reason: "does not exist"}
+	}
+	if err := voucher.Check(planTypeKey, req, now); err != nil {
+		return nil, nil, err
+	}
+
+	applied := &domain.PlanVoucher{Code: voucher.Code, Kind: voucher.Kind, DiscountPercent: voucher.DiscountPercent}
+	if voucher.Kind == domain.VoucherKindTrial {
+		req.Bandwidth, req.Duration = voucher.TrialBandwidth, voucher.TrialDuration
+		req.Cycle, req.Months = domain.BillingCycleDays, 0
+		applied.DiscountPercent = 100
+	}
+
+	voucher.Redemptions++
+	if err := s.repo.Update(ctx, voucher); err != nil {
+		return nil, nil, fmt.Errorf("failed to store voucher: %w", err)
+	}
+
+	redemption := &domain.VoucherRedemption{
+		ID:              uuid.New(),
+		Code:            voucher.Code,
+		PlanID:          planID,
+		CustomerID:      req.CustomerID,
+		PlanTypeKey:     planTypeKey,
+		Kind:            voucher.Kind,
+		DiscountPercent: applied.DiscountPercent,
+		Bandwidth:       req.Bandwidth,
+		Duration:        req.Duration,
+		RedeemedAt:      now.UTC(),
+	}
+	if err := s.repo.CreateRedemption(ctx, redemption); err != nil {
+		voucher.Redemptions--
+		if restoreErr := s.repo.Update(ctx, voucher); restoreErr != nil {
+			s.logger.Error("Failed to restore voucher redemption count", zap.String("code", voucher.Code), zap.Error(restoreErr))
+		}
+		return nil, nil, fmt.Errorf("failed to store voucher redemption: %w", err)
+	}
+
+	s.logger.Info("Redeemed voucher",
+		zap.String("code", voucher.Code),
+		zap.String("plan_id", planID.String()),
+		zap.String("kind", voucher.Kind))
+	return redemption, applied, nil
+}
+
+// Release gives back the use of a redemption whose plan was not created,
+// keeping the record with the reason
+func (s *VoucherService) Release(ctx context.Context, redemption *domain.VoucherRedemption, reason string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	releasedAt := now.UTC()
+	redemption.ReleasedAt = &releasedAt
+	redemption.ReleaseReason = reason
+	if err := s.repo.UpdateRedemption(ctx, redemption); err != nil {
+		s.logger.Error("Failed to release voucher redemption", zap.String("code", redemption.Code), zap.Error(err))
+		return
+	}
+
+	voucher, err := s.repo.GetByCode(ctx, redemption.Code)
+	if err != nil || voucher == nil {
+		s.logger.Error("Failed to load released voucher", zap.String("code", redemption.Code), zap.Error(err))
+		return
+	}
+	if voucher.Redemptions > 0 {
+		voucher.Redemptions--
+	}
+	if err := s.repo.Update(ctx, voucher); err != nil {
+		s.logger.Error("Failed to store released voucher", zap.String("code", voucher.Code), zap.Error(err))
+		return
+	}
+
+	s.logger.Info("Released voucher redemption",
+		zap.String("code", voucher.Code),
+		zap.String("plan_id", redemption.PlanID.String()),
+		zap.String("reason", reason))
+}
+
+// get returns a voucher by code or ErrVoucherNotFound
+func (s *VoucherService) get(ctx context.Context, code string) (*domain.Voucher, error) {
+	voucher, err := s.repo.GetByCode(ctx, domain.NormalizeVoucherCode(code))
+	if err != nil {
+		return nil, err
+	}
+	if voucher == nil {
+		return nil, ErrVoucherNotFound
+	}
+	return voucher, nil
+}
@@ -2,21 +2,45 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Environment string    `mapstructure:"environment"`
-	Server      Server    `mapstructure:"server"`
-	Database    Database  `mapstructure:"database"`
-	Redis       Redis     `mapstructure:"redis"`
-	Logger      Logger    `mapstructure:"logger"`
-	Auth        Auth      `mapstructure:"auth"`
-	Providers   Providers `mapstructure:"providers"`
-	Proxy       Proxy     `mapstructure:"proxy"`
+	Environment    string         `mapstructure:"environment"`
+	Server         Server         `mapstructure:"server"`
+	API            API            `mapstructure:"api"`
+	Database       Database       `mapstructure:"database"`
+	Redis          Redis          `mapstructure:"redis"`
+	PortAllocation PortAllocation `mapstructure:"port_allocation"`
+	Logger         Logger         `mapstructure:"logger"`
+	Auth           Auth           `mapstructure:"auth"`
+	Providers      Providers      `mapstructure:"providers"`
+	Proxy          Proxy          `mapstructure:"proxy"`
+	GeoCheck       GeoCheck       `mapstructure:"geo_check"`
+	Notifications  Notifications  `mapstructure:"notifications"`
+	Slack          Slack          `mapstructure:"slack"`
+	Alerting       Alerting       `mapstructure:"alerting"`
+	Maintenance    Maintenance    `mapstructure:"maintenance"`
+	Metrics        Metrics        `mapstructure:"metrics"`
+	StatusPage     StatusPage     `mapstructure:"status_page"`
+	SLA            SLA            `mapstructure:"sla"`
+	DeadPlans      DeadPlans      `mapstructure:"dead_plans"`
+	Stats          Stats          `mapstructure:"stats"`
+	Reports        Reports        `mapstructure:"reports"`
+	Updates        Updates        `mapstructure:"updates"`
+	Backup         Backup         `mapstructure:"backup"`
+	GitOps         GitOps         `mapstructure:"gitops"`
+	Timeouts       Timeouts       `mapstructure:"timeouts"`
+	Billing        Billing        `mapstructure:"billing"`
+	Usernames      Usernames      `mapstructure:"usernames"`
+	Passwords      Passwords      `mapstructure:"passwords"`
 }
 
 type Server struct {
@@ -26,29 +50,142 @@ type Server struct {
 	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
 	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
 	CORS            CORS          `mapstructure:"cors"`
+	TLS             TLS           `mapstructure:"tls"`
+
+	// CompressionLevel is the gzip level for JSON and text responses; 0 disables compression
+	CompressionLevel int `mapstructure:"compression_level"`
+
+	RateLimit RateLimit `mapstructure:"rate_limit"`
+
+	// MaxBodyBytes caps request bodies; 0 disables the cap
+	MaxBodyBytes int64 `mapstructure:"max_body_bytes"`
+
+	// ReadOnly rejects mutating API requests while reads and the proxies keep
+	// serving; PUT /admin/readonly switches it at runtime
+	ReadOnly bool `mapstructure:"read_only"`
+
+	// TrustedProxies are the IPs and CIDR networks of reverse proxies whose
+	// X-Forwarded-For and X-Real-IP headers name the client; other peers'
+	// headers are ignored
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+// RateLimit sets per-client-IP budgets in requests per minute; 0 disables a
+// budget. Requests with the admin bearer token are not limited.
+type RateLimit struct {
+	// Create limits plan creation and cloning, on top of Write
+	Create int `mapstructure:"create"`
+	Write  int `mapstructure:"write"`
+	Read   int `mapstructure:"read"`
+
+	// Public limits the unauthenticated status, customer metrics and portal routes
+	Public int `mapstructure:"public"`
+
+	// BypassToken exempts requests sending it in X-RateLimit-Bypass from
+	// every limit, for operator tooling; empty exempts none
+	BypassToken string `mapstructure:"bypass_token"`
+}
+
+// API configures versioning of the HTTP API
+type API struct {
+	// LegacyRoutes serves the deprecated /plan and /nettify/plan routes,
+	// superseded by POST /api/v1/plans
+	LegacyRoutes bool `mapstructure:"legacy_routes"`
+
+	// LegacySunset is the date (YYYY-MM-DD) announced in the legacy routes'
+	// Sunset header; empty announces none
+	LegacySunset string `mapstructure:"legacy_sunset"`
+}
+
+// Sunset returns the parsed legacy sunset date, zero when unset
+func (a API) Sunset() time.Time {
+	sunset, _ := time.Parse("2006-01-02", a.LegacySunset)
+	return sunset
+}
+
+type TLS struct {
+	Enabled                   bool   `mapstructure:"enabled"`
+	CertFile                  string `mapstructure:"cert_file"`
+	KeyFile                   string `mapstructure:"key_file"`
+	ClientCAFile              string `mapstructure:"client_ca_file"`
+	RequireClientCertForAdmin bool   `mapstructure:"require_client_cert_for_admin"`
+	RedirectHTTP              bool   `mapstructure:"redirect_http"`
+	HTTPPort                  int    `mapstructure:"http_port"`
 }
 
 type CORS struct {
-	AllowOrigins     []string `mapstructure:"allow_origins"`
-	AllowMethods     []string `mapstructure:"allow_methods"`
-	AllowHeaders     []string `mapstructure:"allow_headers"`
-	AllowCredentials bool     `mapstructure:"allow_credentials"`
+	CORSPolicy `mapstructure:",squash"`
+
+	// Routes override the default policy for matching path prefixes (longest prefix wins)
+	Routes []CORSRoute `mapstructure:"routes"`
+}
+
+type CORSPolicy struct {
+	// AllowOrigins entries are exact origins, "*", wildcards like
+	// "https://*.oceanproxy.io", or regular expressions prefixed with "regex:"
+	AllowOrigins     []string      `mapstructure:"allow_origins"`
+	AllowMethods     []string      `mapstructure:"allow_methods"`
+	AllowHeaders     []string      `mapstructure:"allow_headers"`
+	ExposeHeaders    []string      `mapstructure:"expose_headers"`
+	AllowCredentials bool          `mapstructure:"allow_credentials"`
+	MaxAge           time.Duration `mapstructure:"max_age"`
+}
+
+type CORSRoute struct {
+	PathPrefix string `mapstructure:"path_prefix"`
+	CORSPolicy `mapstructure:",squash"`
 }
 
+// Database drivers
+const (
+	DatabaseDriverJSON     = "json"
+	DatabaseDriverPostgres = "postgres"
+)
+
+// Database selects where plans and instances are stored. json keeps them in
+// files next to DSN; postgres keeps them in the PostgreSQL database DSN
+// names, so several API servers can share them. The other stores are JSON
+// files either way, next to DataFile under postgres.
 type Database struct {
 	Driver          string        `mapstructure:"driver"`
 	DSN             string        `mapstructure:"dsn"`
+	DataFile        string        `mapstructure:"data_file"`
 	MaxOpenConns    int           `mapstructure:"max_open_conns"`
 	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
 	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
 }
 
+// JSONFile returns the path the JSON stores are kept next to
+func (d Database) JSONFile() string {
+	if d.Driver == DatabaseDriverPostgres {
+		return d.DataFile
+	}
+	return d.DSN
+}
+
 type Redis struct {
 	Addr     string `mapstructure:"addr"`
 	Password string `mapstructure:"password"`
 	DB       int    `mapstructure:"db"`
 }
 
+// Port allocation backends
+const (
+	PortAllocationMemory = "memory"
+	PortAllocationRedis  = "redis"
+)
+
+// PortAllocation selects where local port and egress IP allocations are
+// kept. memory keeps them in the process, which allows one API replica;
+// redis keeps them on the redis server, so replicas sharing it never
+// allocate the same port or dedicated egress IP.
+type PortAllocation struct {
+	Backend string `mapstructure:"backend"`
+
+	// KeyPrefix starts every redis key, to share a server between deployments
+	KeyPrefix string `mapstructure:"key_prefix"`
+}
+
 type Logger struct {
 	Level  string `mapstructure:"level"`
 	Format string `mapstructure:"format"`
@@ -61,6 +198,14 @@ type Auth struct {
 }
 
 type Providers struct {
+	// HTTPProxy sends every provider's API calls through an outbound proxy
+	// unless the provider sets its own; empty uses HTTPS_PROXY, HTTP_PROXY
+	// and NO_PROXY from the environment
+	HTTPProxy string `mapstructure:"http_proxy"`
+
+	// NoProxy lists hosts reached without HTTPProxy, as in NO_PROXY
+	NoProxy []string `mapstructure:"no_proxy"`
+
 	ProxiesFo ProxiesFoConfig `mapstructure:"proxies_fo"`
 	Nettify   NettifyConfig   `mapstructure:"nettify"`
 }
@@ -69,12 +214,143 @@ type ProxiesFoConfig struct {
 	APIKey  string        `mapstructure:"api_key"`
 	BaseURL string        `mapstructure:"base_url"`
 	Timeout time.Duration `mapstructure:"timeout"`
+
+	// ResellerIDs maps plan types (residential, isp, datacenter) to reseller UUIDs
+	ResellerIDs map[string]string `mapstructure:"reseller_ids"`
+
+	// ReuseAccounts tops up a customer's existing account instead of creating a new one
+	ReuseAccounts bool `mapstructure:"reuse_accounts"`
+
+	// HTTPProxy and NoProxy override the providers-wide settings;
+	// "direct" bypasses a providers-wide proxy
+	HTTPProxy string   `mapstructure:"http_proxy"`
+	NoProxy   []string `mapstructure:"no_proxy"`
+
+	HTTP ProviderHTTP `mapstructure:"http"`
 }
 
 type NettifyConfig struct {
 	APIKey  string        `mapstructure:"api_key"`
 	BaseURL string        `mapstructure:"base_url"`
 	Timeout time.Duration `mapstructure:"timeout"`
+
+	// ReuseAccounts tops up a customer's existing account instead of creating a new one
+	ReuseAccounts bool `mapstructure:"reuse_accounts"`
+
+	// HTTPProxy and NoProxy override the providers-wide settings;
+	// "direct" bypasses a providers-wide proxy
+	HTTPProxy string   `mapstructure:"http_proxy"`
+	NoProxy   []string `mapstructure:"no_proxy"`
+
+	HTTP ProviderHTTP `mapstructure:"http"`
+}
+
+// ProviderHTTP tunes the pooled HTTP client a provider's API calls share.
+// Idle connections are kept alive and reused, so bulk operations do not
+// open a connection per call. Changes take effect on restart.
+type ProviderHTTP struct {
+	MaxIdleConns        int           `mapstructure:"max_idle_conns"`
+	MaxIdleConnsPerHost int           `mapstructure:"max_idle_conns_per_host"`
+	MaxConnsPerHost     int           `mapstructure:"max_conns_per_host"` // 0 for no limit
+	IdleConnTimeout     time.Duration `mapstructure:"idle_conn_timeout"`
+	KeepAlive           time.Duration `mapstructure:"keep_alive"`
+	DialTimeout         time.Duration `mapstructure:"dial_timeout"`
+	TLSHandshakeTimeout time.Duration `mapstructure:"tls_handshake_timeout"`
+
+	// CAFile adds PEM certificates to the system roots, e.g. for a
+	// provider behind a private CA or a TLS-inspecting egress proxy
+	CAFile string `mapstructure:"ca_file"`
+}
+
+// Validate checks the client settings of the provider named by key
+func (h ProviderHTTP) Validate(key string) error {
+	if h.MaxIdleConns < 0 || h.MaxIdleConnsPerHost < 0 || h.MaxConnsPerHost < 0 {
+		return fmt.Errorf("%s: connection limits must not be negative", key)
+	}
+	if h.IdleConnTimeout < 0 || h.KeepAlive < 0 || h.DialTimeout < 0 || h.TLSHandshakeTimeout < 0 {
+		return fmt.Errorf("%s: timeouts must not be negative", key)
+	}
+	if h.CAFile != "" {
+		if _, err := os.Stat(h.CAFile); err != nil {
+			return fmt.Errorf("%s.ca_file: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// DirectHTTPProxy as a provider's http_proxy bypasses providers.http_proxy
+const DirectHTTPProxy = "direct"
+
+// OutboundProxy is the proxy a provider's API calls go through
+type OutboundProxy struct {
+	URL     string   // empty uses the environment; DirectHTTPProxy uses none
+	NoProxy []string // hosts reached directly
+}
+
+// OutboundProxy returns the proxy for a provider's API calls, applying its
+// overrides to the providers-wide settings
+func (p Providers) OutboundProxy(provider string) OutboundProxy {
+	proxy := OutboundProxy{URL: p.HTTPProxy, NoProxy: p.NoProxy}
+
+	var httpProxy string
+	var noProxy []string
+	switch provider {
+	case "proxies_fo":
+		httpProxy, noProxy = p.ProxiesFo.HTTPProxy, p.ProxiesFo.NoProxy
+	case "nettify":
+		httpProxy, noProxy = p.Nettify.HTTPProxy, p.Nettify.NoProxy
+	}
+	if httpProxy != "" {
+		proxy.URL = httpProxy
+	}
+	if len(noProxy) > 0 {
+		proxy.NoProxy = noProxy
+	}
+	return proxy
+}
+
+// validateHTTPProxy checks an http_proxy setting
+func validateHTTPProxy(key, value string, allowDirect bool) error {
+	if value == "" || (allowDirect && value == DirectHTTPProxy) {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%s: %q is not a valid URL", key, value)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("%s: scheme must be http, https or socks5", key)
+	}
+	return nil
+}
+
+// validateNoProxy checks the entries of a no_proxy setting
+func validateNoProxy(key string, entries []string) error {
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			return fmt.Errorf("%s: empty entry", key)
+		}
+		if strings.Contains(entry, "/") {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				return fmt.Errorf("%s: %q is not a valid CIDR", key, entry)
+			}
+		}
+	}
+	return nil
+}
+
+// ReuseAccounts reports whether account reuse is enabled for a provider
+func (p Providers) ReuseAccounts(provider string) bool {
+	switch provider {
+	case "proxies_fo":
+		return p.ProxiesFo.ReuseAccounts
+	case "nettify":
+		return p.Nettify.ReuseAccounts
+	}
+	return false
 }
 
 type Proxy struct {
@@ -85,6 +361,749 @@ type Proxy struct {
 	LogDir       string `mapstructure:"log_dir"`
 	ScriptDir    string `mapstructure:"script_dir"`
 	NginxConfDir string `mapstructure:"nginx_conf_dir"`
+	CgroupRoot   string `mapstructure:"cgroup_root"`
+
+	// UpstreamProbeInterval and UpstreamProbeTimeout control RTT probing of
+	// upstream hosts for plan types using latency-based selection
+	UpstreamProbeInterval time.Duration `mapstructure:"upstream_probe_interval"`
+	UpstreamProbeTimeout  time.Duration `mapstructure:"upstream_probe_timeout"`
+
+	// ConnectionTestURL is fetched through an instance to test it; empty only checks the process
+	ConnectionTestURL string `mapstructure:"connection_test_url"`
+
+	// Health check defaults; plan types may override them. A zero interval
+	// only schedules checks for plan types that set their own.
+	HealthCheckInterval         time.Duration `mapstructure:"health_check_interval"`
+	HealthCheckTimeout          time.Duration `mapstructure:"health_check_timeout"`
+	HealthCheckFailureThreshold int           `mapstructure:"health_check_failure_threshold"`
+	HealthCheckSuccessThreshold int           `mapstructure:"health_check_success_threshold"`
+
+	// HealthCheckWorkers bounds how many instances are health checked at once
+	HealthCheckWorkers int `mapstructure:"health_check_workers"`
+
+	Binary ProxyBinary `mapstructure:"binary"`
+
+	AuthGuard AuthGuard `mapstructure:"auth_guard"`
+
+	DoH DoHForwarding `mapstructure:"doh"`
+
+	Activation Activation `mapstructure:"activation"`
+
+	Cache ResponseCache `mapstructure:"cache"`
+
+	DebugSampling DebugSampling `mapstructure:"debug_sampling"`
+
+	TempCredentials TempCredentials `mapstructure:"temp_credentials"`
+
+	SubUsers SubUsers `mapstructure:"subusers"`
+
+	DNSRecords DNSRecords `mapstructure:"dns_records"`
+}
+
+// DNSRecords configures the zone file fragment written whenever regions
+// change. Each region's full domain gets a record pointing at Target: an A
+// or AAAA record for an IP, otherwise a CNAME. An empty File writes nothing.
+type DNSRecords struct {
+	File   string `mapstructure:"file"`
+	Target string `mapstructure:"target"`
+	TTL    int    `mapstructure:"ttl"`
+}
+
+// DebugSampling bounds the request sampling plans can be put in to debug
+// customer issues. Instance logs are read every Interval; a plan samples
+// for at most MaxWindow and keeps its latest MaxSamples requests in memory.
+type DebugSampling struct {
+	Interval   time.Duration `mapstructure:"interval"`
+	MaxWindow  time.Duration `mapstructure:"max_window"`
+	MaxSamples int           `mapstructure:"max_samples"`
+}
+
+// TempCredentials bounds the short-lived credentials issued for plans.
+// Expired ones are removed from their plan's instances every Interval; a
+// credential lasts at most MaxTTL and a plan holds at most MaxPerPlan.
+type TempCredentials struct {
+	Interval   time.Duration `mapstructure:"interval"`
+	MaxTTL     time.Duration `mapstructure:"max_ttl"`
+	MaxPerPlan int           `mapstructure:"max_per_plan"`
+}
+
+// SubUsers bounds the sub-users plans can be split into. Instance logs are
+// read every Interval to count each sub-user's traffic and lock out those
+// over their limit; 0 disables counting and limits.
+type SubUsers struct {
+	Interval   time.Duration `mapstructure:"interval"`
+	MaxPerPlan int           `mapstructure:"max_per_plan"`
+}
+
+// ResponseCache bounds the response caches datacenter plans may enable; a
+// plan's TTL and size default to these limits
+type ResponseCache struct {
+	MaxTTL    time.Duration `mapstructure:"max_ttl"`
+	MaxSizeMB int           `mapstructure:"max_size_mb"`
+}
+
+// Activation retries bringing up new plans whose instance failed to start or
+// whose nginx upstream could not be updated. Pending plans are checked every
+// Interval; a failed attempt is retried after Backoff, doubled after each
+// failure, until Retries is used up and the plan is marked failed.
+type Activation struct {
+	Interval time.Duration `mapstructure:"interval"`
+	Retries  int           `mapstructure:"retries"`
+	Backoff  time.Duration `mapstructure:"backoff"`
+}
+
+// DoHForwarding configures the local DNS forwarders serving plan types with
+// DNS-over-HTTPS resolvers. Each distinct resolver list gets a forwarder on
+// ListenHost, on consecutive ports from PortStart in the order of the sorted
+// lists, which 3proxy instances use as their nserver.
+type DoHForwarding struct {
+	ListenHost string        `mapstructure:"listen_host"`
+	PortStart  int           `mapstructure:"port_start"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+}
+
+// AuthGuard temporarily blocks source IPs that keep failing proxy
+// authentication. Failures are read from the 3proxy logs every Interval; an
+// IP with MaxFailures failures on a port within Window is blocked there for
+// BlockFor. Action "command" runs BlockCommand and UnblockCommand, with {ip}
+// and {port} replaced; "acl" adds a deny rule to the instance's 3proxy config
+// and restarts it.
+type AuthGuard struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	Action         string        `mapstructure:"action"`
+	Interval       time.Duration `mapstructure:"interval"`
+	MaxFailures    int           `mapstructure:"max_failures"`
+	Window         time.Duration `mapstructure:"window"`
+	BlockFor       time.Duration `mapstructure:"block_for"`
+	BlockCommand   []string      `mapstructure:"block_command"`
+	UnblockCommand []string      `mapstructure:"unblock_command"`
+
+	// IgnoreIPs are never blocked
+	IgnoreIPs []string `mapstructure:"ignore_ips"`
+}
+
+// ProxyBinary selects the 3proxy binary. With a pinned Version the binary is
+// downloaded from URL into ManagedDir, verified against SHA256, and checked
+// at startup to report that version.
+type ProxyBinary struct {
+	// Path is an explicit binary; empty uses the managed binary or 3proxy from PATH
+	Path    string `mapstructure:"path"`
+	Version string `mapstructure:"version"`
+
+	// URL of the release binary or a .tar.gz containing it; {version} is substituted
+	URL        string `mapstructure:"url"`
+	SHA256     string `mapstructure:"sha256"`
+	ManagedDir string `mapstructure:"managed_dir"`
+}
+
+// GeoCheck configures exit IP geolocation checks of plans against their region
+type GeoCheck struct {
+	Enabled bool `mapstructure:"enabled"`
+
+	// LookupURL is fetched through the plan's upstream and must return JSON
+	// with the exit IP (query/ip) and ISO country code (countryCode/country_code/country)
+	LookupURL string        `mapstructure:"lookup_url"`
+	Interval  time.Duration `mapstructure:"interval"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+
+	// Remediation is "alert" or "reset_upstream"
+	Remediation string `mapstructure:"remediation"`
+}
+
+// Notifications configures delivery of plan notifications and operator
+// alerts. Without channels they are only logged.
+type Notifications struct {
+	// WebhookURL receives each notification as a JSON POST
+	WebhookURL string        `mapstructure:"webhook_url"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+
+	Telegram TelegramNotifications `mapstructure:"telegram"`
+	Discord  DiscordNotifications  `mapstructure:"discord"`
+
+	// Routes send notification types to channels. Without routes every
+	// configured channel receives every notification; with them, a
+	// notification no route matches is only logged.
+	Routes []NotificationRoute `mapstructure:"routes"`
+
+	// An instance that goes down CrashLoopThreshold times within
+	// CrashLoopWindow is alerted as crash looping; 0 disables the alert
+	CrashLoopThreshold int           `mapstructure:"crash_loop_threshold"`
+	CrashLoopWindow    time.Duration `mapstructure:"crash_loop_window"`
+}
+
+// TelegramNotifications sends notifications through a Telegram bot to a
+// chat the bot has been added to
+type TelegramNotifications struct {
+	BotToken string `mapstructure:"bot_token"`
+	ChatID   string `mapstructure:"chat_id"`
+	APIURL   string `mapstructure:"api_url"`
+}
+
+// DiscordNotifications sends notifications through a Discord bot to a
+// channel it can post in
+type DiscordNotifications struct {
+	BotToken  string `mapstructure:"bot_token"`
+	ChannelID string `mapstructure:"channel_id"`
+	APIURL    string `mapstructure:"api_url"`
+}
+
+// Notification channels
+const (
+	NotificationChannelWebhook  = "webhook"
+	NotificationChannelTelegram = "telegram"
+	NotificationChannelDiscord  = "discord"
+)
+
+// NotificationRoute sends the notifications of Types to Channels. A type
+// is matched exactly, by category ("instance.*") or as "*" for all.
+type NotificationRoute struct {
+	Types    []string `mapstructure:"types"`
+	Channels []string `mapstructure:"channels"`
+}
+
+// Channels returns the configured notification channels
+func (n Notifications) Channels() []string {
+	var channels []string
+	if n.WebhookURL != "" {
+		channels = append(channels, NotificationChannelWebhook)
+	}
+	if n.Telegram.BotToken != "" {
+		channels = append(channels, NotificationChannelTelegram)
+	}
+	if n.Discord.BotToken != "" {
+		channels = append(channels, NotificationChannelDiscord)
+	}
+	return channels
+}
+
+// validate checks the chat channels are complete and routes name
+// configured channels
+func (n Notifications) validate() error {
+	if (n.Telegram.BotToken == "") != (n.Telegram.ChatID == "") {
+		return fmt.Errorf("notifications.telegram: bot_token and chat_id must be set together")
+	}
+	if (n.Discord.BotToken == "") != (n.Discord.ChannelID == "") {
+		return fmt.Errorf("notifications.discord: bot_token and channel_id must be set together")
+	}
+
+	configured := make(map[string]bool)
+	for _, channel := range n.Channels() {
+		configured[channel] = true
+	}
+	for i, route := range n.Routes {
+		if len(route.Types) == 0 || len(route.Channels) == 0 {
+			return fmt.Errorf("notifications.routes[%d]: types and channels are required", i)
+		}
+		for _, channel := range route.Channels {
+			if !configured[channel] {
+				return fmt.Errorf("notifications.routes[%d]: channel %q is not configured", i, channel)
+			}
+		}
+	}
+
+	if n.CrashLoopThreshold < 0 || (n.CrashLoopThreshold > 0 && n.CrashLoopWindow <= 0) {
+		return fmt.Errorf("notifications: crash_loop_threshold must not be negative and crash_loop_window must be positive")
+	}
+	return nil
+}
+
+// Paging destinations of alerts
+const (
+	AlertDestinationPagerDuty = "pagerduty"
+	AlertDestinationOpsgenie  = "opsgenie"
+)
+
+// Alerting pages on-call through PagerDuty and Opsgenie when critical
+// conditions are detected, and resolves the alerts when they clear.
+// Conditions are evaluated every Interval; 0 disables alerting.
+type Alerting struct {
+	Interval time.Duration `mapstructure:"interval"`
+
+	// Source names this server in alerts; empty uses the host name
+	Source string `mapstructure:"source"`
+
+	PagerDuty PagerDutyAlerting `mapstructure:"pagerduty"`
+	Opsgenie  OpsgenieAlerting  `mapstructure:"opsgenie"`
+
+	// Severities sets the severity of each alert kind
+	Severities map[string]string `mapstructure:"severities"`
+
+	// Routes lists the destinations paged for each severity; destinations
+	// that are not configured are skipped
+	Routes map[string][]string `mapstructure:"routes"`
+}
+
+// PagerDutyAlerting sends alerts to a PagerDuty service through the Events
+// API v2 with the service's integration (routing) key
+type PagerDutyAlerting struct {
+	RoutingKey string `mapstructure:"routing_key"`
+	URL        string `mapstructure:"url"`
+}
+
+// OpsgenieAlerting sends alerts through the Opsgenie Alert API with an API
+// integration key
+type OpsgenieAlerting struct {
+	APIKey string `mapstructure:"api_key"`
+	URL    string `mapstructure:"url"`
+}
+
+// Destinations returns the configured paging destinations
+func (a Alerting) Destinations() []string {
+	var destinations []string
+	if a.PagerDuty.RoutingKey != "" {
+		destinations = append(destinations, AlertDestinationPagerDuty)
+	}
+	if a.Opsgenie.APIKey != "" {
+		destinations = append(destinations, AlertDestinationOpsgenie)
+	}
+	return destinations
+}
+
+// validate checks severities and routes use known names
+func (a Alerting) validate() error {
+	if a.Interval < 0 {
+		return fmt.Errorf("alerting.interval must not be negative")
+	}
+	severities := map[string]bool{"critical": true, "error": true, "warning": true, "info": true}
+	for kind, severity := range a.Severities {
+		if !severities[severity] {
+			return fmt.Errorf("alerting.severities.%s: %q must be critical, error, warning or info", kind, severity)
+		}
+	}
+	for severity, destinations := range a.Routes {
+		if !severities[severity] {
+			return fmt.Errorf("alerting.routes: %q must be critical, error, warning or info", severity)
+		}
+		for _, destination := range destinations {
+			if destination != AlertDestinationPagerDuty && destination != AlertDestinationOpsgenie {
+				return fmt.Errorf("alerting.routes.%s: %q must be pagerduty or opsgenie", severity, destination)
+			}
+		}
+	}
+	return nil
+}
+
+// Maintenance feed formats
+const (
+	// MaintenanceFeedStatuspage is the scheduled-maintenances.json of a
+	// Statuspage-hosted status page
+	MaintenanceFeedStatuspage = "statuspage"
+	// MaintenanceFeedRSS is an RSS feed whose items carry the RSS event
+	// module's ev:startdate and ev:enddate
+	MaintenanceFeedRSS = "rss"
+)
+
+// Maintenance configures ingestion of provider maintenance windows. Every
+// FetchInterval (0 disables it) the feeds are fetched and windows that
+// ended more than Retention ago are removed.
+type Maintenance struct {
+	FetchInterval time.Duration     `mapstructure:"fetch_interval"`
+	Retention     time.Duration     `mapstructure:"retention"`
+	Feeds         []MaintenanceFeed `mapstructure:"feeds"`
+}
+
+// MaintenanceFeed is a provider status feed listing scheduled maintenance
+type MaintenanceFeed struct {
+	Name     string `mapstructure:"name"`
+	Provider string `mapstructure:"provider"`
+	URL      string `mapstructure:"url"`
+	Format   string `mapstructure:"format"`
+
+	// PlanTypes limits the feed's windows to these plan types; empty
+	// covers every plan type of the provider
+	PlanTypes []string `mapstructure:"plan_types"`
+}
+
+// validate checks feeds are named uniquely and have a known format
+func (m Maintenance) validate() error {
+	if m.FetchInterval < 0 || m.Retention < 0 {
+		return fmt.Errorf("maintenance: fetch_interval and retention must not be negative")
+	}
+	names := make(map[string]bool)
+	for i, feed := range m.Feeds {
+		if feed.Name == "" || feed.Name == "manual" {
+			return fmt.Errorf("maintenance.feeds[%d]: name is required and must not be \"manual\"", i)
+		}
+		if names[feed.Name] {
+			return fmt.Errorf("maintenance.feeds[%d]: duplicate name %q", i, feed.Name)
+		}
+		names[feed.Name] = true
+		if feed.Provider == "" {
+			return fmt.Errorf("maintenance.feeds.%s: provider is required", feed.Name)
+		}
+		if !strings.HasPrefix(feed.URL, "https://") && !strings.HasPrefix(feed.URL, "http://") {
+			return fmt.Errorf("maintenance.feeds.%s: url must be an http(s) URL", feed.Name)
+		}
+		if feed.Format != MaintenanceFeedStatuspage && feed.Format != MaintenanceFeedRSS {
+			return fmt.Errorf("maintenance.feeds.%s: format must be statuspage or rss", feed.Name)
+		}
+	}
+	return nil
+}
+
+// Slack roles for slash commands
+const (
+	// SlackRoleViewer may see status and plan listings
+	SlackRoleViewer = "viewer"
+	// SlackRoleOperator may also restart instances
+	SlackRoleOperator = "operator"
+)
+
+// Slack configures the /slack/commands endpoint for the Slack app's slash
+// command. Requests are authenticated with the app's signing secret.
+type Slack struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	SigningSecret string `mapstructure:"signing_secret"`
+
+	// MaxSkew is how far a request's timestamp may be from now before it
+	// is refused as a replay
+	MaxSkew time.Duration `mapstructure:"max_skew"`
+
+	// TeamID, when set, only accepts commands from that workspace
+	TeamID string `mapstructure:"team_id"`
+
+	// Roles lists the Slack user IDs holding each role; "*" is every user.
+	// Users without a role cannot run commands.
+	Roles map[string][]string `mapstructure:"roles"`
+}
+
+// Role returns the role of a Slack user, or "" if they have none
+func (s Slack) Role(userID string) string {
+	for _, role := range []string{SlackRoleOperator, SlackRoleViewer} {
+		for _, id := range s.Roles[role] {
+			if id == "*" || strings.EqualFold(id, userID) {
+				return role
+			}
+		}
+	}
+	return ""
+}
+
+// Metrics configures the per-customer metrics endpoint
+type Metrics struct {
+	// CustomerEndpoint enables /metrics/customer/{token}
+	CustomerEndpoint bool `mapstructure:"customer_endpoint"`
+
+	// TokenSecret signs customer metrics tokens; rotating it revokes all tokens
+	TokenSecret string `mapstructure:"token_secret"`
+
+	// LatencySampleRate is the fraction of logged requests whose upstream
+	// latency is observed; 0 disables request sampling
+	LatencySampleRate float64 `mapstructure:"latency_sample_rate"`
+
+	// LatencyBuckets are the upper bounds, in seconds, of the upstream
+	// latency histograms
+	LatencyBuckets []float64 `mapstructure:"latency_buckets"`
+}
+
+// StatusPage configures the public, unauthenticated /status endpoints.
+// Region uptime and incidents are derived from the health check events of
+// the last Window; the page is rebuilt at most every Refresh.
+type StatusPage struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Title   string        `mapstructure:"title"`
+	Refresh time.Duration `mapstructure:"refresh"`
+	Window  time.Duration `mapstructure:"window"`
+}
+
+// SLA configures uptime targets, in percent, for regions and plans; 0 sets
+// no target. RegionTargets overrides RegionTarget per region. Uptime is
+// reported over the last Window; every CheckInterval the current calendar
+// month is checked against the targets and violations are notified.
+type SLA struct {
+	RegionTarget  float64            `mapstructure:"region_target"`
+	RegionTargets map[string]float64 `mapstructure:"region_targets"`
+	PlanTarget    float64            `mapstructure:"plan_target"`
+	Window        time.Duration      `mapstructure:"window"`
+	CheckInterval time.Duration      `mapstructure:"check_interval"`
+}
+
+// DeadPlans configures the detector of plans that look active but do not
+// work. Request outcomes are read from the 3proxy logs every
+// CollectInterval (0 disables reading them) and kept for Window.
+type DeadPlans struct {
+	CollectInterval time.Duration `mapstructure:"collect_interval"`
+	Window          time.Duration `mapstructure:"window"`
+
+	// A plan with at least MinRequests in the window, of which FailureRatio
+	// or more failed upstream, is flagged
+	MinRequests  int     `mapstructure:"min_requests"`
+	FailureRatio float64 `mapstructure:"failure_ratio"`
+
+	// A plan with at least MinBytes of edge traffic whose provider reports
+	// less than UsageRatio of it as used is flagged
+	MinBytes   int64   `mapstructure:"min_bytes"`
+	UsageRatio float64 `mapstructure:"usage_ratio"`
+}
+
+// Stats configures retention of request/usage statistics. Raw one-minute
+// samples are rolled up into hourly and daily buckets; daily buckets are
+// kept indefinitely.
+type Stats struct {
+	RawRetention    time.Duration `mapstructure:"raw_retention"`
+	HourlyRetention time.Duration `mapstructure:"hourly_retention"`
+
+	// RollupInterval is how often raw and hourly data is downsampled and pruned
+	RollupInterval time.Duration `mapstructure:"rollup_interval"`
+
+	// CollectInterval is how often traffic is collected from the 3proxy
+	// logs; 0 disables collection
+	CollectInterval time.Duration `mapstructure:"collect_interval"`
+
+	// GeoIPDatabase is a MaxMind GeoLite2/GeoIP2 Country or City database;
+	// when set, collected traffic is broken down by client and destination
+	// country. The file is reloaded when it changes.
+	GeoIPDatabase string `mapstructure:"geoip_database"`
+
+	// EnforceBandwidth suspends plans whose collected traffic since their
+	// creation or last renewal, weighed by any accounting schedule of their
+	// plan type, is over their bandwidth. It is checked every collect
+	// interval.
+	EnforceBandwidth bool `mapstructure:"enforce_bandwidth"`
+}
+
+// Reports configures the weekly operator report. It is built on Weekday at
+// Hour, in the billing timezone, over the preceding seven days and
+// delivered by email, webhook or both. An empty Weekday disables it.
+type Reports struct {
+	Weekday string `mapstructure:"weekday"`
+	Hour    int    `mapstructure:"hour"`
+
+	// TopCustomers is how many customers are listed by traffic
+	TopCustomers int `mapstructure:"top_customers"`
+
+	// WebhookURL receives the report as a JSON POST
+	WebhookURL string        `mapstructure:"webhook_url"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+
+	Email ReportEmail `mapstructure:"email"`
+}
+
+// ReportEmail sends the report as HTML with CSV attachments through an
+// SMTP server; without recipients no email is sent
+type ReportEmail struct {
+	SMTPAddr string   `mapstructure:"smtp_addr"`
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
+}
+
+// Enabled reports whether the weekly report is scheduled
+func (r Reports) Enabled() bool {
+	return r.Weekday != ""
+}
+
+// ScheduledWeekday returns the day the report is built on
+func (r Reports) ScheduledWeekday() (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(r.Weekday, day.String()) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", r.Weekday)
+}
+
+// validate checks the schedule and that an enabled report has somewhere to go
+func (r Reports) validate() error {
+	if !r.Enabled() {
+		return nil
+	}
+	if _, err := r.ScheduledWeekday(); err != nil {
+		return fmt.Errorf("reports.weekday: %w", err)
+	}
+	if r.Hour < 0 || r.Hour > 23 {
+		return fmt.Errorf("reports.hour must be between 0 and 23")
+	}
+	if r.TopCustomers <= 0 {
+		return fmt.Errorf("reports.top_customers must be positive")
+	}
+	if r.WebhookURL == "" && len(r.Email.To) == 0 {
+		return fmt.Errorf("reports: webhook_url or email.to is required")
+	}
+	if len(r.Email.To) > 0 {
+		if _, _, err := net.SplitHostPort(r.Email.SMTPAddr); err != nil {
+			return fmt.Errorf("reports.email.smtp_addr: %w", err)
+		}
+		if r.Email.From == "" {
+			return fmt.Errorf("reports.email.from is required")
+		}
+	}
+	return nil
+}
+
+// Updates configures signed self-updates. A control plane serves the
+// release manifest in ReleasesFile; nodes and the CLI check ServerURL.
+type Updates struct {
+	// ReleasesFile is the signed release manifest served at /api/v1/releases/latest
+	ReleasesFile string `mapstructure:"releases_file"`
+
+	// ServerURL is the control plane base URL checked for updates
+	ServerURL string `mapstructure:"server_url"`
+	Token     string `mapstructure:"token"`
+	Channel   string `mapstructure:"channel"`
+
+	// PublicKey is the base64 ed25519 key releases must be signed with
+	PublicKey string `mapstructure:"public_key"`
+
+	// Auto installs new server releases every CheckInterval and exits so the
+	// service manager restarts the new build
+	Auto          bool          `mapstructure:"auto"`
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+}
+
+// Timeouts bound individual operations so a hung dependency cannot stall
+// the request waiting on it. Zero leaves an operation bounded only by its caller.
+type Timeouts struct {
+	// Request is the deadline of every HTTP request, passed down as its context
+	Request time.Duration `mapstructure:"request"`
+
+	// NginxReload bounds each of nginx -t and the reload command
+	NginxReload time.Duration `mapstructure:"nginx_reload"`
+
+	// Exec bounds other helper commands such as sed, lsof and netstat
+	Exec time.Duration `mapstructure:"exec"`
+}
+
+// Billing configures plan expiry
+type Billing struct {
+	// Timezone is the IANA zone plans expire at midnight in when a request
+	// does not name one; empty keeps exact day-count expiry
+	Timezone string `mapstructure:"timezone"`
+
+	// ExpiryInterval is how often expired plans are looked for; 0 disables
+	// the expiry worker
+	ExpiryInterval time.Duration `mapstructure:"expiry_interval"`
+
+	// GracePeriod keeps expired plans serving, flagged grace, before their
+	// instances are stopped; GraceThrottle caps instance bandwidth in bits
+	// per second meanwhile. Plan types may override both.
+	GracePeriod   time.Duration `mapstructure:"grace_period"`
+	GraceThrottle int64         `mapstructure:"grace_throttle"`
+
+	// TeaserThrottle caps the bandwidth, in bits per second, of plans that
+	// expire with the downgrade action and keep serving as a teaser
+	TeaserThrottle int64 `mapstructure:"teaser_throttle"`
+
+	// TeaserPage answers plain HTTP requests of teaser and exhausted plans
+	// with a renewal notice instead of proxying them
+	TeaserPage TeaserPage `mapstructure:"teaser_page"`
+
+	// Payment charges auto-renewing plans; without a URL plans cannot be
+	// set to auto-renew
+	Payment Payment `mapstructure:"payment"`
+
+	// RenewRetries is how many times a failed renewal is retried, waiting
+	// RenewBackoff and doubling it after each failure
+	RenewRetries int           `mapstructure:"renew_retries"`
+	RenewBackoff time.Duration `mapstructure:"renew_backoff"`
+}
+
+// TeaserPage is served to teaser and exhausted plans' 3proxy instances as
+// the parent of plain HTTP requests. CONNECT tunnels are not affected.
+type TeaserPage struct {
+	// Listen is the local address the page is served on; empty disables it
+	Listen string `mapstructure:"listen"`
+	// Status is the response status. 407 asks the client for credentials
+	// again; any other status serves Message as a block page.
+	Status      int    `mapstructure:"status"`
+	ContentType string `mapstructure:"content_type"`
+	Message     string `mapstructure:"message"`
+}
+
+// Payment configures the billing integration renewals are charged through
+type Payment struct {
+	// URL receives each renewal charge as a JSON POST; a 2xx response
+	// means the customer was billed
+	URL     string        `mapstructure:"url"`
+	Token   string        `mapstructure:"token"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Usernames is the policy for proxy usernames of providers that take
+// caller-chosen credentials. Requested usernames must satisfy it; omitted
+// ones are generated as Prefix followed by Length random characters.
+type Usernames struct {
+	Prefix        string `mapstructure:"prefix"`
+	Length        int    `mapstructure:"length"`
+	MinLength     int    `mapstructure:"min_length"`
+	MaxLength     int    `mapstructure:"max_length"`
+	Charset       string `mapstructure:"charset"`
+	RequirePrefix bool   `mapstructure:"require_prefix"`
+}
+
+// Passwords is the strength policy for caller-chosen proxy passwords, and the
+// length of generated ones. MinEntropy is in bits, estimated from the
+// character classes used and the number of distinct characters.
+type Passwords struct {
+	MinLength  int     `mapstructure:"min_length"`
+	MinEntropy float64 `mapstructure:"min_entropy"`
+	Length     int     `mapstructure:"length"`
+}
+
+// UsernameCharsets maps usernames.charset names to their characters
+var UsernameCharsets = map[string]string{
+	"alphanumeric": "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+	"lowercase":    "abcdefghijklmnopqrstuvwxyz0123456789",
+}
+
+// Backup configures encrypted snapshots of the repository data and
+// generated proxy configs to S3-compatible object storage
+type Backup struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"`
+
+	// Endpoint is the S3 service URL, e.g. https://minio.internal:9000; empty uses AWS
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	Prefix    string `mapstructure:"prefix"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+
+	// PathStyle addresses the bucket in the URL path, as MinIO requires
+	PathStyle bool `mapstructure:"path_style"`
+
+	// EncryptionKey is a base64 32-byte AES-256 key; losing it makes backups unrecoverable
+	EncryptionKey string `mapstructure:"encryption_key"`
+
+	// Retention keeps the newest KeepLast snapshots and deletes any older
+	// than MaxAge; zero disables either rule
+	KeepLast int           `mapstructure:"keep_last"`
+	MaxAge   time.Duration `mapstructure:"max_age"`
+}
+
+// GitOps reconciles regions, plan types and plan manifests from a Git
+// repository
+type GitOps struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"`
+
+	// Repo is the URL or path git clones; Branch is the branch followed
+	Repo   string `mapstructure:"repo"`
+	Branch string `mapstructure:"branch"`
+
+	// CheckoutDir is where the repository is cloned and updated
+	CheckoutDir string `mapstructure:"checkout_dir"`
+
+	// RegionsFile, PlanTypesFile and PlansDir are paths inside the repository;
+	// an empty or missing path leaves that kind of resource unmanaged
+	RegionsFile   string `mapstructure:"regions_file"`
+	PlanTypesFile string `mapstructure:"plan_types_file"`
+	PlansDir      string `mapstructure:"plans_dir"`
+
+	// Prune deletes regions and plans and disables plan types that are
+	// missing from the repository; without it they are only reported
+	Prune bool `mapstructure:"prune"`
+
+	// DryRun reports the changes a sync would make without applying them
+	DryRun bool `mapstructure:"dry_run"`
+
+	// ReportFile, if set, receives a Markdown report of every sync
+	ReportFile string `mapstructure:"report_file"`
+
+	// GitTimeout bounds each git command
+	GitTimeout time.Duration `mapstructure:"git_timeout"`
 }
 
 // getenvTrimBraces resolves values like ${VAR} from environment
@@ -109,25 +1128,41 @@ func getenvTrimBraces(s string) string {
 // getenv wraps lookup to allow unit testing if needed
 func getenv(key string) string { return strings.TrimSpace(strings.ReplaceAll(viper.GetViper().GetString(key), "\n", "")) }
 
+// Options selects configuration sources beyond the defaults. Sources are
+// layered with increasing precedence: defaults, config file, the
+// config.<environment> profile, environment, then Overrides.
+type Options struct {
+	// ConfigFile is an explicit config file; ./configs and . are searched when empty
+	ConfigFile string
+
+	// Overrides are key=value settings, typically from -set flags
+	Overrides map[string]string
+}
+
+// Load reads configuration from the default locations and environment
 func Load() (*Config, error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath("./configs")
-	viper.AddConfigPath(".")
+	return LoadWithOptions(Options{})
+}
+
+// LoadWithOptions reads configuration using the layered sources in opts
+func LoadWithOptions(opts Options) (*Config, error) {
+	if opts.ConfigFile != "" {
+		viper.SetConfigFile(opts.ConfigFile)
+	} else {
+		viper.SetConfigName("config")
+		viper.SetConfigType("yaml")
+		viper.AddConfigPath("./configs")
+		viper.AddConfigPath(".")
+	}
 
 	// Set defaults
 	setDefaults()
 
-	// Read config file
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, fmt.Errorf("failed to read config file: %w", err)
-		}
-	}
-
-    // Override with environment variables
+    // Override with environment variables. Every key can be set with an
+    // OCEANPROXY_ prefixed variable; unprefixed names are still honoured.
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
+	bindPrefixedEnv()
 
     // Explicit env bindings for common keys used in .env
     // These allow using BEARER_TOKEN and PROXIES_FO_API_KEY, etc., without nested names
@@ -135,6 +1170,26 @@ func Load() (*Config, error) {
     _ = viper.BindEnv("auth.jwt_secret", "JWT_SECRET")
     _ = viper.BindEnv("providers.proxies_fo.api_key", "PROXIES_FO_API_KEY")
     _ = viper.BindEnv("providers.nettify.api_key", "NETTIFY_API_KEY")
+    _ = viper.BindEnv("server.tls.enabled", "TLS_ENABLED")
+    _ = viper.BindEnv("server.tls.cert_file", "TLS_CERT_FILE")
+    _ = viper.BindEnv("server.tls.key_file", "TLS_KEY_FILE")
+
+    // Flag overrides take precedence over every other source
+    for key, value := range opts.Overrides {
+        viper.Set(key, value)
+    }
+
+	// Read config file
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+
+	// Merge the profile for the selected environment, e.g. config.production.yaml
+	if _, err := mergeProfile(); err != nil {
+		return nil, fmt.Errorf("failed to merge %s profile: %w", viper.GetString("environment"), err)
+	}
 
     var cfg Config
     if err := viper.Unmarshal(&cfg); err != nil {
@@ -164,9 +1219,294 @@ func Load() (*Config, error) {
         }
     }
 
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
 	return &cfg, nil
 }
 
+// Validate checks settings that cannot be expressed as defaults
+func (c *Config) Validate() error {
+	for _, entry := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+			return fmt.Errorf("server.trusted_proxies: %q is not a valid IP or CIDR", entry)
+		}
+	}
+
+	for planType, id := range c.Providers.ProxiesFo.ResellerIDs {
+		if strings.TrimSpace(planType) == "" {
+			return fmt.Errorf("providers.proxies_fo.reseller_ids: empty plan type")
+		}
+		if _, err := uuid.Parse(id); err != nil {
+			return fmt.Errorf("providers.proxies_fo.reseller_ids.%s: %q is not a valid UUID", planType, id)
+		}
+	}
+
+	if err := c.Providers.ProxiesFo.HTTP.Validate("providers.proxies_fo.http"); err != nil {
+		return err
+	}
+	if err := c.Providers.Nettify.HTTP.Validate("providers.nettify.http"); err != nil {
+		return err
+	}
+	if err := validateHTTPProxy("providers.http_proxy", c.Providers.HTTPProxy, false); err != nil {
+		return err
+	}
+	if err := validateNoProxy("providers.no_proxy", c.Providers.NoProxy); err != nil {
+		return err
+	}
+	for name, override := range map[string]OutboundProxy{
+		"proxies_fo": {URL: c.Providers.ProxiesFo.HTTPProxy, NoProxy: c.Providers.ProxiesFo.NoProxy},
+		"nettify":    {URL: c.Providers.Nettify.HTTPProxy, NoProxy: c.Providers.Nettify.NoProxy},
+	} {
+		if err := validateHTTPProxy("providers."+name+".http_proxy", override.URL, true); err != nil {
+			return err
+		}
+		if err := validateNoProxy("providers."+name+".no_proxy", override.NoProxy); err != nil {
+			return err
+		}
+	}
+
+	if c.Metrics.CustomerEndpoint && c.Metrics.TokenSecret == "" {
+		return fmt.Errorf("metrics.token_secret is required when metrics.customer_endpoint is enabled")
+	}
+	if c.Metrics.LatencySampleRate < 0 || c.Metrics.LatencySampleRate > 1 {
+		return fmt.Errorf("metrics.latency_sample_rate must be between 0 and 1")
+	}
+	for i, bound := range c.Metrics.LatencyBuckets {
+		if bound <= 0 || i > 0 && bound <= c.Metrics.LatencyBuckets[i-1] {
+			return fmt.Errorf("metrics.latency_buckets must be positive and increasing")
+		}
+	}
+
+	if c.StatusPage.Enabled && (c.StatusPage.Refresh <= 0 || c.StatusPage.Window < time.Hour) {
+		return fmt.Errorf("status_page: refresh must be positive and window at least 1h")
+	}
+
+	if c.API.LegacySunset != "" {
+		if _, err := time.Parse("2006-01-02", c.API.LegacySunset); err != nil {
+			return fmt.Errorf("api.legacy_sunset must be a YYYY-MM-DD date: %w", err)
+		}
+	}
+
+	if c.SLA.Window < time.Hour {
+		return fmt.Errorf("sla.window must be at least 1h")
+	}
+	targets := map[string]float64{"region_target": c.SLA.RegionTarget, "plan_target": c.SLA.PlanTarget}
+	for region, target := range c.SLA.RegionTargets {
+		targets["region_targets."+region] = target
+	}
+	for name, target := range targets {
+		if target < 0 || target > 100 {
+			return fmt.Errorf("sla.%s: %v is not a percentage", name, target)
+		}
+	}
+
+	if c.DeadPlans.Window <= 0 || c.DeadPlans.CollectInterval < 0 {
+		return fmt.Errorf("dead_plans: window must be positive and collect_interval not negative")
+	}
+	if c.DeadPlans.FailureRatio <= 0 || c.DeadPlans.FailureRatio > 1 || c.DeadPlans.UsageRatio < 0 || c.DeadPlans.UsageRatio > 1 {
+		return fmt.Errorf("dead_plans: failure_ratio and usage_ratio must be between 0 and 1")
+	}
+
+	if c.Proxy.Binary.URL != "" && c.Proxy.Binary.SHA256 == "" {
+		return fmt.Errorf("proxy.binary.sha256 is required when proxy.binary.url is set")
+	}
+
+	if c.Updates.Auto && (c.Updates.ServerURL == "" || c.Updates.PublicKey == "") {
+		return fmt.Errorf("updates.server_url and updates.public_key are required when updates.auto is enabled")
+	}
+
+	if c.Backup.Enabled && (c.Backup.Bucket == "" || c.Backup.EncryptionKey == "") {
+		return fmt.Errorf("backup.bucket and backup.encryption_key are required when backup is enabled")
+	}
+
+	if c.GitOps.Enabled && (c.GitOps.Repo == "" || c.GitOps.CheckoutDir == "") {
+		return fmt.Errorf("gitops.repo and gitops.checkout_dir are required when gitops is enabled")
+	}
+
+	if c.Proxy.Activation.Interval < 0 || c.Proxy.Activation.Retries < 0 || c.Proxy.Activation.Backoff < 0 {
+		return fmt.Errorf("proxy.activation: interval, retries and backoff must not be negative")
+	}
+
+	if c.Proxy.Cache.MaxTTL < time.Second || c.Proxy.Cache.MaxSizeMB <= 0 {
+		return fmt.Errorf("proxy.cache: max_ttl must be at least 1s and max_size_mb positive")
+	}
+
+	if sampling := c.Proxy.DebugSampling; sampling.Interval < 0 || sampling.MaxWindow < time.Minute || sampling.MaxSamples <= 0 {
+		return fmt.Errorf("proxy.debug_sampling: interval must not be negative, max_window must be at least 1m and max_samples positive")
+	}
+
+	if temp := c.Proxy.TempCredentials; temp.Interval <= 0 || temp.MaxTTL < time.Minute || temp.MaxPerPlan <= 0 {
+		return fmt.Errorf("proxy.temp_credentials: interval must be positive, max_ttl at least 1m and max_per_plan positive")
+	}
+
+	if sub := c.Proxy.SubUsers; sub.Interval < 0 || sub.MaxPerPlan <= 0 {
+		return fmt.Errorf("proxy.subusers: interval must not be negative and max_per_plan must be positive")
+	}
+
+	if records := c.Proxy.DNSRecords; records.File != "" && (records.Target == "" || records.TTL <= 0) {
+		return fmt.Errorf("proxy.dns_records: target and a positive ttl are required when file is set")
+	}
+
+	if c.Billing.GracePeriod < 0 || c.Billing.GraceThrottle < 0 {
+		return fmt.Errorf("billing.grace_period and billing.grace_throttle must not be negative")
+	}
+
+	if c.Billing.TeaserThrottle <= 0 {
+		return fmt.Errorf("billing.teaser_throttle must be positive")
+	}
+
+	if page := c.Billing.TeaserPage; page.Listen != "" {
+		if _, _, err := net.SplitHostPort(page.Listen); err != nil {
+			return fmt.Errorf("billing.teaser_page.listen: %w", err)
+		}
+		if page.Status < 200 || page.Status > 599 {
+			return fmt.Errorf("billing.teaser_page.status must be an HTTP status code")
+		}
+	}
+
+	if c.Billing.RenewRetries < 0 || c.Billing.RenewBackoff < 0 {
+		return fmt.Errorf("billing.renew_retries and billing.renew_backoff must not be negative")
+	}
+
+	if c.Billing.Timezone != "" {
+		if _, err := time.LoadLocation(c.Billing.Timezone); err != nil {
+			return fmt.Errorf("billing.timezone: unknown time zone %q", c.Billing.Timezone)
+		}
+	}
+
+	if err := c.Usernames.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Notifications.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Reports.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Alerting.validate(); err != nil {
+		return err
+	}
+	if err := c.Maintenance.validate(); err != nil {
+		return err
+	}
+
+	switch c.Database.Driver {
+	case "", DatabaseDriverJSON:
+	case DatabaseDriverPostgres:
+		if c.Database.DataFile == "" {
+			return fmt.Errorf("database.driver postgres requires database.data_file")
+		}
+	default:
+		return fmt.Errorf("database.driver must be json or postgres")
+	}
+
+	switch c.PortAllocation.Backend {
+	case "", PortAllocationMemory:
+	case PortAllocationRedis:
+		if c.Redis.Addr == "" {
+			return fmt.Errorf("port_allocation.backend redis requires redis.addr")
+		}
+	default:
+		return fmt.Errorf("port_allocation.backend must be memory or redis")
+	}
+
+	if c.Slack.Enabled {
+		if c.Slack.SigningSecret == "" || c.Slack.MaxSkew <= 0 {
+			return fmt.Errorf("slack: signing_secret and a positive max_skew are required when slack is enabled")
+		}
+		for role := range c.Slack.Roles {
+			if role != SlackRoleViewer && role != SlackRoleOperator {
+				return fmt.Errorf("slack.roles: %q must be viewer or operator", role)
+			}
+		}
+	}
+
+	if c.Passwords.MinLength < 1 || c.Passwords.Length < c.Passwords.MinLength {
+		return fmt.Errorf("passwords.min_length must be positive and passwords.length at least passwords.min_length")
+	}
+	if c.Passwords.MinEntropy < 0 {
+		return fmt.Errorf("passwords.min_entropy must not be negative")
+	}
+
+	if guard := c.Proxy.AuthGuard; guard.Enabled {
+		if guard.Interval <= 0 || guard.Window <= 0 || guard.BlockFor <= 0 || guard.MaxFailures < 1 {
+			return fmt.Errorf("proxy.auth_guard: interval, window, block_for and max_failures must be positive")
+		}
+		switch guard.Action {
+		case "command":
+			if len(guard.BlockCommand) == 0 || len(guard.UnblockCommand) == 0 {
+				return fmt.Errorf("proxy.auth_guard: block_command and unblock_command are required for action command")
+			}
+		case "acl":
+		default:
+			return fmt.Errorf("proxy.auth_guard.action: %q must be command or acl", guard.Action)
+		}
+		for _, ip := range guard.IgnoreIPs {
+			if net.ParseIP(ip) == nil {
+				return fmt.Errorf("proxy.auth_guard.ignore_ips: %q is not an IP address", ip)
+			}
+		}
+	}
+
+	if doh := c.Proxy.DoH; net.ParseIP(doh.ListenHost) == nil || doh.PortStart < 1 || doh.PortStart > 65535 || doh.Timeout <= 0 {
+		return fmt.Errorf("proxy.doh: listen_host must be an IP address, port_start a port and timeout positive")
+	}
+
+	if c.Stats.RawRetention > 0 && c.Stats.HourlyRetention > 0 && c.Stats.HourlyRetention < c.Stats.RawRetention {
+		return fmt.Errorf("stats.hourly_retention must not be shorter than stats.raw_retention")
+	}
+	if c.Stats.CollectInterval < 0 {
+		return fmt.Errorf("stats.collect_interval must not be negative")
+	}
+	if c.Stats.GeoIPDatabase != "" && c.Stats.CollectInterval == 0 {
+		return fmt.Errorf("stats.geoip_database requires stats.collect_interval")
+	}
+	if c.Stats.EnforceBandwidth && c.Stats.CollectInterval == 0 {
+		return fmt.Errorf("stats.enforce_bandwidth requires stats.collect_interval")
+	}
+
+	if c.Server.CompressionLevel < 0 || c.Server.CompressionLevel > 9 {
+		return fmt.Errorf("server.compression_level must be between 0 and 9")
+	}
+
+	switch c.GeoCheck.Remediation {
+	case "", "alert", "reset_upstream":
+	default:
+		return fmt.Errorf("geo_check.remediation: %q must be alert or reset_upstream", c.GeoCheck.Remediation)
+	}
+
+	return nil
+}
+
+// validate checks that the username policy can be satisfied, including by
+// the usernames it generates itself
+func (u Usernames) validate() error {
+	charset, ok := UsernameCharsets[u.Charset]
+	if !ok {
+		return fmt.Errorf("usernames.charset: %q must be alphanumeric or lowercase", u.Charset)
+	}
+	if u.MinLength < 1 || u.MaxLength < u.MinLength {
+		return fmt.Errorf("usernames.min_length must be positive and not above usernames.max_length")
+	}
+	if u.Length < 1 {
+		return fmt.Errorf("usernames.length must be positive")
+	}
+	if generated := len(u.Prefix) + u.Length; generated < u.MinLength || generated > u.MaxLength {
+		return fmt.Errorf("usernames.prefix plus usernames.length must be between usernames.min_length and usernames.max_length")
+	}
+	for _, r := range u.Prefix {
+		if !strings.ContainsRune(charset, r) {
+			return fmt.Errorf("usernames.prefix: %q has characters outside the %s charset", u.Prefix, u.Charset)
+		}
+	}
+	return nil
+}
+
 func setDefaults() {
 	// Server defaults
 	viper.SetDefault("server.port", 8080)
@@ -174,19 +1514,37 @@ func setDefaults() {
 	viper.SetDefault("server.read_timeout", "30s")
 	viper.SetDefault("server.write_timeout", "30s")
 	viper.SetDefault("server.shutdown_timeout", "30s")
+	viper.SetDefault("server.compression_level", 5)
+	viper.SetDefault("api.legacy_routes", true)
+	viper.SetDefault("api.legacy_sunset", "")
+	viper.SetDefault("server.max_body_bytes", 1<<20)
+	viper.SetDefault("server.read_only", false)
+	viper.SetDefault("server.rate_limit.create", 10)
+	viper.SetDefault("server.rate_limit.write", 60)
+	viper.SetDefault("server.rate_limit.read", 600)
+	viper.SetDefault("server.rate_limit.public", 120)
 
 	// CORS defaults
 	viper.SetDefault("server.cors.allow_origins", []string{"*"})
 	viper.SetDefault("server.cors.allow_methods", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
 	viper.SetDefault("server.cors.allow_headers", []string{"*"})
 	viper.SetDefault("server.cors.allow_credentials", true)
+	viper.SetDefault("server.cors.max_age", "10m")
+
+	// TLS defaults
+	viper.SetDefault("server.tls.enabled", false)
+	viper.SetDefault("server.tls.redirect_http", false)
+	viper.SetDefault("server.tls.http_port", 80)
 
 	// Database defaults
 	viper.SetDefault("database.driver", "json")
+	viper.SetDefault("port_allocation.backend", "memory")
+	viper.SetDefault("port_allocation.key_prefix", "oceanproxy:")
 	viper.SetDefault("database.dsn", "/var/lib/oceanproxy/data/proxies.json") // ADD THIS LINE
 	viper.SetDefault("database.max_open_conns", 25)
 	viper.SetDefault("database.max_idle_conns", 25)
 	viper.SetDefault("database.conn_max_lifetime", "5m")
+	viper.SetDefault("database.data_file", "/var/lib/oceanproxy/data/proxies.json")
 
 	// Logger defaults
 	viper.SetDefault("logger.level", "info")
@@ -198,8 +1556,22 @@ func setDefaults() {
 	// Provider defaults
 	viper.SetDefault("providers.proxies_fo.base_url", "https://app.proxies.fo")
 	viper.SetDefault("providers.proxies_fo.timeout", "30s")
+	viper.SetDefault("providers.proxies_fo.reseller_ids", map[string]string{
+		"residential": "7c9ea873-63f9-4013-9147-3807cc6f0553",
+		"isp":         "3471aa35-7922-488a-a7a9-b92a5510080e",
+		"datacenter":  "b3fd0f3c-693d-4ec5-b49f-c77feaab0b72",
+	})
 	viper.SetDefault("providers.nettify.base_url", "https://api.nettify.xyz")
 	viper.SetDefault("providers.nettify.timeout", "30s")
+	for _, provider := range []string{"proxies_fo", "nettify"} {
+		viper.SetDefault("providers."+provider+".http.max_idle_conns", 100)
+		viper.SetDefault("providers."+provider+".http.max_idle_conns_per_host", 32)
+		viper.SetDefault("providers."+provider+".http.max_conns_per_host", 64)
+		viper.SetDefault("providers."+provider+".http.idle_conn_timeout", "90s")
+		viper.SetDefault("providers."+provider+".http.keep_alive", "30s")
+		viper.SetDefault("providers."+provider+".http.dial_timeout", "10s")
+		viper.SetDefault("providers."+provider+".http.tls_handshake_timeout", "10s")
+	}
 
 	// Proxy defaults
 	viper.SetDefault("proxy.domain", "oceanproxy.io")
@@ -209,6 +1581,151 @@ func setDefaults() {
 	viper.SetDefault("proxy.log_dir", "/var/log/oceanproxy")
 	viper.SetDefault("proxy.script_dir", "./scripts")
 	viper.SetDefault("proxy.nginx_conf_dir", "/etc/nginx/conf.d")
+	viper.SetDefault("proxy.cgroup_root", "/sys/fs/cgroup/oceanproxy")
+	viper.SetDefault("proxy.upstream_probe_interval", "30s")
+	viper.SetDefault("proxy.upstream_probe_timeout", "3s")
+	viper.SetDefault("proxy.health_check_interval", "0s")
+	viper.SetDefault("proxy.health_check_timeout", "15s")
+	viper.SetDefault("proxy.health_check_failure_threshold", 3)
+	viper.SetDefault("proxy.health_check_success_threshold", 1)
+	viper.SetDefault("proxy.health_check_workers", 16)
+	viper.SetDefault("proxy.binary.managed_dir", "/var/lib/oceanproxy/bin")
+	viper.SetDefault("proxy.auth_guard.enabled", false)
+	viper.SetDefault("proxy.auth_guard.action", "command")
+	viper.SetDefault("proxy.auth_guard.interval", "15s")
+	viper.SetDefault("proxy.auth_guard.max_failures", 10)
+	viper.SetDefault("proxy.auth_guard.window", "10m")
+	viper.SetDefault("proxy.auth_guard.block_for", "1h")
+	viper.SetDefault("proxy.auth_guard.block_command", []string{"iptables", "-I", "INPUT", "-s", "{ip}", "-p", "tcp", "--dport", "{port}", "-j", "DROP"})
+	viper.SetDefault("proxy.auth_guard.unblock_command", []string{"iptables", "-D", "INPUT", "-s", "{ip}", "-p", "tcp", "--dport", "{port}", "-j", "DROP"})
+	viper.SetDefault("proxy.doh.listen_host", "127.0.0.1")
+	viper.SetDefault("proxy.doh.port_start", 5300)
+	viper.SetDefault("proxy.doh.timeout", "5s")
+	viper.SetDefault("proxy.activation.interval", "15s")
+	viper.SetDefault("proxy.activation.retries", 5)
+	viper.SetDefault("proxy.activation.backoff", "30s")
+	viper.SetDefault("proxy.cache.max_ttl", "1h")
+	viper.SetDefault("proxy.cache.max_size_mb", 1024)
+	viper.SetDefault("proxy.debug_sampling.interval", "10s")
+	viper.SetDefault("proxy.debug_sampling.max_window", "1h")
+	viper.SetDefault("proxy.debug_sampling.max_samples", 500)
+	viper.SetDefault("proxy.temp_credentials.interval", "1m")
+	viper.SetDefault("proxy.temp_credentials.max_ttl", "24h")
+	viper.SetDefault("proxy.temp_credentials.max_per_plan", 10)
+	viper.SetDefault("proxy.subusers.interval", "30s")
+	viper.SetDefault("proxy.subusers.max_per_plan", 50)
+	viper.SetDefault("proxy.dns_records.file", "")
+	viper.SetDefault("proxy.dns_records.target", "")
+	viper.SetDefault("proxy.dns_records.ttl", 300)
+
+	// Geo check defaults
+	viper.SetDefault("geo_check.enabled", false)
+	viper.SetDefault("geo_check.lookup_url", "http://ip-api.com/json/?fields=status,countryCode,query")
+	viper.SetDefault("geo_check.interval", "6h")
+	viper.SetDefault("geo_check.timeout", "15s")
+	viper.SetDefault("geo_check.remediation", "alert")
+
+	// Notification defaults
+	viper.SetDefault("notifications.timeout", "10s")
+	viper.SetDefault("notifications.telegram.api_url", "https://api.telegram.org")
+	viper.SetDefault("notifications.discord.api_url", "https://discord.com/api/v10")
+	viper.SetDefault("notifications.crash_loop_threshold", 3)
+	viper.SetDefault("notifications.crash_loop_window", "10m")
+
+	// Metrics defaults
+	viper.SetDefault("metrics.latency_sample_rate", 0.1)
+	viper.SetDefault("metrics.latency_buckets", []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10})
+
+	// Stats defaults: 7 days raw, 90 days hourly, daily forever
+	viper.SetDefault("status_page.enabled", false)
+	viper.SetDefault("status_page.title", "OceanProxy Status")
+	viper.SetDefault("status_page.refresh", "1m")
+	viper.SetDefault("status_page.window", "720h")
+	viper.SetDefault("alerting.interval", "1m")
+	viper.SetDefault("alerting.pagerduty.url", "https://events.pagerduty.com/v2/enqueue")
+	viper.SetDefault("alerting.opsgenie.url", "https://api.opsgenie.com")
+	viper.SetDefault("alerting.severities.region_down", "critical")
+	viper.SetDefault("alerting.severities.provider_outage", "critical")
+	viper.SetDefault("alerting.severities.port_pool_exhausted", "error")
+	viper.SetDefault("alerting.routes.critical", []string{"pagerduty", "opsgenie"})
+	viper.SetDefault("alerting.routes.error", []string{"opsgenie"})
+	viper.SetDefault("maintenance.fetch_interval", "15m")
+	viper.SetDefault("maintenance.retention", "720h")
+	viper.SetDefault("slack.enabled", false)
+	viper.SetDefault("slack.max_skew", "5m")
+	viper.SetDefault("sla.region_target", 0)
+	viper.SetDefault("sla.plan_target", 0)
+	viper.SetDefault("sla.window", "720h")
+	viper.SetDefault("sla.check_interval", "1h")
+	viper.SetDefault("dead_plans.collect_interval", "1m")
+	viper.SetDefault("dead_plans.window", "1h")
+	viper.SetDefault("dead_plans.min_requests", 20)
+	viper.SetDefault("dead_plans.failure_ratio", 0.5)
+	viper.SetDefault("dead_plans.min_bytes", 104857600)
+	viper.SetDefault("dead_plans.usage_ratio", 0.1)
+	viper.SetDefault("stats.raw_retention", "168h")
+	viper.SetDefault("stats.hourly_retention", "2160h")
+	viper.SetDefault("stats.rollup_interval", "5m")
+	viper.SetDefault("stats.collect_interval", "1m")
+	viper.SetDefault("stats.geoip_database", "")
+	viper.SetDefault("stats.enforce_bandwidth", false)
+	viper.SetDefault("reports.weekday", "")
+	viper.SetDefault("reports.hour", 8)
+	viper.SetDefault("reports.top_customers", 10)
+	viper.SetDefault("reports.timeout", "30s")
+
+	// Update defaults
+	viper.SetDefault("updates.channel", "stable")
+	viper.SetDefault("updates.auto", false)
+	viper.SetDefault("updates.check_interval", "6h")
+
+	// Timeout defaults
+	viper.SetDefault("timeouts.request", "60s")
+	viper.SetDefault("timeouts.nginx_reload", "30s")
+	viper.SetDefault("timeouts.exec", "10s")
+	viper.SetDefault("billing.timezone", "")
+	viper.SetDefault("billing.expiry_interval", "1m")
+	viper.SetDefault("billing.grace_period", "0s")
+	viper.SetDefault("billing.grace_throttle", 0)
+	viper.SetDefault("billing.teaser_throttle", 64000)
+	viper.SetDefault("billing.teaser_page.listen", "")
+	viper.SetDefault("billing.teaser_page.status", 407)
+	viper.SetDefault("billing.teaser_page.content_type", "text/plain; charset=utf-8")
+	viper.SetDefault("billing.teaser_page.message", "Your proxy plan has expired or run out of bandwidth. Renew or top it up to restore full service.\n")
+	viper.SetDefault("usernames.prefix", "op")
+	viper.SetDefault("usernames.length", 10)
+	viper.SetDefault("usernames.min_length", 4)
+	viper.SetDefault("usernames.max_length", 32)
+	viper.SetDefault("usernames.charset", "alphanumeric")
+	viper.SetDefault("usernames.require_prefix", false)
+	viper.SetDefault("passwords.min_length", 12)
+	viper.SetDefault("passwords.min_entropy", 60)
+	viper.SetDefault("passwords.length", 16)
+	viper.SetDefault("billing.payment.url", "")
+	viper.SetDefault("billing.payment.token", "")
+	viper.SetDefault("billing.payment.timeout", "30s")
+	viper.SetDefault("billing.renew_retries", 3)
+	viper.SetDefault("billing.renew_backoff", "1h")
+
+	// Backup defaults
+	viper.SetDefault("backup.enabled", false)
+	viper.SetDefault("backup.interval", "24h")
+	viper.SetDefault("backup.region", "us-east-1")
+	viper.SetDefault("backup.prefix", "oceanproxy/")
+	viper.SetDefault("backup.keep_last", 14)
+	viper.SetDefault("backup.max_age", "0s")
+
+	// GitOps defaults
+	viper.SetDefault("gitops.enabled", false)
+	viper.SetDefault("gitops.interval", "5m")
+	viper.SetDefault("gitops.branch", "main")
+	viper.SetDefault("gitops.checkout_dir", "/var/lib/oceanproxy/gitops")
+	viper.SetDefault("gitops.regions_file", "regions.yaml")
+	viper.SetDefault("gitops.plan_types_file", "proxy-plans.yaml")
+	viper.SetDefault("gitops.plans_dir", "plans")
+	viper.SetDefault("gitops.prune", false)
+	viper.SetDefault("gitops.dry_run", false)
+	viper.SetDefault("gitops.git_timeout", "2m")
 
 	// Environment
 	viper.SetDefault("environment", "development")
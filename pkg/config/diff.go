@@ -0,0 +1,59 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Change is a key whose value differs between two configurations. Secret
+// values are redacted as in Effective.
+type Change struct {
+	Key  string      `json:"key"`
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Diff returns the keys whose values differ from old to new, sorted by key
+func Diff(old, new *Config) []Change {
+	before := flattenKeys(effectiveSection(reflect.ValueOf(*old), false), "")
+	after := flattenKeys(effectiveSection(reflect.ValueOf(*new), false), "")
+	beforeShown := flattenKeys(Effective(old), "")
+	afterShown := flattenKeys(Effective(new), "")
+
+	keys := make(map[string]bool, len(after))
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	changes := []Change{}
+	for key := range keys {
+		if reflect.DeepEqual(before[key], after[key]) {
+			continue
+		}
+		changes = append(changes, Change{Key: key, From: beforeShown[key], To: afterShown[key]})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes
+}
+
+// flattenKeys turns a nested Effective map into dotted keys
+func flattenKeys(section map[string]interface{}, prefix string) map[string]interface{} {
+	out := make(map[string]interface{})
+	for name, value := range section {
+		key := joinKey(prefix, name)
+		if inner, ok := value.(map[string]interface{}); ok {
+			for k, v := range flattenKeys(inner, key) {
+				out[k] = v
+			}
+			continue
+		}
+		out[key] = value
+	}
+	return out
+}
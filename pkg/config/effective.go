@@ -0,0 +1,187 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix prefixes the environment variable for every config key, e.g.
+// OCEANPROXY_SERVER_PORT for server.port
+const EnvPrefix = "OCEANPROXY"
+
+// redactedValue replaces secret values in Effective output
+const redactedValue = "[REDACTED]"
+
+// secretKeys lists the final key segments that always hold secrets
+var secretKeys = map[string]bool{
+	"api_key":        true,
+	"bearer_token":   true,
+	"bot_token":      true,
+	"bypass_token":   true,
+	"encryption_key": true,
+	"jwt_secret":     true,
+	"password":       true,
+	"routing_key":    true,
+	"secret_key":     true,
+	"signing_secret": true,
+	"token":          true,
+	"token_secret":   true,
+	"webhook_url":    true,
+}
+
+// credentialURLKeys lists the final key segments of URLs that may carry
+// credentials; they are redacted when they do
+var credentialURLKeys = map[string]bool{
+	"dsn":        true,
+	"http_proxy": true,
+	"repo":       true,
+}
+
+// profilePath returns the profile file for env next to base, e.g.
+// configs/config.yaml -> configs/config.production.yaml
+func profilePath(base, env string) string {
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "." + env + ext
+}
+
+// mergeProfile merges config.<environment>.<ext> over the base config file
+// when it exists. It returns the merged file path, or "" when none was found.
+func mergeProfile() (string, error) {
+	base := viper.ConfigFileUsed()
+	env := viper.GetString("environment")
+	if base == "" || env == "" {
+		return "", nil
+	}
+
+	path := profilePath(base, env)
+	if _, err := os.Stat(path); err != nil {
+		return "", nil
+	}
+
+	viper.SetConfigFile(path)
+	if err := viper.MergeInConfig(); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// bindPrefixedEnv binds OCEANPROXY_<KEY> for every key in Config
+func bindPrefixedEnv() {
+	for _, key := range Keys() {
+		name := EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		_ = viper.BindEnv(key, name)
+	}
+}
+
+// Keys returns the dotted key of every leaf setting in Config
+func Keys() []string {
+	var keys []string
+	walkKeys(reflect.TypeOf(Config{}), "", &keys)
+	return keys
+}
+
+func walkKeys(t reflect.Type, prefix string, keys *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, squash := fieldKey(field)
+		if name == "" && !squash {
+			continue
+		}
+
+		key := prefix
+		if !squash {
+			key = joinKey(prefix, name)
+		}
+
+		if isSection(field.Type) {
+			walkKeys(field.Type, key, keys)
+			continue
+		}
+		*keys = append(*keys, key)
+	}
+}
+
+// Effective returns cfg as a nested map keyed like the config file, with
+// secret values replaced so it is safe to expose for debugging
+func Effective(cfg *Config) map[string]interface{} {
+	return effectiveSection(reflect.ValueOf(*cfg), true)
+}
+
+func effectiveSection(v reflect.Value, redact bool) map[string]interface{} {
+	out := make(map[string]interface{})
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, squash := fieldKey(field)
+		if name == "" && !squash {
+			continue
+		}
+
+		value := v.Field(i)
+		if squash {
+			for k, inner := range effectiveSection(value, redact) {
+				out[k] = inner
+			}
+			continue
+		}
+
+		switch {
+		case isSection(field.Type):
+			out[name] = effectiveSection(value, redact)
+		case redact && (secretKeys[name] || (credentialURLKeys[name] && strings.Contains(value.String(), "@"))):
+			if value.String() != "" {
+				out[name] = redactedValue
+			} else {
+				out[name] = ""
+			}
+		default:
+			out[name] = effectiveValue(value, redact)
+		}
+	}
+	return out
+}
+
+func effectiveValue(v reflect.Value, redact bool) interface{} {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct {
+		items := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items[i] = effectiveSection(v.Index(i), redact)
+		}
+		return items
+	}
+	return v.Interface()
+}
+
+// fieldKey returns the mapstructure key of a field and whether it is squashed
+func fieldKey(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("mapstructure")
+	if tag == "" || tag == "-" {
+		return "", false
+	}
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "squash" {
+			return "", true
+		}
+	}
+	return parts[0], false
+}
+
+func isSection(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OverrideFlag collects repeated -set key=value flags
+type OverrideFlag map[string]string
+
+// String implements flag.Value
+func (o OverrideFlag) String() string {
+	pairs := make([]string, 0, len(o))
+	for key, value := range o {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set implements flag.Value
+func (o OverrideFlag) Set(v string) error {
+	key, value, ok := strings.Cut(v, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", v)
+	}
+	o[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	return nil
+}
+
+// RegisterFlags adds the shared -config and -set flags to fs and returns
+// the Options they populate once fs is parsed
+func RegisterFlags(fs *flag.FlagSet) *Options {
+	opts := &Options{Overrides: OverrideFlag{}}
+	fs.StringVar(&opts.ConfigFile, "config", "", "Path to config file (default: ./configs/config.yaml)")
+	fs.Var(OverrideFlag(opts.Overrides), "set", "Override a config key, e.g. -set server.port=9090 (repeatable)")
+	return opts
+}
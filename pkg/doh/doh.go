@@ -0,0 +1,223 @@
+// Package doh forwards plain DNS queries to DNS-over-HTTPS resolvers
+// (RFC 8484), so programs that only speak classic DNS, like 3proxy, can
+// resolve names without using the host's resolver.
+package doh
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	contentType = "application/dns-message"
+
+	// maxMessageSize is the largest DNS message accepted in either direction
+	maxMessageSize = 65535
+
+	// headerSize is the length of a DNS message header
+	headerSize = 12
+)
+
+// Forwarder relays DNS queries to DoH resolvers, trying them in order
+type Forwarder struct {
+	upstreams []string
+	client    *http.Client
+}
+
+// NewForwarder creates a forwarder for the given https:// resolver URLs,
+// such as https://cloudflare-dns.com/dns-query
+func NewForwarder(upstreams []string, timeout time.Duration) (*Forwarder, error) {
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("no DoH resolvers given")
+	}
+	for _, upstream := range upstreams {
+		if err := ValidateURL(upstream); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Forwarder{
+		upstreams: upstreams,
+		client:    &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// ValidateURL checks that raw is usable as a DoH resolver URL
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid DoH resolver %q: must be an https:// URL", raw)
+	}
+	return nil
+}
+
+// Exchange sends a DNS query in wire format and returns the response. The
+// query ID is zeroed on the wire, as RFC 8484 recommends for cacheability,
+// and restored in the response.
+func (f *Forwarder) Exchange(ctx context.Context, query []byte) ([]byte, error) {
+	if len(query) < headerSize {
+		return nil, fmt.Errorf("DNS query too short")
+	}
+
+	id := binary.BigEndian.Uint16(query)
+	wire := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(wire, 0)
+
+	var errs []error
+	for _, upstream := range f.upstreams {
+		response, err := f.post(ctx, upstream, wire)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		binary.BigEndian.PutUint16(response, id)
+		return response, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (f *Forwarder) post(ctx context.Context, upstream string, query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, upstream, bytes.NewReader(query))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DoH request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", contentType)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("DoH request to %s failed: %w", upstream, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH resolver %s returned status %d", upstream, resp.StatusCode)
+	}
+
+	response, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DoH response from %s: %w", upstream, err)
+	}
+	if len(response) < headerSize || len(response) > maxMessageSize {
+		return nil, fmt.Errorf("DoH resolver %s returned an invalid DNS message", upstream)
+	}
+	return response, nil
+}
+
+// Listen binds addr on both UDP and TCP and serves queries until ctx is
+// cancelled. Binding happens before Listen returns, so a port already in
+// use is reported to the caller; errors while serving go to onError.
+func (f *Forwarder) Listen(ctx context.Context, addr string, onError func(error)) error {
+	packetConn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on udp %s: %w", addr, err)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		packetConn.Close()
+		return fmt.Errorf("failed to listen on tcp %s: %w", addr, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		packetConn.Close()
+		listener.Close()
+	}()
+	go f.serveUDP(ctx, packetConn, onError)
+	go f.serveTCP(ctx, listener, onError)
+
+	return nil
+}
+
+func (f *Forwarder) serveUDP(ctx context.Context, conn net.PacketConn, onError func(error)) {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				onError(fmt.Errorf("failed to read DNS query: %w", err))
+			}
+			return
+		}
+
+		query := append([]byte(nil), buf[:n]...)
+		go func() {
+			response, err := f.Exchange(ctx, query)
+			if err != nil {
+				onError(err)
+				response = serverFailure(query)
+			}
+			if response != nil {
+				conn.WriteTo(response, addr)
+			}
+		}()
+	}
+}
+
+func (f *Forwarder) serveTCP(ctx context.Context, listener net.Listener, onError func(error)) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				onError(fmt.Errorf("failed to accept DNS connection: %w", err))
+			}
+			return
+		}
+		go f.serveConn(ctx, conn, onError)
+	}
+}
+
+// serveConn answers length-prefixed queries on a TCP connection in order
+func (f *Forwarder) serveConn(ctx context.Context, conn net.Conn, onError func(error)) {
+	defer conn.Close()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+		query := make([]byte, length)
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+
+		response, err := f.Exchange(ctx, query)
+		if err != nil {
+			onError(err)
+			if response = serverFailure(query); response == nil {
+				return
+			}
+		}
+
+		frame := make([]byte, 2+len(response))
+		binary.BigEndian.PutUint16(frame, uint16(len(response)))
+		copy(frame[2:], response)
+		if _, err := conn.Write(frame); err != nil {
+			return
+		}
+	}
+}
+
+// serverFailure returns a SERVFAIL response to query, or nil if query is
+// too short to answer
+func serverFailure(query []byte) []byte {
+	if len(query) < headerSize {
+		return nil
+	}
+
+	response := append([]byte(nil), query...)
+	// QR set, opcode and RD kept; RA set, RCODE 2 (SERVFAIL)
+	response[2] = 0x80 | query[2]&0x79
+	response[3] = 0x80 | 0x02
+	return response
+}
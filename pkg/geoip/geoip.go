@@ -0,0 +1,398 @@
+// Package geoip is a minimal reader for MaxMind DB files (GeoLite2/GeoIP2
+// Country and City databases) that resolves IP addresses to ISO 3166-1
+// country codes.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// metadataMarker precedes the metadata map at the end of the file
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparator is the number of zero bytes between the search tree
+// and the data section
+const dataSectionSeparator = 16
+
+// MaxMind DB data types
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// Metadata describes a database
+type Metadata struct {
+	DatabaseType string
+	BuildEpoch   uint64
+	IPVersion    int
+	NodeCount    int
+	RecordSize   int
+}
+
+// Reader looks up countries in a MaxMind DB loaded into memory. It is safe
+// for concurrent use.
+type Reader struct {
+	metadata  Metadata
+	tree      []byte
+	data      []byte
+	ipv4Start int
+}
+
+// Open loads the database at path
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GeoIP database: %w", err)
+	}
+
+	reader, err := FromBytes(buf)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GeoIP database %s: %w", path, err)
+	}
+	return reader, nil
+}
+
+// FromBytes parses a database held in memory
+func FromBytes(buf []byte) (*Reader, error) {
+	marker := bytes.LastIndex(buf, metadataMarker)
+	if marker < 0 {
+		return nil, fmt.Errorf("metadata not found")
+	}
+
+	metaSection := buf[marker+len(metadataMarker):]
+	raw, _, err := decoder{data: metaSection}.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	fields, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("metadata is not a map")
+	}
+
+	metadata := Metadata{
+		DatabaseType: stringField(fields, "database_type"),
+		BuildEpoch:   uintField(fields, "build_epoch"),
+		IPVersion:    int(uintField(fields, "ip_version")),
+		NodeCount:    int(uintField(fields, "node_count")),
+		RecordSize:   int(uintField(fields, "record_size")),
+	}
+	switch metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", metadata.RecordSize)
+	}
+	if metadata.IPVersion != 4 && metadata.IPVersion != 6 {
+		return nil, fmt.Errorf("unsupported IP version %d", metadata.IPVersion)
+	}
+
+	treeSize := metadata.NodeCount * metadata.RecordSize / 4
+	if treeSize+dataSectionSeparator > marker {
+		return nil, fmt.Errorf("search tree exceeds file size")
+	}
+
+	r := &Reader{
+		metadata: metadata,
+		tree:     buf[:treeSize],
+		data:     buf[treeSize+dataSectionSeparator : marker],
+	}
+
+	// IPv4 addresses live under ::/96 in IPv6 databases
+	if metadata.IPVersion == 6 {
+		node := 0
+		for i := 0; i < 96 && node < metadata.NodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// Metadata returns the database's metadata
+func (r *Reader) Metadata() Metadata {
+	return r.metadata
+}
+
+// Country returns the ISO country code of ip, or "" if the database has no
+// country for it. Addresses without a country of their own, such as anycast
+// networks, fall back to the country they are registered in.
+func (r *Reader) Country(ip net.IP) (string, error) {
+	offset, found, err := r.lookup(ip)
+	if err != nil || !found {
+		return "", err
+	}
+
+	raw, _, err := decoder{data: r.data}.decode(offset, 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode record: %w", err)
+	}
+	record, _ := raw.(map[string]interface{})
+
+	for _, key := range []string{"country", "registered_country"} {
+		if country, ok := record[key].(map[string]interface{}); ok {
+			if code := stringField(country, "iso_code"); code != "" {
+				return code, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// lookup walks the search tree and returns the data section offset of ip's
+// record
+func (r *Reader) lookup(ip net.IP) (int, bool, error) {
+	bits := ip.To4()
+	node := 0
+	if bits != nil {
+		node = r.ipv4Start
+	} else {
+		if bits = ip.To16(); bits == nil {
+			return 0, false, fmt.Errorf("invalid IP address")
+		}
+		if r.metadata.IPVersion == 4 {
+			return 0, false, fmt.Errorf("cannot look up IPv6 address in an IPv4 database")
+		}
+	}
+
+	nodeCount := r.metadata.NodeCount
+	for i := 0; i < len(bits)*8 && node < nodeCount; i++ {
+		bit := (bits[i/8] >> (7 - uint(i%8))) & 1
+		node = r.record(node, int(bit))
+	}
+
+	switch {
+	case node == nodeCount:
+		return 0, false, nil
+	case node > nodeCount:
+		offset := node - nodeCount - dataSectionSeparator
+		if offset < 0 || offset >= len(r.data) {
+			return 0, false, fmt.Errorf("record points outside the data section")
+		}
+		return offset, true, nil
+	default:
+		return 0, false, fmt.Errorf("search tree is corrupt")
+	}
+}
+
+// record returns the left (0) or right (1) record of a search tree node
+func (r *Reader) record(node, side int) int {
+	switch r.metadata.RecordSize {
+	case 24:
+		b := r.tree[node*6+side*3:]
+		return int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+	case 28:
+		b := r.tree[node*7:]
+		if side == 0 {
+			return int(b[3]&0xf0)<<20 | int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+		}
+		return int(b[3]&0x0f)<<24 | int(b[4])<<16 | int(b[5])<<8 | int(b[6])
+	default:
+		return int(binary.BigEndian.Uint32(r.tree[node*8+side*4:]))
+	}
+}
+
+// maxDecodeDepth bounds nesting so a corrupt file cannot recurse forever
+const maxDecodeDepth = 64
+
+// decoder decodes values of a MaxMind DB data section
+type decoder struct {
+	data []byte
+}
+
+// decode decodes the value at offset and returns it with the offset of the
+// value that follows it
+func (d decoder) decode(offset, depth int) (interface{}, int, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, fmt.Errorf("data nested too deeply")
+	}
+
+	kind, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if kind == typePointer {
+		// A pointer's value is decoded where it points; decoding continues
+		// after the pointer itself
+		value, _, err := d.decode(size, depth+1)
+		return value, offset, err
+	}
+
+	switch kind {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := 0; i < size; i++ {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key is not a string")
+			}
+			value, next, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[name] = value
+			offset = next
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			value, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > len(d.data) {
+		return nil, 0, fmt.Errorf("value exceeds data section")
+	}
+	b := d.data[offset : offset+size]
+	next := offset + size
+
+	switch kind {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes:
+		return append([]byte(nil), b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("invalid integer size %d", size)
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid integer size %d", size)
+		}
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), next, nil
+	case typeUint128:
+		// Too wide for country lookups to care about; kept as raw bytes
+		return append([]byte(nil), b...), next, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d", kind)
+	}
+}
+
+// control reads the control byte(s) at offset and returns the value's type,
+// its size (for pointers, the offset pointed to) and the offset of its
+// payload
+func (d decoder) control(offset int) (int, int, int, error) {
+	next := func(n int) ([]byte, error) {
+		if offset+n > len(d.data) {
+			return nil, fmt.Errorf("unexpected end of data")
+		}
+		b := d.data[offset : offset+n]
+		offset += n
+		return b, nil
+	}
+
+	b, err := next(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	ctrl := b[0]
+	kind := int(ctrl >> 5)
+
+	if kind == typePointer {
+		n := int(ctrl>>3)&0x3 + 1
+		p, err := next(n)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		vvv := int(ctrl & 0x7)
+		var pointer int
+		switch n {
+		case 1:
+			pointer = vvv<<8 | int(p[0])
+		case 2:
+			pointer = (vvv<<16 | int(p[0])<<8 | int(p[1])) + 2048
+		case 3:
+			pointer = (vvv<<24 | int(p[0])<<16 | int(p[1])<<8 | int(p[2])) + 526336
+		default:
+			pointer = int(binary.BigEndian.Uint32(p))
+		}
+		return kind, pointer, offset, nil
+	}
+
+	if kind == typeExtended {
+		ext, err := next(1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		kind = 7 + int(ext[0])
+	}
+
+	size := int(ctrl & 0x1f)
+	if size >= 29 {
+		extra, err := next(size - 28)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		switch size {
+		case 29:
+			size = 29 + int(extra[0])
+		case 30:
+			size = 285 + (int(extra[0])<<8 | int(extra[1]))
+		default:
+			size = 65821 + (int(extra[0])<<16 | int(extra[1])<<8 | int(extra[2]))
+		}
+	}
+
+	return kind, size, offset, nil
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func uintField(m map[string]interface{}, key string) uint64 {
+	v, _ := m[key].(uint64)
+	return v
+}
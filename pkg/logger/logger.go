@@ -11,24 +11,14 @@ import (
 
 // New creates a new zap logger with the specified level and format
 func New(level, format string) *zap.Logger {
-	// Parse log level
-	var zapLevel zapcore.Level
-	switch strings.ToLower(level) {
-	case "debug":
-		zapLevel = zapcore.DebugLevel
-	case "info":
-		zapLevel = zapcore.InfoLevel
-	case "warn", "warning":
-		zapLevel = zapcore.WarnLevel
-	case "error":
-		zapLevel = zapcore.ErrorLevel
-	case "fatal":
-		zapLevel = zapcore.FatalLevel
-	case "panic":
-		zapLevel = zapcore.PanicLevel
-	default:
-		zapLevel = zapcore.InfoLevel
-	}
+	logger, _ := NewAtomic(level, format)
+	return logger
+}
+
+// NewAtomic creates a logger like New and returns its level, which can be
+// changed while the logger is in use
+func NewAtomic(level, format string) (*zap.Logger, zap.AtomicLevel) {
+	atomicLevel := zap.NewAtomicLevelAt(ParseLevel(level))
 
 	// Configure encoder
 	var encoder zapcore.Encoder
@@ -50,7 +40,7 @@ func New(level, format string) *zap.Logger {
 	core := zapcore.NewCore(
 		encoder,
 		zapcore.AddSync(os.Stdout),
-		zapLevel,
+		atomicLevel,
 	)
 
 	// Add caller information and stack trace for errors
@@ -60,7 +50,27 @@ func New(level, format string) *zap.Logger {
 		zap.AddCallerSkip(0),
 	)
 
-	return logger
+	return logger, atomicLevel
+}
+
+// ParseLevel parses a level name, defaulting to info
+func ParseLevel(level string) zapcore.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return zapcore.DebugLevel
+	case "info":
+		return zapcore.InfoLevel
+	case "warn", "warning":
+		return zapcore.WarnLevel
+	case "error":
+		return zapcore.ErrorLevel
+	case "fatal":
+		return zapcore.FatalLevel
+	case "panic":
+		return zapcore.PanicLevel
+	default:
+		return zapcore.InfoLevel
+	}
 }
 
 // NewWithFile creates a logger that writes to both stdout and a file
@@ -0,0 +1,131 @@
+// Package mail sends multipart email with attachments over SMTP.
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Config identifies an SMTP server and the sender
+type Config struct {
+	// Addr is the server's host:port; STARTTLS is used when offered
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// Attachment is a file attached to a message
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Message is an HTML email with optional attachments
+type Message struct {
+	To          []string
+	Subject     string
+	HTML        string
+	Attachments []Attachment
+}
+
+// Client sends messages through one SMTP server
+type Client struct {
+	cfg Config
+}
+
+// New creates a client for the configured server
+func New(cfg Config) (*Client, error) {
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		return nil, fmt.Errorf("invalid SMTP address %q: %w", cfg.Addr, err)
+	}
+	if cfg.From == "" {
+		return nil, fmt.Errorf("sender address is required")
+	}
+	return &Client{cfg: cfg}, nil
+}
+
+// Send delivers msg to its recipients
+func (c *Client) Send(msg *Message) error {
+	if len(msg.To) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+
+	body, err := c.encode(msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if c.cfg.Username != "" {
+		host, _, _ := net.SplitHostPort(c.cfg.Addr)
+		auth = smtp.PlainAuth("", c.cfg.Username, c.cfg.Password, host)
+	}
+	if err := smtp.SendMail(c.cfg.Addr, auth, c.cfg.From, msg.To, body); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
+
+// encode renders msg as a multipart/mixed MIME message
+func (c *Client) encode(msg *Message, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", c.cfg.From)
+	header("To", strings.Join(msg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+	buf.WriteString("\r\n")
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	writeBase64(part, []byte(msg.HTML))
+
+	for _, attachment := range msg.Attachments {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(part, attachment.Data)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBase64 writes data base64 encoded in lines of 76 characters
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
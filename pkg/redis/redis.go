@@ -0,0 +1,301 @@
+// Package redis is a minimal Redis client speaking RESP2: commands and Lua
+// scripts over a small pool of connections.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTimeout  = 5 * time.Second
+	defaultPoolSize = 8
+)
+
+// Config identifies a Redis server and its credentials
+type Config struct {
+	Addr     string
+	Password string
+	DB       int
+
+	// Timeout bounds dialing and each command; 0 uses 5s
+	Timeout time.Duration
+
+	// PoolSize is how many idle connections are kept; 0 uses 8
+	PoolSize int
+}
+
+// Error is an error reply from the server
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// ErrNil is returned by the typed helpers for a nil reply
+var ErrNil = errors.New("redis: nil reply")
+
+// Client runs commands on one server. It is safe for concurrent use.
+type Client struct {
+	cfg  Config
+	idle chan *conn
+
+	mu      sync.Mutex
+	scripts map[string]string // script -> SHA1
+}
+
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// New creates a client; connections are made when first needed
+func New(cfg Config) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = defaultPoolSize
+	}
+	return &Client{
+		cfg:     cfg,
+		idle:    make(chan *conn, cfg.PoolSize),
+		scripts: make(map[string]string),
+	}
+}
+
+// Do runs a command. Replies are returned as int64, string, nil or
+// []interface{} of those; error replies as Error.
+func (c *Client) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.do(ctx, c.cfg.Timeout, args)
+	var replyErr Error
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection state is unknown after an I/O error
+		cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return reply, err
+}
+
+// Eval runs a Lua script with EVALSHA, loading it with EVAL the first time
+// the server does not know it
+func (c *Client) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	c.mu.Lock()
+	sha, exists := c.scripts[script]
+	if !exists {
+		sum := sha1.Sum([]byte(script))
+		sha = hex.EncodeToString(sum[:])
+		c.scripts[script] = sha
+	}
+	c.mu.Unlock()
+
+	params := make([]interface{}, 0, 3+len(keys)+len(args))
+	params = append(params, "EVALSHA", sha, len(keys))
+	for _, key := range keys {
+		params = append(params, key)
+	}
+	params = append(params, args...)
+
+	reply, err := c.Do(ctx, params...)
+	if err == nil || !strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		return reply, err
+	}
+	params[0], params[1] = "EVAL", script
+	return c.Do(ctx, params...)
+}
+
+// Ping checks the server can be reached
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// Close closes the idle connections
+func (c *Client) Close() error {
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: c.cfg.Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: failed to connect to %s: %w", c.cfg.Addr, err)
+	}
+	cn := &conn{Conn: netConn, reader: bufio.NewReader(netConn)}
+
+	if c.cfg.Password != "" {
+		if _, err := cn.do(ctx, c.cfg.Timeout, []interface{}{"AUTH", c.cfg.Password}); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("redis: authentication failed: %w", err)
+		}
+	}
+	if c.cfg.DB != 0 {
+		if _, err := cn.do(ctx, c.cfg.Timeout, []interface{}{"SELECT", c.cfg.DB}); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("redis: failed to select database %d: %w", c.cfg.DB, err)
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	select {
+	case c.idle <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+// do writes a command and reads its reply
+func (cn *conn) do(ctx context.Context, timeout time.Duration, args []interface{}) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := cn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		value := fmt.Sprint(arg)
+		b.WriteString("$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n")
+	}
+	if _, err := io.WriteString(cn, b.String()); err != nil {
+		return nil, fmt.Errorf("redis: failed to send command: %w", err)
+	}
+
+	return readReply(cn.reader)
+}
+
+// readReply reads one RESP2 reply
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: failed to read reply: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: malformed reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed integer reply %q", line)
+		}
+		return n, nil
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk reply %q", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("redis: failed to read reply: %w", err)
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array reply %q", line)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		var itemErr error
+		for i := range items {
+			item, err := readReply(r)
+			var replyErr Error
+			if err != nil && !errors.As(err, &replyErr) {
+				return nil, err
+			}
+			if err != nil && itemErr == nil {
+				itemErr = err
+			}
+			items[i] = item
+		}
+		return items, itemErr
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", line[0])
+	}
+}
+
+// Int converts a reply to an integer
+func Int(reply interface{}, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch v := reply.(type) {
+	case int64:
+		return v, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case nil:
+		return 0, ErrNil
+	}
+	return 0, fmt.Errorf("redis: unexpected %T reply for an integer", reply)
+}
+
+// Strings converts an array reply to strings; nil items become ""
+func Strings(reply interface{}, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok {
+		if reply == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("redis: unexpected %T reply for an array", reply)
+	}
+	values := make([]string, len(items))
+	for i, item := range items {
+		switch v := item.(type) {
+		case string:
+			values[i] = v
+		case int64:
+			values[i] = strconv.FormatInt(v, 10)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,355 @@
+// Package selfupdate checks a control plane for signed releases and replaces
+// the running binary with them.
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/je265/oceanproxy/pkg/config"
+)
+
+// Components that can be updated
+const (
+	ComponentServer = "server"
+	ComponentCLI    = "cli"
+)
+
+const (
+	checkTimeout    = 30 * time.Second
+	downloadTimeout = 10 * time.Minute
+	maxBinarySize   = 256 << 20
+)
+
+// Release describes one signed build
+type Release struct {
+	Component string `json:"component"`
+	Version   string `json:"version"`
+	Channel   string `json:"channel"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`
+
+	// Signature is the base64 ed25519 signature of SignedMessage
+	Signature string `json:"signature"`
+}
+
+// Manifest is the release list published by the control plane
+type Manifest struct {
+	Releases []Release `json:"releases"`
+}
+
+// SignedMessage is the payload a release signature covers. The channel is
+// part of it so a signed beta build cannot be relabelled as stable.
+func (r *Release) SignedMessage() []byte {
+	return []byte(strings.Join([]string{"oceanproxy-release", r.Component, r.Version, r.Channel, r.OS, r.Arch, strings.ToLower(r.SHA256)}, "\n"))
+}
+
+// Sign sets the release signature
+func (r *Release) Sign(key ed25519.PrivateKey) {
+	r.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, r.SignedMessage()))
+}
+
+// Verify checks the release signature against a base64 ed25519 public key
+func (r *Release) Verify(publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid update public key")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(r.Signature)
+	if err != nil || !ed25519.Verify(ed25519.PublicKey(key), r.SignedMessage(), signature) {
+		return fmt.Errorf("release %s %s has an invalid signature", r.Component, r.Version)
+	}
+
+	return nil
+}
+
+// LoadManifest reads a manifest file
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read release manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse release manifest: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+// Latest returns the newest release matching the filters, or nil
+func (m *Manifest) Latest(component, channel, goos, goarch string) *Release {
+	var latest *Release
+	for i := range m.Releases {
+		r := &m.Releases[i]
+		if r.Component != component || r.Channel != channel || r.OS != goos || r.Arch != goarch {
+			continue
+		}
+		if latest == nil || CompareVersions(r.Version, latest.Version) > 0 {
+			latest = r
+		}
+	}
+	return latest
+}
+
+// CompareVersions compares dotted numeric versions, ignoring a leading "v"
+// and any pre-release suffix. It returns -1, 0 or 1.
+func CompareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+
+	var parts []int
+	for _, field := range strings.Split(v, ".") {
+		n, _ := strconv.Atoi(field)
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// Updater checks the control plane for newer releases of one component
+type Updater struct {
+	cfg       config.Updates
+	logger    *zap.Logger
+	component string
+	current   string
+}
+
+// New creates an updater for a component running version current
+func New(cfg *config.Config, logger *zap.Logger, component, current string) *Updater {
+	return &Updater{
+		cfg:       cfg.Updates,
+		logger:    logger,
+		component: component,
+		current:   current,
+	}
+}
+
+// Check returns the latest release if it is newer than the running version,
+// or nil when up to date
+func (u *Updater) Check(ctx context.Context) (*Release, error) {
+	if u.cfg.ServerURL == "" {
+		return nil, fmt.Errorf("updates.server_url is not set")
+	}
+
+	query := url.Values{
+		"component": {u.component},
+		"channel":   {u.cfg.Channel},
+		"os":        {runtime.GOOS},
+		"arch":      {runtime.GOARCH},
+	}
+	endpoint := strings.TrimSuffix(u.cfg.ServerURL, "/") + "/api/v1/releases/latest?" + query.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build update check request: %w", err)
+	}
+	if u.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+u.cfg.Token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("update check failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("update check returned status %d", resp.StatusCode)
+	}
+
+	var release Release
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&release); err != nil {
+		return nil, fmt.Errorf("failed to parse update check response: %w", err)
+	}
+
+	if CompareVersions(release.Version, u.current) <= 0 {
+		return nil, nil
+	}
+	return &release, nil
+}
+
+// Apply verifies and downloads a release and replaces the running
+// executable. The release must be signed for this node's channel and newer
+// than the running version, so a replayed release cannot cross channels or
+// downgrade the node.
+func (u *Updater) Apply(ctx context.Context, release *Release) error {
+	if release.Component != u.component || release.OS != runtime.GOOS || release.Arch != runtime.GOARCH {
+		return fmt.Errorf("release is for %s %s/%s", release.Component, release.OS, release.Arch)
+	}
+	if release.Channel != u.cfg.Channel {
+		return fmt.Errorf("release is for the %q channel, not %q", release.Channel, u.cfg.Channel)
+	}
+	if CompareVersions(release.Version, u.current) <= 0 {
+		return fmt.Errorf("release %s is not newer than the running %s", release.Version, u.current)
+	}
+	if err := release.Verify(u.cfg.PublicKey); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, release.URL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build download request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBinarySize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read download: %w", err)
+	}
+	if len(data) > maxBinarySize {
+		return fmt.Errorf("download exceeds %d bytes", maxBinarySize)
+	}
+
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, release.SHA256) {
+		return fmt.Errorf("checksum mismatch: got %s, want %s", got, release.SHA256)
+	}
+
+	if err := replaceExecutable(data); err != nil {
+		return err
+	}
+
+	u.logger.Info("Installed update",
+		zap.String("component", u.component),
+		zap.String("from", u.current),
+		zap.String("to", release.Version))
+	u.current = release.Version
+
+	return nil
+}
+
+// replaceExecutable atomically swaps the running binary for data
+func replaceExecutable(data []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("failed to resolve executable: %w", err)
+	}
+
+	info, err := os.Stat(exe)
+	if err != nil {
+		return fmt.Errorf("failed to stat executable: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write update: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write update: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to set update permissions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		return fmt.Errorf("failed to replace executable: %w", err)
+	}
+
+	return nil
+}
+
+// Run checks for and installs updates every check interval until ctx is
+// cancelled, calling updated after a release is installed
+func (u *Updater) Run(ctx context.Context, updated func(version string)) {
+	if !u.cfg.Auto || u.cfg.CheckInterval <= 0 {
+		return
+	}
+
+	u.logger.Info("Starting auto-update checks",
+		zap.String("channel", u.cfg.Channel),
+		zap.Duration("interval", u.cfg.CheckInterval))
+
+	ticker := time.NewTicker(u.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		release, err := u.Check(ctx)
+		if err != nil {
+			u.logger.Warn("Update check failed", zap.Error(err))
+			continue
+		}
+		if release == nil {
+			continue
+		}
+
+		if err := u.Apply(ctx, release); err != nil {
+			u.logger.Error("Failed to install update", zap.String("version", release.Version), zap.Error(err))
+			continue
+		}
+		updated(release.Version)
+		return
+	}
+}
@@ -0,0 +1,253 @@
+// Command bench checks `go test -bench -benchmem` output against the
+// performance budget in budget.yaml, failing when a benchmark's time,
+// bytes or allocations per operation regress beyond the budget's tolerance:
+//
+//	go test -run '^$' -bench . -benchmem -count 3 ./internal/... | go run ./scripts/bench
+//
+// With -update the measured values become the new baselines. Times depend
+// on the machine, so update the baselines on the machine that runs the
+// check.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Budget is the budget file
+type Budget struct {
+	Tolerance  Tolerance              `yaml:"tolerance"`
+	Benchmarks map[string]Measurement `yaml:"benchmarks"`
+}
+
+// Tolerance is how far above its baseline a measurement may be, as a
+// fraction of the baseline
+type Tolerance struct {
+	NsPerOp     float64 `yaml:"ns_per_op"`
+	BytesPerOp  float64 `yaml:"bytes_per_op"`
+	AllocsPerOp float64 `yaml:"allocs_per_op"`
+}
+
+// Measurement is a benchmark's cost per operation
+type Measurement struct {
+	NsPerOp     float64 `yaml:"ns_per_op"`
+	BytesPerOp  float64 `yaml:"bytes_per_op"`
+	AllocsPerOp float64 `yaml:"allocs_per_op"`
+}
+
+// MarshalYAML writes whole numbers, which read and diff better than the
+// exponents yaml uses for large floats
+func (m Measurement) MarshalYAML() (interface{}, error) {
+	return struct {
+		NsPerOp     int64 `yaml:"ns_per_op"`
+		BytesPerOp  int64 `yaml:"bytes_per_op"`
+		AllocsPerOp int64 `yaml:"allocs_per_op"`
+	}{int64(math.Ceil(m.NsPerOp)), int64(math.Ceil(m.BytesPerOp)), int64(math.Ceil(m.AllocsPerOp))}, nil
+}
+
+// benchmarkLine matches a benchmark result; the -N suffix is GOMAXPROCS
+var benchmarkLine = regexp.MustCompile(`^(Benchmark\S+?)(?:-\d+)?\s+\d+\s+(.*)$`)
+
+func main() {
+	budgetPath := flag.String("budget", "scripts/bench/budget.yaml", "Budget file")
+	update := flag.Bool("update", false, "Write the measured values to the budget file as the new baselines")
+	flag.Parse()
+
+	budget, err := loadBudget(*budgetPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	// The benchmark output is passed through so the run stays readable
+	measured, failedRun, err := parse(io.TeeReader(os.Stdin, os.Stdout))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to read benchmark output: %v\n", err)
+		os.Exit(1)
+	}
+	if failedRun {
+		fmt.Fprintln(os.Stderr, "❌ Benchmarks failed")
+		os.Exit(1)
+	}
+	if len(measured) == 0 {
+		fmt.Fprintln(os.Stderr, "❌ No benchmark results; run go test with -bench and -benchmem")
+		os.Exit(1)
+	}
+
+	if *update {
+		if budget.Benchmarks == nil {
+			budget.Benchmarks = make(map[string]Measurement)
+		}
+		for name, runs := range measured {
+			budget.Benchmarks[name] = median(runs)
+		}
+		if err := saveBudget(*budgetPath, budget); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Updated %d baselines in %s\n", len(measured), *budgetPath)
+		return
+	}
+
+	if failures := check(budget, measured); len(failures) > 0 {
+		fmt.Println()
+		for _, failure := range failures {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", failure)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("\n✅ %d benchmarks within budget\n", len(measured))
+}
+
+// parse reads benchmark results by name, one measurement per -count run,
+// and whether any package failed
+func parse(r io.Reader) (map[string][]Measurement, bool, error) {
+	measured := make(map[string][]Measurement)
+	failed := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "FAIL") || strings.HasPrefix(line, "--- FAIL") {
+			failed = true
+			continue
+		}
+
+		match := benchmarkLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		// Values come in pairs: 123 ns/op	456 B/op	7 allocs/op
+		var m Measurement
+		fields := strings.Fields(match[2])
+		for i := 0; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				continue
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				m.NsPerOp = value
+			case "B/op":
+				m.BytesPerOp = value
+			case "allocs/op":
+				m.AllocsPerOp = value
+			}
+		}
+		measured[match[1]] = append(measured[match[1]], m)
+	}
+	return measured, failed, scanner.Err()
+}
+
+// check returns the budget violations. Budgeted benchmarks missing from the
+// run fail too, so renaming one cannot drop it from the budget unnoticed.
+func check(budget *Budget, measured map[string][]Measurement) []string {
+	var failures []string
+
+	names := make([]string, 0, len(budget.Benchmarks))
+	for name := range budget.Benchmarks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		baseline := budget.Benchmarks[name]
+		runs, ok := measured[name]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%s: not run", name))
+			continue
+		}
+		got := median(runs)
+
+		for _, metric := range []struct {
+			unit      string
+			got, base float64
+			tolerance float64
+		}{
+			{"ns/op", got.NsPerOp, baseline.NsPerOp, budget.Tolerance.NsPerOp},
+			{"B/op", got.BytesPerOp, baseline.BytesPerOp, budget.Tolerance.BytesPerOp},
+			{"allocs/op", got.AllocsPerOp, baseline.AllocsPerOp, budget.Tolerance.AllocsPerOp},
+		} {
+			limit := metric.base * (1 + metric.tolerance)
+			if metric.got > limit {
+				failures = append(failures, fmt.Sprintf("%s: %.0f %s exceeds the budget of %.1f (baseline %.0f +%.0f%%)",
+					name, metric.got, metric.unit, limit, metric.base, metric.tolerance*100))
+			}
+		}
+	}
+
+	for name := range measured {
+		if _, ok := budget.Benchmarks[name]; !ok {
+			fmt.Fprintf(os.Stderr, "⚠️  %s has no budget; add it with -update\n", name)
+		}
+	}
+	return failures
+}
+
+// median returns the median of each metric across runs
+func median(runs []Measurement) Measurement {
+	pick := func(value func(Measurement) float64) float64 {
+		values := make([]float64, 0, len(runs))
+		for _, run := range runs {
+			values = append(values, value(run))
+		}
+		sort.Float64s(values)
+		return values[len(values)/2]
+	}
+	return Measurement{
+		NsPerOp:     pick(func(m Measurement) float64 { return m.NsPerOp }),
+		BytesPerOp:  pick(func(m Measurement) float64 { return m.BytesPerOp }),
+		AllocsPerOp: pick(func(m Measurement) float64 { return m.AllocsPerOp }),
+	}
+}
+
+func loadBudget(path string) (*Budget, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read budget: %w", err)
+	}
+	var budget Budget
+	if err := yaml.Unmarshal(data, &budget); err != nil {
+		return nil, fmt.Errorf("failed to parse budget %s: %w", path, err)
+	}
+	return &budget, nil
+}
+
+// saveBudget writes the budget back, keeping the header comment
+func saveBudget(path string, budget *Budget) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read budget: %w", err)
+	}
+	var header strings.Builder
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		header.WriteString(line)
+	}
+
+	var encoded bytes.Buffer
+	encoded.WriteString(header.String())
+	encoder := yaml.NewEncoder(&encoded)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(budget); err != nil {
+		return fmt.Errorf("failed to encode budget: %w", err)
+	}
+	if err := os.WriteFile(path, encoded.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write budget: %w", err)
+	}
+	return nil
+}
@@ -60,7 +60,7 @@ cat > "$PROXY_CONFIG" << EOF
 
 daemon
 log $LOG_DIR/3proxy_${PLAN_ID}.log D
-logformat "- +_L%t.%. %N.%p %E %U %C:%c %R:%r %O %I %h %T"
+logformat "- +_L%t.%. %N.%p %E %U %C:%c %R:%r %O %I %h %D %T"
 rotate 30
 
 # Customer authentication (what customers use)